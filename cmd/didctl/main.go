@@ -0,0 +1,300 @@
+// didctl exports and imports gateway configuration bundles through the
+// admin API:
+//
+//	didctl export -o staging.jws
+//	didctl import -dry-run staging.jws
+//	didctl import staging.jws
+//	didctl verify -kid staging-1 -key <base64url Ed25519 public key> staging.jws
+//
+// The admin API is reached at -url (or GATEWAY_ADMIN_URL) with the Bearer
+// token in GATEWAY_ADMIN_TOKEN.
+//
+// It also generates a Grafana dashboard and Prometheus alert rules matched
+// to the gateway's metrics, offline:
+//
+//	didctl monitoring -job did-gateway -o deploy/monitoring/generated
+//
+// and a local dev stack (gateway, Redis, Postgres, issuer, upstream, did:web
+// test server, OpenTelemetry collector) seeded with policies, a trusted
+// issuer and test DIDs:
+//
+//	didctl devstack -o .devstack && docker compose -f .devstack/docker-compose.yml up -d --build
+package main
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/example/privacy-gateway/internal/gateway/bundle"
+	"github.com/example/privacy-gateway/internal/gateway/devstack"
+	"github.com/example/privacy-gateway/internal/gateway/monitoring"
+	"github.com/example/privacy-gateway/internal/shared/crypto"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+	var err error
+	switch os.Args[1] {
+	case "export":
+		err = export(os.Args[2:])
+	case "import":
+		err = importBundle(os.Args[2:])
+	case "verify":
+		err = verify(os.Args[2:])
+	case "monitoring":
+		err = generateMonitoring(os.Args[2:])
+	case "devstack":
+		err = generateDevStack(os.Args[2:])
+	default:
+		usage()
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "didctl:", err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: didctl export|import|verify|monitoring|devstack [flags] [file]")
+	os.Exit(2)
+}
+
+// client talks to the admin API
+type client struct {
+	url   string
+	token string
+	http  *http.Client
+}
+
+func newClient(fs *flag.FlagSet) *client {
+	c := &client{http: &http.Client{Timeout: time.Minute}}
+	fs.StringVar(&c.url, "url", envOr("GATEWAY_ADMIN_URL", "http://localhost:8080"), "gateway admin base URL")
+	c.token = os.Getenv("GATEWAY_ADMIN_TOKEN")
+	return c
+}
+
+func (c *client) do(method, path string, body []byte) ([]byte, error) {
+	req, err := http.NewRequest(method, c.url+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/jose")
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s %s: %s: %s", method, path, resp.Status, bytes.TrimSpace(data))
+	}
+	return data, nil
+}
+
+func export(args []string) error {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	c := newClient(fs)
+	out := fs.String("o", "", "output file (default stdout)")
+	_ = fs.Parse(args)
+
+	data, err := c.do(http.MethodGet, "/admin/v1/bundle", nil)
+	if err != nil {
+		return err
+	}
+	if *out == "" {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+	return os.WriteFile(*out, data, 0o600)
+}
+
+func importBundle(args []string) error {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	c := newClient(fs)
+	dryRun := fs.Bool("dry-run", false, "only report what would change")
+	_ = fs.Parse(args)
+	if fs.NArg() != 1 {
+		return fmt.Errorf("import needs a bundle file")
+	}
+
+	token, err := os.ReadFile(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	data, err := c.do(http.MethodPost, fmt.Sprintf("/admin/v1/bundle?dry_run=%t", *dryRun), token)
+	if err != nil {
+		return err
+	}
+	var res bundle.Result
+	if err := json.Unmarshal(data, &res); err != nil {
+		return err
+	}
+	verb := "imported"
+	if res.DryRun {
+		verb = "would import"
+	}
+	fmt.Printf("%s bundle from %q (%s, signed by %s)\n", verb, res.Source, res.CreatedAt.Format(time.RFC3339), res.KeyID)
+	printChanges("policies", res.Policies)
+	printChanges("issuers", res.Issuers)
+	return nil
+}
+
+func printChanges(kind string, ch bundle.Changes) {
+	fmt.Printf("%s: %d created, %d updated, %d unchanged\n", kind, len(ch.Created), len(ch.Updated), len(ch.Unchanged))
+	for _, id := range ch.Created {
+		fmt.Printf("  + %s\n", id)
+	}
+	for _, id := range ch.Updated {
+		fmt.Printf("  ~ %s\n", id)
+	}
+	for _, id := range ch.Extra {
+		fmt.Printf("  ? %s (not in bundle, left in place)\n", id)
+	}
+}
+
+func verify(args []string) error {
+	fs := flag.NewFlagSet("verify", flag.ExitOnError)
+	kid := fs.String("kid", "", "signing key ID")
+	key := fs.String("key", "", "base64url Ed25519 public key")
+	_ = fs.Parse(args)
+	if fs.NArg() != 1 || *kid == "" || *key == "" {
+		return fmt.Errorf("verify needs -kid, -key and a bundle file")
+	}
+
+	pub, err := crypto.DecodePublicKey(*key)
+	if err != nil {
+		return err
+	}
+	token, err := os.ReadFile(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	b, _, err := bundle.Verify(string(token), map[string]ed25519.PublicKey{*kid: pub})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("valid bundle v%d from %q (%s): %d policies, %d issuers\n",
+		b.Version, b.Source, b.CreatedAt.Format(time.RFC3339), len(b.Policies), len(b.Issuers))
+	return nil
+}
+
+func generateMonitoring(args []string) error {
+	fs := flag.NewFlagSet("monitoring", flag.ExitOnError)
+	var cfg monitoring.Config
+	fs.StringVar(&cfg.Job, "job", "did-gateway", "Prometheus job label of the gateway")
+	fs.StringVar(&cfg.Datasource, "datasource", "Prometheus", "Grafana datasource name")
+	fs.StringVar(&cfg.Title, "title", "DID Gateway", "dashboard title")
+	fs.StringVar(&cfg.UID, "uid", "did-gateway-generated", "dashboard UID")
+	out := fs.String("o", ".", "output directory")
+	_ = fs.Parse(args)
+
+	dashboard, err := monitoring.Dashboard(cfg)
+	if err != nil {
+		return err
+	}
+	rules, err := monitoring.AlertRules(cfg)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(*out, 0o755); err != nil {
+		return err
+	}
+	files := map[string][]byte{
+		"grafana-dashboard.json": append(dashboard, '\n'),
+		"prometheus-alerts.yaml": rules,
+	}
+	for name, data := range files {
+		path := filepath.Join(*out, name)
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			return err
+		}
+		fmt.Println("wrote", path)
+	}
+	return nil
+}
+
+func generateDevStack(args []string) error {
+	fs := flag.NewFlagSet("devstack", flag.ExitOnError)
+	var cfg devstack.Config
+	fs.StringVar(&cfg.AdminToken, "admin-token", "admin-token", "gateway admin token")
+	fs.IntVar(&cfg.Wallets, "wallets", 3, "did:key test wallets to generate")
+	fs.StringVar(&cfg.Seed, "seed", "devstack", "seed of the generated keys; the same seed gives the same DIDs")
+	out := fs.String("o", ".devstack", "output directory")
+	_ = fs.Parse(args)
+
+	root, err := repoRoot()
+	if err != nil {
+		return err
+	}
+	abs, err := filepath.Abs(*out)
+	if err != nil {
+		return err
+	}
+	if cfg.RepoRoot, err = filepath.Rel(abs, root); err != nil {
+		return err
+	}
+	files, err := devstack.Generate(cfg)
+	if err != nil {
+		return err
+	}
+	for name, data := range files {
+		path := filepath.Join(*out, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return err
+		}
+		mode := os.FileMode(0o644)
+		if filepath.Ext(path) == ".sh" {
+			mode = 0o755
+		}
+		if err := os.WriteFile(path, data, mode); err != nil {
+			return err
+		}
+	}
+	fmt.Printf("wrote %d files to %s; start with: docker compose -f %s up -d --build\n",
+		len(files), *out, filepath.Join(*out, "docker-compose.yml"))
+	return nil
+}
+
+// repoRoot finds the module root above the working directory, the build
+// context of the dev stack
+func repoRoot() (string, error) {
+	dir, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+	for {
+		if _, err := os.Stat(filepath.Join(dir, "go.mod")); err == nil {
+			return dir, nil
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", fmt.Errorf("run devstack inside the repository")
+		}
+		dir = parent
+	}
+}
+
+func envOr(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/bits"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Endpoints, as reported
+const (
+	endpointChallenge = "challenge"
+	endpointVerify    = "verify"
+	endpointProxy     = "proxy"
+)
+
+// flow is one unit of load started at the configured rate
+type flow func(c *client, id *identity, st *stats)
+
+var flows = map[string]flow{
+	"challenge": func(c *client, id *identity, st *stats) { _, _ = c.challenge(id, st) },
+	"auth":      func(c *client, id *identity, st *stats) { _, _ = c.auth(id, st) },
+	"proxy":     (*client).proxy,
+}
+
+// client issues the gateway requests of the flows
+type client struct {
+	base      string
+	http      *http.Client
+	proxyPath string
+}
+
+// send performs req and returns the status, body and latency; status is 0
+// if the request failed before a response
+func (c *client) send(req *http.Request) (int, []byte, time.Duration, error) {
+	start := time.Now()
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return 0, nil, time.Since(start), err
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	resp.Body.Close()
+	return resp.StatusCode, body, time.Since(start), err
+}
+
+// do sends req and records it under endpoint. Statuses other than 2xx are
+// returned as errors along with the body.
+func (c *client) do(req *http.Request, endpoint string, st *stats) (int, []byte, error) {
+	status, body, elapsed, err := c.send(req)
+	st.record(endpoint, elapsed, status)
+	if err == nil && status/100 != 2 {
+		err = fmt.Errorf("%s: status %d", endpoint, status)
+	}
+	return status, body, err
+}
+
+// challenge fetches a challenge for id, solving a proof of work if the
+// gateway asks for one
+func (c *client) challenge(id *identity, st *stats) (string, error) {
+	u := c.base + "/v1/auth/challenge?did=" + url.QueryEscape(id.did)
+	req, _ := http.NewRequest(http.MethodGet, u, nil)
+	status, body, err := c.do(req, endpointChallenge, st)
+	if status == http.StatusTooManyRequests {
+		var pow struct {
+			PoW *struct {
+				Challenge  string `json:"challenge"`
+				Difficulty int    `json:"difficulty"`
+			} `json:"pow"`
+		}
+		if json.Unmarshal(body, &pow) != nil || pow.PoW == nil {
+			return "", err
+		}
+		req, _ = http.NewRequest(http.MethodGet, u, nil)
+		req.Header.Set("X-PoW", pow.PoW.Challenge+":"+solvePoW(pow.PoW.Challenge, pow.PoW.Difficulty))
+		_, body, err = c.do(req, endpointChallenge, st)
+	}
+	if err != nil {
+		return "", err
+	}
+	var res struct {
+		Challenge string `json:"challenge"`
+	}
+	if err := json.Unmarshal(body, &res); err != nil || res.Challenge == "" {
+		return "", fmt.Errorf("challenge: malformed response")
+	}
+	return res.Challenge, nil
+}
+
+// auth runs challenge and verify for id and keeps the access token
+func (c *client) auth(id *identity, st *stats) (string, error) {
+	ch, err := c.challenge(id, st)
+	if err != nil {
+		return "", err
+	}
+	payload, _ := json.Marshal(map[string]interface{}{
+		"did":       id.did,
+		"challenge": ch,
+		"signature": base64.RawURLEncoding.EncodeToString(ed25519.Sign(id.priv, []byte(ch))),
+	})
+	req, _ := http.NewRequest(http.MethodPost, c.base+"/v1/auth/verify", bytes.NewReader(payload))
+	req.Header.Set("Content-Type", "application/json")
+	_, body, err := c.do(req, endpointVerify, st)
+	if err != nil {
+		return "", err
+	}
+	var res struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.Unmarshal(body, &res); err != nil || res.AccessToken == "" {
+		return "", fmt.Errorf("verify: malformed response")
+	}
+	id.mu.Lock()
+	id.token = res.AccessToken
+	id.mu.Unlock()
+	return res.AccessToken, nil
+}
+
+// proxy calls the proxied route with id's token, authenticating first when
+// id has none and again when the token was rejected
+func (c *client) proxy(id *identity, st *stats) {
+	id.mu.Lock()
+	token := id.token
+	id.mu.Unlock()
+	for retried := false; ; retried = true {
+		if token == "" {
+			var err error
+			if token, err = c.auth(id, st); err != nil {
+				return
+			}
+		}
+		req, _ := http.NewRequest(http.MethodGet, c.base+c.proxyPath, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		status, _, elapsed, _ := c.send(req)
+		if status == http.StatusUnauthorized && !retried {
+			// Expired token: not a proxy failure
+			token = ""
+			continue
+		}
+		st.record(endpointProxy, elapsed, status)
+		return
+	}
+}
+
+// solvePoW finds a nonce such that SHA-256(challenge ":" nonce) starts with
+// difficulty zero bits
+func solvePoW(challenge string, difficulty int) string {
+	prefix := []byte(challenge + ":")
+	for n := 0; ; n++ {
+		nonce := strconv.Itoa(n)
+		sum := sha256.Sum256(append(prefix[:len(prefix):len(prefix)], nonce...))
+		if leadingZeros(sum[:]) >= difficulty {
+			return nonce
+		}
+	}
+}
+
+func leadingZeros(b []byte) int {
+	n := 0
+	for _, x := range b {
+		if x != 0 {
+			return n + bits.LeadingZeros8(x)
+		}
+		n += 8
+	}
+	return n
+}
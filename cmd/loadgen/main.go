@@ -0,0 +1,234 @@
+// loadgen drives the gateway's challenge, verify and proxy paths at a fixed
+// request rate with real did:key identities and reports latency percentiles:
+//
+//	loadgen -url http://localhost:8080 -rps 200 -duration 1m -o result.json
+//
+// -rps is the rate at which flows start, open loop: a slow gateway doesn't
+// slow the schedule down, so queueing shows up in the latencies. -mix weights
+// the flows:
+//
+//	challenge  GET /v1/auth/challenge
+//	auth       challenge, Ed25519 signature, POST /v1/auth/verify
+//	proxy      GET -proxy-path with a Bearer token from an earlier auth flow
+//
+// With -baseline it compares the run against an earlier -o report and exits 1
+// if p95 or p99 latency of any endpoint or the achieved rate regressed by
+// more than -max-regression, or the error rate exceeds -max-error-rate. It
+// always exits 1 if the achieved rate falls short of -rps by that margin.
+package main
+
+import (
+	"context"
+	"crypto/ed25519"
+	"flag"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/example/privacy-gateway/internal/shared/crypto"
+)
+
+func main() {
+	var cfg config
+	flag.StringVar(&cfg.url, "url", envOr("GATEWAY_URL", "http://localhost:8080"), "gateway base URL")
+	flag.Float64Var(&cfg.rps, "rps", 100, "flows started per second")
+	flag.DurationVar(&cfg.duration, "duration", 30*time.Second, "measured run length")
+	flag.DurationVar(&cfg.warmup, "warmup", 5*time.Second, "unmeasured load before the run")
+	flag.StringVar(&cfg.mix, "mix", "challenge=2,auth=1,proxy=2", "flow weights")
+	flag.StringVar(&cfg.proxyPath, "proxy-path", "/api/echo", "path of the proxied route")
+	flag.IntVar(&cfg.identities, "identities", 100, "did:key identities to spread the load over")
+	flag.IntVar(&cfg.concurrency, "concurrency", 512, "flows in flight at most; later starts are counted as skipped")
+	flag.DurationVar(&cfg.timeout, "timeout", 5*time.Second, "per request timeout")
+	out := flag.String("o", "", "write the report as JSON to this file")
+	baseline := flag.String("baseline", "", "report of an earlier run to compare against")
+	maxRegression := flag.Float64("max-regression", 0.10, "allowed latency and throughput regression")
+	maxErrorRate := flag.Float64("max-error-rate", 0.01, "allowed fraction of failed requests")
+	noise := flag.Duration("noise", 2*time.Millisecond, "latency increases below this are never regressions")
+	flag.Parse()
+
+	if err := run(cfg, *out, *baseline, gate{maxRegression: *maxRegression, maxErrorRate: *maxErrorRate, noise: *noise}); err != nil {
+		fmt.Fprintln(os.Stderr, "loadgen:", err)
+		os.Exit(1)
+	}
+}
+
+type config struct {
+	url         string
+	rps         float64
+	duration    time.Duration
+	warmup      time.Duration
+	mix         string
+	proxyPath   string
+	identities  int
+	concurrency int
+	timeout     time.Duration
+}
+
+func run(cfg config, out, baseline string, g gate) error {
+	if cfg.rps <= 0 || cfg.identities <= 0 || cfg.concurrency <= 0 {
+		return fmt.Errorf("-rps, -identities and -concurrency must be positive")
+	}
+	mix, err := parseMix(cfg.mix)
+	if err != nil {
+		return err
+	}
+	var base *Report
+	if baseline != "" {
+		if base, err = readReport(baseline); err != nil {
+			return err
+		}
+	}
+
+	ids := make([]*identity, cfg.identities)
+	for i := range ids {
+		pub, priv, err := crypto.GenerateEd25519Key()
+		if err != nil {
+			return err
+		}
+		ids[i] = &identity{did: crypto.EncodeDidKey(pub), priv: priv}
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConnsPerHost = cfg.concurrency
+	c := &client{
+		base:      strings.TrimSuffix(cfg.url, "/"),
+		http:      &http.Client{Transport: transport, Timeout: cfg.timeout},
+		proxyPath: cfg.proxyPath,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	fmt.Fprintf(os.Stderr, "loadgen: %s at %g flows/s, %s warmup, %s measured, mix %s\n", c.base, cfg.rps, cfg.warmup, cfg.duration, cfg.mix)
+	if cfg.warmup > 0 {
+		drive(ctx, c, ids, mix, cfg, cfg.warmup, newStats())
+	}
+	st := newStats()
+	elapsed, started, skipped := drive(ctx, c, ids, mix, cfg, cfg.duration, st)
+	rep := st.report(cfg, elapsed, started, skipped)
+	rep.print(os.Stdout)
+
+	if out != "" {
+		if err := rep.write(out); err != nil {
+			return err
+		}
+	}
+	if problems := g.check(rep, base); len(problems) > 0 {
+		for _, p := range problems {
+			fmt.Fprintln(os.Stderr, "FAIL:", p)
+		}
+		return fmt.Errorf("%d check(s) failed", len(problems))
+	}
+	return ctx.Err()
+}
+
+// drive starts flows at cfg.rps for d and waits for them to finish. It
+// returns how long the run took, how many flows started and how many starts
+// were skipped because cfg.concurrency flows were already in flight.
+func drive(ctx context.Context, c *client, ids []*identity, mix []weighted, cfg config, d time.Duration, st *stats) (time.Duration, int, int) {
+	ctx, cancel := context.WithTimeout(ctx, d)
+	defer cancel()
+	interval := time.Duration(float64(time.Second) / cfg.rps)
+	slots := make(chan struct{}, cfg.concurrency)
+	rng := rand.New(rand.NewSource(time.Now().UnixNano()))
+
+	var wg sync.WaitGroup
+	started, skipped := 0, 0
+	start := time.Now()
+	// Flows start at start+n*interval, not a ticker's pace, so a late wake-up
+	// catches up instead of losing starts
+	for n := 0; ; n++ {
+		wait := time.Until(start.Add(time.Duration(n) * interval))
+		if wait > 0 {
+			t := time.NewTimer(wait)
+			select {
+			case <-t.C:
+			case <-ctx.Done():
+				t.Stop()
+			}
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		select {
+		case slots <- struct{}{}:
+		default:
+			skipped++
+			continue
+		}
+		f := pick(mix, rng.Float64())
+		id := ids[rng.Intn(len(ids))]
+		started++
+		wg.Add(1)
+		go func() {
+			defer func() { <-slots; wg.Done() }()
+			f(c, id, st)
+		}()
+	}
+	elapsed := time.Since(start)
+	wg.Wait()
+	return elapsed, started, skipped
+}
+
+// weighted is one flow of the mix with its cumulative share
+type weighted struct {
+	until float64
+	flow  flow
+}
+
+// parseMix parses "name=weight,..." into cumulative shares
+func parseMix(s string) ([]weighted, error) {
+	var mix []weighted
+	total := 0.0
+	for _, part := range strings.Split(s, ",") {
+		name, w, ok := strings.Cut(strings.TrimSpace(part), "=")
+		weight, err := strconv.ParseFloat(w, 64)
+		f, known := flows[name]
+		if !ok || err != nil || weight < 0 || !known {
+			return nil, fmt.Errorf("invalid -mix entry %q: want challenge, auth or proxy=<weight>", part)
+		}
+		if weight == 0 {
+			continue
+		}
+		total += weight
+		mix = append(mix, weighted{until: total, flow: f})
+	}
+	if total == 0 {
+		return nil, fmt.Errorf("-mix has no flow with a positive weight")
+	}
+	for i := range mix {
+		mix[i].until /= total
+	}
+	return mix, nil
+}
+
+func pick(mix []weighted, x float64) flow {
+	for _, w := range mix {
+		if x < w.until {
+			return w.flow
+		}
+	}
+	return mix[len(mix)-1].flow
+}
+
+// identity is a did:key wallet and its current access token
+type identity struct {
+	did  string
+	priv ed25519.PrivateKey
+
+	mu    sync.Mutex
+	token string
+}
+
+func envOr(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// stats collects the latency and outcome of every request
+type stats struct {
+	mu        sync.Mutex
+	endpoints map[string]*samples
+}
+
+type samples struct {
+	latencies []time.Duration
+	errors    int // Transport errors and error statuses other than 429
+	throttled int // 429s, e.g. proof of work demands
+}
+
+func newStats() *stats {
+	return &stats{endpoints: make(map[string]*samples)}
+}
+
+// record adds a request; status 0 means it failed without a response
+func (s *stats) record(endpoint string, d time.Duration, status int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e := s.endpoints[endpoint]
+	if e == nil {
+		e = &samples{}
+		s.endpoints[endpoint] = e
+	}
+	e.latencies = append(e.latencies, d)
+	switch {
+	case status == http.StatusTooManyRequests:
+		e.throttled++
+	case status == 0 || status >= 400:
+		e.errors++
+	}
+}
+
+// Report is the result of a run, also the -baseline format
+type Report struct {
+	URL       string    `json:"url"`
+	Mix       string    `json:"mix"`
+	TargetRPS float64   `json:"target_rps"`
+	Started   time.Time `json:"started"`
+	// Seconds is the measured run length
+	Seconds float64 `json:"seconds"`
+	// FlowsPerSecond is the achieved flow start rate
+	FlowsPerSecond float64 `json:"flows_per_second"`
+	Skipped        int     `json:"skipped"`
+	// Endpoints by name: challenge, verify, proxy
+	Endpoints map[string]EndpointReport `json:"endpoints"`
+}
+
+// EndpointReport summarizes the requests to one endpoint. Latencies are in
+// milliseconds.
+type EndpointReport struct {
+	Requests  int     `json:"requests"`
+	Errors    int     `json:"errors"`
+	Throttled int     `json:"throttled"`
+	RPS       float64 `json:"rps"`
+	P50       float64 `json:"p50_ms"`
+	P90       float64 `json:"p90_ms"`
+	P95       float64 `json:"p95_ms"`
+	P99       float64 `json:"p99_ms"`
+	Max       float64 `json:"max_ms"`
+}
+
+func (s *stats) report(cfg config, elapsed time.Duration, started, skipped int) *Report {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	secs := elapsed.Seconds()
+	r := &Report{
+		URL:            cfg.url,
+		Mix:            cfg.mix,
+		TargetRPS:      cfg.rps,
+		Started:        time.Now().Add(-elapsed).UTC().Truncate(time.Second),
+		Seconds:        round(secs),
+		FlowsPerSecond: round(float64(started) / secs),
+		Skipped:        skipped,
+		Endpoints:      make(map[string]EndpointReport),
+	}
+	for name, e := range s.endpoints {
+		sort.Slice(e.latencies, func(i, j int) bool { return e.latencies[i] < e.latencies[j] })
+		r.Endpoints[name] = EndpointReport{
+			Requests:  len(e.latencies),
+			Errors:    e.errors,
+			Throttled: e.throttled,
+			RPS:       round(float64(len(e.latencies)) / secs),
+			P50:       percentile(e.latencies, 0.50),
+			P90:       percentile(e.latencies, 0.90),
+			P95:       percentile(e.latencies, 0.95),
+			P99:       percentile(e.latencies, 0.99),
+			Max:       percentile(e.latencies, 1),
+		}
+	}
+	return r
+}
+
+// percentile returns the nearest-rank percentile of sorted latencies in ms
+func percentile(sorted []time.Duration, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	i := int(math.Ceil(p*float64(len(sorted)))) - 1
+	if i < 0 {
+		i = 0
+	}
+	return round(float64(sorted[i]) / float64(time.Millisecond))
+}
+
+func round(x float64) float64 {
+	return math.Round(x*100) / 100
+}
+
+func (r *Report) names() []string {
+	names := make([]string, 0, len(r.Endpoints))
+	for name := range r.Endpoints {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (r *Report) print(w io.Writer) {
+	fmt.Fprintf(w, "%.0fs at %.1f flows/s (target %g), %d skipped\n\n", r.Seconds, r.FlowsPerSecond, r.TargetRPS, r.Skipped)
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "endpoint\trequests\trps\terrors\t429\tp50\tp90\tp95\tp99\tmax\t")
+	for _, name := range r.names() {
+		e := r.Endpoints[name]
+		fmt.Fprintf(tw, "%s\t%d\t%.1f\t%d\t%d\t%.2fms\t%.2fms\t%.2fms\t%.2fms\t%.2fms\t\n",
+			name, e.Requests, e.RPS, e.Errors, e.Throttled, e.P50, e.P90, e.P95, e.P99, e.Max)
+	}
+	tw.Flush()
+}
+
+func (r *Report) write(path string) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
+
+func readReport(path string) (*Report, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var r Report
+	if err := json.Unmarshal(data, &r); err != nil {
+		return nil, fmt.Errorf("baseline %s: %w", path, err)
+	}
+	return &r, nil
+}
+
+// gate holds the pass criteria of a run
+type gate struct {
+	maxRegression float64
+	maxErrorRate  float64
+	noise         time.Duration
+}
+
+// check returns the criteria the run fails: the achieved rate against the
+// target, the error rate, and with a baseline each endpoint's p95 and p99
+// and the achieved rate against the baseline's
+func (g gate) check(r, base *Report) []string {
+	var problems []string
+	if r.FlowsPerSecond < r.TargetRPS*(1-g.maxRegression) {
+		problems = append(problems, fmt.Sprintf("achieved %.1f flows/s, target %g", r.FlowsPerSecond, r.TargetRPS))
+	}
+	for _, name := range r.names() {
+		e := r.Endpoints[name]
+		if e.Requests > 0 && float64(e.Errors)/float64(e.Requests) > g.maxErrorRate {
+			problems = append(problems, fmt.Sprintf("%s: %d of %d requests failed", name, e.Errors, e.Requests))
+		}
+	}
+	if base == nil {
+		return problems
+	}
+
+	if base.TargetRPS != r.TargetRPS || base.Mix != r.Mix {
+		problems = append(problems, fmt.Sprintf("baseline ran %g flows/s with mix %s; compare like with like", base.TargetRPS, base.Mix))
+		return problems
+	}
+	if r.FlowsPerSecond < base.FlowsPerSecond*(1-g.maxRegression) {
+		problems = append(problems, fmt.Sprintf("throughput %.1f flows/s, baseline %.1f", r.FlowsPerSecond, base.FlowsPerSecond))
+	}
+	noise := float64(g.noise) / float64(time.Millisecond)
+	for _, name := range base.names() {
+		b := base.Endpoints[name]
+		e, ok := r.Endpoints[name]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("%s: no requests, baseline had %d", name, b.Requests))
+			continue
+		}
+		for _, q := range []struct {
+			label     string
+			got, want float64
+		}{{"p95", e.P95, b.P95}, {"p99", e.P99, b.P99}} {
+			if q.got > q.want*(1+g.maxRegression) && q.got-q.want > noise {
+				problems = append(problems, fmt.Sprintf("%s %s %.2fms, baseline %.2fms (+%.0f%%)",
+					name, q.label, q.got, q.want, (q.got/q.want-1)*100))
+			}
+		}
+	}
+	return problems
+}
@@ -0,0 +1,158 @@
+package accountlink
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/example/privacy-gateway/internal/gateway/store"
+	"github.com/example/privacy-gateway/internal/shared/audit"
+	"github.com/example/privacy-gateway/internal/shared/models"
+	"github.com/example/privacy-gateway/internal/shared/observability"
+	"github.com/example/privacy-gateway/internal/shared/validate"
+)
+
+var ErrInvalidRequest = errors.New("invalid account link request")
+
+// maxCached bounds the lookup cache; it is cleared when full
+const maxCached = 100000
+
+// Store persists account links
+type Store interface {
+	GetAccountLink(ctx context.Context, did string) (models.AccountLink, error)
+	ListAccountLinks(ctx context.Context, accountID string) ([]models.AccountLink, error)
+	UpsertAccountLink(ctx context.Context, l models.AccountLink) error
+	DeleteAccountLink(ctx context.Context, did string) error
+}
+
+// Config configures account linking
+type Config struct {
+	Store    Store
+	Audit    audit.Sink
+	CacheTTL time.Duration // How long replicas cache lookups (default 30s)
+	Logger   *slog.Logger
+}
+
+// Linker maps DIDs to internal account IDs, so systems keyed by user ID can
+// accept DID logins: the token minted for a linked DID carries the account
+// ID. Lookups are cached per replica for CacheTTL, which bounds how long an
+// unlinked DID keeps getting the old account ID on other replicas.
+type Linker struct {
+	cfg   Config
+	audit *audit.Recorder
+
+	mu    sync.Mutex
+	cache map[string]cachedLink
+}
+
+type cachedLink struct {
+	accountID string // Empty when the DID is not linked
+	expires   time.Time
+}
+
+// NewLinker creates a linker
+func NewLinker(cfg Config) (*Linker, error) {
+	if cfg.Store == nil {
+		return nil, errors.New("account linker requires a store")
+	}
+	if cfg.CacheTTL == 0 {
+		cfg.CacheTTL = 30 * time.Second
+	}
+	cfg.Logger = observability.Component(cfg.Logger, observability.ComponentAuth)
+	return &Linker{cfg: cfg, audit: audit.NewRecorder(cfg.Audit, cfg.Logger, nil), cache: make(map[string]cachedLink)}, nil
+}
+
+// AccountID returns the account linked to did, or "" when there is none
+func (l *Linker) AccountID(ctx context.Context, did string) (string, error) {
+	now := time.Now()
+	l.mu.Lock()
+	c, ok := l.cache[did]
+	l.mu.Unlock()
+	if ok && now.Before(c.expires) {
+		return c.accountID, nil
+	}
+	link, err := l.cfg.Store.GetAccountLink(ctx, did)
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		return "", err
+	}
+	l.mu.Lock()
+	if len(l.cache) >= maxCached {
+		l.cache = make(map[string]cachedLink)
+	}
+	l.cache[did] = cachedLink{accountID: link.AccountID, expires: now.Add(l.cfg.CacheTTL)}
+	l.mu.Unlock()
+	return link.AccountID, nil
+}
+
+// Enrich sets claims.AccountID from the subject's link. Token minting calls
+// it before signing; an error should fail the mint rather than issue a
+// token that silently lacks the account.
+func (l *Linker) Enrich(ctx context.Context, claims *models.AccessTokenClaims) error {
+	id, err := l.AccountID(ctx, claims.Subject)
+	if err != nil {
+		return fmt.Errorf("look up account link: %w", err)
+	}
+	claims.AccountID = id
+	return nil
+}
+
+// Get returns the link of did
+func (l *Linker) Get(ctx context.Context, did string) (models.AccountLink, error) {
+	return l.cfg.Store.GetAccountLink(ctx, did)
+}
+
+// List returns the DIDs linked to an account
+func (l *Linker) List(ctx context.Context, accountID string) ([]models.AccountLink, error) {
+	return l.cfg.Store.ListAccountLinks(ctx, accountID)
+}
+
+// Link links did to accountID, moving it from the account it was linked to
+// before. Tokens already minted keep their account ID until they expire.
+func (l *Linker) Link(ctx context.Context, did, accountID, actor string) (models.AccountLink, error) {
+	if err := validate.ValidateDID(did); err != nil {
+		return models.AccountLink{}, fmt.Errorf("%w: %v", ErrInvalidRequest, err)
+	}
+	accountID = strings.TrimSpace(accountID)
+	if accountID == "" || len(accountID) > 255 {
+		return models.AccountLink{}, fmt.Errorf("%w: account_id is required (at most 255 bytes)", ErrInvalidRequest)
+	}
+	previous, err := l.cfg.Store.GetAccountLink(ctx, did)
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		return models.AccountLink{}, err
+	}
+	link := models.AccountLink{DID: did, AccountID: accountID, LinkedBy: actor}
+	if err := l.cfg.Store.UpsertAccountLink(ctx, link); err != nil {
+		return models.AccountLink{}, err
+	}
+	l.forget(did)
+	meta := map[string]interface{}{"account_id": accountID}
+	if previous.AccountID != "" && previous.AccountID != accountID {
+		meta["previous_account_id"] = previous.AccountID
+	}
+	l.audit.Record(ctx, "account.link", did, actor, "success", meta)
+	return l.cfg.Store.GetAccountLink(ctx, did)
+}
+
+// Unlink removes did's link; it returns store.ErrNotFound if there is none
+func (l *Linker) Unlink(ctx context.Context, did, actor string) error {
+	previous, err := l.cfg.Store.GetAccountLink(ctx, did)
+	if err != nil {
+		return err
+	}
+	if err := l.cfg.Store.DeleteAccountLink(ctx, did); err != nil {
+		return err
+	}
+	l.forget(did)
+	l.audit.Record(ctx, "account.unlink", did, actor, "success", map[string]interface{}{"account_id": previous.AccountID})
+	return nil
+}
+
+func (l *Linker) forget(did string) {
+	l.mu.Lock()
+	delete(l.cache, did)
+	l.mu.Unlock()
+}
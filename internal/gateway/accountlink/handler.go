@@ -0,0 +1,94 @@
+package accountlink
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/example/privacy-gateway/internal/gateway/store"
+	"github.com/example/privacy-gateway/internal/shared/httpx"
+	"github.com/example/privacy-gateway/internal/shared/models"
+)
+
+type linkRequest struct {
+	AccountID string `json:"account_id"`
+}
+
+// Handler serves the admin account link endpoints:
+//
+//	GET    /admin/v1/account-links?account_id={id}  DIDs linked to an account
+//	GET    /admin/v1/account-links/{did}
+//	PUT    /admin/v1/account-links/{did}            {"account_id"}
+//	DELETE /admin/v1/account-links/{did}
+//
+// {did} is path-escaped. actor identifies the caller in the audit trail.
+func Handler(l *Linker, actor func(*http.Request) string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		who := "admin"
+		if actor != nil {
+			who = actor(r)
+		}
+		rest := strings.Trim(strings.TrimPrefix(r.URL.EscapedPath(), "/admin/v1/account-links"), "/")
+		did, err := url.PathUnescape(rest)
+		if err != nil || strings.Contains(rest, "/") {
+			httpx.WriteJSON(w, http.StatusNotFound, httpx.ErrorResponse{Error: "not found"})
+			return
+		}
+
+		switch {
+		case did == "" && r.Method == http.MethodGet:
+			accountID := r.URL.Query().Get("account_id")
+			if accountID == "" {
+				httpx.WriteJSON(w, http.StatusBadRequest, httpx.ErrorResponse{Error: "account_id is required"})
+				return
+			}
+			links, err := l.List(r.Context(), accountID)
+			if err != nil {
+				httpx.WriteJSON(w, http.StatusInternalServerError, httpx.ErrorResponse{Error: "failed to list account links"})
+				return
+			}
+			if links == nil {
+				links = []models.AccountLink{}
+			}
+			httpx.WriteJSON(w, http.StatusOK, links)
+		case did != "" && r.Method == http.MethodGet:
+			link, err := l.Get(r.Context(), did)
+			switch {
+			case errors.Is(err, store.ErrNotFound):
+				httpx.WriteJSON(w, http.StatusNotFound, httpx.ErrorResponse{Error: "DID is not linked"})
+			case err != nil:
+				httpx.WriteJSON(w, http.StatusInternalServerError, httpx.ErrorResponse{Error: "failed to load account link"})
+			default:
+				httpx.WriteJSON(w, http.StatusOK, link)
+			}
+		case did != "" && r.Method == http.MethodPut:
+			var req linkRequest
+			if err := httpx.DecodeJSON(r, &req); err != nil {
+				httpx.WriteJSON(w, http.StatusBadRequest, httpx.ErrorResponse{Error: "invalid request body"})
+				return
+			}
+			link, err := l.Link(r.Context(), did, req.AccountID, who)
+			switch {
+			case errors.Is(err, ErrInvalidRequest):
+				httpx.WriteJSON(w, http.StatusBadRequest, httpx.ErrorResponse{Error: err.Error()})
+			case err != nil:
+				httpx.WriteJSON(w, http.StatusInternalServerError, httpx.ErrorResponse{Error: "failed to link DID"})
+			default:
+				httpx.WriteJSON(w, http.StatusOK, link)
+			}
+		case did != "" && r.Method == http.MethodDelete:
+			err := l.Unlink(r.Context(), did, who)
+			switch {
+			case errors.Is(err, store.ErrNotFound):
+				httpx.WriteJSON(w, http.StatusNotFound, httpx.ErrorResponse{Error: "DID is not linked"})
+			case err != nil:
+				httpx.WriteJSON(w, http.StatusInternalServerError, httpx.ErrorResponse{Error: "failed to unlink DID"})
+			default:
+				w.WriteHeader(http.StatusNoContent)
+			}
+		default:
+			httpx.WriteJSON(w, http.StatusNotFound, httpx.ErrorResponse{Error: "not found"})
+		}
+	}
+}
@@ -0,0 +1,382 @@
+package adminauth
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/example/privacy-gateway/internal/gateway/did"
+	"github.com/example/privacy-gateway/internal/gateway/store"
+	"github.com/example/privacy-gateway/internal/shared/audit"
+	"github.com/example/privacy-gateway/internal/shared/challenge"
+	"github.com/example/privacy-gateway/internal/shared/crypto"
+	"github.com/example/privacy-gateway/internal/shared/models"
+	"github.com/example/privacy-gateway/internal/shared/observability"
+	"github.com/example/privacy-gateway/internal/shared/region"
+	"github.com/example/privacy-gateway/internal/shared/timing"
+)
+
+var (
+	ErrUnauthenticated = errors.New("admin authentication required")
+	ErrForbidden       = errors.New("admin role does not allow this action")
+	ErrNotAdmin        = errors.New("DID is not an enabled admin")
+	ErrChallengeUsed   = errors.New("challenge already used")
+	ErrBadChallenge    = errors.New("invalid admin challenge")
+	ErrBadSignature    = errors.New("invalid challenge signature")
+	ErrInvalidRole     = errors.New("invalid admin role")
+)
+
+// Role is an admin role. Roles are ordered: each role includes the
+// permissions of the ones below it.
+type Role string
+
+const (
+	RoleViewer        Role = "viewer"
+	RoleOperator      Role = "operator"
+	RoleSecurityAdmin Role = "security-admin"
+)
+
+// BreakGlassActor is the audit actor for requests authenticated with the
+// static admin token
+const BreakGlassActor = "break-glass"
+
+func (r Role) rank() int {
+	switch r {
+	case RoleViewer:
+		return 1
+	case RoleOperator:
+		return 2
+	case RoleSecurityAdmin:
+		return 3
+	}
+	return 0
+}
+
+// Valid reports whether r is a known role
+func (r Role) Valid() bool {
+	return r.rank() > 0
+}
+
+// Allows reports whether r includes the permissions of min
+func (r Role) Allows(min Role) bool {
+	return r.Valid() && r.rank() >= min.rank()
+}
+
+// Rule sets the minimum roles for admin paths under Prefix. Read applies to
+// GET and HEAD, Mutate to every other method.
+type Rule struct {
+	Prefix string
+	Read   Role
+	Mutate Role
+}
+
+// DefaultRules gates security-sensitive resources (admin identities, trusted
+// issuers, API keys, revocation) and runtime controls (fault injection, log
+// levels) behind security-admin; operators handle day-to-day policy, rate
+// limit and issuance changes; viewers can read everything else. Every admin
+// endpoint is served under /admin/v1.
+var DefaultRules = []Rule{
+	{Prefix: "/admin/v1/admins", Read: RoleSecurityAdmin, Mutate: RoleSecurityAdmin},
+	{Prefix: "/admin/v1/issuers", Read: RoleViewer, Mutate: RoleSecurityAdmin},
+	{Prefix: "/admin/v1/apikeys", Read: RoleViewer, Mutate: RoleSecurityAdmin},
+	{Prefix: "/admin/v1/revocations", Read: RoleViewer, Mutate: RoleSecurityAdmin},
+	{Prefix: "/admin/v1/status/revocations", Read: RoleSecurityAdmin, Mutate: RoleSecurityAdmin},
+	{Prefix: "/admin/v1/bundle", Read: RoleViewer, Mutate: RoleSecurityAdmin}, // Imports replace trusted issuers
+	{Prefix: "/admin/v1/gitops", Read: RoleViewer, Mutate: RoleSecurityAdmin},
+	{Prefix: "/admin/v1/recovery", Read: RoleViewer, Mutate: RoleSecurityAdmin}, // Approvals rebind accounts
+	{Prefix: "/admin/v1/account-links", Read: RoleViewer, Mutate: RoleSecurityAdmin},
+	{Prefix: "/admin/v1/trust", Read: RoleViewer, Mutate: RoleSecurityAdmin},
+	{Prefix: "/admin/v1/devices", Read: RoleViewer, Mutate: RoleSecurityAdmin},
+	{Prefix: "/admin/v1/login-alerts", Read: RoleViewer, Mutate: RoleSecurityAdmin},
+	{Prefix: "/admin/v1/chaos", Read: RoleSecurityAdmin, Mutate: RoleSecurityAdmin}, // Injects faults into live traffic
+	{Prefix: "/admin/v1/loglevel", Read: RoleViewer, Mutate: RoleSecurityAdmin},     // Debug logs can carry request data
+	{Prefix: "/admin/v1/slo", Read: RoleViewer, Mutate: RoleSecurityAdmin},
+	{Prefix: "/admin/v1/policies/simulate", Read: RoleOperator, Mutate: RoleOperator}, // For policy authors
+	{Prefix: "/", Read: RoleViewer, Mutate: RoleOperator},
+}
+
+// AdminStore loads and manages admin identities
+type AdminStore interface {
+	GetAdmin(ctx context.Context, did string) (models.Admin, error)
+	ListAdmins(ctx context.Context) ([]models.Admin, error)
+	UpsertAdmin(ctx context.Context, a models.Admin) error
+	DeleteAdmin(ctx context.Context, did string) error
+}
+
+// Config configures admin authentication
+type Config struct {
+	Challenges *challenge.Generator
+	Admins     AdminStore
+	Resolver   did.Resolver // Resolves non-did:key admins (default did.KeyResolver{})
+	Audit      audit.Sink
+	Rules      []Rule        // Longest matching prefix wins (default DefaultRules)
+	SessionTTL time.Duration // Admin session lifetime (default 1h)
+	// StaticToken enables the legacy X-Admin-Token header as a break-glass
+	// security-admin credential. Leave empty to require DID login.
+	StaticToken string
+	// Replay checks challenge nonces across regions (optional; nonces are
+	// burned in the local Redis without it)
+	Replay *region.Guard
+	// FailureFloor is the minimum duration of a failed login (default
+	// 250ms), so an unknown or unresolvable DID, a bad challenge and a bad
+	// signature can't be told apart by response time
+	FailureFloor time.Duration
+	Logger       *slog.Logger
+}
+
+// Principal is the authenticated admin for a request
+type Principal struct {
+	DID  string
+	Role Role
+}
+
+// Actor returns the audit actor for the principal
+func (p Principal) Actor() string {
+	if p.DID == "" {
+		return BreakGlassActor
+	}
+	return p.DID
+}
+
+// Session is an issued admin session token
+type Session struct {
+	Token     string `json:"access_token"`
+	TokenType string `json:"token_type"`
+	ExpiresIn int64  `json:"expires_in"`
+	Role      Role   `json:"role"`
+}
+
+// Authenticator logs admins in with a signed gateway challenge and enforces
+// role rules on the admin API. Sessions live in Redis so any replica can
+// check them; the admin record is re-read on every request, so disabling an
+// admin or changing a role takes effect immediately.
+type Authenticator struct {
+	client *redis.Client
+	cfg    Config
+	audit  *audit.Recorder
+}
+
+// NewAuthenticator creates an authenticator
+func NewAuthenticator(client *redis.Client, cfg Config) (*Authenticator, error) {
+	if cfg.Challenges == nil || cfg.Admins == nil {
+		return nil, errors.New("admin auth requires a challenge generator and admin store")
+	}
+	if cfg.Resolver == nil {
+		cfg.Resolver = did.KeyResolver{}
+	}
+	if len(cfg.Rules) == 0 {
+		cfg.Rules = DefaultRules
+	}
+	for _, rule := range cfg.Rules {
+		if !rule.Read.Valid() || !rule.Mutate.Valid() {
+			return nil, fmt.Errorf("%w: rule for %s", ErrInvalidRole, rule.Prefix)
+		}
+	}
+	if cfg.SessionTTL == 0 {
+		cfg.SessionTTL = time.Hour
+	}
+	if cfg.FailureFloor == 0 {
+		cfg.FailureFloor = 250 * time.Millisecond
+	}
+	cfg.Logger = observability.Component(cfg.Logger, observability.ComponentAuth)
+	return &Authenticator{client: client, cfg: cfg, audit: audit.NewRecorder(cfg.Audit, cfg.Logger, nil)}, nil
+}
+
+func nonceKey(nonce string) string {
+	return "adm:n|" + nonce
+}
+
+func sessionKey(token string) string {
+	return "adm:s|" + crypto.HashSecret(token)
+}
+
+// Challenge issues a login challenge for an admin DID. Unknown DIDs get a
+// challenge too, so the endpoint doesn't reveal who is an admin.
+func (a *Authenticator) Challenge(adminDID string) (challenge.Challenge, error) {
+	return a.cfg.Challenges.Generate(adminDID)
+}
+
+// Login verifies a signed challenge and opens a session for an enabled admin
+func (a *Authenticator) Login(ctx context.Context, adminDID, challengeStr, signature string) (*Session, error) {
+	start := time.Now()
+	s, err := a.login(ctx, adminDID, challengeStr, signature)
+	if err != nil {
+		a.audit.Record(ctx, "admin.login", adminDID, adminDID, "denied", map[string]interface{}{"reason": err.Error()})
+		timing.Floor(a.cfg.FailureFloor).Wait(ctx, start)
+		return nil, err
+	}
+	a.audit.Record(ctx, "admin.login", adminDID, adminDID, "success", map[string]interface{}{"role": s.Role})
+	return s, nil
+}
+
+func (a *Authenticator) login(ctx context.Context, adminDID, challengeStr, signature string) (*Session, error) {
+	// Admin challenges are issued unbound, so a bound one fails here
+	c, err := a.cfg.Challenges.Verify(challengeStr, adminDID, challenge.Binding{})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrBadChallenge, err)
+	}
+	if err := a.verifySignature(ctx, adminDID, challengeStr, signature); err != nil {
+		return nil, err
+	}
+	// Burn the nonce only after the signature checks out, so a forged
+	// request can't spend a legitimate admin's challenge
+	ttl := time.Unix(c.ExpiresAt, 0).Sub(a.cfg.Challenges.Clock().Now())
+	if ttl <= 0 {
+		ttl = time.Second
+	}
+	if err := a.burnNonce(ctx, c.Nonce, ttl); err != nil {
+		return nil, err
+	}
+
+	admin, err := a.cfg.Admins.GetAdmin(ctx, adminDID)
+	if errors.Is(err, store.ErrNotFound) || (err == nil && !admin.Enabled) {
+		return nil, ErrNotAdmin
+	}
+	if err != nil {
+		return nil, err
+	}
+	role := Role(admin.Role)
+	if !role.Valid() {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidRole, admin.Role)
+	}
+
+	token, err := crypto.RandomToken(32)
+	if err != nil {
+		return nil, err
+	}
+	if err := a.client.Set(ctx, sessionKey(token), adminDID, a.cfg.SessionTTL).Err(); err != nil {
+		return nil, err
+	}
+	return &Session{
+		Token:     token,
+		TokenType: "Bearer",
+		ExpiresIn: int64(a.cfg.SessionTTL.Seconds()),
+		Role:      role,
+	}, nil
+}
+
+// verifySignature checks the challenge signature against the did:key key or,
+// for other methods, any authentication key in the resolved document. Paths
+// without a key to check still do one verification, see crypto.VerifyDecoy.
+func (a *Authenticator) verifySignature(ctx context.Context, adminDID, msg, sig string) error {
+	if strings.HasPrefix(adminDID, "did:key:") {
+		pub, err := crypto.DecodeDidKey(adminDID)
+		if err != nil {
+			crypto.VerifyDecoy(msg, sig)
+			return fmt.Errorf("%w: %v", ErrBadSignature, err)
+		}
+		if err := crypto.VerifySignature(pub, msg, sig); err != nil {
+			return fmt.Errorf("%w: %v", ErrBadSignature, err)
+		}
+		return nil
+	}
+	doc, err := a.cfg.Resolver.Resolve(ctx, adminDID, did.ResolveOptions{})
+	if err != nil {
+		crypto.VerifyDecoy(msg, sig)
+		return fmt.Errorf("%w: resolve %s: %v", ErrBadSignature, adminDID, err)
+	}
+	checked := 0
+	for _, ref := range doc.Authentication {
+		pub, err := doc.AuthenticationKey(ref.ID)
+		if err != nil || len(pub) != ed25519.PublicKeySize {
+			continue
+		}
+		checked++
+		if crypto.VerifySignature(pub, msg, sig) == nil {
+			return nil
+		}
+	}
+	if checked == 0 {
+		crypto.VerifyDecoy(msg, sig)
+	}
+	return ErrBadSignature
+}
+
+// Logout ends a session
+func (a *Authenticator) Logout(ctx context.Context, token string) error {
+	return a.client.Del(ctx, sessionKey(token)).Err()
+}
+
+// Authenticate resolves the caller from a Bearer session token or, when
+// configured, the break-glass static token
+func (a *Authenticator) Authenticate(ctx context.Context, bearer, staticToken string) (Principal, error) {
+	if a.cfg.StaticToken != "" && staticToken != "" {
+		if subtle.ConstantTimeCompare([]byte(staticToken), []byte(a.cfg.StaticToken)) == 1 {
+			return Principal{Role: RoleSecurityAdmin}, nil
+		}
+		return Principal{}, ErrUnauthenticated
+	}
+	if bearer == "" {
+		return Principal{}, ErrUnauthenticated
+	}
+	adminDID, err := a.client.Get(ctx, sessionKey(bearer)).Result()
+	if err == redis.Nil {
+		return Principal{}, ErrUnauthenticated
+	}
+	if err != nil {
+		return Principal{}, err
+	}
+	admin, err := a.cfg.Admins.GetAdmin(ctx, adminDID)
+	if errors.Is(err, store.ErrNotFound) || (err == nil && !admin.Enabled) {
+		return Principal{}, ErrUnauthenticated
+	}
+	if err != nil {
+		return Principal{}, err
+	}
+	return Principal{DID: adminDID, Role: Role(admin.Role)}, nil
+}
+
+// Required returns the minimum role for method on path
+func (a *Authenticator) Required(method, path string) Role {
+	var best *Rule
+	for i := range a.cfg.Rules {
+		rule := &a.cfg.Rules[i]
+		if !matchPrefix(path, rule.Prefix) {
+			continue
+		}
+		if best == nil || len(rule.Prefix) > len(best.Prefix) {
+			best = rule
+		}
+	}
+	if best == nil {
+		return RoleSecurityAdmin
+	}
+	if method == "GET" || method == "HEAD" {
+		return best.Read
+	}
+	return best.Mutate
+}
+
+// matchPrefix matches whole path segments, so /admin/v1/admins doesn't cover /v1/adminsx
+func matchPrefix(path, prefix string) bool {
+	if prefix == "/" || path == prefix {
+		return true
+	}
+	return strings.HasPrefix(path, strings.TrimSuffix(prefix, "/")+"/")
+}
+
+// burnNonce marks a challenge nonce used
+func (a *Authenticator) burnNonce(ctx context.Context, nonce string, ttl time.Duration) error {
+	if a.cfg.Replay != nil {
+		err := a.cfg.Replay.Claim(ctx, nonceKey(nonce), nonce, ttl)
+		if errors.Is(err, region.ErrReplayed) || errors.Is(err, region.ErrForeignRegion) {
+			return fmt.Errorf("%w: %v", ErrChallengeUsed, err)
+		}
+		return err
+	}
+	fresh, err := a.client.SetNX(ctx, nonceKey(nonce), 1, ttl).Result()
+	if err != nil {
+		return err
+	}
+	if !fresh {
+		return ErrChallengeUsed
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package adminauth_test
+
+import (
+	"testing"
+
+	"github.com/example/privacy-gateway/internal/gateway/adminauth"
+	"github.com/example/privacy-gateway/internal/shared/challenge"
+)
+
+// TestDefaultRulesRequiredRole pins the role each admin path needs, so a
+// new endpoint can't silently fall through to the catch-all rule
+func TestDefaultRulesRequiredRole(t *testing.T) {
+	gen, err := challenge.NewGenerator(challenge.Config{Audience: "admin", Domain: "localhost"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	auth, err := adminauth.NewAuthenticator(nil, adminauth.Config{Challenges: gen, Admins: adminStore{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		method, path string
+		want         adminauth.Role
+	}{
+		{"GET", "/admin/v1/admins", adminauth.RoleSecurityAdmin},
+		{"PUT", "/admin/v1/admins/did:key:z6Mk", adminauth.RoleSecurityAdmin},
+		{"GET", "/admin/v1/issuers", adminauth.RoleViewer},
+		{"PUT", "/admin/v1/issuers/did:web:issuer.example", adminauth.RoleSecurityAdmin},
+		{"DELETE", "/admin/v1/apikeys/k1", adminauth.RoleSecurityAdmin},
+		{"PATCH", "/admin/v1/revocations/default", adminauth.RoleSecurityAdmin},
+		{"POST", "/admin/v1/status/revocations", adminauth.RoleSecurityAdmin},
+		{"POST", "/admin/v1/bundle", adminauth.RoleSecurityAdmin},
+		{"POST", "/admin/v1/gitops/sync", adminauth.RoleSecurityAdmin},
+		{"POST", "/admin/v1/recovery/r1/approve", adminauth.RoleSecurityAdmin},
+		{"PUT", "/admin/v1/account-links/did:key:z6Mk", adminauth.RoleSecurityAdmin},
+		{"DELETE", "/admin/v1/trust/did:key:z6Mk", adminauth.RoleSecurityAdmin},
+		{"POST", "/admin/v1/devices/did:key:z6Mk", adminauth.RoleSecurityAdmin},
+		{"POST", "/admin/v1/login-alerts/did:key:z6Mk", adminauth.RoleSecurityAdmin},
+		{"GET", "/admin/v1/chaos", adminauth.RoleSecurityAdmin},
+		{"PUT", "/admin/v1/chaos", adminauth.RoleSecurityAdmin},
+		{"GET", "/admin/v1/loglevel", adminauth.RoleViewer},
+		{"PUT", "/admin/v1/loglevel", adminauth.RoleSecurityAdmin},
+		{"GET", "/admin/v1/slo", adminauth.RoleViewer},
+		{"GET", "/admin/v1/policies", adminauth.RoleViewer},
+		{"PUT", "/admin/v1/policies/premium", adminauth.RoleOperator},
+		{"POST", "/admin/v1/policies/simulate", adminauth.RoleOperator},
+		{"GET", "/admin/v1/policies/simulate", adminauth.RoleOperator},
+		{"PUT", "/admin/v1/ratelimits/overrides", adminauth.RoleOperator},
+		{"POST", "/admin/v1/issuance/offers", adminauth.RoleOperator},
+		{"PUT", "/admin/v1/chaosx", adminauth.RoleOperator},
+	}
+	for _, c := range cases {
+		if got := auth.Required(c.method, c.path); got != c.want {
+			t.Errorf("%s %s: required %s, want %s", c.method, c.path, got, c.want)
+		}
+	}
+}
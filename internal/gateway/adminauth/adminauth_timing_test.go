@@ -0,0 +1,156 @@
+package adminauth_test
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"io"
+	"log/slog"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+
+	"github.com/example/privacy-gateway/internal/gateway/adminauth"
+	"github.com/example/privacy-gateway/internal/gateway/did"
+	"github.com/example/privacy-gateway/internal/gateway/store"
+	"github.com/example/privacy-gateway/internal/shared/challenge"
+	"github.com/example/privacy-gateway/internal/shared/crypto"
+	"github.com/example/privacy-gateway/internal/shared/models"
+)
+
+type adminStore map[string]models.Admin
+
+func (s adminStore) GetAdmin(_ context.Context, d string) (models.Admin, error) {
+	a, ok := s[d]
+	if !ok {
+		return models.Admin{}, store.ErrNotFound
+	}
+	return a, nil
+}
+func (s adminStore) ListAdmins(context.Context) ([]models.Admin, error) { return nil, nil }
+func (s adminStore) UpsertAdmin(context.Context, models.Admin) error    { return nil }
+func (s adminStore) DeleteAdmin(context.Context, string) error          { return nil }
+
+// webResolver serves did:web documents after a delay and reports others as
+// not found right away
+type webResolver struct {
+	docs  map[string]*did.Document
+	delay time.Duration
+}
+
+func (r webResolver) Resolve(ctx context.Context, d string, opts did.ResolveOptions) (*did.Document, error) {
+	doc, ok := r.docs[d]
+	if !ok {
+		return nil, did.ErrNotFound
+	}
+	time.Sleep(r.delay)
+	return doc, nil
+}
+
+// TestLoginFailureTiming checks that failed logins take the same time
+// whatever the reason, so response times don't reveal which DIDs exist,
+// resolve or are admins
+func TestLoginFailureTiming(t *testing.T) {
+	const (
+		floor     = 60 * time.Millisecond
+		tolerance = 20 * time.Millisecond
+		rounds    = 5
+	)
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	gen, err := challenge.NewGenerator(challenge.Config{Audience: "admin", Domain: "localhost"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	adminPub, _, _ := crypto.GenerateEd25519Key()
+	strangerPub, strangerPriv, _ := crypto.GenerateEd25519Key()
+	webPub, _, _ := crypto.GenerateEd25519Key()
+	admin := crypto.EncodeDidKey(adminPub)
+	stranger := crypto.EncodeDidKey(strangerPub)
+	webDID := "did:web:admin.example.com"
+	resolver := webResolver{delay: 30 * time.Millisecond, docs: map[string]*did.Document{webDID: {
+		ID: webDID,
+		VerificationMethod: []did.VerificationMethod{{
+			ID: "#key-1", Type: "JsonWebKey2020", Controller: webDID,
+			PublicKeyJwk: map[string]interface{}{"kty": "OKP", "crv": "Ed25519", "x": base64.RawURLEncoding.EncodeToString(webPub)},
+		}},
+		Authentication: []did.VerificationRef{{ID: "#key-1"}},
+	}}}
+
+	auth, err := adminauth.NewAuthenticator(client, adminauth.Config{
+		Challenges: gen,
+		Admins: adminStore{
+			admin:  {DID: admin, Role: string(adminauth.RoleSecurityAdmin), Enabled: true},
+			webDID: {DID: webDID, Role: string(adminauth.RoleViewer), Enabled: true},
+		},
+		Resolver:     resolver,
+		FailureFloor: floor,
+		Logger:       slog.New(slog.NewTextHandler(io.Discard, nil)),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// login signs a fresh challenge for subject with priv (a random key
+	// when nil) and presents it as claimed
+	login := func(claimed, subject string, priv ed25519.PrivateKey) error {
+		c, err := gen.Generate(subject)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if priv == nil {
+			_, priv, _ = crypto.GenerateEd25519Key()
+		}
+		msg := c.String()
+		sig := base64.RawURLEncoding.EncodeToString(ed25519.Sign(priv, []byte(msg)))
+		_, err = auth.Login(context.Background(), claimed, msg, sig)
+		return err
+	}
+
+	cases := []struct {
+		name string
+		run  func() error
+	}{
+		{"bad signature for admin did:key", func() error { return login(admin, admin, nil) }},
+		{"malformed did:key", func() error { return login("did:key:zNotAKey", "did:key:zNotAKey", nil) }},
+		{"valid signature, not an admin", func() error { return login(stranger, stranger, strangerPriv) }},
+		{"did:web not found", func() error { return login("did:web:nobody.example.com", "did:web:nobody.example.com", nil) }},
+		{"bad signature for did:web admin", func() error { return login(webDID, webDID, nil) }},
+		{"challenge for another DID", func() error { return login(admin, stranger, nil) }},
+	}
+
+	medians := make(map[string]time.Duration, len(cases))
+	for _, tc := range cases {
+		var took []time.Duration
+		for i := 0; i < rounds; i++ {
+			start := time.Now()
+			err := tc.run()
+			took = append(took, time.Since(start))
+			if err == nil {
+				t.Fatalf("%s: login succeeded", tc.name)
+			}
+			if took[i] < floor {
+				t.Errorf("%s: failed in %v, under the %v floor", tc.name, took[i], floor)
+			}
+		}
+		sort.Slice(took, func(i, j int) bool { return took[i] < took[j] })
+		medians[tc.name] = took[rounds/2]
+	}
+
+	var lo, hi time.Duration
+	for _, m := range medians {
+		if lo == 0 || m < lo {
+			lo = m
+		}
+		if m > hi {
+			hi = m
+		}
+	}
+	if hi-lo > tolerance {
+		t.Errorf("failure timings differ by %v (tolerance %v): %v", hi-lo, tolerance, medians)
+	}
+}
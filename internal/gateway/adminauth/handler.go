@@ -0,0 +1,232 @@
+package adminauth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/example/privacy-gateway/internal/gateway/store"
+	"github.com/example/privacy-gateway/internal/shared/httpx"
+	"github.com/example/privacy-gateway/internal/shared/models"
+	"github.com/example/privacy-gateway/internal/shared/validate"
+)
+
+type ctxKey struct{}
+
+// FromContext returns the admin principal set by Middleware
+func FromContext(ctx context.Context) (Principal, bool) {
+	p, ok := ctx.Value(ctxKey{}).(Principal)
+	return p, ok
+}
+
+// Actor returns the audit actor for an admin request, for handlers that take
+// an actor func (e.g. issuance.OffersHandler)
+func Actor(r *http.Request) string {
+	if p, ok := FromContext(r.Context()); ok {
+		return p.Actor()
+	}
+	return "admin"
+}
+
+type challengeRequest struct {
+	DID string `json:"did"`
+}
+
+type challengeResponse struct {
+	Challenge string `json:"challenge"`
+	ExpiresAt int64  `json:"expires_at"`
+}
+
+type loginRequest struct {
+	DID       string `json:"did"`
+	Challenge string `json:"challenge"`
+	Signature string `json:"signature"`
+}
+
+type adminRequest struct {
+	Role    string `json:"role"`
+	Enabled *bool  `json:"enabled,omitempty"`
+}
+
+// LoginHandler serves the admin login flow, mounted outside Middleware:
+//
+//	POST /v1/admin/challenge  {"did"} -> challenge to sign
+//	POST /v1/admin/login      {"did", "challenge", "signature"} -> session token
+//	POST /v1/admin/logout     ends the Bearer session
+func LoginHandler(a *Authenticator) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			httpx.WriteJSON(w, http.StatusMethodNotAllowed, httpx.ErrorResponse{Error: "method not allowed"})
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/admin/challenge":
+			var req challengeRequest
+			if err := httpx.DecodeJSONLimit(r, &req, 4<<10); err != nil || validate.ValidateDID(req.DID) != nil {
+				httpx.WriteJSON(w, http.StatusBadRequest, httpx.ErrorResponse{Error: "a valid did is required"})
+				return
+			}
+			c, err := a.Challenge(req.DID)
+			if err != nil {
+				httpx.WriteJSON(w, http.StatusInternalServerError, httpx.ErrorResponse{Error: "failed to issue challenge"})
+				return
+			}
+			httpx.WriteJSON(w, http.StatusOK, challengeResponse{Challenge: c.String(), ExpiresAt: c.ExpiresAt})
+		case "/v1/admin/login":
+			var req loginRequest
+			if err := httpx.DecodeJSONLimit(r, &req, 16<<10); err != nil {
+				httpx.WriteJSON(w, http.StatusBadRequest, httpx.ErrorResponse{Error: "invalid request body"})
+				return
+			}
+			sess, err := a.Login(r.Context(), req.DID, req.Challenge, req.Signature)
+			switch {
+			case errors.Is(err, ErrBadChallenge), errors.Is(err, ErrBadSignature), errors.Is(err, ErrChallengeUsed),
+				errors.Is(err, ErrNotAdmin), errors.Is(err, ErrInvalidRole):
+				// One answer for every rejection, so probing doesn't reveal admin DIDs
+				httpx.WriteJSON(w, http.StatusUnauthorized, httpx.ErrorResponse{Error: "admin login failed"})
+			case err != nil:
+				httpx.WriteJSON(w, http.StatusInternalServerError, httpx.ErrorResponse{Error: "admin login failed"})
+			default:
+				w.Header().Set("Cache-Control", "no-store")
+				httpx.WriteJSON(w, http.StatusOK, sess)
+			}
+		case "/v1/admin/logout":
+			token, _ := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if token != "" {
+				if err := a.Logout(r.Context(), token); err != nil {
+					httpx.WriteJSON(w, http.StatusInternalServerError, httpx.ErrorResponse{Error: "logout failed"})
+					return
+				}
+			}
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			httpx.WriteJSON(w, http.StatusNotFound, httpx.ErrorResponse{Error: "not found"})
+		}
+	}
+}
+
+// statusRecorder captures the response status for the audit trail
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (s *statusRecorder) WriteHeader(code int) {
+	s.status = code
+	s.ResponseWriter.WriteHeader(code)
+}
+
+func (s *statusRecorder) Unwrap() http.ResponseWriter {
+	return s.ResponseWriter
+}
+
+// Middleware authenticates admin requests (Bearer session or break-glass
+// X-Admin-Token), enforces the role rules and audits every denial and mutation
+func (a *Authenticator) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		bearer, _ := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		p, err := a.Authenticate(ctx, bearer, r.Header.Get("X-Admin-Token"))
+		if errors.Is(err, ErrUnauthenticated) {
+			a.audit.Record(ctx, "admin.request", r.URL.Path, "", "unauthenticated", map[string]interface{}{"method": r.Method})
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			httpx.WriteJSON(w, http.StatusUnauthorized, httpx.ErrorResponse{Error: ErrUnauthenticated.Error()})
+			return
+		}
+		if err != nil {
+			httpx.WriteJSON(w, http.StatusInternalServerError, httpx.ErrorResponse{Error: "admin authentication failed"})
+			return
+		}
+
+		required := a.Required(r.Method, r.URL.Path)
+		meta := map[string]interface{}{"method": r.Method, "role": p.Role}
+		if !p.Role.Allows(required) {
+			meta["required"] = required
+			a.audit.Record(ctx, "admin.request", r.URL.Path, p.Actor(), "forbidden", meta)
+			httpx.WriteJSON(w, http.StatusForbidden, httpx.ErrorResponse{Error: ErrForbidden.Error()})
+			return
+		}
+
+		r = r.WithContext(context.WithValue(ctx, ctxKey{}, p))
+		if r.Method == http.MethodGet || r.Method == http.MethodHead {
+			next.ServeHTTP(w, r)
+			return
+		}
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		start := time.Now()
+		next.ServeHTTP(rec, r)
+		meta["status"] = rec.status
+		meta["duration_ms"] = time.Since(start).Milliseconds()
+		outcome := "success"
+		if rec.status >= 400 {
+			outcome = "failure"
+		}
+		a.audit.Record(ctx, "admin.mutation", r.URL.Path, p.Actor(), outcome, meta)
+	})
+}
+
+// AdminsHandler manages admin identities (security-admin via the default rules):
+//
+//	GET    /admin/v1/admins
+//	PUT    /admin/v1/admins/{did}  {"role": "operator", "enabled": true}
+//	DELETE /admin/v1/admins/{did}
+func AdminsHandler(a *Authenticator) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		if r.URL.Path == "/admin/v1/admins" {
+			if r.Method != http.MethodGet {
+				httpx.WriteJSON(w, http.StatusMethodNotAllowed, httpx.ErrorResponse{Error: "method not allowed"})
+				return
+			}
+			admins, err := a.cfg.Admins.ListAdmins(ctx)
+			if err != nil {
+				httpx.WriteJSON(w, http.StatusInternalServerError, httpx.ErrorResponse{Error: "failed to list admins"})
+				return
+			}
+			httpx.WriteJSON(w, http.StatusOK, admins)
+			return
+		}
+
+		adminDID, err := url.PathUnescape(strings.TrimPrefix(r.URL.Path, "/admin/v1/admins/"))
+		if err != nil || validate.ValidateDID(adminDID) != nil {
+			httpx.WriteJSON(w, http.StatusBadRequest, httpx.ErrorResponse{Error: "invalid did"})
+			return
+		}
+		switch r.Method {
+		case http.MethodPut:
+			var req adminRequest
+			if err := httpx.DecodeJSON(r, &req); err != nil {
+				httpx.WriteJSON(w, http.StatusBadRequest, httpx.ErrorResponse{Error: "invalid request body"})
+				return
+			}
+			if !Role(req.Role).Valid() {
+				httpx.WriteJSON(w, http.StatusBadRequest, httpx.ErrorResponse{Error: ErrInvalidRole.Error()})
+				return
+			}
+			enabled := req.Enabled == nil || *req.Enabled
+			if err := a.cfg.Admins.UpsertAdmin(ctx, models.Admin{DID: adminDID, Role: req.Role, Enabled: enabled}); err != nil {
+				httpx.WriteJSON(w, http.StatusInternalServerError, httpx.ErrorResponse{Error: "failed to save admin"})
+				return
+			}
+			a.audit.Record(ctx, "admin.identity.upsert", adminDID, Actor(r), "success", map[string]interface{}{"role": req.Role, "enabled": enabled})
+			w.WriteHeader(http.StatusNoContent)
+		case http.MethodDelete:
+			err := a.cfg.Admins.DeleteAdmin(ctx, adminDID)
+			if errors.Is(err, store.ErrNotFound) {
+				httpx.WriteJSON(w, http.StatusNotFound, httpx.ErrorResponse{Error: "admin not found"})
+				return
+			}
+			if err != nil {
+				httpx.WriteJSON(w, http.StatusInternalServerError, httpx.ErrorResponse{Error: "failed to delete admin"})
+				return
+			}
+			a.audit.Record(ctx, "admin.identity.delete", adminDID, Actor(r), "success", nil)
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			httpx.WriteJSON(w, http.StatusMethodNotAllowed, httpx.ErrorResponse{Error: "method not allowed"})
+		}
+	}
+}
@@ -0,0 +1,238 @@
+package adminui
+
+import (
+	"context"
+	"embed"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/example/privacy-gateway/internal/shared/cache"
+	"github.com/example/privacy-gateway/internal/shared/circuitbreaker"
+	"github.com/example/privacy-gateway/internal/shared/health"
+	"github.com/example/privacy-gateway/internal/shared/httpx"
+	"github.com/example/privacy-gateway/internal/shared/models"
+	"github.com/example/privacy-gateway/internal/shared/observability"
+)
+
+//go:embed static
+var static embed.FS
+
+// Prefix is where the dashboard is mounted
+const Prefix = "/admin/ui/"
+
+// AuditLister returns recent audit events, newest first
+type AuditLister interface {
+	ListAuditEvents(ctx context.Context, limit int) ([]models.AuditEvent, error)
+}
+
+// PolicyLister returns the configured policies
+type PolicyLister interface {
+	ListPolicies(ctx context.Context) ([]models.Policy, error)
+}
+
+// Config configures the dashboard. Every source is optional; its panel is
+// left empty without one.
+type Config struct {
+	Health     *health.HealthChecker
+	Caches     *cache.Registry
+	Audit      AuditLister
+	Policies   PolicyLister
+	AuditLimit int // Recent audit events shown (default 50)
+	Logger     *slog.Logger
+}
+
+// Dashboard is a single-page admin dashboard for operators without
+// Grafana: health, circuit breakers, cache hit rates, recent audit events
+// and policies. The page and its assets are embedded and contain no data;
+// everything it shows comes from an overview endpoint behind admin auth.
+type Dashboard struct {
+	cfg Config
+
+	mu       sync.RWMutex
+	breakers map[string]func() map[string]circuitbreaker.Stats
+}
+
+// New creates a dashboard
+func New(cfg Config) *Dashboard {
+	if cfg.AuditLimit == 0 {
+		cfg.AuditLimit = 50
+	}
+	cfg.Logger = observability.Component(cfg.Logger, observability.ComponentAdmin)
+	return &Dashboard{cfg: cfg, breakers: make(map[string]func() map[string]circuitbreaker.Stats)}
+}
+
+// RegisterBreaker shows cb under name
+func (d *Dashboard) RegisterBreaker(name string, cb *circuitbreaker.CircuitBreaker) {
+	d.RegisterBreakers(name, func() map[string]circuitbreaker.Stats {
+		return map[string]circuitbreaker.Stats{"": cb.Stats()}
+	})
+}
+
+// RegisterBreakers shows a set of breakers keyed by host or upstream under
+// prefix, e.g. RegisterBreakers("did:web", resolver.BreakerStats)
+func (d *Dashboard) RegisterBreakers(prefix string, stats func() map[string]circuitbreaker.Stats) {
+	d.mu.Lock()
+	d.breakers[prefix] = stats
+	d.mu.Unlock()
+}
+
+// BreakerView is one breaker on the dashboard
+type BreakerView struct {
+	Name          string     `json:"name"`
+	State         string     `json:"state"`
+	Failures      int        `json:"failures"`
+	TotalCalls    int64      `json:"total_calls"`
+	TotalFailure  int64      `json:"total_failure"`
+	TotalRejected int64      `json:"total_rejected,omitempty"`
+	LastFailTime  *time.Time `json:"last_fail_time,omitempty"`
+}
+
+// PolicyView is the dashboard summary of a policy
+type PolicyView struct {
+	ID             string   `json:"id"`
+	Name           string   `json:"name"`
+	Route          string   `json:"route"`
+	Methods        []string `json:"methods,omitempty"`
+	RequiredScopes []string `json:"required_scopes,omitempty"`
+	PriorityClass  string   `json:"priority_class,omitempty"`
+	Shadow         bool     `json:"shadow,omitempty"`
+}
+
+// Overview is everything the dashboard shows, served at
+// GET /admin/ui/api/overview
+type Overview struct {
+	Time     time.Time                   `json:"time"`
+	Health   *health.HealthStatus        `json:"health,omitempty"`
+	Breakers []BreakerView               `json:"breakers"`
+	Caches   map[string]cache.CacheStats `json:"caches,omitempty"`
+	Audit    []models.AuditEvent         `json:"audit"`
+	Policies []PolicyView                `json:"policies"`
+	Errors   map[string]string           `json:"errors,omitempty"` // Sources that failed, by panel
+}
+
+// Overview collects the dashboard's data
+func (d *Dashboard) Overview(ctx context.Context) Overview {
+	ov := Overview{Time: time.Now().UTC(), Breakers: []BreakerView{}, Audit: []models.AuditEvent{}, Policies: []PolicyView{}}
+	fail := func(panel string, err error) {
+		d.cfg.Logger.Warn("admin dashboard source failed", "panel", panel, "error", err)
+		if ov.Errors == nil {
+			ov.Errors = make(map[string]string)
+		}
+		ov.Errors[panel] = err.Error()
+	}
+
+	if d.cfg.Health != nil {
+		ov.Health = d.cfg.Health.Check(ctx)
+	}
+	ov.Breakers = d.breakerViews()
+	if d.cfg.Caches != nil {
+		ov.Caches = d.cfg.Caches.Stats(0)
+	}
+	if d.cfg.Audit != nil {
+		events, err := d.cfg.Audit.ListAuditEvents(ctx, d.cfg.AuditLimit)
+		if err != nil {
+			fail("audit", err)
+		} else if events != nil {
+			ov.Audit = events
+		}
+	}
+	if d.cfg.Policies != nil {
+		policies, err := d.cfg.Policies.ListPolicies(ctx)
+		if err != nil {
+			fail("policies", err)
+		}
+		for _, pol := range policies {
+			route := pol.Route
+			if route == "" {
+				route = pol.RoutePrefix
+			}
+			ov.Policies = append(ov.Policies, PolicyView{
+				ID: pol.ID, Name: pol.Name, Route: route, Methods: pol.Methods,
+				RequiredScopes: pol.RequiredScopes, PriorityClass: pol.PriorityClass, Shadow: pol.Shadow,
+			})
+		}
+	}
+	return ov
+}
+
+func (d *Dashboard) breakerViews() []BreakerView {
+	d.mu.RLock()
+	sources := make(map[string]func() map[string]circuitbreaker.Stats, len(d.breakers))
+	for k, v := range d.breakers {
+		sources[k] = v
+	}
+	d.mu.RUnlock()
+
+	out := []BreakerView{}
+	for prefix, stats := range sources {
+		for key, st := range stats() {
+			name := prefix
+			if key != "" {
+				name += " " + key
+			}
+			v := BreakerView{
+				Name: name, State: stateName(st.State), Failures: st.Failures,
+				TotalCalls: st.TotalCalls, TotalFailure: st.TotalFailure, TotalRejected: st.TotalRejected,
+			}
+			if !st.LastFailTime.IsZero() {
+				t := st.LastFailTime
+				v.LastFailTime = &t
+			}
+			out = append(out, v)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// Handler serves the dashboard under Prefix. auth wraps the overview
+// endpoint, normally adminauth's Authenticator.Middleware (viewers may read
+// it); the embedded page and assets are served to anyone since they hold no
+// data, and the page logs in with the admin challenge flow.
+func (d *Dashboard) Handler(auth func(http.Handler) http.Handler) http.Handler {
+	assets, _ := fs.Sub(static, "static")
+	files := http.StripPrefix(Prefix, http.FileServer(http.FS(assets)))
+	overview := auth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			httpx.WriteJSON(w, http.StatusMethodNotAllowed, httpx.ErrorResponse{Error: "method not allowed"})
+			return
+		}
+		w.Header().Set("Cache-Control", "no-store")
+		httpx.WriteJSON(w, http.StatusOK, d.Overview(r.Context()))
+	}))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h := w.Header()
+		h.Set("Content-Security-Policy", "default-src 'self'; connect-src 'self'; frame-ancestors 'none'")
+		h.Set("X-Content-Type-Options", "nosniff")
+		h.Set("Referrer-Policy", "no-referrer")
+		switch r.URL.Path {
+		case Prefix + "api/overview":
+			overview.ServeHTTP(w, r)
+		case strings.TrimSuffix(Prefix, "/"):
+			http.Redirect(w, r, Prefix, http.StatusMovedPermanently)
+		default:
+			if r.Method != http.MethodGet && r.Method != http.MethodHead {
+				httpx.WriteJSON(w, http.StatusMethodNotAllowed, httpx.ErrorResponse{Error: "method not allowed"})
+				return
+			}
+			files.ServeHTTP(w, r)
+		}
+	})
+}
+
+func stateName(st circuitbreaker.State) string {
+	switch st {
+	case circuitbreaker.StateOpen:
+		return "open"
+	case circuitbreaker.StateHalfOpen:
+		return "half-open"
+	default:
+		return "closed"
+	}
+}
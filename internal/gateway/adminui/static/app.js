@@ -0,0 +1,155 @@
+"use strict";
+
+// The session token lives in sessionStorage, so it is gone when the tab closes
+const TOKEN_KEY = "gateway-admin-token";
+const REFRESH_MS = 5000;
+const $ = (id) => document.getElementById(id);
+let timer = null;
+
+async function postJSON(path, body) {
+  const res = await fetch(path, {
+    method: "POST",
+    headers: { "Content-Type": "application/json" },
+    body: JSON.stringify(body),
+  });
+  const data = await res.json().catch(() => ({}));
+  if (!res.ok) throw new Error(data.error || res.statusText);
+  return data;
+}
+
+function showLogin(message) {
+  sessionStorage.removeItem(TOKEN_KEY);
+  clearTimeout(timer);
+  $("dashboard").hidden = true;
+  $("logout").hidden = true;
+  $("login").hidden = false;
+  $("login-error").textContent = message || "";
+}
+
+function startDashboard(token) {
+  sessionStorage.setItem(TOKEN_KEY, token);
+  $("login").hidden = true;
+  $("dashboard").hidden = false;
+  $("logout").hidden = false;
+  refresh();
+}
+
+$("challenge-form").addEventListener("submit", async (e) => {
+  e.preventDefault();
+  try {
+    const c = await postJSON("/v1/admin/challenge", { did: $("did").value.trim() });
+    $("challenge").value = c.challenge;
+    $("login-form").hidden = false;
+    $("login-error").textContent = "";
+  } catch (err) {
+    $("login-error").textContent = err.message;
+  }
+});
+
+$("login-form").addEventListener("submit", async (e) => {
+  e.preventDefault();
+  try {
+    const s = await postJSON("/v1/admin/login", {
+      did: $("did").value.trim(),
+      challenge: $("challenge").value,
+      signature: $("signature").value.trim(),
+    });
+    $("signature").value = "";
+    startDashboard(s.access_token);
+  } catch (err) {
+    $("login-error").textContent = err.message;
+  }
+});
+
+$("token-form").addEventListener("submit", (e) => {
+  e.preventDefault();
+  const token = $("token").value.trim();
+  $("token").value = "";
+  startDashboard(token);
+});
+
+$("logout").addEventListener("click", async () => {
+  const token = sessionStorage.getItem(TOKEN_KEY);
+  await fetch("/v1/admin/logout", { method: "POST", headers: { Authorization: "Bearer " + token } }).catch(() => {});
+  showLogin();
+});
+
+async function refresh() {
+  clearTimeout(timer);
+  const token = sessionStorage.getItem(TOKEN_KEY);
+  if (!token) return showLogin();
+  try {
+    const res = await fetch("api/overview", { headers: { Authorization: "Bearer " + token } });
+    if (res.status === 401) return showLogin("Session expired; log in again.");
+    if (res.status === 403) return showLogin("This admin may not view the dashboard.");
+    if (!res.ok) throw new Error(res.statusText);
+    render(await res.json());
+  } catch (err) {
+    $("errors").textContent = "Refresh failed: " + err.message;
+  }
+  timer = setTimeout(refresh, REFRESH_MS);
+}
+
+// row builds a table row from cells; a cell is text or {text, cls}
+function row(cells) {
+  const tr = document.createElement("tr");
+  for (const c of cells) {
+    const td = document.createElement("td");
+    const cell = typeof c === "object" && c !== null ? c : { text: c };
+    if (cell.cls) {
+      const span = document.createElement("span");
+      span.className = "state " + cell.cls;
+      span.textContent = cell.text;
+      td.appendChild(span);
+    } else {
+      td.textContent = cell.text === undefined || cell.text === null ? "" : String(cell.text);
+    }
+    if (cell.wrap) td.className = "wrap";
+    tr.appendChild(td);
+  }
+  return tr;
+}
+
+function fill(id, rows, columns) {
+  const body = $(id);
+  body.replaceChildren(...rows);
+  if (rows.length === 0) {
+    const tr = row([{ text: "Nothing to show" }]);
+    tr.firstChild.colSpan = columns;
+    tr.firstChild.className = "empty";
+    body.appendChild(tr);
+  }
+}
+
+const pct = (v) => (v === undefined ? "" : (v * 100).toFixed(1) + "%");
+const when = (t) => (t ? new Date(t).toLocaleString() : "");
+const ms = (ns) => (ns ? (ns / 1e6).toFixed(1) + "ms" : "");
+
+function render(ov) {
+  $("updated").textContent = "Updated " + when(ov.time);
+  $("errors").textContent = Object.entries(ov.errors || {}).map(([k, v]) => k + ": " + v).join("; ");
+
+  const health = ov.health || { components: [] };
+  $("health-status").textContent = health.status || "unknown";
+  $("health-status").className = "badge " + (health.status || "");
+  fill("health", health.components.map((c) =>
+    row([c.name, { text: c.status, cls: c.status }, ms(c.latency), { text: c.error, wrap: true }])), 4);
+
+  fill("breakers", ov.breakers.map((b) =>
+    row([b.name, { text: b.state, cls: b.state }, b.failures, b.total_calls, b.total_failure, b.total_rejected || 0, when(b.last_fail_time)])), 7);
+
+  fill("caches", Object.keys(ov.caches || {}).sort().map((name) => {
+    const c = ov.caches[name];
+    const l = c.lookups || {};
+    const l1 = c.l1 || {};
+    return row([name, pct(l.hit_ratio), l.hits, l.misses, pct(l1.hit_ratio), l1.keys, pct(l1.cost_utilization)]);
+  }), 7);
+
+  fill("audit", ov.audit.map((e) =>
+    row([when(e.time), e.event, { text: e.outcome, cls: e.outcome }, { text: e.subject, wrap: true }, { text: e.actor, wrap: true }])), 5);
+
+  fill("policies", ov.policies.map((p) =>
+    row([p.id, p.name, p.route, (p.methods || []).join(", ") || "any", (p.required_scopes || []).join(", "), (p.priority_class || "") + (p.shadow ? " (shadow)" : "")])), 6);
+}
+
+refresh();
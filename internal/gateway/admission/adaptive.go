@@ -0,0 +1,196 @@
+package admission
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"net/http"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Class is a request's shedding priority. Lower classes are shed first.
+type Class int
+
+const (
+	ClassBulk     Class = iota // Bulk proxy traffic, shed first
+	ClassNormal                // Ordinary API traffic
+	ClassCritical              // Health checks and auth flows, never shed
+)
+
+// AdaptiveConfig configures adaptive admission control
+type AdaptiveConfig struct {
+	TargetP99 time.Duration // Latency above which the gateway is overloaded (default 500ms)
+	MaxCPU    float64       // CPU utilisation of GOMAXPROCS above which it is overloaded (default 0.85)
+	Interval  time.Duration // Evaluation interval (default 1s)
+	Step      float64       // Drop rate increase per overloaded interval (default 0.1)
+}
+
+// Stats is the controller's latest view
+type Stats struct {
+	DropRate float64       `json:"drop_rate"`
+	P99      time.Duration `json:"p99"`
+	CPU      float64       `json:"cpu"`
+}
+
+// Adaptive sheds traffic based on observed latency and CPU. Each interval it
+// checks p99 latency and CPU; while either is over target the drop rate grows
+// additively, and once both recover it decays at half that pace, so the
+// gateway backs off quickly and recovers without oscillating. Bulk requests
+// are dropped with the drop rate as probability; normal requests only start
+// being dropped past a 50% drop rate; critical requests are always admitted.
+type Adaptive struct {
+	cfg AdaptiveConfig
+
+	mu      sync.Mutex
+	samples []time.Duration
+
+	dropRate atomic.Uint64 // float64 bits
+	stats    atomic.Value  // Stats
+
+	lastCPU  float64
+	lastWall time.Time
+}
+
+// NewAdaptive creates an adaptive controller; call Run to start it
+func NewAdaptive(cfg AdaptiveConfig) *Adaptive {
+	if cfg.TargetP99 == 0 {
+		cfg.TargetP99 = 500 * time.Millisecond
+	}
+	if cfg.MaxCPU == 0 {
+		cfg.MaxCPU = 0.85
+	}
+	if cfg.Interval == 0 {
+		cfg.Interval = time.Second
+	}
+	if cfg.Step == 0 {
+		cfg.Step = 0.1
+	}
+	a := &Adaptive{cfg: cfg}
+	a.stats.Store(Stats{})
+	a.lastCPU, _ = processCPUSeconds()
+	a.lastWall = time.Now()
+	return a
+}
+
+// Observe records a completed request's latency
+func (a *Adaptive) Observe(d time.Duration) {
+	a.mu.Lock()
+	a.samples = append(a.samples, d)
+	a.mu.Unlock()
+}
+
+// Admit reports whether a request of class c should be served
+func (a *Adaptive) Admit(c Class) bool {
+	rate := math.Float64frombits(a.dropRate.Load())
+	switch c {
+	case ClassCritical:
+		return true
+	case ClassNormal:
+		rate = math.Max(0, rate-0.5) * 2
+	}
+	return rate == 0 || rand.Float64() >= rate
+}
+
+// Stats returns the most recent evaluation
+func (a *Adaptive) Stats() Stats {
+	return a.stats.Load().(Stats)
+}
+
+// Run evaluates load every interval until ctx is cancelled
+func (a *Adaptive) Run(ctx context.Context) {
+	ticker := time.NewTicker(a.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			a.evaluate()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// evaluate updates the drop rate from the last interval's samples
+func (a *Adaptive) evaluate() {
+	a.mu.Lock()
+	samples := a.samples
+	a.samples = make([]time.Duration, 0, len(samples))
+	a.mu.Unlock()
+
+	p99 := percentile(samples, 0.99)
+	cpu := a.cpuUtilisation()
+
+	rate := math.Float64frombits(a.dropRate.Load())
+	if p99 > a.cfg.TargetP99 || cpu > a.cfg.MaxCPU {
+		rate = math.Min(1, rate+a.cfg.Step)
+	} else {
+		rate = math.Max(0, rate-a.cfg.Step/2)
+	}
+	a.dropRate.Store(math.Float64bits(rate))
+	a.stats.Store(Stats{DropRate: rate, P99: p99, CPU: cpu})
+}
+
+// cpuUtilisation returns process CPU time since the last call as a fraction
+// of the GOMAXPROCS capacity available over that wall time
+func (a *Adaptive) cpuUtilisation() float64 {
+	now := time.Now()
+	busy, ok := processCPUSeconds()
+	if !ok {
+		return 0
+	}
+	wall := now.Sub(a.lastWall).Seconds() * float64(runtime.GOMAXPROCS(0))
+	used := busy - a.lastCPU
+	a.lastCPU, a.lastWall = busy, now
+	if wall <= 0 {
+		return 0
+	}
+	return used / wall
+}
+
+// percentile returns the p-th percentile of samples (0 when empty)
+func percentile(samples []time.Duration, p float64) time.Duration {
+	if len(samples) == 0 {
+		return 0
+	}
+	sort.Slice(samples, func(i, j int) bool { return samples[i] < samples[j] })
+	idx := int(math.Ceil(p*float64(len(samples)))) - 1
+	if idx < 0 {
+		idx = 0
+	}
+	return samples[idx]
+}
+
+// ClassifyFunc assigns a class to a request
+type ClassifyFunc func(r *http.Request) Class
+
+// DefaultClassify treats health and auth endpoints as critical, proxied API
+// traffic as bulk and everything else as normal
+func DefaultClassify(r *http.Request) Class {
+	switch p := r.URL.Path; {
+	case isCriticalPath(p):
+		return ClassCritical
+	case strings.HasPrefix(p, "/api/"):
+		return ClassBulk
+	default:
+		return ClassNormal
+	}
+}
+
+// Middleware sheds requests the controller does not admit and feeds it the
+// latency of those it does
+func (a *Adaptive) Middleware(classify ClassifyFunc, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !a.Admit(classify(r)) {
+			WriteOverloaded(w, a.cfg.Interval)
+			return
+		}
+		start := time.Now()
+		next.ServeHTTP(w, r)
+		a.Observe(time.Since(start))
+	})
+}
@@ -0,0 +1,61 @@
+package admission
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/example/privacy-gateway/internal/shared/models"
+)
+
+// Policy priority class names
+const (
+	PriorityCritical = "critical"
+	PriorityNormal   = "normal"
+	PriorityBulk     = "bulk"
+)
+
+// globalShare is the fraction of global concurrency a class may occupy.
+// The headroom above each share is reserved for higher classes, so bulk
+// routes saturating the gateway still leave room for auth and health checks.
+var globalShare = map[Class]float64{
+	ClassBulk:     0.7,
+	ClassNormal:   0.9,
+	ClassCritical: 1.0,
+}
+
+// ParseClass converts a policy priority_class; unknown or empty is normal
+func ParseClass(s string) Class {
+	switch s {
+	case PriorityCritical:
+		return ClassCritical
+	case PriorityBulk:
+		return ClassBulk
+	default:
+		return ClassNormal
+	}
+}
+
+// isCriticalPath reports whether a path must never be shed
+func isCriticalPath(p string) bool {
+	return p == "/healthz" || p == "/readyz" || strings.HasPrefix(p, "/v1/auth/")
+}
+
+// ClassFor returns the class of a request. Health and auth endpoints are
+// always critical; otherwise the matched policy's priority_class applies,
+// falling back to DefaultClassify when no policy matched or none is set.
+func ClassFor(r *http.Request, pol *models.Policy) Class {
+	if isCriticalPath(r.URL.Path) {
+		return ClassCritical
+	}
+	if pol != nil && pol.PriorityClass != "" {
+		return ParseClass(pol.PriorityClass)
+	}
+	return DefaultClassify(r)
+}
+
+// PolicyClassifier adapts a policy lookup into a ClassifyFunc
+func PolicyClassifier(lookup func(r *http.Request) *models.Policy) ClassifyFunc {
+	return func(r *http.Request) Class {
+		return ClassFor(r, lookup(r))
+	}
+}
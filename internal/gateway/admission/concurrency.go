@@ -0,0 +1,203 @@
+package admission
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/example/privacy-gateway/internal/shared/httpx"
+	"github.com/example/privacy-gateway/internal/shared/models"
+)
+
+var (
+	ErrQueueFull    = errors.New("admission queue full")
+	ErrQueueTimeout = errors.New("timed out waiting for admission")
+)
+
+// DefaultQueueTimeout bounds how long a request waits for a slot
+const DefaultQueueTimeout = time.Second
+
+// Config bounds concurrency for one scope (global or a route)
+type Config struct {
+	MaxConcurrent int           // 0 means unlimited
+	MaxQueue      int           // Requests allowed to wait for a slot
+	QueueTimeout  time.Duration // Default 1s; a sooner request deadline wins
+}
+
+// configFor converts per-route limits into a Config
+func configFor(l *models.RouteLimits) Config {
+	if l == nil {
+		return Config{}
+	}
+	return Config{
+		MaxConcurrent: l.MaxConcurrent,
+		MaxQueue:      l.MaxQueue,
+		QueueTimeout:  time.Duration(l.QueueTimeoutMillis) * time.Millisecond,
+	}
+}
+
+// semaphore is a counting semaphore with a bounded wait queue
+type semaphore struct {
+	cfg    Config
+	slots  chan struct{}
+	queued atomic.Int64
+}
+
+func newSemaphore(cfg Config) *semaphore {
+	if cfg.QueueTimeout == 0 {
+		cfg.QueueTimeout = DefaultQueueTimeout
+	}
+	return &semaphore{cfg: cfg, slots: make(chan struct{}, cfg.MaxConcurrent)}
+}
+
+// acquire takes a slot, waiting in the queue if there is room. The wait is
+// bounded by the queue timeout or the request deadline, whichever is sooner.
+func (s *semaphore) acquire(ctx context.Context) error {
+	select {
+	case s.slots <- struct{}{}:
+		return nil
+	default:
+	}
+
+	if s.queued.Add(1) > int64(s.cfg.MaxQueue) {
+		s.queued.Add(-1)
+		return ErrQueueFull
+	}
+	defer s.queued.Add(-1)
+
+	timer := time.NewTimer(s.cfg.QueueTimeout)
+	defer timer.Stop()
+	select {
+	case s.slots <- struct{}{}:
+		return nil
+	case <-timer.C:
+		return ErrQueueTimeout
+	case <-ctx.Done():
+		return ErrQueueTimeout
+	}
+}
+
+func (s *semaphore) release() {
+	<-s.slots
+}
+
+// Limiter enforces a global concurrency limit and optional per-route limits.
+// A request takes its route slot before the global one, so a slow route
+// queues against itself instead of holding global capacity while it waits.
+// Lower priority classes may only fill part of the global capacity (see
+// globalShare) and are shed once it is reached.
+type Limiter struct {
+	global *semaphore
+	onShed func(route string, err error) // Metrics callback
+
+	mu     sync.Mutex
+	routes map[string]*semaphore
+}
+
+// NewLimiter creates a limiter with the given global limit
+func NewLimiter(global Config, onShed func(route string, err error)) *Limiter {
+	l := &Limiter{onShed: onShed, routes: make(map[string]*semaphore)}
+	if global.MaxConcurrent > 0 {
+		l.global = newSemaphore(global)
+	}
+	return l
+}
+
+// route returns the semaphore for a route, replacing it when its limits
+// changed on a policy reload
+func (l *Limiter) route(name string, cfg Config) *semaphore {
+	if cfg.MaxConcurrent <= 0 {
+		return nil
+	}
+	if cfg.QueueTimeout == 0 {
+		cfg.QueueTimeout = DefaultQueueTimeout
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	s, ok := l.routes[name]
+	if !ok || s.cfg != cfg {
+		// In-flight holders of the old semaphore release into it harmlessly
+		s = newSemaphore(cfg)
+		l.routes[name] = s
+	}
+	return s
+}
+
+// Acquire admits a request of class c to route. The returned release func
+// must be called when the request finishes.
+func (l *Limiter) Acquire(ctx context.Context, route string, limits *models.RouteLimits, c Class) (func(), error) {
+	rs := l.route(route, configFor(limits))
+	if rs != nil {
+		if err := rs.acquire(ctx); err != nil {
+			l.shed(route, err)
+			return nil, err
+		}
+	}
+	if l.global != nil {
+		err := l.global.acquire(ctx)
+		if err == nil && c != ClassCritical &&
+			float64(len(l.global.slots)) > globalShare[c]*float64(cap(l.global.slots)) {
+			// Over this class's share: give the slot back for higher classes
+			l.global.release()
+			err = ErrQueueFull
+		}
+		if err != nil {
+			if rs != nil {
+				rs.release()
+			}
+			l.shed(route, err)
+			return nil, err
+		}
+	}
+	return func() {
+		if l.global != nil {
+			l.global.release()
+		}
+		if rs != nil {
+			rs.release()
+		}
+	}, nil
+}
+
+func (l *Limiter) shed(route string, err error) {
+	if l.onShed != nil {
+		l.onShed(route, err)
+	}
+}
+
+// RouteFunc identifies the route and matched policy for a request; pol may
+// be nil for gateway endpoints
+type RouteFunc func(r *http.Request) (route string, pol *models.Policy)
+
+// Middleware admits requests through the limiter, answering 503 with
+// Retry-After when a queue is full or the wait times out
+func (l *Limiter) Middleware(routeOf RouteFunc, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route, pol := routeOf(r)
+		var limits *models.RouteLimits
+		if pol != nil {
+			limits = pol.Limits
+		}
+		release, err := l.Acquire(r.Context(), route, limits, ClassFor(r, pol))
+		if err != nil {
+			WriteOverloaded(w, time.Second)
+			return
+		}
+		defer release()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// WriteOverloaded writes the 503 response for a shed request
+func WriteOverloaded(w http.ResponseWriter, retryAfter time.Duration) {
+	secs := int(retryAfter.Seconds())
+	if secs < 1 {
+		secs = 1
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(secs))
+	httpx.WriteJSON(w, http.StatusServiceUnavailable, httpx.ErrorResponse{Error: "gateway overloaded"})
+}
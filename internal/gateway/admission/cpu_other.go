@@ -0,0 +1,9 @@
+//go:build !unix
+
+package admission
+
+// processCPUSeconds is unavailable on this platform; CPU-based shedding is
+// disabled and only latency is considered
+func processCPUSeconds() (float64, bool) {
+	return 0, false
+}
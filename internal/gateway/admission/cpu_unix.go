@@ -0,0 +1,18 @@
+//go:build unix
+
+package admission
+
+import "syscall"
+
+// processCPUSeconds returns user+system CPU time consumed by the process
+func processCPUSeconds() (float64, bool) {
+	var ru syscall.Rusage
+	if err := syscall.Getrusage(syscall.RUSAGE_SELF, &ru); err != nil {
+		return 0, false
+	}
+	return timeval(ru.Utime) + timeval(ru.Stime), true
+}
+
+func timeval(tv syscall.Timeval) float64 {
+	return float64(tv.Sec) + float64(tv.Usec)/1e6
+}
@@ -0,0 +1,294 @@
+package apikey
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/example/privacy-gateway/internal/gateway/store"
+	"github.com/example/privacy-gateway/internal/shared/audit"
+	"github.com/example/privacy-gateway/internal/shared/cache"
+	"github.com/example/privacy-gateway/internal/shared/crypto"
+	"github.com/example/privacy-gateway/internal/shared/models"
+	"github.com/example/privacy-gateway/internal/shared/observability"
+	"github.com/example/privacy-gateway/internal/shared/validate"
+)
+
+var (
+	ErrNoKey           = errors.New("no API key presented")
+	ErrInvalidKey      = errors.New("invalid API key")
+	ErrExpiredKey      = errors.New("API key expired")
+	ErrRouteNotAllowed = errors.New("route does not accept API keys")
+	ErrInvalidRequest  = errors.New("invalid API key request")
+)
+
+// Prefix starts every API key so they are easy to spot in logs and secret
+// scanners: pgk_<id>_<secret>
+const Prefix = "pgk_"
+
+// SubjectPrefix marks the synthetic subject of API key requests, so policies,
+// rate limits and the audit trail can tell them apart from DIDs
+const SubjectPrefix = "apikey:"
+
+// Header carries the key; "Authorization: ApiKey <key>" is accepted as well
+const Header = "X-API-Key"
+
+// Store persists API keys
+type Store interface {
+	GetAPIKey(ctx context.Context, id string) (models.APIKey, error)
+	ListAPIKeys(ctx context.Context) ([]models.APIKey, error)
+	InsertAPIKey(ctx context.Context, k models.APIKey) error
+	RotateAPIKey(ctx context.Context, id, hash string, previousExpiresAt time.Time) error
+	RevokeAPIKey(ctx context.Context, id string) error
+}
+
+// Config configures API key management
+type Config struct {
+	Store      Store
+	Audit      audit.Sink
+	DefaultTTL time.Duration // Key lifetime when none is requested (default 90 days)
+	MaxTTL     time.Duration // Longest allowed key lifetime (default 365 days)
+	MaxGrace   time.Duration // Longest rotation overlap (default 7 days)
+	CacheTTL   time.Duration // How long replicas cache key lookups (default 30s)
+	CacheSize  int           // Cached lookups, found or not (default 10000)
+	Logger     *slog.Logger
+}
+
+// Manager creates, rotates, revokes and authenticates API keys. Lookups are
+// cached per replica for CacheTTL, which bounds how long a revoked key keeps
+// working on other replicas.
+type Manager struct {
+	cfg   Config
+	audit *audit.Recorder
+	keys  *cache.LRU[cachedKey]
+}
+
+type cachedKey struct {
+	key   models.APIKey
+	found bool
+}
+
+// NewManager creates an API key manager
+func NewManager(cfg Config) (*Manager, error) {
+	if cfg.Store == nil {
+		return nil, errors.New("API key manager requires a store")
+	}
+	if cfg.DefaultTTL == 0 {
+		cfg.DefaultTTL = 90 * 24 * time.Hour
+	}
+	if cfg.MaxTTL == 0 {
+		cfg.MaxTTL = 365 * 24 * time.Hour
+	}
+	if cfg.MaxGrace == 0 {
+		cfg.MaxGrace = 7 * 24 * time.Hour
+	}
+	if cfg.CacheTTL == 0 {
+		cfg.CacheTTL = 30 * time.Second
+	}
+	if cfg.CacheSize == 0 {
+		cfg.CacheSize = 10000
+	}
+	cfg.Logger = observability.Component(cfg.Logger, observability.ComponentAuth)
+	return &Manager{
+		cfg:   cfg,
+		audit: audit.NewRecorder(cfg.Audit, cfg.Logger, nil),
+		keys:  cache.NewLRU[cachedKey](cfg.CacheSize, nil),
+	}, nil
+}
+
+// Create issues a new key and returns it in plaintext. The plaintext is
+// never stored and can't be retrieved again.
+func (m *Manager) Create(ctx context.Context, name string, scopes []string, ttl time.Duration, actor string) (string, models.APIKey, error) {
+	if name == "" || len(scopes) == 0 {
+		return "", models.APIKey{}, fmt.Errorf("%w: name and scopes are required", ErrInvalidRequest)
+	}
+	if err := validate.ValidateKeyScopes(scopes); err != nil {
+		return "", models.APIKey{}, fmt.Errorf("%w: %v", ErrInvalidRequest, err)
+	}
+	if ttl == 0 {
+		ttl = m.cfg.DefaultTTL
+	}
+	if err := validate.ValidateTTL(ttl, time.Hour, m.cfg.MaxTTL); err != nil {
+		return "", models.APIKey{}, fmt.Errorf("%w: %v", ErrInvalidRequest, err)
+	}
+
+	idBytes := make([]byte, 8)
+	if _, err := rand.Read(idBytes); err != nil {
+		return "", models.APIKey{}, err
+	}
+	id := hex.EncodeToString(idBytes)
+	raw, hash, err := newSecret(id)
+	if err != nil {
+		return "", models.APIKey{}, err
+	}
+	now := time.Now().UTC()
+	k := models.APIKey{
+		ID:        id,
+		Name:      name,
+		Hash:      hash,
+		Scopes:    scopes,
+		ExpiresAt: now.Add(ttl),
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := m.cfg.Store.InsertAPIKey(ctx, k); err != nil {
+		return "", models.APIKey{}, err
+	}
+	m.audit.Record(ctx, "apikey.create", SubjectPrefix+id, actor, "success", map[string]interface{}{"name": name, "scopes": scopes, "expires_at": k.ExpiresAt})
+	return raw, k, nil
+}
+
+// Rotate issues a new secret for key id. The old secret keeps working for
+// grace so clients can be redeployed without downtime.
+func (m *Manager) Rotate(ctx context.Context, id string, grace time.Duration, actor string) (string, error) {
+	if grace < 0 || grace > m.cfg.MaxGrace {
+		return "", fmt.Errorf("%w: grace must be between 0 and %s", ErrInvalidRequest, m.cfg.MaxGrace)
+	}
+	raw, hash, err := newSecret(id)
+	if err != nil {
+		return "", err
+	}
+	until := time.Now().UTC().Add(grace)
+	if err := m.cfg.Store.RotateAPIKey(ctx, id, hash, until); err != nil {
+		return "", err
+	}
+	m.forget(id)
+	m.audit.Record(ctx, "apikey.rotate", SubjectPrefix+id, actor, "success", map[string]interface{}{"previous_expires_at": until})
+	return raw, nil
+}
+
+// Revoke disables key id for good
+func (m *Manager) Revoke(ctx context.Context, id, actor string) error {
+	if err := m.cfg.Store.RevokeAPIKey(ctx, id); err != nil {
+		return err
+	}
+	m.forget(id)
+	m.audit.Record(ctx, "apikey.revoke", SubjectPrefix+id, actor, "success", nil)
+	return nil
+}
+
+// List returns all keys without their hashes
+func (m *Manager) List(ctx context.Context) ([]models.APIKey, error) {
+	return m.cfg.Store.ListAPIKeys(ctx)
+}
+
+// Authenticate checks a plaintext key and returns the claims it stands in
+// for: the key's scopes under the synthetic subject apikey:<id>
+func (m *Manager) Authenticate(ctx context.Context, raw string) (*models.AccessTokenClaims, error) {
+	id, _, ok := parseKey(raw)
+	if !ok {
+		return nil, ErrInvalidKey
+	}
+	k, err := m.lookup(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	hash := crypto.HashSecret(raw)
+	valid := subtle.ConstantTimeCompare([]byte(hash), []byte(k.Hash)) == 1
+	if !valid && k.PreviousHash != "" && k.PreviousExpiresAt != nil && now.Before(*k.PreviousExpiresAt) {
+		valid = subtle.ConstantTimeCompare([]byte(hash), []byte(k.PreviousHash)) == 1
+	}
+	if !valid || k.Revoked {
+		return nil, ErrInvalidKey
+	}
+	if !now.Before(k.ExpiresAt) {
+		return nil, ErrExpiredKey
+	}
+	return &models.AccessTokenClaims{
+		Subject:   SubjectPrefix + k.ID,
+		Scopes:    k.Scopes,
+		Issuer:    "apikey",
+		IssuedAt:  now.Unix(),
+		ExpiresAt: k.ExpiresAt.Unix(),
+		JWTID:     SubjectPrefix + k.ID,
+	}, nil
+}
+
+// Authorize authenticates the key presented on r for a route governed by
+// pol. It returns ErrNoKey when the request carries no key, so callers can
+// fall back to access token auth.
+func (m *Manager) Authorize(r *http.Request, pol *models.Policy) (*models.AccessTokenClaims, error) {
+	raw := FromRequest(r)
+	if raw == "" {
+		return nil, ErrNoKey
+	}
+	if pol == nil || !pol.AllowAPIKeys {
+		return nil, ErrRouteNotAllowed
+	}
+	claims, err := m.Authenticate(r.Context(), raw)
+	if err != nil {
+		m.cfg.Logger.Warn("API key rejected", "path", r.URL.Path, "error", err)
+	}
+	return claims, err
+}
+
+// FromRequest returns the API key presented on r, if any
+func FromRequest(r *http.Request) string {
+	if k := r.Header.Get(Header); k != "" {
+		return k
+	}
+	if k, ok := strings.CutPrefix(r.Header.Get("Authorization"), "ApiKey "); ok {
+		return k
+	}
+	return ""
+}
+
+// lookup loads a key through the replica-local cache. Unknown IDs are cached
+// too, so a client retrying a bad key doesn't reach the database each time;
+// the cache holds at most CacheSize entries, so random IDs can't grow it.
+func (m *Manager) lookup(ctx context.Context, id string) (models.APIKey, error) {
+	c, ok := m.keys.Get(id)
+	if !ok {
+		k, err := m.cfg.Store.GetAPIKey(ctx, id)
+		if err != nil && !errors.Is(err, store.ErrNotFound) {
+			return models.APIKey{}, err
+		}
+		c = cachedKey{key: k, found: err == nil}
+		m.keys.Set(id, c, m.cfg.CacheTTL)
+	}
+	if !c.found {
+		return models.APIKey{}, ErrInvalidKey
+	}
+	return c.key, nil
+}
+
+func (m *Manager) forget(id string) {
+	m.keys.Delete(id)
+}
+
+// newSecret returns a plaintext key for id and the hash to store
+func newSecret(id string) (string, string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", err
+	}
+	raw := Prefix + id + "_" + base64.RawURLEncoding.EncodeToString(buf)
+	return raw, crypto.HashSecret(raw), nil
+}
+
+// parseKey splits pgk_<id>_<secret>; the ID is hex, so the first underscore
+// after the prefix ends it
+func parseKey(raw string) (string, string, bool) {
+	rest, ok := strings.CutPrefix(raw, Prefix)
+	if !ok {
+		return "", "", false
+	}
+	id, secret, ok := strings.Cut(rest, "_")
+	if !ok || len(id) != 16 || secret == "" {
+		return "", "", false
+	}
+	if _, err := hex.DecodeString(id); err != nil {
+		return "", "", false
+	}
+	return id, secret, true
+}
@@ -0,0 +1,105 @@
+package apikey
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/example/privacy-gateway/internal/gateway/store"
+	"github.com/example/privacy-gateway/internal/shared/httpx"
+	"github.com/example/privacy-gateway/internal/shared/models"
+)
+
+type createRequest struct {
+	Name       string   `json:"name"`
+	Scopes     []string `json:"scopes"`
+	TTLSeconds int64    `json:"ttl_seconds,omitempty"`
+}
+
+type rotateRequest struct {
+	GraceSeconds int64 `json:"grace_seconds"`
+}
+
+// keyResponse returns the plaintext key exactly once, on create and rotate
+type keyResponse struct {
+	Key    string         `json:"key"`
+	APIKey *models.APIKey `json:"api_key,omitempty"`
+}
+
+// Handler serves the admin API key endpoints:
+//
+//	GET    /admin/v1/apikeys
+//	POST   /admin/v1/apikeys              {"name", "scopes", "ttl_seconds"}
+//	POST   /admin/v1/apikeys/{id}/rotate  {"grace_seconds"}
+//	DELETE /admin/v1/apikeys/{id}
+//
+// actor identifies the caller in the audit trail.
+func Handler(m *Manager, actor func(*http.Request) string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		who := "admin"
+		if actor != nil {
+			who = actor(r)
+		}
+		rest := strings.Trim(strings.TrimPrefix(r.URL.Path, "/admin/v1/apikeys"), "/")
+		id, action, _ := strings.Cut(rest, "/")
+
+		switch {
+		case id == "" && r.Method == http.MethodGet:
+			keys, err := m.List(r.Context())
+			if err != nil {
+				httpx.WriteJSON(w, http.StatusInternalServerError, httpx.ErrorResponse{Error: "failed to list API keys"})
+				return
+			}
+			httpx.WriteJSON(w, http.StatusOK, keys)
+		case id == "" && r.Method == http.MethodPost:
+			var req createRequest
+			if err := httpx.DecodeJSON(r, &req); err != nil {
+				httpx.WriteJSON(w, http.StatusBadRequest, httpx.ErrorResponse{Error: "invalid request body"})
+				return
+			}
+			raw, k, err := m.Create(r.Context(), req.Name, req.Scopes, time.Duration(req.TTLSeconds)*time.Second, who)
+			if errors.Is(err, ErrInvalidRequest) {
+				httpx.WriteJSON(w, http.StatusBadRequest, httpx.ErrorResponse{Error: err.Error()})
+				return
+			}
+			if err != nil {
+				httpx.WriteJSON(w, http.StatusInternalServerError, httpx.ErrorResponse{Error: "failed to create API key"})
+				return
+			}
+			w.Header().Set("Cache-Control", "no-store")
+			httpx.WriteJSON(w, http.StatusCreated, keyResponse{Key: raw, APIKey: &k})
+		case id != "" && action == "rotate" && r.Method == http.MethodPost:
+			var req rotateRequest
+			if err := httpx.DecodeJSON(r, &req); err != nil {
+				httpx.WriteJSON(w, http.StatusBadRequest, httpx.ErrorResponse{Error: "invalid request body"})
+				return
+			}
+			raw, err := m.Rotate(r.Context(), id, time.Duration(req.GraceSeconds)*time.Second, who)
+			switch {
+			case errors.Is(err, ErrInvalidRequest):
+				httpx.WriteJSON(w, http.StatusBadRequest, httpx.ErrorResponse{Error: err.Error()})
+			case errors.Is(err, store.ErrNotFound):
+				httpx.WriteJSON(w, http.StatusNotFound, httpx.ErrorResponse{Error: "API key not found"})
+			case err != nil:
+				httpx.WriteJSON(w, http.StatusInternalServerError, httpx.ErrorResponse{Error: "failed to rotate API key"})
+			default:
+				w.Header().Set("Cache-Control", "no-store")
+				httpx.WriteJSON(w, http.StatusOK, keyResponse{Key: raw})
+			}
+		case id != "" && action == "" && r.Method == http.MethodDelete:
+			err := m.Revoke(r.Context(), id, who)
+			if errors.Is(err, store.ErrNotFound) {
+				httpx.WriteJSON(w, http.StatusNotFound, httpx.ErrorResponse{Error: "API key not found"})
+				return
+			}
+			if err != nil {
+				httpx.WriteJSON(w, http.StatusInternalServerError, httpx.ErrorResponse{Error: "failed to revoke API key"})
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			httpx.WriteJSON(w, http.StatusNotFound, httpx.ErrorResponse{Error: "not found"})
+		}
+	}
+}
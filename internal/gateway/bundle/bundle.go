@@ -0,0 +1,366 @@
+package bundle
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/example/privacy-gateway/internal/gateway/proxy"
+	"github.com/example/privacy-gateway/internal/shared/audit"
+	"github.com/example/privacy-gateway/internal/shared/crypto"
+	"github.com/example/privacy-gateway/internal/shared/jws"
+	"github.com/example/privacy-gateway/internal/shared/models"
+	"github.com/example/privacy-gateway/internal/shared/observability"
+	"github.com/example/privacy-gateway/internal/shared/resilience"
+	"github.com/example/privacy-gateway/internal/shared/validate"
+)
+
+// Version is the bundle format version this gateway reads and writes
+const Version = 1
+
+// bundleType is the JWS typ header of a signed bundle
+const bundleType = "gateway-bundle+jwt"
+
+var (
+	ErrInvalidBundle   = errors.New("invalid bundle")
+	ErrUntrustedSigner = errors.New("bundle signer is not trusted")
+	ErrNoSigningKey    = errors.New("no bundle signing key configured")
+)
+
+// Bundle is a snapshot of the gateway configuration that moves between
+// environments. Scopes are part of each policy (required_scopes). Admins,
+// API keys and revocation lists are environment-specific and never included.
+type Bundle struct {
+	Version   int             `json:"version"`
+	Source    string          `json:"source,omitempty"` // Environment it was exported from
+	CreatedAt time.Time       `json:"created_at"`
+	Policies  []models.Policy `json:"policies"`
+	Issuers   []models.Issuer `json:"issuers"`
+}
+
+// Store reads and writes the bundled configuration; store.Postgres implements it
+type Store interface {
+	ListPolicies(ctx context.Context) ([]models.Policy, error)
+	UpsertPolicy(ctx context.Context, pol models.Policy) error
+	ListIssuers(ctx context.Context) ([]models.Issuer, error)
+	UpsertIssuer(ctx context.Context, iss models.Issuer) error
+}
+
+// Config configures bundle export and import
+type Config struct {
+	Store       Store
+	Environment string             // Recorded as the source of exported bundles, e.g. "staging"
+	SigningKey  ed25519.PrivateKey // Signs exports (optional; export is disabled without it)
+	KeyID       string             // kid of SigningKey (default "bundle-1")
+	// TrustedKeys verify imported bundles by kid. The signing key is always
+	// trusted, so an environment can restore its own backups.
+	TrustedKeys map[string]ed25519.PublicKey
+	// Audit and Logger are optional
+	Audit  audit.Sink
+	Logger *slog.Logger
+}
+
+// Changes lists what an import does to one kind of object, by ID
+type Changes struct {
+	Created   []string `json:"created,omitempty"`
+	Updated   []string `json:"updated,omitempty"`
+	Unchanged []string `json:"unchanged,omitempty"`
+	// Extra exist here but not in the bundle. Imports never delete, so
+	// they're left in place; remove them by hand if they shouldn't be.
+	Extra []string `json:"extra,omitempty"`
+}
+
+// Result describes an import
+type Result struct {
+	Source    string    `json:"source,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	KeyID     string    `json:"kid"`
+	DryRun    bool      `json:"dry_run"`
+	Policies  Changes   `json:"policies"`
+	Issuers   Changes   `json:"issuers"`
+}
+
+// Manager exports and imports signed configuration bundles, for backups and
+// for promoting configuration from staging to production
+type Manager struct {
+	cfg   Config
+	audit *audit.Recorder
+}
+
+// NewManager creates a bundle manager
+func NewManager(cfg Config) (*Manager, error) {
+	if cfg.SigningKey != nil && len(cfg.SigningKey) != ed25519.PrivateKeySize {
+		return nil, errors.New("bundle signing key must be an Ed25519 private key")
+	}
+	if cfg.KeyID == "" {
+		cfg.KeyID = "bundle-1"
+	}
+	trusted := make(map[string]ed25519.PublicKey, len(cfg.TrustedKeys)+1)
+	for kid, pub := range cfg.TrustedKeys {
+		trusted[kid] = pub
+	}
+	if cfg.SigningKey != nil {
+		trusted[cfg.KeyID] = cfg.SigningKey.Public().(ed25519.PublicKey)
+	}
+	cfg.TrustedKeys = trusted
+	cfg.Logger = observability.Component(cfg.Logger, observability.ComponentConfig)
+	return &Manager{cfg: cfg, audit: audit.NewRecorder(cfg.Audit, cfg.Logger, nil)}, nil
+}
+
+// Export snapshots the current configuration as a signed bundle
+func (m *Manager) Export(ctx context.Context) (string, error) {
+	if m.cfg.SigningKey == nil {
+		return "", ErrNoSigningKey
+	}
+	policies, err := m.cfg.Store.ListPolicies(ctx)
+	if err != nil {
+		return "", err
+	}
+	issuers, err := m.cfg.Store.ListIssuers(ctx)
+	if err != nil {
+		return "", err
+	}
+	b := Bundle{
+		Version:   Version,
+		Source:    m.cfg.Environment,
+		CreatedAt: time.Now().UTC(),
+		Policies:  policies,
+		Issuers:   issuers,
+	}
+	return Sign(b, m.cfg.SigningKey, m.cfg.KeyID)
+}
+
+// Import verifies a signed bundle and writes its policies and issuers,
+// creating missing ones and replacing changed ones. With dryRun it only
+// reports what would change. actor identifies the caller in the audit trail.
+func (m *Manager) Import(ctx context.Context, token string, dryRun bool, actor string) (Result, error) {
+	b, kid, err := Verify(token, m.cfg.TrustedKeys)
+	if err != nil {
+		return Result{}, err
+	}
+	if err := b.Validate(); err != nil {
+		return Result{}, err
+	}
+	plan, err := NewPlan(ctx, m.cfg.Store, b)
+	if err != nil {
+		return Result{}, err
+	}
+	res := Result{Source: b.Source, CreatedAt: b.CreatedAt, KeyID: kid, DryRun: dryRun,
+		Policies: plan.Policies, Issuers: plan.Issuers}
+	if dryRun {
+		return res, nil
+	}
+	if err := plan.Apply(ctx, m.cfg.Store); err != nil {
+		return res, err
+	}
+	m.audit.Record(ctx, "bundle.import", res.Source, actor, "success", map[string]interface{}{
+		"kid":              res.KeyID,
+		"bundle_created":   res.CreatedAt,
+		"policies_created": len(res.Policies.Created),
+		"policies_updated": len(res.Policies.Updated),
+		"issuers_created":  len(res.Issuers.Created),
+		"issuers_updated":  len(res.Issuers.Updated),
+	})
+	return res, nil
+}
+
+// Plan is the difference between a bundle and the current configuration
+type Plan struct {
+	Policies Changes
+	Issuers  Changes
+
+	policies []models.Policy // Created or updated
+	issuers  []models.Issuer
+}
+
+// NewPlan compares b with the configuration in st
+func NewPlan(ctx context.Context, st Store, b Bundle) (*Plan, error) {
+	policies, err := st.ListPolicies(ctx)
+	if err != nil {
+		return nil, err
+	}
+	issuers, err := st.ListIssuers(ctx)
+	if err != nil {
+		return nil, err
+	}
+	plan := &Plan{}
+	current := make(map[string]models.Policy, len(policies))
+	for _, pol := range policies {
+		current[pol.ID] = pol
+	}
+	for _, pol := range b.Policies {
+		existing, ok := current[pol.ID]
+		delete(current, pol.ID)
+		switch {
+		case !ok:
+			plan.Policies.Created = append(plan.Policies.Created, pol.ID)
+		case sameJSON(existing, pol):
+			plan.Policies.Unchanged = append(plan.Policies.Unchanged, pol.ID)
+			continue
+		default:
+			plan.Policies.Updated = append(plan.Policies.Updated, pol.ID)
+		}
+		plan.policies = append(plan.policies, pol)
+	}
+	plan.Policies.Extra = sortedKeys(current)
+
+	currentIss := make(map[string]models.Issuer, len(issuers))
+	for _, iss := range issuers {
+		currentIss[iss.DID] = iss
+	}
+	for _, iss := range b.Issuers {
+		existing, ok := currentIss[iss.DID]
+		delete(currentIss, iss.DID)
+		switch {
+		case !ok:
+			plan.Issuers.Created = append(plan.Issuers.Created, iss.DID)
+		case sameIssuer(existing, iss):
+			plan.Issuers.Unchanged = append(plan.Issuers.Unchanged, iss.DID)
+			continue
+		default:
+			plan.Issuers.Updated = append(plan.Issuers.Updated, iss.DID)
+		}
+		plan.issuers = append(plan.issuers, iss)
+	}
+	plan.Issuers.Extra = sortedKeys(currentIss)
+	return plan, nil
+}
+
+// Empty reports whether applying the plan would change nothing
+func (p *Plan) Empty() bool {
+	return len(p.policies) == 0 && len(p.issuers) == 0
+}
+
+// Apply writes the plan's created and updated objects to st
+func (p *Plan) Apply(ctx context.Context, st Store) error {
+	// Issuers first, so policies naming them never apply before they exist
+	for _, iss := range p.issuers {
+		if err := st.UpsertIssuer(ctx, iss); err != nil {
+			return fmt.Errorf("issuer %s: %w", iss.DID, err)
+		}
+	}
+	for _, pol := range p.policies {
+		if err := st.UpsertPolicy(ctx, pol); err != nil {
+			return fmt.Errorf("policy %s: %w", pol.ID, err)
+		}
+	}
+	return nil
+}
+
+// Validate rejects bundles this gateway can't import safely
+func (b *Bundle) Validate() error {
+	if b.Version != Version {
+		return fmt.Errorf("%w: unsupported version %d", ErrInvalidBundle, b.Version)
+	}
+	seen := make(map[string]bool, len(b.Policies))
+	for _, pol := range b.Policies {
+		if pol.ID == "" || seen[pol.ID] {
+			return fmt.Errorf("%w: missing or duplicate policy id %q", ErrInvalidBundle, pol.ID)
+		}
+		seen[pol.ID] = true
+		if pol.Limits != nil {
+			if _, err := resilience.Lookup(pol.Limits.Resilience); err != nil {
+				return fmt.Errorf("%w: policy %s: %v", ErrInvalidBundle, pol.ID, err)
+			}
+		}
+		if pol.Receipt != nil && !proxy.ValidReceiptMode(pol.Receipt.Mode) {
+			return fmt.Errorf("%w: policy %s: unknown receipt mode %q", ErrInvalidBundle, pol.ID, pol.Receipt.Mode)
+		}
+		if pol.Envelope != nil && !proxy.ValidEnvelopeEnc(pol.Envelope.Enc) {
+			return fmt.Errorf("%w: policy %s: unknown envelope enc %q", ErrInvalidBundle, pol.ID, pol.Envelope.Enc)
+		}
+	}
+	seen = make(map[string]bool, len(b.Issuers))
+	for _, iss := range b.Issuers {
+		if err := validate.ValidateDID(iss.DID); err != nil || seen[iss.DID] {
+			return fmt.Errorf("%w: invalid or duplicate issuer %q", ErrInvalidBundle, iss.DID)
+		}
+		seen[iss.DID] = true
+		if err := validate.ValidateIssuer(iss); err != nil {
+			return fmt.Errorf("%w: issuer %s: %v", ErrInvalidBundle, iss.DID, err)
+		}
+		if _, err := crypto.DecodePublicKey(iss.PublicKey); err != nil {
+			return fmt.Errorf("%w: issuer %s: %v", ErrInvalidBundle, iss.DID, err)
+		}
+	}
+	return nil
+}
+
+// Sign encodes b as a compact JWS (EdDSA, typ gateway-bundle+jwt)
+func Sign(b Bundle, key ed25519.PrivateKey, kid string) (string, error) {
+	header, err := json.Marshal(map[string]string{"alg": "EdDSA", "typ": bundleType, "kid": kid})
+	if err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(b)
+	if err != nil {
+		return "", err
+	}
+	input := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	return input + "." + base64.RawURLEncoding.EncodeToString(ed25519.Sign(key, []byte(input))), nil
+}
+
+// Verify checks a signed bundle against trusted keys by kid and returns it
+// with the kid that signed it
+func Verify(token string, trusted map[string]ed25519.PublicKey) (Bundle, string, error) {
+	var b Bundle
+	parts := strings.Split(strings.TrimSpace(token), ".")
+	if len(parts) != 3 {
+		return b, "", fmt.Errorf("%w: not a JWS", ErrInvalidBundle)
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Typ string `json:"typ"`
+		Kid string `json:"kid"`
+	}
+	if err := jws.DecodeSegment(parts[0], &header); err != nil {
+		return b, "", fmt.Errorf("%w: header: %v", ErrInvalidBundle, err)
+	}
+	if header.Alg != "EdDSA" || header.Typ != bundleType {
+		return b, "", fmt.Errorf("%w: must be an EdDSA %s", ErrInvalidBundle, bundleType)
+	}
+	pub, ok := trusted[header.Kid]
+	if !ok {
+		return b, "", fmt.Errorf("%w: kid %q", ErrUntrustedSigner, header.Kid)
+	}
+	if err := crypto.VerifySignature(pub, parts[0]+"."+parts[1], parts[2]); err != nil {
+		return b, "", fmt.Errorf("%w: %v", ErrUntrustedSigner, err)
+	}
+	if err := jws.DecodeSegment(parts[1], &b); err != nil {
+		return b, "", fmt.Errorf("%w: payload: %v", ErrInvalidBundle, err)
+	}
+	return b, header.Kid, nil
+}
+
+// sameJSON compares two values by their JSON encoding, which is what the
+// bundle carries
+func sameJSON(a, b interface{}) bool {
+	ja, errA := json.Marshal(a)
+	jb, errB := json.Marshal(b)
+	return errA == nil && errB == nil && bytes.Equal(ja, jb)
+}
+
+// sameIssuer compares issuers ignoring timestamps, which differ per
+// environment
+func sameIssuer(a, b models.Issuer) bool {
+	a.CreatedAt, a.UpdatedAt = time.Time{}, time.Time{}
+	b.CreatedAt, b.UpdatedAt = time.Time{}, time.Time{}
+	return a == b
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
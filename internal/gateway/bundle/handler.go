@@ -0,0 +1,63 @@
+package bundle
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/example/privacy-gateway/internal/shared/httpx"
+)
+
+// maxBundleSize caps an uploaded bundle
+const maxBundleSize = 16 << 20
+
+// Handler serves the admin bundle endpoints:
+//
+//	GET  /admin/v1/bundle               signed bundle (application/jose)
+//	POST /admin/v1/bundle?dry_run=true  import a signed bundle from the body
+//
+// actor identifies the caller in the audit trail.
+func Handler(m *Manager, actor func(*http.Request) string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			token, err := m.Export(r.Context())
+			if errors.Is(err, ErrNoSigningKey) {
+				httpx.WriteJSON(w, http.StatusNotImplemented, httpx.ErrorResponse{Error: err.Error()})
+				return
+			}
+			if err != nil {
+				httpx.WriteJSON(w, http.StatusInternalServerError, httpx.ErrorResponse{Error: "failed to export bundle"})
+				return
+			}
+			w.Header().Set("Content-Type", "application/jose")
+			w.Header().Set("Content-Disposition", `attachment; filename="gateway-bundle.jws"`)
+			_, _ = io.WriteString(w, token)
+		case http.MethodPost:
+			body, err := io.ReadAll(io.LimitReader(r.Body, maxBundleSize+1))
+			if err != nil || len(body) > maxBundleSize {
+				httpx.WriteJSON(w, http.StatusBadRequest, httpx.ErrorResponse{Error: "bundle is unreadable or too large"})
+				return
+			}
+			dryRun, _ := strconv.ParseBool(r.URL.Query().Get("dry_run"))
+			who := "admin"
+			if actor != nil {
+				who = actor(r)
+			}
+			res, err := m.Import(r.Context(), string(body), dryRun, who)
+			switch {
+			case errors.Is(err, ErrUntrustedSigner):
+				httpx.WriteJSON(w, http.StatusForbidden, httpx.ErrorResponse{Error: err.Error()})
+			case errors.Is(err, ErrInvalidBundle):
+				httpx.WriteJSON(w, http.StatusBadRequest, httpx.ErrorResponse{Error: err.Error()})
+			case err != nil:
+				httpx.WriteJSON(w, http.StatusInternalServerError, httpx.ErrorResponse{Error: "import failed: " + err.Error()})
+			default:
+				httpx.WriteJSON(w, http.StatusOK, res)
+			}
+		default:
+			httpx.WriteJSON(w, http.StatusMethodNotAllowed, httpx.ErrorResponse{Error: "method not allowed"})
+		}
+	}
+}
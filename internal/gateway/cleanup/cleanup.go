@@ -0,0 +1,230 @@
+package cleanup
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/example/privacy-gateway/internal/shared/observability"
+	"github.com/example/privacy-gateway/internal/shared/scheduler"
+)
+
+// Store purges expired rows; store.Postgres implements it
+type Store interface {
+	PurgeAuditEvents(ctx context.Context, before time.Time, batchSize int) (int64, error)
+	PurgeMeteringRecords(ctx context.Context, before time.Time, batchSize int) (int64, error)
+	PurgeQuotaUsage(ctx context.Context, beforePeriod string, batchSize int) (int64, error)
+	PurgeRevocationDeltas(ctx context.Context, before time.Time) (int64, error)
+}
+
+// Retention is how long each kind of data is kept. Zero uses the default;
+// a negative value keeps the data forever.
+type Retention struct {
+	AuditEvents      time.Duration // default 400 days
+	MeteringRecords  time.Duration // default 90 days
+	QuotaUsage       time.Duration // Whole months older than this are purged (default 400 days)
+	RevocationDeltas time.Duration // default 7 days
+}
+
+// DefaultRedisPatterns match the single-use and session keys that must carry
+// a TTL: admin login nonces and sessions, cross-device sessions, OID4VCI
+// codes and tokens, proof-of-work solutions and revocation push jtis
+var DefaultRedisPatterns = []string{"adm:n|*", "adm:s|*", "session:v1:*", "vci:*", "pow|*", "rvl:push|*"}
+
+// Config configures the cleanup jobs
+type Config struct {
+	Store     Store
+	Redis     *redis.Client // Optional; enables the Redis sweep
+	Retention Retention
+	// RedisPatterns are the keys swept for a missing TTL (default DefaultRedisPatterns)
+	RedisPatterns []string
+	BatchSize     int    // Rows deleted per statement (default 5000)
+	Schedule      string // Postgres purge schedule (default @daily)
+	SweepSchedule string // Redis sweep schedule (default @hourly)
+	Logger        *slog.Logger
+}
+
+// Targets reported in Stats
+const (
+	TargetAuditEvents      = "audit_events"
+	TargetMeteringRecords  = "metering_records"
+	TargetQuotaUsage       = "quota_usage"
+	TargetRevocationDeltas = "revocation_deltas"
+	TargetRedisKeys        = "redis_keys"
+)
+
+// TargetStats counts what one target's purges removed
+type TargetStats struct {
+	Purged    int64      `json:"purged"` // Total rows (or keys) removed
+	LastRun   *time.Time `json:"last_run,omitempty"`
+	LastCount int64      `json:"last_count"`
+	LastError string     `json:"last_error,omitempty"`
+}
+
+// Cleaner deletes data past its retention. Sessions, login nonces and other
+// single-use values live in Redis with TTLs and expire on their own; the
+// sweep only deletes keys under those prefixes that somehow lack a TTL
+// (written by an old release or restored from a snapshot), which would
+// otherwise live forever.
+type Cleaner struct {
+	cfg Config
+
+	mu    sync.Mutex
+	stats map[string]*TargetStats
+}
+
+// New creates a cleaner
+func New(cfg Config) *Cleaner {
+	const day = 24 * time.Hour
+	defaults := []struct {
+		d   *time.Duration
+		def time.Duration
+	}{
+		{&cfg.Retention.AuditEvents, 400 * day},
+		{&cfg.Retention.MeteringRecords, 90 * day},
+		{&cfg.Retention.QuotaUsage, 400 * day},
+		{&cfg.Retention.RevocationDeltas, 7 * day},
+	}
+	for _, d := range defaults {
+		if *d.d == 0 {
+			*d.d = d.def
+		}
+	}
+	if cfg.RedisPatterns == nil {
+		cfg.RedisPatterns = DefaultRedisPatterns
+	}
+	if cfg.BatchSize == 0 {
+		cfg.BatchSize = 5000
+	}
+	if cfg.Schedule == "" {
+		cfg.Schedule = "@daily"
+	}
+	if cfg.SweepSchedule == "" {
+		cfg.SweepSchedule = "@hourly"
+	}
+	cfg.Logger = observability.Component(cfg.Logger, observability.ComponentJobs)
+	return &Cleaner{cfg: cfg, stats: make(map[string]*TargetStats)}
+}
+
+// Jobs returns the cleanup jobs for the singleton scheduler
+func (c *Cleaner) Jobs() []scheduler.Job {
+	jobs := []scheduler.Job{{
+		Name:     "cleanup-postgres",
+		Schedule: c.cfg.Schedule,
+		Jitter:   10 * time.Minute,
+		Run:      c.PurgePostgres,
+	}}
+	if c.cfg.Redis != nil {
+		jobs = append(jobs, scheduler.Job{
+			Name:     "cleanup-redis",
+			Schedule: c.cfg.SweepSchedule,
+			Jitter:   5 * time.Minute,
+			Run:      c.SweepRedis,
+		})
+	}
+	return jobs
+}
+
+// PurgePostgres deletes rows past their retention from every table with one
+// configured. A failing table doesn't stop the others.
+func (c *Cleaner) PurgePostgres(ctx context.Context) error {
+	now := time.Now().UTC()
+	r := c.cfg.Retention
+	var errs []error
+	if r.AuditEvents > 0 {
+		errs = append(errs, c.purge(TargetAuditEvents, func() (int64, error) {
+			return c.cfg.Store.PurgeAuditEvents(ctx, now.Add(-r.AuditEvents), c.cfg.BatchSize)
+		}))
+	}
+	if r.MeteringRecords > 0 {
+		errs = append(errs, c.purge(TargetMeteringRecords, func() (int64, error) {
+			return c.cfg.Store.PurgeMeteringRecords(ctx, now.Add(-r.MeteringRecords), c.cfg.BatchSize)
+		}))
+	}
+	if r.QuotaUsage > 0 {
+		// Only whole months go, so a monthly total is never left without its days
+		period := now.Add(-r.QuotaUsage).Format("2006-01")
+		errs = append(errs, c.purge(TargetQuotaUsage, func() (int64, error) {
+			return c.cfg.Store.PurgeQuotaUsage(ctx, period, c.cfg.BatchSize)
+		}))
+	}
+	if r.RevocationDeltas > 0 {
+		errs = append(errs, c.purge(TargetRevocationDeltas, func() (int64, error) {
+			return c.cfg.Store.PurgeRevocationDeltas(ctx, now.Add(-r.RevocationDeltas))
+		}))
+	}
+	return errors.Join(errs...)
+}
+
+// SweepRedis deletes keys matching RedisPatterns that have no TTL
+func (c *Cleaner) SweepRedis(ctx context.Context) error {
+	if c.cfg.Redis == nil {
+		return nil
+	}
+	return c.purge(TargetRedisKeys, func() (int64, error) {
+		var deleted int64
+		for _, pattern := range c.cfg.RedisPatterns {
+			iter := c.cfg.Redis.Scan(ctx, 0, pattern, 500).Iterator()
+			for iter.Next(ctx) {
+				key := iter.Val()
+				ttl, err := c.cfg.Redis.TTL(ctx, key).Result()
+				if err != nil {
+					return deleted, err
+				}
+				if ttl != -1 {
+					continue // Has a TTL, or already gone
+				}
+				n, err := c.cfg.Redis.Del(ctx, key).Result()
+				if err != nil {
+					return deleted, err
+				}
+				deleted += n
+			}
+			if err := iter.Err(); err != nil {
+				return deleted, err
+			}
+		}
+		return deleted, nil
+	})
+}
+
+// purge runs one target's purge and records its outcome
+func (c *Cleaner) purge(target string, fn func() (int64, error)) error {
+	start := time.Now()
+	n, err := fn()
+
+	c.mu.Lock()
+	st, ok := c.stats[target]
+	if !ok {
+		st = &TargetStats{}
+		c.stats[target] = st
+	}
+	st.Purged += n
+	st.LastRun, st.LastCount, st.LastError = &start, n, ""
+	if err != nil {
+		st.LastError = err.Error()
+	}
+	c.mu.Unlock()
+
+	if err != nil {
+		c.cfg.Logger.Error("cleanup failed", "target", target, "purged", n, "error", err)
+		return err
+	}
+	c.cfg.Logger.Info("cleanup finished", "target", target, "purged", n, "duration", time.Since(start))
+	return nil
+}
+
+// Stats returns purge counts per target, for metrics
+func (c *Cleaner) Stats() map[string]TargetStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	out := make(map[string]TargetStats, len(c.stats))
+	for target, st := range c.stats {
+		out[target] = *st
+	}
+	return out
+}
@@ -0,0 +1,79 @@
+package credential
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/fnv"
+	"math"
+)
+
+// bloomFilter is a fixed-size Bloom filter over strings. Hashes are stable
+// across processes (FNV), so a filter built on one replica can be shared
+// with the others through Redis.
+type bloomFilter struct {
+	k    uint32
+	bits []uint64
+}
+
+// newBloomFilter sizes a filter for n entries at false positive rate p
+func newBloomFilter(n int, p float64) *bloomFilter {
+	n = max(n, 1)
+	m := math.Ceil(-float64(n) * math.Log(p) / (math.Ln2 * math.Ln2))
+	k := math.Round(m / float64(n) * math.Ln2)
+	words := max(1, int(math.Ceil(m/64)))
+	return &bloomFilter{k: uint32(max(1, k)), bits: make([]uint64, words)}
+}
+
+// indexes derives the k bit positions of s by double hashing
+func (b *bloomFilter) indexes(s string, fn func(uint64) bool) bool {
+	h1 := fnv.New64a()
+	h1.Write([]byte(s))
+	h2 := fnv.New64()
+	h2.Write([]byte(s))
+	a, c := h1.Sum64(), h2.Sum64()|1
+	m := uint64(len(b.bits)) * 64
+	for i := uint32(0); i < b.k; i++ {
+		if !fn((a + uint64(i)*c) % m) {
+			return false
+		}
+	}
+	return true
+}
+
+func (b *bloomFilter) add(s string) {
+	b.indexes(s, func(i uint64) bool {
+		b.bits[i/64] |= 1 << (i % 64)
+		return true
+	})
+}
+
+// mayContain is false only for strings that were never added
+func (b *bloomFilter) mayContain(s string) bool {
+	return b.indexes(s, func(i uint64) bool {
+		return b.bits[i/64]&(1<<(i%64)) != 0
+	})
+}
+
+// marshal encodes the filter as k (4 bytes) followed by the bit words
+func (b *bloomFilter) marshal() []byte {
+	out := make([]byte, 4+8*len(b.bits))
+	binary.BigEndian.PutUint32(out, b.k)
+	for i, w := range b.bits {
+		binary.BigEndian.PutUint64(out[4+8*i:], w)
+	}
+	return out
+}
+
+func unmarshalBloomFilter(data []byte) (*bloomFilter, error) {
+	if len(data) < 12 || (len(data)-4)%8 != 0 {
+		return nil, errors.New("malformed bloom filter")
+	}
+	b := &bloomFilter{k: binary.BigEndian.Uint32(data), bits: make([]uint64, (len(data)-4)/8)}
+	if b.k == 0 || b.k > 64 {
+		return nil, errors.New("malformed bloom filter")
+	}
+	for i := range b.bits {
+		b.bits[i] = binary.BigEndian.Uint64(data[4+8*i:])
+	}
+	return b, nil
+}
@@ -0,0 +1,149 @@
+package credential
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/example/privacy-gateway/internal/gateway/did"
+	"github.com/example/privacy-gateway/internal/shared/budget"
+	"github.com/example/privacy-gateway/internal/shared/crypto"
+	"github.com/example/privacy-gateway/internal/shared/models"
+)
+
+var (
+	ErrUntrustedIssuer  = errors.New("issuer not trusted")
+	ErrInvalidSignature = errors.New("invalid credential signature")
+	ErrRevoked          = errors.New("credential revoked")
+	ErrSchema           = errors.New("credential does not match schema")
+	ErrKeyPinMismatch   = errors.New("issuer DID document key does not match pinned key")
+)
+
+// IssuerStore looks up registered issuers
+type IssuerStore interface {
+	GetIssuer(ctx context.Context, did string) (models.Issuer, error)
+}
+
+// RevocationStore looks up revocation lists
+type RevocationStore interface {
+	GetRevocationList(ctx context.Context, listID string) (models.RevocationList, error)
+}
+
+// SignatureCheck verifies the EdDSA signature of a credential against the
+// public key registered for its issuer
+func SignatureCheck(issuers IssuerStore) Check {
+	return Check{Name: "signature", Run: func(ctx context.Context, c *Credential) error {
+		iss, err := issuers.GetIssuer(ctx, c.Claims.Issuer)
+		if err != nil {
+			if ctx.Err() != nil {
+				return err
+			}
+			return fmt.Errorf("%w: %s", ErrUntrustedIssuer, c.Claims.Issuer)
+		}
+		if !iss.Enabled {
+			return fmt.Errorf("%w: %s is disabled", ErrUntrustedIssuer, c.Claims.Issuer)
+		}
+		if c.Alg != "EdDSA" {
+			return fmt.Errorf("%w: unsupported alg %s", ErrInvalidSignature, c.Alg)
+		}
+		pub, err := crypto.DecodePublicKey(iss.PublicKey)
+		if err != nil {
+			return fmt.Errorf("%w: issuer key: %v", ErrInvalidSignature, err)
+		}
+		if err := crypto.VerifySignature(pub, c.signingInput, c.signature); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidSignature, err)
+		}
+		return nil
+	}}
+}
+
+// KeyPinCheck protects against a compromised issuer DID document. For
+// issuers with KeyPinning "enforce", the credential's kid must name an
+// assertion method in the resolved document whose key equals the pinned
+// Issuer.PublicKey. Other issuers pass unchecked.
+func KeyPinCheck(issuers IssuerStore, res did.Resolver) Check {
+	return Check{Name: "key_pin", Run: func(ctx context.Context, c *Credential) error {
+		iss, err := issuers.GetIssuer(ctx, c.Claims.Issuer)
+		if err != nil {
+			if ctx.Err() != nil {
+				return err
+			}
+			return fmt.Errorf("%w: %s", ErrUntrustedIssuer, c.Claims.Issuer)
+		}
+		if iss.KeyPinning != models.KeyPinningEnforce {
+			return nil
+		}
+		pinned, err := crypto.DecodePublicKey(iss.PublicKey)
+		if err != nil {
+			return fmt.Errorf("%w: pinned key: %v", ErrKeyPinMismatch, err)
+		}
+		if c.KeyID == "" {
+			return fmt.Errorf("%w: credential has no kid", ErrKeyPinMismatch)
+		}
+		doc, err := res.Resolve(ctx, c.Claims.Issuer, did.ResolveOptions{})
+		if err != nil {
+			return fmt.Errorf("resolve issuer: %w", err)
+		}
+		key, err := doc.AssertionKey(c.KeyID)
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrKeyPinMismatch, err)
+		}
+		if !pinned.Equal(key) {
+			return fmt.Errorf("%w: %s", ErrKeyPinMismatch, c.KeyID)
+		}
+		return nil
+	}}
+}
+
+// RevocationCheck rejects credentials whose jti is on the given revocation list
+func RevocationCheck(lists RevocationStore, listID string) Check {
+	return Check{Name: "revocation", Run: func(ctx context.Context, c *Credential) error {
+		var list models.RevocationList
+		err := budget.Run(ctx, budget.StageRevocation, func(ctx context.Context) (err error) {
+			list, err = lists.GetRevocationList(ctx, listID)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		for _, jti := range list.Revoked {
+			if jti == c.Claims.JWTID {
+				return fmt.Errorf("%w: %s", ErrRevoked, jti)
+			}
+		}
+		return nil
+	}}
+}
+
+// SchemaCheck requires credentialSubject fields per credential type. Every
+// credential must be a VerifiableCredential; types without an entry in
+// required only need a credentialSubject.
+func SchemaCheck(required map[string][]string) Check {
+	return Check{Name: "schema", Run: func(ctx context.Context, c *Credential) error {
+		types := c.Types()
+		if !contains(types, "VerifiableCredential") {
+			return fmt.Errorf("%w: type must include VerifiableCredential", ErrSchema)
+		}
+		subject := c.Subject()
+		if subject == nil {
+			return fmt.Errorf("%w: missing credentialSubject", ErrSchema)
+		}
+		for _, t := range types {
+			for _, field := range required[t] {
+				if _, ok := subject[field]; !ok {
+					return fmt.Errorf("%w: %s requires credentialSubject.%s", ErrSchema, t, field)
+				}
+			}
+		}
+		return nil
+	}}
+}
+
+func contains(list []string, v string) bool {
+	for _, s := range list {
+		if s == v {
+			return true
+		}
+	}
+	return false
+}
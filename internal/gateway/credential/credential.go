@@ -0,0 +1,120 @@
+package credential
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/example/privacy-gateway/internal/shared/jws"
+	"github.com/example/privacy-gateway/internal/shared/models"
+)
+
+var ErrMalformed = errors.New("malformed credential")
+
+// Credential is a parsed JWT-VC. Parsing does not verify anything; see Verifier
+type Credential struct {
+	Raw    string
+	Alg    string
+	KeyID  string
+	Claims models.CredentialClaims
+
+	signingInput string
+	signature    string
+}
+
+// Parse decodes the header and claims of a JWT-VC
+func Parse(raw string) (*Credential, error) {
+	head, rest, ok := strings.Cut(raw, ".")
+	if !ok {
+		return nil, fmt.Errorf("%w: not a JWT", ErrMalformed)
+	}
+	payload, sig, ok := strings.Cut(rest, ".")
+	if !ok || strings.Contains(sig, ".") {
+		return nil, fmt.Errorf("%w: not a JWT", ErrMalformed)
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := jws.DecodeSegment(head, &header); err != nil {
+		return nil, fmt.Errorf("%w: header: %v", ErrMalformed, err)
+	}
+	c := &Credential{Raw: raw, Alg: header.Alg, KeyID: header.Kid, signingInput: raw[:len(head)+1+len(payload)], signature: sig}
+	if err := jws.DecodeSegment(payload, &c.Claims); err != nil {
+		return nil, fmt.Errorf("%w: claims: %v", ErrMalformed, err)
+	}
+	if c.Claims.Issuer == "" || c.Claims.VC == nil {
+		return nil, fmt.Errorf("%w: missing iss or vc", ErrMalformed)
+	}
+	return c, nil
+}
+
+// Types returns the vc.type entries
+func (c *Credential) Types() []string {
+	list, _ := c.Claims.VC["type"].([]interface{})
+	types := make([]string, 0, len(list))
+	for _, t := range list {
+		if s, ok := t.(string); ok {
+			types = append(types, s)
+		}
+	}
+	return types
+}
+
+// Subject returns vc.credentialSubject
+func (c *Credential) Subject() map[string]interface{} {
+	subject, _ := c.Claims.VC["credentialSubject"].(map[string]interface{})
+	return subject
+}
+
+// ApplyTo records a verified credential in access token claims: its types
+// and issuer, the issuer's trust tier, and the credentialSubject claims
+// named in include (e.g. proxy.ReferencedVCClaims). Token minting calls it
+// after verification so policies and ${vc_*} transform values see the
+// credential.
+func (c *Credential) ApplyTo(claims *models.AccessTokenClaims, trustTier int, include []string) {
+	claims.VCTypes = c.Types()
+	claims.VCIssuer = c.Claims.Issuer
+	claims.VCTrustTier = trustTier
+	claims.VCClaims = nil
+	subject := c.Subject()
+	for _, name := range include {
+		v, ok := subject[name]
+		if !ok {
+			continue
+		}
+		if claims.VCClaims == nil {
+			claims.VCClaims = make(map[string]interface{}, len(include))
+		}
+		claims.VCClaims[name] = v
+	}
+}
+
+// FromPresentation returns the JWT-VCs embedded in a JWT verifiable
+// presentation (vp.verifiableCredential). The presentation's own signature is
+// not checked here; holder binding is verified by the caller.
+func FromPresentation(vp string) ([]string, error) {
+	parts := strings.Split(vp, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("%w: presentation is not a JWT", ErrMalformed)
+	}
+	var claims struct {
+		VP struct {
+			VerifiableCredential []json.RawMessage `json:"verifiableCredential"`
+		} `json:"vp"`
+	}
+	if err := jws.DecodeSegment(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("%w: presentation: %v", ErrMalformed, err)
+	}
+	creds := make([]string, 0, len(claims.VP.VerifiableCredential))
+	for _, raw := range claims.VP.VerifiableCredential {
+		var s string
+		if err := json.Unmarshal(raw, &s); err != nil {
+			return nil, fmt.Errorf("%w: only JWT credentials are supported in presentations", ErrMalformed)
+		}
+		creds = append(creds, s)
+	}
+	return creds, nil
+}
@@ -0,0 +1,46 @@
+package credential_test
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"github.com/example/privacy-gateway/internal/gateway/credential"
+)
+
+func segment(s string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(s))
+}
+
+func FuzzParse(f *testing.F) {
+	f.Add(segment(`{"alg":"EdDSA","kid":"did:web:issuer.example#key-1"}`) + "." +
+		segment(`{"iss":"did:web:issuer.example","sub":"did:key:z6Mk","vc":{"type":["VerifiableCredential","KYC"],"credentialSubject":{"id":"did:key:z6Mk"}}}`) + ".c2ln")
+	f.Add(segment(`{}`) + "." + segment(`{"iss":"x","vc":{"type":"KYC","credentialSubject":[]}}`) + ".")
+	f.Add("a.b.c.d")
+	f.Add("..")
+	f.Add("")
+
+	f.Fuzz(func(t *testing.T, raw string) {
+		c, err := credential.Parse(raw)
+		if err != nil {
+			return
+		}
+		_ = c.Types()
+		_ = c.Subject()
+	})
+}
+
+func FuzzFromPresentation(f *testing.F) {
+	f.Add(segment(`{"alg":"EdDSA"}`) + "." + segment(`{"vp":{"verifiableCredential":["a.b.c"]}}`) + ".c2ln")
+	f.Add(segment(`{}`) + "." + segment(`{"vp":{"verifiableCredential":[{"type":"ldp"}]}}`) + ".")
+	f.Add("a.b")
+
+	f.Fuzz(func(t *testing.T, vp string) {
+		creds, err := credential.FromPresentation(vp)
+		if err != nil {
+			return
+		}
+		for _, raw := range creds {
+			_, _ = credential.Parse(raw)
+		}
+	})
+}
@@ -0,0 +1,296 @@
+package credential
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/example/privacy-gateway/internal/shared/budget"
+	"github.com/example/privacy-gateway/internal/shared/models"
+	"github.com/example/privacy-gateway/internal/shared/observability"
+)
+
+// RevocationFilterConfig configures a RevocationFilter
+type RevocationFilterConfig struct {
+	Lists             RevocationStore // Authoritative lists
+	Redis             *redis.Client   // Optional; shares built filters across replicas
+	FalsePositiveRate float64         // Default 0.001
+	RebuildInterval   time.Duration   // Default 30s
+	Logger            *slog.Logger
+}
+
+// RevocationFilterStats counts filter outcomes. FalsePositives are filter
+// hits the authoritative list didn't confirm.
+type RevocationFilterStats struct {
+	Lists          int    `json:"lists"`
+	Negatives      uint64 `json:"negatives"`
+	Positives      uint64 `json:"positives"`
+	FalsePositives uint64 `json:"false_positives"`
+	Fallbacks      uint64 `json:"fallbacks"`
+	Rebuilds       uint64 `json:"rebuilds"`
+}
+
+// RevocationFilter keeps a Bloom filter per revocation list so the common
+// "not revoked" answer doesn't need the full list. A filter hit is always
+// confirmed against the authoritative list, and a list without a filter
+// (not built yet, build failed) is checked directly, so the filter never
+// accepts a jti the list revokes, except for revocations made since the
+// last rebuild. Filters are rebuilt every RebuildInterval; Invalidate forces
+// a rebuild after a list changes.
+type RevocationFilter struct {
+	cfg RevocationFilterConfig
+
+	mu      sync.Mutex
+	filters map[string]*listFilter
+
+	negatives      atomic.Uint64
+	positives      atomic.Uint64
+	falsePositives atomic.Uint64
+	fallbacks      atomic.Uint64
+	rebuilds       atomic.Uint64
+}
+
+type listFilter struct {
+	load  sync.Mutex // Serializes builds of one list
+	bloom atomic.Pointer[bloomFilter]
+	built atomic.Int64 // Unix nanoseconds
+}
+
+// NewRevocationFilter creates a filter; call Run to rebuild periodically
+func NewRevocationFilter(cfg RevocationFilterConfig) *RevocationFilter {
+	if cfg.FalsePositiveRate <= 0 || cfg.FalsePositiveRate >= 1 {
+		cfg.FalsePositiveRate = 0.001
+	}
+	if cfg.RebuildInterval == 0 {
+		cfg.RebuildInterval = 30 * time.Second
+	}
+	cfg.Logger = observability.Component(cfg.Logger, observability.ComponentRevocation)
+	return &RevocationFilter{cfg: cfg, filters: make(map[string]*listFilter)}
+}
+
+func filterKey(listID string) string {
+	return "rvb|" + listID
+}
+
+// IsRevoked reports whether jti is on the list
+func (f *RevocationFilter) IsRevoked(ctx context.Context, listID, jti string) (bool, error) {
+	bloom := f.filter(ctx, listID)
+	if bloom != nil && !bloom.mayContain(jti) {
+		f.negatives.Add(1)
+		return false, nil
+	}
+	if bloom == nil {
+		f.fallbacks.Add(1)
+	}
+	list, err := f.cfg.Lists.GetRevocationList(ctx, listID)
+	if err != nil {
+		return false, err
+	}
+	for _, revoked := range list.Revoked {
+		if revoked == jti {
+			f.positives.Add(1)
+			return true, nil
+		}
+	}
+	if bloom != nil {
+		f.falsePositives.Add(1)
+	}
+	return false, nil
+}
+
+// filter returns the list's filter, building it on first use. It returns
+// nil when no filter could be built.
+func (f *RevocationFilter) filter(ctx context.Context, listID string) *bloomFilter {
+	f.mu.Lock()
+	lf, ok := f.filters[listID]
+	if !ok {
+		lf = &listFilter{}
+		f.filters[listID] = lf
+	}
+	f.mu.Unlock()
+
+	if b := lf.bloom.Load(); b != nil {
+		return b
+	}
+	lf.load.Lock()
+	defer lf.load.Unlock()
+	if b := lf.bloom.Load(); b != nil {
+		return b
+	}
+	if err := f.load(ctx, listID, lf); err != nil {
+		f.cfg.Logger.Warn("revocation filter unavailable", "list_id", listID, "error", err)
+		return nil
+	}
+	return lf.bloom.Load()
+}
+
+// load takes a filter shared by another replica when there is one, and
+// builds it from the authoritative list otherwise
+func (f *RevocationFilter) load(ctx context.Context, listID string, lf *listFilter) error {
+	if f.cfg.Redis != nil {
+		if data, err := f.cfg.Redis.Get(ctx, filterKey(listID)).Bytes(); err == nil {
+			if b, err := unmarshalBloomFilter(data); err == nil {
+				lf.bloom.Store(b)
+				lf.built.Store(time.Now().UnixNano())
+				return nil
+			}
+		}
+	}
+	return f.build(ctx, listID, lf)
+}
+
+// build fetches the list, replaces the filter and shares it
+func (f *RevocationFilter) build(ctx context.Context, listID string, lf *listFilter) error {
+	list, err := f.cfg.Lists.GetRevocationList(ctx, listID)
+	if err != nil {
+		return fmt.Errorf("fetch revocation list: %w", err)
+	}
+	b := newBloomFilter(len(list.Revoked), f.cfg.FalsePositiveRate)
+	for _, jti := range list.Revoked {
+		b.add(jti)
+	}
+	lf.bloom.Store(b)
+	lf.built.Store(time.Now().UnixNano())
+	f.rebuilds.Add(1)
+	if f.cfg.Redis != nil {
+		// Expires before the next rebuild round, so replicas never load a
+		// filter older than one interval
+		if err := f.cfg.Redis.Set(ctx, filterKey(listID), b.marshal(), f.cfg.RebuildInterval).Err(); err != nil {
+			f.cfg.Logger.Warn("share revocation filter", "list_id", listID, "error", err)
+		}
+	}
+	return nil
+}
+
+// Rebuild rebuilds a list's filter from the authoritative list
+func (f *RevocationFilter) Rebuild(ctx context.Context, listID string) error {
+	f.mu.Lock()
+	lf, ok := f.filters[listID]
+	if !ok {
+		lf = &listFilter{}
+		f.filters[listID] = lf
+	}
+	f.mu.Unlock()
+	lf.load.Lock()
+	defer lf.load.Unlock()
+	return f.build(ctx, listID, lf)
+}
+
+// Invalidate rebuilds a list's filter after the list changed, dropping the
+// shared copy so other replicas don't reload the stale one. Other replicas
+// pick up the change at their next rebuild.
+func (f *RevocationFilter) Invalidate(ctx context.Context, listID string) error {
+	if f.cfg.Redis != nil {
+		if err := f.cfg.Redis.Del(ctx, filterKey(listID)).Err(); err != nil {
+			return err
+		}
+	}
+	return f.Rebuild(ctx, listID)
+}
+
+// Apply adds a delta's revocations to a loaded filter, e.g. from
+// revocation.Sync.Subscribe, so they take effect before the next rebuild.
+// Removals wait for the rebuild; until then they only cost a list lookup.
+func (f *RevocationFilter) Apply(delta models.RevocationDelta) {
+	f.mu.Lock()
+	lf, ok := f.filters[delta.ListID]
+	f.mu.Unlock()
+	if !ok {
+		return
+	}
+	lf.load.Lock()
+	defer lf.load.Unlock()
+	old := lf.bloom.Load()
+	if old == nil || len(delta.Added) == 0 {
+		return
+	}
+	// Copy on write: readers hold the old filter without locks
+	b := &bloomFilter{k: old.k, bits: append([]uint64(nil), old.bits...)}
+	for _, jti := range delta.Added {
+		b.add(jti)
+	}
+	lf.bloom.Store(b)
+}
+
+// Run rebuilds every known list's filter each interval until ctx is
+// cancelled
+func (f *RevocationFilter) Run(ctx context.Context) {
+	ticker := time.NewTicker(f.cfg.RebuildInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			_ = f.RebuildAll(ctx)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// RebuildAll refreshes every known list's filter once, keeping a list's
+// previous filter when its refresh fails. It is the unit of work for
+// scheduling rebuilds as a job instead of with Run.
+func (f *RevocationFilter) RebuildAll(ctx context.Context) error {
+	f.mu.Lock()
+	ids := make([]string, 0, len(f.filters))
+	for id := range f.filters {
+		ids = append(ids, id)
+	}
+	f.mu.Unlock()
+	var errs []error
+	for _, id := range ids {
+		f.mu.Lock()
+		lf := f.filters[id]
+		f.mu.Unlock()
+		lf.load.Lock()
+		err := f.load(ctx, id, lf)
+		lf.load.Unlock()
+		if err != nil {
+			// Keep serving the previous filter; a failed fetch is no reason
+			// to fall back to full lookups
+			f.cfg.Logger.Warn("revocation filter rebuild failed", "list_id", id, "error", err)
+			errs = append(errs, fmt.Errorf("list %s: %w", id, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Stats returns the filter's counters
+func (f *RevocationFilter) Stats() RevocationFilterStats {
+	f.mu.Lock()
+	n := len(f.filters)
+	f.mu.Unlock()
+	return RevocationFilterStats{
+		Lists:          n,
+		Negatives:      f.negatives.Load(),
+		Positives:      f.positives.Load(),
+		FalsePositives: f.falsePositives.Load(),
+		Fallbacks:      f.fallbacks.Load(),
+		Rebuilds:       f.rebuilds.Load(),
+	}
+}
+
+// FilteredRevocationCheck is RevocationCheck with a Bloom filter in front of
+// the list lookup
+func FilteredRevocationCheck(f *RevocationFilter, listID string) Check {
+	return Check{Name: "revocation", Run: func(ctx context.Context, c *Credential) error {
+		var revoked bool
+		err := budget.Run(ctx, budget.StageRevocation, func(ctx context.Context) (err error) {
+			revoked, err = f.IsRevoked(ctx, listID, c.Claims.JWTID)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		if revoked {
+			return fmt.Errorf("%w: %s", ErrRevoked, c.Claims.JWTID)
+		}
+		return nil
+	}}
+}
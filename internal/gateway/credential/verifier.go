@@ -0,0 +1,144 @@
+package credential
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/example/privacy-gateway/internal/shared/clock"
+)
+
+var (
+	ErrNoCredentials = errors.New("no credentials")
+	ErrExpired       = errors.New("credential expired")
+)
+
+// Check is one verification step run against every credential
+type Check struct {
+	Name string
+	Run  func(ctx context.Context, c *Credential) error
+}
+
+// Config configures a Verifier
+type Config struct {
+	Workers int           // Concurrent checks per Verify call (default 8)
+	Timeout time.Duration // Deadline for a whole Verify call (default 5s)
+	Clock   clock.Clock   // Checks credential expiry (default clock.Real)
+}
+
+// Verifier runs checks over the credentials of a presentation. Every
+// (credential, check) pair is an independent job on a bounded worker pool, so
+// a presentation with many VCs costs roughly the slowest check rather than
+// the sum of all of them. The first failure cancels the remaining jobs.
+type Verifier struct {
+	cfg    Config
+	checks []Check
+}
+
+// NewVerifier creates a verifier that runs checks against every credential
+func NewVerifier(cfg Config, checks ...Check) *Verifier {
+	if cfg.Workers <= 0 {
+		cfg.Workers = 8
+	}
+	if cfg.Timeout == 0 {
+		cfg.Timeout = 5 * time.Second
+	}
+	cfg.Clock = clock.Or(cfg.Clock)
+	return &Verifier{cfg: cfg, checks: checks}
+}
+
+type job struct {
+	cred  *Credential
+	index int
+	check Check
+}
+
+// Verify parses and checks raw JWT-VCs, returning them in input order. The
+// returned error names the credential index and check that failed first.
+func (v *Verifier) Verify(ctx context.Context, raws []string) ([]*Credential, error) {
+	if len(raws) == 0 {
+		return nil, ErrNoCredentials
+	}
+
+	// Parsing and expiry are cheap; fail before spinning up workers
+	now := v.cfg.Clock.Now().Unix()
+	creds := make([]*Credential, len(raws))
+	for i, raw := range raws {
+		c, err := Parse(raw)
+		if err != nil {
+			return nil, fmt.Errorf("credential %d: %w", i, err)
+		}
+		if c.Claims.Expiry != 0 && now >= c.Claims.Expiry {
+			return nil, fmt.Errorf("credential %d: %w", i, ErrExpired)
+		}
+		creds[i] = c
+	}
+
+	total := len(creds) * len(v.checks)
+	if total == 0 {
+		return creds, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, v.cfg.Timeout)
+	defer cancel()
+
+	var (
+		once     sync.Once
+		firstErr error
+		done     atomic.Int64
+	)
+	fail := func(err error) {
+		once.Do(func() {
+			firstErr = err
+			cancel()
+		})
+	}
+
+	jobs := make(chan job)
+	workers := v.cfg.Workers
+	if workers > total {
+		workers = total
+	}
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				if ctx.Err() != nil {
+					continue
+				}
+				if err := j.check.Run(ctx, j.cred); err != nil {
+					fail(fmt.Errorf("credential %d: %s: %w", j.index, j.check.Name, err))
+					continue
+				}
+				done.Add(1)
+			}
+		}()
+	}
+
+dispatch:
+	for i, c := range creds {
+		for _, check := range v.checks {
+			select {
+			case jobs <- job{cred: c, index: i, check: check}:
+			case <-ctx.Done():
+				break dispatch
+			}
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	if done.Load() < int64(total) {
+		// Jobs were skipped because the caller went away or the deadline passed
+		return nil, ctx.Err()
+	}
+	return creds, nil
+}
@@ -0,0 +1,219 @@
+package crossdevice
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/skip2/go-qrcode"
+
+	"github.com/example/privacy-gateway/internal/shared/challenge"
+	"github.com/example/privacy-gateway/internal/shared/httpx"
+	"github.com/example/privacy-gateway/internal/shared/models"
+	"github.com/example/privacy-gateway/internal/shared/validate"
+)
+
+// DeepLinkScheme is the URI scheme wallets register for cross-device login
+const DeepLinkScheme = "didauth"
+
+// startResponse is returned to the initiating browser
+type startResponse struct {
+	ID          string `json:"id"`
+	ChallengeID string `json:"challenge_id"`
+	PollSecret  string `json:"poll_secret"`
+	DeepLink    string `json:"deep_link"`
+	QRCodeURL   string `json:"qr_code_url"`
+	ExpiresAt   int64  `json:"expires_at"`
+}
+
+// statusResponse is returned when polling a session
+type statusResponse struct {
+	Status      string `json:"status"`
+	AccessToken string `json:"access_token,omitempty"`
+	TokenType   string `json:"token_type,omitempty"`
+	ExpiresIn   int64  `json:"expires_in,omitempty"`
+}
+
+// Handler serves the cross-device endpoints under /v1/auth/cross-device:
+//
+//	POST /v1/auth/cross-device                 start a session (browser)
+//	GET  /v1/auth/cross-device/{id}/qr          QR code for the deep link (?format=png|svg)
+//	GET  /v1/auth/cross-device/{id}/challenge   the session's challenge (wallet, ?did=)
+//	GET  /v1/auth/cross-device/{id}             poll status (browser, X-Poll-Secret header)
+//
+// The wallet signs the session's challenge and calls /v1/auth/verify with
+// cross_device_id set to {id}; the verify handler then calls Complete.
+type Handler struct {
+	store      *Store
+	challenges *challenge.Generator
+	baseURL    string // Public gateway URL embedded in deep links
+}
+
+// NewHandler creates the cross-device handler. challenges must be the
+// generator /v1/auth/verify checks challenges against.
+func NewHandler(store *Store, challenges *challenge.Generator, baseURL string) *Handler {
+	return &Handler{store: store, challenges: challenges, baseURL: strings.TrimSuffix(baseURL, "/")}
+}
+
+// DeepLink builds the wallet deep link for a session
+func (h *Handler) DeepLink(id string) string {
+	q := url.Values{}
+	q.Set("gateway", h.baseURL)
+	q.Set("session", id)
+	return DeepLinkScheme + "://login?" + q.Encode()
+}
+
+// ServeHTTP dispatches on the path below /v1/auth/cross-device
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rest := strings.Trim(strings.TrimPrefix(r.URL.Path, "/v1/auth/cross-device"), "/")
+	parts := strings.Split(rest, "/")
+
+	switch {
+	case rest == "" && r.Method == http.MethodPost:
+		h.start(w, r)
+	case len(parts) == 2 && parts[1] == "qr" && r.Method == http.MethodGet:
+		h.qr(w, r, parts[0])
+	case len(parts) == 2 && parts[1] == "challenge" && r.Method == http.MethodGet:
+		h.challenge(w, r, parts[0])
+	case len(parts) == 1 && rest != "" && r.Method == http.MethodGet:
+		h.poll(w, r, parts[0])
+	default:
+		httpx.WriteJSON(w, http.StatusNotFound, httpx.ErrorResponse{Error: "not found"})
+	}
+}
+
+func (h *Handler) start(w http.ResponseWriter, r *http.Request) {
+	nonce, err := h.challenges.NewNonce()
+	if err != nil {
+		httpx.WriteJSON(w, http.StatusInternalServerError, httpx.ErrorResponse{Error: "failed to start session"})
+		return
+	}
+	sess, secret, err := h.store.Create(r.Context(), nonce)
+	if err != nil {
+		httpx.WriteJSON(w, http.StatusInternalServerError, httpx.ErrorResponse{Error: "failed to start session"})
+		return
+	}
+	httpx.WriteJSON(w, http.StatusCreated, startResponse{
+		ID:          sess.ID,
+		ChallengeID: sess.ChallengeID,
+		PollSecret:  secret,
+		DeepLink:    h.DeepLink(sess.ID),
+		QRCodeURL:   fmt.Sprintf("%s/v1/auth/cross-device/%s/qr", h.baseURL, sess.ID),
+		ExpiresAt:   sess.ExpiresAt.Unix(),
+	})
+}
+
+// challenge issues the wallet a challenge for its DID carrying the nonce
+// reserved for the session, so verifying it can complete the session
+func (h *Handler) challenge(w http.ResponseWriter, r *http.Request, id string) {
+	did := r.URL.Query().Get("did")
+	if err := validate.ValidateDID(did); err != nil {
+		httpx.WriteJSON(w, http.StatusBadRequest, httpx.ErrorResponse{Error: "invalid did"})
+		return
+	}
+	sess, err := h.store.Get(r.Context(), id)
+	if err != nil || sess.Status != StatusPending {
+		httpx.WriteJSON(w, http.StatusNotFound, httpx.ErrorResponse{Error: "session not found"})
+		return
+	}
+	c, err := h.challenges.GenerateWithNonce(did, sess.ChallengeID)
+	if err != nil {
+		httpx.WriteJSON(w, http.StatusBadRequest, httpx.ErrorResponse{Error: "invalid did"})
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	httpx.WriteJSON(w, http.StatusOK, models.ChallengeResponse{
+		Challenge: c.String(),
+		Nonce:     c.Nonce,
+		ExpiresAt: c.ExpiresAt,
+		Audience:  c.Audience,
+		Domain:    c.Domain,
+	})
+}
+
+// Complete hands the token minted by /v1/auth/verify to the browser session
+// named by req.CrossDeviceID. c is the verified challenge; it must carry the
+// session's nonce. Requests without a cross-device ID are left alone.
+func (h *Handler) Complete(ctx context.Context, req models.AuthVerifyRequest, c challenge.Challenge, token models.AuthVerifyResponse) error {
+	if req.CrossDeviceID == "" {
+		return nil
+	}
+	return h.store.Complete(ctx, req.CrossDeviceID, c.Nonce, c.DID, token)
+}
+
+func (h *Handler) qr(w http.ResponseWriter, r *http.Request, id string) {
+	sess, err := h.store.Get(r.Context(), id)
+	if err != nil || sess.Status != StatusPending {
+		httpx.WriteJSON(w, http.StatusNotFound, httpx.ErrorResponse{Error: "session not found"})
+		return
+	}
+
+	code, err := qrcode.New(h.DeepLink(id), qrcode.Medium)
+	if err != nil {
+		httpx.WriteJSON(w, http.StatusInternalServerError, httpx.ErrorResponse{Error: "failed to render QR code"})
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	switch r.URL.Query().Get("format") {
+	case "svg":
+		w.Header().Set("Content-Type", "image/svg+xml")
+		_, _ = w.Write(renderSVG(code.Bitmap(), 8))
+	default:
+		png, err := code.PNG(256)
+		if err != nil {
+			httpx.WriteJSON(w, http.StatusInternalServerError, httpx.ErrorResponse{Error: "failed to render QR code"})
+			return
+		}
+		w.Header().Set("Content-Type", "image/png")
+		_, _ = w.Write(png)
+	}
+}
+
+func (h *Handler) poll(w http.ResponseWriter, r *http.Request, id string) {
+	sess, err := h.store.Poll(r.Context(), id, r.Header.Get("X-Poll-Secret"))
+	if err != nil {
+		writePollError(w, r, err)
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	httpx.WriteJSON(w, http.StatusOK, toStatusResponse(sess))
+}
+
+// toStatusResponse converts a session into the polling payload
+func toStatusResponse(sess *Session) statusResponse {
+	resp := statusResponse{Status: sess.Status}
+	if sess.Token != nil {
+		resp.AccessToken = sess.Token.AccessToken
+		resp.TokenType = sess.Token.TokenType
+		resp.ExpiresIn = sess.Token.ExpiresIn
+	}
+	return resp
+}
+
+// renderSVG draws a QR bitmap as an SVG using one rect per dark module row run
+func renderSVG(bitmap [][]bool, scale int) []byte {
+	size := len(bitmap) * scale
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">`,
+		size, size, size, size)
+	fmt.Fprintf(&b, `<rect width="%d" height="%d" fill="#fff"/>`, size, size)
+	for y, row := range bitmap {
+		for x := 0; x < len(row); {
+			if !row[x] {
+				x++
+				continue
+			}
+			start := x
+			for x < len(row) && row[x] {
+				x++
+			}
+			fmt.Fprintf(&b, `<rect x="%d" y="%d" width="%d" height="%d"/>`,
+				start*scale, y*scale, (x-start)*scale, scale)
+		}
+	}
+	b.WriteString(`</svg>`)
+	return []byte(b.String())
+}
@@ -0,0 +1,244 @@
+package crossdevice
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/example/privacy-gateway/internal/shared/bus"
+	"github.com/example/privacy-gateway/internal/shared/cache"
+	"github.com/example/privacy-gateway/internal/shared/crypto"
+	"github.com/example/privacy-gateway/internal/shared/models"
+)
+
+var (
+	ErrSessionNotFound = errors.New("cross-device session not found or expired")
+	ErrBadSecret       = errors.New("invalid poll secret")
+	ErrAlreadyComplete = errors.New("cross-device session already completed")
+	ErrWrongChallenge  = errors.New("challenge was not issued for this cross-device session")
+)
+
+// Session status values
+const (
+	StatusPending   = "pending"
+	StatusCompleted = "completed"
+	StatusConsumed  = "consumed"
+)
+
+// Session tracks one cross-device login. The initiating browser holds the poll
+// secret; the wallet only ever sees the session ID (via QR / deep link), so a
+// bystander scanning the code can't collect the resulting token. ChallengeID
+// is the nonce reserved for the session's challenge: only a verify of a
+// challenge carrying it completes the session.
+type Session struct {
+	ID          string                     `json:"id"`
+	ChallengeID string                     `json:"challenge_id"`
+	SecretHash  string                     `json:"secret_hash"`
+	Status      string                     `json:"status"`
+	Subject     string                     `json:"subject,omitempty"`
+	Token       *models.AuthVerifyResponse `json:"token,omitempty"`
+	CreatedAt   time.Time                  `json:"created_at"`
+	ExpiresAt   time.Time                  `json:"expires_at"`
+}
+
+// Store persists sessions in Redis so any replica can complete or poll them
+type Store struct {
+	client *redis.Client
+	ttl    time.Duration
+	enc    *cache.Encryptor // Optional; sessions carry access tokens
+	bus    bus.MessageBus   // Completion notifications
+}
+
+// NewStore creates a session store; ttl bounds how long a QR code stays usable
+func NewStore(client *redis.Client, ttl time.Duration) *Store {
+	if ttl == 0 {
+		ttl = 2 * time.Minute
+	}
+	return &Store{client: client, ttl: ttl, bus: bus.NewRedis(client)}
+}
+
+// WithBus sends completion notifications over b instead of Redis pub/sub
+func (s *Store) WithBus(b bus.MessageBus) *Store {
+	s.bus = b
+	return s
+}
+
+// WithEncryption encrypts stored sessions with enc, so the access tokens they
+// hold aren't readable from Redis
+func (s *Store) WithEncryption(enc *cache.Encryptor) *Store {
+	s.enc = enc
+	return s
+}
+
+// encode marshals a session, sealing it when encryption is enabled
+func (s *Store) encode(sess *Session) ([]byte, error) {
+	data, err := json.Marshal(sess)
+	if err != nil || s.enc == nil {
+		return data, err
+	}
+	return s.enc.Seal(sessionKey(sess.ID), data)
+}
+
+// decode opens and unmarshals a stored session
+func (s *Store) decode(id string, data []byte) (*Session, error) {
+	if s.enc != nil {
+		plain, err := s.enc.Open(sessionKey(id), data)
+		if err != nil {
+			// Undecryptable (e.g. sealed with a retired key) is as good as gone
+			return nil, ErrSessionNotFound
+		}
+		data = plain
+	}
+	var sess Session
+	if err := json.Unmarshal(data, &sess); err != nil {
+		return nil, err
+	}
+	return &sess, nil
+}
+
+func sessionKey(id string) string {
+	return cache.SessionKeys.Key("xdev", id)
+}
+
+func completionChannel(id string) string {
+	return "xdev:done:" + id
+}
+
+// Create starts a session bound to challengeID and returns it with the
+// plaintext poll secret
+func (s *Store) Create(ctx context.Context, challengeID string) (*Session, string, error) {
+	id, err := crypto.RandomToken(18)
+	if err != nil {
+		return nil, "", err
+	}
+	secret, err := crypto.RandomToken(32)
+	if err != nil {
+		return nil, "", err
+	}
+
+	now := time.Now().UTC()
+	sess := &Session{
+		ID:          id,
+		ChallengeID: challengeID,
+		SecretHash:  crypto.HashSecret(secret),
+		Status:      StatusPending,
+		CreatedAt:   now,
+		ExpiresAt:   now.Add(s.ttl),
+	}
+	if err := s.save(ctx, sess, s.ttl); err != nil {
+		return nil, "", err
+	}
+	return sess, secret, nil
+}
+
+// Get loads a session by ID
+func (s *Store) Get(ctx context.Context, id string) (*Session, error) {
+	data, err := s.client.Get(ctx, sessionKey(id)).Bytes()
+	if err == redis.Nil {
+		return nil, ErrSessionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s.decode(id, data)
+}
+
+// Complete attaches the token minted by the wallet's verify call.
+// Only a pending session can be completed, and only with the challenge
+// nonce it was created for.
+func (s *Store) Complete(ctx context.Context, id, challengeID, subject string, token models.AuthVerifyResponse) error {
+	err := s.update(ctx, id, func(sess *Session) (bool, error) {
+		if subtle.ConstantTimeCompare([]byte(sess.ChallengeID), []byte(challengeID)) != 1 {
+			return false, ErrWrongChallenge
+		}
+		if sess.Status != StatusPending {
+			return false, ErrAlreadyComplete
+		}
+		sess.Status = StatusCompleted
+		sess.Subject = subject
+		sess.Token = &token
+		return true, nil
+	})
+	if err != nil {
+		return err
+	}
+	// Wake long-pollers and SSE streams on every replica
+	return s.bus.Publish(ctx, completionChannel(id), []byte(StatusCompleted))
+}
+
+// Subscribe returns a subscription that receives a message when the session
+// completes. Subscribe before checking status to avoid missing the event.
+func (s *Store) Subscribe(ctx context.Context, id string) (bus.Subscription, error) {
+	return s.bus.Subscribe(ctx, completionChannel(id))
+}
+
+// Poll returns the session status for the initiating browser. The token is
+// handed out exactly once; later polls see StatusConsumed without it.
+func (s *Store) Poll(ctx context.Context, id, secret string) (*Session, error) {
+	var out Session
+	err := s.update(ctx, id, func(sess *Session) (bool, error) {
+		if subtle.ConstantTimeCompare([]byte(sess.SecretHash), []byte(crypto.HashSecret(secret))) != 1 {
+			return false, ErrBadSecret
+		}
+		out = *sess
+		if sess.Status != StatusCompleted {
+			return false, nil
+		}
+		sess.Status = StatusConsumed
+		sess.Token = nil
+		return true, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// update applies fn to a session under optimistic locking (WATCH/MULTI).
+// fn reports whether it modified the session.
+func (s *Store) update(ctx context.Context, id string, fn func(*Session) (bool, error)) error {
+	key := sessionKey(id)
+	return s.client.Watch(ctx, func(tx *redis.Tx) error {
+		data, err := tx.Get(ctx, key).Bytes()
+		if err == redis.Nil {
+			return ErrSessionNotFound
+		}
+		if err != nil {
+			return err
+		}
+		sess, err := s.decode(id, data)
+		if err != nil {
+			return err
+		}
+		changed, err := fn(sess)
+		if err != nil || !changed {
+			return err
+		}
+		ttl := time.Until(sess.ExpiresAt)
+		if ttl <= 0 {
+			return ErrSessionNotFound
+		}
+		encoded, err := s.encode(sess)
+		if err != nil {
+			return err
+		}
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.Set(ctx, key, encoded, ttl)
+			return nil
+		})
+		return err
+	}, key)
+}
+
+// save writes a session with the given TTL
+func (s *Store) save(ctx context.Context, sess *Session, ttl time.Duration) error {
+	data, err := s.encode(sess)
+	if err != nil {
+		return err
+	}
+	return s.client.Set(ctx, sessionKey(sess.ID), data, ttl).Err()
+}
@@ -0,0 +1,68 @@
+package crossdevice_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+
+	"github.com/example/privacy-gateway/internal/gateway/crossdevice"
+	"github.com/example/privacy-gateway/internal/shared/challenge"
+	"github.com/example/privacy-gateway/internal/shared/models"
+)
+
+const wallet = "did:key:z6MkhaXgBZDvotDkL5257faiztiGiC2QtKLGpbnnEGta2doK"
+
+// TestCompleteRequiresSessionChallenge checks that only a verify of the
+// challenge reserved for a session completes it
+func TestCompleteRequiresSessionChallenge(t *testing.T) {
+	ctx := context.Background()
+	mr := miniredis.RunT(t)
+	store := crossdevice.NewStore(redis.NewClient(&redis.Options{Addr: mr.Addr()}), time.Minute)
+	gen, err := challenge.NewGenerator(challenge.Config{Audience: "did-gateway", Domain: "localhost"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := crossdevice.NewHandler(store, gen, "https://gateway.example.com")
+
+	nonce, err := gen.NewNonce()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sess, secret, err := store.Create(ctx, nonce)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := models.AuthVerifyRequest{DID: wallet, CrossDeviceID: sess.ID}
+	token := models.AuthVerifyResponse{AccessToken: "tok", TokenType: "Bearer", ExpiresIn: 300}
+
+	other, err := gen.Generate(wallet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Complete(ctx, req, other, token); !errors.Is(err, crossdevice.ErrWrongChallenge) {
+		t.Fatalf("other challenge: err = %v, want ErrWrongChallenge", err)
+	}
+
+	bound, err := gen.GenerateWithNonce(wallet, sess.ChallengeID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Complete(ctx, req, bound, token); err != nil {
+		t.Fatalf("session challenge: %v", err)
+	}
+	if err := h.Complete(ctx, req, bound, token); !errors.Is(err, crossdevice.ErrAlreadyComplete) {
+		t.Fatalf("second completion: err = %v, want ErrAlreadyComplete", err)
+	}
+
+	got, err := store.Poll(ctx, sess.ID, secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Status != crossdevice.StatusCompleted || got.Subject != wallet || got.Token == nil || got.Token.AccessToken != "tok" {
+		t.Fatalf("poll = %+v", got)
+	}
+}
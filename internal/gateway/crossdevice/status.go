@@ -0,0 +1,178 @@
+package crossdevice
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/example/privacy-gateway/internal/shared/httpx"
+	"github.com/example/privacy-gateway/internal/shared/i18n"
+)
+
+// Long-poll bounds
+const (
+	defaultWait = 25 * time.Second
+	maxWait     = 55 * time.Second
+	sseKeepOpen = 2 * time.Minute
+)
+
+// StatusHandler serves GET /v1/auth/status/{id}, where id is the session ID
+// from POST /v1/auth/cross-device. It is keyed by session rather than by the
+// session's challenge ID because sessions are stored and their completions
+// published under the session ID, and the challenge ID is a nonce that the
+// wallet also sees. The poll secret is read from X-Poll-Secret, or the
+// poll_secret query parameter for EventSource clients which cannot set
+// headers.
+//
+// With Accept: text/event-stream the response is an SSE stream emitting a
+// "status" event immediately and again on completion. Otherwise the request
+// long-polls: it returns as soon as the session completes or after ?wait=N
+// seconds (default 25, max 55) with the current status.
+func (h *Handler) StatusHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			httpx.WriteJSON(w, http.StatusMethodNotAllowed, httpx.ErrorResponse{Error: "method not allowed"})
+			return
+		}
+		id := strings.Trim(strings.TrimPrefix(r.URL.Path, "/v1/auth/status/"), "/")
+		secret := r.Header.Get("X-Poll-Secret")
+		if secret == "" {
+			secret = r.URL.Query().Get("poll_secret")
+		}
+
+		if strings.Contains(r.Header.Get("Accept"), "text/event-stream") {
+			h.streamStatus(w, r, id, secret)
+			return
+		}
+		h.longPoll(w, r, id, secret)
+	}
+}
+
+func (h *Handler) longPoll(w http.ResponseWriter, r *http.Request, id, secret string) {
+	wait := defaultWait
+	if v := r.URL.Query().Get("wait"); v != "" {
+		secs, err := strconv.Atoi(v)
+		if err != nil || secs < 0 {
+			httpx.WriteJSON(w, http.StatusBadRequest, httpx.ErrorResponse{Error: "invalid wait"})
+			return
+		}
+		wait = min(time.Duration(secs)*time.Second, maxWait)
+	}
+
+	ctx := r.Context()
+	sub, err := h.store.Subscribe(ctx, id)
+	if err != nil {
+		httpx.WriteJSON(w, http.StatusServiceUnavailable, httpx.ErrorResponse{Error: "session notifications unavailable"})
+		return
+	}
+	defer sub.Close()
+
+	sess, err := h.store.Poll(ctx, id, secret)
+	if err != nil {
+		writePollError(w, r, err)
+		return
+	}
+	if sess.Status == StatusPending && wait > 0 {
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+		select {
+		case <-sub.Channel():
+			sess, err = h.store.Poll(ctx, id, secret)
+			if err != nil {
+				writePollError(w, r, err)
+				return
+			}
+		case <-timer.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	httpx.WriteJSON(w, http.StatusOK, toStatusResponse(sess))
+}
+
+func (h *Handler) streamStatus(w http.ResponseWriter, r *http.Request, id, secret string) {
+	ctx := r.Context()
+	sub, err := h.store.Subscribe(ctx, id)
+	if err != nil {
+		httpx.WriteJSON(w, http.StatusServiceUnavailable, httpx.ErrorResponse{Error: "session notifications unavailable"})
+		return
+	}
+	defer sub.Close()
+
+	sess, err := h.store.Poll(ctx, id, secret)
+	if err != nil {
+		writePollError(w, r, err)
+		return
+	}
+
+	// Middleware wrappers expose the connection's Flusher through Unwrap,
+	// which ResponseController follows. Flushing before anything is written
+	// sends the headers; if nothing in the chain can flush, nothing has been
+	// written and the status is returned as JSON instead, since the poll
+	// above may already have handed out the token.
+	rc := http.NewResponseController(w)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("X-Accel-Buffering", "no")
+	if err := rc.Flush(); errors.Is(err, http.ErrNotSupported) {
+		w.Header().Del("Content-Type")
+		w.Header().Del("X-Accel-Buffering")
+		httpx.WriteJSON(w, http.StatusOK, toStatusResponse(sess))
+		return
+	} else if err != nil {
+		return
+	}
+
+	send := func(resp statusResponse) {
+		data, _ := json.Marshal(resp)
+		fmt.Fprintf(w, "event: status\ndata: %s\n\n", data)
+		_ = rc.Flush()
+	}
+	send(toStatusResponse(sess))
+	if sess.Status != StatusPending {
+		return
+	}
+
+	keepAlive := time.NewTicker(15 * time.Second)
+	defer keepAlive.Stop()
+	deadline := time.NewTimer(sseKeepOpen)
+	defer deadline.Stop()
+
+	for {
+		select {
+		case <-sub.Channel():
+			if sess, err = h.store.Poll(ctx, id, secret); err == nil {
+				send(toStatusResponse(sess))
+			}
+			return
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+			if err := rc.Flush(); err != nil {
+				return
+			}
+		case <-deadline.C:
+			return
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// writePollError maps store errors to HTTP responses. Sessions expire, so
+// the user sees a localized "code expired" message for a missing one.
+func writePollError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, ErrSessionNotFound):
+		i18n.WriteErrorMessage(w, r, http.StatusNotFound, "session not found", i18n.CodeSessionExpired)
+	case errors.Is(err, ErrBadSecret):
+		httpx.WriteJSON(w, http.StatusForbidden, httpx.ErrorResponse{Error: "invalid poll secret"})
+	default:
+		httpx.WriteJSON(w, http.StatusInternalServerError, httpx.ErrorResponse{Error: "failed to load session"})
+	}
+}
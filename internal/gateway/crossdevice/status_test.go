@@ -0,0 +1,78 @@
+package crossdevice_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+
+	"github.com/example/privacy-gateway/internal/gateway/crossdevice"
+	"github.com/example/privacy-gateway/internal/shared/challenge"
+	"github.com/example/privacy-gateway/internal/shared/models"
+)
+
+// unwrapWriter hides the recorder's Flusher behind Unwrap, as the access
+// log and metering middleware do
+type unwrapWriter struct{ http.ResponseWriter }
+
+func (w unwrapWriter) Unwrap() http.ResponseWriter { return w.ResponseWriter }
+
+// plainWriter can't flush at all
+type plainWriter struct{ rec *httptest.ResponseRecorder }
+
+func (w plainWriter) Header() http.Header         { return w.rec.Header() }
+func (w plainWriter) Write(b []byte) (int, error) { return w.rec.Write(b) }
+func (w plainWriter) WriteHeader(code int)        { w.rec.WriteHeader(code) }
+
+func TestStatusStreamsBehindWrappers(t *testing.T) {
+	cases := []struct {
+		name  string
+		wrap  func(*httptest.ResponseRecorder) http.ResponseWriter
+		ctype string
+	}{
+		{"unwrap", func(rec *httptest.ResponseRecorder) http.ResponseWriter { return unwrapWriter{rec} }, "text/event-stream"},
+		{"no flusher", func(rec *httptest.ResponseRecorder) http.ResponseWriter { return plainWriter{rec} }, "application/json"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			ctx := context.Background()
+			mr := miniredis.RunT(t)
+			store := crossdevice.NewStore(redis.NewClient(&redis.Options{Addr: mr.Addr()}), time.Minute)
+			gen, err := challenge.NewGenerator(challenge.Config{Audience: "did-gateway", Domain: "localhost"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			h := crossdevice.NewHandler(store, gen, "https://gateway.example.com")
+
+			nonce, _ := gen.NewNonce()
+			sess, secret, err := store.Create(ctx, nonce)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := store.Complete(ctx, sess.ID, nonce, wallet, models.AuthVerifyResponse{AccessToken: "tok"}); err != nil {
+				t.Fatal(err)
+			}
+
+			r := httptest.NewRequest(http.MethodGet, "/v1/auth/status/"+sess.ID, nil)
+			r.Header.Set("Accept", "text/event-stream")
+			r.Header.Set("X-Poll-Secret", secret)
+			rec := httptest.NewRecorder()
+			h.StatusHandler()(c.wrap(rec), r)
+
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d, body %s", rec.Code, rec.Body)
+			}
+			if got := rec.Header().Get("Content-Type"); !strings.HasPrefix(got, c.ctype) {
+				t.Fatalf("Content-Type = %q, want %s", got, c.ctype)
+			}
+			if !strings.Contains(rec.Body.String(), `"access_token":"tok"`) {
+				t.Fatalf("token not delivered: %s", rec.Body)
+			}
+		})
+	}
+}
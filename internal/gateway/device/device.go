@@ -0,0 +1,288 @@
+package device
+
+import (
+	"context"
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/example/privacy-gateway/internal/gateway/store"
+	"github.com/example/privacy-gateway/internal/gateway/webhook"
+	"github.com/example/privacy-gateway/internal/shared/audit"
+	"github.com/example/privacy-gateway/internal/shared/crypto"
+	"github.com/example/privacy-gateway/internal/shared/models"
+	"github.com/example/privacy-gateway/internal/shared/observability"
+	"github.com/example/privacy-gateway/internal/shared/validate"
+)
+
+var (
+	ErrInvalidRequest = errors.New("invalid device request")
+	ErrRevoked        = errors.New("device revoked")
+	ErrDuplicate      = errors.New("device key already registered")
+)
+
+// Device sources
+const (
+	SourceDocument = "document" // A verification method of the DID document, seen at login
+	SourceRegistry = "registry" // A device key registered with the gateway
+)
+
+// maxLabel bounds device labels, in bytes
+const maxLabel = 64
+
+// maxCached bounds the revocation cache; it is cleared when full
+const maxCached = 100000
+
+// Store persists devices
+type Store interface {
+	ListDevices(ctx context.Context, did string) ([]models.Device, error)
+	GetDevice(ctx context.Context, did, id string) (models.Device, error)
+	GetDeviceByKey(ctx context.Context, did, keyID string) (models.Device, error)
+	CreateDevice(ctx context.Context, d models.Device) (bool, error)
+	TouchDevice(ctx context.Context, id string) error
+	LabelDevice(ctx context.Context, did, id, label string) error
+	RevokeDevice(ctx context.Context, did, id string) error
+}
+
+// Publisher delivers device notifications; webhook.Dispatcher implements it
+type Publisher interface {
+	Publish(eventType string, data map[string]interface{}) error
+}
+
+// Config configures the device registry
+type Config struct {
+	Store     Store
+	Publisher Publisher // Optional; receives device.added and device.revoked
+	Audit     audit.Sink
+	CacheTTL  time.Duration // How long replicas cache revocation checks (default 30s)
+	Logger    *slog.Logger
+}
+
+// Registry tracks the devices each DID authenticates with, so tokens can
+// name the device (the device_id claim) and a lost device can be revoked
+// without rotating the DID. A device is either a verification method of
+// the DID document, registered the first time the DID logs in with it, or
+// a device key (a did:key) registered through the API, which lets DIDs
+// with a single key, such as did:key, use several devices.
+type Registry struct {
+	cfg   Config
+	audit *audit.Recorder
+
+	mu    sync.Mutex
+	cache map[string]cachedState
+}
+
+type cachedState struct {
+	revoked bool
+	expires time.Time
+}
+
+// NewRegistry creates a device registry
+func NewRegistry(cfg Config) (*Registry, error) {
+	if cfg.Store == nil {
+		return nil, errors.New("device registry requires a store")
+	}
+	if cfg.CacheTTL == 0 {
+		cfg.CacheTTL = 30 * time.Second
+	}
+	cfg.Logger = observability.Component(cfg.Logger, observability.ComponentAuth)
+	return &Registry{cfg: cfg, audit: audit.NewRecorder(cfg.Audit, cfg.Logger, nil), cache: make(map[string]cachedState)}, nil
+}
+
+// Identify returns the device of did using keyID, the verification method
+// the subject just proved control of. A key seen for the first time becomes
+// a new device and triggers a device.added notification; a revoked device
+// returns ErrRevoked and the login must fail.
+func (r *Registry) Identify(ctx context.Context, did, keyID string) (models.Device, error) {
+	if keyID == "" {
+		return models.Device{}, fmt.Errorf("%w: key ID is required", ErrInvalidRequest)
+	}
+	d, err := r.cfg.Store.GetDeviceByKey(ctx, did, keyID)
+	if err == nil {
+		if d.RevokedAt != nil {
+			return d, ErrRevoked
+		}
+		if err := r.cfg.Store.TouchDevice(ctx, d.ID); err != nil {
+			r.cfg.Logger.Warn("failed to update device last seen", "device", d.ID, "error", err)
+		}
+		return d, nil
+	}
+	if !errors.Is(err, store.ErrNotFound) {
+		return d, err
+	}
+	return r.create(ctx, models.Device{DID: did, KeyID: keyID, Source: SourceDocument}, "")
+}
+
+// Enrich sets claims.DeviceID from the key the subject authenticated with.
+// Token minting calls it before signing; an error, including ErrRevoked,
+// should fail the mint.
+func (r *Registry) Enrich(ctx context.Context, claims *models.AccessTokenClaims, keyID string) error {
+	d, err := r.Identify(ctx, claims.Subject, keyID)
+	if err != nil {
+		return fmt.Errorf("identify device: %w", err)
+	}
+	claims.DeviceID = d.ID
+	return nil
+}
+
+// Register adds a device key for did. publicKey is the device's Ed25519
+// did:key; the device authenticates as did with it, using the did:key as
+// the key ID (see PublicKey).
+func (r *Registry) Register(ctx context.Context, did, publicKey, label, actor string) (models.Device, error) {
+	if err := validate.ValidateDID(did); err != nil {
+		return models.Device{}, fmt.Errorf("%w: %v", ErrInvalidRequest, err)
+	}
+	if _, err := crypto.DecodeDidKey(publicKey); err != nil {
+		return models.Device{}, fmt.Errorf("%w: public_key must be an Ed25519 did:key", ErrInvalidRequest)
+	}
+	label, err := cleanLabel(label)
+	if err != nil {
+		return models.Device{}, err
+	}
+	return r.create(ctx, models.Device{DID: did, KeyID: publicKey, Label: label, Source: SourceRegistry}, actor)
+}
+
+// PublicKey returns the key of an active registry device of did. The login
+// verifier uses it for key IDs the DID document doesn't contain.
+func (r *Registry) PublicKey(ctx context.Context, did, keyID string) (ed25519.PublicKey, error) {
+	d, err := r.cfg.Store.GetDeviceByKey(ctx, did, keyID)
+	if err != nil {
+		return nil, err
+	}
+	if d.Source != SourceRegistry {
+		return nil, store.ErrNotFound
+	}
+	if d.RevokedAt != nil {
+		return nil, ErrRevoked
+	}
+	return crypto.DecodeDidKey(d.KeyID)
+}
+
+// create inserts a device and notifies subscribers. Two first logins racing
+// with the same key both get the device the first one inserted.
+func (r *Registry) create(ctx context.Context, d models.Device, actor string) (models.Device, error) {
+	existing, err := r.cfg.Store.ListDevices(ctx, d.DID)
+	if err != nil {
+		return models.Device{}, err
+	}
+	d.ID = uuid.NewString()
+	created, err := r.cfg.Store.CreateDevice(ctx, d)
+	if err != nil {
+		return models.Device{}, err
+	}
+	stored, err := r.cfg.Store.GetDeviceByKey(ctx, d.DID, d.KeyID)
+	if err != nil || !created {
+		if err == nil && d.Source == SourceRegistry {
+			err = ErrDuplicate
+		}
+		return stored, err
+	}
+	if actor == "" {
+		actor = d.DID
+	}
+	r.audit.Record(ctx, "device.add", stored.DID, actor, "success", map[string]interface{}{"device_id": stored.ID, "key_id": stored.KeyID, "source": stored.Source})
+	// The first device of a DID is its first login, not a new device
+	r.publish(webhook.EventDeviceAdded, stored, map[string]interface{}{"first_device": len(existing) == 0})
+	return stored, nil
+}
+
+// List returns the devices of did, including revoked ones
+func (r *Registry) List(ctx context.Context, did string) ([]models.Device, error) {
+	return r.cfg.Store.ListDevices(ctx, did)
+}
+
+// Get returns a device of did
+func (r *Registry) Get(ctx context.Context, did, id string) (models.Device, error) {
+	return r.cfg.Store.GetDevice(ctx, did, id)
+}
+
+// Label sets a device's label, e.g. "Work laptop"
+func (r *Registry) Label(ctx context.Context, did, id, label, actor string) (models.Device, error) {
+	label, err := cleanLabel(label)
+	if err != nil {
+		return models.Device{}, err
+	}
+	if err := r.cfg.Store.LabelDevice(ctx, did, id, label); err != nil {
+		return models.Device{}, err
+	}
+	d, err := r.cfg.Store.GetDevice(ctx, did, id)
+	if err != nil {
+		return d, err
+	}
+	r.audit.Record(ctx, "device.label", d.DID, actor, "success", map[string]interface{}{"device_id": d.ID, "label": label})
+	return d, nil
+}
+
+// Revoke revokes a device: its tokens stop passing Revoked checks and it
+// can no longer log in. It returns store.ErrNotFound if the device doesn't
+// exist or is already revoked.
+func (r *Registry) Revoke(ctx context.Context, did, id, actor string) error {
+	if err := r.cfg.Store.RevokeDevice(ctx, did, id); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	delete(r.cache, did+"|"+id)
+	r.mu.Unlock()
+	d, err := r.cfg.Store.GetDevice(ctx, did, id)
+	if err != nil {
+		d = models.Device{ID: id, DID: did}
+	}
+	r.audit.Record(ctx, "device.revoke", d.DID, actor, "success", map[string]interface{}{"device_id": d.ID, "key_id": d.KeyID})
+	r.publish(webhook.EventDeviceRevoked, d, nil)
+	return nil
+}
+
+// Revoked reports whether the device a token was minted for has been
+// revoked. Results are cached per replica for CacheTTL, which bounds how
+// long a revoked device's tokens keep working on other replicas. An
+// unknown device counts as revoked.
+func (r *Registry) Revoked(ctx context.Context, did, deviceID string) (bool, error) {
+	key := did + "|" + deviceID
+	now := time.Now()
+	r.mu.Lock()
+	c, ok := r.cache[key]
+	r.mu.Unlock()
+	if ok && now.Before(c.expires) {
+		return c.revoked, nil
+	}
+	d, err := r.cfg.Store.GetDevice(ctx, did, deviceID)
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		return false, err
+	}
+	revoked := err != nil || d.RevokedAt != nil
+	r.mu.Lock()
+	if len(r.cache) >= maxCached {
+		r.cache = make(map[string]cachedState)
+	}
+	r.cache[key] = cachedState{revoked: revoked, expires: now.Add(r.cfg.CacheTTL)}
+	r.mu.Unlock()
+	return revoked, nil
+}
+
+func cleanLabel(label string) (string, error) {
+	label = strings.TrimSpace(validate.SanitizeString(label, 1<<10))
+	if len(label) > maxLabel {
+		return "", fmt.Errorf("%w: label is limited to %d bytes", ErrInvalidRequest, maxLabel)
+	}
+	return label, nil
+}
+
+// publish sends a device notification; delivery failures are only logged
+func (r *Registry) publish(eventType string, d models.Device, extra map[string]interface{}) {
+	if r.cfg.Publisher == nil {
+		return
+	}
+	data := map[string]interface{}{"did": d.DID, "device_id": d.ID, "label": d.Label, "source": d.Source}
+	for k, v := range extra {
+		data[k] = v
+	}
+	if err := r.cfg.Publisher.Publish(eventType, data); err != nil {
+		r.cfg.Logger.Warn("failed to publish device event", "event", eventType, "device", d.ID, "error", err)
+	}
+}
@@ -0,0 +1,112 @@
+package device
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/example/privacy-gateway/internal/gateway/store"
+	"github.com/example/privacy-gateway/internal/shared/httpx"
+	"github.com/example/privacy-gateway/internal/shared/models"
+)
+
+type registerRequest struct {
+	PublicKey string `json:"public_key"`
+	Label     string `json:"label"`
+}
+
+type labelRequest struct {
+	Label string `json:"label"`
+}
+
+// Handler serves the admin device endpoints:
+//
+//	GET    /admin/v1/devices/{did}       devices of the DID, including revoked ones
+//	POST   /admin/v1/devices/{did}       register a device key: {"public_key", "label"}
+//	GET    /admin/v1/devices/{did}/{id}
+//	PATCH  /admin/v1/devices/{did}/{id}  {"label"}
+//	DELETE /admin/v1/devices/{did}/{id}  revoke the device
+//
+// {did} is path-escaped. actor identifies the caller in the audit trail.
+func Handler(reg *Registry, actor func(*http.Request) string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		who := "admin"
+		if actor != nil {
+			who = actor(r)
+		}
+		rest := strings.Trim(strings.TrimPrefix(r.URL.EscapedPath(), "/admin/v1/devices"), "/")
+		escaped, id, _ := strings.Cut(rest, "/")
+		did, err := url.PathUnescape(escaped)
+		if err != nil || did == "" || strings.Contains(id, "/") {
+			httpx.WriteJSON(w, http.StatusNotFound, httpx.ErrorResponse{Error: "not found"})
+			return
+		}
+
+		switch {
+		case id == "" && r.Method == http.MethodGet:
+			devices, err := reg.List(r.Context(), did)
+			if err != nil {
+				httpx.WriteJSON(w, http.StatusInternalServerError, httpx.ErrorResponse{Error: "failed to list devices"})
+				return
+			}
+			if devices == nil {
+				devices = []models.Device{}
+			}
+			httpx.WriteJSON(w, http.StatusOK, devices)
+		case id == "" && r.Method == http.MethodPost:
+			var req registerRequest
+			if err := httpx.DecodeJSON(r, &req); err != nil {
+				httpx.WriteJSON(w, http.StatusBadRequest, httpx.ErrorResponse{Error: "invalid request body"})
+				return
+			}
+			d, err := reg.Register(r.Context(), did, req.PublicKey, req.Label, who)
+			switch {
+			case errors.Is(err, ErrInvalidRequest):
+				httpx.WriteJSON(w, http.StatusBadRequest, httpx.ErrorResponse{Error: err.Error()})
+			case errors.Is(err, ErrDuplicate):
+				httpx.WriteJSON(w, http.StatusConflict, httpx.ErrorResponse{Error: err.Error()})
+			case err != nil:
+				httpx.WriteJSON(w, http.StatusInternalServerError, httpx.ErrorResponse{Error: "failed to register device"})
+			default:
+				httpx.WriteJSON(w, http.StatusCreated, d)
+			}
+		case id != "" && r.Method == http.MethodGet:
+			d, err := reg.Get(r.Context(), did, id)
+			writeDevice(w, d, err)
+		case id != "" && r.Method == http.MethodPatch:
+			var req labelRequest
+			if err := httpx.DecodeJSON(r, &req); err != nil {
+				httpx.WriteJSON(w, http.StatusBadRequest, httpx.ErrorResponse{Error: "invalid request body"})
+				return
+			}
+			d, err := reg.Label(r.Context(), did, id, req.Label, who)
+			writeDevice(w, d, err)
+		case id != "" && r.Method == http.MethodDelete:
+			err := reg.Revoke(r.Context(), did, id, who)
+			switch {
+			case errors.Is(err, store.ErrNotFound):
+				httpx.WriteJSON(w, http.StatusNotFound, httpx.ErrorResponse{Error: "device not found or already revoked"})
+			case err != nil:
+				httpx.WriteJSON(w, http.StatusInternalServerError, httpx.ErrorResponse{Error: "failed to revoke device"})
+			default:
+				w.WriteHeader(http.StatusNoContent)
+			}
+		default:
+			httpx.WriteJSON(w, http.StatusNotFound, httpx.ErrorResponse{Error: "not found"})
+		}
+	}
+}
+
+func writeDevice(w http.ResponseWriter, d models.Device, err error) {
+	switch {
+	case errors.Is(err, ErrInvalidRequest):
+		httpx.WriteJSON(w, http.StatusBadRequest, httpx.ErrorResponse{Error: err.Error()})
+	case errors.Is(err, store.ErrNotFound):
+		httpx.WriteJSON(w, http.StatusNotFound, httpx.ErrorResponse{Error: "device not found"})
+	case err != nil:
+		httpx.WriteJSON(w, http.StatusInternalServerError, httpx.ErrorResponse{Error: "failed to load device"})
+	default:
+		httpx.WriteJSON(w, http.StatusOK, d)
+	}
+}
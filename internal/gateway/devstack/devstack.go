@@ -0,0 +1,228 @@
+package devstack
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/example/privacy-gateway/internal/shared/crypto"
+	"github.com/example/privacy-gateway/internal/shared/models"
+)
+
+// WebDID is the did:web identity served by the stack's test server. The
+// server shares the gateway's network namespace, so the gateway resolves it
+// from localhost in did:web dev mode.
+const WebDID = "did:web:localhost%3A8888"
+
+// Config parameterizes the generated stack
+type Config struct {
+	// RepoRoot is the repository checkout relative to the output directory,
+	// used as the build context (default "..")
+	RepoRoot   string
+	AdminToken string // Default "admin-token"
+	Wallets    int    // did:key test wallets (default 3)
+	// Seed derives every key, so regenerating with the same seed keeps the
+	// same DIDs (default "devstack")
+	Seed string
+}
+
+func (c Config) withDefaults() Config {
+	if c.RepoRoot == "" {
+		c.RepoRoot = ".."
+	}
+	if c.AdminToken == "" {
+		c.AdminToken = "admin-token"
+	}
+	if c.Wallets == 0 {
+		c.Wallets = 3
+	}
+	if c.Seed == "" {
+		c.Seed = "devstack"
+	}
+	return c
+}
+
+// Wallet is a test identity with its private key. Keys are base64url, as
+// crypto.EncodePublicKey and EncodePrivateKey write them.
+type Wallet struct {
+	Name       string `json:"name"`
+	DID        string `json:"did"`
+	PublicKey  string `json:"public_key"`
+	PrivateKey string `json:"private_key"`
+}
+
+// Stack is the generated seed data
+type Stack struct {
+	Wallets  []Wallet
+	Issuers  []models.Issuer
+	Policies []models.Policy
+}
+
+// key derives the named Ed25519 key from the seed
+func (c Config) key(name string) ed25519.PrivateKey {
+	seed := sha256.Sum256([]byte(c.Seed + "/" + name))
+	return ed25519.NewKeyFromSeed(seed[:])
+}
+
+func (c Config) wallet(name, did string) Wallet {
+	priv := c.key(name)
+	pub := priv.Public().(ed25519.PublicKey)
+	if did == "" {
+		did = crypto.EncodeDidKey(pub)
+	}
+	return Wallet{Name: name, DID: did, PublicKey: crypto.EncodePublicKey(pub), PrivateKey: crypto.EncodePrivateKey(priv)}
+}
+
+// NewStack derives the wallets, a trusted issuer and sample policies
+func NewStack(cfg Config) Stack {
+	cfg = cfg.withDefaults()
+	var s Stack
+	for i := 1; i <= cfg.Wallets; i++ {
+		s.Wallets = append(s.Wallets, cfg.wallet(fmt.Sprintf("wallet-%d", i), ""))
+	}
+	s.Wallets = append(s.Wallets, cfg.wallet("web", WebDID))
+
+	issuer := cfg.wallet("issuer", "")
+	s.Wallets = append(s.Wallets, issuer)
+	s.Issuers = []models.Issuer{{DID: issuer.DID, PublicKey: issuer.PublicKey, Enabled: true, TrustTier: 1}}
+
+	tier := 1
+	s.Policies = []models.Policy{
+		{
+			ID: "basic", Name: "Basic API", Route: "/api/v1/*",
+			RequiredScopes: []string{"basic"}, TokenTTLSeconds: 300,
+			RateLimit: &models.RateLimit{WindowSeconds: 60, MaxRequests: 600},
+		},
+		{
+			ID: "echo", Name: "Echo (load tests)", Route: "/api/echo",
+			RequiredScopes: []string{"basic"}, TokenTTLSeconds: 300, PriorityClass: "normal",
+		},
+		{
+			ID: "premium", Name: "Premium API", Route: "/api/v1/premium/*",
+			RequiredScopes: []string{"premium"}, RequiredVCTypes: []string{"PremiumCredential"},
+			MinTrustTier: &tier, TokenTTLSeconds: 300,
+		},
+	}
+	return s
+}
+
+// Generate returns the stack's files by path relative to the output
+// directory: docker-compose.yml, the collector config, seed data, the seed
+// script and a README
+func Generate(cfg Config) (map[string][]byte, error) {
+	cfg = cfg.withDefaults()
+	s := NewStack(cfg)
+	var web Wallet
+	for _, w := range s.Wallets {
+		if w.DID == WebDID {
+			web = w
+		}
+	}
+
+	compose, err := composeFile(cfg, web)
+	if err != nil {
+		return nil, err
+	}
+	files := map[string][]byte{
+		"docker-compose.yml":  compose,
+		"otel-collector.yaml": []byte(otelCollectorConfig),
+	}
+	wallets, err := json.MarshalIndent(s.Wallets, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	files["seed/wallets.json"] = append(wallets, '\n')
+
+	// One file per admin PUT, listed in order by seed.sh
+	var puts []string
+	add := func(kind, id string, v interface{}) error {
+		data, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			return err
+		}
+		name := path.Join("seed", kind, strings.NewReplacer(":", "_", "%", "_").Replace(id)+".json")
+		files[name] = append(data, '\n')
+		puts = append(puts, fmt.Sprintf("put /v1/%s/%s /%s", kind, url.PathEscape(id), name))
+		return nil
+	}
+	for _, iss := range s.Issuers {
+		// The gateway stamps created_at and updated_at
+		payload := map[string]interface{}{"did": iss.DID, "public_key": iss.PublicKey, "enabled": iss.Enabled, "trust_tier": iss.TrustTier}
+		if err := add("issuers", iss.DID, payload); err != nil {
+			return nil, err
+		}
+	}
+	for _, p := range s.Policies {
+		if err := add("policies", p.ID, p); err != nil {
+			return nil, err
+		}
+	}
+	files["seed/seed.sh"] = seedScript(cfg, puts)
+	files["README.md"] = readme(s)
+	return files, nil
+}
+
+// seedScript waits for the gateway and loads the seed data through the admin
+// API, then registers the issuer service's own DID
+func seedScript(cfg Config, puts []string) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, `#!/bin/sh
+# Generated by didctl devstack: loads the seed data through the admin API
+set -eu
+GATEWAY="${GATEWAY:-http://gateway:8080}"
+ISSUER="${ISSUER:-http://issuer:8090}"
+ADMIN_TOKEN="${ADMIN_TOKEN:-%s}"
+
+put() {
+	curl -fsS -o /dev/null -X PUT "$GATEWAY$1" -H "X-Admin-Token: $ADMIN_TOKEN" \
+		-H 'Content-Type: application/json' --data-binary "@$2"
+	echo "seeded $1"
+}
+
+echo "waiting for $GATEWAY"
+until curl -fsS -o /dev/null "$GATEWAY/healthz"; do sleep 1; done
+
+`, cfg.AdminToken)
+	for _, p := range puts {
+		fmt.Fprintln(&b, p)
+	}
+	b.WriteString(`
+# The issuer service generates its key at startup; trust it as well
+info=$(curl -fsS "$ISSUER/v1/issuer/did")
+did=$(echo "$info" | sed -n 's/.*"did" *: *"\([^"]*\)".*/\1/p')
+key=$(echo "$info" | sed -n 's/.*"public_key" *: *"\([^"]*\)".*/\1/p')
+if [ -n "$did" ] && [ -n "$key" ]; then
+	printf '{"did":"%s","public_key":"%s","enabled":true,"trust_tier":1}' "$did" "$key" > /tmp/issuer.json
+	put "/admin/v1/issuers/$did" /tmp/issuer.json
+fi
+echo "seed complete"
+`)
+	return b.Bytes()
+}
+
+func readme(s Stack) []byte {
+	var b bytes.Buffer
+	b.WriteString("# Local dev stack\n\nGenerated by `didctl devstack`; regenerate rather than edit. Start it from this directory with `docker compose up -d --build`. The `seed` service loads the policies and issuers below once the gateway is up.\n\n")
+	b.WriteString("| Service | URL |\n| --- | --- |\n")
+	b.WriteString("| Gateway | http://localhost:8080 |\n| did:web test server | http://localhost:8888/.well-known/did.json |\n")
+	b.WriteString("| Issuer | http://localhost:8090 |\n| Upstream | http://localhost:8081 |\n| OTLP collector | localhost:4317 (gRPC), localhost:4318 (HTTP) |\n\n")
+	b.WriteString("## Test identities\n\nPrivate keys are in `seed/wallets.json`. They derive from the generator's seed, so they are public; never use them outside this stack.\n\n")
+	b.WriteString("| Name | DID |\n| --- | --- |\n")
+	for _, w := range s.Wallets {
+		fmt.Fprintf(&b, "| %s | `%s` |\n", w.Name, w.DID)
+	}
+	b.WriteString("\n## Policies\n\n| ID | Route | Scopes | Credentials |\n| --- | --- | --- | --- |\n")
+	policies := append([]models.Policy(nil), s.Policies...)
+	sort.Slice(policies, func(i, j int) bool { return policies[i].ID < policies[j].ID })
+	for _, p := range policies {
+		fmt.Fprintf(&b, "| %s | `%s` | %s | %s |\n", p.ID, p.Route, strings.Join(p.RequiredScopes, ", "), strings.Join(p.RequiredVCTypes, ", "))
+	}
+	fmt.Fprintf(&b, "\nTry it:\n\n```bash\ncurl 'http://localhost:8080/v1/auth/challenge?did=%s'\ngo run ./cmd/loadgen -rps 20 -duration 10s\n```\n", s.Wallets[0].DID)
+	return b.Bytes()
+}
@@ -0,0 +1,186 @@
+package diag
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/example/privacy-gateway/internal/shared/circuitbreaker"
+	"github.com/example/privacy-gateway/internal/shared/httpx"
+	"github.com/example/privacy-gateway/internal/shared/tlsconfig"
+)
+
+var ErrClientCARequired = errors.New("diagnostics listener requires a client CA for mTLS")
+
+// Config configures the diagnostics listener
+type Config struct {
+	Addr string // Default 127.0.0.1:6060
+	TLS  tlsconfig.Config
+}
+
+// Server exposes pprof, expvar and runtime stats on a dedicated listener.
+// It never shares the public mux, and every connection must present a client
+// certificate signed by the configured CA.
+type Server struct {
+	cfg Config
+
+	mu       sync.RWMutex
+	sources  map[string]func() interface{}
+	breakers map[string]*circuitbreaker.CircuitBreaker
+}
+
+// NewServer creates the diagnostics server
+func NewServer(cfg Config) *Server {
+	if cfg.Addr == "" {
+		cfg.Addr = "127.0.0.1:6060"
+	}
+	return &Server{
+		cfg:      cfg,
+		sources:  make(map[string]func() interface{}),
+		breakers: make(map[string]*circuitbreaker.CircuitBreaker),
+	}
+}
+
+// Register adds a named stats source (e.g. cache sizes) to /debug/runtime
+func (s *Server) Register(name string, fn func() interface{}) {
+	s.mu.Lock()
+	s.sources[name] = fn
+	s.mu.Unlock()
+}
+
+// RegisterBreaker adds a circuit breaker to /debug/runtime
+func (s *Server) RegisterBreaker(name string, cb *circuitbreaker.CircuitBreaker) {
+	s.mu.Lock()
+	s.breakers[name] = cb
+	s.mu.Unlock()
+}
+
+// Handler returns the diagnostics mux
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.Handle("/debug/vars", expvar.Handler())
+	mux.HandleFunc("/debug/runtime", s.runtimeStats)
+	return mux
+}
+
+// ListenAndServe serves until ctx is cancelled. It refuses to start without
+// mTLS so profiling data is never exposed to unauthenticated clients.
+func (s *Server) ListenAndServe(ctx context.Context) error {
+	if s.cfg.TLS.ClientCAFile == "" {
+		return ErrClientCARequired
+	}
+	tlsCfg := s.cfg.TLS
+	tlsCfg.RequireClientCert = true
+	tc, err := tlsconfig.LoadServerTLSConfig(tlsCfg)
+	if err != nil {
+		return err
+	}
+
+	srv := &http.Server{
+		Addr:              s.cfg.Addr,
+		Handler:           s.Handler(),
+		TLSConfig:         tc,
+		ReadHeaderTimeout: 10 * time.Second,
+		// No WriteTimeout: CPU profiles and traces stream for their duration
+	}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = srv.Shutdown(shutdownCtx)
+	}()
+	if err := srv.ListenAndServeTLS("", ""); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// breakerStats is the JSON form of circuitbreaker.Stats
+type breakerStats struct {
+	State         string    `json:"state"`
+	Failures      int       `json:"failures"`
+	TotalCalls    int64     `json:"total_calls"`
+	TotalFailure  int64     `json:"total_failure"`
+	TotalRejected int64     `json:"total_rejected,omitempty"`
+	InFlight      int       `json:"in_flight,omitempty"`
+	LastFailTime  time.Time `json:"last_fail_time,omitempty"`
+}
+
+// runtimeResponse is returned by /debug/runtime
+type runtimeResponse struct {
+	Goroutines   int                     `json:"goroutines"`
+	GOMAXPROCS   int                     `json:"gomaxprocs"`
+	HeapAlloc    uint64                  `json:"heap_alloc_bytes"`
+	HeapObjects  uint64                  `json:"heap_objects"`
+	Sys          uint64                  `json:"sys_bytes"`
+	NumGC        uint32                  `json:"num_gc"`
+	LastGCPause  time.Duration           `json:"last_gc_pause_ns"`
+	PauseTotal   time.Duration           `json:"gc_pause_total_ns"`
+	NextGCTarget uint64                  `json:"next_gc_bytes"`
+	Breakers     map[string]breakerStats `json:"breakers,omitempty"`
+	Sources      map[string]interface{}  `json:"sources,omitempty"`
+}
+
+func (s *Server) runtimeStats(w http.ResponseWriter, r *http.Request) {
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	resp := runtimeResponse{
+		Goroutines:   runtime.NumGoroutine(),
+		GOMAXPROCS:   runtime.GOMAXPROCS(0),
+		HeapAlloc:    ms.HeapAlloc,
+		HeapObjects:  ms.HeapObjects,
+		Sys:          ms.Sys,
+		NumGC:        ms.NumGC,
+		LastGCPause:  time.Duration(ms.PauseNs[(ms.NumGC+255)%256]),
+		PauseTotal:   time.Duration(ms.PauseTotalNs),
+		NextGCTarget: ms.NextGC,
+		Breakers:     make(map[string]breakerStats),
+		Sources:      make(map[string]interface{}),
+	}
+
+	s.mu.RLock()
+	for name, cb := range s.breakers {
+		st := cb.Stats()
+		resp.Breakers[name] = breakerStats{
+			State:         stateName(st.State),
+			Failures:      st.Failures,
+			TotalCalls:    st.TotalCalls,
+			TotalFailure:  st.TotalFailure,
+			TotalRejected: st.TotalRejected,
+			InFlight:      st.InFlight,
+			LastFailTime:  st.LastFailTime,
+		}
+	}
+	sources := make(map[string]func() interface{}, len(s.sources))
+	for name, fn := range s.sources {
+		sources[name] = fn
+	}
+	s.mu.RUnlock()
+
+	// Sources run outside the lock since they may take their own locks
+	for name, fn := range sources {
+		resp.Sources[name] = fn()
+	}
+	httpx.WriteJSON(w, http.StatusOK, resp)
+}
+
+func stateName(st circuitbreaker.State) string {
+	switch st {
+	case circuitbreaker.StateOpen:
+		return "open"
+	case circuitbreaker.StateHalfOpen:
+		return "half_open"
+	default:
+		return "closed"
+	}
+}
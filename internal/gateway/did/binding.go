@@ -0,0 +1,158 @@
+package did
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/example/privacy-gateway/internal/shared/httpx"
+)
+
+// Media types of the DID Resolution HTTP binding
+const (
+	ContentTypeResolution = `application/ld+json;profile="https://w3id.org/did-resolution"`
+	ContentTypeDIDLDJSON  = "application/did+ld+json"
+	ContentTypeDIDJSON    = "application/did+json"
+	resolutionContext     = "https://w3id.org/did-resolution/v1"
+)
+
+// DID resolution error codes
+const (
+	errInvalidDID         = "invalidDid"
+	errNotFound           = "notFound"
+	errMethodNotSupported = "methodNotSupported"
+	errInternal           = "internalError"
+)
+
+// ResolutionResult is the body of a DID resolution response
+type ResolutionResult struct {
+	Context               string                 `json:"@context"`
+	DIDDocument           *Document              `json:"didDocument"`
+	DIDResolutionMetadata map[string]interface{} `json:"didResolutionMetadata"`
+	DIDDocumentMetadata   map[string]interface{} `json:"didDocumentMetadata"`
+}
+
+// DereferencingResult is the body of a DID URL dereferencing response
+type DereferencingResult struct {
+	Context               string                 `json:"@context"`
+	ContentStream         interface{}            `json:"contentStream"`
+	DereferencingMetadata map[string]interface{} `json:"dereferencingMetadata"`
+	ContentMetadata       map[string]interface{} `json:"contentMetadata"`
+}
+
+// ResolutionHandler serves GET /1.0/identifiers/{did-or-did-url}, the W3C DID
+// Resolution HTTP binding used by the universal resolver. A plain DID returns
+// a resolution result, or the bare document when the client asks for
+// application/did+ld+json or application/did+json. A DID URL (fragment or
+// service query) returns a dereferencing result. Pass the cached resolver.
+func ResolutionHandler(res Resolver) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			httpx.WriteJSON(w, http.StatusMethodNotAllowed, httpx.ErrorResponse{Error: "method not allowed"})
+			return
+		}
+		raw := requestDIDURL(r.URL.EscapedPath(), "/1.0/identifiers/")
+		if r.URL.RawQuery != "" {
+			raw += "?" + r.URL.RawQuery
+		}
+		start := time.Now()
+
+		u, err := ParseURL(raw)
+		if err != nil {
+			writeResolution(w, http.StatusBadRequest, nil, errInvalidDID, start)
+			return
+		}
+
+		doc, err := res.Resolve(r.Context(), u.DID, u.ResolveOptions())
+		if err != nil {
+			status, code := resolutionError(err)
+			writeResolution(w, status, nil, code, start)
+			return
+		}
+
+		if u.Fragment != "" || u.Path != "" || u.Query.Get("service") != "" {
+			content, err := Dereference(doc, u)
+			if err != nil {
+				status, code := resolutionError(err)
+				writeDereference(w, status, nil, code, start)
+				return
+			}
+			writeDereference(w, http.StatusOK, content, "", start)
+			return
+		}
+
+		accept := r.Header.Get("Accept")
+		switch {
+		case strings.Contains(accept, ContentTypeDIDLDJSON):
+			writeJSONAs(w, http.StatusOK, ContentTypeDIDLDJSON, doc)
+		case strings.Contains(accept, ContentTypeDIDJSON):
+			writeJSONAs(w, http.StatusOK, ContentTypeDIDJSON, doc)
+		default:
+			writeResolution(w, http.StatusOK, doc, "", start)
+		}
+	}
+}
+
+// pathDelims decodes the DID URL delimiters a client has to escape to send
+// them in a request path
+var pathDelims = strings.NewReplacer("%23", "#", "%3F", "?", "%3f", "?")
+
+// requestDIDURL returns the DID URL in an escaped request path after prefix.
+// Other percent-encoded octets are left as sent, since they are part of the
+// DID (a did:web port is %3A) and decoding them would change it.
+func requestDIDURL(escapedPath, prefix string) string {
+	return pathDelims.Replace(strings.TrimPrefix(escapedPath, prefix))
+}
+
+// resolutionError maps an error to an HTTP status and resolution error code
+func resolutionError(err error) (int, string) {
+	switch {
+	case errors.Is(err, ErrInvalidDIDURL), errors.Is(err, ErrLocalHost):
+		return http.StatusBadRequest, errInvalidDID
+	case errors.Is(err, ErrNotFound):
+		return http.StatusNotFound, errNotFound
+	case errors.Is(err, ErrUnsupportedMethod):
+		return http.StatusNotImplemented, errMethodNotSupported
+	default:
+		return http.StatusInternalServerError, errInternal
+	}
+}
+
+func writeResolution(w http.ResponseWriter, status int, doc *Document, code string, start time.Time) {
+	meta := map[string]interface{}{"duration": time.Since(start).Milliseconds()}
+	if code != "" {
+		meta["error"] = code
+	} else {
+		meta["contentType"] = ContentTypeDIDLDJSON
+	}
+	writeJSONAs(w, status, ContentTypeResolution, ResolutionResult{
+		Context:               resolutionContext,
+		DIDDocument:           doc,
+		DIDResolutionMetadata: meta,
+		DIDDocumentMetadata:   map[string]interface{}{},
+	})
+}
+
+func writeDereference(w http.ResponseWriter, status int, content interface{}, code string, start time.Time) {
+	meta := map[string]interface{}{"duration": time.Since(start).Milliseconds()}
+	if code != "" {
+		meta["error"] = code
+	} else {
+		meta["contentType"] = "application/json"
+	}
+	writeJSONAs(w, status, ContentTypeResolution, DereferencingResult{
+		Context:               resolutionContext,
+		ContentStream:         content,
+		DereferencingMetadata: meta,
+		ContentMetadata:       map[string]interface{}{},
+	})
+}
+
+// writeJSONAs is httpx.WriteJSON with a binding-specific media type
+func writeJSONAs(w http.ResponseWriter, status int, contentType string, payload interface{}) {
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(payload)
+}
@@ -0,0 +1,137 @@
+package did
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/example/privacy-gateway/internal/shared/cache"
+	"github.com/example/privacy-gateway/internal/shared/observability"
+)
+
+// CacheConfig configures resolution caching
+type CacheConfig struct {
+	TTL    time.Duration // Document lifetime (default 5m)
+	L1     *cache.RistrettoCache
+	L2     *cache.RedisCache // Optional, shared across replicas
+	OnHit  func()            // Metrics callback
+	OnMiss func()            // Metrics callback
+	Codec  cache.Codec       // L2 serialization (default msgpack)
+}
+
+// Cache wraps a Resolver with an in-memory and optional Redis cache of
+// resolved documents
+type Cache struct {
+	next Resolver
+	cfg  CacheConfig
+}
+
+// NewCache creates a caching resolver in front of next
+func NewCache(next Resolver, cfg CacheConfig) *Cache {
+	if cfg.TTL == 0 {
+		cfg.TTL = 5 * time.Minute
+	}
+	if cfg.Codec == nil {
+		cfg.Codec = cache.Msgpack
+	}
+	return &Cache{next: next, cfg: cfg}
+}
+
+// TTL returns how long documents are cached
+func (c *Cache) TTL() time.Duration {
+	return c.cfg.TTL
+}
+
+// Resolve returns a cached document or resolves and caches it
+func (c *Cache) Resolve(ctx context.Context, did string, opts ResolveOptions) (*Document, error) {
+	key := cacheKey(did, opts)
+
+	if c.cfg.L1 != nil {
+		if v, ok := c.cfg.L1.Get(key); ok {
+			if doc, ok := v.(*Document); ok {
+				c.hit()
+				observability.Annotate(ctx, "did_cache", "l1")
+				return doc, nil
+			}
+		}
+	}
+	if c.cfg.L2 != nil {
+		if data, err := c.cfg.L2.GetBytes(ctx, key); err == nil {
+			// Entries written with another codec (e.g. JSON before an
+			// upgrade) still decode
+			var doc Document
+			if cache.Decode(data, &doc) == nil {
+				c.setL1(key, &doc, int64(len(data)))
+				c.hit()
+				observability.Annotate(ctx, "did_cache", "l2")
+				return &doc, nil
+			}
+		}
+	}
+	if c.cfg.OnMiss != nil {
+		c.cfg.OnMiss()
+	}
+	observability.Annotate(ctx, "did_cache", "miss")
+
+	doc, err := c.next.Resolve(ctx, did, opts)
+	if err != nil {
+		return nil, err
+	}
+	data, err := cache.Encode(c.cfg.Codec, doc)
+	if err != nil {
+		return doc, nil
+	}
+	c.setL1(key, doc, int64(len(data)))
+	if c.cfg.L2 != nil {
+		// Best effort; a Redis outage only costs a re-resolve
+		_ = c.cfg.L2.SetBytes(ctx, key, data, c.cfg.TTL)
+	}
+	return doc, nil
+}
+
+// Invalidate drops a DID's cached current document
+func (c *Cache) Invalidate(ctx context.Context, did string) error {
+	key := cacheKey(did, ResolveOptions{})
+	if c.cfg.L1 != nil {
+		c.cfg.L1.Delete(key)
+	}
+	if c.cfg.L2 != nil {
+		return c.cfg.L2.Delete(ctx, key)
+	}
+	return nil
+}
+
+func (c *Cache) setL1(key string, doc *Document, cost int64) {
+	if c.cfg.L1 != nil {
+		c.cfg.L1.Set(key, doc, cost, c.cfg.TTL)
+	}
+}
+
+func (c *Cache) hit() {
+	if c.cfg.OnHit != nil {
+		c.cfg.OnHit()
+	}
+}
+
+func cacheKey(did string, opts ResolveOptions) string {
+	key := cache.DIDKeys.Key("doc", normalizeDID(did))
+	if opts.VersionID != "" {
+		key += "|v=" + opts.VersionID
+	}
+	if opts.VersionTime != "" {
+		key += "|t=" + opts.VersionTime
+	}
+	return key
+}
+
+// normalizeDID returns the spelling of did used in cache keys, so the
+// equivalent spellings of a did:web DID (Unicode or punycode domain, any
+// case) share one entry
+func normalizeDID(did string) string {
+	if strings.HasPrefix(did, "did:web:") {
+		if n, err := NormalizeWebDID(did); err == nil {
+			return n
+		}
+	}
+	return did
+}
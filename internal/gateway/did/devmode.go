@@ -0,0 +1,68 @@
+package did
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+)
+
+var (
+	ErrDevModeNotAllowed = errors.New("did:web dev mode requires a local gateway domain")
+	ErrLocalHost         = errors.New("did:web host is local; enable dev mode to resolve it")
+)
+
+// Production did:web resolution is HTTPS only and refuses local hosts: names
+// are checked here, and the transport refuses to dial loopback, unspecified,
+// private and link-local addresses, so a public name resolving to one is
+// refused too. Dev mode additionally resolves localhost, loopback addresses
+// and mDNS .local names over plain http, e.g. did:web:localhost%3A8888 from
+// the test server, and dials through DevTransport. It can only be enabled
+// when the gateway's own domain is local, so a production config (a public
+// GATEWAY_DOMAIN) can't turn it on.
+
+// checkDevMode returns an error unless gatewayDomain is a local host
+func checkDevMode(gatewayDomain string) error {
+	host := gatewayDomain
+	if h, _, err := net.SplitHostPort(gatewayDomain); err == nil {
+		host = h
+	}
+	if host == "" || !isLocalHost(host) {
+		return fmt.Errorf("%w: gateway domain is %q", ErrDevModeNotAllowed, gatewayDomain)
+	}
+	return nil
+}
+
+// isLocalHost reports whether host (without port) is localhost, a loopback
+// address or an mDNS name
+func isLocalHost(host string) bool {
+	h := strings.ToLower(strings.TrimSuffix(host, "."))
+	if h == "localhost" || strings.HasSuffix(h, ".localhost") || strings.HasSuffix(h, ".local") {
+		return true
+	}
+	ip := net.ParseIP(strings.Trim(h, "[]"))
+	return ip != nil && ip.IsLoopback()
+}
+
+// localAddr reports whether ip is loopback, unspecified, private or
+// link-local, addresses production resolution must not reach
+func localAddr(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsUnspecified() || ip.IsPrivate() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast()
+}
+
+// webOrigin returns the origin to fetch from for a did:web host (with
+// optional port): http for local hosts in dev mode, https otherwise
+func webOrigin(hostPort string, devMode bool) (string, error) {
+	host := hostPort
+	if h, _, err := net.SplitHostPort(hostPort); err == nil {
+		host = h
+	}
+	if !isLocalHost(host) {
+		return "https://" + hostPort, nil
+	}
+	if !devMode {
+		return "", fmt.Errorf("%w: %s", ErrLocalHost, host)
+	}
+	return "http://" + hostPort, nil
+}
@@ -0,0 +1,210 @@
+package did
+
+import (
+	"bytes"
+	"crypto/ecdh"
+	"crypto/ed25519"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/example/privacy-gateway/internal/shared/crypto"
+)
+
+// Document is a W3C DID document. Only the members the gateway uses are typed;
+// verification relationships may hold either references or embedded methods.
+type Document struct {
+	Context              interface{}          `json:"@context,omitempty"`
+	ID                   string               `json:"id"`
+	Controller           interface{}          `json:"controller,omitempty"`
+	AlsoKnownAs          []string             `json:"alsoKnownAs,omitempty"`
+	VerificationMethod   []VerificationMethod `json:"verificationMethod,omitempty"`
+	Authentication       []VerificationRef    `json:"authentication,omitempty"`
+	AssertionMethod      []VerificationRef    `json:"assertionMethod,omitempty"`
+	KeyAgreement         []VerificationRef    `json:"keyAgreement,omitempty"`
+	CapabilityInvocation []VerificationRef    `json:"capabilityInvocation,omitempty"`
+	CapabilityDelegation []VerificationRef    `json:"capabilityDelegation,omitempty"`
+	Service              []Service            `json:"service,omitempty"`
+}
+
+// VerificationMethod is a public key entry
+type VerificationMethod struct {
+	ID                 string                 `json:"id"`
+	Type               string                 `json:"type"`
+	Controller         string                 `json:"controller"`
+	PublicKeyJwk       map[string]interface{} `json:"publicKeyJwk,omitempty"`
+	PublicKeyMultibase string                 `json:"publicKeyMultibase,omitempty"`
+}
+
+// VerificationRef is either a reference to a verification method by ID or an
+// embedded verification method
+type VerificationRef struct {
+	ID       string
+	Embedded *VerificationMethod
+}
+
+// UnmarshalJSON accepts a string reference or an embedded method object
+func (v *VerificationRef) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] == '"' {
+		return json.Unmarshal(data, &v.ID)
+	}
+	var vm VerificationMethod
+	if err := json.Unmarshal(data, &vm); err != nil {
+		return err
+	}
+	v.ID = vm.ID
+	v.Embedded = &vm
+	return nil
+}
+
+// MarshalJSON writes the reference back in its original form
+func (v VerificationRef) MarshalJSON() ([]byte, error) {
+	if v.Embedded != nil {
+		return json.Marshal(v.Embedded)
+	}
+	return json.Marshal(v.ID)
+}
+
+// Service is a service endpoint entry. Type and ServiceEndpoint may each be a
+// string, a list or (for the endpoint) a map, so they are kept untyped.
+type Service struct {
+	ID              string          `json:"id"`
+	Type            json.RawMessage `json:"type"`
+	ServiceEndpoint json.RawMessage `json:"serviceEndpoint"`
+}
+
+// Types returns the service type(s) as a list
+func (s Service) Types() []string {
+	var one string
+	if err := json.Unmarshal(s.Type, &one); err == nil {
+		return []string{one}
+	}
+	var many []string
+	_ = json.Unmarshal(s.Type, &many)
+	return many
+}
+
+// EndpointURL returns the endpoint when it is a single URL string
+func (s Service) EndpointURL() (string, bool) {
+	var u string
+	if err := json.Unmarshal(bytes.TrimSpace(s.ServiceEndpoint), &u); err != nil {
+		return "", false
+	}
+	return u, true
+}
+
+// findMethod looks up a verification method by absolute or relative ID,
+// including methods embedded in verification relationships
+func (d *Document) findMethod(id string) *VerificationMethod {
+	for i := range d.VerificationMethod {
+		if d.matchesID(d.VerificationMethod[i].ID, id) {
+			return &d.VerificationMethod[i]
+		}
+	}
+	for _, rel := range [][]VerificationRef{
+		d.Authentication, d.AssertionMethod, d.KeyAgreement,
+		d.CapabilityInvocation, d.CapabilityDelegation,
+	} {
+		for _, ref := range rel {
+			if ref.Embedded != nil && d.matchesID(ref.ID, id) {
+				return ref.Embedded
+			}
+		}
+	}
+	return nil
+}
+
+// AssertionKey returns the Ed25519 key of the verification method id, which
+// must be listed under assertionMethod (the relationship for issuing credentials)
+func (d *Document) AssertionKey(id string) (ed25519.PublicKey, error) {
+	return d.relationshipKey(d.AssertionMethod, "assertion method", id)
+}
+
+// AuthenticationKey returns the Ed25519 key of the verification method id,
+// which must be listed under authentication (e.g. for holder proofs)
+func (d *Document) AuthenticationKey(id string) (ed25519.PublicKey, error) {
+	return d.relationshipKey(d.Authentication, "authentication method", id)
+}
+
+func (d *Document) relationshipKey(rel []VerificationRef, name, id string) (ed25519.PublicKey, error) {
+	listed := false
+	for _, ref := range rel {
+		if d.matchesID(ref.ID, id) {
+			listed = true
+			break
+		}
+	}
+	if !listed {
+		return nil, fmt.Errorf("%w: %s is not an %s", ErrNotFound, id, name)
+	}
+	vm := d.findMethod(id)
+	if vm == nil {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+	return ed25519Key(vm)
+}
+
+// KeyAgreementKey returns the X25519 key of the key agreement method id and
+// its absolute ID. With an empty id it picks the first X25519 method listed
+// under keyAgreement.
+func (d *Document) KeyAgreementKey(id string) (*ecdh.PublicKey, string, error) {
+	for _, ref := range d.KeyAgreement {
+		if id != "" && !d.matchesID(ref.ID, id) {
+			continue
+		}
+		vm := ref.Embedded
+		if vm == nil {
+			vm = d.findMethod(ref.ID)
+		}
+		if vm == nil {
+			return nil, "", fmt.Errorf("%w: %s", ErrNotFound, ref.ID)
+		}
+		pub, err := x25519Key(vm)
+		if err != nil && id == "" {
+			continue
+		}
+		return pub, d.absolute(ref.ID), err
+	}
+	if id == "" {
+		return nil, "", fmt.Errorf("%w: no X25519 key agreement method", ErrNotFound)
+	}
+	return nil, "", fmt.Errorf("%w: %s is not a key agreement method", ErrNotFound, id)
+}
+
+func x25519Key(vm *VerificationMethod) (*ecdh.PublicKey, error) {
+	if vm.PublicKeyMultibase != "" {
+		return crypto.DecodeX25519Multibase(vm.PublicKeyMultibase)
+	}
+	if vm.PublicKeyJwk["kty"] == "OKP" && vm.PublicKeyJwk["crv"] == "X25519" {
+		x, _ := vm.PublicKeyJwk["x"].(string)
+		pub, err := crypto.DecodeX25519PublicKey(x)
+		if err != nil {
+			return nil, errors.New("invalid X25519 JWK")
+		}
+		return pub, nil
+	}
+	return nil, fmt.Errorf("unsupported key type %s", vm.Type)
+}
+
+// findService looks up a service by absolute or relative ID
+func (d *Document) findService(id string) *Service {
+	for i := range d.Service {
+		if d.matchesID(d.Service[i].ID, id) {
+			return &d.Service[i]
+		}
+	}
+	return nil
+}
+
+// matchesID compares an entry ID with a wanted ID. Either side may be relative
+// ("#key-1") or absolute ("did:web:example.com#key-1").
+func (d *Document) matchesID(entry, want string) bool {
+	return d.absolute(entry) == d.absolute(want)
+}
+
+func (d *Document) absolute(id string) string {
+	if len(id) > 0 && id[0] == '#' {
+		return d.ID + id
+	}
+	return id
+}
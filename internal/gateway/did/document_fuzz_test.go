@@ -0,0 +1,53 @@
+package did_test
+
+import (
+	"testing"
+
+	"github.com/example/privacy-gateway/internal/gateway/did"
+)
+
+func FuzzParseDocument(f *testing.F) {
+	f.Add([]byte(`{"id":"did:web:example.com","verificationMethod":[{"id":"#key-1","type":"JsonWebKey2020","controller":"did:web:example.com","publicKeyJwk":{"kty":"OKP","crv":"Ed25519","x":"11qYAYKxCrfVS_7TyWQHOg7hcvPapiMlrwIaaPcHURo"}}],"authentication":["#key-1"],"assertionMethod":[{"id":"#key-2","type":"Ed25519VerificationKey2020","controller":"did:web:example.com","publicKeyMultibase":"z6MkhaXgBZDvotDkL5257faiztiGiC2QtKLGpbnnEGta2doK"}],"service":[{"id":"#svc","type":["LinkedDomains"],"serviceEndpoint":"https://example.com"}]}`))
+	f.Add([]byte(`{"id":"a","id":"b"}`))
+	f.Add([]byte(`[[[[[[[[[[[[[[[[[[[[[[[[[[[[[[[[[[[]]]]]]]]]]]]]]]]]]]]]]]]]]]]]]]]]]`))
+	f.Add([]byte(`{"authentication":[1,{"id":2}],"service":[{"type":{}}]}`))
+	f.Add([]byte(`{} {}`))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		doc, err := did.ParseDocument(data, did.DocumentLimits{})
+		if err != nil {
+			return
+		}
+		for _, rel := range [][]did.VerificationRef{doc.Authentication, doc.AssertionMethod} {
+			for _, ref := range rel {
+				_, _ = doc.AuthenticationKey(ref.ID)
+				_, _ = doc.AssertionKey(ref.ID)
+			}
+		}
+		for _, vm := range doc.VerificationMethod {
+			_, _ = doc.AuthenticationKey(vm.ID)
+		}
+		for _, svc := range doc.Service {
+			_ = svc.Types()
+			_, _ = svc.EndpointURL()
+		}
+	})
+}
+
+func FuzzParseURL(f *testing.F) {
+	f.Add("did:web:example.com")
+	f.Add("did:web:example.com/path?service=files&relativeRef=%2Fa#key-1")
+	f.Add("did:key:z6Mk#")
+	f.Add("did:web:example.com?%zz")
+
+	f.Fuzz(func(t *testing.T, s string) {
+		u, err := did.ParseURL(s)
+		if err != nil {
+			return
+		}
+		_ = u.ResolveOptions()
+		if _, err := did.ParseURL(u.String()); err != nil {
+			t.Fatalf("%q re-rendered as %q, which does not parse: %v", s, u.String(), err)
+		}
+	})
+}
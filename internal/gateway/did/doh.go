@@ -0,0 +1,202 @@
+package did
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+var (
+	ErrInvalidDoHServer = errors.New("invalid DNS-over-HTTPS server")
+	ErrDNSSECRequired   = errors.New("DNS answer not DNSSEC-validated")
+)
+
+// DoHConfig configures DNS-over-HTTPS lookups for did:web hosts
+type DoHConfig struct {
+	URL           string // RFC 8484 endpoint, e.g. https://1.1.1.1/dns-query
+	Client        *http.Client
+	Timeout       time.Duration // Per query (default 3s)
+	RequireDNSSEC bool          // Reject answers without the AD (authenticated data) flag
+}
+
+// DoHResolver resolves hostnames through a DNS-over-HTTPS server so did:web
+// lookups can't be spoofed by the local network. With RequireDNSSEC the
+// server must report the answer as DNSSEC-validated; the gateway trusts the
+// server's validation, so it should be one you control or trust.
+type DoHResolver struct {
+	url     string
+	client  *http.Client
+	timeout time.Duration
+	dnssec  bool
+}
+
+// NewDoHResolver creates a DoH resolver. The server URL must be https.
+func NewDoHResolver(cfg DoHConfig) (*DoHResolver, error) {
+	u, err := url.Parse(cfg.URL)
+	if err != nil || u.Scheme != "https" || u.Host == "" {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidDoHServer, cfg.URL)
+	}
+	if cfg.Timeout == 0 {
+		cfg.Timeout = 3 * time.Second
+	}
+	if cfg.Client == nil {
+		cfg.Client = &http.Client{Timeout: cfg.Timeout}
+	}
+	return &DoHResolver{url: cfg.URL, client: cfg.Client, timeout: cfg.Timeout, dnssec: cfg.RequireDNSSEC}, nil
+}
+
+// LookupHost returns the IPv4 and IPv6 addresses of host. It matches
+// TransportConfig.LookupHost.
+func (r *DoHResolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	type answer struct {
+		addrs []string
+		err   error
+	}
+	results := make(chan answer, 2)
+	for _, qtype := range []dnsmessage.Type{dnsmessage.TypeA, dnsmessage.TypeAAAA} {
+		go func(qtype dnsmessage.Type) {
+			addrs, err := r.query(ctx, host, qtype)
+			results <- answer{addrs, err}
+		}(qtype)
+	}
+
+	var (
+		addrs    []string
+		firstErr error
+	)
+	for i := 0; i < 2; i++ {
+		a := <-results
+		if a.err != nil {
+			if firstErr == nil || errors.Is(a.err, ErrDNSSECRequired) {
+				firstErr = a.err
+			}
+			continue
+		}
+		addrs = append(addrs, a.addrs...)
+	}
+	// An unvalidated answer for either family fails the lookup, so an
+	// attacker can't steer connections through the unsigned one
+	if errors.Is(firstErr, ErrDNSSECRequired) {
+		return nil, firstErr
+	}
+	if len(addrs) == 0 {
+		if firstErr != nil {
+			return nil, firstErr
+		}
+		return nil, &net.DNSError{Err: "no addresses", Name: host, IsNotFound: true}
+	}
+	return addrs, nil
+}
+
+// query sends one RFC 8484 POST query
+func (r *DoHResolver) query(ctx context.Context, host string, qtype dnsmessage.Type) ([]string, error) {
+	name, err := dnsmessage.NewName(strings.TrimSuffix(host, ".") + ".")
+	if err != nil {
+		return nil, &net.DNSError{Err: err.Error(), Name: host}
+	}
+
+	b := dnsmessage.NewBuilder(nil, dnsmessage.Header{RecursionDesired: true})
+	b.EnableCompression()
+	if err := b.StartQuestions(); err != nil {
+		return nil, err
+	}
+	if err := b.Question(dnsmessage.Question{Name: name, Type: qtype, Class: dnsmessage.ClassINET}); err != nil {
+		return nil, err
+	}
+	if err := b.StartAdditionals(); err != nil {
+		return nil, err
+	}
+	// EDNS0 with the DO bit asks the server to perform DNSSEC validation
+	var opt dnsmessage.ResourceHeader
+	if err := opt.SetEDNS0(4096, dnsmessage.RCodeSuccess, true); err != nil {
+		return nil, err
+	}
+	if err := b.OPTResource(opt, dnsmessage.OPTResource{}); err != nil {
+		return nil, err
+	}
+	msg, err := b.Finish()
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.url, bytes.NewReader(msg))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/dns-message")
+	req.Header.Set("Accept", "application/dns-message")
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, &net.DNSError{Err: err.Error(), Name: host, IsTemporary: true}
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, &net.DNSError{Err: fmt.Sprintf("DoH server returned %d", resp.StatusCode), Name: host, IsTemporary: true}
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if err != nil {
+		return nil, &net.DNSError{Err: err.Error(), Name: host, IsTemporary: true}
+	}
+
+	var p dnsmessage.Parser
+	h, err := p.Start(body)
+	if err != nil {
+		return nil, &net.DNSError{Err: "malformed DoH response", Name: host}
+	}
+	switch h.RCode {
+	case dnsmessage.RCodeSuccess:
+	case dnsmessage.RCodeNameError:
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	default:
+		return nil, &net.DNSError{Err: h.RCode.String(), Name: host, IsTemporary: h.RCode == dnsmessage.RCodeServerFailure}
+	}
+	if r.dnssec && !h.AuthenticData {
+		return nil, fmt.Errorf("%w: %s", ErrDNSSECRequired, host)
+	}
+	if err := p.SkipAllQuestions(); err != nil {
+		return nil, &net.DNSError{Err: "malformed DoH response", Name: host}
+	}
+
+	var addrs []string
+	for {
+		rh, err := p.AnswerHeader()
+		if err == dnsmessage.ErrSectionDone {
+			break
+		}
+		if err != nil {
+			return nil, &net.DNSError{Err: "malformed DoH response", Name: host}
+		}
+		switch rh.Type {
+		case dnsmessage.TypeA:
+			a, err := p.AResource()
+			if err != nil {
+				return nil, &net.DNSError{Err: "malformed DoH response", Name: host}
+			}
+			addrs = append(addrs, net.IP(a.A[:]).String())
+		case dnsmessage.TypeAAAA:
+			a, err := p.AAAAResource()
+			if err != nil {
+				return nil, &net.DNSError{Err: "malformed DoH response", Name: host}
+			}
+			addrs = append(addrs, net.IP(a.AAAA[:]).String())
+		default:
+			// CNAMEs and RRSIGs; the recursive server has already followed the chain
+			if err := p.SkipAnswer(); err != nil {
+				return nil, &net.DNSError{Err: "malformed DoH response", Name: host}
+			}
+		}
+	}
+	return addrs, nil
+}
@@ -0,0 +1,83 @@
+package did
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/example/privacy-gateway/internal/shared/httpx"
+)
+
+// servicesResponse is returned by the service discovery endpoint
+type servicesResponse struct {
+	DID      string    `json:"did"`
+	Services []Service `json:"services"`
+}
+
+// ServicesHandler serves GET /v1/did/{did}/services, listing the service
+// endpoints of the resolved DID document. ?type= filters by service type
+// (e.g. LinkedDomains, DIDCommMessaging). maxAge sets Cache-Control and
+// should match the resolver cache TTL.
+func ServicesHandler(res Resolver, maxAge time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			httpx.WriteJSON(w, http.StatusMethodNotAllowed, httpx.ErrorResponse{Error: "method not allowed"})
+			return
+		}
+		// The escaped path keeps percent-encoded octets of the DID (a did:web
+		// port is %3A) as the client sent them
+		rest := strings.TrimPrefix(r.URL.EscapedPath(), "/v1/did/")
+		did, ok := strings.CutSuffix(rest, "/services")
+		if !ok || did == "" {
+			httpx.WriteJSON(w, http.StatusNotFound, httpx.ErrorResponse{Error: "not found"})
+			return
+		}
+		u, err := ParseURL(did)
+		if err != nil || u.Path != "" || u.Fragment != "" || len(u.Query) > 0 {
+			httpx.WriteJSON(w, http.StatusBadRequest, httpx.ErrorResponse{Error: "invalid DID"})
+			return
+		}
+
+		doc, err := res.Resolve(r.Context(), u.DID, ResolveOptions{})
+		if err != nil {
+			writeResolveError(w, err)
+			return
+		}
+
+		want := r.URL.Query().Get("type")
+		services := make([]Service, 0, len(doc.Service))
+		for _, s := range doc.Service {
+			if want == "" || hasType(s, want) {
+				services = append(services, s)
+			}
+		}
+
+		w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(maxAge.Seconds())))
+		httpx.WriteJSON(w, http.StatusOK, servicesResponse{DID: doc.ID, Services: services})
+	}
+}
+
+func hasType(s Service, want string) bool {
+	for _, t := range s.Types() {
+		if t == want {
+			return true
+		}
+	}
+	return false
+}
+
+// writeResolveError maps resolution errors to HTTP responses
+func writeResolveError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrUnsupportedMethod):
+		httpx.WriteJSON(w, http.StatusNotImplemented, httpx.ErrorResponse{Error: "unsupported DID method"})
+	case errors.Is(err, ErrNotFound):
+		httpx.WriteJSON(w, http.StatusNotFound, httpx.ErrorResponse{Error: "DID not found"})
+	case errors.Is(err, ErrLocalHost):
+		httpx.WriteJSON(w, http.StatusBadRequest, httpx.ErrorResponse{Error: "local did:web hosts are not resolved"})
+	default:
+		httpx.WriteJSON(w, http.StatusBadGateway, httpx.ErrorResponse{Error: "DID resolution failed"})
+	}
+}
@@ -0,0 +1,36 @@
+package did
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/example/privacy-gateway/internal/shared/crypto"
+)
+
+// KeyResolver expands did:key DIDs into documents locally; no network is involved
+type KeyResolver struct{}
+
+// Resolve builds the document for an Ed25519 did:key
+func (KeyResolver) Resolve(_ context.Context, did string, _ ResolveOptions) (*Document, error) {
+	if _, err := crypto.DecodeDidKey(did); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidDIDURL, err)
+	}
+	multibase := strings.TrimPrefix(did, "did:key:")
+	vmID := did + "#" + multibase
+	ref := []VerificationRef{{ID: vmID}}
+	return &Document{
+		Context: []string{"https://www.w3.org/ns/did/v1", "https://w3id.org/security/suites/ed25519-2020/v1"},
+		ID:      did,
+		VerificationMethod: []VerificationMethod{{
+			ID:                 vmID,
+			Type:               "Ed25519VerificationKey2020",
+			Controller:         did,
+			PublicKeyMultibase: multibase,
+		}},
+		Authentication:       ref,
+		AssertionMethod:      ref,
+		CapabilityInvocation: ref,
+		CapabilityDelegation: ref,
+	}, nil
+}
@@ -0,0 +1,81 @@
+package did
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/example/privacy-gateway/internal/shared/httpx"
+)
+
+var (
+	ErrDocumentLimit     = errors.New("DID document exceeds limits")
+	ErrMalformedDocument = errors.New("malformed DID document")
+)
+
+// DocumentLimits bounds the shape of a fetched DID document so a hostile
+// host can't exhaust memory or hide a second value behind a duplicate key
+type DocumentLimits struct {
+	MaxMethods  int // Verification methods, including embedded ones (default 64)
+	MaxServices int // Default 64
+	MaxDepth    int // JSON nesting depth (default 32)
+}
+
+func (l DocumentLimits) withDefaults() DocumentLimits {
+	if l.MaxMethods == 0 {
+		l.MaxMethods = 64
+	}
+	if l.MaxServices == 0 {
+		l.MaxServices = 64
+	}
+	if l.MaxDepth == 0 {
+		l.MaxDepth = 32
+	}
+	return l
+}
+
+// ParseDocument strictly decodes a DID document. Duplicate object keys (at
+// any depth), nesting deeper than MaxDepth and more methods or services than
+// allowed are rejected. Size is bounded by the caller before reading.
+func ParseDocument(data []byte, limits DocumentLimits) (*Document, error) {
+	limits = limits.withDefaults()
+	if err := checkStrictJSON(data, limits.MaxDepth); err != nil {
+		return nil, err
+	}
+
+	var doc Document
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMalformedDocument, err)
+	}
+
+	methods := len(doc.VerificationMethod)
+	for _, rel := range [][]VerificationRef{doc.Authentication, doc.AssertionMethod, doc.KeyAgreement, doc.CapabilityInvocation, doc.CapabilityDelegation} {
+		for _, ref := range rel {
+			if ref.Embedded != nil {
+				methods++
+			}
+		}
+	}
+	if methods > limits.MaxMethods {
+		return nil, fmt.Errorf("%w: %d verification methods (max %d)", ErrDocumentLimit, methods, limits.MaxMethods)
+	}
+	if len(doc.Service) > limits.MaxServices {
+		return nil, fmt.Errorf("%w: %d services (max %d)", ErrDocumentLimit, len(doc.Service), limits.MaxServices)
+	}
+	return &doc, nil
+}
+
+// checkStrictJSON rejects duplicate keys, excessive nesting and trailing
+// data (see httpx.CheckStrictJSON), so a document can't show different keys
+// to different parsers
+func checkStrictJSON(data []byte, maxDepth int) error {
+	err := httpx.CheckStrictJSON(data, maxDepth)
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, httpx.ErrJSONTooDeep):
+		return fmt.Errorf("%w: %v", ErrDocumentLimit, err)
+	default:
+		return fmt.Errorf("%w: %v", ErrMalformedDocument, err)
+	}
+}
@@ -0,0 +1,277 @@
+package did
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/example/privacy-gateway/internal/shared/cache"
+	"github.com/example/privacy-gateway/internal/shared/clock"
+	"github.com/example/privacy-gateway/internal/shared/crypto"
+	"github.com/example/privacy-gateway/internal/shared/jws"
+)
+
+var ErrNotLinked = errors.New("DID is not linked to its domain")
+
+// LinkageConfig configures domain linkage verification
+type LinkageConfig struct {
+	Client  *http.Client  // Default: DefaultTransport().Client(), DevTransport in dev mode
+	Timeout time.Duration // Per fetch (default 5s)
+	TTL     time.Duration // Result cache lifetime (default 1h)
+	// FailureTTL is how long a transient failure (a network error, a 5xx or
+	// a failed resolution) is cached (default 1m)
+	FailureTTL time.Duration
+	MaxEntries int         // Cached results (default 10000)
+	Clock      clock.Clock // Cache expiry and credential validity (default clock.Real)
+
+	// DevMode fetches from local hosts over http, as in WebConfig
+	DevMode       bool
+	GatewayDomain string
+}
+
+// LinkageVerifier checks the DIF Well Known DID Configuration of did:web DIDs:
+// the domain must serve /.well-known/did-configuration.json containing a
+// DomainLinkageCredential issued by the DID for that origin.
+type LinkageVerifier struct {
+	res    Resolver
+	client *http.Client
+	cfg    LinkageConfig
+
+	results *cache.LRU[error] // nil for a linked DID
+}
+
+// NewLinkageVerifier creates a verifier that resolves DIDs with res
+func NewLinkageVerifier(res Resolver, cfg LinkageConfig) (*LinkageVerifier, error) {
+	if cfg.DevMode {
+		if err := checkDevMode(cfg.GatewayDomain); err != nil {
+			return nil, err
+		}
+	}
+	switch {
+	case cfg.Client != nil:
+	case cfg.DevMode:
+		cfg.Client = DevTransport().Client()
+	default:
+		cfg.Client = DefaultTransport().Client()
+	}
+	if cfg.Timeout == 0 {
+		cfg.Timeout = 5 * time.Second
+	}
+	if cfg.TTL == 0 {
+		cfg.TTL = time.Hour
+	}
+	if cfg.FailureTTL == 0 {
+		cfg.FailureTTL = time.Minute
+	}
+	if cfg.MaxEntries == 0 {
+		cfg.MaxEntries = 10000
+	}
+	cfg.Clock = clock.Or(cfg.Clock)
+	return &LinkageVerifier{
+		res:     res,
+		client:  cfg.Client,
+		cfg:     cfg,
+		results: cache.NewLRU[error](cfg.MaxEntries, cfg.Clock),
+	}, nil
+}
+
+// VerifyLinkage returns nil when did is linked to its domain. Successes and
+// definitive failures (the domain serves no valid credential for the DID)
+// are cached for TTL; transient failures only for FailureTTL, so an outage
+// doesn't keep a linked issuer out for the full TTL.
+func (v *LinkageVerifier) VerifyLinkage(ctx context.Context, did string) error {
+	if err, ok := v.results.Get(did); ok {
+		return err
+	}
+
+	transient, err := v.verify(ctx, did)
+	if ctx.Err() != nil {
+		// Don't cache a failure caused by the caller going away
+		return err
+	}
+	ttl := v.cfg.TTL
+	if transient {
+		ttl = v.cfg.FailureTTL
+	}
+	v.results.Set(did, err, ttl)
+	return err
+}
+
+// verify checks the linkage of did. transient reports a failure that says
+// nothing about the domain's configuration.
+func (v *LinkageVerifier) verify(ctx context.Context, did string) (transient bool, err error) {
+	host, _, err := WebHost(did)
+	if err != nil {
+		return false, fmt.Errorf("%w: only did:web DIDs can be domain-linked", ErrNotLinked)
+	}
+	origin, err := webOrigin(host, v.cfg.DevMode)
+	if err != nil {
+		return false, fmt.Errorf("%w: %v", ErrNotLinked, err)
+	}
+
+	linked, transient, err := v.fetchConfiguration(ctx, origin)
+	if err != nil {
+		return transient, fmt.Errorf("%w: %v", ErrNotLinked, err)
+	}
+	doc, err := v.res.Resolve(ctx, did, ResolveOptions{})
+	if err != nil {
+		return true, err
+	}
+	normalized, _ := NormalizeWebDID(did)
+	for _, jwt := range linked {
+		if verifyLinkageJWT(jwt, doc, normalized, origin, v.cfg.Clock.Now()) == nil {
+			return false, nil
+		}
+	}
+	return false, fmt.Errorf("%w: no valid DomainLinkageCredential for %s", ErrNotLinked, origin)
+}
+
+// fetchConfiguration returns the JWT entries of linked_dids. transient
+// reports a network error, 429 or 5xx rather than a missing or invalid file.
+func (v *LinkageVerifier) fetchConfiguration(ctx context.Context, origin string) ([]string, bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, v.cfg.Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, origin+"/.well-known/did-configuration.json", nil)
+	if err != nil {
+		return nil, false, err
+	}
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return nil, true, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		transient := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+		return nil, transient, fmt.Errorf("did-configuration.json returned %d", resp.StatusCode)
+	}
+
+	var cfg struct {
+		LinkedDIDs []json.RawMessage `json:"linked_dids"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 256<<10)).Decode(&cfg); err != nil {
+		if ctx.Err() != nil {
+			return nil, true, ctx.Err()
+		}
+		return nil, false, fmt.Errorf("invalid did-configuration.json: %w", err)
+	}
+	// JSON-LD proof entries are objects; only the JWT form is supported
+	var jwts []string
+	for _, raw := range cfg.LinkedDIDs {
+		var s string
+		if json.Unmarshal(raw, &s) == nil {
+			jwts = append(jwts, s)
+		}
+	}
+	return jwts, false, nil
+}
+
+// linkageClaims is the JWT form of a DomainLinkageCredential
+type linkageClaims struct {
+	Iss string `json:"iss"`
+	Sub string `json:"sub"`
+	Nbf int64  `json:"nbf"`
+	Exp int64  `json:"exp"`
+	VC  struct {
+		Type              []string `json:"type"`
+		CredentialSubject struct {
+			ID     string `json:"id"`
+			Origin string `json:"origin"`
+		} `json:"credentialSubject"`
+	} `json:"vc"`
+}
+
+// verifyLinkageJWT checks an EdDSA JWT DomainLinkageCredential: the signing
+// key belongs to the DID, iss/sub/subject id name the DID, origin matches
+// and it is valid at now
+func verifyLinkageJWT(token string, doc *Document, did, origin string, at time.Time) error {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return errors.New("malformed JWT")
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := jws.DecodeSegment(parts[0], &header); err != nil {
+		return err
+	}
+	if header.Alg != "EdDSA" {
+		return fmt.Errorf("unsupported alg %s", header.Alg)
+	}
+	var claims linkageClaims
+	if err := jws.DecodeSegment(parts[1], &claims); err != nil {
+		return err
+	}
+
+	same := func(id string) bool {
+		n, err := NormalizeWebDID(id)
+		return err == nil && n == did
+	}
+	if !same(claims.Iss) || !same(claims.Sub) || !same(claims.VC.CredentialSubject.ID) {
+		return errors.New("credential does not name the DID")
+	}
+	if !strings.EqualFold(strings.TrimSuffix(claims.VC.CredentialSubject.Origin, "/"), origin) {
+		return errors.New("origin mismatch")
+	}
+	if !contains(claims.VC.Type, "DomainLinkageCredential") {
+		return errors.New("not a DomainLinkageCredential")
+	}
+	now := at.Unix()
+	if (claims.Exp != 0 && now >= claims.Exp) || (claims.Nbf != 0 && now < claims.Nbf) {
+		return errors.New("credential not valid now")
+	}
+
+	kid, _, _ := strings.Cut(header.Kid, "#")
+	if kid != "" && !same(kid) {
+		return errors.New("kid is not a key of the DID")
+	}
+	_, fragment, _ := strings.Cut(header.Kid, "#")
+	vm := doc.findMethod("#" + fragment)
+	if vm == nil {
+		return errors.New("signing key not found in DID document")
+	}
+	pub, err := ed25519Key(vm)
+	if err != nil {
+		return err
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return err
+	}
+	if !ed25519.Verify(pub, []byte(parts[0]+"."+parts[1]), sig) {
+		return errors.New("invalid signature")
+	}
+	return nil
+}
+
+// ed25519Key extracts an Ed25519 public key from a JWK or multibase method
+func ed25519Key(vm *VerificationMethod) (ed25519.PublicKey, error) {
+	if vm.PublicKeyMultibase != "" {
+		return crypto.DecodeDidKey("did:key:" + vm.PublicKeyMultibase)
+	}
+	if vm.PublicKeyJwk["kty"] == "OKP" && vm.PublicKeyJwk["crv"] == "Ed25519" {
+		x, _ := vm.PublicKeyJwk["x"].(string)
+		raw, err := base64.RawURLEncoding.DecodeString(x)
+		if err != nil || len(raw) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid Ed25519 JWK")
+		}
+		return ed25519.PublicKey(raw), nil
+	}
+	return nil, fmt.Errorf("unsupported key type %s", vm.Type)
+}
+
+func contains(list []string, v string) bool {
+	for _, s := range list {
+		if s == v {
+			return true
+		}
+	}
+	return false
+}
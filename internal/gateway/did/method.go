@@ -0,0 +1,225 @@
+package did
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/example/privacy-gateway/internal/shared/circuitbreaker"
+	"github.com/example/privacy-gateway/internal/shared/observability"
+	"github.com/example/privacy-gateway/internal/shared/resilience"
+	"github.com/example/privacy-gateway/internal/shared/retry"
+	"github.com/example/privacy-gateway/internal/shared/validate"
+)
+
+// maxBreakers bounds the per-host breakers of one method; did:web hosts are
+// chosen by callers, so closed breakers are dropped once the map is full
+const maxBreakers = 4096
+
+// MethodConfig tunes resolution for one DID method. did:web needs
+// seconds-level timeouts, retries and a breaker per host, while did:key is
+// computed locally and needs none of them.
+type MethodConfig struct {
+	Timeout  time.Duration          // Per-attempt deadline (0 = none)
+	CacheTTL time.Duration          // Document cache lifetime (0 = uncached)
+	Breaker  *circuitbreaker.Config // Per-host breaker thresholds (nil = no breaker)
+	Retry    retry.Config           // MaxAttempts <= 1 disables retries
+}
+
+// DefaultMethodConfigs returns the built-in per-method settings: did:web
+// uses the balanced resilience profile
+func DefaultMethodConfigs() map[string]MethodConfig {
+	web, _ := resilience.Lookup(resilience.Balanced)
+	return map[string]MethodConfig{
+		"key": {},
+		"web": MethodConfig{CacheTTL: 5 * time.Minute}.WithProfile(web),
+	}
+}
+
+// WithProfile returns c with the timeout, retry and breaker settings of p
+func (c MethodConfig) WithProfile(p resilience.Profile) MethodConfig {
+	breaker := p.Breaker
+	c.Timeout, c.Retry, c.Breaker = p.Timeout, p.Retry, &breaker
+	return c
+}
+
+// ProfileMethodConfigs returns DefaultMethodConfigs with a resilience
+// profile applied per method, e.g. {"web": "conservative"}. Methods without
+// defaults get the profile and no cache.
+func ProfileMethodConfigs(profiles map[string]string) (map[string]MethodConfig, error) {
+	cfgs := DefaultMethodConfigs()
+	for method, name := range profiles {
+		p, err := resilience.Lookup(name)
+		if err != nil {
+			return nil, fmt.Errorf("did:%s: %w", method, err)
+		}
+		cfgs[method] = cfgs[method].WithProfile(p)
+	}
+	return cfgs, nil
+}
+
+// MethodResolver applies a MethodConfig around a method's resolver: cache,
+// then a breaker keyed by host (did:web) or method, then retries, each
+// attempt bounded by Timeout. Permanent failures (not found, invalid DID or
+// document) are neither retried nor counted against the breaker.
+type MethodResolver struct {
+	next    Resolver
+	cfg     MethodConfig
+	breaker circuitbreaker.Config
+	cached  *Cache
+
+	mu       sync.Mutex
+	breakers map[string]*circuitbreaker.CircuitBreaker
+}
+
+// NewMethodResolver wraps res with cfg. cache supplies the shared cache
+// layers and metrics callbacks; its TTL is replaced by cfg.CacheTTL.
+func NewMethodResolver(res Resolver, cfg MethodConfig, cache CacheConfig) *MethodResolver {
+	m := &MethodResolver{next: res, cfg: cfg, breakers: make(map[string]*circuitbreaker.CircuitBreaker)}
+	if cfg.Breaker != nil {
+		m.breaker = *cfg.Breaker
+		if m.breaker.Timeout == 0 {
+			// The breaker's own deadline covers every attempt and backoff
+			attempts := time.Duration(max(1, cfg.Retry.MaxAttempts))
+			m.breaker.Timeout = attempts*cfg.Timeout + (attempts-1)*cfg.Retry.MaxDelay
+		}
+	}
+	if cfg.CacheTTL > 0 && (cache.L1 != nil || cache.L2 != nil) {
+		cache.TTL = cfg.CacheTTL
+		m.cached = NewCache(resolverFunc(m.resolve), cache)
+	}
+	return m
+}
+
+// resolverFunc adapts a function to Resolver
+type resolverFunc func(ctx context.Context, did string, opts ResolveOptions) (*Document, error)
+
+func (f resolverFunc) Resolve(ctx context.Context, did string, opts ResolveOptions) (*Document, error) {
+	return f(ctx, did, opts)
+}
+
+// Resolve resolves did through the configured cache, breaker and retries
+func (m *MethodResolver) Resolve(ctx context.Context, did string, opts ResolveOptions) (*Document, error) {
+	if m.cached != nil {
+		return m.cached.Resolve(ctx, did, opts)
+	}
+	return m.resolve(ctx, did, opts)
+}
+
+// Invalidate drops a DID's cached document, if caching is enabled
+func (m *MethodResolver) Invalidate(ctx context.Context, did string) error {
+	if m.cached == nil {
+		return nil
+	}
+	return m.cached.Invalidate(ctx, did)
+}
+
+// BreakerStats returns the state of each breaker, keyed by host or method
+func (m *MethodResolver) BreakerStats() map[string]circuitbreaker.Stats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := make(map[string]circuitbreaker.Stats, len(m.breakers))
+	for k, b := range m.breakers {
+		out[k] = b.Stats()
+	}
+	return out
+}
+
+func (m *MethodResolver) resolve(ctx context.Context, did string, opts ResolveOptions) (*Document, error) {
+	var (
+		doc       *Document
+		permanent error
+	)
+	attempt := func(ctx context.Context) error {
+		if m.cfg.Timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, m.cfg.Timeout)
+			defer cancel()
+		}
+		d, err := m.next.Resolve(ctx, did, opts)
+		if err != nil && isPermanent(err) {
+			// Report success to the breaker and stop retrying; the error is
+			// returned to the caller below
+			permanent = err
+			return nil
+		}
+		doc = d
+		return err
+	}
+	run := attempt
+	if m.cfg.Retry.MaxAttempts > 1 {
+		run = func(ctx context.Context) error {
+			return retry.WithExponentialBackoffContext(ctx, m.cfg.Retry, attempt)
+		}
+	}
+
+	var err error
+	if m.cfg.Breaker != nil {
+		err = m.breakerFor(did).Call(ctx, run)
+	} else {
+		err = run(ctx)
+	}
+	switch {
+	case errors.Is(err, circuitbreaker.ErrCircuitOpen):
+		observability.Annotate(ctx, "breaker", "open")
+		return nil, err
+	case errors.Is(err, circuitbreaker.ErrTooManyCalls):
+		observability.Annotate(ctx, "breaker", "saturated")
+		return nil, err
+	case errors.Is(err, circuitbreaker.ErrTimeout):
+		observability.Annotate(ctx, "breaker", "timeout")
+		return nil, err
+	}
+	if permanent != nil {
+		return nil, permanent
+	}
+	if err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// breakerFor returns the breaker for a DID's host (did:web) or method
+func (m *MethodResolver) breakerFor(did string) *circuitbreaker.CircuitBreaker {
+	key := methodOf(did)
+	if host, _, err := WebHost(did); err == nil {
+		key = host
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if b, ok := m.breakers[key]; ok {
+		return b
+	}
+	if len(m.breakers) >= maxBreakers {
+		for k, b := range m.breakers {
+			if b.State() == circuitbreaker.StateClosed {
+				delete(m.breakers, k)
+			}
+		}
+	}
+	b := circuitbreaker.New(m.breaker)
+	m.breakers[key] = b
+	return b
+}
+
+// isPermanent reports whether a resolution error won't go away on retry
+func isPermanent(err error) bool {
+	for _, e := range []error{
+		ErrNotFound, ErrUnsupportedMethod, ErrDocumentMismatch, ErrDocumentLimit,
+		ErrMalformedDocument, ErrLocalHost, ErrDNSSECRequired, validate.ErrInvalidDID,
+	} {
+		if errors.Is(err, e) {
+			return true
+		}
+	}
+	return errors.Is(err, context.Canceled)
+}
+
+// RegisterMethod registers res for method wrapped with cfg (see MethodResolver)
+func (r *Registry) RegisterMethod(method string, res Resolver, cfg MethodConfig, cache CacheConfig) *MethodResolver {
+	m := NewMethodResolver(res, cfg, cache)
+	r.Register(method, m)
+	return m
+}
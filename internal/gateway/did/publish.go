@@ -0,0 +1,295 @@
+package did
+
+import (
+	"context"
+	"crypto/ecdh"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/example/privacy-gateway/internal/shared/crypto"
+)
+
+var ErrNoSigningKeys = errors.New("no active signing keys to publish")
+
+// SigningKey is a gateway signing key to publish. Retired keys stay listed
+// until NotAfter (the expiry of the last artifact they signed) so existing
+// tokens and credentials keep verifying across a rotation.
+type SigningKey struct {
+	ID       string // Fragment, e.g. "key-2024-06"
+	Public   ed25519.PublicKey
+	NotAfter time.Time // Zero for the current key
+	// Region that signs with the key in a multi-region deployment. Every
+	// region publishes every region's keys, so a document served from
+	// either region verifies tokens minted in both.
+	Region string
+}
+
+// AgreementKey is a gateway X25519 key agreement key to publish, e.g. for
+// clients encrypting request bodies to the gateway. Like signing keys,
+// retired keys stay listed until NotAfter.
+type AgreementKey struct {
+	ID       string // Fragment, e.g. "enc-2024-06"
+	Public   *ecdh.PublicKey
+	NotAfter time.Time // Zero for the current key
+}
+
+// ServiceEndpoint is a service advertised in the gateway's DID document
+type ServiceEndpoint struct {
+	ID       string // Fragment, e.g. "oid4vci"
+	Type     string
+	Endpoint string
+}
+
+// PublisherConfig configures the gateway's own did:web identity
+type PublisherConfig struct {
+	Domain   string // Public host (and port), e.g. gateway.example.com
+	Services []ServiceEndpoint
+	MaxAge   time.Duration // Cache-Control max-age for did.json (default 5m)
+}
+
+// Publisher serves the gateway's did:web document at /.well-known/did.json.
+// The document lists the token-signing keys under authentication and
+// assertionMethod and any X25519 keys under keyAgreement, and is rebuilt
+// whenever the key set changes.
+type Publisher struct {
+	did      string
+	services []ServiceEndpoint
+	maxAge   time.Duration
+
+	mu        sync.RWMutex
+	body      []byte
+	etag      string
+	keys      string // Fingerprint of the published key set
+	byID      map[string]SigningKey
+	signing   []SigningKey   // Active signing keys, current first
+	agreement []AgreementKey // Set by UpdateAgreement
+}
+
+// NewPublisher creates a publisher for did:web:<domain>
+func NewPublisher(cfg PublisherConfig) (*Publisher, error) {
+	id, err := NormalizeWebDID("did:web:" + cfg.Domain)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.MaxAge == 0 {
+		cfg.MaxAge = 5 * time.Minute
+	}
+	return &Publisher{did: id, services: cfg.Services, maxAge: cfg.MaxAge}, nil
+}
+
+// DID returns the gateway DID
+func (p *Publisher) DID() string {
+	return p.did
+}
+
+// KeyID returns the absolute verification method ID for a key fragment
+func (p *Publisher) KeyID(fragment string) string {
+	return p.did + "#" + fragment
+}
+
+// Update rebuilds the document from keys. Expired retired keys are dropped.
+// It reports whether the published document changed.
+func (p *Publisher) Update(keys []SigningKey) (bool, error) {
+	now := time.Now()
+	active := make([]SigningKey, 0, len(keys))
+	for _, k := range keys {
+		if len(k.Public) != ed25519.PublicKeySize {
+			return false, fmt.Errorf("signing key %s: invalid Ed25519 public key", k.ID)
+		}
+		if k.NotAfter.IsZero() || now.Before(k.NotAfter) {
+			active = append(active, k)
+		}
+	}
+	if len(active) == 0 {
+		return false, ErrNoSigningKeys
+	}
+	// Current key first, then retired keys newest first
+	sort.SliceStable(active, func(i, j int) bool {
+		a, b := active[i].NotAfter, active[j].NotAfter
+		if a.IsZero() != b.IsZero() {
+			return a.IsZero()
+		}
+		return a.After(b)
+	})
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.signing = active
+	return p.rebuild()
+}
+
+// UpdateAgreement replaces the published key agreement keys; expired retired
+// keys are dropped. It reports whether the published document changed, which
+// it doesn't until Update has published signing keys.
+func (p *Publisher) UpdateAgreement(keys []AgreementKey) (bool, error) {
+	now := time.Now()
+	active := make([]AgreementKey, 0, len(keys))
+	for _, k := range keys {
+		if k.Public == nil || k.Public.Curve() != ecdh.X25519() {
+			return false, fmt.Errorf("agreement key %s: not an X25519 key", k.ID)
+		}
+		if k.NotAfter.IsZero() || now.Before(k.NotAfter) {
+			active = append(active, k)
+		}
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.agreement = active
+	if len(p.signing) == 0 {
+		return false, nil
+	}
+	return p.rebuild()
+}
+
+// rebuild publishes the document for the current key sets if their
+// fingerprint changed; p.mu must be held
+func (p *Publisher) rebuild() (bool, error) {
+	fp := sha256.New()
+	for _, k := range p.signing {
+		fp.Write([]byte(k.ID))
+		fp.Write([]byte(k.Region))
+		fp.Write(k.Public)
+	}
+	for _, k := range p.agreement {
+		fp.Write([]byte("agreement:" + k.ID))
+		fp.Write(k.Public.Bytes())
+	}
+	fingerprint := base64.RawURLEncoding.EncodeToString(fp.Sum(nil))
+	if fingerprint == p.keys {
+		return false, nil
+	}
+
+	body, err := json.Marshal(p.document(p.signing, p.agreement))
+	if err != nil {
+		return false, err
+	}
+	sum := sha256.Sum256(body)
+	byID := make(map[string]SigningKey, len(p.signing))
+	for _, k := range p.signing {
+		byID[k.ID] = k
+	}
+	p.body, p.keys, p.byID = body, fingerprint, byID
+	p.etag = `"` + base64.RawURLEncoding.EncodeToString(sum[:16]) + `"`
+	return true, nil
+}
+
+// VerificationKey returns a published key by fragment, with the region that
+// signs with it. Token validation uses it to accept tokens minted by any
+// active region while still rejecting unpublished or expired keys.
+func (p *Publisher) VerificationKey(fragment string) (ed25519.PublicKey, string, bool) {
+	p.mu.RLock()
+	k, ok := p.byID[fragment]
+	p.mu.RUnlock()
+	if !ok || (!k.NotAfter.IsZero() && !time.Now().Before(k.NotAfter)) {
+		return nil, "", false
+	}
+	return k.Public, k.Region, true
+}
+
+// document builds the DID document for the given keys
+func (p *Publisher) document(keys []SigningKey, agreement []AgreementKey) *Document {
+	doc := &Document{
+		Context: []string{"https://www.w3.org/ns/did/v1", "https://w3id.org/security/suites/jws-2020/v1"},
+		ID:      p.did,
+	}
+	for _, k := range keys {
+		id := p.KeyID(k.ID)
+		jwk := map[string]interface{}{
+			"kty": "OKP",
+			"crv": "Ed25519",
+			"x":   base64.RawURLEncoding.EncodeToString(k.Public),
+			"kid": k.ID,
+		}
+		if k.Region != "" {
+			jwk["region"] = k.Region
+		}
+		doc.VerificationMethod = append(doc.VerificationMethod, VerificationMethod{
+			ID:           id,
+			Type:         "JsonWebKey2020",
+			Controller:   p.did,
+			PublicKeyJwk: jwk,
+		})
+		doc.Authentication = append(doc.Authentication, VerificationRef{ID: id})
+		doc.AssertionMethod = append(doc.AssertionMethod, VerificationRef{ID: id})
+	}
+	for _, k := range agreement {
+		id := p.KeyID(k.ID)
+		doc.VerificationMethod = append(doc.VerificationMethod, VerificationMethod{
+			ID:         id,
+			Type:       "JsonWebKey2020",
+			Controller: p.did,
+			PublicKeyJwk: map[string]interface{}{
+				"kty": "OKP",
+				"crv": "X25519",
+				"x":   crypto.EncodeX25519PublicKey(k.Public),
+				"kid": k.ID,
+			},
+		})
+		doc.KeyAgreement = append(doc.KeyAgreement, VerificationRef{ID: id})
+	}
+	for _, s := range p.services {
+		typ, _ := json.Marshal(s.Type)
+		endpoint, _ := json.Marshal(s.Endpoint)
+		doc.Service = append(doc.Service, Service{ID: p.did + "#" + s.ID, Type: typ, ServiceEndpoint: endpoint})
+	}
+	return doc
+}
+
+// Watch refreshes the document from keys at the given interval until ctx is
+// cancelled, so a key rotation is published without a restart. Rotation code
+// can also call Update directly for immediate publication.
+func (p *Publisher) Watch(ctx context.Context, interval time.Duration, keys func(context.Context) ([]SigningKey, error), onError func(error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		ks, err := keys(ctx)
+		if err == nil {
+			_, err = p.Update(ks)
+		}
+		if err != nil && onError != nil {
+			onError(err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// ServeHTTP serves GET /.well-known/did.json
+func (p *Publisher) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	p.mu.RLock()
+	body, etag := p.body, p.etag
+	p.mu.RUnlock()
+	if body == nil {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(p.maxAge.Seconds())))
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("Content-Type", ContentTypeDIDJSON)
+	w.WriteHeader(http.StatusOK)
+	if r.Method == http.MethodGet {
+		_, _ = w.Write(body)
+	}
+}
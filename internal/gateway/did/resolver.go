@@ -0,0 +1,119 @@
+package did
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/example/privacy-gateway/internal/shared/budget"
+	"github.com/example/privacy-gateway/internal/shared/observability"
+)
+
+var (
+	ErrUnsupportedMethod = errors.New("unsupported DID method")
+	ErrDocumentMismatch  = errors.New("DID document id does not match requested DID")
+)
+
+// ResolveOptions are DID resolution parameters
+type ResolveOptions struct {
+	VersionID   string
+	VersionTime string
+}
+
+// Resolver resolves DIDs of one method into documents
+type Resolver interface {
+	Resolve(ctx context.Context, did string, opts ResolveOptions) (*Document, error)
+}
+
+// Registry dispatches resolution to the resolver registered for a DID method
+type Registry struct {
+	mu        sync.RWMutex
+	resolvers map[string]Resolver
+	logger    *slog.Logger
+}
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{resolvers: make(map[string]Resolver), logger: observability.Component(nil, observability.ComponentResolver)}
+}
+
+// WithLogger sets the logger; resolutions are logged at debug level under
+// the resolver component
+func (r *Registry) WithLogger(logger *slog.Logger) *Registry {
+	r.logger = observability.Component(logger, observability.ComponentResolver)
+	return r
+}
+
+// Register sets the resolver for method (e.g. "web")
+func (r *Registry) Register(method string, res Resolver) {
+	r.mu.Lock()
+	r.resolvers[method] = res
+	r.mu.Unlock()
+}
+
+// Methods returns the registered method names
+func (r *Registry) Methods() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	out := make([]string, 0, len(r.resolvers))
+	for m := range r.resolvers {
+		out = append(out, m)
+	}
+	return out
+}
+
+// Resolve resolves a DID with the resolver for its method
+func (r *Registry) Resolve(ctx context.Context, did string, opts ResolveOptions) (*Document, error) {
+	method := methodOf(did)
+	r.mu.RLock()
+	res, ok := r.resolvers[method]
+	r.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedMethod, method)
+	}
+	var doc *Document
+	start := time.Now()
+	err := budget.Run(ctx, budget.StageResolution, func(ctx context.Context) (err error) {
+		doc, err = res.Resolve(ctx, did, opts)
+		return err
+	})
+	if r.logger.Enabled(ctx, slog.LevelDebug) {
+		attrs := []any{"method", method, "duration_ms", time.Since(start).Milliseconds()}
+		if host, _, herr := WebHost(did); herr == nil {
+			attrs = append(attrs, "host", host)
+		}
+		if err != nil {
+			r.logger.DebugContext(ctx, "DID resolution failed", append(attrs, "error", err)...)
+		} else {
+			r.logger.DebugContext(ctx, "DID resolved", attrs...)
+		}
+	}
+	return doc, err
+}
+
+// Dereference resolves the DID in a DID URL and selects the resource it
+// identifies; see Dereference
+func (r *Registry) Dereference(ctx context.Context, didURL string) (interface{}, error) {
+	u, err := ParseURL(didURL)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := r.Resolve(ctx, u.DID, u.ResolveOptions())
+	if err != nil {
+		return nil, err
+	}
+	return Dereference(doc, u)
+}
+
+// methodOf returns the method name of a DID
+func methodOf(did string) string {
+	parts := strings.SplitN(did, ":", 3)
+	if len(parts) < 3 || parts[0] != "did" {
+		return ""
+	}
+	return parts[1]
+}
@@ -0,0 +1,325 @@
+package did
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/net/http/httpproxy"
+	"golang.org/x/net/http2"
+
+	"github.com/example/privacy-gateway/internal/shared/clock"
+)
+
+// TransportConfig configures the HTTP client shared by DID resolvers
+type TransportConfig struct {
+	MaxConnsPerHost     int           // Default 16
+	MaxIdleConnsPerHost int           // Default 8
+	IdleConnTimeout     time.Duration // Default 90s
+	DialTimeout         time.Duration // Default 3s
+	TLSHandshakeTimeout time.Duration // Default 3s
+	Timeout             time.Duration // Whole request including body (default 10s)
+	DNSTTL              time.Duration // Default 1m; negative disables caching
+	TLSConfig           *tls.Config
+
+	// AllowLocal lets connections reach loopback, private and link-local
+	// addresses. Only dev mode sets it; otherwise a public name that resolves
+	// to such an address is refused at dial time.
+	AllowLocal bool
+
+	// LookupHost resolves hostnames (default net.DefaultResolver.LookupHost)
+	LookupHost func(ctx context.Context, host string) ([]string, error)
+	Clock      clock.Clock // Expires cached DNS answers (default clock.Real)
+
+	OnConn func(reused bool) // Metrics callback per request
+}
+
+// TransportStats reports connection reuse and DNS cache effectiveness
+type TransportStats struct {
+	Requests    int64 `json:"requests"`
+	ReusedConns int64 `json:"reused_conns"`
+	NewConns    int64 `json:"new_conns"`
+	DNSHits     int64 `json:"dns_hits"`
+	DNSMisses   int64 `json:"dns_misses"`
+}
+
+// Transport is a tuned http.RoundTripper for did:web and other HTTP-based
+// resolution: HTTP/2 with keepalive pings, bounded connections per host and
+// cached DNS answers. One Transport should be shared by all resolvers so
+// connections to popular DID hosts are reused.
+type Transport struct {
+	base    *http.Transport
+	timeout time.Duration
+	onConn  func(reused bool)
+	dns     *dnsCache
+
+	requests atomic.Int64
+	reused   atomic.Int64
+	created  atomic.Int64
+}
+
+var (
+	defaultTransportOnce sync.Once
+	defaultTransport     *Transport
+	devTransportOnce     sync.Once
+	devTransport         *Transport
+)
+
+// DefaultTransport returns the transport used by resolvers configured without
+// a Client, created with default settings on first use
+func DefaultTransport() *Transport {
+	defaultTransportOnce.Do(func() {
+		defaultTransport = NewTransport(TransportConfig{})
+	})
+	return defaultTransport
+}
+
+// DevTransport is DefaultTransport for dev mode: it may also connect to
+// local addresses
+func DevTransport() *Transport {
+	devTransportOnce.Do(func() {
+		devTransport = NewTransport(TransportConfig{AllowLocal: true})
+	})
+	return devTransport
+}
+
+// NewTransport creates a resolution transport
+func NewTransport(cfg TransportConfig) *Transport {
+	if cfg.MaxConnsPerHost == 0 {
+		cfg.MaxConnsPerHost = 16
+	}
+	if cfg.MaxIdleConnsPerHost == 0 {
+		cfg.MaxIdleConnsPerHost = 8
+	}
+	if cfg.IdleConnTimeout == 0 {
+		cfg.IdleConnTimeout = 90 * time.Second
+	}
+	if cfg.DialTimeout == 0 {
+		cfg.DialTimeout = 3 * time.Second
+	}
+	if cfg.TLSHandshakeTimeout == 0 {
+		cfg.TLSHandshakeTimeout = 3 * time.Second
+	}
+	if cfg.Timeout == 0 {
+		cfg.Timeout = 10 * time.Second
+	}
+	if cfg.DNSTTL == 0 {
+		cfg.DNSTTL = time.Minute
+	}
+	if cfg.LookupHost == nil {
+		cfg.LookupHost = net.DefaultResolver.LookupHost
+	}
+
+	t := &Transport{
+		timeout: cfg.Timeout,
+		onConn:  cfg.OnConn,
+		dns:     &dnsCache{ttl: cfg.DNSTTL, lookup: cfg.LookupHost, clock: clock.Or(cfg.Clock), entries: make(map[string]dnsEntry)},
+	}
+	dialer := &net.Dialer{Timeout: cfg.DialTimeout, KeepAlive: 30 * time.Second}
+	var check func(host string, ip net.IP) error
+	if !cfg.AllowLocal {
+		check = publicOnly(proxyHosts())
+	}
+	t.base = &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           t.dns.dialer(dialer, check),
+		TLSClientConfig:       cfg.TLSConfig,
+		TLSHandshakeTimeout:   cfg.TLSHandshakeTimeout,
+		MaxIdleConns:          256,
+		MaxIdleConnsPerHost:   cfg.MaxIdleConnsPerHost,
+		MaxConnsPerHost:       cfg.MaxConnsPerHost,
+		IdleConnTimeout:       cfg.IdleConnTimeout,
+		ResponseHeaderTimeout: cfg.Timeout,
+		ForceAttemptHTTP2:     true,
+	}
+	// Detect dead HTTP/2 connections instead of waiting for the request
+	// timeout. ConfigureTransports only fails on an already configured
+	// transport; ForceAttemptHTTP2 still negotiates h2 without the pings.
+	if h2, err := http2.ConfigureTransports(t.base); err == nil {
+		h2.ReadIdleTimeout = 30 * time.Second
+		h2.PingTimeout = 5 * time.Second
+	}
+	return t
+}
+
+// Client returns an http.Client using the transport with the total timeout
+func (t *Transport) Client() *http.Client {
+	return &http.Client{Transport: t, Timeout: t.timeout}
+}
+
+// RoundTrip implements http.RoundTripper, recording connection reuse
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.requests.Add(1)
+	trace := &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			if info.Reused {
+				t.reused.Add(1)
+			} else {
+				t.created.Add(1)
+			}
+			if t.onConn != nil {
+				t.onConn(info.Reused)
+			}
+		},
+	}
+	return t.base.RoundTrip(req.WithContext(httptrace.WithClientTrace(req.Context(), trace)))
+}
+
+// CloseIdleConnections closes pooled connections
+func (t *Transport) CloseIdleConnections() {
+	t.base.CloseIdleConnections()
+}
+
+// Stats returns counters since the transport was created
+func (t *Transport) Stats() TransportStats {
+	hits, misses := t.dns.stats()
+	return TransportStats{
+		Requests:    t.requests.Load(),
+		ReusedConns: t.reused.Load(),
+		NewConns:    t.created.Load(),
+		DNSHits:     hits,
+		DNSMisses:   misses,
+	}
+}
+
+// dnsCache caches LookupHost answers for a fixed TTL
+type dnsCache struct {
+	ttl    time.Duration
+	lookup func(ctx context.Context, host string) ([]string, error)
+	clock  clock.Clock
+
+	mu      sync.Mutex
+	entries map[string]dnsEntry
+
+	hits   atomic.Int64
+	misses atomic.Int64
+}
+
+type dnsEntry struct {
+	addrs   []string
+	expires time.Time
+}
+
+// resolve returns the addresses of host, from cache when fresh
+func (c *dnsCache) resolve(ctx context.Context, host string) ([]string, error) {
+	if c.ttl > 0 {
+		c.mu.Lock()
+		e, ok := c.entries[host]
+		c.mu.Unlock()
+		if ok && c.clock.Now().Before(e.expires) {
+			c.hits.Add(1)
+			return e.addrs, nil
+		}
+	}
+	c.misses.Add(1)
+
+	addrs, err := c.lookup(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	if len(addrs) == 0 {
+		return nil, &net.DNSError{Err: "no addresses", Name: host, IsNotFound: true}
+	}
+	if c.ttl > 0 {
+		c.mu.Lock()
+		c.entries[host] = dnsEntry{addrs: addrs, expires: c.clock.Now().Add(c.ttl)}
+		c.mu.Unlock()
+	}
+	return addrs, nil
+}
+
+// dialer wraps d so hostnames are resolved through the cache. Addresses are
+// tried in order until one connects. check, when set, vets every address
+// before it is dialed.
+func (c *dnsCache) dialer(d *net.Dialer, check func(host string, ip net.IP) error) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, err
+		}
+		if ip := net.ParseIP(host); ip != nil {
+			if check != nil {
+				if err := check(host, ip); err != nil {
+					return nil, err
+				}
+			}
+			return d.DialContext(ctx, network, addr)
+		}
+		addrs, err := c.resolve(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		var errs []error
+		for _, ip := range addrs {
+			if check != nil {
+				if err := check(host, net.ParseIP(ip)); err != nil {
+					errs = append(errs, err)
+					continue
+				}
+			}
+			conn, err := d.DialContext(ctx, network, net.JoinHostPort(ip, port))
+			if err == nil {
+				return conn, nil
+			}
+			errs = append(errs, err)
+			if ctx.Err() != nil {
+				break
+			}
+		}
+		// Re-resolve next time in case the host moved
+		c.evict(host)
+		return nil, errors.Join(errs...)
+	}
+}
+
+func (c *dnsCache) evict(host string) {
+	c.mu.Lock()
+	delete(c.entries, host)
+	c.mu.Unlock()
+}
+
+func (c *dnsCache) stats() (hits, misses int64) {
+	return c.hits.Load(), c.misses.Load()
+}
+
+// publicOnly returns a dial check refusing local addresses. Hosts of the
+// configured HTTP(S) proxy are exempt, since with a proxy every connection
+// goes to it and it fetches the target itself.
+func publicOnly(exempt map[string]bool) func(host string, ip net.IP) error {
+	return func(host string, ip net.IP) error {
+		if ip == nil {
+			return fmt.Errorf("%w: %s has an invalid address", ErrLocalHost, host)
+		}
+		if localAddr(ip) && !exempt[host] {
+			return fmt.Errorf("%w: %s resolves to %s", ErrLocalHost, host, ip)
+		}
+		return nil
+	}
+}
+
+// proxyHosts returns the hosts of the proxies named in the environment
+func proxyHosts() map[string]bool {
+	hosts := make(map[string]bool)
+	env := httpproxy.FromEnvironment()
+	for _, p := range []string{env.HTTPProxy, env.HTTPSProxy} {
+		if p == "" {
+			continue
+		}
+		if !strings.Contains(p, "://") {
+			p = "http://" + p
+		}
+		if u, err := url.Parse(p); err == nil && u.Hostname() != "" {
+			hosts[u.Hostname()] = true
+		}
+	}
+	return hosts
+}
@@ -0,0 +1,108 @@
+package did
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/example/privacy-gateway/internal/shared/validate"
+)
+
+var (
+	ErrInvalidDIDURL = errors.New("invalid DID URL")
+	ErrNotFound      = errors.New("DID URL does not resolve to a resource")
+)
+
+// URL is a parsed DID URL: did [path] [?query] [#fragment]
+type URL struct {
+	DID      string
+	Path     string
+	Query    url.Values
+	Fragment string
+}
+
+// ParseURL splits a DID URL into its components and validates the DID
+func ParseURL(s string) (URL, error) {
+	var u URL
+	rest, fragment, hasFragment := strings.Cut(s, "#")
+	if hasFragment {
+		if fragment == "" {
+			return u, fmt.Errorf("%w: empty fragment", ErrInvalidDIDURL)
+		}
+		u.Fragment = fragment
+	}
+	rest, rawQuery, _ := strings.Cut(rest, "?")
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return u, fmt.Errorf("%w: %v", ErrInvalidDIDURL, err)
+	}
+	u.Query = query
+	if i := strings.IndexByte(rest, '/'); i >= 0 {
+		u.DID, u.Path = rest[:i], rest[i:]
+	} else {
+		u.DID = rest
+	}
+	if err := validate.ValidateDID(u.DID); err != nil {
+		return u, fmt.Errorf("%w: %v", ErrInvalidDIDURL, err)
+	}
+	return u, nil
+}
+
+// ResolveOptions returns the resolution parameters carried in the query
+func (u URL) ResolveOptions() ResolveOptions {
+	return ResolveOptions{
+		VersionID:   u.Query.Get("versionId"),
+		VersionTime: u.Query.Get("versionTime"),
+	}
+}
+
+// String renders the DID URL
+func (u URL) String() string {
+	s := u.DID + u.Path
+	if q := u.Query.Encode(); q != "" {
+		s += "?" + q
+	}
+	if u.Fragment != "" {
+		s += "#" + u.Fragment
+	}
+	return s
+}
+
+// Dereference selects the resource a DID URL identifies within its resolved
+// document:
+//
+//	#fragment                    the verification method or service with that ID
+//	?service=<id>                the service's endpoint, with relativeRef appended
+//	no fragment, path or service the document itself
+//
+// It returns *Document, *VerificationMethod, *Service or a string URL.
+func Dereference(doc *Document, u URL) (interface{}, error) {
+	if u.Path != "" {
+		return nil, fmt.Errorf("%w: DID URL paths are not supported", ErrNotFound)
+	}
+
+	if svc := u.Query.Get("service"); svc != "" {
+		s := doc.findService("#" + svc)
+		if s == nil {
+			return nil, fmt.Errorf("%w: service %s", ErrNotFound, svc)
+		}
+		endpoint, ok := s.EndpointURL()
+		if !ok {
+			return nil, fmt.Errorf("%w: service %s has no URL endpoint", ErrNotFound, svc)
+		}
+		return endpoint + u.Query.Get("relativeRef"), nil
+	}
+
+	if u.Fragment != "" {
+		id := "#" + u.Fragment
+		if vm := doc.findMethod(id); vm != nil {
+			return vm, nil
+		}
+		if s := doc.findService(id); s != nil {
+			return s, nil
+		}
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+	return doc, nil
+}
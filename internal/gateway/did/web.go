@@ -0,0 +1,183 @@
+package did
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/net/idna"
+
+	"github.com/example/privacy-gateway/internal/shared/validate"
+)
+
+// WebConfig configures the did:web resolver
+type WebConfig struct {
+	Client   *http.Client  // Default: DefaultTransport().Client(), DevTransport in dev mode
+	Timeout  time.Duration // Per fetch (default 5s)
+	MaxBytes int64         // Document size cap (default 256KB)
+	Limits   DocumentLimits
+
+	// DevMode resolves local hosts over http; GatewayDomain must then be
+	// local too (see checkDevMode)
+	DevMode       bool
+	GatewayDomain string
+}
+
+// WebResolver resolves did:web DIDs by fetching did.json over HTTPS
+type WebResolver struct {
+	client   *http.Client
+	timeout  time.Duration
+	maxBytes int64
+	limits   DocumentLimits
+	devMode  bool
+}
+
+// NewWebResolver creates a did:web resolver
+func NewWebResolver(cfg WebConfig) (*WebResolver, error) {
+	if cfg.DevMode {
+		if err := checkDevMode(cfg.GatewayDomain); err != nil {
+			return nil, err
+		}
+	}
+	switch {
+	case cfg.Client != nil:
+	case cfg.DevMode:
+		cfg.Client = DevTransport().Client()
+	default:
+		cfg.Client = DefaultTransport().Client()
+	}
+	if cfg.Timeout == 0 {
+		cfg.Timeout = 5 * time.Second
+	}
+	if cfg.MaxBytes == 0 {
+		cfg.MaxBytes = 256 << 10
+	}
+	return &WebResolver{client: cfg.Client, timeout: cfg.Timeout, maxBytes: cfg.MaxBytes, limits: cfg.Limits, devMode: cfg.DevMode}, nil
+}
+
+// WebHost decodes the host (and optional port) of a did:web DID. The domain
+// may be given as punycode or as percent-encoded Unicode; the result is always
+// the ASCII (punycode) form.
+func WebHost(did string) (string, []string, error) {
+	id, ok := strings.CutPrefix(did, "did:web:")
+	if !ok {
+		return "", nil, fmt.Errorf("%w: not a did:web DID", ErrUnsupportedMethod)
+	}
+	segments := strings.Split(id, ":")
+	hostPort, err := url.PathUnescape(segments[0])
+	if err != nil {
+		return "", nil, fmt.Errorf("%w: bad domain encoding", validate.ErrInvalidDID)
+	}
+	host, port := hostPort, ""
+	if h, p, err := net.SplitHostPort(hostPort); err == nil {
+		host, port = h, p
+	}
+	ascii, err := idna.Lookup.ToASCII(host)
+	if err != nil {
+		return "", nil, fmt.Errorf("%w: invalid domain: %v", validate.ErrInvalidDID, err)
+	}
+	if port != "" {
+		ascii = net.JoinHostPort(ascii, port)
+	}
+
+	path := make([]string, 0, len(segments)-1)
+	for _, seg := range segments[1:] {
+		p, err := url.PathUnescape(seg)
+		if err != nil || p == "" || strings.ContainsAny(p, "/?#") {
+			return "", nil, fmt.Errorf("%w: bad path segment", validate.ErrInvalidDID)
+		}
+		path = append(path, p)
+	}
+	return ascii, path, nil
+}
+
+// NormalizeWebDID rewrites a did:web DID with its domain in punycode so the
+// Unicode and ASCII spellings of one DID compare equal
+func NormalizeWebDID(did string) (string, error) {
+	host, path, err := WebHost(did)
+	if err != nil {
+		return "", err
+	}
+	out := "did:web:" + strings.ReplaceAll(host, ":", "%3A")
+	for _, p := range path {
+		out += ":" + url.PathEscape(p)
+	}
+	return out, nil
+}
+
+// DocumentURL returns the HTTPS URL of a did:web document. versionID, when
+// set, is forwarded as a query parameter for hosts that serve history.
+func (w *WebResolver) DocumentURL(did, versionID string) (string, error) {
+	host, path, err := WebHost(did)
+	if err != nil {
+		return "", err
+	}
+	origin, err := webOrigin(host, w.devMode)
+	if err != nil {
+		return "", err
+	}
+	u := "/.well-known/did.json"
+	if len(path) > 0 {
+		u = ""
+		for _, p := range path {
+			u += "/" + url.PathEscape(p)
+		}
+		u += "/did.json"
+	}
+	if versionID != "" {
+		u += "?" + url.Values{"versionId": {versionID}}.Encode()
+	}
+	return origin + u, nil
+}
+
+// Resolve fetches and decodes the DID document
+func (w *WebResolver) Resolve(ctx context.Context, did string, opts ResolveOptions) (*Document, error) {
+	docURL, err := w.DocumentURL(did, opts.VersionID)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, w.timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, docURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/did+json, application/json")
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch %s: %w", docURL, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, did)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch %s: status %d", docURL, resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, w.maxBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(body)) > w.maxBytes {
+		return nil, fmt.Errorf("%w: document for %s exceeds %d bytes", ErrDocumentLimit, did, w.maxBytes)
+	}
+	doc, err := ParseDocument(body, w.limits)
+	if err != nil {
+		return nil, fmt.Errorf("invalid DID document for %s: %w", did, err)
+	}
+
+	want, _ := NormalizeWebDID(did)
+	got, err := NormalizeWebDID(doc.ID)
+	if err != nil || got != want {
+		return nil, fmt.Errorf("%w: got %s", ErrDocumentMismatch, doc.ID)
+	}
+	return doc, nil
+}
@@ -0,0 +1,46 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"time"
+
+	"github.com/example/privacy-gateway/internal/shared/audit"
+	"github.com/example/privacy-gateway/internal/shared/bus"
+	"github.com/example/privacy-gateway/internal/shared/models"
+	"github.com/example/privacy-gateway/internal/shared/observability"
+)
+
+// AuditStream records audit events in a sink and then streams each one on
+// the message bus, on subject gateway.audit.<event> (e.g.
+// gateway.audit.bundle.import). The sink stays the record of truth: a
+// failed publish is logged, not returned.
+type AuditStream struct {
+	sink   audit.Sink
+	bus    bus.MessageBus
+	logger *slog.Logger
+}
+
+// NewAuditStream wraps sink; use it wherever an audit.Sink is configured
+func NewAuditStream(sink audit.Sink, b bus.MessageBus, logger *slog.Logger) *AuditStream {
+	logger = observability.Component(logger, observability.ComponentAudit)
+	return &AuditStream{sink: sink, bus: b, logger: logger}
+}
+
+// InsertAuditEvent records ev, then publishes it
+func (a *AuditStream) InsertAuditEvent(ctx context.Context, ev models.AuditEvent) error {
+	if err := a.sink.InsertAuditEvent(ctx, ev); err != nil {
+		return err
+	}
+	data, err := json.Marshal(ev)
+	if err != nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	if err := a.bus.Publish(ctx, "gateway.audit."+ev.Event, data); err != nil {
+		a.logger.Warn("failed to stream audit event", "event", ev.Event, "error", err)
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	"github.com/example/privacy-gateway/internal/shared/bus"
+)
+
+// MessageBus publishes events on a shared message bus, on subjects
+// <prefix>.issued and <prefix>.revoked
+type MessageBus struct {
+	bus    bus.MessageBus
+	prefix string
+}
+
+// NewMessageBus creates a bus publishing under prefix (default
+// "gateway.tokens")
+func NewMessageBus(b bus.MessageBus, prefix string) *MessageBus {
+	if prefix == "" {
+		prefix = "gateway.tokens"
+	}
+	return &MessageBus{bus: b, prefix: prefix}
+}
+
+// Publish publishes every event in order. On NATS it then waits for the
+// server to acknowledge the batch, so a lost connection is retried.
+func (b *MessageBus) Publish(ctx context.Context, events []Event) error {
+	for _, ev := range events {
+		data, err := json.Marshal(ev)
+		if err != nil {
+			return err
+		}
+		_, kind, _ := strings.Cut(ev.Type, ".")
+		if err := b.bus.Publish(ctx, b.prefix+"."+kind, data); err != nil {
+			return err
+		}
+	}
+	if f, ok := b.bus.(interface{ Flush(context.Context) error }); ok {
+		return f.Flush(ctx)
+	}
+	return nil
+}
+
+// Close is a no-op; the shared bus is closed by its owner
+func (b *MessageBus) Close() error {
+	return nil
+}
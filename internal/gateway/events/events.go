@@ -0,0 +1,197 @@
+package events
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/example/privacy-gateway/internal/gateway/webhook"
+	"github.com/example/privacy-gateway/internal/shared/models"
+	"github.com/example/privacy-gateway/internal/shared/observability"
+	"github.com/example/privacy-gateway/internal/shared/retry"
+)
+
+// Event is a token lifecycle event as published on the bus
+type Event struct {
+	ID        string     `json:"id"`   // Unique per event so consumers can de-duplicate
+	Type      string     `json:"type"` // webhook.EventTokenIssued or webhook.EventTokenRevoked
+	Time      time.Time  `json:"time"`
+	DID       string     `json:"did"`
+	JTI       string     `json:"jti,omitempty"` // Empty on revocation: every token of the DID
+	Tenant    string     `json:"tenant,omitempty"`
+	Scopes    []string   `json:"scopes,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	Reason    string     `json:"reason,omitempty"` // Why a token was revoked
+}
+
+// Bus publishes events to a message bus
+type Bus interface {
+	Publish(ctx context.Context, events []Event) error
+	Close() error
+}
+
+// Config configures the token event stream
+type Config struct {
+	Bus       Bus
+	QueueSize int // Events buffered while the bus is slow or down (default 10000)
+	BatchSize int // Events per publish (default 100)
+	Retry     retry.Config
+	Logger    *slog.Logger
+}
+
+// Stats counts published and dropped events
+type Stats struct {
+	Published int64 `json:"published"`
+	Failed    int64 `json:"failed"`  // Dropped after retries
+	Dropped   int64 `json:"dropped"` // Dropped because the queue was full
+	Queued    int   `json:"queued"`
+}
+
+// Stream publishes token issuance and revocation events so downstream
+// services can keep their session caches in sync without polling
+// introspection. Emitting never blocks the request: events are queued and
+// published in order by Run, with retries. Delivery is at least once; an
+// event that still fails after retries, or that doesn't fit in a full
+// queue, is dropped and counted, so consumers should also honour token
+// expiry.
+type Stream struct {
+	cfg   Config
+	queue chan Event
+
+	published atomic.Int64
+	failed    atomic.Int64
+	dropped   atomic.Int64
+
+	done chan struct{}
+	once sync.Once
+}
+
+// NewStream creates a token event stream publishing to cfg.Bus
+func NewStream(cfg Config) *Stream {
+	if cfg.QueueSize == 0 {
+		cfg.QueueSize = 10000
+	}
+	if cfg.BatchSize == 0 {
+		cfg.BatchSize = 100
+	}
+	if cfg.Retry.MaxAttempts == 0 {
+		cfg.Retry = retry.DefaultConfig()
+	}
+	cfg.Logger = observability.Component(cfg.Logger, observability.ComponentEvents)
+	return &Stream{cfg: cfg, queue: make(chan Event, cfg.QueueSize), done: make(chan struct{})}
+}
+
+// TokenIssued emits token.issued for a freshly minted access token
+func (s *Stream) TokenIssued(claims *models.AccessTokenClaims) {
+	exp := time.Unix(claims.ExpiresAt, 0).UTC()
+	s.emit(Event{
+		Type:      webhook.EventTokenIssued,
+		DID:       claims.Subject,
+		JTI:       claims.JWTID,
+		Tenant:    claims.VCIssuer,
+		Scopes:    claims.Scopes,
+		ExpiresAt: &exp,
+	})
+}
+
+// TokenRevoked emits token.revoked for one token, or for every token of
+// the DID when jti is empty
+func (s *Stream) TokenRevoked(did, jti, reason string) {
+	s.emit(Event{Type: webhook.EventTokenRevoked, DID: did, JTI: jti, Reason: reason})
+}
+
+func (s *Stream) emit(ev Event) {
+	ev.ID, ev.Time = uuid.NewString(), time.Now().UTC()
+	select {
+	case s.queue <- ev:
+	default:
+		if s.dropped.Add(1)%1000 == 1 {
+			s.cfg.Logger.Error("token event queue is full; dropping events", "type", ev.Type, "dropped", s.dropped.Load())
+		}
+	}
+}
+
+// Run publishes queued events until ctx is cancelled, then drains the queue
+// for up to 10 seconds and closes the bus
+func (s *Stream) Run(ctx context.Context) {
+	defer close(s.done)
+	batch := make([]Event, 0, s.cfg.BatchSize)
+	for {
+		select {
+		case <-ctx.Done():
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			s.drain(shutdownCtx, batch)
+			cancel()
+			if err := s.cfg.Bus.Close(); err != nil {
+				s.cfg.Logger.Warn("failed to close token event bus", "error", err)
+			}
+			return
+		case ev := <-s.queue:
+			batch = s.fill(append(batch, ev))
+			if s.publish(ctx, batch) {
+				batch = batch[:0]
+			}
+		}
+	}
+}
+
+// Wait blocks until Run has returned
+func (s *Stream) Wait() {
+	<-s.done
+}
+
+// fill adds already-queued events to batch, up to the batch size
+func (s *Stream) fill(batch []Event) []Event {
+	for len(batch) < s.cfg.BatchSize {
+		select {
+		case ev := <-s.queue:
+			batch = append(batch, ev)
+		default:
+			return batch
+		}
+	}
+	return batch
+}
+
+func (s *Stream) drain(ctx context.Context, batch []Event) {
+	for {
+		batch = s.fill(batch)
+		if len(batch) == 0 || ctx.Err() != nil {
+			return
+		}
+		s.publish(ctx, batch)
+		batch = batch[:0]
+	}
+}
+
+// publish writes a batch with retries. It returns false, keeping the batch
+// for the shutdown drain, when ctx was cancelled mid-publish.
+func (s *Stream) publish(ctx context.Context, batch []Event) bool {
+	err := retry.WithExponentialBackoffContext(ctx, s.cfg.Retry, func(ctx context.Context) error {
+		return s.cfg.Bus.Publish(ctx, batch)
+	})
+	switch {
+	case err == nil:
+		s.published.Add(int64(len(batch)))
+	case ctx.Err() != nil:
+		return false
+	default:
+		s.failed.Add(int64(len(batch)))
+		s.cfg.Logger.Error("failed to publish token events", "events", len(batch), "error", err)
+	}
+	return true
+}
+
+// Stats returns the stream's counters
+func (s *Stream) Stats() Stats {
+	return Stats{
+		Published: s.published.Load(),
+		Failed:    s.failed.Load(),
+		Dropped:   s.dropped.Load(),
+		Queued:    len(s.queue),
+	}
+}
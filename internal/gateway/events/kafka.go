@@ -0,0 +1,67 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// KafkaConfig configures the Kafka bus
+type KafkaConfig struct {
+	Brokers []string
+	Topic   string        // Default "gateway.tokens"
+	Timeout time.Duration // Per batch write (default 10s)
+}
+
+// KafkaBus publishes events as JSON messages keyed by DID, so one DID's
+// issuances and revocations land on the same partition in order
+type KafkaBus struct {
+	writer  *kafka.Writer
+	timeout time.Duration
+}
+
+// NewKafkaBus creates a Kafka bus
+func NewKafkaBus(cfg KafkaConfig) *KafkaBus {
+	if cfg.Topic == "" {
+		cfg.Topic = "gateway.tokens"
+	}
+	if cfg.Timeout == 0 {
+		cfg.Timeout = 10 * time.Second
+	}
+	return &KafkaBus{
+		writer: &kafka.Writer{
+			Addr:         kafka.TCP(cfg.Brokers...),
+			Topic:        cfg.Topic,
+			Balancer:     &kafka.Hash{},
+			RequiredAcks: kafka.RequireAll,
+		},
+		timeout: cfg.Timeout,
+	}
+}
+
+// Publish writes one message per event, with the event type in the
+// "type" header
+func (b *KafkaBus) Publish(ctx context.Context, events []Event) error {
+	msgs := make([]kafka.Message, 0, len(events))
+	for _, ev := range events {
+		value, err := json.Marshal(ev)
+		if err != nil {
+			return err
+		}
+		msgs = append(msgs, kafka.Message{
+			Key:     []byte(ev.DID),
+			Value:   value,
+			Headers: []kafka.Header{{Key: "type", Value: []byte(ev.Type)}},
+		})
+	}
+	ctx, cancel := context.WithTimeout(ctx, b.timeout)
+	defer cancel()
+	return b.writer.WriteMessages(ctx, msgs...)
+}
+
+// Close flushes and closes the Kafka writer
+func (b *KafkaBus) Close() error {
+	return b.writer.Close()
+}
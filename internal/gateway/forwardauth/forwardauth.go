@@ -0,0 +1,250 @@
+package forwardauth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/example/privacy-gateway/internal/gateway/apikey"
+	"github.com/example/privacy-gateway/internal/gateway/policy"
+	"github.com/example/privacy-gateway/internal/shared/httpx"
+	"github.com/example/privacy-gateway/internal/shared/i18n"
+	"github.com/example/privacy-gateway/internal/shared/models"
+	"github.com/example/privacy-gateway/internal/shared/observability"
+)
+
+// Identity headers set on allowed responses, for the reverse proxy to copy
+// to the upstream request
+const (
+	HeaderSubject    = "X-Gateway-Subject"
+	HeaderScopes     = "X-Gateway-Scopes" // Space separated
+	HeaderPolicy     = "X-Gateway-Policy"
+	HeaderVCTypes    = "X-Gateway-VC-Types" // Space separated
+	HeaderVCIssuer   = "X-Gateway-VC-Issuer"
+	HeaderTrustTier  = "X-Gateway-Trust-Tier"
+	HeaderAccountID  = "X-Gateway-Account-ID" // Set when the DID is linked to an account
+	HeaderTrustScore = "X-Gateway-Trust-Score"
+	HeaderDeviceID   = "X-Gateway-Device-ID" // Set when the token names a device
+)
+
+// TokenVerifier validates a gateway access token; the gateway's token
+// service implements it
+type TokenVerifier interface {
+	VerifyToken(ctx context.Context, token string) (*models.AccessTokenClaims, error)
+}
+
+// DeviceChecker reports whether a token's device was revoked;
+// device.Registry implements it
+type DeviceChecker interface {
+	Revoked(ctx context.Context, did, deviceID string) (bool, error)
+}
+
+// Config configures the forward auth endpoint
+type Config struct {
+	Tokens TokenVerifier
+	// Router returns the current policy routes
+	Router func() *policy.Router
+	// APIKeys, when set, accepts API keys on policies that allow them
+	APIKeys *apikey.Manager
+	// Linkage verifies issuers for require_domain_linked_issuer policies;
+	// without it those policies deny every caller
+	Linkage policy.LinkageVerifier
+	// Devices, when set, rejects tokens minted for a revoked device
+	Devices DeviceChecker
+	// Decisions, when set, caches policy decisions per token
+	Decisions *policy.DecisionCache
+	Denials   *policy.Denials
+	// Logger is optional
+	Logger *slog.Logger
+}
+
+// Handler serves GET /v1/auth/forward for reverse proxies that delegate
+// authorization: Traefik ForwardAuth and NGINX auth_request. The original
+// request is described by X-Forwarded-Method and X-Forwarded-Uri (Traefik)
+// or X-Original-Method and X-Original-URI (set in the NGINX config). The
+// path is decoded and cleaned before matching, and one with ".." segments,
+// backslashes or encoded slashes is rejected with 400. It answers 200 with
+// identity headers when the token satisfies the policy of the original
+// route, 401 without a valid token and 403 when the policy denies the
+// caller. Rate limits, quotas and body conditions are not enforced here;
+// those need the full proxy.
+type Handler struct {
+	cfg Config
+}
+
+// NewHandler creates the forward auth handler
+func NewHandler(cfg Config) *Handler {
+	if cfg.Denials == nil {
+		cfg.Denials = &policy.Denials{}
+	}
+	cfg.Logger = observability.Component(cfg.Logger, observability.ComponentPolicy)
+	return &Handler{cfg: cfg}
+}
+
+// original returns the method and path of the request being authorized
+func original(r *http.Request) (string, string, bool) {
+	method := r.Header.Get("X-Forwarded-Method")
+	if method == "" {
+		method = r.Header.Get("X-Original-Method")
+	}
+	if method == "" {
+		method = http.MethodGet
+	}
+	uri := r.Header.Get("X-Forwarded-Uri")
+	if uri == "" {
+		uri = r.Header.Get("X-Original-URI")
+	}
+	u, err := url.ParseRequestURI(uri)
+	if uri == "" || err != nil {
+		return "", "", false
+	}
+	p, ok := cleanPath(u)
+	if !ok {
+		return "", "", false
+	}
+	return method, p, true
+}
+
+// cleanPath returns the decoded, cleaned path of u for policy matching. The
+// header carries the raw request line, which the proxy may not have
+// normalized, and the upstream may resolve ".." or an encoded slash
+// differently than the router would; such paths are rejected rather than
+// guessed at, so a request can't match one policy and reach another route.
+func cleanPath(u *url.URL) (string, bool) {
+	if strings.Contains(strings.ToLower(u.EscapedPath()), "%2f") {
+		return "", false
+	}
+	if !strings.HasPrefix(u.Path, "/") || strings.ContainsRune(u.Path, '\\') {
+		return "", false
+	}
+	for _, seg := range strings.Split(u.Path, "/") {
+		if seg == ".." {
+			return "", false
+		}
+	}
+	return path.Clean(u.Path), true
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// Decisions depend on the caller's token; never let a cache reuse them
+	w.Header().Set("Cache-Control", "no-store")
+	method, path, ok := original(r)
+	if !ok {
+		httpx.WriteJSON(w, http.StatusBadRequest, httpx.ErrorResponse{Error: "missing or invalid X-Forwarded-Uri or X-Original-URI"})
+		return
+	}
+
+	var match *policy.Match
+	if router := h.cfg.Router(); router != nil {
+		if m, found := router.Match(method, path); found {
+			match = &m
+		}
+	}
+	var pol *models.Policy
+	if match != nil {
+		pol = match.Policy
+	}
+
+	claims, isKey, err := h.authenticate(r, pol)
+	if err != nil {
+		h.cfg.Logger.Debug("forward auth rejected", "method", method, "path", path, "error", err)
+		w.Header().Set("WWW-Authenticate", `Bearer realm="gateway"`)
+		i18n.WriteError(w, r, http.StatusUnauthorized, i18n.CodeUnauthorized)
+		return
+	}
+	caller := policy.Caller{
+		Scopes:     claims.Scopes,
+		VCTypes:    claims.VCTypes,
+		Issuer:     claims.VCIssuer,
+		TrustTier:  claims.VCTrustTier,
+		APIKey:     isKey,
+		TrustScore: claims.TrustScore,
+	}
+	if match == nil {
+		h.cfg.Denials.Write(w, r, policy.Denial{Decision: policy.Decision{Reason: policy.ReasonNoMatchingPolicy}, Caller: caller})
+		return
+	}
+	if pol.RequireDomainLinkedIssuer && h.cfg.Linkage != nil {
+		caller.IssuerLinked = policy.CheckIssuerLinkage(r.Context(), pol, claims.VCIssuer, h.cfg.Linkage) == nil
+	}
+	// API key scopes can change under the same key ID, so only token
+	// decisions are cached
+	jti := claims.JWTID
+	if isKey {
+		jti = ""
+	}
+	if d := h.evaluate(pol, caller, jti); !d.Allowed {
+		h.cfg.Denials.Write(w, r, policy.Denial{Decision: d, Match: match, Caller: caller})
+		return
+	}
+
+	hdr := w.Header()
+	hdr.Set(HeaderSubject, claims.Subject)
+	hdr.Set(HeaderScopes, strings.Join(claims.Scopes, " "))
+	hdr.Set(HeaderPolicy, pol.ID)
+	if len(claims.VCTypes) > 0 {
+		hdr.Set(HeaderVCTypes, strings.Join(claims.VCTypes, " "))
+	}
+	if claims.VCIssuer != "" {
+		hdr.Set(HeaderVCIssuer, claims.VCIssuer)
+		hdr.Set(HeaderTrustTier, strconv.Itoa(claims.VCTrustTier))
+	}
+	if claims.AccountID != "" {
+		hdr.Set(HeaderAccountID, claims.AccountID)
+	}
+	if claims.DeviceID != "" {
+		hdr.Set(HeaderDeviceID, claims.DeviceID)
+	}
+	if !isKey {
+		hdr.Set(HeaderTrustScore, strconv.Itoa(claims.TrustScore))
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// authenticate returns the claims of the bearer token or, where pol allows
+// it, the API key on r
+func (h *Handler) authenticate(r *http.Request, pol *models.Policy) (*models.AccessTokenClaims, bool, error) {
+	if h.cfg.APIKeys != nil {
+		claims, err := h.cfg.APIKeys.Authorize(r, pol)
+		if errors.Is(err, apikey.ErrRouteNotAllowed) {
+			// A valid key on the wrong route is a policy denial, not a 401
+			claims, err = h.cfg.APIKeys.Authenticate(r.Context(), apikey.FromRequest(r))
+		}
+		if !errors.Is(err, apikey.ErrNoKey) {
+			return claims, err == nil, err
+		}
+	}
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || token == "" {
+		return nil, false, errors.New("no bearer token")
+	}
+	claims, err := h.cfg.Tokens.VerifyToken(r.Context(), token)
+	if err != nil {
+		return nil, false, err
+	}
+	if claims.DeviceID != "" && h.cfg.Devices != nil {
+		revoked, err := h.cfg.Devices.Revoked(r.Context(), claims.Subject, claims.DeviceID)
+		if err != nil {
+			return nil, false, fmt.Errorf("check device: %w", err)
+		}
+		if revoked {
+			return nil, false, errors.New("device revoked")
+		}
+	}
+	return claims, false, nil
+}
+
+// evaluate decides the caller's access to pol, through the decision cache
+// when one is configured
+func (h *Handler) evaluate(pol *models.Policy, caller policy.Caller, jti string) policy.Decision {
+	if h.cfg.Decisions == nil {
+		return policy.Evaluate(pol, caller)
+	}
+	return h.cfg.Decisions.Evaluate(pol, caller, jti)
+}
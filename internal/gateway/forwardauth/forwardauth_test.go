@@ -0,0 +1,70 @@
+package forwardauth_test
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/example/privacy-gateway/internal/gateway/forwardauth"
+	"github.com/example/privacy-gateway/internal/gateway/policy"
+	"github.com/example/privacy-gateway/internal/shared/models"
+)
+
+// staticTokens accepts any token as a caller holding the read scope
+type staticTokens struct{}
+
+func (staticTokens) VerifyToken(context.Context, string) (*models.AccessTokenClaims, error) {
+	return &models.AccessTokenClaims{Scopes: []string{"read"}}, nil
+}
+
+func newHandler(t *testing.T) *forwardauth.Handler {
+	t.Helper()
+	rt, err := policy.NewRouter([]models.Policy{
+		{ID: "public", Route: "/public/*", RequiredScopes: []string{"read"}},
+		{ID: "admin", Route: "/admin/*", RequiredScopes: []string{"admin"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return forwardauth.NewHandler(forwardauth.Config{
+		Tokens: staticTokens{},
+		Router: func() *policy.Router { return rt },
+		Logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+	})
+}
+
+func TestForwardedPathIsCleaned(t *testing.T) {
+	h := newHandler(t)
+	cases := []struct {
+		header string
+		uri    string
+		status int
+		policy string
+	}{
+		{"X-Forwarded-Uri", "/public/a", http.StatusOK, "public"},
+		{"X-Forwarded-Uri", "/public//a/./b?x=1", http.StatusOK, "public"},
+		{"X-Original-URI", "/public/a/../b", http.StatusBadRequest, ""},
+		{"X-Forwarded-Uri", "/public/../admin/x", http.StatusBadRequest, ""},
+		{"X-Forwarded-Uri", "/public/%2e%2e/admin/x", http.StatusBadRequest, ""},
+		{"X-Forwarded-Uri", "/public%2F..%2Fadmin/x", http.StatusBadRequest, ""},
+		{"X-Forwarded-Uri", "/public/x%2fy", http.StatusBadRequest, ""},
+		{"X-Forwarded-Uri", "/public/..%5cadmin/x", http.StatusBadRequest, ""},
+		{"X-Original-URI", "/admin/x", http.StatusForbidden, ""},
+	}
+	for _, c := range cases {
+		r := httptest.NewRequest(http.MethodGet, "/v1/auth/forward", nil)
+		r.Header.Set("Authorization", "Bearer token")
+		r.Header.Set(c.header, c.uri)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != c.status {
+			t.Errorf("%s %s: status %d, want %d", c.header, c.uri, w.Code, c.status)
+		}
+		if got := w.Header().Get(forwardauth.HeaderPolicy); got != c.policy {
+			t.Errorf("%s %s: policy %q, want %q", c.header, c.uri, got, c.policy)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package gitops
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// commit is the checked-out revision
+type commit struct {
+	SHA     string
+	Author  string
+	Subject string
+	Time    time.Time
+}
+
+// checkout keeps a shallow clone of one branch up to date using the git
+// binary, so the gateway image needs git installed
+type checkout struct {
+	repo, branch, dir string
+}
+
+// update fetches the branch tip and returns its commit
+func (c *checkout) update(ctx context.Context) (commit, error) {
+	if _, err := os.Stat(filepath.Join(c.dir, ".git")); err != nil {
+		if err := os.RemoveAll(c.dir); err != nil {
+			return commit{}, err
+		}
+		if _, err := c.git(ctx, "", "clone", "--quiet", "--depth", "1", "--single-branch", "--branch", c.branch, c.repo, c.dir); err != nil {
+			return commit{}, err
+		}
+	} else {
+		steps := [][]string{
+			{"remote", "set-url", "origin", c.repo},
+			{"fetch", "--quiet", "--depth", "1", "origin", c.branch},
+			{"reset", "--quiet", "--hard", "FETCH_HEAD"},
+			{"clean", "--quiet", "-fdx"},
+		}
+		for _, args := range steps {
+			if _, err := c.git(ctx, c.dir, args...); err != nil {
+				return commit{}, err
+			}
+		}
+	}
+
+	out, err := c.git(ctx, c.dir, "log", "-1", "--format=%H%x00%an <%ae>%x00%ct%x00%s")
+	if err != nil {
+		return commit{}, err
+	}
+	fields := strings.SplitN(strings.TrimSpace(out), "\x00", 4)
+	if len(fields) != 4 {
+		return commit{}, fmt.Errorf("%w: unexpected git log output", ErrFetch)
+	}
+	var unix int64
+	fmt.Sscan(fields[2], &unix)
+	return commit{SHA: fields[0], Author: fields[1], Time: time.Unix(unix, 0).UTC(), Subject: fields[3]}, nil
+}
+
+func (c *checkout) git(ctx context.Context, dir string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	// Never wait on a credential prompt
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return "", fmt.Errorf("%w: git %s: %s", ErrFetch, args[0], strings.TrimSpace(stderr.String()))
+		}
+		return "", fmt.Errorf("%w: git %s: %v", ErrFetch, args[0], err)
+	}
+	return stdout.String(), nil
+}
@@ -0,0 +1,308 @@
+package gitops
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/example/privacy-gateway/internal/gateway/bundle"
+	"github.com/example/privacy-gateway/internal/gateway/policy"
+	"github.com/example/privacy-gateway/internal/shared/audit"
+	"github.com/example/privacy-gateway/internal/shared/models"
+	"github.com/example/privacy-gateway/internal/shared/observability"
+	"github.com/example/privacy-gateway/internal/shared/scheduler"
+)
+
+var (
+	ErrFetch          = errors.New("git fetch failed")
+	ErrInvalidContent = errors.New("invalid configuration in repository")
+)
+
+// Config configures syncing from a Git repository
+type Config struct {
+	Repo   string // Clone URL (https, with credentials if needed, or ssh)
+	Branch string // default "main"
+	Path   string // Directory within the repo holding the configuration (default the root)
+	Dir    string // Local checkout (default <tmp>/gateway-gitops)
+	// Interval is the polling interval (default 1m). Pushes can also trigger
+	// a sync through WebhookHandler.
+	Interval time.Duration
+	// WebhookSecret authenticates push webhooks (GitHub HMAC signature or
+	// GitLab token)
+	WebhookSecret string
+	Store         bundle.Store
+	// Audit and Logger are optional
+	Audit  audit.Sink
+	Logger *slog.Logger
+}
+
+// Status is the outcome of the latest sync
+type Status struct {
+	Repo        string         `json:"repo"`
+	Branch      string         `json:"branch"`
+	Path        string         `json:"path,omitempty"`
+	Commit      string         `json:"commit,omitempty"` // Last applied commit
+	Author      string         `json:"author,omitempty"`
+	Subject     string         `json:"subject,omitempty"`
+	LastSync    *time.Time     `json:"last_sync,omitempty"` // Last successful sync
+	LastAttempt *time.Time     `json:"last_attempt,omitempty"`
+	LastError   string         `json:"last_error,omitempty"`
+	Policies    bundle.Changes `json:"policies"` // Of the last sync that wrote anything
+	Issuers     bundle.Changes `json:"issuers"`
+	Syncs       int64          `json:"syncs"`
+	Failures    int64          `json:"failures"`
+}
+
+// Syncer makes a Git branch the source of truth for policies and trusted
+// issuers, so changes go through code review. Each sync fetches the branch,
+// validates the whole configuration and applies what differs from the
+// database. A commit that fails validation leaves the current configuration
+// in place. Policies and issuers edited through the admin API are reverted
+// on the next sync; ones missing from the repository are reported as extra
+// but not deleted.
+//
+// Repository layout under Path:
+//
+//	policies/<id>.json   one policy per file, as accepted by PUT /admin/v1/policies/{id}
+//	issuers.json         array of trusted issuers
+type Syncer struct {
+	cfg      Config
+	audit    *audit.Recorder
+	checkout *checkout
+	queued   atomic.Bool
+
+	mu           sync.Mutex // Serializes syncs
+	failedCommit string     // Last commit that failed validation, audited once
+
+	statusMu sync.Mutex
+	status   Status
+}
+
+// New creates a syncer
+func New(cfg Config) (*Syncer, error) {
+	if cfg.Repo == "" || cfg.Store == nil {
+		return nil, errors.New("gitops sync needs a repository and a store")
+	}
+	if cfg.Branch == "" {
+		cfg.Branch = "main"
+	}
+	if cfg.Dir == "" {
+		cfg.Dir = filepath.Join(os.TempDir(), "gateway-gitops")
+	}
+	if cfg.Interval == 0 {
+		cfg.Interval = time.Minute
+	}
+	cfg.Logger = observability.Component(cfg.Logger, observability.ComponentConfig)
+	cfg.Path = strings.Trim(filepath.Clean("/"+cfg.Path), "/")
+	return &Syncer{
+		cfg:      cfg,
+		audit:    audit.NewRecorder(cfg.Audit, cfg.Logger, nil),
+		checkout: &checkout{repo: cfg.Repo, branch: cfg.Branch, dir: cfg.Dir},
+		status:   Status{Repo: redactURL(cfg.Repo), Branch: cfg.Branch, Path: cfg.Path},
+	}, nil
+}
+
+// Job returns the polling job for the singleton scheduler
+func (s *Syncer) Job() scheduler.Job {
+	return scheduler.Job{
+		Name:     "gitops-sync",
+		Schedule: "@every " + s.cfg.Interval.String(),
+		Timeout:  2 * time.Minute,
+		Run:      s.Sync,
+	}
+}
+
+// Trigger starts a sync in the background. Triggers that arrive while one
+// is waiting to start are merged into it.
+func (s *Syncer) Trigger() {
+	if !s.queued.CompareAndSwap(false, true) {
+		return
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+		defer cancel()
+		_ = s.sync(ctx, func() { s.queued.Store(false) })
+	}()
+}
+
+// Sync fetches the branch and applies it
+func (s *Syncer) Sync(ctx context.Context) error {
+	return s.sync(ctx, nil)
+}
+
+func (s *Syncer) sync(ctx context.Context, started func()) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if started != nil {
+		started()
+	}
+
+	now := time.Now().UTC()
+	c, plan, err := s.apply(ctx)
+
+	s.statusMu.Lock()
+	s.status.LastAttempt = &now
+	s.status.Syncs++
+	if err != nil {
+		s.status.Failures++
+		s.status.LastError = err.Error()
+	} else {
+		s.status.LastError = ""
+		s.status.LastSync = &now
+		s.status.Commit, s.status.Author, s.status.Subject = c.SHA, c.Author, c.Subject
+		if !plan.Empty() {
+			s.status.Policies, s.status.Issuers = plan.Policies, plan.Issuers
+		}
+	}
+	s.statusMu.Unlock()
+
+	if err != nil {
+		s.cfg.Logger.Error("gitops sync failed", "repo", redactURL(s.cfg.Repo), "commit", c.SHA, "error", err)
+	}
+	return err
+}
+
+// apply fetches, validates and writes one commit. The commit is returned
+// whenever it was fetched, so failures can name it.
+func (s *Syncer) apply(ctx context.Context) (commit, *bundle.Plan, error) {
+	c, err := s.checkout.update(ctx)
+	if err != nil {
+		return c, nil, err
+	}
+	b, err := load(filepath.Join(s.cfg.Dir, s.cfg.Path))
+	if err == nil {
+		err = b.Validate()
+	}
+	if err == nil {
+		// Catches bad route templates and parameter patterns before they
+		// reach the live router
+		_, err = policy.NewRouter(b.Policies)
+	}
+	if err != nil {
+		if !errors.Is(err, ErrInvalidContent) {
+			err = fmt.Errorf("%w: %v", ErrInvalidContent, err)
+		}
+		if c.SHA != s.failedCommit {
+			s.failedCommit = c.SHA
+			s.recordSync(ctx, c, nil, err)
+		}
+		return c, nil, err
+	}
+	s.failedCommit = ""
+
+	plan, err := bundle.NewPlan(ctx, s.cfg.Store, b)
+	if err != nil {
+		return c, nil, err
+	}
+	if plan.Empty() {
+		return c, plan, nil
+	}
+	if err := plan.Apply(ctx, s.cfg.Store); err != nil {
+		s.recordSync(ctx, c, plan, err)
+		return c, nil, err
+	}
+	s.recordSync(ctx, c, plan, nil)
+	return c, plan, nil
+}
+
+// load reads the configuration under dir
+func load(dir string) (bundle.Bundle, error) {
+	b := bundle.Bundle{Version: bundle.Version}
+	files, err := filepath.Glob(filepath.Join(dir, "policies", "*.json"))
+	if err != nil {
+		return b, err
+	}
+	sort.Strings(files)
+	for _, file := range files {
+		var pol models.Policy
+		if err := decodeFile(file, &pol); err != nil {
+			return b, err
+		}
+		id := strings.TrimSuffix(filepath.Base(file), ".json")
+		if pol.ID == "" {
+			pol.ID = id
+		}
+		if pol.ID != id {
+			return b, fmt.Errorf("%w: policies/%s.json has id %q", ErrInvalidContent, id, pol.ID)
+		}
+		b.Policies = append(b.Policies, pol)
+	}
+
+	file := filepath.Join(dir, "issuers.json")
+	if _, err := os.Stat(file); err == nil {
+		if err := decodeFile(file, &b.Issuers); err != nil {
+			return b, err
+		}
+	}
+	if len(b.Policies) == 0 && len(b.Issuers) == 0 {
+		// Most likely a wrong Path; applying nothing is harmless, but say so
+		return b, fmt.Errorf("%w: no policies or issuers under %q", ErrInvalidContent, dir)
+	}
+	return b, nil
+}
+
+// decodeFile decodes one JSON file, rejecting unknown fields so a typo
+// fails the sync instead of silently dropping a setting
+func decodeFile(file string, v interface{}) error {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return fmt.Errorf("%w: %s: %v", ErrInvalidContent, filepath.Base(file), err)
+	}
+	return nil
+}
+
+// recordSync audits a sync of commit c
+func (s *Syncer) recordSync(ctx context.Context, c commit, plan *bundle.Plan, err error) {
+	meta := map[string]interface{}{
+		"commit":         c.SHA,
+		"commit_author":  c.Author,
+		"commit_subject": c.Subject,
+		"path":           s.cfg.Path,
+	}
+	if plan != nil {
+		meta["policies_created"] = plan.Policies.Created
+		meta["policies_updated"] = plan.Policies.Updated
+		meta["issuers_created"] = plan.Issuers.Created
+		meta["issuers_updated"] = plan.Issuers.Updated
+	}
+	outcome := "success"
+	if err != nil {
+		outcome = "failure"
+		meta["error"] = err.Error()
+	}
+	s.audit.Record(ctx, "gitops.sync", redactURL(s.cfg.Repo)+"@"+s.cfg.Branch, "gitops", outcome, meta)
+}
+
+// Status returns the latest sync outcome
+func (s *Syncer) Status() Status {
+	s.statusMu.Lock()
+	defer s.statusMu.Unlock()
+	return s.status
+}
+
+// redactURL drops credentials from a clone URL
+func redactURL(repo string) string {
+	scheme, rest, ok := strings.Cut(repo, "://")
+	if !ok {
+		return repo
+	}
+	if at := strings.LastIndex(rest, "@"); at >= 0 && at < strings.IndexByte(rest+"/", '/') {
+		rest = rest[at+1:]
+	}
+	return scheme + "://" + rest
+}
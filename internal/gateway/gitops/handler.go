@@ -0,0 +1,104 @@
+package gitops
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/example/privacy-gateway/internal/shared/httpx"
+)
+
+// maxWebhookBody caps a webhook payload
+const maxWebhookBody = 1 << 20
+
+// Handler serves the admin GitOps endpoints:
+//
+//	GET  /admin/v1/gitops        status of the latest sync
+//	POST /admin/v1/gitops/sync   sync now and return the status
+func (s *Syncer) Handler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		action := strings.Trim(strings.TrimPrefix(r.URL.Path, "/admin/v1/gitops"), "/")
+		switch {
+		case action == "" && r.Method == http.MethodGet:
+			httpx.WriteJSON(w, http.StatusOK, s.Status())
+		case action == "sync" && r.Method == http.MethodPost:
+			err := s.Sync(r.Context())
+			switch {
+			case errors.Is(err, ErrInvalidContent):
+				httpx.WriteJSON(w, http.StatusUnprocessableEntity, httpx.ErrorResponse{Error: err.Error()})
+			case errors.Is(err, ErrFetch):
+				httpx.WriteJSON(w, http.StatusBadGateway, httpx.ErrorResponse{Error: err.Error()})
+			case err != nil:
+				httpx.WriteJSON(w, http.StatusInternalServerError, httpx.ErrorResponse{Error: "sync failed"})
+			default:
+				httpx.WriteJSON(w, http.StatusOK, s.Status())
+			}
+		default:
+			httpx.WriteJSON(w, http.StatusNotFound, httpx.ErrorResponse{Error: "not found"})
+		}
+	}
+}
+
+// WebhookHandler accepts push webhooks from GitHub (X-Hub-Signature-256)
+// or GitLab (X-Gitlab-Token) and triggers a sync for pushes to the synced
+// branch. It authenticates with WebhookSecret rather than an admin session,
+// so mount it outside the admin API.
+func (s *Syncer) WebhookHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			httpx.WriteJSON(w, http.StatusMethodNotAllowed, httpx.ErrorResponse{Error: "method not allowed"})
+			return
+		}
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxWebhookBody))
+		if err != nil {
+			httpx.WriteJSON(w, http.StatusBadRequest, httpx.ErrorResponse{Error: "unreadable body"})
+			return
+		}
+		if !s.authenticWebhook(r, body) {
+			httpx.WriteJSON(w, http.StatusUnauthorized, httpx.ErrorResponse{Error: "invalid webhook signature"})
+			return
+		}
+		if r.Header.Get("X-GitHub-Event") == "ping" {
+			httpx.WriteJSON(w, http.StatusOK, map[string]string{"status": "pong"})
+			return
+		}
+		var push struct {
+			Ref string `json:"ref"`
+		}
+		if err := json.Unmarshal(body, &push); err != nil {
+			httpx.WriteJSON(w, http.StatusBadRequest, httpx.ErrorResponse{Error: "invalid push payload"})
+			return
+		}
+		if push.Ref != "refs/heads/"+s.cfg.Branch {
+			httpx.WriteJSON(w, http.StatusOK, map[string]string{"status": "ignored"})
+			return
+		}
+		s.Trigger()
+		httpx.WriteJSON(w, http.StatusAccepted, map[string]string{"status": "sync started"})
+	}
+}
+
+func (s *Syncer) authenticWebhook(r *http.Request, body []byte) bool {
+	if s.cfg.WebhookSecret == "" {
+		return false
+	}
+	if sig, ok := strings.CutPrefix(r.Header.Get("X-Hub-Signature-256"), "sha256="); ok {
+		got, err := hex.DecodeString(sig)
+		if err != nil {
+			return false
+		}
+		mac := hmac.New(sha256.New, []byte(s.cfg.WebhookSecret))
+		mac.Write(body)
+		return hmac.Equal(got, mac.Sum(nil))
+	}
+	if token := r.Header.Get("X-Gitlab-Token"); token != "" {
+		return subtle.ConstantTimeCompare([]byte(token), []byte(s.cfg.WebhookSecret)) == 1
+	}
+	return false
+}
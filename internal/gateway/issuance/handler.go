@@ -0,0 +1,209 @@
+package issuance
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/example/privacy-gateway/internal/shared/httpx"
+)
+
+// oauthError is the OAuth 2.0 / OID4VCI error body
+type oauthError struct {
+	Error       string `json:"error"`
+	Description string `json:"error_description,omitempty"`
+}
+
+// offerRequest is the admin request to create an offer
+type offerRequest struct {
+	Template string                 `json:"template"`
+	Claims   map[string]interface{} `json:"claims,omitempty"`
+	TxCode   string                 `json:"tx_code,omitempty"`
+}
+
+// offerResponse is returned to the admin; the URI is shown to the holder
+type offerResponse struct {
+	CredentialOffer    map[string]interface{} `json:"credential_offer"`
+	CredentialOfferURI string                 `json:"credential_offer_uri"`
+	ExpiresAt          int64                  `json:"expires_at"`
+}
+
+// credentialRequest is the OID4VCI credential request
+type credentialRequest struct {
+	Format                    string `json:"format,omitempty"`
+	CredentialConfigurationID string `json:"credential_configuration_id,omitempty"`
+	Proof                     *struct {
+		ProofType string `json:"proof_type"`
+		JWT       string `json:"jwt"`
+	} `json:"proof"`
+}
+
+// Handler serves the OpenID4VCI endpoints:
+//
+//	GET  /.well-known/openid-credential-issuer    credential issuer metadata
+//	GET  /.well-known/oauth-authorization-server  token endpoint metadata
+//	POST /oid4vci/token                           pre-authorized code grant
+//	POST /oid4vci/credential                      credential request with key proof
+//
+// OffersHandler (POST /admin/v1/issuance/offers) is an admin endpoint and must be
+// mounted behind admin authentication.
+type Handler struct {
+	issuer *Issuer
+}
+
+// NewHandler creates the OID4VCI handler
+func NewHandler(issuer *Issuer) *Handler {
+	return &Handler{issuer: issuer}
+}
+
+// ServeHTTP dispatches the wallet-facing endpoints
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.URL.Path == "/.well-known/openid-credential-issuer" && r.Method == http.MethodGet:
+		httpx.WriteJSON(w, http.StatusOK, h.issuer.Metadata())
+	case r.URL.Path == "/.well-known/oauth-authorization-server" && r.Method == http.MethodGet:
+		base := h.issuer.cfg.IssuerURL
+		httpx.WriteJSON(w, http.StatusOK, map[string]interface{}{
+			"issuer":                base,
+			"token_endpoint":        base + "/oid4vci/token",
+			"grant_types_supported": []string{"urn:ietf:params:oauth:grant-type:pre-authorized_code"},
+			"pre-authorized_grant_anonymous_access_supported": true,
+		})
+	case r.URL.Path == "/oid4vci/token" && r.Method == http.MethodPost:
+		h.token(w, r)
+	case r.URL.Path == "/oid4vci/credential" && r.Method == http.MethodPost:
+		h.credential(w, r)
+	default:
+		httpx.WriteJSON(w, http.StatusNotFound, httpx.ErrorResponse{Error: "not found"})
+	}
+}
+
+// Metadata returns the credential issuer metadata with one configuration per template
+func (i *Issuer) Metadata() map[string]interface{} {
+	configs := make(map[string]interface{}, len(i.templates))
+	for id, t := range i.templates {
+		cfg := map[string]interface{}{
+			"format": "jwt_vc_json",
+			"scope":  id,
+			"cryptographic_binding_methods_supported": []string{"did:key", "did:web"},
+			"credential_signing_alg_values_supported": []string{"EdDSA"},
+			"proof_types_supported": map[string]interface{}{
+				"jwt": map[string]interface{}{"proof_signing_alg_values_supported": []string{"EdDSA"}},
+			},
+			"credential_definition": map[string]interface{}{
+				"type": append([]string{"VerifiableCredential"}, t.Types...),
+			},
+		}
+		if t.Name != "" {
+			cfg["display"] = []map[string]string{{"name": t.Name}}
+		}
+		configs[id] = cfg
+	}
+	base := i.cfg.IssuerURL
+	return map[string]interface{}{
+		"credential_issuer":                   base,
+		"credential_endpoint":                 base + "/oid4vci/credential",
+		"authorization_servers":               []string{base},
+		"credential_configurations_supported": configs,
+	}
+}
+
+func (h *Handler) token(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, 16<<10)
+	if err := r.ParseForm(); err != nil {
+		httpx.WriteJSON(w, http.StatusBadRequest, oauthError{Error: "invalid_request"})
+		return
+	}
+	if r.PostForm.Get("grant_type") != "urn:ietf:params:oauth:grant-type:pre-authorized_code" {
+		httpx.WriteJSON(w, http.StatusBadRequest, oauthError{Error: "unsupported_grant_type"})
+		return
+	}
+	code := r.PostForm.Get("pre-authorized_code")
+	if code == "" {
+		httpx.WriteJSON(w, http.StatusBadRequest, oauthError{Error: "invalid_request", Description: "pre-authorized_code is required"})
+		return
+	}
+
+	resp, err := h.issuer.ExchangeCode(r.Context(), code, r.PostForm.Get("tx_code"))
+	if errors.Is(err, ErrInvalidGrant) {
+		httpx.WriteJSON(w, http.StatusBadRequest, oauthError{Error: "invalid_grant", Description: err.Error()})
+		return
+	}
+	if err != nil {
+		httpx.WriteJSON(w, http.StatusInternalServerError, oauthError{Error: "server_error"})
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	httpx.WriteJSON(w, http.StatusOK, resp)
+}
+
+func (h *Handler) credential(w http.ResponseWriter, r *http.Request) {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || token == "" {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		httpx.WriteJSON(w, http.StatusUnauthorized, oauthError{Error: "invalid_token"})
+		return
+	}
+	var req credentialRequest
+	if err := httpx.DecodeJSONLimit(r, &req, 64<<10); err != nil {
+		httpx.WriteJSON(w, http.StatusBadRequest, oauthError{Error: "invalid_credential_request"})
+		return
+	}
+	if req.Format != "" && req.Format != "jwt_vc_json" {
+		httpx.WriteJSON(w, http.StatusBadRequest, oauthError{Error: "unsupported_credential_format"})
+		return
+	}
+	if req.Proof == nil || req.Proof.ProofType != "jwt" || req.Proof.JWT == "" {
+		httpx.WriteJSON(w, http.StatusBadRequest, oauthError{Error: "invalid_proof", Description: "a jwt proof is required"})
+		return
+	}
+
+	cred, err := h.issuer.Issue(r.Context(), token, req.CredentialConfigurationID, req.Proof.JWT)
+	switch {
+	case errors.Is(err, ErrInvalidToken):
+		w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+		httpx.WriteJSON(w, http.StatusUnauthorized, oauthError{Error: "invalid_token"})
+	case errors.Is(err, ErrInvalidProof):
+		httpx.WriteJSON(w, http.StatusBadRequest, oauthError{Error: "invalid_proof", Description: err.Error()})
+	case errors.Is(err, ErrUnknownTemplate):
+		httpx.WriteJSON(w, http.StatusBadRequest, oauthError{Error: "unsupported_credential_type"})
+	case err != nil:
+		httpx.WriteJSON(w, http.StatusInternalServerError, oauthError{Error: "server_error"})
+	default:
+		w.Header().Set("Cache-Control", "no-store")
+		httpx.WriteJSON(w, http.StatusOK, map[string]string{"credential": cred})
+	}
+}
+
+// OffersHandler serves POST /admin/v1/issuance/offers (admin). actor identifies
+// the caller in the audit trail.
+func OffersHandler(issuer *Issuer, actor func(*http.Request) string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			httpx.WriteJSON(w, http.StatusMethodNotAllowed, httpx.ErrorResponse{Error: "method not allowed"})
+			return
+		}
+		var req offerRequest
+		if err := httpx.DecodeJSON(r, &req); err != nil {
+			httpx.WriteJSON(w, http.StatusBadRequest, httpx.ErrorResponse{Error: "invalid request body"})
+			return
+		}
+		who := "admin"
+		if actor != nil {
+			who = actor(r)
+		}
+		offer, err := issuer.CreateOffer(r.Context(), req.Template, req.Claims, req.TxCode, who)
+		switch {
+		case errors.Is(err, ErrUnknownTemplate), errors.Is(err, ErrMissingClaim):
+			httpx.WriteJSON(w, http.StatusBadRequest, httpx.ErrorResponse{Error: err.Error()})
+		case err != nil:
+			httpx.WriteJSON(w, http.StatusInternalServerError, httpx.ErrorResponse{Error: "failed to create offer"})
+		default:
+			httpx.WriteJSON(w, http.StatusCreated, offerResponse{
+				CredentialOffer:    offer.CredentialOffer,
+				CredentialOfferURI: offer.URI(),
+				ExpiresAt:          offer.ExpiresAt.Unix(),
+			})
+		}
+	}
+}
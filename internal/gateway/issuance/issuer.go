@@ -0,0 +1,331 @@
+package issuance
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+
+	"github.com/example/privacy-gateway/internal/gateway/did"
+	"github.com/example/privacy-gateway/internal/shared/audit"
+	"github.com/example/privacy-gateway/internal/shared/clock"
+	"github.com/example/privacy-gateway/internal/shared/crypto"
+	"github.com/example/privacy-gateway/internal/shared/models"
+	"github.com/example/privacy-gateway/internal/shared/observability"
+)
+
+var (
+	ErrUnknownTemplate = errors.New("unknown credential template")
+	ErrMissingClaim    = errors.New("missing offer claim")
+	ErrInvalidGrant    = errors.New("invalid or expired pre-authorized code")
+	ErrInvalidToken    = errors.New("invalid or expired access token")
+	ErrInvalidProof    = errors.New("invalid proof of possession")
+	ErrInvalidConfig   = errors.New("invalid issuance config")
+)
+
+// Template describes an attestation the gateway can issue. Claims are fixed
+// credentialSubject values; OfferClaims must be supplied per offer (e.g. the
+// member's org). The holder DID is always set as credentialSubject.id.
+type Template struct {
+	ID              string                 `json:"id"`
+	Name            string                 `json:"name,omitempty"`
+	Types           []string               `json:"types"`
+	Claims          map[string]interface{} `json:"claims,omitempty"`
+	OfferClaims     []string               `json:"offer_claims,omitempty"`
+	ValiditySeconds int64                  `json:"validity_seconds,omitempty"` // Default 30 days
+}
+
+// StatusAllocator assigns revocation status entries to issued credentials
+type StatusAllocator interface {
+	// Allocate returns the credentialStatus entry for jti; the entry is
+	// only needed until the credential expires
+	Allocate(ctx context.Context, jti string, expires time.Time) (map[string]interface{}, error)
+}
+
+// Config configures the issuer
+type Config struct {
+	IssuerURL   string // Public credential issuer identifier, e.g. https://gateway.example.com
+	DID         string // Gateway DID that signs credentials
+	KeyID       string // Verification method ID (default DID + "#key-1")
+	SigningKey  ed25519.PrivateKey
+	Templates   []Template
+	CodeTTL     time.Duration   // Pre-authorized code lifetime (default 10m)
+	TokenTTL    time.Duration   // Access token and c_nonce lifetime (default 5m)
+	ProofMaxAge time.Duration   // Oldest accepted proof iat (default 5m)
+	Resolver    did.Resolver    // Resolves holder DIDs (default did:key only)
+	Audit       audit.Sink      // Optional; events are always logged
+	Status      StatusAllocator // Optional; adds credentialStatus to credentials
+	// Clock stamps credential iat/exp, offer expiry and proof age checks
+	// (default clock.Real). Codes and tokens are expired by Redis TTLs,
+	// which follow the Redis server's clock.
+	Clock  clock.Clock
+	Logger *slog.Logger
+}
+
+// Issuer implements the OpenID4VCI pre-authorized code flow for attestations
+// signed with the gateway DID. Codes and tokens are single-use and live in
+// Redis, so any replica can serve any step.
+type Issuer struct {
+	client    *redis.Client
+	cfg       Config
+	audit     *audit.Recorder
+	templates map[string]Template
+}
+
+// offerState is stored under a pre-authorized code and then an access token
+type offerState struct {
+	Template   string                 `json:"template"`
+	Claims     map[string]interface{} `json:"claims,omitempty"`
+	TxCodeHash string                 `json:"tx_code_hash,omitempty"`
+	Nonce      string                 `json:"c_nonce,omitempty"`
+}
+
+// NewIssuer creates an issuer
+func NewIssuer(client *redis.Client, cfg Config) (*Issuer, error) {
+	if cfg.IssuerURL == "" || cfg.DID == "" || len(cfg.SigningKey) != ed25519.PrivateKeySize {
+		return nil, fmt.Errorf("%w: issuer URL, DID and signing key are required", ErrInvalidConfig)
+	}
+	cfg.IssuerURL = strings.TrimSuffix(cfg.IssuerURL, "/")
+	if cfg.KeyID == "" {
+		cfg.KeyID = cfg.DID + "#key-1"
+	}
+	if cfg.CodeTTL == 0 {
+		cfg.CodeTTL = 10 * time.Minute
+	}
+	if cfg.TokenTTL == 0 {
+		cfg.TokenTTL = 5 * time.Minute
+	}
+	if cfg.ProofMaxAge == 0 {
+		cfg.ProofMaxAge = 5 * time.Minute
+	}
+	if cfg.Resolver == nil {
+		cfg.Resolver = did.KeyResolver{}
+	}
+	cfg.Clock = clock.Or(cfg.Clock)
+	cfg.Logger = observability.Component(cfg.Logger, observability.ComponentIssuance)
+
+	templates := make(map[string]Template, len(cfg.Templates))
+	for _, t := range cfg.Templates {
+		if t.ID == "" || len(t.Types) == 0 {
+			return nil, fmt.Errorf("%w: template needs an id and at least one type", ErrInvalidConfig)
+		}
+		if t.ValiditySeconds == 0 {
+			t.ValiditySeconds = int64((30 * 24 * time.Hour).Seconds())
+		}
+		templates[t.ID] = t
+	}
+	return &Issuer{client: client, cfg: cfg, audit: audit.NewRecorder(cfg.Audit, cfg.Logger, cfg.Clock), templates: templates}, nil
+}
+
+func codeKey(code string) string {
+	return "vci:code|" + crypto.HashSecret(code)
+}
+
+func tokenKey(token string) string {
+	return "vci:at|" + crypto.HashSecret(token)
+}
+
+// Offer is a created credential offer
+type Offer struct {
+	Code      string
+	ExpiresAt time.Time
+	// CredentialOffer is the OID4VCI credential_offer object
+	CredentialOffer map[string]interface{}
+}
+
+// URI returns the openid-credential-offer:// link wallets scan
+func (o Offer) URI() string {
+	raw, _ := json.Marshal(o.CredentialOffer)
+	return "openid-credential-offer://?credential_offer=" + url.QueryEscape(string(raw))
+}
+
+// CreateOffer creates a pre-authorized offer for a template. txCode, when
+// set, is a PIN delivered to the holder out of band and required at the
+// token endpoint.
+func (i *Issuer) CreateOffer(ctx context.Context, templateID string, claims map[string]interface{}, txCode, actor string) (*Offer, error) {
+	tmpl, ok := i.templates[templateID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownTemplate, templateID)
+	}
+	for _, name := range tmpl.OfferClaims {
+		if _, ok := claims[name]; !ok {
+			return nil, fmt.Errorf("%w: %s", ErrMissingClaim, name)
+		}
+	}
+
+	code, err := crypto.RandomToken(32)
+	if err != nil {
+		return nil, err
+	}
+	state := offerState{Template: templateID, Claims: claims}
+	if txCode != "" {
+		state.TxCodeHash = crypto.HashSecret(txCode)
+	}
+	if err := i.save(ctx, codeKey(code), state, i.cfg.CodeTTL); err != nil {
+		return nil, err
+	}
+
+	grant := map[string]interface{}{"pre-authorized_code": code}
+	if txCode != "" {
+		grant["tx_code"] = map[string]interface{}{"input_mode": "numeric", "length": len(txCode)}
+	}
+	i.audit.Record(ctx, "issuance.offer_created", "", actor, "success", map[string]interface{}{"template": templateID})
+	return &Offer{
+		Code:      code,
+		ExpiresAt: i.cfg.Clock.Now().Add(i.cfg.CodeTTL),
+		CredentialOffer: map[string]interface{}{
+			"credential_issuer":            i.cfg.IssuerURL,
+			"credential_configuration_ids": []string{templateID},
+			"grants": map[string]interface{}{
+				"urn:ietf:params:oauth:grant-type:pre-authorized_code": grant,
+			},
+		},
+	}, nil
+}
+
+// TokenResponse is the token endpoint result
+type TokenResponse struct {
+	AccessToken     string `json:"access_token"`
+	TokenType       string `json:"token_type"`
+	ExpiresIn       int64  `json:"expires_in"`
+	CNonce          string `json:"c_nonce"`
+	CNonceExpiresIn int64  `json:"c_nonce_expires_in"`
+}
+
+// ExchangeCode redeems a pre-authorized code (once) for an access token
+func (i *Issuer) ExchangeCode(ctx context.Context, code, txCode string) (*TokenResponse, error) {
+	data, err := i.client.GetDel(ctx, codeKey(code)).Bytes()
+	if err == redis.Nil {
+		i.audit.Record(ctx, "issuance.token_denied", "", "", "denied", map[string]interface{}{"reason": "unknown code"})
+		return nil, ErrInvalidGrant
+	}
+	if err != nil {
+		return nil, err
+	}
+	var state offerState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, err
+	}
+	if state.TxCodeHash != "" && subtle.ConstantTimeCompare([]byte(state.TxCodeHash), []byte(crypto.HashSecret(txCode))) != 1 {
+		// The code is burned; a guessed PIN can't be retried
+		i.audit.Record(ctx, "issuance.token_denied", "", "", "denied", map[string]interface{}{"template": state.Template, "reason": "tx_code mismatch"})
+		return nil, fmt.Errorf("%w: tx_code mismatch", ErrInvalidGrant)
+	}
+
+	token, err := crypto.RandomToken(32)
+	if err != nil {
+		return nil, err
+	}
+	if state.Nonce, err = crypto.RandomToken(16); err != nil {
+		return nil, err
+	}
+	if err := i.save(ctx, tokenKey(token), state, i.cfg.TokenTTL); err != nil {
+		return nil, err
+	}
+	ttl := int64(i.cfg.TokenTTL.Seconds())
+	return &TokenResponse{AccessToken: token, TokenType: "Bearer", ExpiresIn: ttl, CNonce: state.Nonce, CNonceExpiresIn: ttl}, nil
+}
+
+// Issue verifies the holder's proof and signs the credential. The access
+// token is consumed even when the proof is invalid, so a failed request
+// must restart from a new offer.
+func (i *Issuer) Issue(ctx context.Context, token, configurationID, proofJWT string) (string, error) {
+	data, err := i.client.GetDel(ctx, tokenKey(token)).Bytes()
+	if err == redis.Nil {
+		return "", ErrInvalidToken
+	}
+	if err != nil {
+		return "", err
+	}
+	var state offerState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return "", err
+	}
+	if configurationID != "" && configurationID != state.Template {
+		return "", fmt.Errorf("%w: credential_configuration_id not in offer", ErrUnknownTemplate)
+	}
+	tmpl, ok := i.templates[state.Template]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrUnknownTemplate, state.Template)
+	}
+
+	holder, err := i.verifyProof(ctx, proofJWT, state.Nonce)
+	if err != nil {
+		i.audit.Record(ctx, "issuance.credential_denied", "", "", "denied", map[string]interface{}{"template": tmpl.ID, "reason": err.Error()})
+		return "", err
+	}
+
+	jti := uuid.NewString()
+	now := i.cfg.Clock.Now()
+	var status map[string]interface{}
+	if i.cfg.Status != nil {
+		expires := now.Add(time.Duration(tmpl.ValiditySeconds) * time.Second)
+		if status, err = i.cfg.Status.Allocate(ctx, jti, expires); err != nil {
+			return "", fmt.Errorf("allocate status entry: %w", err)
+		}
+	}
+	cred, err := i.sign(tmpl, state.Claims, holder, jti, status, now)
+	if err != nil {
+		return "", err
+	}
+	i.audit.Record(ctx, "issuance.credential_issued", holder, i.cfg.DID, "success", map[string]interface{}{"template": tmpl.ID, "jti": jti})
+	return cred, nil
+}
+
+// sign builds and signs the JWT-VC
+func (i *Issuer) sign(tmpl Template, offerClaims map[string]interface{}, holder, jti string, status map[string]interface{}, now time.Time) (string, error) {
+	subject := map[string]interface{}{}
+	for k, v := range offerClaims {
+		subject[k] = v
+	}
+	// Template claims win over offer claims so an offer can't override them
+	for k, v := range tmpl.Claims {
+		subject[k] = v
+	}
+	subject["id"] = holder
+
+	claims := models.CredentialClaims{
+		Issuer:   i.cfg.DID,
+		Subject:  holder,
+		IssuedAt: now.Unix(),
+		Expiry:   now.Add(time.Duration(tmpl.ValiditySeconds) * time.Second).Unix(),
+		JWTID:    jti,
+		VC: map[string]interface{}{
+			"@context":          []string{"https://www.w3.org/2018/credentials/v1"},
+			"type":              append([]string{"VerifiableCredential"}, tmpl.Types...),
+			"credentialSubject": subject,
+		},
+	}
+	if status != nil {
+		claims.VC["credentialStatus"] = status
+	}
+	header, err := json.Marshal(map[string]string{"alg": "EdDSA", "typ": "JWT", "kid": i.cfg.KeyID})
+	if err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	input := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	sig := ed25519.Sign(i.cfg.SigningKey, []byte(input))
+	return input + "." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
+
+func (i *Issuer) save(ctx context.Context, key string, state offerState, ttl time.Duration) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	return i.client.Set(ctx, key, data, ttl).Err()
+}
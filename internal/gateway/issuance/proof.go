@@ -0,0 +1,93 @@
+package issuance
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/example/privacy-gateway/internal/gateway/did"
+	"github.com/example/privacy-gateway/internal/shared/jws"
+)
+
+// proofType is the JWT typ of an OID4VCI key proof
+const proofType = "openid4vci-proof+jwt"
+
+// verifyProof checks a holder key proof JWT and returns the holder DID. The
+// proof must be signed (EdDSA) by an authentication key of the DID named in
+// kid, be addressed to this issuer, carry the c_nonce and be recent.
+func (i *Issuer) verifyProof(ctx context.Context, proof, nonce string) (string, error) {
+	parts := strings.Split(proof, ".")
+	if len(parts) != 3 {
+		return "", fmt.Errorf("%w: not a JWT", ErrInvalidProof)
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Typ string `json:"typ"`
+		Kid string `json:"kid"`
+	}
+	if err := jws.DecodeSegment(parts[0], &header); err != nil {
+		return "", fmt.Errorf("%w: header: %v", ErrInvalidProof, err)
+	}
+	if header.Typ != proofType {
+		return "", fmt.Errorf("%w: typ must be %s", ErrInvalidProof, proofType)
+	}
+	if header.Alg != "EdDSA" {
+		return "", fmt.Errorf("%w: unsupported alg %s", ErrInvalidProof, header.Alg)
+	}
+	holder, _, ok := strings.Cut(header.Kid, "#")
+	if !ok || !strings.HasPrefix(holder, "did:") {
+		return "", fmt.Errorf("%w: kid must be a DID URL", ErrInvalidProof)
+	}
+
+	var claims struct {
+		Aud   interface{} `json:"aud"`
+		Iat   int64       `json:"iat"`
+		Nonce string      `json:"nonce"`
+	}
+	if err := jws.DecodeSegment(parts[1], &claims); err != nil {
+		return "", fmt.Errorf("%w: claims: %v", ErrInvalidProof, err)
+	}
+	if !audienceIncludes(claims.Aud, i.cfg.IssuerURL) {
+		return "", fmt.Errorf("%w: aud must be %s", ErrInvalidProof, i.cfg.IssuerURL)
+	}
+	if claims.Nonce == "" || subtle.ConstantTimeCompare([]byte(claims.Nonce), []byte(nonce)) != 1 {
+		return "", fmt.Errorf("%w: c_nonce mismatch", ErrInvalidProof)
+	}
+	iat := time.Unix(claims.Iat, 0)
+	if now := i.cfg.Clock.Now(); iat.Before(now.Add(-i.cfg.ProofMaxAge)) || iat.After(now.Add(time.Minute)) {
+		return "", fmt.Errorf("%w: iat out of range", ErrInvalidProof)
+	}
+
+	doc, err := i.cfg.Resolver.Resolve(ctx, holder, did.ResolveOptions{})
+	if err != nil {
+		return "", fmt.Errorf("%w: resolve %s: %v", ErrInvalidProof, holder, err)
+	}
+	pub, err := doc.AuthenticationKey(header.Kid)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidProof, err)
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil || !ed25519.Verify(pub, []byte(parts[0]+"."+parts[1]), sig) {
+		return "", fmt.Errorf("%w: bad signature", ErrInvalidProof)
+	}
+	return holder, nil
+}
+
+// audienceIncludes accepts a string or array aud claim
+func audienceIncludes(aud interface{}, want string) bool {
+	switch v := aud.(type) {
+	case string:
+		return strings.TrimSuffix(v, "/") == want
+	case []interface{}:
+		for _, a := range v {
+			if s, ok := a.(string); ok && strings.TrimSuffix(s, "/") == want {
+				return true
+			}
+		}
+	}
+	return false
+}
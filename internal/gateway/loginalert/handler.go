@@ -0,0 +1,80 @@
+package loginalert
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/example/privacy-gateway/internal/gateway/store"
+	"github.com/example/privacy-gateway/internal/shared/httpx"
+	"github.com/example/privacy-gateway/internal/shared/models"
+)
+
+type endpointRequest struct {
+	Channel string `json:"channel"`
+	Target  string `json:"target"`
+}
+
+// Handler serves the admin alert endpoint registry:
+//
+//	GET    /admin/v1/login-alerts/{did}       endpoints of the DID
+//	POST   /admin/v1/login-alerts/{did}       {"channel", "target"}
+//	DELETE /admin/v1/login-alerts/{did}/{id}
+//
+// {did} is path-escaped. actor identifies the caller in the audit trail.
+func Handler(m *Monitor, actor func(*http.Request) string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		who := "admin"
+		if actor != nil {
+			who = actor(r)
+		}
+		rest := strings.Trim(strings.TrimPrefix(r.URL.EscapedPath(), "/admin/v1/login-alerts"), "/")
+		escaped, id, _ := strings.Cut(rest, "/")
+		did, err := url.PathUnescape(escaped)
+		if err != nil || did == "" || strings.Contains(id, "/") {
+			httpx.WriteJSON(w, http.StatusNotFound, httpx.ErrorResponse{Error: "not found"})
+			return
+		}
+
+		switch {
+		case id == "" && r.Method == http.MethodGet:
+			endpoints, err := m.Endpoints(r.Context(), did)
+			if err != nil {
+				httpx.WriteJSON(w, http.StatusInternalServerError, httpx.ErrorResponse{Error: "failed to list alert endpoints"})
+				return
+			}
+			if endpoints == nil {
+				endpoints = []models.AlertEndpoint{}
+			}
+			httpx.WriteJSON(w, http.StatusOK, endpoints)
+		case id == "" && r.Method == http.MethodPost:
+			var req endpointRequest
+			if err := httpx.DecodeJSON(r, &req); err != nil {
+				httpx.WriteJSON(w, http.StatusBadRequest, httpx.ErrorResponse{Error: "invalid request body"})
+				return
+			}
+			e, err := m.AddEndpoint(r.Context(), did, req.Channel, req.Target, who)
+			switch {
+			case errors.Is(err, ErrInvalidRequest):
+				httpx.WriteJSON(w, http.StatusBadRequest, httpx.ErrorResponse{Error: err.Error()})
+			case err != nil:
+				httpx.WriteJSON(w, http.StatusInternalServerError, httpx.ErrorResponse{Error: "failed to add alert endpoint"})
+			default:
+				httpx.WriteJSON(w, http.StatusCreated, e)
+			}
+		case id != "" && r.Method == http.MethodDelete:
+			err := m.RemoveEndpoint(r.Context(), did, id, who)
+			switch {
+			case errors.Is(err, store.ErrNotFound):
+				httpx.WriteJSON(w, http.StatusNotFound, httpx.ErrorResponse{Error: "alert endpoint not found"})
+			case err != nil:
+				httpx.WriteJSON(w, http.StatusInternalServerError, httpx.ErrorResponse{Error: "failed to remove alert endpoint"})
+			default:
+				w.WriteHeader(http.StatusNoContent)
+			}
+		default:
+			httpx.WriteJSON(w, http.StatusNotFound, httpx.ErrorResponse{Error: "not found"})
+		}
+	}
+}
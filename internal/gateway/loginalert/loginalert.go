@@ -0,0 +1,263 @@
+package loginalert
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+
+	"github.com/example/privacy-gateway/internal/shared/audit"
+	"github.com/example/privacy-gateway/internal/shared/models"
+	"github.com/example/privacy-gateway/internal/shared/observability"
+	"github.com/example/privacy-gateway/internal/shared/validate"
+)
+
+var ErrInvalidRequest = errors.New("invalid login alert request")
+
+// Alert reasons
+const (
+	ReasonNewDevice  = "new_device"
+	ReasonNewCountry = "new_country"
+	ReasonInactive   = "inactive"
+)
+
+// Endpoint channels
+const (
+	ChannelWebhook = "webhook"
+	ChannelFCM     = "fcm"
+	ChannelAPNs    = "apns"
+)
+
+// maxEndpoints bounds the endpoints one DID can register
+const maxEndpoints = 10
+
+// Login is a successful login as seen by the token service
+type Login struct {
+	DID     string
+	Device  string // The device_id claim, or the key ID the subject proved
+	Country string // ISO 3166-1 alpha-2 (see CountryFromHeader); empty skips the country check
+	Time    time.Time
+}
+
+// Alert is sent to the DID's endpoints when a login looks risky
+type Alert struct {
+	ID            string     `json:"id"`
+	DID           string     `json:"did"`
+	Reasons       []string   `json:"reasons"`
+	Device        string     `json:"device,omitempty"`
+	Country       string     `json:"country,omitempty"`
+	Time          time.Time  `json:"time"`
+	PreviousLogin *time.Time `json:"previous_login,omitempty"`
+}
+
+// Store persists alert endpoints
+type Store interface {
+	ListAlertEndpoints(ctx context.Context, did string) ([]models.AlertEndpoint, error)
+	InsertAlertEndpoint(ctx context.Context, e models.AlertEndpoint) error
+	DeleteAlertEndpoint(ctx context.Context, did, id string) error
+}
+
+// Config configures login alerts
+type Config struct {
+	Store      Store
+	Notifiers  map[string]Notifier // By channel; endpoints can only use configured channels
+	Inactivity time.Duration       // Gap since the previous login that triggers an alert (default 90d)
+	Retention  time.Duration       // How long login history outlives the last login (default 400d)
+	Timeout    time.Duration       // Per delivery (default 10s)
+	Audit      audit.Sink
+	Logger     *slog.Logger
+}
+
+// Monitor alerts a DID's owner when it logs in from a device or country it
+// hasn't used before, or after a long period of inactivity, so a stolen
+// key is noticed by the person who owns it. Login history is kept in Redis;
+// alerts go to the endpoints the owner registered, through the Notifier of
+// each endpoint's channel. A DID's first login never alerts.
+type Monitor struct {
+	client *redis.Client
+	cfg    Config
+	audit  *audit.Recorder
+}
+
+// NewMonitor creates a login alert monitor
+func NewMonitor(client *redis.Client, cfg Config) (*Monitor, error) {
+	if cfg.Store == nil {
+		return nil, errors.New("login alerts require a store")
+	}
+	if cfg.Inactivity == 0 {
+		cfg.Inactivity = 90 * 24 * time.Hour
+	}
+	if cfg.Retention == 0 {
+		cfg.Retention = 400 * 24 * time.Hour
+	}
+	if cfg.Retention <= cfg.Inactivity {
+		return nil, errors.New("login alert retention must exceed the inactivity period")
+	}
+	if cfg.Timeout == 0 {
+		cfg.Timeout = 10 * time.Second
+	}
+	cfg.Logger = observability.Component(cfg.Logger, observability.ComponentAuth)
+	return &Monitor{client: client, cfg: cfg, audit: audit.NewRecorder(cfg.Audit, cfg.Logger, nil)}, nil
+}
+
+func lastKey(did string) string      { return "la:h|" + did }
+func devicesKey(did string) string   { return "la:d|" + did }
+func countriesKey(did string) string { return "la:c|" + did }
+
+// Observe records a login and, when it is risky, sends an alert in the
+// background and returns it. Token minting calls it after the subject has
+// proven control of the DID; delivery never delays the login.
+func (m *Monitor) Observe(ctx context.Context, l Login) (*Alert, error) {
+	if l.Time.IsZero() {
+		l.Time = time.Now()
+	}
+	var last *redis.StringCmd
+	var devices, countries, newDevice, newCountry *redis.IntCmd
+	_, err := m.client.TxPipelined(ctx, func(p redis.Pipeliner) error {
+		hk, dk, ck := lastKey(l.DID), devicesKey(l.DID), countriesKey(l.DID)
+		last = p.HGet(ctx, hk, "last")
+		devices, countries = p.SCard(ctx, dk), p.SCard(ctx, ck)
+		if l.Device != "" {
+			newDevice = p.SAdd(ctx, dk, l.Device)
+		}
+		if l.Country != "" {
+			newCountry = p.SAdd(ctx, ck, l.Country)
+		}
+		p.HSet(ctx, hk, "last", l.Time.Unix())
+		for _, k := range []string{hk, dk, ck} {
+			p.Expire(ctx, k, m.cfg.Retention)
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return nil, fmt.Errorf("record login: %w", err)
+	}
+	prev, perr := strconv.ParseInt(last.Val(), 10, 64)
+	if perr != nil {
+		return nil, nil // First login: nothing to compare against
+	}
+
+	previous := time.Unix(prev, 0).UTC()
+	a := &Alert{DID: l.DID, Device: l.Device, Country: l.Country, Time: l.Time.UTC(), PreviousLogin: &previous}
+	// A set that was empty before this login has no baseline yet
+	if newDevice != nil && newDevice.Val() == 1 && devices.Val() > 0 {
+		a.Reasons = append(a.Reasons, ReasonNewDevice)
+	}
+	if newCountry != nil && newCountry.Val() == 1 && countries.Val() > 0 {
+		a.Reasons = append(a.Reasons, ReasonNewCountry)
+	}
+	if l.Time.Sub(previous) >= m.cfg.Inactivity {
+		a.Reasons = append(a.Reasons, ReasonInactive)
+	}
+	if len(a.Reasons) == 0 {
+		return nil, nil
+	}
+	a.ID = uuid.NewString()
+	go m.deliver(context.WithoutCancel(ctx), *a)
+	return a, nil
+}
+
+// deliver sends an alert to every endpoint of the DID. Failures are logged;
+// alerts are not retried, as a late "new login" alert is of little use.
+func (m *Monitor) deliver(ctx context.Context, a Alert) {
+	endpoints, err := m.cfg.Store.ListAlertEndpoints(ctx, a.DID)
+	if err != nil {
+		m.cfg.Logger.Error("failed to load login alert endpoints", "subject", a.DID, "error", err)
+		return
+	}
+	for _, e := range endpoints {
+		n := m.cfg.Notifiers[e.Channel]
+		if n == nil {
+			m.cfg.Logger.Warn("no notifier for login alert channel", "channel", e.Channel, "endpoint", e.ID)
+			continue
+		}
+		dctx, cancel := context.WithTimeout(ctx, m.cfg.Timeout)
+		err := n.Notify(dctx, e.Target, a)
+		cancel()
+		if err != nil {
+			m.cfg.Logger.Warn("failed to deliver login alert", "channel", e.Channel, "endpoint", e.ID, "alert", a.ID, "error", err)
+			continue
+		}
+		m.cfg.Logger.Info("login alert delivered", "channel", e.Channel, "endpoint", e.ID, "alert", a.ID, "reasons", a.Reasons)
+	}
+}
+
+// Endpoints returns the alert endpoints of did
+func (m *Monitor) Endpoints(ctx context.Context, did string) ([]models.AlertEndpoint, error) {
+	return m.cfg.Store.ListAlertEndpoints(ctx, did)
+}
+
+// AddEndpoint registers an alert endpoint for did. Webhook targets must be
+// https URLs; push targets are device tokens.
+func (m *Monitor) AddEndpoint(ctx context.Context, did, channel, target, actor string) (models.AlertEndpoint, error) {
+	if err := validate.ValidateDID(did); err != nil {
+		return models.AlertEndpoint{}, fmt.Errorf("%w: %v", ErrInvalidRequest, err)
+	}
+	if m.cfg.Notifiers[channel] == nil {
+		return models.AlertEndpoint{}, fmt.Errorf("%w: channel %q is not configured", ErrInvalidRequest, channel)
+	}
+	target = strings.TrimSpace(target)
+	if err := validateTarget(channel, target); err != nil {
+		return models.AlertEndpoint{}, err
+	}
+	existing, err := m.cfg.Store.ListAlertEndpoints(ctx, did)
+	if err != nil {
+		return models.AlertEndpoint{}, err
+	}
+	if len(existing) >= maxEndpoints {
+		return models.AlertEndpoint{}, fmt.Errorf("%w: at most %d endpoints per DID", ErrInvalidRequest, maxEndpoints)
+	}
+	e := models.AlertEndpoint{ID: uuid.NewString(), DID: did, Channel: channel, Target: target, CreatedAt: time.Now().UTC()}
+	if err := m.cfg.Store.InsertAlertEndpoint(ctx, e); err != nil {
+		return models.AlertEndpoint{}, err
+	}
+	m.audit.Record(ctx, "login_alert.endpoint_add", did, actor, "success", map[string]interface{}{"endpoint_id": e.ID, "channel": channel})
+	return e, nil
+}
+
+// RemoveEndpoint deletes an alert endpoint; it returns store.ErrNotFound if
+// there is none
+func (m *Monitor) RemoveEndpoint(ctx context.Context, did, id, actor string) error {
+	if err := m.cfg.Store.DeleteAlertEndpoint(ctx, did, id); err != nil {
+		return err
+	}
+	m.audit.Record(ctx, "login_alert.endpoint_remove", did, actor, "success", map[string]interface{}{"endpoint_id": id})
+	return nil
+}
+
+func validateTarget(channel, target string) error {
+	if target == "" || len(target) > 4096 {
+		return fmt.Errorf("%w: target is required (at most 4096 bytes)", ErrInvalidRequest)
+	}
+	if channel == ChannelWebhook {
+		u, err := url.Parse(target)
+		if err != nil || u.Scheme != "https" || u.Host == "" {
+			return fmt.Errorf("%w: webhook target must be an https URL", ErrInvalidRequest)
+		}
+		return nil
+	}
+	if strings.ContainsAny(target, " \t\r\n/") {
+		return fmt.Errorf("%w: invalid push token", ErrInvalidRequest)
+	}
+	return nil
+}
+
+// CountryFromHeader returns the client country set by a CDN or load
+// balancer in header, e.g. CF-IPCountry or CloudFront-Viewer-Country. Only
+// the edge may set it: strip it from client requests there. Unknown ("XX")
+// or malformed values return "".
+func CountryFromHeader(r *http.Request, header string) string {
+	c := strings.ToUpper(strings.TrimSpace(r.Header.Get(header)))
+	if len(c) != 2 || c == "XX" || c[0] < 'A' || c[0] > 'Z' || c[1] < 'A' || c[1] > 'Z' {
+		return ""
+	}
+	return c
+}
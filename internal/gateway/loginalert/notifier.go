@@ -0,0 +1,159 @@
+package loginalert
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/example/privacy-gateway/internal/gateway/webhook"
+)
+
+// EventLoginAlert is the X-Gateway-Event value of webhook alerts
+const EventLoginAlert = "login.alert"
+
+// Notifier delivers an alert to one endpoint target: a URL for webhooks, a
+// device token for push services
+type Notifier interface {
+	Notify(ctx context.Context, target string, a Alert) error
+}
+
+// TokenSource returns a bearer token for a push service, e.g. an OAuth
+// access token for FCM or a provider JWT for APNs. Implementations should
+// cache the token until shortly before it expires.
+type TokenSource func(ctx context.Context) (string, error)
+
+// message is the human-readable text of a push notification
+func message(a Alert) (title, body string) {
+	var parts []string
+	for _, r := range a.Reasons {
+		switch r {
+		case ReasonNewDevice:
+			parts = append(parts, "from a new device")
+		case ReasonNewCountry:
+			parts = append(parts, "from "+a.Country)
+		case ReasonInactive:
+			parts = append(parts, "for the first time in a while")
+		}
+	}
+	return "New sign-in", "Your identity was used to sign in " + strings.Join(parts, ", ") +
+		". If this wasn't you, revoke the device or recover your account."
+}
+
+// WebhookNotifier posts the alert as JSON to the endpoint URL, signed like
+// gateway webhooks (X-Gateway-Signature with Secret)
+type WebhookNotifier struct {
+	Secret string
+	Client *http.Client
+}
+
+// Notify posts the alert
+func (n WebhookNotifier) Notify(ctx context.Context, target string, a Alert) error {
+	body, err := json.Marshal(a)
+	if err != nil {
+		return err
+	}
+	return post(ctx, n.Client, target, body, map[string]string{
+		webhook.EventTypeHeader:  EventLoginAlert,
+		webhook.DeliveryIDHeader: a.ID,
+		webhook.SignatureHeader:  webhook.Sign(n.Secret, time.Now(), body),
+	})
+}
+
+// FCMNotifier sends alerts through the Firebase Cloud Messaging HTTP v1 API
+type FCMNotifier struct {
+	ProjectID string
+	Token     TokenSource // OAuth token with the firebase.messaging scope
+	Client    *http.Client
+	BaseURL   string // Default https://fcm.googleapis.com
+}
+
+// Notify sends the alert to the device token target
+func (n FCMNotifier) Notify(ctx context.Context, target string, a Alert) error {
+	token, err := n.Token(ctx)
+	if err != nil {
+		return fmt.Errorf("fcm token: %w", err)
+	}
+	title, text := message(a)
+	body, err := json.Marshal(map[string]interface{}{"message": map[string]interface{}{
+		"token":        target,
+		"notification": map[string]string{"title": title, "body": text},
+		// FCM data values must be strings
+		"data": map[string]string{"type": EventLoginAlert, "alert_id": a.ID, "did": a.DID, "reasons": strings.Join(a.Reasons, " ")},
+	}})
+	if err != nil {
+		return err
+	}
+	base := n.BaseURL
+	if base == "" {
+		base = "https://fcm.googleapis.com"
+	}
+	endpoint := base + "/v1/projects/" + url.PathEscape(n.ProjectID) + "/messages:send"
+	return post(ctx, n.Client, endpoint, body, map[string]string{"Authorization": "Bearer " + token})
+}
+
+// APNsNotifier sends alerts through the Apple Push Notification service.
+// Client must speak HTTP/2, which http.DefaultClient does over TLS.
+type APNsNotifier struct {
+	Topic   string      // The app's bundle ID
+	Token   TokenSource // Provider authentication JWT
+	Client  *http.Client
+	BaseURL string // Default https://api.push.apple.com
+}
+
+// Notify sends the alert to the device token target
+func (n APNsNotifier) Notify(ctx context.Context, target string, a Alert) error {
+	token, err := n.Token(ctx)
+	if err != nil {
+		return fmt.Errorf("apns token: %w", err)
+	}
+	title, text := message(a)
+	body, err := json.Marshal(map[string]interface{}{
+		"aps":   map[string]interface{}{"alert": map[string]string{"title": title, "body": text}, "sound": "default"},
+		"alert": a,
+	})
+	if err != nil {
+		return err
+	}
+	base := n.BaseURL
+	if base == "" {
+		base = "https://api.push.apple.com"
+	}
+	return post(ctx, n.Client, base+"/3/device/"+url.PathEscape(target), body, map[string]string{
+		"Authorization":    "bearer " + token,
+		"apns-topic":       n.Topic,
+		"apns-push-type":   "alert",
+		"apns-priority":    "10",
+		"apns-collapse-id": a.ID,
+	})
+}
+
+// post sends a JSON body and expects a 2xx response
+func post(ctx context.Context, client *http.Client, target string, body []byte, header map[string]string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("status %d: %s", resp.StatusCode, bytes.TrimSpace(msg))
+	}
+	return nil
+}
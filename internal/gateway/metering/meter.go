@@ -0,0 +1,208 @@
+package metering
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/example/privacy-gateway/internal/shared/models"
+	"github.com/example/privacy-gateway/internal/shared/observability"
+	"github.com/example/privacy-gateway/internal/shared/retry"
+	"github.com/example/privacy-gateway/internal/shared/tenant"
+)
+
+// Sink receives aggregated records
+type Sink interface {
+	Write(ctx context.Context, records []models.MeteringRecord) error
+}
+
+// Config configures the meter
+type Config struct {
+	Interval time.Duration // Aggregation window (default 1m)
+	Retry    retry.Config  // Sink write retries
+	// MaxPending caps records held back after failed writes (default 100000);
+	// the oldest are dropped beyond it
+	MaxPending int
+	// Metrics, when set, also counts every request (anonymous ones too) in
+	// the per-tenant Prometheus metrics
+	Metrics *tenant.Metrics
+}
+
+type meterKey struct {
+	did, tenant, route, policyID string
+}
+
+type counts struct {
+	requests, errors, bytesIn, bytesOut int64
+}
+
+// Meter aggregates per-DID, per-route usage in memory and emits one record per
+// key and window to a sink. Records that fail to write are carried into the
+// next window rather than dropped.
+type Meter struct {
+	sink       Sink
+	interval   time.Duration
+	retry      retry.Config
+	maxPending int
+	metrics    *tenant.Metrics
+	logger     *slog.Logger
+
+	mu          sync.Mutex
+	windowStart time.Time
+	current     map[meterKey]*counts
+	pending     []models.MeteringRecord // Failed writes awaiting retry
+}
+
+// NewMeter creates a meter writing to sink
+func NewMeter(cfg Config, sink Sink, logger *slog.Logger) *Meter {
+	if cfg.Interval == 0 {
+		cfg.Interval = time.Minute
+	}
+	if cfg.Retry.MaxAttempts == 0 {
+		cfg.Retry = retry.DefaultConfig()
+	}
+	if cfg.MaxPending == 0 {
+		cfg.MaxPending = 100000
+	}
+	return &Meter{
+		sink:        sink,
+		interval:    cfg.Interval,
+		retry:       cfg.Retry,
+		maxPending:  cfg.MaxPending,
+		metrics:     cfg.Metrics,
+		logger:      observability.Component(logger, observability.ComponentMetering),
+		windowStart: time.Now().UTC(),
+		current:     make(map[meterKey]*counts),
+	}
+}
+
+// Observe counts one request
+func (m *Meter) Observe(did, tenantID, route, policyID string, status int, bytesIn, bytesOut int64) {
+	if m.metrics != nil {
+		m.metrics.Request(tenantID, tenant.Outcome(status), bytesIn, bytesOut)
+	}
+	if did == "" {
+		return // Anonymous traffic is not billable
+	}
+	k := meterKey{did, tenantID, route, policyID}
+	m.mu.Lock()
+	c, ok := m.current[k]
+	if !ok {
+		c = &counts{}
+		m.current[k] = c
+	}
+	c.requests++
+	if status >= 500 {
+		c.errors++
+	}
+	c.bytesIn += bytesIn
+	c.bytesOut += bytesOut
+	m.mu.Unlock()
+}
+
+// Run emits a batch every interval until ctx is cancelled, then flushes the
+// partial window
+func (m *Meter) Run(ctx context.Context) {
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			m.flush(ctx)
+		case <-ctx.Done():
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			m.flush(shutdownCtx)
+			cancel()
+			return
+		}
+	}
+}
+
+// flush closes the current window and writes it with any pending records
+func (m *Meter) flush(ctx context.Context) {
+	now := time.Now().UTC()
+	m.mu.Lock()
+	records := m.pending
+	for k, c := range m.current {
+		records = append(records, models.MeteringRecord{
+			ID:          uuid.NewString(),
+			DID:         k.did,
+			Tenant:      k.tenant,
+			Route:       k.route,
+			PolicyID:    k.policyID,
+			WindowStart: m.windowStart,
+			WindowEnd:   now,
+			Requests:    c.requests,
+			Errors:      c.errors,
+			BytesIn:     c.bytesIn,
+			BytesOut:    c.bytesOut,
+		})
+	}
+	m.pending = nil
+	m.current = make(map[meterKey]*counts)
+	m.windowStart = now
+	m.mu.Unlock()
+
+	if len(records) == 0 {
+		return
+	}
+	err := retry.WithExponentialBackoffContext(ctx, m.retry, func(ctx context.Context) error {
+		return m.sink.Write(ctx, records)
+	})
+	if err != nil {
+		m.logger.Error("metering: failed to write records", "error", err, "records", len(records))
+		m.mu.Lock()
+		m.pending = append(records, m.pending...)
+		if over := len(m.pending) - m.maxPending; over > 0 {
+			m.logger.Error("metering: dropping oldest records", "records", over)
+			m.pending = m.pending[over:]
+		}
+		m.mu.Unlock()
+	}
+}
+
+// Identify returns the DID, tenant (credential issuer DID), route template
+// and policy ID of an authorized request; an empty DID skips metering
+type Identify func(r *http.Request) (did, tenant, route, policyID string)
+
+// Middleware meters requests passing through next
+func (m *Meter) Middleware(identify Identify, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cw := &countingWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(cw, r)
+
+		did, tenantID, route, policyID := identify(r)
+		bytesIn := r.ContentLength
+		if bytesIn < 0 {
+			bytesIn = 0
+		}
+		m.Observe(did, tenantID, route, policyID, cw.status, bytesIn, cw.written)
+	})
+}
+
+// countingWriter records status and body bytes written
+type countingWriter struct {
+	http.ResponseWriter
+	status  int
+	written int64
+}
+
+func (w *countingWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *countingWriter) Write(b []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(b)
+	w.written += int64(n)
+	return n, err
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController
+func (w *countingWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
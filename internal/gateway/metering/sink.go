@@ -0,0 +1,84 @@
+package metering
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+
+	"github.com/example/privacy-gateway/internal/shared/models"
+)
+
+// RecordStore persists metering records; store.Postgres implements it
+type RecordStore interface {
+	InsertMeteringRecords(ctx context.Context, records []models.MeteringRecord) error
+}
+
+// PostgresSink writes records to the metering_records table
+type PostgresSink struct {
+	store RecordStore
+}
+
+// NewPostgresSink creates a Postgres sink
+func NewPostgresSink(store RecordStore) *PostgresSink {
+	return &PostgresSink{store: store}
+}
+
+// Write inserts records; duplicates by ID are ignored so retries are safe
+func (s *PostgresSink) Write(ctx context.Context, records []models.MeteringRecord) error {
+	return s.store.InsertMeteringRecords(ctx, records)
+}
+
+// KafkaConfig configures the Kafka sink
+type KafkaConfig struct {
+	Brokers []string
+	Topic   string        // Default "gateway.metering"
+	Timeout time.Duration // Per batch write (default 10s)
+}
+
+// KafkaSink publishes records as JSON messages keyed by DID, so one DID's
+// records land on the same partition in order
+type KafkaSink struct {
+	writer  *kafka.Writer
+	timeout time.Duration
+}
+
+// NewKafkaSink creates a Kafka sink
+func NewKafkaSink(cfg KafkaConfig) *KafkaSink {
+	if cfg.Topic == "" {
+		cfg.Topic = "gateway.metering"
+	}
+	if cfg.Timeout == 0 {
+		cfg.Timeout = 10 * time.Second
+	}
+	return &KafkaSink{
+		writer: &kafka.Writer{
+			Addr:         kafka.TCP(cfg.Brokers...),
+			Topic:        cfg.Topic,
+			Balancer:     &kafka.Hash{},
+			RequiredAcks: kafka.RequireAll,
+		},
+		timeout: cfg.Timeout,
+	}
+}
+
+// Write publishes one message per record
+func (s *KafkaSink) Write(ctx context.Context, records []models.MeteringRecord) error {
+	msgs := make([]kafka.Message, 0, len(records))
+	for _, rec := range records {
+		value, err := json.Marshal(rec)
+		if err != nil {
+			return err
+		}
+		msgs = append(msgs, kafka.Message{Key: []byte(rec.DID), Value: value})
+	}
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	return s.writer.WriteMessages(ctx, msgs...)
+}
+
+// Close flushes and closes the Kafka writer
+func (s *KafkaSink) Close() error {
+	return s.writer.Close()
+}
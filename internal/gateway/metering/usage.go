@@ -0,0 +1,100 @@
+package metering
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/example/privacy-gateway/internal/shared/httpx"
+	"github.com/example/privacy-gateway/internal/shared/models"
+)
+
+// UsageStore summarizes metering records; store.Postgres implements it
+type UsageStore interface {
+	SummarizeTenantUsage(ctx context.Context, from, to time.Time) ([]models.TenantUsage, error)
+	TenantUsageByRoute(ctx context.Context, tenant string, from, to time.Time) ([]models.TenantUsage, error)
+}
+
+// tenantUsageResponse is returned by the tenant usage endpoints
+type tenantUsageResponse struct {
+	Tenant string               `json:"tenant,omitempty"`
+	From   time.Time            `json:"from"`
+	To     time.Time            `json:"to"`
+	Usage  []models.TenantUsage `json:"usage"`
+}
+
+// TenantUsageHandler serves per-tenant usage summaries from the metering
+// records:
+//
+//	GET /admin/v1/tenants/usage?from=&to=
+//	GET /admin/v1/tenants/{tenant}/usage?from=&to=
+//
+// from and to are RFC 3339 times or YYYY-MM-DD dates, defaulting to the
+// current month so far. The first lists every tenant; the second breaks one
+// down by route and policy. The tenant is the path-escaped issuer DID.
+// Windows still being aggregated in memory (up to the metering interval)
+// are not included.
+func TenantUsageHandler(store UsageStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			httpx.WriteJSON(w, http.StatusMethodNotAllowed, httpx.ErrorResponse{Error: "method not allowed"})
+			return
+		}
+		rest := strings.Trim(strings.TrimPrefix(r.URL.EscapedPath(), "/admin/v1/tenants"), "/")
+		escaped, ok := strings.CutSuffix(rest, "usage")
+		if !ok || (escaped != "" && !strings.HasSuffix(escaped, "/")) {
+			httpx.WriteJSON(w, http.StatusNotFound, httpx.ErrorResponse{Error: "not found"})
+			return
+		}
+		tenant, err := url.PathUnescape(strings.TrimSuffix(escaped, "/"))
+		if err != nil || strings.Contains(tenant, "/") {
+			httpx.WriteJSON(w, http.StatusBadRequest, httpx.ErrorResponse{Error: "invalid tenant"})
+			return
+		}
+
+		now := time.Now().UTC()
+		from := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+		to := now
+		q := r.URL.Query()
+		if v := q.Get("from"); v != "" {
+			if from, err = parseTime(v); err != nil {
+				httpx.WriteJSON(w, http.StatusBadRequest, httpx.ErrorResponse{Error: "from must be an RFC 3339 time or YYYY-MM-DD"})
+				return
+			}
+		}
+		if v := q.Get("to"); v != "" {
+			if to, err = parseTime(v); err != nil {
+				httpx.WriteJSON(w, http.StatusBadRequest, httpx.ErrorResponse{Error: "to must be an RFC 3339 time or YYYY-MM-DD"})
+				return
+			}
+		}
+		if !from.Before(to) {
+			httpx.WriteJSON(w, http.StatusBadRequest, httpx.ErrorResponse{Error: "from must be before to"})
+			return
+		}
+
+		var usage []models.TenantUsage
+		if tenant == "" {
+			usage, err = store.SummarizeTenantUsage(r.Context(), from, to)
+		} else {
+			usage, err = store.TenantUsageByRoute(r.Context(), tenant, from, to)
+		}
+		if err != nil {
+			httpx.WriteJSON(w, http.StatusInternalServerError, httpx.ErrorResponse{Error: "failed to load usage"})
+			return
+		}
+		if usage == nil {
+			usage = []models.TenantUsage{}
+		}
+		httpx.WriteJSON(w, http.StatusOK, tenantUsageResponse{Tenant: tenant, From: from, To: to, Usage: usage})
+	}
+}
+
+func parseTime(v string) (time.Time, error) {
+	if t, err := time.Parse("2006-01-02", v); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, v)
+}
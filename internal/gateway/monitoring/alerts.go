@@ -0,0 +1,129 @@
+package monitoring
+
+import (
+	"bytes"
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Rule is one Prometheus alerting rule
+type Rule struct {
+	Alert       string            `yaml:"alert"`
+	Expr        string            `yaml:"expr"`
+	For         string            `yaml:"for,omitempty"`
+	Labels      map[string]string `yaml:"labels,omitempty"`
+	Annotations map[string]string `yaml:"annotations,omitempty"`
+}
+
+// RuleGroup is a Prometheus rule group
+type RuleGroup struct {
+	Name     string `yaml:"name"`
+	Interval string `yaml:"interval,omitempty"`
+	Rules    []Rule `yaml:"rules"`
+}
+
+func rule(name, expr, forDur, severity, component, summary, description string) Rule {
+	return Rule{
+		Alert:       name,
+		Expr:        expr,
+		For:         forDur,
+		Labels:      map[string]string{"severity": severity, "component": component},
+		Annotations: map[string]string{"summary": summary, "description": description},
+	}
+}
+
+// AlertGroups returns the generated alerting rules
+func AlertGroups(cfg Config) ([]RuleGroup, error) {
+	cfg = cfg.withDefaults()
+	q := &queries{job: cfg.Job}
+	ratio := func(num, den string) string { return num + "\n/\n" + den }
+
+	gateway := RuleGroup{Name: "did_gateway_alerts", Interval: "30s", Rules: []Rule{
+		rule("GatewayDown", q.sel("up")+" == 0", "2m", "critical", "gateway",
+			"DID Gateway is down", "Gateway instance {{ $labels.instance }} has been down for more than 2 minutes."),
+		rule("HighErrorRate",
+			ratio(q.rate("http_requests_total", "5m", nil, `code=~"5.."`), q.rate("http_requests_total", "5m", nil))+" > 0.05",
+			"5m", "critical", "gateway",
+			"High error rate detected", "Error rate is {{ $value | humanizePercentage }} (threshold: 5%)"),
+		rule("HighLatency", q.quantile("0.99", "http_request_duration_seconds", "5m")+" > 0.5",
+			"10m", "critical", "gateway",
+			"High latency detected", "P99 latency is {{ $value }}s (threshold: 500ms)"),
+		rule("HighRateLimitHits", q.rate("rate_limit_exceeded_total", "5m", nil)+" > 100",
+			"5m", "warning", "gateway",
+			"High rate limit hits", "Rate limit exceeded {{ $value }} times/sec"),
+		rule("DIDResolutionFailures",
+			ratio(q.rate("did_resolve_errors_total", "5m", nil), q.rate("did_resolve_total", "5m", nil))+" > 0.1",
+			"5m", "warning", "did_resolver",
+			"High DID resolution failure rate", "DID resolution failure rate is {{ $value | humanizePercentage }}"),
+		rule("VCVerificationFailures",
+			ratio(q.rate("vc_verify_total", "5m", nil, `status="failed"`), q.rate("vc_verify_total", "5m", nil))+" > 0.1",
+			"5m", "warning", "vc_verifier",
+			"High VC verification failure rate", "VC verification failure rate is {{ $value | humanizePercentage }}"),
+		rule("LowCacheHitRate",
+			ratio(q.rate("did_resolve_cache_hits_total", "5m", nil), q.rate("did_resolve_total", "5m", nil))+" < 0.5",
+			"15m", "warning", "cache",
+			"Low DID cache hit rate", "Cache hit rate is {{ $value | humanizePercentage }} (expected >50%)"),
+		rule("HighCPUUsage", "rate("+q.sel("process_cpu_seconds_total")+"[5m]) > 0.8",
+			"15m", "warning", "resources",
+			"High CPU usage", "CPU usage is {{ $value | humanizePercentage }}"),
+	}}
+
+	tenants := RuleGroup{Name: "did_gateway_tenant_alerts", Interval: "1m", Rules: []Rule{
+		rule("TenantHeavilyThrottled",
+			ratio(q.rate("gateway_tenant_rate_limited_total", "10m", []string{"tenant"}),
+				q.rate("gateway_tenant_requests_total", "10m", []string{"tenant"}))+" > 0.25",
+			"15m", "warning", "tenancy",
+			"Tenant heavily throttled", "{{ $value | humanizePercentage }} of requests from tenant {{ $labels.tenant }} are rate limited"),
+		rule("TenantDenialSpike",
+			ratio(q.rate("gateway_tenant_denials_total", "10m", []string{"tenant"}),
+				q.rate("gateway_tenant_requests_total", "10m", []string{"tenant"}))+" > 0.5",
+			"15m", "warning", "tenancy",
+			"Tenant denial spike", "{{ $value | humanizePercentage }} of requests from tenant {{ $labels.tenant }} are denied by policy"),
+	}}
+
+	slo := RuleGroup{Name: "slo_alerts", Interval: "30s", Rules: []Rule{
+		{
+			Alert: "SLOAvailabilityBreach",
+			Expr: "1 - (\n" + ratio(q.rate("http_requests_total", "30d", nil, `code!~"5.."`), q.rate("http_requests_total", "30d", nil)) +
+				"\n) < 0.999",
+			For:    "10m",
+			Labels: map[string]string{"severity": "critical", "slo": "availability"},
+			Annotations: map[string]string{
+				"summary":     "SLO availability breach",
+				"description": "30-day availability is {{ $value | humanizePercentage }} (SLO: 99.9%)",
+			},
+		},
+		{
+			Alert:  "SLOLatencyBreach",
+			Expr:   q.quantile("0.99", "http_request_duration_seconds", "1h", `path="/v1/auth/verify"`) + " > 0.2",
+			For:    "30m",
+			Labels: map[string]string{"severity": "warning", "slo": "latency"},
+			Annotations: map[string]string{
+				"summary":     "SLO latency breach",
+				"description": "P99 latency is {{ $value }}s (SLO: 200ms)",
+			},
+		},
+	}}
+
+	if err := q.err(); err != nil {
+		return nil, err
+	}
+	return []RuleGroup{gateway, tenants, slo}, nil
+}
+
+// AlertRules generates a Prometheus rule file
+func AlertRules(cfg Config) ([]byte, error) {
+	groups, err := AlertGroups(cfg)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "# Generated by didctl monitoring for job %q; edit the generator, not this file\n", cfg.withDefaults().Job)
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(map[string][]RuleGroup{"groups": groups}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), enc.Close()
+}
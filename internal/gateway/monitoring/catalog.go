@@ -0,0 +1,59 @@
+package monitoring
+
+// Metric is one Prometheus metric the gateway exposes
+type Metric struct {
+	Name   string
+	Type   string // counter, gauge or histogram
+	Help   string
+	Labels []string
+}
+
+// Metrics is the catalog of gateway metrics the generated dashboards and
+// alert rules may use. Keep it in step with the metrics the gateway
+// registers and with deploy/monitoring/README.md; generation fails on a
+// query that names a metric or label missing here.
+var Metrics = []Metric{
+	{Name: "up", Type: "gauge", Help: "Scrape target is up (Prometheus)", Labels: []string{"instance"}},
+	{Name: "http_requests_total", Type: "counter", Help: "HTTP requests", Labels: []string{"method", "path", "code"}},
+	{Name: "http_request_duration_seconds", Type: "histogram", Help: "HTTP request duration", Labels: []string{"method", "path", "code"}},
+	{Name: "auth_challenge_total", Type: "counter", Help: "Challenge requests"},
+	{Name: "auth_verify_total", Type: "counter", Help: "Verification attempts", Labels: []string{"status", "did_method", "vc_type"}},
+	{Name: "did_resolve_total", Type: "counter", Help: "DID resolutions", Labels: []string{"method"}},
+	{Name: "did_resolve_cache_hits_total", Type: "counter", Help: "DID resolution cache hits"},
+	{Name: "did_resolve_errors_total", Type: "counter", Help: "DID resolution errors", Labels: []string{"method", "error_type"}},
+	{Name: "did_resolve_duration_seconds", Type: "histogram", Help: "DID resolution duration", Labels: []string{"method"}},
+	{Name: "vc_verify_total", Type: "counter", Help: "VC verification attempts", Labels: []string{"status"}},
+	{Name: "vc_verify_duration_seconds", Type: "histogram", Help: "VC verification duration"},
+	{Name: "rate_limit_exceeded_total", Type: "counter", Help: "Rate limit violations", Labels: []string{"did"}},
+	{Name: "policy_denials_total", Type: "counter", Help: "Policy denials", Labels: []string{"policy_name", "reason"}},
+	{Name: "gateway_tenant_requests_total", Type: "counter", Help: "Requests per tenant", Labels: []string{"tenant", "outcome"}},
+	{Name: "gateway_tenant_bytes_total", Type: "counter", Help: "Body bytes per tenant", Labels: []string{"tenant", "direction"}},
+	{Name: "gateway_tenant_denials_total", Type: "counter", Help: "Policy denials per tenant", Labels: []string{"tenant", "reason"}},
+	{Name: "gateway_tenant_rate_limited_total", Type: "counter", Help: "Rate-limited requests per tenant", Labels: []string{"tenant", "policy"}},
+	{Name: "gateway_slo_burn_rate", Type: "gauge", Help: "SLO error budget burn rate", Labels: []string{"objective", "sli", "window"}},
+	{Name: "gateway_slo_error_budget_remaining", Type: "gauge", Help: "SLO error budget left in the window", Labels: []string{"objective", "sli"}},
+	{Name: "gateway_slo_healthy", Type: "gauge", Help: "SLO objective within budget", Labels: []string{"objective"}},
+	{Name: "process_cpu_seconds_total", Type: "counter", Help: "Process CPU time (Go client)"},
+	{Name: "process_resident_memory_bytes", Type: "gauge", Help: "Process resident memory (Go client)"},
+}
+
+func lookup(name string) (Metric, bool) {
+	for _, m := range Metrics {
+		if m.Name == name {
+			return m, true
+		}
+	}
+	return Metric{}, false
+}
+
+func (m Metric) hasLabel(label string) bool {
+	if label == "job" || label == "le" && m.Type == "histogram" {
+		return true
+	}
+	for _, l := range m.Labels {
+		if l == label {
+			return true
+		}
+	}
+	return false
+}
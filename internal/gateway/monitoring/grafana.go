@@ -0,0 +1,171 @@
+package monitoring
+
+import (
+	"encoding/json"
+)
+
+type target struct {
+	Expr         string `json:"expr"`
+	LegendFormat string `json:"legendFormat,omitempty"`
+	RefID        string `json:"refId"`
+}
+
+type panel struct {
+	ID          int                    `json:"id"`
+	Type        string                 `json:"type"`
+	Title       string                 `json:"title"`
+	Description string                 `json:"description,omitempty"`
+	Datasource  string                 `json:"datasource,omitempty"`
+	GridPos     map[string]int         `json:"gridPos"`
+	FieldConfig map[string]interface{} `json:"fieldConfig,omitempty"`
+	Options     map[string]interface{} `json:"options,omitempty"`
+	Targets     []target               `json:"targets,omitempty"`
+	Collapsed   *bool                  `json:"collapsed,omitempty"`
+	Panels      []panel                `json:"panels,omitempty"`
+}
+
+// dashboardBuilder lays panels out two per row, with a full-width row
+// header per section
+type dashboardBuilder struct {
+	cfg    Config
+	panels []panel
+	x, y   int
+}
+
+func (d *dashboardBuilder) section(title string) {
+	if d.x != 0 {
+		d.x, d.y = 0, d.y+8
+	}
+	collapsed := false
+	d.panels = append(d.panels, panel{
+		ID: len(d.panels) + 1, Type: "row", Title: title, Collapsed: &collapsed, Panels: []panel{},
+		GridPos: map[string]int{"h": 1, "w": 24, "x": 0, "y": d.y},
+	})
+	d.y++
+}
+
+func (d *dashboardBuilder) add(kind, title, unit string, thresholds []float64, targets ...target) {
+	for i := range targets {
+		targets[i].RefID = string(rune('A' + i))
+	}
+	steps := []map[string]interface{}{{"color": "green", "value": nil}}
+	for i, t := range thresholds {
+		steps = append(steps, map[string]interface{}{"color": []string{"yellow", "red"}[min(i, 1)], "value": t})
+	}
+	p := panel{
+		ID: len(d.panels) + 1, Type: kind, Title: title, Datasource: d.cfg.Datasource, Targets: targets,
+		GridPos: map[string]int{"h": 8, "w": 12, "x": d.x, "y": d.y},
+		FieldConfig: map[string]interface{}{
+			"defaults": map[string]interface{}{
+				"unit":       unit,
+				"thresholds": map[string]interface{}{"mode": "absolute", "steps": steps},
+			},
+			"overrides": []interface{}{},
+		},
+	}
+	if kind == "timeseries" {
+		p.Options = map[string]interface{}{
+			"legend":  map[string]interface{}{"displayMode": "table", "placement": "bottom", "calcs": []string{"lastNotNull", "max"}},
+			"tooltip": map[string]interface{}{"mode": "multi", "sort": "desc"},
+		}
+	}
+	d.panels = append(d.panels, p)
+	if d.x == 0 {
+		d.x = 12
+	} else {
+		d.x, d.y = 0, d.y+8
+	}
+}
+
+// Dashboard generates a Grafana dashboard (JSON model for import) over the
+// gateway's metrics
+func Dashboard(cfg Config) ([]byte, error) {
+	cfg = cfg.withDefaults()
+	q := &queries{job: cfg.Job}
+	d := &dashboardBuilder{cfg: cfg}
+	ts := func(expr, legend string) target { return target{Expr: expr, LegendFormat: legend} }
+
+	d.section("Traffic")
+	d.add("timeseries", "Request rate by status", "reqps", nil,
+		ts(q.rate("http_requests_total", "5m", []string{"code"}), "{{code}}"))
+	d.add("stat", "5xx error ratio", "percentunit", []float64{0.01, 0.05},
+		ts(q.rate("http_requests_total", "5m", nil, `code=~"5.."`)+" / "+q.rate("http_requests_total", "5m", nil), "5xx"))
+	d.add("timeseries", "Latency", "s", nil,
+		ts(q.quantile("0.50", "http_request_duration_seconds", "5m"), "p50"),
+		ts(q.quantile("0.95", "http_request_duration_seconds", "5m"), "p95"),
+		ts(q.quantile("0.99", "http_request_duration_seconds", "5m"), "p99"))
+	d.add("timeseries", "Verify latency (SLO 200ms)", "s", []float64{0.2},
+		ts(q.quantile("0.99", "http_request_duration_seconds", "5m", `path="/v1/auth/verify"`), "p99"))
+
+	d.section("Authentication")
+	d.add("timeseries", "Verifications by status", "ops", nil,
+		ts(q.rate("auth_verify_total", "5m", []string{"status"}), "{{status}}"))
+	d.add("timeseries", "VC verifications by status", "ops", nil,
+		ts(q.rate("vc_verify_total", "5m", []string{"status"}), "{{status}}"))
+	d.add("timeseries", "Challenges", "ops", nil,
+		ts(q.rate("auth_challenge_total", "5m", nil), "challenges"))
+	d.add("timeseries", "VC verification latency", "s", nil,
+		ts(q.quantile("0.99", "vc_verify_duration_seconds", "5m"), "p99"))
+
+	d.section("DID resolution")
+	d.add("timeseries", "Resolutions by method", "ops", nil,
+		ts(q.rate("did_resolve_total", "5m", []string{"method"}), "{{method}}"))
+	d.add("stat", "Cache hit ratio", "percentunit", nil,
+		ts(q.rate("did_resolve_cache_hits_total", "5m", nil)+" / "+q.rate("did_resolve_total", "5m", nil), "hit ratio"))
+	d.add("timeseries", "Resolution errors", "ops", nil,
+		ts(q.rate("did_resolve_errors_total", "5m", []string{"method", "error_type"}), "{{method}} {{error_type}}"))
+	d.add("timeseries", "Resolution latency", "s", nil,
+		ts(q.quantile("0.99", "did_resolve_duration_seconds", "5m"), "p99"))
+
+	d.section("Policy and rate limiting")
+	d.add("timeseries", "Policy denials by reason", "ops", nil,
+		ts(q.rate("policy_denials_total", "5m", []string{"policy_name", "reason"}), "{{policy_name}}: {{reason}}"))
+	d.add("timeseries", "Rate limit hits", "ops", nil,
+		ts(q.rate("rate_limit_exceeded_total", "5m", nil), "rate limited"))
+
+	d.section("Tenants")
+	d.add("timeseries", "Requests by tenant (top 10)", "reqps", nil,
+		ts("topk(10, "+q.rate("gateway_tenant_requests_total", "5m", []string{"tenant"})+")", "{{tenant}}"))
+	d.add("timeseries", "Outcomes", "reqps", nil,
+		ts(q.rate("gateway_tenant_requests_total", "5m", []string{"outcome"}), "{{outcome}}"))
+	d.add("timeseries", "Bytes by tenant (top 10)", "Bps", nil,
+		ts("topk(10, "+q.rate("gateway_tenant_bytes_total", "5m", []string{"tenant", "direction"})+")", "{{tenant}} {{direction}}"))
+	d.add("timeseries", "Throttled by tenant (top 10)", "reqps", nil,
+		ts("topk(10, "+q.rate("gateway_tenant_rate_limited_total", "5m", []string{"tenant"})+")", "{{tenant}}"))
+
+	d.section("SLOs")
+	d.add("timeseries", "Error budget remaining", "percentunit", []float64{0, 0.25},
+		ts("min "+q.by("gateway_slo_error_budget_remaining", "objective", "sli")+" ("+q.sel("gateway_slo_error_budget_remaining")+")", "{{objective}} {{sli}}"))
+	d.add("timeseries", "Burn rate (1h)", "none", []float64{1},
+		ts("max "+q.by("gateway_slo_burn_rate", "objective", "sli")+" ("+q.sel("gateway_slo_burn_rate", `window="1h"`)+")", "{{objective}} {{sli}}"))
+	d.add("stat", "Unhealthy objectives", "none", []float64{1},
+		ts("count(min "+q.by("gateway_slo_healthy", "objective")+" ("+q.sel("gateway_slo_healthy")+") == 0) or vector(0)", "unhealthy"))
+
+	d.section("Process")
+	d.add("timeseries", "CPU", "percentunit", nil,
+		ts("rate("+q.sel("process_cpu_seconds_total")+"[5m])", "{{instance}}"))
+	d.add("timeseries", "Resident memory", "bytes", nil,
+		ts(q.sel("process_resident_memory_bytes"), "{{instance}}"))
+
+	if err := q.err(); err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(map[string]interface{}{
+		"uid":                  cfg.UID,
+		"title":                cfg.Title,
+		"tags":                 []string{"did-gateway", "generated"},
+		"editable":             true,
+		"schemaVersion":        38,
+		"version":              1,
+		"refresh":              "30s",
+		"time":                 map[string]string{"from": "now-6h", "to": "now"},
+		"timezone":             "browser",
+		"fiscalYearStartMonth": 0,
+		"graphTooltip":         1,
+		"panels":               d.panels,
+		"annotations": map[string]interface{}{"list": []interface{}{map[string]interface{}{
+			"builtIn": 1, "datasource": "-- Grafana --", "enable": true, "hide": true,
+			"iconColor": "rgba(0, 211, 255, 1)", "name": "Annotations & Alerts", "type": "dashboard",
+		}}},
+	}, "", "  ")
+}
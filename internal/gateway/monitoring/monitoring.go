@@ -0,0 +1,107 @@
+package monitoring
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var ErrUnknownMetric = errors.New("query uses a metric or label the gateway doesn't expose")
+
+// Config parameterizes the generated dashboard and alert rules
+type Config struct {
+	Job        string // Prometheus job label of the gateway (default "did-gateway")
+	Datasource string // Grafana datasource name (default "Prometheus")
+	Title      string // Dashboard title (default "DID Gateway")
+	UID        string // Dashboard UID (default "did-gateway-generated")
+}
+
+func (c Config) withDefaults() Config {
+	if c.Job == "" {
+		c.Job = "did-gateway"
+	}
+	if c.Datasource == "" {
+		c.Datasource = "Prometheus"
+	}
+	if c.Title == "" {
+		c.Title = "DID Gateway"
+	}
+	if c.UID == "" {
+		c.UID = "did-gateway-generated"
+	}
+	return c
+}
+
+// matcherLabel extracts the label name of a matcher such as code=~"5.."
+var matcherLabel = regexp.MustCompile(`^\s*([a-zA-Z_][a-zA-Z0-9_]*)\s*(=~|!~|!=|=)`)
+
+// queries builds PromQL selectors checked against the metric catalog, so a
+// renamed metric or label fails generation instead of producing a panel
+// that silently shows nothing
+type queries struct {
+	job  string
+	errs []error
+}
+
+func (q *queries) metric(name string) (Metric, bool) {
+	base := name
+	for _, suffix := range []string{"_bucket", "_sum", "_count"} {
+		if m, ok := lookup(strings.TrimSuffix(name, suffix)); ok && strings.HasSuffix(name, suffix) && m.Type == "histogram" {
+			base = m.Name
+			break
+		}
+	}
+	m, ok := lookup(base)
+	if !ok {
+		q.errs = append(q.errs, fmt.Errorf("%w: metric %s", ErrUnknownMetric, name))
+	}
+	return m, ok
+}
+
+// sel returns name{job="<job>",matchers...}
+func (q *queries) sel(name string, matchers ...string) string {
+	m, ok := q.metric(name)
+	for _, mt := range matchers {
+		l := matcherLabel.FindStringSubmatch(mt)
+		if l == nil {
+			q.errs = append(q.errs, fmt.Errorf("%w: malformed matcher %q", ErrUnknownMetric, mt))
+			continue
+		}
+		if ok && !m.hasLabel(l[1]) {
+			q.errs = append(q.errs, fmt.Errorf("%w: %s has no label %s", ErrUnknownMetric, name, l[1]))
+		}
+	}
+	all := append([]string{fmt.Sprintf("job=%q", q.job)}, matchers...)
+	return name + "{" + strings.Join(all, ",") + "}"
+}
+
+// by returns "by (labels)" after checking name carries every label
+func (q *queries) by(name string, labels ...string) string {
+	if m, ok := q.metric(name); ok {
+		for _, l := range labels {
+			if !m.hasLabel(l) {
+				q.errs = append(q.errs, fmt.Errorf("%w: %s has no label %s", ErrUnknownMetric, name, l))
+			}
+		}
+	}
+	return "by (" + strings.Join(labels, ", ") + ")"
+}
+
+// rate returns sum(rate(sel[window])) with an optional by clause
+func (q *queries) rate(name, window string, by []string, matchers ...string) string {
+	inner := "rate(" + q.sel(name, matchers...) + "[" + window + "])"
+	if len(by) == 0 {
+		return "sum(" + inner + ")"
+	}
+	return "sum " + q.by(name, by...) + " (" + inner + ")"
+}
+
+// quantile returns histogram_quantile over a histogram's buckets
+func (q *queries) quantile(phi, name, window string, matchers ...string) string {
+	return fmt.Sprintf("histogram_quantile(%s, sum by (le) (rate(%s[%s])))", phi, q.sel(name+"_bucket", matchers...), window)
+}
+
+func (q *queries) err() error {
+	return errors.Join(q.errs...)
+}
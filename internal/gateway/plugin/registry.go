@@ -0,0 +1,58 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Registry holds the filters loaded from the plugin directory, keyed by name
+type Registry struct {
+	filters map[string]*Filter
+}
+
+// LoadDir loads every *.wasm file in dir as a filter named after the file
+func LoadDir(ctx context.Context, dir string, base Config) (*Registry, error) {
+	reg := &Registry{filters: make(map[string]*Filter)}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read plugin directory: %w", err)
+	}
+	for _, e := range entries {
+		if e.IsDir() || filepath.Ext(e.Name()) != ".wasm" {
+			continue
+		}
+		cfg := base
+		cfg.Name = strings.TrimSuffix(e.Name(), ".wasm")
+		cfg.Path = filepath.Join(dir, e.Name())
+		f, err := Load(ctx, cfg)
+		if err != nil {
+			reg.Close(ctx)
+			return nil, err
+		}
+		reg.filters[cfg.Name] = f
+	}
+	return reg, nil
+}
+
+// Chain returns the filters for the given names, in order
+func (r *Registry) Chain(names []string) ([]*Filter, error) {
+	chain := make([]*Filter, 0, len(names))
+	for _, name := range names {
+		f, ok := r.filters[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown filter %q", name)
+		}
+		chain = append(chain, f)
+	}
+	return chain, nil
+}
+
+// Close releases all filters
+func (r *Registry) Close(ctx context.Context) {
+	for _, f := range r.filters {
+		_ = f.Close(ctx)
+	}
+}
@@ -0,0 +1,260 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+)
+
+// Filter ABI
+//
+// A filter module must export:
+//
+//	alloc(size i32) -> i32            allocate size bytes in guest memory
+//	filter(ptr i32, len i32) -> i64   process a JSON Request, return (ptr<<32 | len) of a JSON Result
+//
+// The module may import WASI for stdout/stderr logging; no filesystem or
+// network access is granted.
+
+var (
+	ErrMissingExport = errors.New("wasm filter is missing a required export")
+	ErrFilterFailed  = errors.New("wasm filter failed")
+	ErrPoolExhausted = errors.New("wasm filter pool exhausted")
+)
+
+// Request is the view of an HTTP request passed to a filter
+type Request struct {
+	Method  string              `json:"method"`
+	Path    string              `json:"path"`
+	Query   string              `json:"query,omitempty"`
+	Headers map[string][]string `json:"headers"`
+	Subject string              `json:"subject,omitempty"`
+	Scopes  []string            `json:"scopes,omitempty"`
+}
+
+// Result is a filter's verdict
+type Result struct {
+	Deny          bool              `json:"deny,omitempty"`
+	Status        int               `json:"status,omitempty"` // Response status when denying (default 403)
+	Reason        string            `json:"reason,omitempty"`
+	SetHeaders    map[string]string `json:"set_headers,omitempty"`
+	RemoveHeaders []string          `json:"remove_headers,omitempty"`
+}
+
+// Config holds per-filter resource limits
+type Config struct {
+	Name           string
+	Path           string        // .wasm file
+	MaxMemoryPages uint32        // 64KiB pages (default 256 = 16MiB)
+	Timeout        time.Duration // Per invocation (default 10ms)
+	Instances      int           // Pooled module instances (default 8)
+}
+
+// Filter is a compiled WASM filter with a pool of instances. Instances are not
+// safe for concurrent use so each call checks one out of the pool.
+type Filter struct {
+	name     string
+	timeout  time.Duration
+	runtime  wazero.Runtime
+	compiled wazero.CompiledModule
+	pool     chan api.Module
+
+	closeOnce sync.Once
+	closed    chan struct{}
+}
+
+// Backoff between attempts to replace a failed instance
+const (
+	replaceBackoff    = 100 * time.Millisecond
+	maxReplaceBackoff = 10 * time.Second
+)
+
+// Load compiles a filter module and instantiates its pool
+func Load(ctx context.Context, cfg Config) (*Filter, error) {
+	if cfg.MaxMemoryPages == 0 {
+		cfg.MaxMemoryPages = 256
+	}
+	if cfg.Timeout == 0 {
+		cfg.Timeout = 10 * time.Millisecond
+	}
+	if cfg.Instances == 0 {
+		cfg.Instances = 8
+	}
+
+	wasm, err := os.ReadFile(cfg.Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read filter %s: %w", cfg.Name, err)
+	}
+
+	rt := wazero.NewRuntimeWithConfig(ctx, wazero.NewRuntimeConfig().
+		WithMemoryLimitPages(cfg.MaxMemoryPages).
+		WithCloseOnContextDone(true))
+	wasi_snapshot_preview1.MustInstantiate(ctx, rt)
+
+	compiled, err := rt.CompileModule(ctx, wasm)
+	if err != nil {
+		rt.Close(ctx)
+		return nil, fmt.Errorf("failed to compile filter %s: %w", cfg.Name, err)
+	}
+
+	f := &Filter{
+		name:     cfg.Name,
+		timeout:  cfg.Timeout,
+		runtime:  rt,
+		compiled: compiled,
+		pool:     make(chan api.Module, cfg.Instances),
+		closed:   make(chan struct{}),
+	}
+	for i := 0; i < cfg.Instances; i++ {
+		mod, err := f.instantiate(ctx)
+		if err != nil {
+			rt.Close(ctx)
+			return nil, err
+		}
+		f.pool <- mod
+	}
+	return f, nil
+}
+
+// instantiate creates one module instance and checks its exports
+func (f *Filter) instantiate(ctx context.Context) (api.Module, error) {
+	// Anonymous instances so the pool can hold several copies of the same module
+	mod, err := f.runtime.InstantiateModule(ctx, f.compiled, wazero.NewModuleConfig().WithName(""))
+	if err != nil {
+		return nil, fmt.Errorf("failed to instantiate filter %s: %w", f.name, err)
+	}
+	if mod.ExportedFunction("alloc") == nil || mod.ExportedFunction("filter") == nil {
+		mod.Close(ctx)
+		return nil, fmt.Errorf("%w: %s needs alloc and filter", ErrMissingExport, f.name)
+	}
+	return mod, nil
+}
+
+// Name returns the filter name
+func (f *Filter) Name() string {
+	return f.name
+}
+
+// Run executes the filter for a request. Any failure (a timeout, a trap, bad
+// output) discards the instance, whose memory may be left in an undefined
+// state, and a fresh one replaces it so one bad request can't poison the pool.
+func (f *Filter) Run(ctx context.Context, req Request) (Result, error) {
+	var mod api.Module
+	select {
+	case mod = <-f.pool:
+	case <-ctx.Done():
+		return Result{}, ErrPoolExhausted
+	}
+
+	callCtx, cancel := context.WithTimeout(ctx, f.timeout)
+	defer cancel()
+
+	res, err := f.call(callCtx, mod, req)
+	if err != nil {
+		go f.replace(mod)
+		return Result{}, fmt.Errorf("%w: %s: %v", ErrFilterFailed, f.name, err)
+	}
+	f.pool <- mod
+	return res, nil
+}
+
+// replace closes a failed instance and builds a fresh one in the background.
+// Instantiation is retried with backoff until it succeeds or the filter is
+// closed, so a transient failure doesn't shrink the pool for good.
+func (f *Filter) replace(dead api.Module) {
+	ctx := context.Background()
+	_ = dead.Close(ctx)
+	backoff := replaceBackoff
+	for {
+		mod, err := f.instantiate(ctx)
+		if err == nil {
+			f.pool <- mod
+			return
+		}
+		select {
+		case <-f.closed:
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(2*backoff, maxReplaceBackoff)
+	}
+}
+
+// call marshals the request into guest memory, invokes filter and decodes the result
+func (f *Filter) call(ctx context.Context, mod api.Module, req Request) (Result, error) {
+	in, err := json.Marshal(req)
+	if err != nil {
+		return Result{}, err
+	}
+
+	ret, err := mod.ExportedFunction("alloc").Call(ctx, uint64(len(in)))
+	if err != nil {
+		return Result{}, err
+	}
+	ptr := uint32(ret[0])
+	if !mod.Memory().Write(ptr, in) {
+		return Result{}, errors.New("input out of guest memory bounds")
+	}
+
+	ret, err = mod.ExportedFunction("filter").Call(ctx, uint64(ptr), uint64(len(in)))
+	if err != nil {
+		return Result{}, err
+	}
+	outPtr, outLen := uint32(ret[0]>>32), uint32(ret[0])
+	out, ok := mod.Memory().Read(outPtr, outLen)
+	if !ok {
+		return Result{}, errors.New("output out of guest memory bounds")
+	}
+
+	var res Result
+	if err := json.Unmarshal(out, &res); err != nil {
+		return Result{}, fmt.Errorf("invalid filter output: %w", err)
+	}
+	return res, nil
+}
+
+// Close releases the runtime and all instances
+func (f *Filter) Close(ctx context.Context) error {
+	f.closeOnce.Do(func() { close(f.closed) })
+	return f.runtime.Close(ctx)
+}
+
+// Apply runs a filter chain against r, applying header changes in order.
+// It returns a non-nil Result when a filter denied the request.
+func Apply(ctx context.Context, filters []*Filter, r *http.Request, subject string, scopes []string) (*Result, error) {
+	for _, f := range filters {
+		res, err := f.Run(ctx, Request{
+			Method:  r.Method,
+			Path:    r.URL.Path,
+			Query:   r.URL.RawQuery,
+			Headers: r.Header,
+			Subject: subject,
+			Scopes:  scopes,
+		})
+		if err != nil {
+			return nil, err
+		}
+		if res.Deny {
+			if res.Status == 0 {
+				res.Status = http.StatusForbidden
+			}
+			return &res, nil
+		}
+		for _, h := range res.RemoveHeaders {
+			r.Header.Del(h)
+		}
+		for k, v := range res.SetHeaders {
+			r.Header.Set(k, v)
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,211 @@
+package policy
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"mime"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/example/privacy-gateway/internal/shared/httpx"
+	"github.com/example/privacy-gateway/internal/shared/models"
+)
+
+// DefaultMaxInspectBytes caps how much of a body is buffered for condition checks
+const DefaultMaxInspectBytes = 64 << 10
+
+var (
+	ErrBodyConditionFailed = errors.New("request body condition not satisfied")
+	ErrBodyNotJSON         = errors.New("request body must be JSON")
+	ErrBodyTooLarge        = errors.New("request body too large to inspect")
+)
+
+// CheckBody evaluates body conditions against a JSON request body. At most
+// maxBytes are buffered; the body is then restored so the proxy streams the
+// original bytes upstream. Conditions exempted by one of the caller's scopes are
+// skipped, and the body is not read at all if no condition applies.
+func CheckBody(r *http.Request, conds []models.BodyCondition, scopes []string, maxBytes int64) error {
+	active := make([]models.BodyCondition, 0, len(conds))
+	for _, c := range conds {
+		if !hasAny(scopes, c.ExemptScopes) {
+			active = append(active, c)
+		}
+	}
+	if len(active) == 0 {
+		return nil
+	}
+
+	if mt, _, err := mime.ParseMediaType(r.Header.Get("Content-Type")); err != nil ||
+		(mt != "application/json" && !strings.HasSuffix(mt, "+json")) {
+		return ErrBodyNotJSON
+	}
+	if maxBytes <= 0 {
+		maxBytes = DefaultMaxInspectBytes
+	}
+	if r.ContentLength > maxBytes {
+		return ErrBodyTooLarge
+	}
+
+	buf, err := io.ReadAll(io.LimitReader(r.Body, maxBytes+1))
+	r.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(buf), r.Body), r.Body}
+	if err != nil {
+		return err
+	}
+	if int64(len(buf)) > maxBytes {
+		return ErrBodyTooLarge
+	}
+
+	// Upstream parsers may keep the first duplicate key or read a second
+	// value, so anything ambiguous is refused rather than checked
+	if err := httpx.CheckStrictJSON(buf, 0); err != nil {
+		return fmt.Errorf("%w: %v", ErrBodyNotJSON, err)
+	}
+	dec := json.NewDecoder(bytes.NewReader(buf))
+	dec.UseNumber()
+	var body interface{}
+	if err := dec.Decode(&body); err != nil {
+		return ErrBodyNotJSON
+	}
+
+	for _, c := range active {
+		val, found := lookupField(body, c.Field)
+		ok, err := compare(val, found, c.Op, c.Value)
+		if err != nil {
+			return fmt.Errorf("condition on %s: %w", c.Field, err)
+		}
+		if !ok {
+			return fmt.Errorf("%w: %s %s", ErrBodyConditionFailed, c.Field, c.Op)
+		}
+	}
+	return nil
+}
+
+// lookupField resolves a dot path (with numeric array indexes) in a decoded body
+func lookupField(body interface{}, path string) (interface{}, bool) {
+	cur := body
+	for _, part := range strings.Split(path, ".") {
+		switch v := cur.(type) {
+		case map[string]interface{}:
+			next, ok := v[part]
+			if !ok {
+				return nil, false
+			}
+			cur = next
+		case []interface{}:
+			i, err := strconv.Atoi(part)
+			if err != nil || i < 0 || i >= len(v) {
+				return nil, false
+			}
+			cur = v[i]
+		default:
+			return nil, false
+		}
+	}
+	return cur, true
+}
+
+// compare applies op to a body value and the configured operand
+func compare(val interface{}, found bool, op string, operand interface{}) (bool, error) {
+	switch op {
+	case "exists":
+		return found, nil
+	case "eq", "ne":
+		eq := found && equalJSON(val, operand)
+		return eq == (op == "eq"), nil
+	case "in":
+		list, ok := operand.([]interface{})
+		if !ok {
+			return false, errors.New("'in' requires a list value")
+		}
+		for _, item := range list {
+			if found && equalJSON(val, item) {
+				return true, nil
+			}
+		}
+		return false, nil
+	case "lt", "lte", "gt", "gte":
+		if !found {
+			return false, nil
+		}
+		a, ok := toRat(val)
+		if !ok {
+			return false, nil
+		}
+		b, ok := toRat(operand)
+		if !ok {
+			return false, fmt.Errorf("'%s' requires a numeric value", op)
+		}
+		c := a.Cmp(b)
+		switch op {
+		case "lt":
+			return c < 0, nil
+		case "lte":
+			return c <= 0, nil
+		case "gt":
+			return c > 0, nil
+		default:
+			return c >= 0, nil
+		}
+	}
+	return false, fmt.Errorf("unknown operator %q", op)
+}
+
+// equalJSON compares decoded JSON values, treating numbers numerically
+func equalJSON(a, b interface{}) bool {
+	if ra, ok := toRat(a); ok {
+		rb, ok := toRat(b)
+		return ok && ra.Cmp(rb) == 0
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// maxNumberExponent bounds the exponent of a body number compared exactly;
+// big.Rat would otherwise expand 1e999999999 in full
+const maxNumberExponent = 1000
+
+// toRat converts a JSON number to an exact rational. Body numbers stay
+// json.Number so large integers and long decimals aren't rounded to a
+// float64 that compares equal to a different value.
+func toRat(v interface{}) (*big.Rat, bool) {
+	switch n := v.(type) {
+	case json.Number:
+		s := string(n)
+		if i := strings.IndexAny(s, "eE"); i >= 0 {
+			exp, err := strconv.Atoi(strings.TrimPrefix(s[i+1:], "+"))
+			if err != nil || exp > maxNumberExponent || exp < -maxNumberExponent {
+				return nil, false
+			}
+		}
+		return new(big.Rat).SetString(s)
+	case float64:
+		if math.IsInf(n, 0) || math.IsNaN(n) {
+			return nil, false
+		}
+		return new(big.Rat).SetFloat64(n), true
+	case int:
+		return new(big.Rat).SetInt64(int64(n)), true
+	}
+	return nil, false
+}
+
+// hasAny reports whether have contains any of want
+func hasAny(have, want []string) bool {
+	for _, w := range want {
+		for _, h := range have {
+			if h == w {
+				return true
+			}
+		}
+	}
+	return false
+}
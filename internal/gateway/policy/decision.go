@@ -0,0 +1,192 @@
+package policy
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/example/privacy-gateway/internal/shared/models"
+)
+
+// Decision is the outcome of evaluating a request against a policy
+type Decision struct {
+	Allowed  bool   `json:"allowed"`
+	PolicyID string `json:"policy_id,omitempty"`
+	Reason   string `json:"reason,omitempty"`
+	// Shadow marks a denial by a shadow policy that was let through
+	Shadow bool `json:"shadow,omitempty"`
+}
+
+// Denial reasons
+const (
+	ReasonMissingScope     = "missing_scope"
+	ReasonMissingVCType    = "missing_vc_type"
+	ReasonIssuerNotAllowed = "issuer_not_allowed"
+	ReasonTrustTierTooLow  = "trust_tier_too_low"
+	ReasonTrustScoreTooLow = "trust_score_too_low"
+	ReasonIssuerNotLinked  = "issuer_not_domain_linked"
+	ReasonAPIKeyNotAllowed = "api_key_not_allowed"
+	ReasonNoMatchingPolicy = "no_matching_policy"
+)
+
+// Caller is what a request presented: token scopes and, when a credential
+// was verified, its types and issuer
+type Caller struct {
+	Scopes    []string
+	VCTypes   []string
+	Issuer    string
+	TrustTier int
+	// IssuerLinked is the result of CheckIssuerLinkage, for policies that
+	// require a domain-linked issuer
+	IssuerLinked bool
+	APIKey       bool
+	TrustScore   int // The token's trust_score claim
+}
+
+// rule is one access requirement of a policy. check returns "" when the
+// caller satisfies it and otherwise what is missing.
+type rule struct {
+	name    string // Policy field
+	reason  string
+	applies func(pol *models.Policy) bool
+	check   func(pol *models.Policy, c Caller) string
+}
+
+// rules are checked in order; the first failing one decides the reason
+var rules = []rule{
+	{
+		name: "allow_api_keys", reason: ReasonAPIKeyNotAllowed,
+		applies: func(pol *models.Policy) bool { return !pol.AllowAPIKeys },
+		check: func(pol *models.Policy, c Caller) string {
+			if c.APIKey {
+				return "API keys are not accepted"
+			}
+			return ""
+		},
+	},
+	{
+		name: "required_scopes", reason: ReasonMissingScope,
+		applies: func(pol *models.Policy) bool { return len(pol.RequiredScopes) > 0 },
+		check: func(pol *models.Policy, c Caller) string {
+			if missing := missing(c.Scopes, pol.RequiredScopes); len(missing) > 0 {
+				return "missing " + strings.Join(missing, ", ")
+			}
+			return ""
+		},
+	},
+	{
+		name: "required_vc_types", reason: ReasonMissingVCType,
+		applies: func(pol *models.Policy) bool { return len(pol.RequiredVCTypes) > 0 },
+		check: func(pol *models.Policy, c Caller) string {
+			if missing := missing(c.VCTypes, pol.RequiredVCTypes); len(missing) > 0 {
+				return "missing " + strings.Join(missing, ", ")
+			}
+			return ""
+		},
+	},
+	{
+		name: "allowed_issuers", reason: ReasonIssuerNotAllowed,
+		applies: func(pol *models.Policy) bool { return len(pol.AllowedIssuers) > 0 },
+		check: func(pol *models.Policy, c Caller) string {
+			if !hasAny(pol.AllowedIssuers, []string{c.Issuer}) {
+				return fmt.Sprintf("issuer %q is not allowed", c.Issuer)
+			}
+			return ""
+		},
+	},
+	{
+		name: "min_trust_tier", reason: ReasonTrustTierTooLow,
+		applies: func(pol *models.Policy) bool { return pol.MinTrustTier != nil },
+		check: func(pol *models.Policy, c Caller) string {
+			if c.TrustTier < *pol.MinTrustTier {
+				return fmt.Sprintf("trust tier %d is below %d", c.TrustTier, *pol.MinTrustTier)
+			}
+			return ""
+		},
+	},
+	{
+		name: "trust_score", reason: ReasonTrustScoreTooLow,
+		applies: func(pol *models.Policy) bool { return pol.TrustScore != nil && pol.TrustScore.RateLimit == nil },
+		check: func(pol *models.Policy, c Caller) string {
+			ts := pol.TrustScore
+			if c.TrustScore >= ts.MinScore || (ts.StepUp && c.Issuer != "") {
+				return ""
+			}
+			if ts.StepUp {
+				return fmt.Sprintf("trust score %d is below %d; present a credential to continue", c.TrustScore, ts.MinScore)
+			}
+			return fmt.Sprintf("trust score %d is below %d", c.TrustScore, ts.MinScore)
+		},
+	},
+	{
+		name: "require_domain_linked_issuer", reason: ReasonIssuerNotLinked,
+		applies: func(pol *models.Policy) bool { return pol.RequireDomainLinkedIssuer },
+		check: func(pol *models.Policy, c Caller) string {
+			if !c.IssuerLinked {
+				return "issuer is not linked to its domain"
+			}
+			return ""
+		},
+	},
+}
+
+// Evaluate checks a caller against a policy's access requirements: every
+// required scope and VC type, the issuer allowlist and minimum trust tier,
+// the trust score rule, domain linkage and API key use. Rate limits, quotas
+// and body conditions are enforced separately.
+func Evaluate(pol *models.Policy, c Caller) Decision {
+	for i := range rules {
+		r := &rules[i]
+		if r.applies(pol) && r.check(pol, c) != "" {
+			return Decision{PolicyID: pol.ID, Reason: r.reason}
+		}
+	}
+	return Decision{Allowed: true, PolicyID: pol.ID}
+}
+
+// RateLimitFor returns the rate limit that applies to the caller: the trust
+// score rule's for callers below its minimum score (unless a credential
+// stepped them up), otherwise the policy's
+func RateLimitFor(pol *models.Policy, c Caller) *models.RateLimit {
+	ts := pol.TrustScore
+	if ts != nil && ts.RateLimit != nil && c.TrustScore < ts.MinScore && !(ts.StepUp && c.Issuer != "") {
+		return ts.RateLimit
+	}
+	return pol.RateLimit
+}
+
+// Check is the outcome of one access requirement
+type Check struct {
+	Rule   string `json:"rule"` // Policy field
+	Passed bool   `json:"passed"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// Explain evaluates like Evaluate and also returns every requirement the
+// policy sets, with what was missing for those that failed
+func Explain(pol *models.Policy, c Caller) (Decision, []Check) {
+	d := Decision{Allowed: true, PolicyID: pol.ID}
+	var checks []Check
+	for i := range rules {
+		r := &rules[i]
+		if !r.applies(pol) {
+			continue
+		}
+		detail := r.check(pol, c)
+		checks = append(checks, Check{Rule: r.name, Passed: detail == "", Detail: detail})
+		if detail != "" && d.Allowed {
+			d.Allowed, d.Reason = false, r.reason
+		}
+	}
+	return d, checks
+}
+
+// missing returns the entries of want that have lacks
+func missing(have, want []string) []string {
+	var out []string
+	for _, w := range want {
+		if !hasAny(have, []string{w}) {
+			out = append(out, w)
+		}
+	}
+	return out
+}
@@ -0,0 +1,106 @@
+package policy
+
+import (
+	"context"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/example/privacy-gateway/internal/shared/cache"
+	"github.com/example/privacy-gateway/internal/shared/clock"
+	"github.com/example/privacy-gateway/internal/shared/models"
+)
+
+// decisionCost is the L1 cost charged per cached decision
+const decisionCost = 64
+
+// DecisionCache memoizes policy decisions keyed by (policy version, token jti, route).
+// Entries are only valid for the policy version they were computed under;
+// after a version change lookups use the new version's keys, so stale
+// decisions are never served and the old entries expire on their own.
+type DecisionCache struct {
+	l1      *cache.RistrettoCache
+	ttl     time.Duration
+	clock   clock.Clock
+	version atomic.Int64
+}
+
+// NewDecisionCache creates a decision cache backed by a dedicated L1 cache.
+// ttl should not exceed the shortest access token lifetime. clk paces Watch
+// (default clock.Real).
+func NewDecisionCache(l1 *cache.RistrettoCache, ttl time.Duration, clk clock.Clock) *DecisionCache {
+	if ttl == 0 {
+		ttl = time.Minute
+	}
+	return &DecisionCache{l1: l1, ttl: ttl, clock: clock.Or(clk)}
+}
+
+// decisionKey builds the cache key for a decision
+func decisionKey(version int64, jti, route string) string {
+	return cache.PolicyKeys.Key("decision", strconv.FormatInt(version, 10), jti, route)
+}
+
+// Get returns a cached decision for the current policy version
+func (c *DecisionCache) Get(jti, route string) (Decision, bool) {
+	val, ok := c.l1.Get(decisionKey(c.version.Load(), jti, route))
+	if !ok {
+		return Decision{}, false
+	}
+	d, ok := val.(Decision)
+	return d, ok
+}
+
+// Set stores a decision computed under the given policy version.
+// Decisions computed under an outdated version are dropped.
+func (c *DecisionCache) Set(version int64, jti, route string, d Decision) {
+	if jti == "" || version != c.version.Load() {
+		return
+	}
+	c.l1.Set(decisionKey(version, jti, route), d, decisionCost, c.ttl)
+}
+
+// Version returns the policy version the cache is currently serving
+func (c *DecisionCache) Version() int64 {
+	return c.version.Load()
+}
+
+// SetVersion records a new policy version and reports whether it changed.
+// It doesn't clear the L1 cache: ristretto's Clear is unsafe alongside
+// concurrent Get and Set, and keys carry the version anyway.
+func (c *DecisionCache) SetVersion(version int64) bool {
+	return c.version.Swap(version) != version
+}
+
+// Evaluate is Evaluate served from the cache. Decisions are keyed on the
+// token's jti and the policy, so the caller must be derived from that token
+// alone; with an empty jti the decision is computed every time.
+func (c *DecisionCache) Evaluate(pol *models.Policy, caller Caller, jti string) Decision {
+	// Load the version first so a decision racing a policy change is
+	// stored under the old version and never served
+	version := c.version.Load()
+	if jti != "" {
+		if d, ok := c.Get(jti, pol.ID); ok {
+			return d
+		}
+	}
+	d := Evaluate(pol, caller)
+	c.Set(version, jti, pol.ID, d)
+	return d
+}
+
+// Watch polls the policy version at the given interval until ctx is cancelled
+func (c *DecisionCache) Watch(ctx context.Context, interval time.Duration, version func(context.Context) (int64, error)) {
+	ticker := clock.NewTicker(c.clock, interval)
+	defer ticker.Stop()
+
+	for {
+		if v, err := version(ctx); err == nil {
+			c.SetVersion(v)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package policy_test
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/example/privacy-gateway/internal/gateway/policy"
+	"github.com/example/privacy-gateway/internal/shared/cache"
+	"github.com/example/privacy-gateway/internal/shared/clock"
+	"github.com/example/privacy-gateway/internal/shared/models"
+)
+
+// TestDecisionCacheWatch drives the version poll with a fake clock and
+// checks that a version change stops serving the old decision
+func TestDecisionCacheWatch(t *testing.T) {
+	l1, err := cache.NewRistrettoCache(1<<20, 1e4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clk := clock.NewFake(time.Unix(1_700_000_000, 0))
+	dc := policy.NewDecisionCache(l1, time.Minute, clk)
+
+	var version atomic.Int64
+	version.Store(1)
+	polled := make(chan int64, 16)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go dc.Watch(ctx, 5*time.Second, func(context.Context) (int64, error) {
+		v := version.Load()
+		polled <- v
+		return v, nil
+	})
+	waitPoll := func(want int64) {
+		t.Helper()
+		select {
+		case v := <-polled:
+			if v != want {
+				t.Fatalf("polled version %d, want %d", v, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("version not polled")
+		}
+	}
+	waitPoll(1)
+
+	pol := &models.Policy{ID: "p", RequiredScopes: []string{"read"}}
+	if d := dc.Evaluate(pol, policy.Caller{Scopes: []string{"read"}}, "jti-1"); !d.Allowed {
+		t.Fatalf("decision = %+v, want allowed", d)
+	}
+	l1.Wait()
+
+	// The cached decision is served even though the caller changed
+	if d := dc.Evaluate(pol, policy.Caller{}, "jti-1"); !d.Allowed {
+		t.Fatal("cached decision not served")
+	}
+
+	// Nothing polls until the clock passes the interval
+	version.Store(2)
+	clk.Advance(4 * time.Second)
+	select {
+	case <-polled:
+		t.Fatal("polled before the interval")
+	case <-time.After(20 * time.Millisecond):
+	}
+	clk.Advance(time.Second)
+	waitPoll(2)
+	for dc.Version() != 2 {
+		time.Sleep(time.Millisecond)
+	}
+
+	if d := dc.Evaluate(pol, policy.Caller{}, "jti-1"); d.Allowed {
+		t.Fatal("decision from the old policy version served")
+	}
+}
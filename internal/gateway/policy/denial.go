@@ -0,0 +1,97 @@
+package policy
+
+import (
+	"net/http"
+
+	"github.com/example/privacy-gateway/internal/shared/httpx"
+	"github.com/example/privacy-gateway/internal/shared/i18n"
+	"github.com/example/privacy-gateway/internal/shared/tenant"
+)
+
+// DebugScope lets an API key caller see why its requests are denied. Only
+// admins can grant it; it can't be requested for a token.
+const DebugScope = "debug"
+
+// Denial reasons decided outside policy rules
+const (
+	ReasonCredentialRevoked = "credential_revoked"
+)
+
+// Explanation says why a request was denied
+type Explanation struct {
+	PolicyID string  `json:"policy_id,omitempty"`
+	Route    string  `json:"route,omitempty"`
+	Rule     string  `json:"rule,omitempty"` // Policy field or check that failed
+	Reason   string  `json:"reason"`
+	Detail   string  `json:"detail,omitempty"`
+	Checks   []Check `json:"checks,omitempty"`
+}
+
+// DenialResponse is the body of a 403. Message explains the denial reason
+// in the caller's language (Accept-Language). Explanation is only included
+// in debug mode or for API key callers holding DebugScope.
+type DenialResponse struct {
+	Error       string       `json:"error"`
+	Message     string       `json:"message,omitempty"`
+	Explanation *Explanation `json:"explanation,omitempty"`
+}
+
+// Denial describes a denied request
+type Denial struct {
+	Decision Decision
+	Match    *Match // nil when no policy matched
+	Caller   Caller
+	// Rule and Detail describe denials outside the policy's access rules,
+	// e.g. Rule "credential_status" with the revoked credential's status entry
+	Rule   string
+	Detail string
+}
+
+// Denials writes denial responses
+type Denials struct {
+	// Debug explains every denial; for staging, never production, since
+	// explanations reveal policy requirements
+	Debug bool
+	// Metrics, when set, counts denials by tenant (the caller's credential
+	// issuer) and reason
+	Metrics *tenant.Metrics
+}
+
+// Explain builds the explanation of a denial
+func (dn Denial) Explain() *Explanation {
+	ex := &Explanation{PolicyID: dn.Decision.PolicyID, Reason: dn.Decision.Reason, Rule: dn.Rule, Detail: dn.Detail}
+	if dn.Match == nil || dn.Match.Policy == nil {
+		return ex
+	}
+	ex.PolicyID, ex.Route = dn.Match.Policy.ID, dn.Match.Route
+	if dn.Rule != "" {
+		return ex
+	}
+	_, ex.Checks = Explain(dn.Match.Policy, dn.Caller)
+	for _, c := range ex.Checks {
+		if !c.Passed {
+			ex.Rule, ex.Detail = c.Rule, c.Detail
+			break
+		}
+	}
+	return ex
+}
+
+// Write sends a 403 for dn to the caller of r, explained when debug mode is
+// on or the caller is an API key holding DebugScope
+func (d *Denials) Write(w http.ResponseWriter, r *http.Request, dn Denial) {
+	if d.Metrics != nil {
+		d.Metrics.Denied(dn.Caller.Issuer, dn.Decision.Reason)
+	}
+	lang := i18n.ForRequest(w, r)
+	msg := i18n.Message(lang, dn.Decision.Reason)
+	if msg == "" {
+		msg = i18n.Message(lang, i18n.CodeForbidden)
+	}
+	resp := DenialResponse{Error: "forbidden", Message: msg}
+	if d.Debug || (dn.Caller.APIKey && hasAny(dn.Caller.Scopes, []string{DebugScope})) {
+		resp.Explanation = dn.Explain()
+		w.Header().Set("Cache-Control", "no-store")
+	}
+	httpx.WriteJSON(w, http.StatusForbidden, resp)
+}
@@ -0,0 +1,32 @@
+package policy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/example/privacy-gateway/internal/shared/models"
+)
+
+var ErrIssuerNotLinked = errors.New("credential issuer is not domain-linked")
+
+// LinkageVerifier confirms a DID is linked to its domain; did.LinkageVerifier
+// implements it
+type LinkageVerifier interface {
+	VerifyLinkage(ctx context.Context, did string) error
+}
+
+// CheckIssuerLinkage enforces require_domain_linked_issuer: the issuer of the
+// presented credential must pass well-known DID configuration verification
+func CheckIssuerLinkage(ctx context.Context, pol *models.Policy, issuer string, v LinkageVerifier) error {
+	if !pol.RequireDomainLinkedIssuer {
+		return nil
+	}
+	if issuer == "" {
+		return fmt.Errorf("%w: no credential presented", ErrIssuerNotLinked)
+	}
+	if err := v.VerifyLinkage(ctx, issuer); err != nil {
+		return fmt.Errorf("%w: %v", ErrIssuerNotLinked, err)
+	}
+	return nil
+}
@@ -0,0 +1,253 @@
+package policy
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/example/privacy-gateway/internal/shared/models"
+)
+
+var ErrInvalidRoute = errors.New("invalid route pattern")
+
+// Router matches requests to policies. Route patterns are compiled into a
+// segment radix tree at load time:
+//
+//	/api/v1/public          static segments
+//	/api/v1/users/{id}      named parameter (one segment)
+//	/api/v1/files/{name:.+\.pdf}  parameter constrained by a regex
+//	/api/v1/premium/*       wildcard (zero or more trailing segments)
+//
+// Policies without a Route fall back to RoutePrefix, which is treated as
+// "<prefix>/*". When several routes match, the highest Priority wins, then the
+// most specific pattern (static > regex param > param > wildcard), then a
+// route that lists the request method explicitly.
+type Router struct {
+	root *node
+}
+
+// Match is the result of routing a request
+type Match struct {
+	Policy *models.Policy
+	Route  string
+	Params map[string]string
+}
+
+// node is a radix tree node keyed by path segment
+type node struct {
+	static   map[string]*node
+	params   []*paramEdge
+	wildcard []*route
+	leaves   []*route
+}
+
+// paramEdge is a parameter segment, optionally constrained by a regex
+type paramEdge struct {
+	name  string
+	re    *regexp.Regexp
+	child *node
+}
+
+// route is a compiled policy route
+type route struct {
+	policy  *models.Policy
+	pattern string
+	methods map[string]bool
+	params  []string
+	static  int // static segments
+	regex   int // regex-constrained params
+	dynamic int // unconstrained params
+}
+
+// NewRouter compiles policy routes into a route tree
+func NewRouter(policies []models.Policy) (*Router, error) {
+	r := &Router{root: newNode()}
+	for i := range policies {
+		pol := &policies[i]
+		pattern := pol.Route
+		if pattern == "" {
+			if pol.RoutePrefix == "" {
+				continue
+			}
+			pattern = strings.TrimSuffix(pol.RoutePrefix, "/") + "/*"
+		}
+		if err := r.add(pol, pattern); err != nil {
+			return nil, fmt.Errorf("policy %s: %w", pol.ID, err)
+		}
+	}
+	return r, nil
+}
+
+func newNode() *node {
+	return &node{static: make(map[string]*node)}
+}
+
+// add compiles a single pattern into the tree
+func (r *Router) add(pol *models.Policy, pattern string) error {
+	if !strings.HasPrefix(pattern, "/") {
+		return fmt.Errorf("%w: %q must start with /", ErrInvalidRoute, pattern)
+	}
+
+	rt := &route{policy: pol, pattern: pattern}
+	if len(pol.Methods) > 0 {
+		rt.methods = make(map[string]bool, len(pol.Methods))
+		for _, m := range pol.Methods {
+			rt.methods[strings.ToUpper(m)] = true
+		}
+	}
+
+	n := r.root
+	segments := splitPath(pattern)
+	for i, seg := range segments {
+		switch {
+		case seg == "*":
+			if i != len(segments)-1 {
+				return fmt.Errorf("%w: %q wildcard must be last", ErrInvalidRoute, pattern)
+			}
+			n.wildcard = append(n.wildcard, rt)
+			return nil
+
+		case strings.HasPrefix(seg, "{") && strings.HasSuffix(seg, "}"):
+			name, expr, _ := strings.Cut(seg[1:len(seg)-1], ":")
+			if name == "" {
+				return fmt.Errorf("%w: %q has an unnamed parameter", ErrInvalidRoute, pattern)
+			}
+			edge, err := n.paramEdge(name, expr)
+			if err != nil {
+				return fmt.Errorf("%w: %q: %v", ErrInvalidRoute, pattern, err)
+			}
+			if edge.re != nil {
+				rt.regex++
+			} else {
+				rt.dynamic++
+			}
+			rt.params = append(rt.params, name)
+			n = edge.child
+
+		default:
+			child, ok := n.static[seg]
+			if !ok {
+				child = newNode()
+				n.static[seg] = child
+			}
+			rt.static++
+			n = child
+		}
+	}
+
+	n.leaves = append(n.leaves, rt)
+	return nil
+}
+
+// paramEdge returns (creating if needed) the parameter edge for name/expr
+func (n *node) paramEdge(name, expr string) (*paramEdge, error) {
+	for _, e := range n.params {
+		if e.name == name && ((e.re == nil && expr == "") || (e.re != nil && e.re.String() == "^(?:"+expr+")$")) {
+			return e, nil
+		}
+	}
+	edge := &paramEdge{name: name, child: newNode()}
+	if expr != "" {
+		re, err := regexp.Compile("^(?:" + expr + ")$")
+		if err != nil {
+			return nil, err
+		}
+		edge.re = re
+	}
+	// Keep regex-constrained params ahead of unconstrained ones
+	if edge.re != nil {
+		n.params = append([]*paramEdge{edge}, n.params...)
+	} else {
+		n.params = append(n.params, edge)
+	}
+	return edge, nil
+}
+
+// Match finds the best policy for a request
+func (r *Router) Match(method, path string) (Match, bool) {
+	method = strings.ToUpper(method)
+	segments := splitPath(path)
+
+	var (
+		best       *route
+		bestValues []string
+	)
+	consider := func(rt *route, values []string) {
+		if rt.methods != nil && !rt.methods[method] {
+			return
+		}
+		if best == nil || rt.beats(best) {
+			best = rt
+			bestValues = append([]string(nil), values...)
+		}
+	}
+
+	var walk func(n *node, i int, values []string)
+	walk = func(n *node, i int, values []string) {
+		for _, rt := range n.wildcard {
+			consider(rt, values)
+		}
+		if i == len(segments) {
+			for _, rt := range n.leaves {
+				consider(rt, values)
+			}
+			return
+		}
+		seg := segments[i]
+		if child, ok := n.static[seg]; ok {
+			walk(child, i+1, values)
+		}
+		for _, e := range n.params {
+			if e.re != nil && !e.re.MatchString(seg) {
+				continue
+			}
+			walk(e.child, i+1, append(values, seg))
+		}
+	}
+	walk(r.root, 0, nil)
+
+	if best == nil {
+		return Match{}, false
+	}
+
+	m := Match{Policy: best.policy, Route: best.pattern}
+	if len(best.params) > 0 {
+		m.Params = make(map[string]string, len(best.params))
+		for i, name := range best.params {
+			m.Params[name] = bestValues[i]
+		}
+	}
+	return m, true
+}
+
+// beats reports whether rt should be preferred over other
+func (rt *route) beats(other *route) bool {
+	if rt.policy.Priority != other.policy.Priority {
+		return rt.policy.Priority > other.policy.Priority
+	}
+	if rt.static != other.static {
+		return rt.static > other.static
+	}
+	if rt.regex != other.regex {
+		return rt.regex > other.regex
+	}
+	if rt.dynamic != other.dynamic {
+		return rt.dynamic > other.dynamic
+	}
+	// Exact routes beat wildcard routes of the same shape
+	rtWild, otherWild := strings.HasSuffix(rt.pattern, "/*"), strings.HasSuffix(other.pattern, "/*")
+	if rtWild != otherWild {
+		return !rtWild
+	}
+	return rt.methods != nil && other.methods == nil
+}
+
+// splitPath splits a path into non-empty segments
+func splitPath(path string) []string {
+	path = strings.Trim(path, "/")
+	if path == "" {
+		return nil
+	}
+	return strings.Split(path, "/")
+}
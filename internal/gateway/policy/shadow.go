@@ -0,0 +1,272 @@
+package policy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/example/privacy-gateway/internal/shared/cache"
+	"github.com/example/privacy-gateway/internal/shared/models"
+	"github.com/example/privacy-gateway/internal/shared/observability"
+)
+
+// Redis keys of the shared candidate policy set
+var (
+	shadowSetKey     = cache.PolicyKeys.Key("shadow")
+	shadowVersionKey = cache.PolicyKeys.Key("shadow", "version")
+)
+
+// shadowLogInterval limits would-deny logs to one per policy per interval
+const shadowLogInterval = 10 * time.Second
+
+// ShadowCounts counts shadow evaluations of one policy
+type ShadowCounts struct {
+	Evaluated  int64            `json:"evaluated"`
+	WouldDeny  int64            `json:"would_deny"`            // Denied by the shadow decision, allowed live
+	WouldAllow int64            `json:"would_allow,omitempty"` // Allowed by the candidate set, denied live
+	Reasons    map[string]int64 `json:"reasons,omitempty"`     // Shadow denials by reason
+}
+
+// ShadowStats is a replica's shadow evaluation counts
+type ShadowStats struct {
+	// Policies counts live policies marked shadow, by policy ID
+	Policies map[string]ShadowCounts `json:"policies"`
+	// Candidate compares the candidate policy set with the live one, by
+	// candidate policy ID ("" counts requests no candidate policy matches)
+	Candidate        map[string]ShadowCounts `json:"candidate,omitempty"`
+	CandidateVersion int64                   `json:"candidate_version,omitempty"`
+	CandidateLoaded  *time.Time              `json:"candidate_loaded,omitempty"`
+}
+
+type candidateSet struct {
+	router  *Router
+	version int64
+	loaded  time.Time
+}
+
+// Shadow evaluates policies without enforcing them, to measure the denial
+// impact of a change before it goes live. Two modes:
+//
+//   - A live policy with Shadow set is evaluated as usual, but a denial is
+//     recorded and the request let through.
+//   - A candidate policy set (a whole new set, stored in Redis so every
+//     replica sees it) is routed and evaluated next to the live set, and
+//     every disagreement is counted; the live decision still applies.
+type Shadow struct {
+	client *redis.Client
+	logger *slog.Logger
+
+	candidate atomic.Pointer[candidateSet]
+
+	mu         sync.Mutex
+	policies   map[string]*ShadowCounts
+	compared   map[string]*ShadowCounts
+	lastLog    map[string]time.Time
+	suppressed map[string]int64
+}
+
+// NewShadow creates a shadow evaluator
+func NewShadow(client *redis.Client, logger *slog.Logger) *Shadow {
+	logger = observability.Component(logger, observability.ComponentPolicy)
+	return &Shadow{
+		client:     client,
+		logger:     logger,
+		policies:   make(map[string]*ShadowCounts),
+		compared:   make(map[string]*ShadowCounts),
+		lastLog:    make(map[string]time.Time),
+		suppressed: make(map[string]int64),
+	}
+}
+
+// Apply records the shadow outcome of a request whose live decision is d
+// and returns the decision to enforce. match is the live route match
+// (nil when no live policy matched).
+func (s *Shadow) Apply(method, path string, match *Match, c Caller, d Decision) Decision {
+	if cand := s.candidate.Load(); cand != nil {
+		cd := Decision{Reason: ReasonNoMatchingPolicy}
+		if m, ok := cand.router.Match(method, path); ok {
+			cd = Evaluate(m.Policy, c)
+		}
+		s.compare(cd, d, path)
+	}
+	if match == nil || match.Policy == nil || !match.Policy.Shadow {
+		return d
+	}
+	s.mu.Lock()
+	counts := s.counts(s.policies, d.PolicyID)
+	counts.Evaluated++
+	if !d.Allowed {
+		counts.WouldDeny++
+		counts.Reasons[d.Reason]++
+	}
+	s.mu.Unlock()
+	if d.Allowed {
+		return d
+	}
+	s.logDenial("shadow policy would deny", d.PolicyID, match.Route, d.Reason)
+	d.Allowed, d.Shadow = true, true
+	return d
+}
+
+// compare counts a candidate decision against the live one
+func (s *Shadow) compare(cd, live Decision, path string) {
+	s.mu.Lock()
+	counts := s.counts(s.compared, cd.PolicyID)
+	counts.Evaluated++
+	switch {
+	case live.Allowed && !cd.Allowed:
+		counts.WouldDeny++
+		counts.Reasons[cd.Reason]++
+	case !live.Allowed && cd.Allowed:
+		counts.WouldAllow++
+	}
+	s.mu.Unlock()
+	if live.Allowed && !cd.Allowed {
+		s.logDenial("candidate policy set would deny", cd.PolicyID, path, cd.Reason)
+	}
+}
+
+// counts returns the counters for id in m; callers hold s.mu
+func (s *Shadow) counts(m map[string]*ShadowCounts, id string) *ShadowCounts {
+	c, ok := m[id]
+	if !ok {
+		c = &ShadowCounts{Reasons: make(map[string]int64)}
+		m[id] = c
+	}
+	return c
+}
+
+// logDenial logs at most one denial per policy per shadowLogInterval,
+// with the number suppressed since the last one
+func (s *Shadow) logDenial(msg, policyID, route, reason string) {
+	s.mu.Lock()
+	key := msg + "|" + policyID
+	now := time.Now()
+	if now.Sub(s.lastLog[key]) < shadowLogInterval {
+		s.suppressed[key]++
+		s.mu.Unlock()
+		return
+	}
+	suppressed := s.suppressed[key]
+	s.lastLog[key], s.suppressed[key] = now, 0
+	s.mu.Unlock()
+	s.logger.Info(msg, "policy_id", policyID, "route", route, "reason", reason, "suppressed", suppressed)
+}
+
+// SetCandidate validates a candidate policy set and shares it with every
+// replica; they pick it up on their next Refresh
+func (s *Shadow) SetCandidate(ctx context.Context, policies []models.Policy) (int64, error) {
+	if _, err := NewRouter(policies); err != nil {
+		return 0, err
+	}
+	data, err := json.Marshal(policies)
+	if err != nil {
+		return 0, err
+	}
+	version := time.Now().UnixNano()
+	_, err = s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, shadowSetKey, data, 0)
+		pipe.Set(ctx, shadowVersionKey, version, 0)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return version, s.Refresh(ctx)
+}
+
+// ClearCandidate removes the candidate policy set
+func (s *Shadow) ClearCandidate(ctx context.Context) error {
+	if err := s.client.Del(ctx, shadowSetKey, shadowVersionKey).Err(); err != nil {
+		return err
+	}
+	return s.Refresh(ctx)
+}
+
+// Refresh loads the shared candidate set if it changed, resetting the
+// comparison counts
+func (s *Shadow) Refresh(ctx context.Context) error {
+	version, err := s.client.Get(ctx, shadowVersionKey).Int64()
+	if errors.Is(err, redis.Nil) {
+		if s.candidate.Swap(nil) != nil {
+			s.resetCompared()
+		}
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if cur := s.candidate.Load(); cur != nil && cur.version == version {
+		return nil
+	}
+	data, err := s.client.Get(ctx, shadowSetKey).Bytes()
+	if err != nil {
+		return err
+	}
+	var policies []models.Policy
+	if err := json.Unmarshal(data, &policies); err != nil {
+		return err
+	}
+	router, err := NewRouter(policies)
+	if err != nil {
+		return err
+	}
+	s.candidate.Store(&candidateSet{router: router, version: version, loaded: time.Now().UTC()})
+	s.resetCompared()
+	s.logger.Info("loaded candidate policy set", "version", version, "policies", len(policies))
+	return nil
+}
+
+func (s *Shadow) resetCompared() {
+	s.mu.Lock()
+	s.compared = make(map[string]*ShadowCounts)
+	s.mu.Unlock()
+}
+
+// Watch refreshes the candidate set at the given interval until ctx is cancelled
+func (s *Shadow) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := s.Refresh(ctx); err != nil && ctx.Err() == nil {
+			s.logger.Warn("failed to refresh candidate policy set", "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Stats returns this replica's shadow counts
+func (s *Shadow) Stats() ShadowStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st := ShadowStats{Policies: copyCounts(s.policies)}
+	if cand := s.candidate.Load(); cand != nil {
+		st.Candidate = copyCounts(s.compared)
+		st.CandidateVersion = cand.version
+		st.CandidateLoaded = &cand.loaded
+	}
+	return st
+}
+
+func copyCounts(m map[string]*ShadowCounts) map[string]ShadowCounts {
+	out := make(map[string]ShadowCounts, len(m))
+	for id, c := range m {
+		cp := *c
+		cp.Reasons = make(map[string]int64, len(c.Reasons))
+		for r, n := range c.Reasons {
+			cp.Reasons[r] = n
+		}
+		out[id] = cp
+	}
+	return out
+}
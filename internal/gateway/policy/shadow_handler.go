@@ -0,0 +1,51 @@
+package policy
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/example/privacy-gateway/internal/shared/httpx"
+	"github.com/example/privacy-gateway/internal/shared/models"
+)
+
+type candidateRequest struct {
+	Policies []models.Policy `json:"policies"`
+}
+
+// ShadowHandler serves the shadow evaluation endpoints:
+//
+//	GET    /admin/v1/policies/shadow   this replica's shadow counts
+//	PUT    /admin/v1/policies/shadow   {"policies": [...]} sets the candidate policy set
+//	DELETE /admin/v1/policies/shadow   removes the candidate policy set
+func ShadowHandler(s *Shadow) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			httpx.WriteJSON(w, http.StatusOK, s.Stats())
+		case http.MethodPut:
+			var req candidateRequest
+			if err := httpx.DecodeJSON(r, &req); err != nil || len(req.Policies) == 0 {
+				httpx.WriteJSON(w, http.StatusBadRequest, httpx.ErrorResponse{Error: "policies are required"})
+				return
+			}
+			version, err := s.SetCandidate(r.Context(), req.Policies)
+			if errors.Is(err, ErrInvalidRoute) {
+				httpx.WriteJSON(w, http.StatusBadRequest, httpx.ErrorResponse{Error: err.Error()})
+				return
+			}
+			if err != nil {
+				httpx.WriteJSON(w, http.StatusInternalServerError, httpx.ErrorResponse{Error: "failed to store candidate policy set"})
+				return
+			}
+			httpx.WriteJSON(w, http.StatusOK, map[string]interface{}{"version": version, "policies": len(req.Policies)})
+		case http.MethodDelete:
+			if err := s.ClearCandidate(r.Context()); err != nil {
+				httpx.WriteJSON(w, http.StatusInternalServerError, httpx.ErrorResponse{Error: "failed to remove candidate policy set"})
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			httpx.WriteJSON(w, http.StatusMethodNotAllowed, httpx.ErrorResponse{Error: "method not allowed"})
+		}
+	}
+}
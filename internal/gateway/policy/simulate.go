@@ -0,0 +1,231 @@
+package policy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/example/privacy-gateway/internal/gateway/proxy"
+	"github.com/example/privacy-gateway/internal/shared/httpx"
+	"github.com/example/privacy-gateway/internal/shared/models"
+)
+
+// ReasonBodyCondition is the denial reason for a failed body condition
+const ReasonBodyCondition = "body_condition_failed"
+
+// maxSimulationCases caps the cases in one simulation request
+const maxSimulationCases = 500
+
+// PolicySource lists the live policies; store.Postgres implements it
+type PolicySource interface {
+	ListPolicies(ctx context.Context) ([]models.Policy, error)
+}
+
+// SimulatedCredential is the verified credential of a synthetic request
+type SimulatedCredential struct {
+	Types        []string `json:"types"`
+	Issuer       string   `json:"issuer"`
+	TrustTier    int      `json:"trust_tier"`
+	DomainLinked bool     `json:"domain_linked,omitempty"`
+}
+
+// Expectation is what a regression test case asserts; unset fields are not
+// checked
+type Expectation struct {
+	Allowed  *bool  `json:"allowed,omitempty"`
+	PolicyID string `json:"policy_id,omitempty"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+// SimulationCase is one synthetic request
+type SimulationCase struct {
+	Name       string               `json:"name,omitempty"`
+	Method     string               `json:"method"`
+	Path       string               `json:"path"`
+	DID        string               `json:"did,omitempty"`
+	Scopes     []string             `json:"scopes,omitempty"`
+	APIKey     bool                 `json:"api_key,omitempty"`
+	Credential *SimulatedCredential `json:"credential,omitempty"`
+	TrustScore int                  `json:"trust_score,omitempty"`
+	Body       json.RawMessage      `json:"body,omitempty"` // JSON body for body conditions
+	Expect     *Expectation         `json:"expect,omitempty"`
+}
+
+// SimulationRequest is a single case, or a list of cases, optionally
+// evaluated against a proposed policy set instead of the live one
+type SimulationRequest struct {
+	SimulationCase
+	Cases    []SimulationCase `json:"cases,omitempty"`
+	Policies []models.Policy  `json:"policies,omitempty"`
+}
+
+// SimulationResult explains how a synthetic request was decided
+type SimulationResult struct {
+	Name     string            `json:"name,omitempty"`
+	Matched  bool              `json:"matched"`
+	PolicyID string            `json:"policy_id,omitempty"`
+	Route    string            `json:"route,omitempty"`
+	Params   map[string]string `json:"params,omitempty"`
+	Decision Decision          `json:"decision"`
+	// Rule is the policy field whose requirement denied the request
+	Rule   string  `json:"rule,omitempty"`
+	Checks []Check `json:"checks,omitempty"`
+	// Enforced is whether the decision applies; shadow policies let denials through
+	Enforced bool   `json:"enforced"`
+	Upstream string `json:"upstream,omitempty"` // Traffic split target, when sticky by DID
+	// Pass and Failures report the case's expectation, when it has one
+	Pass     *bool    `json:"pass,omitempty"`
+	Failures []string `json:"failures,omitempty"`
+}
+
+// SimulationReport is the response for a list of cases
+type SimulationReport struct {
+	Results []SimulationResult `json:"results"`
+	Passed  int                `json:"passed"`
+	Failed  int                `json:"failed"`
+}
+
+// Simulate routes and evaluates one synthetic request against router
+func Simulate(router *Router, c SimulationCase) SimulationResult {
+	res := SimulationResult{Name: c.Name, Enforced: true}
+	m, ok := router.Match(c.Method, c.Path)
+	if !ok {
+		res.Decision = Decision{Reason: ReasonNoMatchingPolicy}
+		res.checkExpectation(c.Expect)
+		return res
+	}
+	pol := m.Policy
+	res.Matched, res.PolicyID, res.Route, res.Params = true, pol.ID, m.Route, m.Params
+
+	caller := Caller{Scopes: c.Scopes, APIKey: c.APIKey, TrustScore: c.TrustScore}
+	if c.Credential != nil {
+		caller.VCTypes, caller.Issuer = c.Credential.Types, c.Credential.Issuer
+		caller.TrustTier, caller.IssuerLinked = c.Credential.TrustTier, c.Credential.DomainLinked
+	}
+	res.Decision, res.Checks = Explain(pol, caller)
+	if len(pol.BodyConditions) > 0 {
+		check := Check{Rule: "body_conditions", Passed: true}
+		r := httptest.NewRequest(c.Method, c.Path, bytes.NewReader(c.Body))
+		r.Header.Set("Content-Type", "application/json")
+		if err := CheckBody(r, pol.BodyConditions, c.Scopes, 0); err != nil {
+			check.Passed, check.Detail = false, err.Error()
+			if res.Decision.Allowed {
+				res.Decision.Allowed, res.Decision.Reason = false, ReasonBodyCondition
+			}
+		}
+		res.Checks = append(res.Checks, check)
+	}
+	for _, check := range res.Checks {
+		if !check.Passed {
+			res.Rule = check.Rule
+			break
+		}
+	}
+	if pol.Shadow && !res.Decision.Allowed {
+		res.Enforced = false
+	}
+	if pol.TrafficSplit != nil && pol.TrafficSplit.StickyByDID && c.DID != "" {
+		if t, ok := proxy.PickTarget(pol.ID, pol.TrafficSplit, c.DID); ok {
+			res.Upstream = t.Name
+		}
+	}
+	res.checkExpectation(c.Expect)
+	return res
+}
+
+func (res *SimulationResult) checkExpectation(e *Expectation) {
+	if e == nil {
+		return
+	}
+	var failures []string
+	if e.Allowed != nil && *e.Allowed != res.Decision.Allowed {
+		failures = append(failures, "allowed: expected "+boolString(*e.Allowed))
+	}
+	if e.PolicyID != "" && e.PolicyID != res.PolicyID {
+		failures = append(failures, "policy_id: expected "+e.PolicyID+", got "+res.PolicyID)
+	}
+	if e.Reason != "" && e.Reason != res.Decision.Reason {
+		failures = append(failures, "reason: expected "+e.Reason+", got "+res.Decision.Reason)
+	}
+	pass := len(failures) == 0
+	res.Pass, res.Failures = &pass, failures
+}
+
+func boolString(b bool) string {
+	if b {
+		return "true"
+	}
+	return "false"
+}
+
+// SimulateHandler serves POST /admin/v1/policies/simulate. The body is one case
+// (method, path, did, scopes, api_key, credential, body, expect) or
+// {"cases": [...]}; "policies" evaluates against a proposed policy set
+// instead of the live one. A single case returns its SimulationResult and a
+// list returns a SimulationReport.
+func SimulateHandler(src PolicySource) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			httpx.WriteJSON(w, http.StatusMethodNotAllowed, httpx.ErrorResponse{Error: "method not allowed"})
+			return
+		}
+		var req SimulationRequest
+		if err := httpx.DecodeJSONLimit(r, &req, 4<<20); err != nil {
+			httpx.WriteJSON(w, http.StatusBadRequest, httpx.ErrorResponse{Error: "invalid request body"})
+			return
+		}
+		if len(req.Cases) > maxSimulationCases {
+			httpx.WriteJSON(w, http.StatusBadRequest, httpx.ErrorResponse{Error: "too many cases"})
+			return
+		}
+		cases := req.Cases
+		if len(cases) == 0 {
+			cases = []SimulationCase{req.SimulationCase}
+		}
+		for _, c := range cases {
+			if c.Method == "" || c.Path == "" {
+				httpx.WriteJSON(w, http.StatusBadRequest, httpx.ErrorResponse{Error: "every case needs a method and a path"})
+				return
+			}
+		}
+
+		policies := req.Policies
+		if policies == nil {
+			var err error
+			if policies, err = src.ListPolicies(r.Context()); err != nil {
+				httpx.WriteJSON(w, http.StatusInternalServerError, httpx.ErrorResponse{Error: "failed to load policies"})
+				return
+			}
+		}
+		router, err := NewRouter(policies)
+		if errors.Is(err, ErrInvalidRoute) {
+			httpx.WriteJSON(w, http.StatusBadRequest, httpx.ErrorResponse{Error: err.Error()})
+			return
+		}
+		if err != nil {
+			httpx.WriteJSON(w, http.StatusInternalServerError, httpx.ErrorResponse{Error: "failed to build routes"})
+			return
+		}
+
+		if len(req.Cases) == 0 {
+			httpx.WriteJSON(w, http.StatusOK, Simulate(router, cases[0]))
+			return
+		}
+		report := SimulationReport{Results: make([]SimulationResult, 0, len(cases))}
+		for _, c := range cases {
+			res := Simulate(router, c)
+			switch {
+			case res.Pass == nil:
+			case *res.Pass:
+				report.Passed++
+			default:
+				report.Failed++
+			}
+			report.Results = append(report.Results, res)
+		}
+		httpx.WriteJSON(w, http.StatusOK, report)
+	}
+}
@@ -0,0 +1,54 @@
+package pow
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/example/privacy-gateway/internal/shared/httpx"
+)
+
+// Header carries a puzzle solution: "<challenge>:<nonce>"
+const Header = "X-PoW"
+
+// requiredResponse tells the client to solve the puzzle and retry
+type requiredResponse struct {
+	Error string `json:"error"`
+	PoW   Puzzle `json:"pow"`
+}
+
+// Middleware gates next (e.g. GET /v1/auth/challenge) behind the proof of
+// work while the gate is engaged. Callers without a valid solution get 429
+// with a fresh puzzle; healthy periods pass straight through.
+func (g *Gate) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		err := g.Check(r.Context(), r.Header.Get(Header))
+		if err == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if !isRejection(err) {
+			httpx.WriteJSON(w, http.StatusServiceUnavailable, httpx.ErrorResponse{Error: "proof of work unavailable"})
+			return
+		}
+		p, perr := g.NewPuzzle()
+		if perr != nil {
+			httpx.WriteJSON(w, http.StatusServiceUnavailable, httpx.ErrorResponse{Error: "proof of work unavailable"})
+			return
+		}
+		w.Header().Set("Cache-Control", "no-store")
+		w.Header().Set("Retry-After", "0")
+		w.Header().Set("X-PoW-Difficulty", strconv.Itoa(p.Difficulty))
+		httpx.WriteJSON(w, http.StatusTooManyRequests, requiredResponse{Error: err.Error(), PoW: p})
+	})
+}
+
+// isRejection reports whether err is the client's fault rather than a Redis failure
+func isRejection(err error) bool {
+	for _, e := range []error{ErrRequired, ErrInvalid, ErrExpired, ErrReplayed, ErrInsufficient} {
+		if errors.Is(err, e) {
+			return true
+		}
+	}
+	return false
+}
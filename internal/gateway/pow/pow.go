@@ -0,0 +1,316 @@
+package pow
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"math"
+	"math/bits"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	ErrRequired     = errors.New("proof of work required")
+	ErrInvalid      = errors.New("invalid proof of work")
+	ErrExpired      = errors.New("proof of work puzzle expired")
+	ErrReplayed     = errors.New("proof of work already used")
+	ErrInsufficient = errors.New("proof of work below required difficulty")
+)
+
+// Modes control when a proof of work is required
+const (
+	ModeOff    = "off"
+	ModeAuto   = "auto"   // Only under attack conditions (default)
+	ModeAlways = "always" // Always, at MinDifficulty or more
+)
+
+// Config configures the proof-of-work gate
+type Config struct {
+	Secret        []byte // HMAC key for stateless puzzles; random per process when empty
+	Mode          string
+	MinDifficulty int            // Leading zero bits once engaged (default 14)
+	MaxDifficulty int            // Ceiling under full load (default 22)
+	RateThreshold float64        // Gated requests per second before the gate engages (default 200)
+	Load          func() float64 // Gateway load in [0,1], e.g. the admission drop rate (optional)
+	PuzzleTTL     time.Duration  // How long a puzzle can be solved (default 1m)
+	Interval      time.Duration  // Difficulty re-evaluation interval (default 1s)
+}
+
+// Stats is the gate's current state
+type Stats struct {
+	Difficulty int     `json:"difficulty"`
+	Rate       float64 `json:"rate"`
+	Issued     int64   `json:"issued"`
+	Accepted   int64   `json:"accepted"`
+	Rejected   int64   `json:"rejected"`
+}
+
+// Puzzle is handed to a client that must do work before retrying. The client
+// finds a nonce such that SHA-256(challenge + ":" + nonce) starts with
+// Difficulty zero bits and retries with "X-PoW: <challenge>:<nonce>".
+type Puzzle struct {
+	Challenge  string `json:"challenge"`
+	Difficulty int    `json:"difficulty"`
+	Algorithm  string `json:"algorithm"`
+	ExpiresAt  int64  `json:"expires_at"`
+}
+
+// Gate makes anonymous callers pay CPU for requests that are cheap to send
+// but not free to serve. Puzzles are stateless (HMAC-signed) so issuing them
+// costs the gateway nothing; solutions are single-use across replicas via
+// Redis. Difficulty follows load: zero while the gateway is healthy (no work
+// required), ramping from MinDifficulty to MaxDifficulty as the request rate
+// passes RateThreshold or Load rises.
+type Gate struct {
+	client *redis.Client
+	cfg    Config
+
+	difficulty atomic.Int64
+	count      atomic.Int64 // Requests in the current interval
+	rate       atomic.Uint64
+	issued     atomic.Int64
+	accepted   atomic.Int64
+	rejected   atomic.Int64
+
+	mu       sync.Mutex
+	lastEval time.Time
+}
+
+// NewGate creates a gate; call Run to start difficulty tuning
+func NewGate(client *redis.Client, cfg Config) (*Gate, error) {
+	if cfg.Mode == "" {
+		cfg.Mode = ModeAuto
+	}
+	if cfg.Mode != ModeOff && cfg.Mode != ModeAuto && cfg.Mode != ModeAlways {
+		return nil, errors.New("pow mode must be off, auto or always")
+	}
+	if len(cfg.Secret) == 0 {
+		cfg.Secret = make([]byte, 32)
+		if _, err := rand.Read(cfg.Secret); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.MinDifficulty == 0 {
+		cfg.MinDifficulty = 14
+	}
+	if cfg.MaxDifficulty == 0 {
+		cfg.MaxDifficulty = 22
+	}
+	if cfg.MaxDifficulty < cfg.MinDifficulty || cfg.MaxDifficulty > 32 {
+		return nil, errors.New("pow difficulty must satisfy min <= max <= 32")
+	}
+	if cfg.RateThreshold == 0 {
+		cfg.RateThreshold = 200
+	}
+	if cfg.PuzzleTTL == 0 {
+		cfg.PuzzleTTL = time.Minute
+	}
+	if cfg.Interval == 0 {
+		cfg.Interval = time.Second
+	}
+	g := &Gate{client: client, cfg: cfg, lastEval: time.Now()}
+	if cfg.Mode == ModeAlways {
+		g.difficulty.Store(int64(cfg.MinDifficulty))
+	}
+	return g, nil
+}
+
+// Difficulty returns the current required difficulty (0 = no work required)
+func (g *Gate) Difficulty() int {
+	return int(g.difficulty.Load())
+}
+
+// Stats returns the gate's counters and current difficulty
+func (g *Gate) Stats() Stats {
+	return Stats{
+		Difficulty: g.Difficulty(),
+		Rate:       math.Float64frombits(g.rate.Load()),
+		Issued:     g.issued.Load(),
+		Accepted:   g.accepted.Load(),
+		Rejected:   g.rejected.Load(),
+	}
+}
+
+// Run re-evaluates the difficulty every interval until ctx is cancelled
+func (g *Gate) Run(ctx context.Context) {
+	ticker := time.NewTicker(g.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			g.evaluate()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// evaluate derives the difficulty from the last interval's request rate and
+// the external load signal. Pressure 0 means healthy; 1 means the rate is at
+// twice the threshold or the gateway is shedding everything it can.
+func (g *Gate) evaluate() {
+	g.mu.Lock()
+	now := time.Now()
+	elapsed := now.Sub(g.lastEval).Seconds()
+	g.lastEval = now
+	g.mu.Unlock()
+
+	rate := 0.0
+	if elapsed > 0 {
+		rate = float64(g.count.Swap(0)) / elapsed
+	}
+	g.rate.Store(math.Float64bits(rate))
+
+	pressure := math.Max(0, math.Min(1, rate/g.cfg.RateThreshold-1))
+	if g.cfg.Load != nil {
+		pressure = math.Max(pressure, math.Min(1, g.cfg.Load()))
+	}
+
+	var d int
+	switch {
+	case g.cfg.Mode == ModeOff:
+		d = 0
+	case pressure > 0:
+		d = g.cfg.MinDifficulty + int(math.Round(pressure*float64(g.cfg.MaxDifficulty-g.cfg.MinDifficulty)))
+	case g.cfg.Mode == ModeAlways:
+		d = g.cfg.MinDifficulty
+	}
+	g.difficulty.Store(int64(d))
+}
+
+// NewPuzzle issues a puzzle at the current difficulty
+func (g *Gate) NewPuzzle() (Puzzle, error) {
+	d := g.Difficulty()
+	if d == 0 {
+		d = g.cfg.MinDifficulty
+	}
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return Puzzle{}, err
+	}
+	exp := time.Now().Add(g.cfg.PuzzleTTL).Unix()
+	payload := strconv.FormatInt(exp, 10) + "." + strconv.Itoa(d) + "." + base64.RawURLEncoding.EncodeToString(salt)
+	g.issued.Add(1)
+	return Puzzle{
+		Challenge:  payload + "." + g.mac(payload),
+		Difficulty: d,
+		Algorithm:  "sha256",
+		ExpiresAt:  exp,
+	}, nil
+}
+
+// Check counts a gated request and, when work is currently required,
+// verifies the presented solution ("<challenge>:<nonce>"). It returns
+// ErrRequired when no solution was presented.
+func (g *Gate) Check(ctx context.Context, solution string) error {
+	g.count.Add(1)
+	required := g.Difficulty()
+	if required == 0 {
+		return nil
+	}
+	if solution == "" {
+		return ErrRequired
+	}
+	err := g.verify(ctx, solution, required)
+	if err != nil {
+		g.rejected.Add(1)
+		return err
+	}
+	g.accepted.Add(1)
+	return nil
+}
+
+func (g *Gate) verify(ctx context.Context, solution string, required int) error {
+	challenge, nonce, ok := strings.Cut(solution, ":")
+	if !ok || nonce == "" || len(nonce) > 64 {
+		return ErrInvalid
+	}
+	payload, sig, ok := cutLast(challenge, ".")
+	if !ok || !hmac.Equal([]byte(sig), []byte(g.mac(payload))) {
+		return ErrInvalid
+	}
+	fields := strings.SplitN(payload, ".", 3)
+	if len(fields) != 3 {
+		return ErrInvalid
+	}
+	exp, err1 := strconv.ParseInt(fields[0], 10, 64)
+	difficulty, err2 := strconv.Atoi(fields[1])
+	if err1 != nil || err2 != nil {
+		return ErrInvalid
+	}
+	ttl := time.Until(time.Unix(exp, 0))
+	if ttl <= 0 {
+		return ErrExpired
+	}
+	// A puzzle issued before the difficulty went up is still honoured, but
+	// only within two bits, so stockpiled easy solutions run out quickly
+	if difficulty < required-2 {
+		return ErrInsufficient
+	}
+	if LeadingZeroBits(challenge, nonce) < difficulty {
+		return ErrInvalid
+	}
+
+	sum := sha256.Sum256([]byte(challenge))
+	fresh, err := g.client.SetNX(ctx, "pow|"+base64.RawURLEncoding.EncodeToString(sum[:]), 1, ttl).Result()
+	if err != nil {
+		return err
+	}
+	if !fresh {
+		return ErrReplayed
+	}
+	return nil
+}
+
+func (g *Gate) mac(payload string) string {
+	h := hmac.New(sha256.New, g.cfg.Secret)
+	h.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(h.Sum(nil)[:16])
+}
+
+// LeadingZeroBits returns the number of leading zero bits of
+// SHA-256(challenge + ":" + nonce)
+func LeadingZeroBits(challenge, nonce string) int {
+	sum := sha256.Sum256([]byte(challenge + ":" + nonce))
+	n := 0
+	for i := 0; i < len(sum); i += 8 {
+		word := binary.BigEndian.Uint64(sum[i:])
+		if word != 0 {
+			return n + bits.LeadingZeros64(word)
+		}
+		n += 64
+	}
+	return n
+}
+
+// Solve finds a nonce for a puzzle. It is meant for client SDKs and tools;
+// expected work is 2^difficulty hashes.
+func Solve(ctx context.Context, p Puzzle) (string, error) {
+	for i := uint64(0); ; i++ {
+		if i&0xffff == 0 && ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+		nonce := strconv.FormatUint(i, 36)
+		if LeadingZeroBits(p.Challenge, nonce) >= p.Difficulty {
+			return p.Challenge + ":" + nonce, nil
+		}
+	}
+}
+
+func cutLast(s, sep string) (string, string, bool) {
+	i := strings.LastIndex(s, sep)
+	if i < 0 {
+		return "", "", false
+	}
+	return s[:i], s[i+len(sep):], true
+}
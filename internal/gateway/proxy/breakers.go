@@ -0,0 +1,43 @@
+package proxy
+
+import (
+	"sync"
+
+	"github.com/example/privacy-gateway/internal/shared/circuitbreaker"
+)
+
+// UpstreamBreakers holds a circuit breaker per upstream and resilience
+// profile, so routes sharing an upstream share its failure count
+type UpstreamBreakers struct {
+	mu       sync.Mutex
+	breakers map[string]*circuitbreaker.CircuitBreaker
+}
+
+// NewUpstreamBreakers creates an empty set of upstream breakers
+func NewUpstreamBreakers() *UpstreamBreakers {
+	return &UpstreamBreakers{breakers: make(map[string]*circuitbreaker.CircuitBreaker)}
+}
+
+// For returns the breaker for upstream under the route's profile
+func (b *UpstreamBreakers) For(upstream string, l Limits) *circuitbreaker.CircuitBreaker {
+	key := l.Resilience.Name + "|" + upstream
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	cb, ok := b.breakers[key]
+	if !ok {
+		cb = l.Resilience.NewBreaker()
+		b.breakers[key] = cb
+	}
+	return cb
+}
+
+// Stats returns each breaker's state, keyed by "<profile>|<upstream>"
+func (b *UpstreamBreakers) Stats() map[string]circuitbreaker.Stats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	out := make(map[string]circuitbreaker.Stats, len(b.breakers))
+	for k, cb := range b.breakers {
+		out[k] = cb.Stats()
+	}
+	return out
+}
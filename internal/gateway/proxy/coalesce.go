@@ -0,0 +1,207 @@
+package proxy
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/example/privacy-gateway/internal/gateway/apikey"
+)
+
+// DefaultCoalesceMaxBody caps how much of a response is buffered for followers
+const DefaultCoalesceMaxBody = 1 << 20
+
+// Coalescer collapses identical in-flight GET requests into a single upstream
+// call. The first request (the leader) is proxied normally while its response
+// is buffered; requests with the same key that arrive before it completes wait
+// and receive a copy. Only requests presenting the same credentials are
+// merged. Responses larger than MaxBody, failed or interrupted ones, and
+// ones marked per-user (Set-Cookie, Cache-Control private or no-store) are
+// not shared, and waiting requests fall back to their own upstream call.
+// Followers only get the headers set while the leader was proxied; headers
+// the gateway set for the leader before that (request IDs, rate limit
+// counters) stay the follower's own.
+type Coalescer struct {
+	// VaryHeaders are request headers included in the coalescing key besides
+	// the credential headers, which are always included. Any other header the
+	// upstream uses to vary responses (e.g. Accept-Language) must be listed.
+	VaryHeaders []string
+	MaxBody     int
+	OnCoalesced func() // Metrics callback
+
+	mu       sync.Mutex
+	inflight map[string]*call
+}
+
+// call is an in-flight leader request
+type call struct {
+	done   chan struct{}
+	ok     bool
+	status int
+	header http.Header
+	body   []byte
+}
+
+// NewCoalescer creates a coalescer keyed on URL plus the given headers
+func NewCoalescer(varyHeaders []string, onCoalesced func()) *Coalescer {
+	return &Coalescer{
+		VaryHeaders: varyHeaders,
+		MaxBody:     DefaultCoalesceMaxBody,
+		OnCoalesced: onCoalesced,
+		inflight:    make(map[string]*call),
+	}
+}
+
+// Middleware wraps the proxy handler
+func (c *Coalescer) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet || r.Header.Get("Range") != "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		key := c.key(r)
+		c.mu.Lock()
+		if cl, ok := c.inflight[key]; ok {
+			c.mu.Unlock()
+			select {
+			case <-cl.done:
+			case <-r.Context().Done():
+				return
+			}
+			if !cl.ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if c.OnCoalesced != nil {
+				c.OnCoalesced()
+			}
+			replay(w, cl)
+			return
+		}
+		cl := &call{done: make(chan struct{})}
+		c.inflight[key] = cl
+		c.mu.Unlock()
+
+		rec := &recorder{ResponseWriter: w, max: c.MaxBody, status: http.StatusOK}
+		before := w.Header().Clone()
+		completed := false
+		defer func() {
+			c.mu.Lock()
+			delete(c.inflight, key)
+			c.mu.Unlock()
+
+			// A panic, a client that went away or a failed write leaves the
+			// buffered body truncated
+			cl.ok = completed && r.Context().Err() == nil && !rec.failed &&
+				!rec.overflow && rec.status < 500 && shareable(w.Header())
+			cl.status = rec.status
+			cl.header = addedHeaders(before, w.Header())
+			cl.body = rec.buf.Bytes()
+			close(cl.done)
+		}()
+
+		next.ServeHTTP(rec, r)
+		completed = true
+	})
+}
+
+// credentialHeaders identify the caller; they are always part of the key
+var credentialHeaders = []string{"Authorization", "Cookie", apikey.Header}
+
+// key builds the coalescing key for a request. Credentials are hashed so
+// the in-flight map doesn't hold tokens.
+func (c *Coalescer) key(r *http.Request) string {
+	var b strings.Builder
+	b.WriteString(r.URL.RequestURI())
+	creds := sha256.New()
+	for _, h := range credentialHeaders {
+		creds.Write([]byte(h + "=" + strings.Join(r.Header.Values(h), ",") + "\n"))
+	}
+	b.WriteString("\ncredentials=")
+	b.Write(creds.Sum(nil))
+	for _, h := range c.VaryHeaders {
+		b.WriteByte('\n')
+		b.WriteString(h)
+		b.WriteByte('=')
+		b.WriteString(strings.Join(r.Header.Values(h), ","))
+	}
+	return b.String()
+}
+
+// shareable reports whether a response may be replayed to other callers
+func shareable(h http.Header) bool {
+	if len(h.Values("Set-Cookie")) > 0 {
+		return false
+	}
+	for _, v := range h.Values("Cache-Control") {
+		for _, d := range strings.Split(v, ",") {
+			d = strings.ToLower(strings.TrimSpace(d))
+			if d == "no-store" || d == "private" || strings.HasPrefix(d, "private=") {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// addedHeaders returns the headers in after that were added or changed
+// since before, i.e. those that came with the proxied response
+func addedHeaders(before, after http.Header) http.Header {
+	out := make(http.Header, len(after))
+	for k, v := range after {
+		if old, ok := before[k]; ok && slices.Equal(old, v) {
+			continue
+		}
+		out[k] = append([]string(nil), v...)
+	}
+	return out
+}
+
+// replay writes a shared response to a follower, on top of the headers
+// already set for the follower
+func replay(w http.ResponseWriter, cl *call) {
+	for k, v := range cl.header {
+		w.Header()[k] = append([]string(nil), v...)
+	}
+	w.WriteHeader(cl.status)
+	_, _ = w.Write(cl.body)
+}
+
+// recorder passes writes through while buffering up to max bytes
+type recorder struct {
+	http.ResponseWriter
+	buf      bytes.Buffer
+	max      int
+	status   int
+	overflow bool
+	failed   bool // A write to the client failed
+}
+
+func (r *recorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *recorder) Write(p []byte) (int, error) {
+	if !r.overflow {
+		if r.buf.Len()+len(p) > r.max {
+			r.overflow = true
+			r.buf.Reset()
+		} else {
+			r.buf.Write(p)
+		}
+	}
+	n, err := r.ResponseWriter.Write(p)
+	if err != nil {
+		r.failed = true
+	}
+	return n, err
+}
+
+func (r *recorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
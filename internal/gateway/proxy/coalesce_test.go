@@ -0,0 +1,65 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestCoalescerKeepsFollowerHeaders checks that followers get the upstream
+// response headers but not the ones the gateway set for the leader
+func TestCoalescerKeepsFollowerHeaders(t *testing.T) {
+	release := make(chan struct{})
+	var calls atomic.Int32
+	upstream := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		<-release
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"ok":true}`))
+	})
+	c := NewCoalescer(nil, nil)
+	var ids atomic.Int32
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Stands in for gateway middleware that sets per-request headers
+		w.Header().Set("X-Request-Id", strconv.Itoa(int(ids.Add(1))))
+		c.Middleware(upstream).ServeHTTP(w, r)
+	})
+
+	recs := make([]*httptest.ResponseRecorder, 3)
+	var wg sync.WaitGroup
+	for i := range recs {
+		recs[i] = httptest.NewRecorder()
+		wg.Add(1)
+		go func(rec *httptest.ResponseRecorder) {
+			defer wg.Done()
+			h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/basic", nil))
+		}(recs[i])
+	}
+	// Let the followers queue behind the leader
+	for calls.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if n := calls.Load(); n != 1 {
+		t.Fatalf("upstream called %d times, want 1", n)
+	}
+	seen := map[string]bool{}
+	for _, rec := range recs {
+		if got := rec.Header().Get("Content-Type"); got != "application/json" {
+			t.Errorf("Content-Type = %q, want the upstream's", got)
+		}
+		id := rec.Header().Values("X-Request-Id")
+		if len(id) != 1 || seen[id[0]] {
+			t.Errorf("X-Request-Id = %v, want the caller's own", id)
+			continue
+		}
+		seen[id[0]] = true
+	}
+}
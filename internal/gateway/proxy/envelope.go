@@ -0,0 +1,392 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/example/privacy-gateway/internal/gateway/did"
+	"github.com/example/privacy-gateway/internal/shared/crypto"
+	"github.com/example/privacy-gateway/internal/shared/jws"
+	"github.com/example/privacy-gateway/internal/shared/models"
+)
+
+// ContentTypeJOSE is the content type of compact JWE bodies
+const ContentTypeJOSE = "application/jose"
+
+// EnvelopeRecipientHeader optionally names the caller's key agreement
+// method that envelope responses are encrypted to
+const EnvelopeRecipientHeader = "X-Gateway-Encrypt-To"
+
+// DefaultMaxEnvelopeResponse caps the body buffered for encryption on
+// routes without a response body limit
+const DefaultMaxEnvelopeResponse = 10 << 20
+
+// JWE content encryption algorithms
+const (
+	EncA128GCM = "A128GCM"
+	EncA256GCM = "A256GCM"
+)
+
+var (
+	ErrEnvelopeRequired    = errors.New("request body must be a JWE")
+	ErrInvalidEnvelope     = errors.New("invalid JWE")
+	ErrNoRecipientKey      = errors.New("caller has no X25519 key agreement key")
+	ErrEnvelopeTooLarge    = errors.New("upstream response too large to encrypt")
+	errEnvelopeNotOpened   = errors.New("envelope: request was not opened")
+	errEnvelopeContentCode = errors.New("envelope: upstream response is content-encoded")
+)
+
+// ValidEnvelopeEnc reports whether enc is a supported content encryption
+// ("" is the default)
+func ValidEnvelopeEnc(enc string) bool {
+	return enc == "" || encKeySize(enc) > 0
+}
+
+func encKeySize(enc string) int {
+	switch enc {
+	case EncA128GCM:
+		return 16
+	case EncA256GCM:
+		return 32
+	}
+	return 0
+}
+
+// jweHeader is the protected header of an ECDH-ES JWE
+type jweHeader struct {
+	Alg string     `json:"alg"`
+	Enc string     `json:"enc"`
+	Kid string     `json:"kid,omitempty"`
+	Cty string     `json:"cty,omitempty"`
+	Epk *x25519JWK `json:"epk"`
+	Apu string     `json:"apu,omitempty"`
+	Apv string     `json:"apv,omitempty"`
+}
+
+type x25519JWK struct {
+	Kty string `json:"kty"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+}
+
+// EncryptJWE encrypts plaintext to pub as a compact JWE using ECDH-ES key
+// agreement with an ephemeral X25519 key and enc (default A256GCM). kid
+// names the recipient key and cty the plaintext's content type.
+func EncryptJWE(pub *ecdh.PublicKey, kid, enc, cty string, plaintext []byte) (string, error) {
+	if enc == "" {
+		enc = EncA256GCM
+	}
+	if encKeySize(enc) == 0 {
+		return "", fmt.Errorf("%w: unsupported enc %q", ErrInvalidEnvelope, enc)
+	}
+	eph, err := crypto.GenerateX25519Key()
+	if err != nil {
+		return "", err
+	}
+	z, err := eph.ECDH(pub)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidEnvelope, err)
+	}
+	h := jweHeader{
+		Alg: "ECDH-ES",
+		Enc: enc,
+		Kid: kid,
+		Cty: cty,
+		Epk: &x25519JWK{Kty: "OKP", Crv: "X25519", X: crypto.EncodeX25519PublicKey(eph.PublicKey())},
+	}
+	hb, err := json.Marshal(h)
+	if err != nil {
+		return "", err
+	}
+	protected := base64.RawURLEncoding.EncodeToString(hb)
+	gcm, err := contentCipher(concatKDF(z, enc, nil, nil, encKeySize(enc)))
+	if err != nil {
+		return "", err
+	}
+	iv := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(iv); err != nil {
+		return "", err
+	}
+	sealed := gcm.Seal(nil, iv, plaintext, []byte(protected))
+	ct, tag := sealed[:len(sealed)-gcm.Overhead()], sealed[len(sealed)-gcm.Overhead():]
+	enc64 := base64.RawURLEncoding.EncodeToString
+	return protected + ".." + enc64(iv) + "." + enc64(ct) + "." + enc64(tag), nil
+}
+
+// DecryptJWE decrypts a compact ECDH-ES JWE with the X25519 key that key
+// returns for the header's kid (nil if there is none), and returns the
+// plaintext and its cty
+func DecryptJWE(token string, key func(kid string) *ecdh.PrivateKey) ([]byte, string, error) {
+	parts := strings.Split(strings.TrimSpace(token), ".")
+	if len(parts) != 5 || parts[1] != "" {
+		return nil, "", fmt.Errorf("%w: not a compact ECDH-ES JWE", ErrInvalidEnvelope)
+	}
+	var h jweHeader
+	if err := jws.DecodeSegment(parts[0], &h); err != nil {
+		return nil, "", fmt.Errorf("%w: header: %v", ErrInvalidEnvelope, err)
+	}
+	if h.Alg != "ECDH-ES" || encKeySize(h.Enc) == 0 {
+		return nil, "", fmt.Errorf("%w: unsupported alg %q or enc %q", ErrInvalidEnvelope, h.Alg, h.Enc)
+	}
+	if h.Epk == nil || h.Epk.Kty != "OKP" || h.Epk.Crv != "X25519" {
+		return nil, "", fmt.Errorf("%w: epk must be an X25519 key", ErrInvalidEnvelope)
+	}
+	priv := key(h.Kid)
+	if priv == nil {
+		return nil, "", fmt.Errorf("%w: unknown kid %q", ErrInvalidEnvelope, h.Kid)
+	}
+	epk, err := crypto.DecodeX25519PublicKey(h.Epk.X)
+	if err != nil {
+		return nil, "", fmt.Errorf("%w: epk: %v", ErrInvalidEnvelope, err)
+	}
+	z, err := priv.ECDH(epk)
+	if err != nil {
+		return nil, "", fmt.Errorf("%w: %v", ErrInvalidEnvelope, err)
+	}
+	var segs [3][]byte
+	for i, p := range parts[2:] {
+		if segs[i], err = base64.RawURLEncoding.DecodeString(p); err != nil {
+			return nil, "", fmt.Errorf("%w: %v", ErrInvalidEnvelope, err)
+		}
+	}
+	apu, err1 := base64.RawURLEncoding.DecodeString(h.Apu)
+	apv, err2 := base64.RawURLEncoding.DecodeString(h.Apv)
+	if err1 != nil || err2 != nil {
+		return nil, "", fmt.Errorf("%w: apu or apv", ErrInvalidEnvelope)
+	}
+	gcm, err := contentCipher(concatKDF(z, h.Enc, apu, apv, encKeySize(h.Enc)))
+	if err != nil {
+		return nil, "", err
+	}
+	iv, ct, tag := segs[0], segs[1], segs[2]
+	if len(iv) != gcm.NonceSize() || len(tag) != gcm.Overhead() {
+		return nil, "", fmt.Errorf("%w: bad iv or tag length", ErrInvalidEnvelope)
+	}
+	plaintext, err := gcm.Open(nil, iv, append(ct, tag...), []byte(parts[0]))
+	if err != nil {
+		return nil, "", fmt.Errorf("%w: decryption failed", ErrInvalidEnvelope)
+	}
+	return plaintext, h.Cty, nil
+}
+
+// concatKDF derives the content key from the shared secret z per RFC 7518
+// section 4.6.2. Keys up to 256 bits take a single SHA-256 round.
+func concatKDF(z []byte, enc string, apu, apv []byte, size int) []byte {
+	h := sha256.New()
+	lengthPrefixed := func(b []byte) {
+		binary.Write(h, binary.BigEndian, uint32(len(b)))
+		h.Write(b)
+	}
+	binary.Write(h, binary.BigEndian, uint32(1))
+	h.Write(z)
+	lengthPrefixed([]byte(enc))
+	lengthPrefixed(apu)
+	lengthPrefixed(apv)
+	binary.Write(h, binary.BigEndian, uint32(size*8))
+	return h.Sum(nil)[:size]
+}
+
+func contentCipher(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// EnvelopeConfig configures envelope encryption
+type EnvelopeConfig struct {
+	// Gateway X25519 key agreement keys by absolute verification method ID,
+	// as published with did.Publisher.UpdateAgreement
+	Keys     map[string]*ecdh.PrivateKey
+	Resolver did.Resolver // Resolves caller DIDs other than did:key for their key agreement key
+}
+
+// Envelope implements JWE envelope mode for routes with envelope set: the
+// client encrypts request bodies to the gateway's key agreement key, the
+// gateway forwards the plaintext, and it encrypts the upstream response to
+// the caller's key agreement key. Intermediaries in front of the gateway,
+// such as TLS-terminating load balancers, only see ciphertext.
+type Envelope struct {
+	cfg EnvelopeConfig
+}
+
+// NewEnvelope creates an envelope handler
+func NewEnvelope(cfg EnvelopeConfig) (*Envelope, error) {
+	if len(cfg.Keys) == 0 {
+		return nil, errors.New("envelope encryption requires a gateway key agreement key")
+	}
+	for id, k := range cfg.Keys {
+		if k == nil || k.Curve() != ecdh.X25519() {
+			return nil, fmt.Errorf("envelope key %s: not an X25519 key", id)
+		}
+	}
+	return &Envelope{cfg: cfg}, nil
+}
+
+// key returns the gateway key for a JWE kid: an absolute method ID, a
+// "#fragment", or empty when the gateway has a single key
+func (e *Envelope) key(kid string) *ecdh.PrivateKey {
+	if k, ok := e.cfg.Keys[kid]; ok {
+		return k
+	}
+	for id, k := range e.cfg.Keys {
+		if (kid == "" && len(e.cfg.Keys) == 1) || (strings.HasPrefix(kid, "#") && strings.HasSuffix(id, kid)) {
+			return k
+		}
+	}
+	return nil
+}
+
+// envelopeRecipient is the caller key a response is encrypted to
+type envelopeRecipient struct {
+	pub *ecdh.PublicKey
+	kid string
+}
+
+type recipientKey struct{}
+
+// Recipient returns the caller's X25519 key agreement key and its method
+// ID: the one named by kid, or the first one listed. A did:key has no
+// keyAgreement entry, so its key is derived from the Ed25519 key as the
+// did:key spec's encryption key derivation does.
+func (e *Envelope) Recipient(ctx context.Context, subject, kid string) (*ecdh.PublicKey, string, error) {
+	if strings.HasPrefix(subject, "did:key:") {
+		edPub, err := crypto.DecodeDidKey(subject)
+		if err != nil {
+			return nil, "", fmt.Errorf("%w: %v", ErrNoRecipientKey, err)
+		}
+		pub, err := crypto.X25519FromEd25519(edPub)
+		if err != nil {
+			return nil, "", fmt.Errorf("%w: %v", ErrNoRecipientKey, err)
+		}
+		id := subject + "#" + crypto.EncodeX25519Multibase(pub)
+		if kid != "" && kid != id && subject+kid != id {
+			return nil, "", fmt.Errorf("%w: %s is not a key agreement method", ErrNoRecipientKey, kid)
+		}
+		return pub, id, nil
+	}
+	if e.cfg.Resolver == nil {
+		return nil, "", fmt.Errorf("%w: no resolver for %s", ErrNoRecipientKey, subject)
+	}
+	doc, err := e.cfg.Resolver.Resolve(ctx, subject, did.ResolveOptions{})
+	if err != nil {
+		return nil, "", fmt.Errorf("%w: resolve %s: %v", ErrNoRecipientKey, subject, err)
+	}
+	pub, id, err := doc.KeyAgreementKey(kid)
+	if err != nil {
+		return nil, "", fmt.Errorf("%w: %v", ErrNoRecipientKey, err)
+	}
+	return pub, id, nil
+}
+
+// OpenRequest prepares a request on a route with envelope set. It resolves
+// the caller's key agreement key up front, so a caller whose response
+// can't be encrypted never reaches the upstream, then decrypts a JWE body
+// (Content-Type application/jose) and restores the plaintext's content type
+// from cty (default application/json). A non-empty body that isn't a JWE
+// fails with ErrEnvelopeRequired. Call it after Limits.Apply has capped the
+// body and before receipts, body conditions and transforms, which all see
+// the plaintext. The returned request carries the recipient for
+// SealResponse; on other routes it is r unchanged.
+func (e *Envelope) OpenRequest(r *http.Request, pol *models.Policy, claims *models.AccessTokenClaims) (*http.Request, error) {
+	if pol == nil || pol.Envelope == nil {
+		return r, nil
+	}
+	if claims == nil || claims.Subject == "" {
+		return nil, ErrNoRecipientKey
+	}
+	pub, kid, err := e.Recipient(r.Context(), claims.Subject, r.Header.Get(EnvelopeRecipientHeader))
+	if err != nil {
+		return nil, err
+	}
+
+	var body []byte
+	if r.Body != nil && r.Body != http.NoBody {
+		body, err = io.ReadAll(r.Body)
+		r.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("read body for envelope: %w", err)
+		}
+	}
+	if len(body) > 0 {
+		if mt, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mt != ContentTypeJOSE {
+			return nil, ErrEnvelopeRequired
+		}
+		plaintext, cty, err := DecryptJWE(string(body), e.key)
+		if err != nil {
+			return nil, err
+		}
+		if cty == "" {
+			cty = "application/json"
+		}
+		body = plaintext
+		r.Header.Set("Content-Type", cty)
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	r.ContentLength = int64(len(body))
+	r.Header.Del(EnvelopeRecipientHeader)
+	// The response is encrypted as a whole; let the transport negotiate
+	// (and undo) compression instead of the client
+	r.Header.Del("Accept-Encoding")
+	return r.WithContext(context.WithValue(r.Context(), recipientKey{}, envelopeRecipient{pub: pub, kid: kid})), nil
+}
+
+// SealResponse encrypts the upstream response to the caller on routes with
+// envelope set; use from ReverseProxy.ModifyResponse after any response
+// transform and Limits.LimitResponse, and before ResponseSigner, which
+// then signs the ciphertext. The body is buffered to encrypt it, up to
+// maxBody (DefaultMaxEnvelopeResponse when 0). The JWE's cty carries the
+// upstream content type; empty bodies are left as they are.
+func (e *Envelope) SealResponse(resp *http.Response, pol *models.Policy, maxBody int64) error {
+	if pol == nil || pol.Envelope == nil {
+		return nil
+	}
+	rc, ok := resp.Request.Context().Value(recipientKey{}).(envelopeRecipient)
+	if !ok {
+		return errEnvelopeNotOpened
+	}
+	if ce := resp.Header.Get("Content-Encoding"); ce != "" && ce != "identity" {
+		return errEnvelopeContentCode
+	}
+	if maxBody <= 0 {
+		maxBody = DefaultMaxEnvelopeResponse
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxBody+1))
+	resp.Body.Close()
+	if err != nil {
+		return fmt.Errorf("read body for envelope: %w", err)
+	}
+	if int64(len(body)) > maxBody {
+		return ErrEnvelopeTooLarge
+	}
+	if len(body) == 0 {
+		resp.Body = io.NopCloser(bytes.NewReader(nil))
+		return nil
+	}
+	jwe, err := EncryptJWE(rc.pub, rc.kid, pol.Envelope.Enc, resp.Header.Get("Content-Type"), body)
+	if err != nil {
+		return err
+	}
+	resp.Body = io.NopCloser(strings.NewReader(jwe))
+	resp.ContentLength = int64(len(jwe))
+	resp.Header.Set("Content-Length", strconv.Itoa(len(jwe)))
+	resp.Header.Set("Content-Type", ContentTypeJOSE)
+	resp.Header.Del("Content-Encoding")
+	return nil
+}
@@ -0,0 +1,161 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/example/privacy-gateway/internal/shared/httpx"
+	"github.com/example/privacy-gateway/internal/shared/models"
+	"github.com/example/privacy-gateway/internal/shared/resilience"
+)
+
+var ErrResponseTooLarge = errors.New("upstream response exceeds route limit")
+
+// DefaultUpstreamTimeout applies when a route sets no upstream timeout
+const DefaultUpstreamTimeout = 30 * time.Second
+
+// responseGrace is how long past the upstream timeout the gateway may still
+// spend writing the response, e.g. the 504 for a timed-out upstream
+const responseGrace = 5 * time.Second
+
+// Limits is the effective set of limits for a route
+type Limits struct {
+	MaxRequestBody  int64
+	MaxResponseBody int64 // 0 means unlimited
+	UpstreamTimeout time.Duration
+	IdleTimeout     time.Duration // 0 means no idle timeout
+	Resilience      resilience.Profile
+}
+
+// LimitsFor resolves route limits for a policy, falling back to gateway
+// defaults. A resilience profile sets the upstream timeout unless the route
+// sets one explicitly.
+func LimitsFor(pol *models.Policy) Limits {
+	l := Limits{
+		MaxRequestBody:  httpx.DefaultMaxBodyBytes,
+		UpstreamTimeout: DefaultUpstreamTimeout,
+	}
+	l.Resilience, _ = resilience.Lookup(resilience.Balanced)
+	if pol == nil || pol.Limits == nil {
+		return l
+	}
+	if name := pol.Limits.Resilience; name != "" {
+		// Unknown names keep the default; bundle validation rejects them
+		if p, err := resilience.Lookup(name); err == nil {
+			l.Resilience, l.UpstreamTimeout = p, p.Timeout
+		}
+	}
+	if pol.Limits.MaxRequestBodyBytes > 0 {
+		l.MaxRequestBody = pol.Limits.MaxRequestBodyBytes
+	}
+	if pol.Limits.MaxResponseBodyBytes > 0 {
+		l.MaxResponseBody = pol.Limits.MaxResponseBodyBytes
+	}
+	if pol.Limits.UpstreamTimeoutSeconds > 0 {
+		l.UpstreamTimeout = time.Duration(pol.Limits.UpstreamTimeoutSeconds) * time.Second
+	}
+	if pol.Limits.IdleTimeoutSeconds > 0 {
+		l.IdleTimeout = time.Duration(pol.Limits.IdleTimeoutSeconds) * time.Second
+	}
+	return l
+}
+
+// Apply enforces the request-side limits: the body is capped at MaxRequestBody
+// (reads beyond it fail with *http.MaxBytesError), the upstream call is bounded by UpstreamTimeout, and
+// with an IdleTimeout the connection read deadline is pushed forward on every
+// body read so slow-but-progressing uploads survive while stalled ones don't.
+// The write deadline is set once from the upstream timeout, since a
+// progressing upload says nothing about how long the response may take.
+// A nil request means a 413 has already been written. The returned cancel
+// func must be called when the request completes.
+func (l Limits) Apply(w http.ResponseWriter, r *http.Request) (*http.Request, context.CancelFunc) {
+	if r.ContentLength > l.MaxRequestBody {
+		httpx.WriteJSON(w, http.StatusRequestEntityTooLarge, httpx.ErrorResponse{Error: "request body too large"})
+		return nil, func() {}
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), l.UpstreamTimeout)
+	r = r.WithContext(ctx)
+	r.Body = http.MaxBytesReader(w, r.Body, l.MaxRequestBody)
+
+	if l.IdleTimeout > 0 {
+		rc := http.NewResponseController(w)
+		deadline, _ := ctx.Deadline()
+		_ = rc.SetWriteDeadline(deadline.Add(responseGrace))
+		extend := func() {
+			_ = rc.SetReadDeadline(time.Now().Add(l.IdleTimeout))
+		}
+		extend()
+		r.Body = &idleBody{ReadCloser: r.Body, extend: extend}
+	}
+
+	return r, cancel
+}
+
+// LimitResponse caps the upstream response body; use from ReverseProxy.ModifyResponse
+func (l Limits) LimitResponse(resp *http.Response) error {
+	if l.MaxResponseBody <= 0 {
+		return nil
+	}
+	if resp.ContentLength > l.MaxResponseBody {
+		return ErrResponseTooLarge
+	}
+	resp.Body = &limitedBody{ReadCloser: resp.Body, remaining: l.MaxResponseBody}
+	return nil
+}
+
+// idleBody extends the connection read deadline whenever data arrives
+type idleBody struct {
+	io.ReadCloser
+	extend func()
+}
+
+func (b *idleBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if n > 0 {
+		b.extend()
+	}
+	return n, err
+}
+
+// limitedBody fails once more than remaining bytes have been read
+type limitedBody struct {
+	io.ReadCloser
+	remaining int64
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.remaining <= 0 {
+		return 0, b.probe()
+	}
+	if int64(len(p)) > b.remaining {
+		p = p[:b.remaining]
+	}
+	n, err := b.ReadCloser.Read(p)
+	b.remaining -= int64(n)
+	return n, err
+}
+
+// maxEmptyReads bounds the (0, nil) reads probe tolerates, as bufio does
+const maxEmptyReads = 100
+
+// probe reads one more byte to distinguish "exactly at limit" from "over".
+// Only a clean io.EOF means the body ended at the limit; any other error,
+// such as an upstream reset, is passed through so a truncated body is not
+// mistaken for a complete one.
+func (b *limitedBody) probe() error {
+	var one [1]byte
+	for i := 0; i < maxEmptyReads; i++ {
+		n, err := b.ReadCloser.Read(one[:])
+		if n > 0 {
+			return ErrResponseTooLarge
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return io.ErrNoProgress
+}
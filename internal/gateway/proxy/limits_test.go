@@ -0,0 +1,103 @@
+package proxy
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// scriptedBody returns one scripted result per Read
+type scriptedBody struct {
+	reads []func(p []byte) (int, error)
+}
+
+func (b *scriptedBody) Read(p []byte) (int, error) {
+	if len(b.reads) == 0 {
+		return 0, io.EOF
+	}
+	r := b.reads[0]
+	b.reads = b.reads[1:]
+	return r(p)
+}
+
+func (b *scriptedBody) Close() error { return nil }
+
+func data(s string) func([]byte) (int, error) {
+	return func(p []byte) (int, error) { return copy(p, s), nil }
+}
+
+func fail(err error) func([]byte) (int, error) {
+	return func([]byte) (int, error) { return 0, err }
+}
+
+func TestLimitedBodyAtLimit(t *testing.T) {
+	errReset := errors.New("connection reset")
+	cases := []struct {
+		name  string
+		reads []func([]byte) (int, error)
+		want  error
+	}{
+		{"exactly at limit", []func([]byte) (int, error){data("abcd"), fail(io.EOF)}, nil},
+		{"over limit", []func([]byte) (int, error){data("abcd"), data("e")}, ErrResponseTooLarge},
+		{"reset at limit", []func([]byte) (int, error){data("abcd"), fail(errReset)}, errReset},
+		{"empty read then more", []func([]byte) (int, error){data("abcd"), fail(nil), data("e")}, ErrResponseTooLarge},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			body := &limitedBody{ReadCloser: &scriptedBody{reads: c.reads}, remaining: 4}
+			got, err := io.ReadAll(body)
+			if !errors.Is(err, c.want) {
+				t.Fatalf("err = %v, want %v", err, c.want)
+			}
+			if string(got) != "abcd" {
+				t.Fatalf("body = %q", got)
+			}
+		})
+	}
+}
+
+// deadlineRecorder records the deadlines set through http.ResponseController
+type deadlineRecorder struct {
+	*httptest.ResponseRecorder
+	reads  []time.Time
+	writes []time.Time
+}
+
+func (d *deadlineRecorder) SetReadDeadline(t time.Time) error {
+	d.reads = append(d.reads, t)
+	return nil
+}
+
+func (d *deadlineRecorder) SetWriteDeadline(t time.Time) error {
+	d.writes = append(d.writes, t)
+	return nil
+}
+
+func TestApplyIdleTimeoutExtendsOnlyReads(t *testing.T) {
+	l := Limits{MaxRequestBody: 1 << 20, UpstreamTimeout: 10 * time.Second, IdleTimeout: time.Second}
+	w := &deadlineRecorder{ResponseRecorder: httptest.NewRecorder()}
+	req := httptest.NewRequest(http.MethodPost, "/upload", strings.NewReader(strings.Repeat("x", 4096)))
+	start := time.Now()
+	r, cancel := l.Apply(w, req)
+	defer cancel()
+
+	buf := make([]byte, 512)
+	for {
+		if _, err := r.Body.Read(buf); err != nil {
+			break
+		}
+	}
+	if len(w.reads) < 2 {
+		t.Fatalf("read deadline set %d times, want one per body read", len(w.reads))
+	}
+	if len(w.writes) != 1 {
+		t.Fatalf("write deadline set %d times, want once", len(w.writes))
+	}
+	if got := w.writes[0].Sub(start); got < l.UpstreamTimeout || got > l.UpstreamTimeout+responseGrace+time.Second {
+		t.Fatalf("write deadline %v after start, want the upstream timeout plus grace", got)
+	}
+}
@@ -0,0 +1,135 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/example/privacy-gateway/internal/shared/models"
+)
+
+// Mirror asynchronously copies a sample of proxied requests to a secondary
+// upstream. Mirrored responses are discarded; mirroring never delays or fails
+// the primary request. When MaxInflight mirrored requests are outstanding,
+// further samples are dropped. The caller's credentials are stripped from
+// mirrored requests unless the route opts in with ForwardCredentials.
+type Mirror struct {
+	client  *http.Client
+	sem     chan struct{}
+	maxBody int64
+	onDrop  func() // Metrics callback
+}
+
+// MirrorConfig configures the mirror dispatcher
+type MirrorConfig struct {
+	Timeout     time.Duration // Per mirrored request
+	MaxInflight int           // Concurrent mirrored requests
+	MaxBody     int64         // Requests with larger bodies are not mirrored
+}
+
+// NewMirror creates a mirror dispatcher
+func NewMirror(cfg MirrorConfig, onDrop func()) *Mirror {
+	if cfg.Timeout == 0 {
+		cfg.Timeout = 5 * time.Second
+	}
+	if cfg.MaxInflight == 0 {
+		cfg.MaxInflight = 100
+	}
+	if cfg.MaxBody == 0 {
+		cfg.MaxBody = 1 << 20
+	}
+	return &Mirror{
+		client:  &http.Client{Timeout: cfg.Timeout},
+		sem:     make(chan struct{}, cfg.MaxInflight),
+		maxBody: cfg.MaxBody,
+		onDrop:  onDrop,
+	}
+}
+
+// Sample mirrors r according to the route's mirror config. It must be called
+// before the primary request is proxied since it may buffer and replace r.Body.
+func (m *Mirror) Sample(r *http.Request, cfg *models.MirrorConfig) {
+	if cfg == nil || cfg.UpstreamURL == "" || cfg.Percent <= 0 {
+		return
+	}
+	if cfg.Percent < 100 && rand.Float64()*100 >= cfg.Percent {
+		return
+	}
+	if r.ContentLength > m.maxBody {
+		m.drop()
+		return
+	}
+
+	target, err := url.Parse(cfg.UpstreamURL)
+	if err != nil {
+		m.drop()
+		return
+	}
+
+	var body []byte
+	if r.Body != nil && r.Body != http.NoBody {
+		body, err = io.ReadAll(io.LimitReader(r.Body, m.maxBody+1))
+		// Restore the primary body regardless of what happens to the mirror copy
+		r.Body = io.NopCloser(io.MultiReader(bytes.NewReader(body), r.Body))
+		if err != nil || int64(len(body)) > m.maxBody {
+			m.drop()
+			return
+		}
+	}
+
+	select {
+	case m.sem <- struct{}{}:
+	default:
+		m.drop()
+		return
+	}
+
+	out := r.Clone(context.Background())
+	out.RequestURI = ""
+	out.URL.Scheme = target.Scheme
+	out.URL.Host = target.Host
+	out.URL.Path = singleJoiningSlash(target.Path, r.URL.Path)
+	out.Host = target.Host
+	out.Body = io.NopCloser(bytes.NewReader(body))
+	out.ContentLength = int64(len(body))
+	out.Header.Set("X-Gateway-Mirror", "1")
+	if !cfg.ForwardCredentials {
+		for _, h := range credentialHeaders {
+			out.Header.Del(h)
+		}
+		out.Header.Del("Proxy-Authorization")
+	}
+
+	go func() {
+		defer func() { <-m.sem }()
+		resp, err := m.client.Do(out)
+		if err != nil {
+			return
+		}
+		_, _ = io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}()
+}
+
+func (m *Mirror) drop() {
+	if m.onDrop != nil {
+		m.onDrop()
+	}
+}
+
+// singleJoiningSlash joins two URL paths with exactly one slash
+func singleJoiningSlash(a, b string) string {
+	aslash := len(a) > 0 && a[len(a)-1] == '/'
+	bslash := len(b) > 0 && b[0] == '/'
+	switch {
+	case aslash && bslash:
+		return a + b[1:]
+	case !aslash && !bslash && a != "" && b != "":
+		return a + "/" + b
+	}
+	return a + b
+}
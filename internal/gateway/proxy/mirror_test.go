@@ -0,0 +1,49 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/example/privacy-gateway/internal/gateway/apikey"
+	"github.com/example/privacy-gateway/internal/shared/models"
+)
+
+// TestMirrorStripsCredentials checks that the shadow upstream only sees the
+// caller's credentials when the route opts in
+func TestMirrorStripsCredentials(t *testing.T) {
+	got := make(chan http.Header, 1)
+	shadow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got <- r.Header.Clone()
+	}))
+	defer shadow.Close()
+	m := NewMirror(MirrorConfig{}, nil)
+
+	for _, forward := range []bool{false, true} {
+		r := httptest.NewRequest(http.MethodGet, "/v1/basic", nil)
+		r.Header.Set("Authorization", "Bearer secret")
+		r.Header.Set("Cookie", "session=secret")
+		r.Header.Set(apikey.Header, "pgk_id_secret")
+		r.Header.Set("Accept", "application/json")
+		m.Sample(r, &models.MirrorConfig{UpstreamURL: shadow.URL, Percent: 100, ForwardCredentials: forward})
+
+		var h http.Header
+		select {
+		case h = <-got:
+		case <-time.After(5 * time.Second):
+			t.Fatal("mirrored request not received")
+		}
+		for _, name := range []string{"Authorization", "Cookie", apikey.Header} {
+			if present := h.Get(name) != ""; present != forward {
+				t.Errorf("forward_credentials=%v: %s present = %v", forward, name, present)
+			}
+		}
+		if h.Get("Accept") != "application/json" || h.Get("X-Gateway-Mirror") != "1" {
+			t.Errorf("forward_credentials=%v: other headers not mirrored: %v", forward, h)
+		}
+		if r.Header.Get("Authorization") == "" {
+			t.Error("primary request lost its credentials")
+		}
+	}
+}
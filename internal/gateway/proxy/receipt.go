@@ -0,0 +1,181 @@
+package proxy
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/example/privacy-gateway/internal/shared/clock"
+	"github.com/example/privacy-gateway/internal/shared/models"
+)
+
+// ReceiptHeader carries a signed receipt to the upstream and the client
+const ReceiptHeader = "X-Gateway-Receipt"
+
+// ReceiptType is the typ header of receipts
+const ReceiptType = "gateway-receipt+jwt"
+
+// Receipt modes
+const (
+	ReceiptUpstream = "upstream"
+	ReceiptResponse = "response"
+	ReceiptBoth     = "both"
+)
+
+// ValidReceiptMode reports whether mode is a known receipt mode ("" is the default)
+func ValidReceiptMode(mode string) bool {
+	switch mode {
+	case "", ReceiptUpstream, ReceiptResponse, ReceiptBoth:
+		return true
+	}
+	return false
+}
+
+// ReceiptClaims is the payload of a receipt: what was requested, by whom,
+// and which policy let it through
+type ReceiptClaims struct {
+	Issuer   string          `json:"iss"`
+	Subject  string          `json:"sub"`
+	IssuedAt int64           `json:"iat"`
+	JWTID    string          `json:"jti"`
+	TokenID  string          `json:"token_jti,omitempty"` // The access token presented
+	Request  ReceiptRequest  `json:"req"`
+	Decision ReceiptDecision `json:"decision"`
+}
+
+// ReceiptRequest identifies the request. Hash is the base64url SHA-256 of
+// "<method> <uri>\n" followed by the body, as received from the client.
+type ReceiptRequest struct {
+	Method string `json:"method"`
+	URI    string `json:"uri"`
+	Hash   string `json:"hash"`
+}
+
+// ReceiptDecision is the policy decision and the credentials it relied on
+type ReceiptDecision struct {
+	PolicyID  string   `json:"policy_id"`
+	Allowed   bool     `json:"allowed"`
+	Scopes    []string `json:"scopes,omitempty"`
+	VCIssuer  string   `json:"vc_issuer,omitempty"`
+	VCTypes   []string `json:"vc_types,omitempty"`
+	TrustTier int      `json:"vc_trust_tier,omitempty"`
+	DeviceID  string   `json:"device_id,omitempty"`
+}
+
+// gatewayKey signs JWSs with the gateway DID key
+type gatewayKey struct {
+	did   string
+	kid   string
+	key   ed25519.PrivateKey
+	clock clock.Clock
+}
+
+func newGatewayKey(did, kid string, key ed25519.PrivateKey, clk clock.Clock) (gatewayKey, error) {
+	if did == "" || len(key) != ed25519.PrivateKeySize {
+		return gatewayKey{}, errors.New("the gateway DID and signing key are required")
+	}
+	if kid == "" {
+		kid = did + "#key-1"
+	}
+	return gatewayKey{did: did, kid: kid, key: key, clock: clock.Or(clk)}, nil
+}
+
+// sign returns the compact JWS of payload; header gets alg and kid
+func (k gatewayKey) sign(header map[string]interface{}, payload []byte) (string, error) {
+	header["alg"], header["kid"] = "EdDSA", k.kid
+	h, err := json.Marshal(header)
+	if err != nil {
+		return "", err
+	}
+	input := base64.RawURLEncoding.EncodeToString(h) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	return input + "." + base64.RawURLEncoding.EncodeToString(ed25519.Sign(k.key, []byte(input))), nil
+}
+
+// ReceiptSigner signs receipts with the gateway DID key
+type ReceiptSigner struct {
+	gatewayKey
+}
+
+// NewReceiptSigner creates a receipt signer; kid defaults to did + "#key-1"
+func NewReceiptSigner(did, kid string, key ed25519.PrivateKey, clk clock.Clock) (*ReceiptSigner, error) {
+	k, err := newGatewayKey(did, kid, key, clk)
+	if err != nil {
+		return nil, fmt.Errorf("receipts: %w", err)
+	}
+	return &ReceiptSigner{k}, nil
+}
+
+// Apply signs a receipt for an allowed request on a route with receipts
+// enabled, and attaches it per the route's mode: to the upstream request,
+// replacing any client-supplied value, and/or to the response headers. It
+// buffers the body to hash it, so call it after Limits.Apply has capped the
+// body and before any transform rewrites the request. On routes without
+// receipts it only strips a client-supplied receipt and returns "".
+func (s *ReceiptSigner) Apply(w http.ResponseWriter, r *http.Request, pol *models.Policy, claims *models.AccessTokenClaims) (string, error) {
+	if pol == nil || pol.Receipt == nil {
+		r.Header.Del(ReceiptHeader)
+		return "", nil
+	}
+	body, err := io.ReadAll(r.Body)
+	r.Body.Close()
+	if err != nil {
+		return "", fmt.Errorf("read body for receipt: %w", err)
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	r.ContentLength = int64(len(body))
+
+	receipt, err := s.Sign(r.Method, r.URL.RequestURI(), body, pol.ID, claims)
+	if err != nil {
+		return "", err
+	}
+	mode := pol.Receipt.Mode
+	if mode == "" || mode == ReceiptUpstream || mode == ReceiptBoth {
+		r.Header.Set(ReceiptHeader, receipt)
+	} else {
+		r.Header.Del(ReceiptHeader)
+	}
+	if mode == ReceiptResponse || mode == ReceiptBoth {
+		w.Header().Set(ReceiptHeader, receipt)
+	}
+	return receipt, nil
+}
+
+// Sign builds and signs a receipt for a request allowed by policyID
+func (s *ReceiptSigner) Sign(method, uri string, body []byte, policyID string, claims *models.AccessTokenClaims) (string, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+	h := sha256.New()
+	io.WriteString(h, method+" "+uri+"\n")
+	h.Write(body)
+	rc := ReceiptClaims{
+		Issuer:   s.did,
+		Subject:  claims.Subject,
+		IssuedAt: s.clock.Now().Unix(),
+		JWTID:    base64.RawURLEncoding.EncodeToString(id),
+		TokenID:  claims.JWTID,
+		Request:  ReceiptRequest{Method: method, URI: uri, Hash: base64.RawURLEncoding.EncodeToString(h.Sum(nil))},
+		Decision: ReceiptDecision{
+			PolicyID:  policyID,
+			Allowed:   true,
+			Scopes:    claims.Scopes,
+			VCIssuer:  claims.VCIssuer,
+			VCTypes:   claims.VCTypes,
+			TrustTier: claims.VCTrustTier,
+			DeviceID:  claims.DeviceID,
+		},
+	}
+	payload, err := json.Marshal(rc)
+	if err != nil {
+		return "", err
+	}
+	return s.sign(map[string]interface{}{"typ": ReceiptType}, payload)
+}
@@ -0,0 +1,186 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/example/privacy-gateway/internal/shared/clock"
+	"github.com/example/privacy-gateway/internal/shared/crypto"
+	"github.com/example/privacy-gateway/internal/shared/models"
+)
+
+// ResponseSignatureHeader carries the detached JWS over a signed response
+const ResponseSignatureHeader = "X-Gateway-Response-Signature"
+
+// ResponseSignatureType is the typ header of response signatures
+const ResponseSignatureType = "gateway-response+jws"
+
+// DefaultMaxSignedResponse caps the body buffered for signing on routes
+// without a response body limit
+const DefaultMaxSignedResponse = 10 << 20
+
+var (
+	ErrResponseTooLargeToSign = errors.New("upstream response too large to sign")
+	ErrInvalidResponseSig     = errors.New("invalid response signature")
+	errResponseNotCaptured    = errors.New("response signing: request was not captured")
+)
+
+// responseSigHeader is the protected header of a response signature
+type responseSigHeader struct {
+	Alg      string   `json:"alg"`
+	Kid      string   `json:"kid"`
+	Typ      string   `json:"typ"`
+	IssuedAt int64    `json:"iat"`
+	Request  string   `json:"req"`  // "<method> <uri>" of the request answered
+	Headers  []string `json:"hdrs"` // Covered headers, lower case, in payload order
+}
+
+// ResponseSigner signs upstream responses with the gateway DID key, so
+// clients can check a response came through the gateway unmodified
+type ResponseSigner struct {
+	gatewayKey
+}
+
+// NewResponseSigner creates a response signer; kid defaults to did + "#key-1"
+func NewResponseSigner(did, kid string, key ed25519.PrivateKey, clk clock.Clock) (*ResponseSigner, error) {
+	k, err := newGatewayKey(did, kid, key, clk)
+	if err != nil {
+		return nil, fmt.Errorf("response signing: %w", err)
+	}
+	return &ResponseSigner{k}, nil
+}
+
+// coveredHeaders returns the lower-cased headers a route's signature covers:
+// Content-Type, then the route's list without duplicates
+func coveredHeaders(cfg *models.ResponseSigning) []string {
+	hdrs := []string{"content-type"}
+	for _, h := range cfg.Headers {
+		h = strings.ToLower(strings.TrimSpace(h))
+		dup := h == ""
+		for _, seen := range hdrs {
+			dup = dup || seen == h
+		}
+		if !dup {
+			hdrs = append(hdrs, h)
+		}
+	}
+	return hdrs
+}
+
+// ResponseSigningInput is the detached payload of a response signature:
+// the status code, each covered header as "name: value" (values of a
+// repeated header joined with ", ", a missing header empty) and the
+// base64url SHA-256 of the body, one per line
+func ResponseSigningInput(status int, header http.Header, hdrs []string, body []byte) []byte {
+	var b bytes.Buffer
+	b.WriteString(strconv.Itoa(status))
+	b.WriteByte('\n')
+	for _, h := range hdrs {
+		b.WriteString(h + ": " + strings.Join(header.Values(h), ", ") + "\n")
+	}
+	sum := sha256.Sum256(body)
+	b.WriteString(base64.RawURLEncoding.EncodeToString(sum[:]))
+	return b.Bytes()
+}
+
+type signedRequestKey struct{}
+
+// CaptureRequest records the method and URI the client sent on routes with
+// sign_response set, for SignResponse's req header. Call it before any
+// transform rewrites the request: the upstream request may have another
+// path or query, and the client checks req against the request it sent.
+// On other routes it returns r unchanged.
+func (s *ResponseSigner) CaptureRequest(r *http.Request, pol *models.Policy) *http.Request {
+	if pol == nil || pol.SignResponse == nil {
+		return r
+	}
+	req := r.Method + " " + r.URL.RequestURI()
+	return r.WithContext(context.WithValue(r.Context(), signedRequestKey{}, req))
+}
+
+// SignResponse signs the response on routes with sign_response set; use
+// from ReverseProxy.ModifyResponse after any response transform and
+// Limits.LimitResponse, on a request prepared with CaptureRequest. The body
+// is buffered to hash it, up to maxBody (DefaultMaxSignedResponse when 0);
+// a larger body fails the response. On other routes it strips any
+// signature header the upstream set.
+func (s *ResponseSigner) SignResponse(resp *http.Response, pol *models.Policy, maxBody int64) error {
+	if pol == nil || pol.SignResponse == nil {
+		resp.Header.Del(ResponseSignatureHeader)
+		return nil
+	}
+	req, ok := resp.Request.Context().Value(signedRequestKey{}).(string)
+	if !ok {
+		return errResponseNotCaptured
+	}
+	if maxBody <= 0 {
+		maxBody = DefaultMaxSignedResponse
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxBody+1))
+	resp.Body.Close()
+	if err != nil {
+		return err
+	}
+	if int64(len(body)) > maxBody {
+		return ErrResponseTooLargeToSign
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	resp.ContentLength = int64(len(body))
+	resp.Header.Set("Content-Length", strconv.Itoa(len(body)))
+
+	hdrs := coveredHeaders(pol.SignResponse)
+	header := map[string]interface{}{
+		"typ":  ResponseSignatureType,
+		"iat":  s.clock.Now().Unix(),
+		"req":  req,
+		"hdrs": hdrs,
+	}
+	jws, err := s.sign(header, ResponseSigningInput(resp.StatusCode, resp.Header, hdrs, body))
+	if err != nil {
+		return err
+	}
+	// Detached: the payload is rebuilt from the response by the verifier
+	parts := strings.Split(jws, ".")
+	resp.Header.Set(ResponseSignatureHeader, parts[0]+".."+parts[2])
+	return nil
+}
+
+// VerifyResponse checks a response signature against the gateway key and
+// the request it answers; body is the full response body. It returns the
+// time the response was signed.
+func VerifyResponse(resp *http.Response, body []byte, pub ed25519.PublicKey) (int64, error) {
+	parts := strings.Split(resp.Header.Get(ResponseSignatureHeader), ".")
+	if len(parts) != 3 || parts[1] != "" {
+		return 0, fmt.Errorf("%w: not a detached JWS", ErrInvalidResponseSig)
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return 0, fmt.Errorf("%w: header: %v", ErrInvalidResponseSig, err)
+	}
+	var h responseSigHeader
+	if err := json.Unmarshal(raw, &h); err != nil {
+		return 0, fmt.Errorf("%w: header: %v", ErrInvalidResponseSig, err)
+	}
+	if h.Alg != "EdDSA" || h.Typ != ResponseSignatureType {
+		return 0, fmt.Errorf("%w: must be an EdDSA %s", ErrInvalidResponseSig, ResponseSignatureType)
+	}
+	if req := resp.Request; req != nil && h.Request != req.Method+" "+req.URL.RequestURI() {
+		return 0, fmt.Errorf("%w: signed for %q", ErrInvalidResponseSig, h.Request)
+	}
+	payload := ResponseSigningInput(resp.StatusCode, resp.Header, h.Headers, body)
+	input := parts[0] + "." + base64.RawURLEncoding.EncodeToString(payload)
+	if err := crypto.VerifySignature(pub, input, parts[2]); err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrInvalidResponseSig, err)
+	}
+	return h.IssuedAt, nil
+}
@@ -0,0 +1,213 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/itchyny/gojq"
+
+	"github.com/example/privacy-gateway/internal/shared/models"
+)
+
+var (
+	ErrScriptFailed  = errors.New("transform script failed")
+	ErrScriptTimeout = errors.New("transform script timed out")
+)
+
+// Script limits
+const (
+	scriptMaxBody = 1 << 20
+	scriptTimeout = 20 * time.Millisecond
+)
+
+// Script variables available to every program
+var scriptVars = []string{"$claims", "$method", "$path", "$status"}
+
+// ScriptCache compiles jq transform scripts on first use and reuses the
+// compiled program while the source is unchanged. Since lookups are keyed by
+// source, editing a policy's script in the store takes effect on the next
+// policy reload without a restart.
+type ScriptCache struct {
+	mu    sync.RWMutex
+	codes map[string]*gojq.Code
+}
+
+// NewScriptCache creates an empty script cache
+func NewScriptCache() *ScriptCache {
+	return &ScriptCache{codes: make(map[string]*gojq.Code)}
+}
+
+// Compile returns the compiled program for src
+func (c *ScriptCache) Compile(src string) (*gojq.Code, error) {
+	c.mu.RLock()
+	code, ok := c.codes[src]
+	c.mu.RUnlock()
+	if ok {
+		return code, nil
+	}
+
+	query, err := gojq.Parse(src)
+	if err != nil {
+		return nil, fmt.Errorf("invalid transform script: %w", err)
+	}
+	code, err = gojq.Compile(query, gojq.WithVariables(scriptVars))
+	if err != nil {
+		return nil, fmt.Errorf("invalid transform script: %w", err)
+	}
+
+	c.mu.Lock()
+	c.codes[src] = code
+	c.mu.Unlock()
+	return code, nil
+}
+
+// Retain drops compiled programs whose source is no longer referenced by any
+// policy. Call after each policy reload.
+func (c *ScriptCache) Retain(policies []models.Policy) {
+	live := make(map[string]bool)
+	for _, pol := range policies {
+		if pol.Scripts != nil {
+			live[pol.Scripts.Request] = true
+			live[pol.Scripts.Response] = true
+		}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for src := range c.codes {
+		if !live[src] {
+			delete(c.codes, src)
+		}
+	}
+}
+
+// TransformRequest runs the route's request script over a JSON request body
+func (c *ScriptCache) TransformRequest(r *http.Request, scripts *models.TransformScripts, claims *models.AccessTokenClaims) error {
+	if scripts == nil || scripts.Request == "" || r.Body == nil || r.Body == http.NoBody {
+		return nil
+	}
+	raw, err := readScriptBody(r.Body)
+	if err != nil {
+		return err
+	}
+	body, err := c.run(r.Context(), scripts.Request, raw, claims, r.Method, r.URL.Path, 0)
+	if err != nil {
+		return err
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	r.ContentLength = int64(len(body))
+	r.Header.Set("Content-Length", strconv.Itoa(len(body)))
+	return nil
+}
+
+// TransformResponse runs the route's response script over a JSON upstream
+// response; use from ReverseProxy.ModifyResponse. Responses that aren't
+// JSON, are content-encoded or have no body pass through untouched.
+func (c *ScriptCache) TransformResponse(resp *http.Response, scripts *models.TransformScripts, claims *models.AccessTokenClaims) error {
+	if scripts == nil || scripts.Response == "" || !scriptableResponse(resp) {
+		return nil
+	}
+	raw, err := readScriptBody(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return err
+	}
+	if len(raw) == 0 {
+		resp.Body = io.NopCloser(bytes.NewReader(nil))
+		return nil
+	}
+	req := resp.Request
+	body, err := c.run(req.Context(), scripts.Response, raw, claims, req.Method, req.URL.Path, resp.StatusCode)
+	if err != nil {
+		return err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	resp.ContentLength = int64(len(body))
+	resp.Header.Set("Content-Length", strconv.Itoa(len(body)))
+	return nil
+}
+
+// scriptableResponse reports whether a response carries a plain JSON body
+func scriptableResponse(resp *http.Response) bool {
+	if resp.Body == nil || resp.Body == http.NoBody || resp.ContentLength == 0 {
+		return false
+	}
+	if resp.StatusCode == http.StatusNoContent || resp.StatusCode == http.StatusNotModified {
+		return false
+	}
+	if ce := resp.Header.Get("Content-Encoding"); ce != "" && ce != "identity" {
+		return false
+	}
+	mt, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	return err == nil && (mt == "application/json" || strings.HasSuffix(mt, "+json"))
+}
+
+// readScriptBody reads a body up to the script size limit
+func readScriptBody(body io.Reader) ([]byte, error) {
+	raw, err := io.ReadAll(io.LimitReader(body, scriptMaxBody+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(raw) > scriptMaxBody {
+		return nil, fmt.Errorf("%w: body too large", ErrScriptFailed)
+	}
+	return raw, nil
+}
+
+// run evaluates a script against a JSON body and returns the re-encoded result.
+// The script must produce exactly one value.
+func (c *ScriptCache) run(ctx context.Context, src string, raw []byte, claims *models.AccessTokenClaims, method, path string, status int) ([]byte, error) {
+	code, err := c.Compile(src)
+	if err != nil {
+		return nil, err
+	}
+
+	var input interface{}
+	if err := json.Unmarshal(raw, &input); err != nil {
+		return nil, fmt.Errorf("%w: body is not JSON", ErrScriptFailed)
+	}
+
+	claimsVal, err := toJQValue(claims)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, scriptTimeout)
+	defer cancel()
+
+	iter := code.RunWithContext(ctx, input, claimsVal, method, path, status)
+	out, ok := iter.Next()
+	if !ok {
+		return nil, fmt.Errorf("%w: script produced no output", ErrScriptFailed)
+	}
+	if err, isErr := out.(error); isErr {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return nil, ErrScriptTimeout
+		}
+		return nil, fmt.Errorf("%w: %v", ErrScriptFailed, err)
+	}
+	if _, more := iter.Next(); more {
+		return nil, fmt.Errorf("%w: script produced more than one output", ErrScriptFailed)
+	}
+	return json.Marshal(out)
+}
+
+// toJQValue converts a struct into the map form gojq operates on
+func toJQValue(v interface{}) (interface{}, error) {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var out interface{}
+	err = json.Unmarshal(raw, &out)
+	return out, err
+}
@@ -0,0 +1,243 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"net/http"
+	"sort"
+
+	"github.com/example/privacy-gateway/internal/gateway/store"
+	"github.com/example/privacy-gateway/internal/shared/httpx"
+	"github.com/example/privacy-gateway/internal/shared/models"
+)
+
+var (
+	ErrUnknownTarget = errors.New("unknown split target")
+	ErrInvalidWeight = errors.New("weights must be non-negative")
+	ErrWeightGated   = errors.New("weight increase blocked")
+)
+
+// PickTarget chooses an upstream version for a request. With StickyByDID the
+// choice is a deterministic function of (policy, subject) so a DID keeps
+// landing on the same version while weights are unchanged.
+func PickTarget(policyID string, split *models.TrafficSplit, subject string) (models.SplitTarget, bool) {
+	if split == nil {
+		return models.SplitTarget{}, false
+	}
+
+	total := totalWeight(split.Targets)
+	if total == 0 {
+		return models.SplitTarget{}, false
+	}
+
+	var n int
+	if split.StickyByDID && subject != "" {
+		h := fnv.New32a()
+		_, _ = h.Write([]byte(policyID))
+		_, _ = h.Write([]byte{0})
+		_, _ = h.Write([]byte(subject))
+		n = int(h.Sum32() % uint32(total))
+	} else {
+		n = rand.Intn(total)
+	}
+
+	for _, t := range split.Targets {
+		if t.Weight <= 0 {
+			continue
+		}
+		if n < t.Weight {
+			return t, true
+		}
+		n -= t.Weight
+	}
+	return models.SplitTarget{}, false
+}
+
+// PickHealthyTarget is PickTarget over the targets healthy reports up. If
+// every target is down, it picks among all of them rather than fail.
+func PickHealthyTarget(policyID string, split *models.TrafficSplit, subject string, healthy func(upstream string) bool) (models.SplitTarget, bool) {
+	if split == nil || healthy == nil {
+		return PickTarget(policyID, split, subject)
+	}
+	up := models.TrafficSplit{StickyByDID: split.StickyByDID}
+	for _, t := range split.Targets {
+		if t.Weight > 0 && healthy(t.UpstreamURL) {
+			up.Targets = append(up.Targets, t)
+		}
+	}
+	if len(up.Targets) == 0 {
+		return PickTarget(policyID, split, subject)
+	}
+	return PickTarget(policyID, &up, subject)
+}
+
+// PolicyStore is the subset of the policy store needed to adjust weights.
+// UpdateTrafficSplit must read the current policy and write the split
+// atomically (store.Postgres locks the row on the primary).
+type PolicyStore interface {
+	GetPolicy(ctx context.Context, id string) (models.Policy, error)
+	UpdateTrafficSplit(ctx context.Context, id string, fn func(pol models.Policy) (*models.TrafficSplit, error)) (*models.TrafficSplit, error)
+}
+
+// weightsRequest is the body accepted by WeightsHandler
+type weightsRequest struct {
+	PolicyID string         `json:"policy_id"`
+	Weights  map[string]int `json:"weights"`
+}
+
+// WeightGate approves raising the weights of the named targets of pol, e.g.
+// only while the route's SLOs are healthy; an error blocks the whole change
+type WeightGate func(ctx context.Context, pol models.Policy, raised []string) error
+
+// SetWeights updates target weights for a policy. Weights are persisted through
+// the policy store so the policy version bump propagates them to every replica.
+func SetWeights(ctx context.Context, ps PolicyStore, policyID string, weights map[string]int) (*models.TrafficSplit, error) {
+	return SetWeightsGated(ctx, ps, nil, policyID, weights)
+}
+
+// SetWeightsGated is SetWeights that asks gate, if set, before growing the
+// share of traffic any target but the stable one (the currently heaviest)
+// receives. Shares are compared rather than raw weights, so lowering the
+// stable target from 95 to 5 is gated like raising the canary from 5 to 95,
+// while shifting weight back to the stable target, as a rollback does, is
+// always allowed.
+func SetWeightsGated(ctx context.Context, ps PolicyStore, gate WeightGate, policyID string, weights map[string]int) (*models.TrafficSplit, error) {
+	return ps.UpdateTrafficSplit(ctx, policyID, func(pol models.Policy) (*models.TrafficSplit, error) {
+		return applyWeights(ctx, gate, pol, weights)
+	})
+}
+
+// applyWeights returns pol's split with weights applied, asking gate first
+// when they grow the share of a target other than the stable one
+func applyWeights(ctx context.Context, gate WeightGate, pol models.Policy, weights map[string]int) (*models.TrafficSplit, error) {
+	if pol.TrafficSplit == nil {
+		return nil, ErrUnknownTarget
+	}
+
+	// Work on a copy so a gated change leaves the loaded policy untouched
+	before := pol.TrafficSplit.Targets
+	split := *pol.TrafficSplit
+	split.Targets = append([]models.SplitTarget(nil), before...)
+	pol.TrafficSplit = &split
+
+	for name, w := range weights {
+		if w < 0 {
+			return nil, ErrInvalidWeight
+		}
+		found := false
+		for i := range split.Targets {
+			if split.Targets[i].Name == name {
+				split.Targets[i].Weight = w
+				found = true
+			}
+		}
+		if !found {
+			return nil, ErrUnknownTarget
+		}
+	}
+
+	if gate != nil {
+		if raised := grownShares(before, split.Targets); len(raised) > 0 {
+			if err := gate(ctx, pol, raised); err != nil {
+				return nil, fmt.Errorf("%w: %v", ErrWeightGated, err)
+			}
+		}
+	}
+	return pol.TrafficSplit, nil
+}
+
+// grownShares returns the sorted names of targets, other than the stable
+// (heaviest) one in before, whose share of the total weight is larger in
+// after than in before. Both slices hold the same targets in the same order.
+func grownShares(before, after []models.SplitTarget) []string {
+	oldTotal, newTotal := totalWeight(before), totalWeight(after)
+	stable := -1
+	for i, t := range before {
+		if stable < 0 || t.Weight > before[stable].Weight {
+			stable = i
+		}
+	}
+	seen := make(map[string]bool)
+	var grown []string
+	for i := range after {
+		if i == stable {
+			continue
+		}
+		oldW, newW := max(before[i].Weight, 0), max(after[i].Weight, 0)
+		var grew bool
+		if oldTotal == 0 {
+			grew = newW > 0
+		} else {
+			// newW/newTotal > oldW/oldTotal without dividing
+			grew = newW*oldTotal > oldW*newTotal
+		}
+		if grew && !seen[after[i].Name] {
+			seen[after[i].Name] = true
+			grown = append(grown, after[i].Name)
+		}
+	}
+	sort.Strings(grown)
+	return grown
+}
+
+func totalWeight(targets []models.SplitTarget) int {
+	total := 0
+	for _, t := range targets {
+		if t.Weight > 0 {
+			total += t.Weight
+		}
+	}
+	return total
+}
+
+// WeightsHandler serves GET (?policy_id=) and PUT for traffic split weights
+func WeightsHandler(ps PolicyStore) http.HandlerFunc {
+	return WeightsHandlerGated(ps, nil)
+}
+
+// WeightsHandlerGated is WeightsHandler with weight increases approved by
+// gate; a blocked PUT gets 409 Conflict
+func WeightsHandlerGated(ps PolicyStore, gate WeightGate) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			pol, err := ps.GetPolicy(r.Context(), r.URL.Query().Get("policy_id"))
+			if errors.Is(err, store.ErrNotFound) {
+				httpx.WriteJSON(w, http.StatusNotFound, httpx.ErrorResponse{Error: "policy not found"})
+				return
+			}
+			if err != nil {
+				httpx.WriteJSON(w, http.StatusInternalServerError, httpx.ErrorResponse{Error: "failed to load policy"})
+				return
+			}
+			httpx.WriteJSON(w, http.StatusOK, pol.TrafficSplit)
+
+		case http.MethodPut:
+			var req weightsRequest
+			if err := httpx.DecodeJSON(r, &req); err != nil {
+				httpx.WriteJSON(w, http.StatusBadRequest, httpx.ErrorResponse{Error: "invalid request body"})
+				return
+			}
+			split, err := SetWeightsGated(r.Context(), ps, gate, req.PolicyID, req.Weights)
+			switch {
+			case errors.Is(err, store.ErrNotFound):
+				httpx.WriteJSON(w, http.StatusNotFound, httpx.ErrorResponse{Error: "policy not found"})
+			case errors.Is(err, ErrUnknownTarget), errors.Is(err, ErrInvalidWeight):
+				httpx.WriteJSON(w, http.StatusBadRequest, httpx.ErrorResponse{Error: err.Error()})
+			case errors.Is(err, ErrWeightGated):
+				httpx.WriteJSON(w, http.StatusConflict, httpx.ErrorResponse{Error: err.Error()})
+			case err != nil:
+				httpx.WriteJSON(w, http.StatusInternalServerError, httpx.ErrorResponse{Error: "failed to update weights"})
+			default:
+				httpx.WriteJSON(w, http.StatusOK, split)
+			}
+
+		default:
+			w.Header().Set("Allow", "GET, PUT")
+			httpx.WriteJSON(w, http.StatusMethodNotAllowed, httpx.ErrorResponse{Error: "method not allowed"})
+		}
+	}
+}
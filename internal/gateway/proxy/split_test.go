@@ -0,0 +1,63 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/example/privacy-gateway/internal/shared/models"
+)
+
+func canaryPolicy() models.Policy {
+	return models.Policy{ID: "p", TrafficSplit: &models.TrafficSplit{Targets: []models.SplitTarget{
+		{Name: "primary", UpstreamURL: "http://v1", Weight: 95},
+		{Name: "canary", UpstreamURL: "http://v2", Weight: 5},
+	}}}
+}
+
+// TestApplyWeightsGatesGrowingShares runs every change against a gate that
+// refuses, as during an SLO burn: canary promotions are blocked while
+// rollbacks to the stable target go through
+func TestApplyWeightsGatesGrowingShares(t *testing.T) {
+	cases := []struct {
+		name    string
+		weights map[string]int
+		raised  []string
+	}{
+		{"raise canary", map[string]int{"canary": 50}, []string{"canary"}},
+		{"lower primary", map[string]int{"primary": 5}, []string{"canary"}},
+		{"roll back canary", map[string]int{"canary": 0}, nil},
+		{"raise primary", map[string]int{"primary": 200}, nil},
+		{"scale both", map[string]int{"primary": 190, "canary": 10}, nil},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			pol := canaryPolicy()
+			var got []string
+			gate := func(_ context.Context, _ models.Policy, raised []string) error {
+				got = raised
+				return errors.New("slo unhealthy")
+			}
+			_, err := applyWeights(context.Background(), gate, pol, c.weights)
+			if c.raised == nil {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if got != nil {
+					t.Fatalf("gate called with %v", got)
+				}
+				return
+			}
+			if !errors.Is(err, ErrWeightGated) {
+				t.Fatalf("err = %v, want ErrWeightGated", err)
+			}
+			if !reflect.DeepEqual(got, c.raised) {
+				t.Fatalf("gate raised = %v, want %v", got, c.raised)
+			}
+			if pol.TrafficSplit.Targets[0].Weight != 95 || pol.TrafficSplit.Targets[1].Weight != 5 {
+				t.Fatalf("gated change modified the loaded policy: %+v", pol.TrafficSplit.Targets)
+			}
+		})
+	}
+}
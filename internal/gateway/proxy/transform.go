@@ -0,0 +1,203 @@
+package proxy
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/example/privacy-gateway/internal/shared/models"
+)
+
+var (
+	ErrMissingClaim     = errors.New("claim required by route transform is missing")
+	ErrEncodedSeparator = errors.New("encoded path separator in rewritten path")
+)
+
+// claimRefRegex matches ${claim} references in transform values
+var claimRefRegex = regexp.MustCompile(`\$\{([a-zA-Z0-9_.]+)\}`)
+
+// Transformer applies a compiled RequestTransform
+type Transformer struct {
+	stripPrefix string
+	rewrite     *regexp.Regexp
+	rewriteTo   string
+	setQuery    map[string]string
+	setHeaders  map[string]string
+}
+
+// CompileTransform validates and compiles a route transform. It is called at
+// policy load time so invalid patterns are rejected before they serve traffic.
+func CompileTransform(t *models.RequestTransform) (*Transformer, error) {
+	if t == nil {
+		return nil, nil
+	}
+	tr := &Transformer{
+		stripPrefix: t.StripPrefix,
+		rewriteTo:   t.RewriteTo,
+		setQuery:    t.SetQuery,
+		setHeaders:  make(map[string]string, len(t.SetHeaders)),
+	}
+	if t.RewritePattern != "" {
+		re, err := regexp.Compile(t.RewritePattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rewrite pattern: %w", err)
+		}
+		tr.rewrite = re
+	}
+	for k, v := range t.SetHeaders {
+		tr.setHeaders[http.CanonicalHeaderKey(k)] = v
+	}
+	return tr, nil
+}
+
+// Apply rewrites the path and injects query/header values derived from claims.
+// Injected keys overwrite any client-supplied values so callers can't spoof them.
+// A path that would be rewritten is rejected with ErrEncodedSeparator if it
+// holds an encoded slash or backslash.
+func (t *Transformer) Apply(r *http.Request, claims *models.AccessTokenClaims) error {
+	if t == nil {
+		return nil
+	}
+
+	// The rewrite works on the decoded path and the result is sent unescaped,
+	// so an encoded slash or backslash would reach the upstream as a real
+	// separator and step past its path-based checks; refuse those instead
+	if t.stripPrefix != "" || t.rewrite != nil {
+		escaped := strings.ToLower(r.URL.EscapedPath())
+		if strings.Contains(escaped, "%2f") || strings.Contains(escaped, "%5c") {
+			return ErrEncodedSeparator
+		}
+	}
+
+	path := r.URL.Path
+	if rest, ok := strings.CutPrefix(path, strings.TrimSuffix(t.stripPrefix, "/")); ok && t.stripPrefix != "" &&
+		(rest == "" || rest[0] == '/') {
+		path = "/" + strings.TrimPrefix(rest, "/")
+	}
+	if t.rewrite != nil {
+		path = t.rewrite.ReplaceAllString(path, t.rewriteTo)
+	}
+	if path != r.URL.Path {
+		r.URL.Path = path
+		r.URL.RawPath = ""
+	}
+
+	if len(t.setQuery) > 0 {
+		q := r.URL.Query()
+		for k, tmpl := range t.setQuery {
+			v, err := expandClaims(tmpl, claims)
+			if err != nil {
+				return err
+			}
+			q.Set(k, v)
+		}
+		r.URL.RawQuery = q.Encode()
+	}
+
+	for k, tmpl := range t.setHeaders {
+		v, err := expandClaims(tmpl, claims)
+		if err != nil {
+			return err
+		}
+		r.Header.Set(k, v)
+	}
+	return nil
+}
+
+// expandClaims substitutes ${claim} references in tmpl
+func expandClaims(tmpl string, claims *models.AccessTokenClaims) (string, error) {
+	var missing error
+	out := claimRefRegex.ReplaceAllStringFunc(tmpl, func(ref string) string {
+		v, ok := lookupClaim(claims, ref[2:len(ref)-1])
+		if !ok {
+			missing = fmt.Errorf("%w: %s", ErrMissingClaim, ref)
+		}
+		return v
+	})
+	return out, missing
+}
+
+// ReferencedVCClaims returns the top-level credentialSubject claims that
+// the policies' transforms reference as ${vc_claims.<name>...}, sorted.
+// Token minting copies these into AccessTokenClaims.VCClaims (see
+// credential.Credential.ApplyTo), so tokens carry only what routes use.
+func ReferencedVCClaims(policies []models.Policy) []string {
+	seen := make(map[string]bool)
+	for _, pol := range policies {
+		if pol.Transform == nil {
+			continue
+		}
+		for _, values := range []map[string]string{pol.Transform.SetQuery, pol.Transform.SetHeaders} {
+			for _, v := range values {
+				for _, m := range claimRefRegex.FindAllStringSubmatch(v, -1) {
+					if path, ok := strings.CutPrefix(m[1], "vc_claims."); ok {
+						name, _, _ := strings.Cut(path, ".")
+						seen[name] = name != ""
+					}
+				}
+			}
+		}
+	}
+	names := make([]string, 0, len(seen))
+	for name, ok := range seen {
+		if ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// lookupClaim resolves a claim name against the access token claims
+func lookupClaim(claims *models.AccessTokenClaims, name string) (string, bool) {
+	if claims == nil {
+		return "", false
+	}
+	switch name {
+	case "sub":
+		return claims.Subject, claims.Subject != ""
+	case "vc_issuer":
+		return claims.VCIssuer, claims.VCIssuer != ""
+	case "vc_trust_tier":
+		// Absent without a credential, rather than tier 0
+		return strconv.Itoa(claims.VCTrustTier), claims.VCIssuer != ""
+	case "scopes":
+		return strings.Join(claims.Scopes, " "), len(claims.Scopes) > 0
+	case "jti":
+		return claims.JWTID, claims.JWTID != ""
+	case "account_id":
+		return claims.AccountID, claims.AccountID != ""
+	case "device_id":
+		return claims.DeviceID, claims.DeviceID != ""
+	case "trust_score":
+		return strconv.Itoa(claims.TrustScore), true
+	}
+
+	path, ok := strings.CutPrefix(name, "vc_claims.")
+	if !ok || claims.VCIssuer == "" {
+		return "", false
+	}
+	var cur interface{} = claims.VCClaims
+	for _, part := range strings.Split(path, ".") {
+		m, ok := cur.(map[string]interface{})
+		if !ok {
+			return "", false
+		}
+		if cur, ok = m[part]; !ok {
+			return "", false
+		}
+	}
+	switch v := cur.(type) {
+	case string:
+		return v, true
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), true
+	case bool:
+		return strconv.FormatBool(v), true
+	}
+	return "", false
+}
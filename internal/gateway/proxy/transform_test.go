@@ -0,0 +1,36 @@
+package proxy
+
+import (
+	"errors"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/example/privacy-gateway/internal/shared/models"
+)
+
+func TestApplyRejectsEncodedSeparators(t *testing.T) {
+	tr, err := CompileTransform(&models.RequestTransform{StripPrefix: "/api"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		target string
+		path   string
+		err    error
+	}{
+		{"/api/users/1", "/users/1", nil},
+		{"/api/users%2F..%2Fadmin", "", ErrEncodedSeparator},
+		{"/api/users%2fadmin", "", ErrEncodedSeparator},
+		{"/api/users%5C..%5Cadmin", "", ErrEncodedSeparator},
+	}
+	for _, c := range cases {
+		r := httptest.NewRequest("GET", c.target, nil)
+		err := tr.Apply(r, &models.AccessTokenClaims{})
+		if !errors.Is(err, c.err) {
+			t.Fatalf("%s: err = %v, want %v", c.target, err, c.err)
+		}
+		if err == nil && r.URL.Path != c.path {
+			t.Fatalf("%s: path = %q, want %q", c.target, r.URL.Path, c.path)
+		}
+	}
+}
@@ -0,0 +1,218 @@
+package proxy
+
+import (
+	"context"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/example/privacy-gateway/internal/shared/health"
+	"github.com/example/privacy-gateway/internal/shared/models"
+	"github.com/example/privacy-gateway/internal/shared/observability"
+)
+
+// UpstreamHealthConfig configures upstream health probing
+type UpstreamHealthConfig struct {
+	// Health, when set, gets one non-critical component per upstream, so
+	// /healthz reflects upstream reachability
+	Health           *health.HealthChecker
+	Path             string        // Probe path appended to each upstream URL (default "/healthz")
+	ExpectedStatus   int           // Default 200
+	Interval         time.Duration // Default 10s
+	Timeout          time.Duration // Per probe (default 2s)
+	FailureThreshold int           // Consecutive failed probes before a target is marked down (default 2)
+	Logger           *slog.Logger
+}
+
+// UpstreamHealth probes every configured upstream in the background. A
+// target that fails FailureThreshold probes in a row is marked down and
+// traffic splits route around it (see PickHealthyTarget) until a probe
+// succeeds again. /healthz reports the last probe result instead of
+// probing upstreams on every load balancer check.
+type UpstreamHealth struct {
+	cfg UpstreamHealthConfig
+
+	mu      sync.Mutex
+	targets map[string]*upstreamTarget // By upstream URL
+}
+
+type upstreamTarget struct {
+	upstream  string
+	checker   *health.HTTPChecker
+	threshold int
+
+	mu       sync.Mutex
+	err      error
+	failures int
+}
+
+// NewUpstreamHealth creates an upstream prober; call SetUpstreams and Run
+func NewUpstreamHealth(cfg UpstreamHealthConfig) *UpstreamHealth {
+	if cfg.Path == "" {
+		cfg.Path = "/healthz"
+	}
+	if cfg.Interval == 0 {
+		cfg.Interval = 10 * time.Second
+	}
+	if cfg.FailureThreshold == 0 {
+		cfg.FailureThreshold = 2
+	}
+	cfg.Logger = observability.Component(cfg.Logger, observability.ComponentProxy)
+	return &UpstreamHealth{cfg: cfg, targets: make(map[string]*upstreamTarget)}
+}
+
+// UpstreamsFromPolicies returns the distinct upstream URLs to probe: the
+// given defaults plus every traffic split target. Mirror upstreams only
+// receive copies and are left out.
+func UpstreamsFromPolicies(policies []models.Policy, defaults ...string) []string {
+	seen := make(map[string]bool)
+	var out []string
+	add := func(u string) {
+		if u != "" && !seen[u] {
+			seen[u] = true
+			out = append(out, u)
+		}
+	}
+	for _, u := range defaults {
+		add(u)
+	}
+	for _, pol := range policies {
+		if pol.TrafficSplit == nil {
+			continue
+		}
+		for _, t := range pol.TrafficSplit.Targets {
+			add(t.UpstreamURL)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// SetUpstreams replaces the probed upstreams; call it whenever policies
+// are reloaded. New upstreams are probed right away.
+func (u *UpstreamHealth) SetUpstreams(ctx context.Context, upstreams []string) {
+	wanted := make(map[string]bool, len(upstreams))
+	var added []*upstreamTarget
+
+	u.mu.Lock()
+	for _, upstream := range upstreams {
+		wanted[upstream] = true
+		if _, ok := u.targets[upstream]; ok {
+			continue
+		}
+		t := &upstreamTarget{
+			upstream:  upstream,
+			threshold: u.cfg.FailureThreshold,
+			checker: health.NewHTTPChecker(health.HTTPCheckerConfig{
+				Name:           "upstream " + upstream,
+				URL:            singleJoiningSlash(upstream, u.cfg.Path),
+				ExpectedStatus: u.cfg.ExpectedStatus,
+				Timeout:        u.cfg.Timeout,
+			}),
+		}
+		u.targets[upstream] = t
+		added = append(added, t)
+		if u.cfg.Health != nil {
+			u.cfg.Health.RegisterComponent(t, health.ComponentInfo{Endpoint: t.checker.URL()})
+		}
+	}
+	for upstream, t := range u.targets {
+		if !wanted[upstream] {
+			delete(u.targets, upstream)
+			if u.cfg.Health != nil {
+				u.cfg.Health.Unregister(t.Name())
+			}
+		}
+	}
+	u.mu.Unlock()
+
+	for _, t := range added {
+		go u.probe(ctx, t)
+	}
+}
+
+// Run probes every upstream each interval until ctx is cancelled
+func (u *UpstreamHealth) Run(ctx context.Context) {
+	ticker := time.NewTicker(u.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		u.mu.Lock()
+		targets := make([]*upstreamTarget, 0, len(u.targets))
+		for _, t := range u.targets {
+			targets = append(targets, t)
+		}
+		u.mu.Unlock()
+
+		var wg sync.WaitGroup
+		for _, t := range targets {
+			wg.Add(1)
+			go func(t *upstreamTarget) {
+				defer wg.Done()
+				u.probe(ctx, t)
+			}(t)
+		}
+		wg.Wait()
+	}
+}
+
+func (u *UpstreamHealth) probe(ctx context.Context, t *upstreamTarget) {
+	err := t.checker.Check(ctx)
+	if ctx.Err() != nil {
+		return
+	}
+	t.mu.Lock()
+	wasDown := t.failures >= u.cfg.FailureThreshold
+	t.err = err
+	if err != nil {
+		t.failures++
+	} else {
+		t.failures = 0
+	}
+	down := t.failures >= u.cfg.FailureThreshold
+	t.mu.Unlock()
+
+	switch {
+	case down && !wasDown:
+		u.cfg.Logger.Warn("upstream marked down", "upstream", t.upstream, "error", err)
+	case !down && wasDown:
+		u.cfg.Logger.Info("upstream recovered", "upstream", t.upstream)
+	}
+}
+
+// Healthy reports whether an upstream is up; unknown upstreams count as up
+func (u *UpstreamHealth) Healthy(upstream string) bool {
+	u.mu.Lock()
+	t, ok := u.targets[upstream]
+	u.mu.Unlock()
+	if !ok {
+		return true
+	}
+	return !t.down()
+}
+
+// Name and Check make a target a health.Checker that reports the last
+// probe, once the target is marked down
+func (t *upstreamTarget) Name() string {
+	return t.checker.Name()
+}
+
+func (t *upstreamTarget) Check(context.Context) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.failures < t.threshold {
+		return nil
+	}
+	return t.err
+}
+
+func (t *upstreamTarget) down() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.failures >= t.threshold
+}
@@ -0,0 +1,78 @@
+package quota
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/example/privacy-gateway/internal/shared/clock"
+	"github.com/example/privacy-gateway/internal/shared/models"
+	"github.com/example/privacy-gateway/internal/shared/observability"
+)
+
+// UsageStore persists flushed usage; store.Postgres implements it
+type UsageStore interface {
+	UpsertQuotaUsage(ctx context.Context, usage []models.QuotaUsage) error
+	ListQuotaUsage(ctx context.Context, scope, subject, from, to string) ([]models.QuotaUsage, error)
+}
+
+// Flusher periodically copies Redis counters to Postgres, the durable record
+// billing reads from. Counters are written as absolute values, so running a
+// flusher on every replica is safe.
+type Flusher struct {
+	tracker  *Tracker
+	store    UsageStore
+	interval time.Duration
+	logger   *slog.Logger
+}
+
+// NewFlusher creates a flusher; interval defaults to one minute
+func NewFlusher(tracker *Tracker, store UsageStore, interval time.Duration, logger *slog.Logger) *Flusher {
+	if interval == 0 {
+		interval = time.Minute
+	}
+	logger = observability.Component(logger, observability.ComponentMetering)
+	return &Flusher{tracker: tracker, store: store, interval: interval, logger: logger}
+}
+
+// Run flushes until ctx is cancelled, with a final flush on the way out
+func (f *Flusher) Run(ctx context.Context) {
+	ticker := clock.NewTicker(f.tracker.clock, f.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C():
+			f.flush(ctx)
+		case <-ctx.Done():
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			f.flush(shutdownCtx)
+			cancel()
+			return
+		}
+	}
+}
+
+// flush copies all counters once, logging failures
+func (f *Flusher) flush(ctx context.Context) {
+	if err := f.Flush(ctx); err != nil {
+		f.logger.Error("quota flush failed", "error", err)
+	}
+}
+
+// Flush copies all counters once, one SCAN page per write. It is the unit
+// of work for running the flush as a scheduled job instead of with Run.
+func (f *Flusher) Flush(ctx context.Context) error {
+	var writeErr error
+	err := f.tracker.scan(ctx, "q|*", func(usage []models.QuotaUsage) error {
+		if err := f.store.UpsertQuotaUsage(ctx, usage); err != nil {
+			writeErr = fmt.Errorf("write %d usage records: %w", len(usage), err)
+			return writeErr
+		}
+		return nil
+	})
+	if err != nil && writeErr == nil {
+		return fmt.Errorf("read counters: %w", err)
+	}
+	return err
+}
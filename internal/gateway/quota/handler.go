@@ -0,0 +1,150 @@
+package quota
+
+import (
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/example/privacy-gateway/internal/shared/httpx"
+	"github.com/example/privacy-gateway/internal/shared/models"
+)
+
+// exceededResponse is the 429 body when a quota is exhausted
+type exceededResponse struct {
+	Error   string    `json:"error"`
+	Period  string    `json:"period"`
+	Limit   int64     `json:"limit"`
+	ResetAt time.Time `json:"reset_at"`
+}
+
+// WriteExceeded writes the 429 quota-exceeded response for res. Retry-After
+// is only sent when res says when the quota resets.
+func WriteExceeded(w http.ResponseWriter, res Result) {
+	if !res.ResetAt.IsZero() && res.RetryAfter > 0 {
+		retry := int(res.RetryAfter.Seconds()) + 1
+		w.Header().Set("Retry-After", strconv.Itoa(retry))
+	}
+	httpx.WriteJSON(w, http.StatusTooManyRequests, exceededResponse{
+		Error:   "quota_exceeded",
+		Period:  res.Period,
+		Limit:   res.Limit,
+		ResetAt: res.ResetAt,
+	})
+}
+
+// WriteError writes the response for a Consume error: 403 when the request
+// has no subject to charge, 429 when a period is exhausted, 500 otherwise
+func WriteError(w http.ResponseWriter, res Result, err error) {
+	switch {
+	case errors.Is(err, ErrNoSubject):
+		httpx.WriteJSON(w, http.StatusForbidden, httpx.ErrorResponse{Error: err.Error()})
+	case errors.Is(err, ErrQuotaExceeded):
+		WriteExceeded(w, res)
+	default:
+		httpx.WriteJSON(w, http.StatusInternalServerError, httpx.ErrorResponse{Error: "failed to check quota"})
+	}
+}
+
+// usageResponse is returned by the usage endpoint
+type usageResponse struct {
+	Scope   string              `json:"scope"`
+	Subject string              `json:"subject"`
+	From    string              `json:"from"`
+	To      string              `json:"to"`
+	Usage   []models.QuotaUsage `json:"usage"`
+}
+
+// UsageHandler serves GET /admin/v1/usage?did=|issuer=&from=&to= for billing.
+// from and to are period strings (YYYY-MM or YYYY-MM-DD) and default to the
+// current month. Stored records are merged with live Redis counters so the
+// current period is up to date even between flushes.
+func UsageHandler(store UsageStore, tracker *Tracker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			httpx.WriteJSON(w, http.StatusMethodNotAllowed, httpx.ErrorResponse{Error: "method not allowed"})
+			return
+		}
+		q := r.URL.Query()
+		scope, subject := ScopeDID, q.Get("did")
+		if issuer := q.Get("issuer"); issuer != "" {
+			scope, subject = ScopeIssuer, issuer
+		}
+		if subject == "" {
+			httpx.WriteJSON(w, http.StatusBadRequest, httpx.ErrorResponse{Error: "did or issuer is required"})
+			return
+		}
+
+		month := tracker.clock.Now().UTC().Format(monthLayout)
+		from, to := q.Get("from"), q.Get("to")
+		if from == "" {
+			from = month
+		}
+		if to == "" {
+			to = month + "-31"
+		}
+		if !validPeriod(from) || !validPeriod(to) {
+			httpx.WriteJSON(w, http.StatusBadRequest, httpx.ErrorResponse{Error: "from and to must be YYYY-MM or YYYY-MM-DD"})
+			return
+		}
+
+		stored, err := store.ListQuotaUsage(r.Context(), scope, subject, from, to)
+		if err != nil {
+			httpx.WriteJSON(w, http.StatusInternalServerError, httpx.ErrorResponse{Error: "failed to load usage"})
+			return
+		}
+		live, err := tracker.Live(r.Context(), scope, subject)
+		if err != nil {
+			httpx.WriteJSON(w, http.StatusInternalServerError, httpx.ErrorResponse{Error: "failed to load usage"})
+			return
+		}
+
+		httpx.WriteJSON(w, http.StatusOK, usageResponse{
+			Scope:   scope,
+			Subject: subject,
+			From:    from,
+			To:      to,
+			Usage:   mergeUsage(stored, live, from, to),
+		})
+	}
+}
+
+// mergeUsage combines stored and live records, keeping the higher count for
+// each policy and period
+func mergeUsage(stored, live []models.QuotaUsage, from, to string) []models.QuotaUsage {
+	type key struct{ policy, period string }
+	merged := make(map[key]models.QuotaUsage, len(stored)+len(live))
+	for _, u := range stored {
+		merged[key{u.PolicyID, u.Period}] = u
+	}
+	for _, u := range live {
+		if u.Period < from || u.Period > to {
+			continue
+		}
+		k := key{u.PolicyID, u.Period}
+		if cur, ok := merged[k]; !ok || u.Count > cur.Count {
+			merged[k] = u
+		}
+	}
+
+	out := make([]models.QuotaUsage, 0, len(merged))
+	for _, u := range merged {
+		out = append(out, u)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Period != out[j].Period {
+			return out[i].Period < out[j].Period
+		}
+		return out[i].PolicyID < out[j].PolicyID
+	})
+	return out
+}
+
+func validPeriod(p string) bool {
+	if _, err := time.Parse(dayLayout, p); err == nil {
+		return true
+	}
+	_, err := time.Parse(monthLayout, p)
+	return err == nil
+}
@@ -0,0 +1,213 @@
+package quota
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/example/privacy-gateway/internal/shared/clock"
+	"github.com/example/privacy-gateway/internal/shared/models"
+)
+
+var (
+	ErrQuotaExceeded = errors.New("quota exceeded")
+	// ErrNoSubject means the request has nothing an issuer-scoped or DID
+	// quota can be charged to, e.g. no credential issuer
+	ErrNoSubject = errors.New("no quota subject")
+)
+
+// Quota scopes
+const (
+	ScopeDID    = "did"
+	ScopeIssuer = "issuer"
+)
+
+// Period names reported in Result
+const (
+	PeriodDaily   = "daily"
+	PeriodMonthly = "monthly"
+)
+
+// Period key layouts
+const (
+	dayLayout   = "2006-01-02"
+	monthLayout = "2006-01"
+)
+
+// Result is the outcome of a quota check
+type Result struct {
+	Allowed bool      `json:"allowed"`
+	Period  string    `json:"period,omitempty"` // Exhausted period when not allowed
+	Limit   int64     `json:"limit,omitempty"`
+	Daily   int64     `json:"daily"`
+	Monthly int64     `json:"monthly"`
+	ResetAt time.Time `json:"reset_at,omitempty"`
+	// RetryAfter is the time left until ResetAt when the request was checked
+	RetryAfter time.Duration `json:"-"`
+}
+
+// consumeScript checks both periods and only counts the request when neither
+// is exhausted, so rejected requests don't eat into the next window.
+//
+//	KEYS: daily, monthly   ARGV: daily limit, monthly limit, daily expireat, monthly expireat
+var consumeScript = redis.NewScript(`
+local d = tonumber(redis.call('GET', KEYS[1]) or '0')
+local m = tonumber(redis.call('GET', KEYS[2]) or '0')
+if tonumber(ARGV[1]) > 0 and d >= tonumber(ARGV[1]) then return {1, d, m} end
+if tonumber(ARGV[2]) > 0 and m >= tonumber(ARGV[2]) then return {2, d, m} end
+d = redis.call('INCR', KEYS[1])
+redis.call('EXPIREAT', KEYS[1], ARGV[3])
+m = redis.call('INCR', KEYS[2])
+redis.call('EXPIREAT', KEYS[2], ARGV[4])
+return {0, d, m}
+`)
+
+// Counter keys outlive their period so the flusher can record the final value
+const (
+	dailyRetention   = 48 * time.Hour
+	monthlyRetention = 7 * 24 * time.Hour
+)
+
+// scanCount is the SCAN page size; each page is read with one MGET
+const scanCount = 500
+
+// Tracker counts cumulative usage in Redis
+type Tracker struct {
+	client *redis.Client
+	clock  clock.Clock
+}
+
+// NewTracker creates a quota tracker. clk picks the period and reset time
+// (default clock.Real).
+func NewTracker(client *redis.Client, clk clock.Clock) *Tracker {
+	return &Tracker{client: client, clock: clock.Or(clk)}
+}
+
+// usageKey uses | as separator since DIDs contain colons but never pipes
+func usageKey(scope, subject, policyID, period string) string {
+	return "q|" + scope + "|" + subject + "|" + policyID + "|" + period
+}
+
+// parseUsageKey is the inverse of usageKey
+func parseUsageKey(key string) (models.QuotaUsage, bool) {
+	parts := strings.Split(key, "|")
+	if len(parts) != 5 || parts[0] != "q" {
+		return models.QuotaUsage{}, false
+	}
+	return models.QuotaUsage{Scope: parts[1], Subject: parts[2], PolicyID: parts[3], Period: parts[4]}, true
+}
+
+// Subject picks the quota subject for a policy: the DID, or the credential
+// issuer when the quota is issuer-scoped
+func Subject(q *models.Quota, did, issuer string) (string, string) {
+	if q.Scope == ScopeIssuer {
+		return ScopeIssuer, issuer
+	}
+	return ScopeDID, did
+}
+
+// Consume counts one request against the policy quota. Policies without a
+// quota are always allowed. When a period is exhausted the result is not
+// allowed and the error is ErrQuotaExceeded. A request without a subject to
+// charge fails with ErrNoSubject instead.
+func (t *Tracker) Consume(ctx context.Context, pol *models.Policy, did, issuer string) (Result, error) {
+	q := pol.Quota
+	if q == nil || (q.Daily == 0 && q.Monthly == 0) {
+		return Result{Allowed: true}, nil
+	}
+	scope, subject := Subject(q, did, issuer)
+	if subject == "" {
+		return Result{}, fmt.Errorf("%w: no %s to charge", ErrNoSubject, scope)
+	}
+
+	now := t.clock.Now().UTC()
+	dayEnd := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, time.UTC)
+	monthEnd := time.Date(now.Year(), now.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+	keys := []string{
+		usageKey(scope, subject, pol.ID, now.Format(dayLayout)),
+		usageKey(scope, subject, pol.ID, now.Format(monthLayout)),
+	}
+	vals, err := consumeScript.Run(ctx, t.client, keys,
+		q.Daily, q.Monthly,
+		dayEnd.Add(dailyRetention).Unix(), monthEnd.Add(monthlyRetention).Unix(),
+	).Int64Slice()
+	if err != nil {
+		return Result{}, err
+	}
+
+	res := Result{Allowed: vals[0] == 0, Daily: vals[1], Monthly: vals[2]}
+	switch vals[0] {
+	case 1:
+		res.Period, res.Limit, res.ResetAt = PeriodDaily, q.Daily, dayEnd
+	case 2:
+		res.Period, res.Limit, res.ResetAt = PeriodMonthly, q.Monthly, monthEnd
+	default:
+		return res, nil
+	}
+	res.RetryAfter = res.ResetAt.Sub(now)
+	return res, fmt.Errorf("%w: %s limit of %d requests", ErrQuotaExceeded, res.Period, res.Limit)
+}
+
+// Live returns the current Redis counters for a subject
+func (t *Tracker) Live(ctx context.Context, scope, subject string) ([]models.QuotaUsage, error) {
+	var out []models.QuotaUsage
+	err := t.scan(ctx, "q|"+scope+"|"+subject+"|*", func(page []models.QuotaUsage) error {
+		out = append(out, page...)
+		return nil
+	})
+	return out, err
+}
+
+// scan reads the usage counters matching pattern one SCAN page at a time,
+// so no single MGET grows with the number of counters
+func (t *Tracker) scan(ctx context.Context, pattern string, fn func([]models.QuotaUsage) error) error {
+	var cursor uint64
+	for {
+		keys, next, err := t.client.Scan(ctx, cursor, pattern, scanCount).Result()
+		if err != nil {
+			return err
+		}
+		if len(keys) > 0 {
+			page, err := t.read(ctx, keys)
+			if err != nil {
+				return err
+			}
+			if len(page) > 0 {
+				if err := fn(page); err != nil {
+					return err
+				}
+			}
+		}
+		if next == 0 {
+			return nil
+		}
+		cursor = next
+	}
+}
+
+// read loads the counters at keys
+func (t *Tracker) read(ctx context.Context, keys []string) ([]models.QuotaUsage, error) {
+	vals, err := t.client.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+	now := t.clock.Now().UTC()
+	out := make([]models.QuotaUsage, 0, len(keys))
+	for i, key := range keys {
+		u, ok := parseUsageKey(key)
+		s, isStr := vals[i].(string)
+		if !ok || !isStr {
+			continue // Malformed or expired between SCAN and MGET
+		}
+		if _, err := fmt.Sscan(s, &u.Count); err != nil {
+			continue
+		}
+		u.UpdatedAt = now
+		out = append(out, u)
+	}
+	return out, nil
+}
@@ -0,0 +1,67 @@
+package quota_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+
+	"github.com/example/privacy-gateway/internal/gateway/quota"
+	"github.com/example/privacy-gateway/internal/shared/clock"
+	"github.com/example/privacy-gateway/internal/shared/models"
+)
+
+func newTracker(t *testing.T, clk clock.Clock) *quota.Tracker {
+	mr := miniredis.RunT(t)
+	return quota.NewTracker(redis.NewClient(&redis.Options{Addr: mr.Addr()}), clk)
+}
+
+// TestConsumeWithoutSubject checks that a request with no issuer to charge
+// is refused with 403 and no Retry-After, not as an exhausted quota
+func TestConsumeWithoutSubject(t *testing.T) {
+	tracker := newTracker(t, nil)
+	pol := &models.Policy{ID: "partner", Quota: &models.Quota{Scope: quota.ScopeIssuer, Daily: 10}}
+
+	res, err := tracker.Consume(context.Background(), pol, "did:key:z6Mk", "")
+	if !errors.Is(err, quota.ErrNoSubject) || errors.Is(err, quota.ErrQuotaExceeded) {
+		t.Fatalf("err = %v, want ErrNoSubject", err)
+	}
+	w := httptest.NewRecorder()
+	quota.WriteError(w, res, err)
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("status = %d, want 403", w.Code)
+	}
+	if got := w.Header().Get("Retry-After"); got != "" {
+		t.Fatalf("Retry-After = %q, want none", got)
+	}
+}
+
+// TestConsumeExceededRetryAfter checks that Retry-After counts down to the
+// reset on the injected clock
+func TestConsumeExceededRetryAfter(t *testing.T) {
+	clk := clock.NewFake(time.Date(2030, 3, 14, 23, 0, 0, 0, time.UTC))
+	tracker := newTracker(t, clk)
+	pol := &models.Policy{ID: "basic", Quota: &models.Quota{Daily: 1}}
+	ctx := context.Background()
+
+	if _, err := tracker.Consume(ctx, pol, "did:key:z6Mk", ""); err != nil {
+		t.Fatal(err)
+	}
+	res, err := tracker.Consume(ctx, pol, "did:key:z6Mk", "")
+	if !errors.Is(err, quota.ErrQuotaExceeded) {
+		t.Fatalf("err = %v, want ErrQuotaExceeded", err)
+	}
+	w := httptest.NewRecorder()
+	quota.WriteError(w, res, err)
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("status = %d, want 429", w.Code)
+	}
+	if got := w.Header().Get("Retry-After"); got != "3601" {
+		t.Fatalf("Retry-After = %q, want 3601", got)
+	}
+}
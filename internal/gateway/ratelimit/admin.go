@@ -0,0 +1,122 @@
+package ratelimit
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/example/privacy-gateway/internal/shared/httpx"
+)
+
+// overrideRequest is the body of PUT /admin/v1/ratelimits/overrides
+type overrideRequest struct {
+	DID         string `json:"did"`
+	PolicyID    string `json:"policy_id,omitempty"`
+	Action      string `json:"action"`
+	MaxRequests int    `json:"max_requests,omitempty"`
+	TTLSeconds  int    `json:"ttl_seconds,omitempty"`
+	Reason      string `json:"reason,omitempty"`
+}
+
+// countersResponse is returned by GET /admin/v1/ratelimits
+type countersResponse struct {
+	DID       string     `json:"did"`
+	Counters  []Counter  `json:"counters"`
+	Overrides []Override `json:"overrides"`
+}
+
+// CountersHandler serves the rate limit state of a DID:
+//
+//	GET    /admin/v1/ratelimits?did=            current window counters and overrides
+//	DELETE /admin/v1/ratelimits?did=&policy_id= reset counters (all policies when policy_id is empty)
+func CountersHandler(l *Limiter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		did := r.URL.Query().Get("did")
+		if did == "" {
+			httpx.WriteJSON(w, http.StatusBadRequest, httpx.ErrorResponse{Error: "did is required"})
+			return
+		}
+		switch r.Method {
+		case http.MethodGet:
+			counters, err := l.Counters(r.Context(), did)
+			if err != nil {
+				httpx.WriteJSON(w, http.StatusInternalServerError, httpx.ErrorResponse{Error: "failed to load counters"})
+				return
+			}
+			overrides, err := l.Overrides(r.Context(), did)
+			if err != nil {
+				httpx.WriteJSON(w, http.StatusInternalServerError, httpx.ErrorResponse{Error: "failed to load overrides"})
+				return
+			}
+			httpx.WriteJSON(w, http.StatusOK, countersResponse{DID: did, Counters: counters, Overrides: overrides})
+
+		case http.MethodDelete:
+			if err := l.Reset(r.Context(), did, r.URL.Query().Get("policy_id")); err != nil {
+				httpx.WriteJSON(w, http.StatusInternalServerError, httpx.ErrorResponse{Error: "failed to reset counters"})
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+
+		default:
+			w.Header().Set("Allow", "GET, DELETE")
+			httpx.WriteJSON(w, http.StatusMethodNotAllowed, httpx.ErrorResponse{Error: "method not allowed"})
+		}
+	}
+}
+
+// OverridesHandler serves live overrides:
+//
+//	GET    /admin/v1/ratelimits/overrides[?did=]
+//	PUT    /admin/v1/ratelimits/overrides          body: overrideRequest
+//	DELETE /admin/v1/ratelimits/overrides?did=&policy_id=
+func OverridesHandler(l *Limiter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			overrides, err := l.Overrides(r.Context(), r.URL.Query().Get("did"))
+			if err != nil {
+				httpx.WriteJSON(w, http.StatusInternalServerError, httpx.ErrorResponse{Error: "failed to load overrides"})
+				return
+			}
+			httpx.WriteJSON(w, http.StatusOK, overrides)
+
+		case http.MethodPut:
+			var req overrideRequest
+			if err := httpx.DecodeJSON(r, &req); err != nil {
+				httpx.WriteJSON(w, http.StatusBadRequest, httpx.ErrorResponse{Error: "invalid request body"})
+				return
+			}
+			ov, err := l.SetOverride(r.Context(), Override{
+				DID:         req.DID,
+				PolicyID:    req.PolicyID,
+				Action:      req.Action,
+				MaxRequests: req.MaxRequests,
+				Reason:      req.Reason,
+			}, time.Duration(req.TTLSeconds)*time.Second)
+			switch {
+			case errors.Is(err, ErrInvalidOverride):
+				httpx.WriteJSON(w, http.StatusBadRequest, httpx.ErrorResponse{Error: err.Error()})
+			case err != nil:
+				httpx.WriteJSON(w, http.StatusInternalServerError, httpx.ErrorResponse{Error: "failed to store override"})
+			default:
+				httpx.WriteJSON(w, http.StatusOK, ov)
+			}
+
+		case http.MethodDelete:
+			did := r.URL.Query().Get("did")
+			if did == "" {
+				httpx.WriteJSON(w, http.StatusBadRequest, httpx.ErrorResponse{Error: "did is required"})
+				return
+			}
+			if err := l.DeleteOverride(r.Context(), did, r.URL.Query().Get("policy_id")); err != nil {
+				httpx.WriteJSON(w, http.StatusInternalServerError, httpx.ErrorResponse{Error: "failed to delete override"})
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+
+		default:
+			w.Header().Set("Allow", "GET, PUT, DELETE")
+			httpx.WriteJSON(w, http.StatusMethodNotAllowed, httpx.ErrorResponse{Error: "method not allowed"})
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/example/privacy-gateway/internal/shared/models"
+	"github.com/example/privacy-gateway/internal/shared/tenant"
+)
+
+// Result is the outcome of a rate limit check
+type Result struct {
+	Allowed   bool      `json:"allowed"`
+	Limit     int       `json:"limit"`
+	Remaining int       `json:"remaining"`
+	ResetAt   time.Time `json:"reset_at"`
+	Override  string    `json:"override,omitempty"` // Action of the applied override, if any
+}
+
+// Counter is the current window usage for one DID and policy
+type Counter struct {
+	DID      string    `json:"did"`
+	PolicyID string    `json:"policy_id"`
+	Count    int64     `json:"count"`
+	ResetAt  time.Time `json:"reset_at"`
+}
+
+// Limiter is a fixed-window limiter keyed by DID and policy. Counters and
+// overrides live in Redis so every replica enforces the same state.
+type Limiter struct {
+	client  *redis.Client
+	metrics *tenant.Metrics
+}
+
+// NewLimiter creates a limiter
+func NewLimiter(client *redis.Client) *Limiter {
+	return &Limiter{client: client}
+}
+
+// SetMetrics counts rejected requests in the per-tenant metrics, labelled
+// with the tenant on the request context
+func (l *Limiter) SetMetrics(m *tenant.Metrics) {
+	l.metrics = m
+}
+
+// counterKey uses | as separator since DIDs contain colons but never pipes
+func counterKey(did, policyID string, windowStart int64) string {
+	return fmt.Sprintf("rl:c|%s|%s|%d", did, policyID, windowStart)
+}
+
+// Allow counts a request against the policy's limit, applying any active
+// override for the DID
+func (l *Limiter) Allow(ctx context.Context, did, policyID string, rl models.RateLimit) (Result, error) {
+	ov, err := l.activeOverride(ctx, did, policyID)
+	if err != nil {
+		return Result{}, err
+	}
+
+	limit := rl.MaxRequests
+	window := time.Duration(rl.WindowSeconds) * time.Second
+	if window <= 0 {
+		window = time.Minute
+	}
+	now := time.Now()
+	windowStart := now.Truncate(window)
+	res := Result{Limit: limit, ResetAt: windowStart.Add(window)}
+
+	if ov != nil {
+		res.Override = ov.Action
+		switch ov.Action {
+		case ActionBlock:
+			res.Limit = 0
+			if l.metrics != nil {
+				l.metrics.RateLimited(tenant.FromContext(ctx), policyID)
+			}
+			return res, nil
+		case ActionBoost:
+			limit = ov.MaxRequests
+			res.Limit = limit
+		}
+	}
+
+	key := counterKey(did, policyID, windowStart.Unix())
+	pipe := l.client.TxPipeline()
+	incr := pipe.Incr(ctx, key)
+	pipe.ExpireAt(ctx, key, res.ResetAt.Add(time.Second))
+	if _, err := pipe.Exec(ctx); err != nil {
+		return Result{}, err
+	}
+	count := int(incr.Val())
+	res.Allowed = count <= limit
+	if !res.Allowed && l.metrics != nil {
+		l.metrics.RateLimited(tenant.FromContext(ctx), policyID)
+	}
+	if res.Remaining = limit - count; res.Remaining < 0 {
+		res.Remaining = 0
+	}
+	return res, nil
+}
+
+// Counters returns the live counters for a DID across policies
+func (l *Limiter) Counters(ctx context.Context, did string) ([]Counter, error) {
+	var out []Counter
+	iter := l.client.Scan(ctx, 0, "rl:c|"+did+"|*", 100).Iterator()
+	for iter.Next(ctx) {
+		key := iter.Val()
+		parts := strings.Split(key, "|")
+		if len(parts) != 4 {
+			continue
+		}
+		count, err := l.client.Get(ctx, key).Int64()
+		if err == redis.Nil {
+			continue // Window rolled over mid-scan
+		}
+		if err != nil {
+			return nil, err
+		}
+		ttl, err := l.client.PTTL(ctx, key).Result()
+		if err != nil {
+			return nil, err
+		}
+		c := Counter{DID: parts[1], PolicyID: parts[2], Count: count}
+		if ttl > 0 {
+			c.ResetAt = time.Now().Add(ttl - time.Second).Truncate(time.Second)
+		}
+		out = append(out, c)
+	}
+	return out, iter.Err()
+}
+
+// Reset clears a DID's counters, optionally for one policy only
+func (l *Limiter) Reset(ctx context.Context, did, policyID string) error {
+	pattern := "rl:c|" + did + "|*"
+	if policyID != "" {
+		pattern = "rl:c|" + did + "|" + policyID + "|*"
+	}
+	iter := l.client.Scan(ctx, 0, pattern, 100).Iterator()
+	for iter.Next(ctx) {
+		if err := l.client.Del(ctx, iter.Val()).Err(); err != nil {
+			return err
+		}
+	}
+	return iter.Err()
+}
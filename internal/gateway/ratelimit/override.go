@@ -0,0 +1,133 @@
+package ratelimit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var ErrInvalidOverride = errors.New("invalid rate limit override")
+
+// Override actions
+const (
+	ActionBoost = "boost" // Replace the policy limit with MaxRequests
+	ActionBlock = "block" // Reject every request
+)
+
+// Override bounds
+const (
+	DefaultOverrideTTL = time.Hour
+	MaxOverrideTTL     = 7 * 24 * time.Hour
+)
+
+// allPolicies is the policy slot for overrides that apply to every policy
+const allPolicies = "*"
+
+// Override temporarily changes the limit for a DID. Overrides are Redis keys
+// with a TTL, so they expire on their own on every replica.
+type Override struct {
+	DID         string    `json:"did"`
+	PolicyID    string    `json:"policy_id,omitempty"` // Empty applies to all policies
+	Action      string    `json:"action"`
+	MaxRequests int       `json:"max_requests,omitempty"` // Boost only
+	Reason      string    `json:"reason,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+func overrideKey(did, policyID string) string {
+	if policyID == "" {
+		policyID = allPolicies
+	}
+	return "rl:o|" + did + "|" + policyID
+}
+
+// SetOverride validates and stores an override for ttl
+func (l *Limiter) SetOverride(ctx context.Context, ov Override, ttl time.Duration) (*Override, error) {
+	if ov.DID == "" {
+		return nil, fmt.Errorf("%w: did is required", ErrInvalidOverride)
+	}
+	switch ov.Action {
+	case ActionBlock:
+		ov.MaxRequests = 0
+	case ActionBoost:
+		if ov.MaxRequests <= 0 {
+			return nil, fmt.Errorf("%w: boost needs max_requests > 0", ErrInvalidOverride)
+		}
+	default:
+		return nil, fmt.Errorf("%w: unknown action %q", ErrInvalidOverride, ov.Action)
+	}
+	if ttl == 0 {
+		ttl = DefaultOverrideTTL
+	}
+	if ttl < 0 || ttl > MaxOverrideTTL {
+		return nil, fmt.Errorf("%w: ttl must be at most %s", ErrInvalidOverride, MaxOverrideTTL)
+	}
+
+	now := time.Now().UTC()
+	ov.CreatedAt = now
+	ov.ExpiresAt = now.Add(ttl)
+	data, err := json.Marshal(ov)
+	if err != nil {
+		return nil, err
+	}
+	if err := l.client.Set(ctx, overrideKey(ov.DID, ov.PolicyID), data, ttl).Err(); err != nil {
+		return nil, err
+	}
+	return &ov, nil
+}
+
+// DeleteOverride removes an override before it expires
+func (l *Limiter) DeleteOverride(ctx context.Context, did, policyID string) error {
+	return l.client.Del(ctx, overrideKey(did, policyID)).Err()
+}
+
+// Overrides lists active overrides, optionally for one DID
+func (l *Limiter) Overrides(ctx context.Context, did string) ([]Override, error) {
+	pattern := "rl:o|*"
+	if did != "" {
+		pattern = "rl:o|" + did + "|*"
+	}
+	var out []Override
+	iter := l.client.Scan(ctx, 0, pattern, 100).Iterator()
+	for iter.Next(ctx) {
+		data, err := l.client.Get(ctx, iter.Val()).Bytes()
+		if err == redis.Nil {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		var ov Override
+		if json.Unmarshal(data, &ov) == nil {
+			out = append(out, ov)
+		}
+	}
+	return out, iter.Err()
+}
+
+// activeOverride returns the override for a DID and policy. A policy-specific
+// override takes precedence over one covering all policies.
+func (l *Limiter) activeOverride(ctx context.Context, did, policyID string) (*Override, error) {
+	vals, err := l.client.MGet(ctx, overrideKey(did, policyID), overrideKey(did, "")).Result()
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range vals {
+		s, ok := v.(string)
+		if !ok || strings.TrimSpace(s) == "" {
+			continue
+		}
+		var ov Override
+		if err := json.Unmarshal([]byte(s), &ov); err != nil {
+			continue
+		}
+		return &ov, nil
+	}
+	return nil, nil
+}
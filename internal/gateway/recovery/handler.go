@@ -0,0 +1,229 @@
+package recovery
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/example/privacy-gateway/internal/shared/httpx"
+	"github.com/example/privacy-gateway/internal/shared/i18n"
+)
+
+// startResponse is returned to the user; the same for unknown addresses
+type startResponse struct {
+	ID           string `json:"id"`
+	PollSecret   string `json:"poll_secret"`
+	Channel      string `json:"channel"`
+	ProofMessage string `json:"proof_message"` // For the new wallet to sign
+	ExpiresAt    int64  `json:"expires_at"`
+}
+
+// verifyRequest completes the verification step
+type verifyRequest struct {
+	Code      string `json:"code,omitempty"`
+	KID       string `json:"kid"`
+	Signature string `json:"signature"`
+}
+
+// statusResponse is a request as its owner sees it
+type statusResponse struct {
+	Status     string `json:"status"`
+	Reason     string `json:"reason,omitempty"`
+	Credential string `json:"credential,omitempty"`
+	ExpiresAt  int64  `json:"expires_at"`
+}
+
+// queueEntry is a request as the admin sees it
+type queueEntry struct {
+	ID          string `json:"id"`
+	Channel     string `json:"channel"`
+	Address     string `json:"address"`
+	AccountID   string `json:"account_id"`
+	PreviousDID string `json:"previous_did"`
+	NewDID      string `json:"new_did"`
+	Note        string `json:"note,omitempty"`
+	Status      string `json:"status"`
+	CreatedAt   int64  `json:"created_at"`
+	ExpiresAt   int64  `json:"expires_at"`
+}
+
+// Handler serves the user-facing recovery endpoints:
+//
+//	POST /v1/recovery              start: channel, address, new_did
+//	POST /v1/recovery/{id}/verify  code and new-DID key proof (X-Poll-Secret header)
+//	GET  /v1/recovery/{id}         status and, once approved, the credential (X-Poll-Secret)
+//
+// AdminHandler serves the approval queue and must be mounted behind admin
+// authentication.
+type Handler struct {
+	svc *Service
+}
+
+// NewHandler creates the recovery handler
+func NewHandler(svc *Service) *Handler {
+	return &Handler{svc: svc}
+}
+
+// ServeHTTP dispatches on the path below /v1/recovery
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rest := strings.Trim(strings.TrimPrefix(r.URL.Path, "/v1/recovery"), "/")
+	parts := strings.Split(rest, "/")
+	w.Header().Set("Cache-Control", "no-store")
+
+	switch {
+	case rest == "" && r.Method == http.MethodPost:
+		h.start(w, r)
+	case len(parts) == 2 && parts[1] == "verify" && r.Method == http.MethodPost:
+		h.verify(w, r, parts[0])
+	case len(parts) == 1 && rest != "" && r.Method == http.MethodGet:
+		h.poll(w, r, parts[0])
+	default:
+		httpx.WriteJSON(w, http.StatusNotFound, httpx.ErrorResponse{Error: "not found"})
+	}
+}
+
+func (h *Handler) start(w http.ResponseWriter, r *http.Request) {
+	var in StartInput
+	if err := httpx.DecodeJSON(r, &in); err != nil {
+		httpx.WriteJSON(w, http.StatusBadRequest, httpx.ErrorResponse{Error: "invalid request body"})
+		return
+	}
+	req, secret, err := h.svc.Start(r.Context(), in)
+	switch {
+	case errors.Is(err, ErrUnknownChannel), errors.Is(err, ErrInvalidRequest):
+		httpx.WriteJSON(w, http.StatusBadRequest, httpx.ErrorResponse{Error: err.Error()})
+	case errors.Is(err, ErrRateLimited):
+		i18n.WriteError(w, r, http.StatusTooManyRequests, i18n.CodeRateLimited)
+	case err != nil:
+		httpx.WriteJSON(w, http.StatusInternalServerError, httpx.ErrorResponse{Error: "failed to start recovery"})
+	default:
+		httpx.WriteJSON(w, http.StatusCreated, startResponse{
+			ID:           req.ID,
+			PollSecret:   secret,
+			Channel:      req.Channel,
+			ProofMessage: ProofMessage(req.ID),
+			ExpiresAt:    req.ExpiresAt.Unix(),
+		})
+	}
+}
+
+func (h *Handler) verify(w http.ResponseWriter, r *http.Request, id string) {
+	var in verifyRequest
+	if err := httpx.DecodeJSON(r, &in); err != nil {
+		httpx.WriteJSON(w, http.StatusBadRequest, httpx.ErrorResponse{Error: "invalid request body"})
+		return
+	}
+	req, err := h.svc.Verify(r.Context(), id, r.Header.Get("X-Poll-Secret"), in.Code, in.KID, in.Signature)
+	switch {
+	case err == nil:
+		httpx.WriteJSON(w, http.StatusOK, toStatusResponse(req))
+	case errors.Is(err, ErrVerificationFailed) && req != nil && req.Status == StatusFailed:
+		i18n.WriteErrorMessage(w, r, http.StatusForbidden, "verification_failed", i18n.CodeRecoveryFailed)
+	default:
+		writeUserError(w, r, err)
+	}
+}
+
+func (h *Handler) poll(w http.ResponseWriter, r *http.Request, id string) {
+	req, err := h.svc.Poll(r.Context(), id, r.Header.Get("X-Poll-Secret"))
+	if err != nil {
+		writeUserError(w, r, err)
+		return
+	}
+	httpx.WriteJSON(w, http.StatusOK, toStatusResponse(req))
+}
+
+// writeUserError maps service errors to localized responses. A bad poll
+// secret reads as an unknown request.
+func writeUserError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, ErrNotFound), errors.Is(err, ErrBadSecret):
+		i18n.WriteErrorMessage(w, r, http.StatusNotFound, "recovery request not found", i18n.CodeRecoveryExpired)
+	case errors.Is(err, ErrInvalidState):
+		httpx.WriteJSON(w, http.StatusConflict, httpx.ErrorResponse{Error: err.Error()})
+	case errors.Is(err, ErrVerificationFailed):
+		i18n.WriteErrorMessage(w, r, http.StatusBadRequest, "verification_failed", i18n.CodeRecoveryInvalid)
+	case errors.Is(err, ErrInvalidProof):
+		i18n.WriteErrorMessage(w, r, http.StatusBadRequest, "invalid_proof", i18n.CodeRecoveryProofInvalid)
+	default:
+		httpx.WriteJSON(w, http.StatusInternalServerError, httpx.ErrorResponse{Error: "recovery failed"})
+	}
+}
+
+func toStatusResponse(req *Request) statusResponse {
+	return statusResponse{Status: req.Status, Reason: req.Reason, Credential: req.Credential, ExpiresAt: req.ExpiresAt.Unix()}
+}
+
+func toQueueEntry(req Request) queueEntry {
+	return queueEntry{
+		ID:          req.ID,
+		Channel:     req.Channel,
+		Address:     req.Address,
+		AccountID:   req.AccountID,
+		PreviousDID: req.PreviousDID,
+		NewDID:      req.NewDID,
+		Note:        req.Note,
+		Status:      req.Status,
+		CreatedAt:   req.CreatedAt.Unix(),
+		ExpiresAt:   req.ExpiresAt.Unix(),
+	}
+}
+
+// AdminHandler serves the approval queue (admin). actor identifies the
+// caller in the audit trail.
+//
+//	GET  /admin/v1/recovery               verified requests awaiting a decision
+//	POST /admin/v1/recovery/{id}/approve  rebind the account and issue the credential
+//	POST /admin/v1/recovery/{id}/reject   {"reason": "..."}
+func AdminHandler(svc *Service, actor func(*http.Request) string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rest := strings.Trim(strings.TrimPrefix(r.URL.Path, "/admin/v1/recovery"), "/")
+		parts := strings.Split(rest, "/")
+		who := "admin"
+		if actor != nil {
+			who = actor(r)
+		}
+
+		switch {
+		case rest == "" && r.Method == http.MethodGet:
+			reqs, err := svc.Pending(r.Context())
+			if err != nil {
+				httpx.WriteJSON(w, http.StatusInternalServerError, httpx.ErrorResponse{Error: "failed to list recovery requests"})
+				return
+			}
+			out := make([]queueEntry, 0, len(reqs))
+			for _, req := range reqs {
+				out = append(out, toQueueEntry(req))
+			}
+			httpx.WriteJSON(w, http.StatusOK, map[string]interface{}{"requests": out})
+		case len(parts) == 2 && parts[1] == "approve" && r.Method == http.MethodPost:
+			req, err := svc.Approve(r.Context(), parts[0], who)
+			writeAdminResult(w, req, err)
+		case len(parts) == 2 && parts[1] == "reject" && r.Method == http.MethodPost:
+			var body struct {
+				Reason string `json:"reason"`
+			}
+			if err := httpx.DecodeJSON(r, &body); err != nil {
+				httpx.WriteJSON(w, http.StatusBadRequest, httpx.ErrorResponse{Error: "invalid request body"})
+				return
+			}
+			req, err := svc.Reject(r.Context(), parts[0], who, body.Reason)
+			writeAdminResult(w, req, err)
+		default:
+			httpx.WriteJSON(w, http.StatusNotFound, httpx.ErrorResponse{Error: "not found"})
+		}
+	}
+}
+
+func writeAdminResult(w http.ResponseWriter, req *Request, err error) {
+	switch {
+	case errors.Is(err, ErrNotFound):
+		httpx.WriteJSON(w, http.StatusNotFound, httpx.ErrorResponse{Error: err.Error()})
+	case errors.Is(err, ErrInvalidState):
+		httpx.WriteJSON(w, http.StatusConflict, httpx.ErrorResponse{Error: err.Error()})
+	case err != nil:
+		httpx.WriteJSON(w, http.StatusInternalServerError, httpx.ErrorResponse{Error: err.Error()})
+	default:
+		httpx.WriteJSON(w, http.StatusOK, toQueueEntry(*req))
+	}
+}
@@ -0,0 +1,591 @@
+package recovery
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+
+	"github.com/example/privacy-gateway/internal/gateway/did"
+	"github.com/example/privacy-gateway/internal/gateway/store"
+	"github.com/example/privacy-gateway/internal/shared/audit"
+	"github.com/example/privacy-gateway/internal/shared/clock"
+	"github.com/example/privacy-gateway/internal/shared/crypto"
+	"github.com/example/privacy-gateway/internal/shared/models"
+	"github.com/example/privacy-gateway/internal/shared/observability"
+)
+
+var (
+	ErrUnknownChannel     = errors.New("unknown recovery channel")
+	ErrInvalidRequest     = errors.New("invalid recovery request")
+	ErrNotFound           = errors.New("recovery request not found or expired")
+	ErrBadSecret          = errors.New("invalid poll secret")
+	ErrInvalidState       = errors.New("recovery request is not in a state that allows this")
+	ErrVerificationFailed = errors.New("verification failed")
+	ErrInvalidProof       = errors.New("invalid proof of control of the new DID")
+	ErrRateLimited        = errors.New("too many recovery requests")
+	ErrInvalidConfig      = errors.New("invalid recovery config")
+)
+
+// Channels of the bundled verifiers
+const (
+	ChannelEmail  = "email"
+	ChannelSMS    = "sms"
+	ChannelManual = "manual"
+)
+
+// Request status values
+const (
+	StatusVerifying       = "verifying"        // Waiting for the out-of-band response and key proof
+	StatusPendingApproval = "pending_approval" // Verified; in the admin queue
+	StatusApproving       = "approving"        // Claimed by an approval in progress
+	StatusApproved        = "approved"         // Account rebound; credential issued
+	StatusRejected        = "rejected"
+	StatusFailed          = "failed" // Too many wrong responses
+)
+
+// CredentialType is the VC type of the key rotation credential
+const CredentialType = "KeyRotationCredential"
+
+// AccountStore looks up accounts and rebinds their DID
+type AccountStore interface {
+	FindAccountByContact(ctx context.Context, channel, address string) (models.Account, error)
+	GetAccount(ctx context.Context, id string) (models.Account, error)
+	RebindAccountDID(ctx context.Context, id, oldDID, newDID string) error
+}
+
+// Config configures the recovery service
+type Config struct {
+	DID        string // Gateway DID that signs key rotation credentials
+	KeyID      string // Verification method ID (default DID + "#key-1")
+	SigningKey ed25519.PrivateKey
+	Accounts   AccountStore
+	// Verifiers by channel; ManualVerifier{} enables admin-only verification
+	Verifiers []Verifier
+	Resolver  did.Resolver // Resolves the new DID for its key proof (default did:key only)
+	// RequestTTL bounds the verification step and how long a decided request
+	// can still be polled (default 15m)
+	RequestTTL time.Duration
+	// ApprovalTTL is how long a verified request waits for an admin (default 72h)
+	ApprovalTTL time.Duration
+	MaxAttempts int // Wrong responses before a request fails (default 5)
+	// StartLimit requests per address and StartWindow (default 3 per hour)
+	StartLimit  int
+	StartWindow time.Duration
+	// CredentialValidity of the key rotation credential (default 30 days)
+	CredentialValidity time.Duration
+	Audit              audit.Sink // Optional; events are always logged
+	Clock              clock.Clock
+	Logger             *slog.Logger
+}
+
+// Request is one recovery attempt. The address is kept masked for the
+// admin queue; the poll secret and the verifier state only hashed.
+type Request struct {
+	ID          string    `json:"id"`
+	SecretHash  string    `json:"secret_hash"`
+	Channel     string    `json:"channel"`
+	Address     string    `json:"address"` // Masked
+	AccountID   string    `json:"account_id,omitempty"`
+	PreviousDID string    `json:"previous_did,omitempty"`
+	NewDID      string    `json:"new_did"`
+	Note        string    `json:"note,omitempty"` // From the user, for manual review
+	Status      string    `json:"status"`
+	State       string    `json:"state,omitempty"`
+	Attempts    int       `json:"attempts"`
+	Actor       string    `json:"actor,omitempty"`  // Admin who decided
+	Reason      string    `json:"reason,omitempty"` // Rejection reason
+	Credential  string    `json:"credential,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+// Service runs recovery requests. Requests live in Redis, so any replica
+// can serve any step; only the approved rebind touches the account store.
+type Service struct {
+	client    *redis.Client
+	cfg       Config
+	audit     *audit.Recorder
+	verifiers map[string]Verifier
+}
+
+// NewService creates the recovery service
+func NewService(client *redis.Client, cfg Config) (*Service, error) {
+	if cfg.DID == "" || len(cfg.SigningKey) != ed25519.PrivateKeySize || cfg.Accounts == nil {
+		return nil, fmt.Errorf("%w: DID, signing key and account store are required", ErrInvalidConfig)
+	}
+	if len(cfg.Verifiers) == 0 {
+		return nil, fmt.Errorf("%w: at least one verifier is required", ErrInvalidConfig)
+	}
+	if cfg.KeyID == "" {
+		cfg.KeyID = cfg.DID + "#key-1"
+	}
+	if cfg.Resolver == nil {
+		cfg.Resolver = did.KeyResolver{}
+	}
+	if cfg.RequestTTL == 0 {
+		cfg.RequestTTL = 15 * time.Minute
+	}
+	if cfg.ApprovalTTL == 0 {
+		cfg.ApprovalTTL = 72 * time.Hour
+	}
+	if cfg.MaxAttempts == 0 {
+		cfg.MaxAttempts = 5
+	}
+	if cfg.StartLimit == 0 {
+		cfg.StartLimit = 3
+	}
+	if cfg.StartWindow == 0 {
+		cfg.StartWindow = time.Hour
+	}
+	if cfg.CredentialValidity == 0 {
+		cfg.CredentialValidity = 30 * 24 * time.Hour
+	}
+	cfg.Clock = clock.Or(cfg.Clock)
+	cfg.Logger = observability.Component(cfg.Logger, observability.ComponentRecovery)
+
+	verifiers := make(map[string]Verifier, len(cfg.Verifiers))
+	for _, v := range cfg.Verifiers {
+		verifiers[v.Name()] = v
+	}
+	return &Service{client: client, cfg: cfg, audit: audit.NewRecorder(cfg.Audit, cfg.Logger, cfg.Clock), verifiers: verifiers}, nil
+}
+
+// Channels returns the configured channel names
+func (s *Service) Channels() []string {
+	names := make([]string, 0, len(s.verifiers))
+	for _, v := range s.cfg.Verifiers {
+		names = append(names, v.Name())
+	}
+	return names
+}
+
+func requestKey(id string) string {
+	return "rcv:req|" + id
+}
+
+const pendingKey = "rcv:pending"
+
+func startLimitKey(address string) string {
+	return "rcv:rl|" + crypto.HashSecret(strings.ToLower(strings.TrimSpace(address)))
+}
+
+// StartInput is a user's recovery request. Address is the email address or
+// phone number on file; for the manual channel either one.
+type StartInput struct {
+	Channel string `json:"channel"`
+	Address string `json:"address"`
+	NewDID  string `json:"new_did"`
+	Note    string `json:"note,omitempty"`
+}
+
+// Start opens a recovery request and returns it with the plaintext poll
+// secret. It answers the same whether or not the address belongs to an
+// account, so it can't be used to probe for accounts; a request for an
+// unknown address just never verifies.
+func (s *Service) Start(ctx context.Context, in StartInput) (*Request, string, error) {
+	v, ok := s.verifiers[in.Channel]
+	if !ok {
+		return nil, "", fmt.Errorf("%w: %s", ErrUnknownChannel, in.Channel)
+	}
+	address := strings.TrimSpace(in.Address)
+	if address == "" || !strings.HasPrefix(in.NewDID, "did:") || len(in.Note) > 1000 {
+		return nil, "", fmt.Errorf("%w: address and new_did are required", ErrInvalidRequest)
+	}
+	if err := s.limitStart(ctx, address); err != nil {
+		return nil, "", err
+	}
+
+	account, err := s.findAccount(ctx, in.Channel, address)
+	if err != nil {
+		return nil, "", err
+	}
+	id, err := crypto.RandomToken(18)
+	if err != nil {
+		return nil, "", err
+	}
+	secret, err := crypto.RandomToken(32)
+	if err != nil {
+		return nil, "", err
+	}
+	now := s.cfg.Clock.Now().UTC()
+	req := &Request{
+		ID:          id,
+		SecretHash:  crypto.HashSecret(secret),
+		Channel:     in.Channel,
+		Address:     maskAddress(address),
+		AccountID:   account.ID,
+		PreviousDID: account.DID,
+		NewDID:      in.NewDID,
+		Note:        in.Note,
+		Status:      StatusVerifying,
+		CreatedAt:   now,
+		ExpiresAt:   now.Add(s.cfg.RequestTTL),
+	}
+	if account.ID != "" {
+		if req.State, err = v.Start(ctx, id, address); err != nil {
+			return nil, "", fmt.Errorf("start %s verification: %w", in.Channel, err)
+		}
+	}
+	if err := s.save(ctx, req); err != nil {
+		return nil, "", err
+	}
+	outcome := "success"
+	if account.ID == "" {
+		outcome = "unknown_account"
+	}
+	s.audit.Record(ctx, "recovery.started", account.DID, "", outcome, map[string]interface{}{"request": id, "channel": in.Channel, "new_did": in.NewDID})
+	return req, secret, nil
+}
+
+// findAccount returns the account for address, or a zero account when there
+// is none. The manual channel accepts either contact.
+func (s *Service) findAccount(ctx context.Context, channel, address string) (models.Account, error) {
+	if channel == ChannelManual {
+		channel = ChannelSMS
+		if strings.Contains(address, "@") {
+			channel = ChannelEmail
+		}
+	}
+	account, err := s.cfg.Accounts.FindAccountByContact(ctx, channel, address)
+	if errors.Is(err, store.ErrNotFound) {
+		return models.Account{}, nil
+	}
+	return account, err
+}
+
+// limitStart counts a start against the address's window
+func (s *Service) limitStart(ctx context.Context, address string) error {
+	key := startLimitKey(address)
+	pipe := s.client.TxPipeline()
+	incr := pipe.Incr(ctx, key)
+	pipe.ExpireNX(ctx, key, s.cfg.StartWindow)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return err
+	}
+	if incr.Val() > int64(s.cfg.StartLimit) {
+		return ErrRateLimited
+	}
+	return nil
+}
+
+// Verify completes the verification step: response is what the channel
+// delivered (the code; empty for manual) and signature a base64url Ed25519
+// signature over ProofMessage(id) by the authentication key kid of the new
+// DID. A verified request joins the approval queue.
+func (s *Service) Verify(ctx context.Context, id, secret, response, kid, signature string) (*Request, error) {
+	req, err := s.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if subtle.ConstantTimeCompare([]byte(req.SecretHash), []byte(crypto.HashSecret(secret))) != 1 {
+		return nil, ErrBadSecret
+	}
+	proofErr := s.verifyProof(ctx, req, kid, signature)
+
+	var out Request
+	var codeErr error
+	err = s.update(ctx, id, func(r *Request) error {
+		if r.Status != StatusVerifying {
+			return ErrInvalidState
+		}
+		r.Attempts++
+		codeErr = ErrVerificationFailed
+		if r.AccountID != "" {
+			codeErr = s.verifiers[r.Channel].Check(ctx, r.ID, r.State, response)
+		}
+		switch {
+		case codeErr == nil && proofErr == nil:
+			r.Status, r.State = StatusPendingApproval, ""
+			r.ExpiresAt = s.cfg.Clock.Now().UTC().Add(s.cfg.ApprovalTTL)
+		case r.Attempts >= s.cfg.MaxAttempts:
+			// A bad key proof counts as well, so the code can't be tried
+			// more often than MaxAttempts
+			r.Status, r.State = StatusFailed, ""
+		}
+		out = *r
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case out.Status == StatusPendingApproval:
+		if err := s.client.ZAdd(ctx, pendingKey, redis.Z{Score: float64(out.CreatedAt.Unix()), Member: id}).Err(); err != nil {
+			return nil, err
+		}
+		s.audit.Record(ctx, "recovery.verified", out.PreviousDID, "", "success", map[string]interface{}{"request": id, "channel": out.Channel, "new_did": out.NewDID})
+		return &out, nil
+	case out.Status == StatusFailed:
+		s.audit.Record(ctx, "recovery.failed", out.PreviousDID, "", "denied", map[string]interface{}{"request": id, "channel": out.Channel, "attempts": out.Attempts})
+		return &out, ErrVerificationFailed
+	case codeErr != nil:
+		// Also the answer for unknown accounts, which never verify
+		return &out, ErrVerificationFailed
+	default:
+		return &out, proofErr
+	}
+}
+
+// ProofMessage is what the new DID's key signs to prove control
+func ProofMessage(id string) string {
+	return "recovery:" + id
+}
+
+// verifyProof checks the new DID's signature over ProofMessage
+func (s *Service) verifyProof(ctx context.Context, req *Request, kid, signature string) error {
+	holder, _, ok := strings.Cut(kid, "#")
+	if !ok || holder != req.NewDID {
+		return fmt.Errorf("%w: kid must be a verification method of %s", ErrInvalidProof, req.NewDID)
+	}
+	doc, err := s.cfg.Resolver.Resolve(ctx, holder, did.ResolveOptions{})
+	if err != nil {
+		return fmt.Errorf("%w: resolve %s: %v", ErrInvalidProof, holder, err)
+	}
+	pub, err := doc.AuthenticationKey(kid)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidProof, err)
+	}
+	if err := crypto.VerifySignature(pub, ProofMessage(req.ID), signature); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidProof, err)
+	}
+	return nil
+}
+
+// Poll returns a request to its owner, with the credential once approved
+func (s *Service) Poll(ctx context.Context, id, secret string) (*Request, error) {
+	req, err := s.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if subtle.ConstantTimeCompare([]byte(req.SecretHash), []byte(crypto.HashSecret(secret))) != 1 {
+		return nil, ErrBadSecret
+	}
+	return req, nil
+}
+
+// Get loads a request by ID
+func (s *Service) Get(ctx context.Context, id string) (*Request, error) {
+	data, err := s.client.Get(ctx, requestKey(id)).Bytes()
+	if err == redis.Nil {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	var req Request
+	if err := json.Unmarshal(data, &req); err != nil {
+		return nil, err
+	}
+	return &req, nil
+}
+
+// Pending returns the approval queue, oldest first. Requests that expired
+// while waiting are dropped from the queue.
+func (s *Service) Pending(ctx context.Context) ([]Request, error) {
+	ids, err := s.client.ZRange(ctx, pendingKey, 0, -1).Result()
+	if err != nil || len(ids) == 0 {
+		return nil, err
+	}
+	keys := make([]string, len(ids))
+	for i, id := range ids {
+		keys[i] = requestKey(id)
+	}
+	values, err := s.client.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+	var out []Request
+	var gone []interface{}
+	for i, v := range values {
+		raw, ok := v.(string)
+		var req Request
+		if !ok || json.Unmarshal([]byte(raw), &req) != nil || req.Status != StatusPendingApproval {
+			gone = append(gone, ids[i])
+			continue
+		}
+		out = append(out, req)
+	}
+	if len(gone) > 0 {
+		s.client.ZRem(ctx, pendingKey, gone...)
+	}
+	return out, nil
+}
+
+// Approve rebinds the account to the new DID and issues the key rotation
+// credential, which the user collects by polling. actor identifies the admin.
+func (s *Service) Approve(ctx context.Context, id, actor string) (*Request, error) {
+	// Claim the request first so two admins can't both rebind
+	var req Request
+	err := s.update(ctx, id, func(r *Request) error {
+		if r.Status != StatusPendingApproval {
+			return ErrInvalidState
+		}
+		r.Status, r.Actor = StatusApproving, actor
+		req = *r
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	release := func(cause error) (*Request, error) {
+		_ = s.update(context.WithoutCancel(ctx), id, func(r *Request) error {
+			r.Status, r.Actor = StatusPendingApproval, ""
+			return nil
+		})
+		return nil, cause
+	}
+
+	account, err := s.cfg.Accounts.GetAccount(ctx, req.AccountID)
+	if err != nil {
+		return release(fmt.Errorf("load account: %w", err))
+	}
+	if account.DID != req.PreviousDID {
+		// Rebound since the request was opened; the user must start again
+		_ = s.update(context.WithoutCancel(ctx), id, func(r *Request) error {
+			r.Status, r.Reason = StatusRejected, "account DID changed"
+			return nil
+		})
+		s.client.ZRem(ctx, pendingKey, id)
+		return nil, fmt.Errorf("%w: account DID changed since the request was opened", ErrInvalidState)
+	}
+	jti := uuid.NewString()
+	cred, err := s.sign(req, jti)
+	if err != nil {
+		return release(err)
+	}
+	if err := s.cfg.Accounts.RebindAccountDID(ctx, account.ID, req.PreviousDID, req.NewDID); err != nil {
+		return release(fmt.Errorf("rebind account: %w", err))
+	}
+
+	var out Request
+	err = s.update(context.WithoutCancel(ctx), id, func(r *Request) error {
+		r.Status, r.Credential = StatusApproved, cred
+		r.ExpiresAt = s.cfg.Clock.Now().UTC().Add(s.cfg.RequestTTL)
+		out = *r
+		return nil
+	})
+	s.client.ZRem(ctx, pendingKey, id)
+	s.audit.Record(ctx, "recovery.approved", req.PreviousDID, actor, "success", map[string]interface{}{"request": id, "account": account.ID, "new_did": req.NewDID, "jti": jti})
+	if err != nil {
+		// The account is rebound; only the credential pickup is lost
+		return nil, fmt.Errorf("account rebound but request not updated: %w", err)
+	}
+	return &out, nil
+}
+
+// Reject closes a pending request with a reason shown to the user
+func (s *Service) Reject(ctx context.Context, id, actor, reason string) (*Request, error) {
+	var out Request
+	err := s.update(ctx, id, func(r *Request) error {
+		if r.Status != StatusPendingApproval {
+			return ErrInvalidState
+		}
+		r.Status, r.Actor, r.Reason = StatusRejected, actor, reason
+		r.ExpiresAt = s.cfg.Clock.Now().UTC().Add(s.cfg.RequestTTL)
+		out = *r
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.client.ZRem(ctx, pendingKey, id)
+	s.audit.Record(ctx, "recovery.rejected", out.PreviousDID, actor, "denied", map[string]interface{}{"request": id, "reason": reason})
+	return &out, nil
+}
+
+// sign builds and signs the key rotation JWT-VC for the new DID
+func (s *Service) sign(req Request, jti string) (string, error) {
+	now := s.cfg.Clock.Now()
+	claims := models.CredentialClaims{
+		Issuer:   s.cfg.DID,
+		Subject:  req.NewDID,
+		IssuedAt: now.Unix(),
+		Expiry:   now.Add(s.cfg.CredentialValidity).Unix(),
+		JWTID:    jti,
+		VC: map[string]interface{}{
+			"@context": []string{"https://www.w3.org/2018/credentials/v1"},
+			"type":     []string{"VerifiableCredential", CredentialType},
+			"credentialSubject": map[string]interface{}{
+				"id":             req.NewDID,
+				"previousDid":    req.PreviousDID,
+				"recoveryMethod": req.Channel,
+			},
+		},
+	}
+	header, err := json.Marshal(map[string]string{"alg": "EdDSA", "typ": "JWT", "kid": s.cfg.KeyID})
+	if err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	input := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	sig := ed25519.Sign(s.cfg.SigningKey, []byte(input))
+	return input + "." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
+
+// update applies fn to a request under optimistic locking (WATCH/MULTI)
+func (s *Service) update(ctx context.Context, id string, fn func(*Request) error) error {
+	key := requestKey(id)
+	return s.client.Watch(ctx, func(tx *redis.Tx) error {
+		data, err := tx.Get(ctx, key).Bytes()
+		if err == redis.Nil {
+			return ErrNotFound
+		}
+		if err != nil {
+			return err
+		}
+		var req Request
+		if err := json.Unmarshal(data, &req); err != nil {
+			return err
+		}
+		if err := fn(&req); err != nil {
+			return err
+		}
+		ttl := req.ExpiresAt.Sub(s.cfg.Clock.Now())
+		if ttl <= 0 {
+			return ErrNotFound
+		}
+		encoded, err := json.Marshal(req)
+		if err != nil {
+			return err
+		}
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.Set(ctx, key, encoded, ttl)
+			return nil
+		})
+		return err
+	}, key)
+}
+
+// save writes a new request until its expiry
+func (s *Service) save(ctx context.Context, req *Request) error {
+	data, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	return s.client.Set(ctx, requestKey(req.ID), data, req.ExpiresAt.Sub(s.cfg.Clock.Now())).Err()
+}
+
+// maskAddress keeps enough of an address for an admin to recognize it:
+// j***@example.com, ***1234
+func maskAddress(address string) string {
+	if local, domain, ok := strings.Cut(address, "@"); ok && local != "" {
+		return local[:1] + "***@" + domain
+	}
+	if len(address) > 4 {
+		return "***" + address[len(address)-4:]
+	}
+	return "***"
+}
@@ -0,0 +1,115 @@
+package revocation
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/example/privacy-gateway/internal/gateway/store"
+	"github.com/example/privacy-gateway/internal/shared/httpx"
+)
+
+type deltaRequest struct {
+	Added   []string `json:"added"`
+	Removed []string `json:"removed"`
+}
+
+// Handler serves the admin delta endpoints next to PUT /admin/v1/revocations/{listId}:
+//
+//	PATCH /admin/v1/revocations/{listId}                          {"added", "removed"}
+//	GET   /admin/v1/revocations/{listId}/changes?since=N&limit=M
+//
+// actor identifies the caller in the audit trail.
+func Handler(s *Sync, actor func(*http.Request) string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rest := strings.Trim(strings.TrimPrefix(r.URL.Path, "/admin/v1/revocations"), "/")
+		listID, action, _ := strings.Cut(rest, "/")
+		if listID == "" {
+			httpx.WriteJSON(w, http.StatusNotFound, httpx.ErrorResponse{Error: "not found"})
+			return
+		}
+
+		switch {
+		case action == "" && r.Method == http.MethodPatch:
+			var req deltaRequest
+			if err := httpx.DecodeJSON(r, &req); err != nil {
+				httpx.WriteJSON(w, http.StatusBadRequest, httpx.ErrorResponse{Error: "invalid request body"})
+				return
+			}
+			who := "admin"
+			if actor != nil {
+				who = actor(r)
+			}
+			delta, err := s.Apply(r.Context(), listID, req.Added, req.Removed, who)
+			writeDelta(w, delta, err)
+		case action == "changes" && r.Method == http.MethodGet:
+			q := r.URL.Query()
+			since, err := strconv.ParseInt(q.Get("since"), 10, 64)
+			if err != nil || since < 0 {
+				httpx.WriteJSON(w, http.StatusBadRequest, httpx.ErrorResponse{Error: "since must be a non-negative seq"})
+				return
+			}
+			limit, _ := strconv.Atoi(q.Get("limit"))
+			changes, err := s.Changes(r.Context(), listID, since, limit)
+			if errors.Is(err, store.ErrNotFound) {
+				httpx.WriteJSON(w, http.StatusNotFound, httpx.ErrorResponse{Error: "revocation list not found"})
+				return
+			}
+			if err != nil {
+				httpx.WriteJSON(w, http.StatusInternalServerError, httpx.ErrorResponse{Error: "failed to read revocation changes"})
+				return
+			}
+			httpx.WriteJSON(w, http.StatusOK, changes)
+		default:
+			httpx.WriteJSON(w, http.StatusNotFound, httpx.ErrorResponse{Error: "not found"})
+		}
+	}
+}
+
+// PushHandler serves POST /revocations/{listId}/push: an issuer-signed delta
+// (see Sync.Push) as the request body. It authenticates by signature and
+// sits outside the admin API.
+func PushHandler(s *Sync) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			httpx.WriteJSON(w, http.StatusMethodNotAllowed, httpx.ErrorResponse{Error: "method not allowed"})
+			return
+		}
+		listID, ok := strings.CutSuffix(strings.TrimPrefix(r.URL.Path, "/revocations/"), "/push")
+		if !ok || listID == "" || strings.Contains(listID, "/") {
+			httpx.WriteJSON(w, http.StatusNotFound, httpx.ErrorResponse{Error: "not found"})
+			return
+		}
+		body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+		if err != nil {
+			httpx.WriteJSON(w, http.StatusBadRequest, httpx.ErrorResponse{Error: "invalid request body"})
+			return
+		}
+		delta, err := s.Push(r.Context(), listID, strings.TrimSpace(string(body)))
+		switch {
+		case errors.Is(err, ErrNotOwner):
+			httpx.WriteJSON(w, http.StatusForbidden, httpx.ErrorResponse{Error: err.Error()})
+		case errors.Is(err, ErrInvalidPush):
+			httpx.WriteJSON(w, http.StatusUnauthorized, httpx.ErrorResponse{Error: err.Error()})
+		case errors.Is(err, ErrPushReplayed):
+			httpx.WriteJSON(w, http.StatusConflict, httpx.ErrorResponse{Error: err.Error()})
+		default:
+			writeDelta(w, delta, err)
+		}
+	}
+}
+
+func writeDelta(w http.ResponseWriter, delta any, err error) {
+	switch {
+	case errors.Is(err, ErrInvalidDelta), errors.Is(err, ErrDeltaTooLarge):
+		httpx.WriteJSON(w, http.StatusBadRequest, httpx.ErrorResponse{Error: err.Error()})
+	case errors.Is(err, store.ErrNotFound):
+		httpx.WriteJSON(w, http.StatusNotFound, httpx.ErrorResponse{Error: "revocation list not found"})
+	case err != nil:
+		httpx.WriteJSON(w, http.StatusInternalServerError, httpx.ErrorResponse{Error: "failed to apply revocation delta"})
+	default:
+		httpx.WriteJSON(w, http.StatusOK, delta)
+	}
+}
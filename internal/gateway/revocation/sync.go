@@ -0,0 +1,227 @@
+package revocation
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/example/privacy-gateway/internal/shared/audit"
+	"github.com/example/privacy-gateway/internal/shared/bus"
+	"github.com/example/privacy-gateway/internal/shared/crypto"
+	"github.com/example/privacy-gateway/internal/shared/jws"
+	"github.com/example/privacy-gateway/internal/shared/models"
+	"github.com/example/privacy-gateway/internal/shared/observability"
+	"github.com/example/privacy-gateway/internal/shared/tenant"
+)
+
+var (
+	ErrInvalidPush   = errors.New("invalid revocation push")
+	ErrNotOwner      = errors.New("issuer does not own revocation list")
+	ErrPushReplayed  = errors.New("revocation push already applied")
+	ErrInvalidDelta  = errors.New("invalid revocation delta")
+	ErrDeltaTooLarge = errors.New("revocation delta too large")
+)
+
+// Channel is the message bus subject deltas are published on
+const Channel = "rvl:deltas"
+
+// pushType is the JWT typ of an issuer revocation push
+const pushType = "revocation-delta+jwt"
+
+// maxDeltaSize bounds the jtis of one delta
+const maxDeltaSize = 10000
+
+// Store persists revocation lists and their deltas
+type Store interface {
+	GetRevocationList(ctx context.Context, listID string) (models.RevocationList, error)
+	ApplyRevocationDelta(ctx context.Context, listID string, added, removed []string) (models.RevocationDelta, error)
+	GetRevocationChanges(ctx context.Context, listID string, since int64, limit int) (models.RevocationChanges, error)
+}
+
+// IssuerStore looks up registered issuers
+type IssuerStore interface {
+	GetIssuer(ctx context.Context, did string) (models.Issuer, error)
+}
+
+// Config configures revocation sync
+type Config struct {
+	Store      Store
+	Issuers    IssuerStore
+	Audit      audit.Sink
+	MaxPushAge time.Duration // Oldest accepted issuer push (default 5m)
+	// Bus fans deltas out to replicas (default Redis pub/sub on the client)
+	Bus    bus.MessageBus
+	Logger *slog.Logger
+}
+
+// Sync applies incremental revocation changes and fans them out to every
+// replica over the message bus, so caches and filters in front of the lists
+// learn about a revocation within seconds instead of at their next refresh.
+// Changes come from admins or, for lists with an owner, from the owning
+// issuer as a push signed with its registered key.
+type Sync struct {
+	client *redis.Client
+	cfg    Config
+	audit  *audit.Recorder
+}
+
+// NewSync creates a revocation sync
+func NewSync(client *redis.Client, cfg Config) *Sync {
+	if cfg.MaxPushAge == 0 {
+		cfg.MaxPushAge = 5 * time.Minute
+	}
+	if cfg.Bus == nil {
+		cfg.Bus = bus.NewRedis(client)
+	}
+	cfg.Logger = observability.Component(cfg.Logger, observability.ComponentRevocation)
+	return &Sync{client: client, cfg: cfg, audit: audit.NewRecorder(cfg.Audit, cfg.Logger, nil)}
+}
+
+// Apply records a delta and publishes it
+func (s *Sync) Apply(ctx context.Context, listID string, added, removed []string, actor string) (models.RevocationDelta, error) {
+	if len(added)+len(removed) == 0 {
+		return models.RevocationDelta{}, fmt.Errorf("%w: no changes", ErrInvalidDelta)
+	}
+	if len(added)+len(removed) > maxDeltaSize {
+		return models.RevocationDelta{}, fmt.Errorf("%w: at most %d jtis", ErrDeltaTooLarge, maxDeltaSize)
+	}
+	for _, jti := range append(append([]string{}, added...), removed...) {
+		if jti == "" || len(jti) > 256 {
+			return models.RevocationDelta{}, fmt.Errorf("%w: jti must be 1-256 characters", ErrInvalidDelta)
+		}
+	}
+	delta, err := s.cfg.Store.ApplyRevocationDelta(ctx, listID, added, removed)
+	if err != nil {
+		return delta, err
+	}
+	s.audit.Record(ctx, "revocation.delta", listID, actor, "success", map[string]interface{}{
+		"seq":     delta.Seq,
+		"added":   len(delta.Added),
+		"removed": len(delta.Removed),
+	})
+	if err := s.publish(ctx, delta); err != nil {
+		// The delta is stored; replicas catch up through Changes or at
+		// their next rebuild
+		s.cfg.Logger.Warn("publish revocation delta", "list_id", listID, "seq", delta.Seq, "error", err)
+	}
+	return delta, nil
+}
+
+// Changes returns the changes to a list since seq
+func (s *Sync) Changes(ctx context.Context, listID string, since int64, limit int) (models.RevocationChanges, error) {
+	if limit <= 0 || limit > 1000 {
+		limit = 1000
+	}
+	return s.cfg.Store.GetRevocationChanges(ctx, listID, since, limit)
+}
+
+func (s *Sync) publish(ctx context.Context, delta models.RevocationDelta) error {
+	data, err := json.Marshal(delta)
+	if err != nil {
+		return err
+	}
+	return s.cfg.Bus.Publish(ctx, Channel, data)
+}
+
+// Subscribe calls fn with every published delta until ctx is cancelled. fn
+// runs on the subscription goroutine and should not block.
+func (s *Sync) Subscribe(ctx context.Context, fn func(models.RevocationDelta)) error {
+	sub, err := s.cfg.Bus.Subscribe(ctx, Channel)
+	if err != nil {
+		return err
+	}
+	defer sub.Close()
+	ch := sub.Channel()
+	for {
+		select {
+		case msg, ok := <-ch:
+			if !ok {
+				return nil
+			}
+			var delta models.RevocationDelta
+			if err := json.Unmarshal(msg.Data, &delta); err != nil {
+				s.cfg.Logger.Warn("malformed revocation delta", "error", err)
+				continue
+			}
+			fn(delta)
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// pushClaims is the payload of an issuer push
+type pushClaims struct {
+	Iss     string   `json:"iss"`
+	JTI     string   `json:"jti"`
+	Iat     int64    `json:"iat"`
+	ListID  string   `json:"list_id"`
+	Added   []string `json:"added"`
+	Removed []string `json:"removed"`
+}
+
+// Push applies a delta pushed by an issuer: a compact JWS (EdDSA, typ
+// revocation-delta+jwt) signed with the key registered for the issuer, who
+// must own the list. Each push jti is accepted once.
+func (s *Sync) Push(ctx context.Context, listID, token string) (models.RevocationDelta, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return models.RevocationDelta{}, fmt.Errorf("%w: not a JWS", ErrInvalidPush)
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Typ string `json:"typ"`
+	}
+	if err := jws.DecodeSegment(parts[0], &header); err != nil {
+		return models.RevocationDelta{}, fmt.Errorf("%w: header: %v", ErrInvalidPush, err)
+	}
+	if header.Alg != "EdDSA" || header.Typ != pushType {
+		return models.RevocationDelta{}, fmt.Errorf("%w: must be an EdDSA %s", ErrInvalidPush, pushType)
+	}
+	var claims pushClaims
+	if err := jws.DecodeSegment(parts[1], &claims); err != nil {
+		return models.RevocationDelta{}, fmt.Errorf("%w: claims: %v", ErrInvalidPush, err)
+	}
+	if claims.ListID != listID || claims.JTI == "" {
+		return models.RevocationDelta{}, fmt.Errorf("%w: list_id and jti are required", ErrInvalidPush)
+	}
+	iat := time.Unix(claims.Iat, 0)
+	if now := time.Now(); iat.Before(now.Add(-s.cfg.MaxPushAge)) || iat.After(now.Add(time.Minute)) {
+		return models.RevocationDelta{}, fmt.Errorf("%w: iat out of range", ErrInvalidPush)
+	}
+
+	list, err := s.cfg.Store.GetRevocationList(ctx, listID)
+	if err != nil {
+		return models.RevocationDelta{}, err
+	}
+	if list.Owner == "" || list.Owner != claims.Iss {
+		return models.RevocationDelta{}, fmt.Errorf("%w: %s", ErrNotOwner, claims.Iss)
+	}
+	iss, err := s.cfg.Issuers.GetIssuer(ctx, claims.Iss)
+	if err != nil || !iss.Enabled {
+		return models.RevocationDelta{}, fmt.Errorf("%w: issuer %s is not registered", ErrInvalidPush, claims.Iss)
+	}
+	pub, err := crypto.DecodePublicKey(iss.PublicKey)
+	if err != nil {
+		return models.RevocationDelta{}, fmt.Errorf("%w: issuer key: %v", ErrInvalidPush, err)
+	}
+	if err := crypto.VerifySignature(pub, parts[0]+"."+parts[1], parts[2]); err != nil {
+		return models.RevocationDelta{}, fmt.Errorf("%w: %v", ErrInvalidPush, err)
+	}
+
+	// Claim the jti until the push could no longer pass the iat check
+	fresh, err := s.client.SetNX(ctx, "rvl:push|"+claims.Iss+"|"+claims.JTI, 1, s.cfg.MaxPushAge+time.Minute).Result()
+	if err != nil {
+		return models.RevocationDelta{}, err
+	}
+	if !fresh {
+		return models.RevocationDelta{}, ErrPushReplayed
+	}
+	return s.Apply(tenant.WithTenant(ctx, claims.Iss), listID, claims.Added, claims.Removed, claims.Iss)
+}
@@ -0,0 +1,217 @@
+package slo
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/example/privacy-gateway/internal/shared/clock"
+	"github.com/example/privacy-gateway/internal/shared/observability"
+)
+
+// SLIs
+const (
+	SLIAvailability = "availability" // Requests answered without a 5xx
+	SLILatency      = "latency"      // Requests answered within the threshold
+)
+
+var (
+	ErrInvalidObjective = errors.New("invalid SLO objective")
+	ErrUnhealthy        = errors.New("SLO unhealthy")
+)
+
+// Objective is the target for requests whose route starts with Route; the
+// longest matching objective applies. Either SLI may be left at zero to
+// not track it.
+type Objective struct {
+	Name  string // Metric label (default Route)
+	Route string // Route template or prefix, e.g. /v1/auth/verify or /api/
+	// Availability is the fraction of requests that must not fail with a
+	// 5xx, e.g. 0.999
+	Availability float64
+	// Latency is the fraction of requests that must be answered within
+	// LatencyThreshold, e.g. 0.99 within 200ms
+	Latency          float64
+	LatencyThreshold time.Duration
+}
+
+// Config configures SLO tracking
+type Config struct {
+	Objectives []Objective
+	// Window is the error budget window (default 24h). Requests are counted
+	// in memory per replica, in one-minute buckets; long-horizon SLOs are
+	// better computed from Prometheus.
+	Window      time.Duration
+	ShortWindow time.Duration // Fast burn window (default 5m)
+	LongWindow  time.Duration // Slow burn window (default 1h)
+	// MaxBurn is the highest burn rate over either window at which an
+	// objective counts as healthy (default 1: spending budget no faster
+	// than it accrues)
+	MaxBurn float64
+	// MinRequests is the fewest requests in a window for its burn rate to
+	// count against health (default 100)
+	MinRequests int64
+	Interval    time.Duration // Metric refresh interval for Run (default 15s)
+	// Registerer, when set, registers gateway_slo_* burn rate and budget
+	// gauges, refreshed by Run
+	Registerer prometheus.Registerer
+	Clock      clock.Clock // Buckets requests by minute (default clock.Real)
+	Logger     *slog.Logger
+}
+
+// bucket counts one minute of requests
+type bucket struct {
+	minute            int64
+	total, errs, slow int64
+}
+
+type objective struct {
+	Objective
+	mu      sync.Mutex
+	buckets []bucket // Ring indexed by minute
+}
+
+// Tracker counts requests against their objectives
+type Tracker struct {
+	cfg        Config
+	objectives []*objective // In configuration order
+	byRoute    []*objective // Longest route first
+	clock      clock.Clock
+	logger     *slog.Logger
+
+	burn    *prometheus.GaugeVec
+	budget  *prometheus.GaugeVec
+	healthy *prometheus.GaugeVec
+
+	mu   sync.Mutex
+	last map[string]bool // Health at the last refresh, by objective
+}
+
+// NewTracker validates the objectives and creates a tracker
+func NewTracker(cfg Config) (*Tracker, error) {
+	if cfg.Window == 0 {
+		cfg.Window = 24 * time.Hour
+	}
+	if cfg.ShortWindow == 0 {
+		cfg.ShortWindow = 5 * time.Minute
+	}
+	if cfg.LongWindow == 0 {
+		cfg.LongWindow = time.Hour
+	}
+	if cfg.MaxBurn == 0 {
+		cfg.MaxBurn = 1
+	}
+	if cfg.MinRequests == 0 {
+		cfg.MinRequests = 100
+	}
+	if cfg.Interval == 0 {
+		cfg.Interval = 15 * time.Second
+	}
+	if cfg.Window < time.Minute || cfg.ShortWindow < time.Minute || cfg.LongWindow < time.Minute {
+		return nil, fmt.Errorf("%w: windows must be at least a minute", ErrInvalidObjective)
+	}
+	if cfg.ShortWindow > cfg.Window || cfg.LongWindow > cfg.Window {
+		return nil, fmt.Errorf("%w: burn windows must fit in the budget window", ErrInvalidObjective)
+	}
+
+	t := &Tracker{
+		cfg:    cfg,
+		clock:  clock.Or(cfg.Clock),
+		logger: observability.Component(cfg.Logger, observability.ComponentHealth),
+		last:   make(map[string]bool),
+	}
+	names := make(map[string]bool)
+	for _, o := range cfg.Objectives {
+		if o.Name == "" {
+			o.Name = o.Route
+		}
+		switch {
+		case o.Route == "":
+			return nil, fmt.Errorf("%w: missing route", ErrInvalidObjective)
+		case names[o.Name]:
+			return nil, fmt.Errorf("%w: duplicate name %s", ErrInvalidObjective, o.Name)
+		case o.Availability < 0 || o.Availability >= 1, o.Latency < 0 || o.Latency >= 1:
+			return nil, fmt.Errorf("%w: %s: targets must be in [0, 1)", ErrInvalidObjective, o.Name)
+		case o.Availability == 0 && o.Latency == 0:
+			return nil, fmt.Errorf("%w: %s: no availability or latency target", ErrInvalidObjective, o.Name)
+		case o.Latency > 0 && o.LatencyThreshold <= 0:
+			return nil, fmt.Errorf("%w: %s: latency target without a threshold", ErrInvalidObjective, o.Name)
+		}
+		names[o.Name] = true
+		t.objectives = append(t.objectives, &objective{Objective: o, buckets: make([]bucket, int(cfg.Window/time.Minute))})
+	}
+	t.byRoute = append([]*objective(nil), t.objectives...)
+	sort.SliceStable(t.byRoute, func(i, j int) bool { return len(t.byRoute[i].Route) > len(t.byRoute[j].Route) })
+
+	if cfg.Registerer != nil {
+		t.burn = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "gateway_slo_burn_rate",
+			Help: "Error budget burn rate by objective, SLI and window; 1 spends the budget exactly over the SLO window.",
+		}, []string{"objective", "sli", "window"})
+		t.budget = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "gateway_slo_error_budget_remaining",
+			Help: "Fraction of the error budget left in the SLO window, by objective and SLI; negative when overspent.",
+		}, []string{"objective", "sli"})
+		t.healthy = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "gateway_slo_healthy",
+			Help: "1 while an objective is within budget and not burning faster than allowed.",
+		}, []string{"objective"})
+		cfg.Registerer.MustRegister(t.burn, t.budget, t.healthy)
+	}
+	return t, nil
+}
+
+// match returns the objective covering route, if any
+func (t *Tracker) match(route string) *objective {
+	for _, o := range t.byRoute {
+		if strings.HasPrefix(route, o.Route) {
+			return o
+		}
+	}
+	return nil
+}
+
+// Observe counts a finished request
+func (t *Tracker) Observe(route string, status int, latency time.Duration) {
+	o := t.match(route)
+	if o == nil {
+		return
+	}
+	minute := t.clock.Now().Unix() / 60
+	o.mu.Lock()
+	b := &o.buckets[minute%int64(len(o.buckets))]
+	if b.minute != minute {
+		*b = bucket{minute: minute}
+	}
+	b.total++
+	if status >= 500 {
+		b.errs++
+	}
+	if o.LatencyThreshold > 0 && latency > o.LatencyThreshold {
+		b.slow++
+	}
+	o.mu.Unlock()
+}
+
+// counts sums the buckets of the last d
+func (o *objective) counts(now time.Time, d time.Duration) bucket {
+	minute := now.Unix() / 60
+	oldest := minute - int64(d/time.Minute)
+	var sum bucket
+	o.mu.Lock()
+	for _, b := range o.buckets {
+		if b.minute > oldest && b.minute <= minute {
+			sum.total += b.total
+			sum.errs += b.errs
+			sum.slow += b.slow
+		}
+	}
+	o.mu.Unlock()
+	return sum
+}
@@ -0,0 +1,248 @@
+package slo
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/example/privacy-gateway/internal/shared/httpx"
+	"github.com/example/privacy-gateway/internal/shared/models"
+	"github.com/example/privacy-gateway/internal/shared/observability"
+)
+
+// SLIStatus is one SLI of an objective over the budget window
+type SLIStatus struct {
+	Target   float64 `json:"target"`
+	Requests int64   `json:"requests"`
+	Bad      int64   `json:"bad"`
+	// Compliance is the fraction of good requests; 1 without requests
+	Compliance float64 `json:"compliance"`
+	// BudgetRemaining is the fraction of the error budget left: 1 untouched,
+	// 0 spent, negative overspent
+	BudgetRemaining float64            `json:"budget_remaining"`
+	BurnRate        map[string]float64 `json:"burn_rate"` // By window, e.g. "5m"
+	Healthy         bool               `json:"healthy"`
+	Reason          string             `json:"reason,omitempty"`
+}
+
+// Status is the state of one objective
+type Status struct {
+	Name               string     `json:"name"`
+	Route              string     `json:"route"`
+	Availability       *SLIStatus `json:"availability,omitempty"`
+	Latency            *SLIStatus `json:"latency,omitempty"`
+	LatencyThresholdMS int64      `json:"latency_threshold_ms,omitempty"`
+	Healthy            bool       `json:"healthy"`
+}
+
+// Summary is the state of every objective
+type Summary struct {
+	Window     string   `json:"window"`
+	Objectives []Status `json:"objectives"`
+}
+
+// windowLabel formats a window as Prometheus does, e.g. 5m or 1h
+func windowLabel(d time.Duration) string {
+	if d%time.Hour == 0 {
+		return fmt.Sprintf("%dh", d/time.Hour)
+	}
+	return fmt.Sprintf("%dm", d/time.Minute)
+}
+
+// round drops float noise below four decimals
+func round(x float64) float64 {
+	return math.Round(x*1e4) / 1e4
+}
+
+func (t *Tracker) sli(o *objective, now time.Time, target float64, bad func(bucket) int64) *SLIStatus {
+	if target == 0 {
+		return nil
+	}
+	allowed := 1 - target
+	whole := o.counts(now, t.cfg.Window)
+	s := &SLIStatus{Target: target, Requests: whole.total, Bad: bad(whole), Compliance: 1, BudgetRemaining: 1,
+		BurnRate: make(map[string]float64), Healthy: true}
+	if whole.total > 0 {
+		badRatio := float64(s.Bad) / float64(whole.total)
+		s.Compliance = round(1 - badRatio)
+		s.BudgetRemaining = round(1 - badRatio/allowed)
+	}
+	if s.BudgetRemaining <= 0 {
+		s.Healthy, s.Reason = false, "error budget spent"
+	}
+	for _, w := range []time.Duration{t.cfg.ShortWindow, t.cfg.LongWindow} {
+		c := o.counts(now, w)
+		var burn float64
+		if c.total > 0 {
+			burn = round(float64(bad(c)) / float64(c.total) / allowed)
+		}
+		label := windowLabel(w)
+		s.BurnRate[label] = burn
+		if s.Healthy && c.total >= t.cfg.MinRequests && burn > t.cfg.MaxBurn {
+			s.Healthy, s.Reason = false, fmt.Sprintf("burn rate %.1f over %s", burn, label)
+		}
+	}
+	return s
+}
+
+func (t *Tracker) status(o *objective, now time.Time) Status {
+	st := Status{
+		Name:               o.Name,
+		Route:              o.Route,
+		Availability:       t.sli(o, now, o.Availability, func(b bucket) int64 { return b.errs }),
+		Latency:            t.sli(o, now, o.Latency, func(b bucket) int64 { return b.slow }),
+		LatencyThresholdMS: o.LatencyThreshold.Milliseconds(),
+		Healthy:            true,
+	}
+	for _, s := range []*SLIStatus{st.Availability, st.Latency} {
+		if s != nil && !s.Healthy {
+			st.Healthy = false
+		}
+	}
+	return st
+}
+
+// Summary returns the state of every objective, in configuration order
+func (t *Tracker) Summary() Summary {
+	now := t.clock.Now()
+	sum := Summary{Window: windowLabel(t.cfg.Window), Objectives: make([]Status, 0, len(t.objectives))}
+	for _, o := range t.objectives {
+		sum.Objectives = append(sum.Objectives, t.status(o, now))
+	}
+	return sum
+}
+
+// Check returns an ErrUnhealthy error if the objective covering route is
+// out of budget or burning too fast. Routes without an objective pass.
+func (t *Tracker) Check(route string) error {
+	o := t.match(route)
+	if o == nil {
+		return nil
+	}
+	st := t.status(o, t.clock.Now())
+	for _, s := range []struct {
+		sli    string
+		status *SLIStatus
+	}{{SLIAvailability, st.Availability}, {SLILatency, st.Latency}} {
+		if s.status != nil && !s.status.Healthy {
+			return fmt.Errorf("%w: %s %s: %s", ErrUnhealthy, o.Name, s.sli, s.status.Reason)
+		}
+	}
+	return nil
+}
+
+// WeightGate returns a gate for proxy.SetWeightsGated that blocks growing a
+// canary's share of traffic while the policy's route is unhealthy, so a canary
+// is not promoted on top of a burning error budget. Rollbacks to the stable
+// target never reach the gate.
+func (t *Tracker) WeightGate() func(ctx context.Context, pol models.Policy, raised []string) error {
+	return func(_ context.Context, pol models.Policy, raised []string) error {
+		route := pol.Route
+		if route == "" {
+			route = pol.RoutePrefix
+		}
+		if err := t.Check(route); err != nil {
+			t.logger.Warn("traffic split weight increase blocked", "policy", pol.ID, "targets", raised, "error", err)
+			return err
+		}
+		return nil
+	}
+}
+
+// Run refreshes the SLO gauges every interval and logs health changes until
+// ctx is cancelled
+func (t *Tracker) Run(ctx context.Context) {
+	ticker := time.NewTicker(t.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		t.refresh()
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (t *Tracker) refresh() {
+	now := t.clock.Now()
+	for _, o := range t.objectives {
+		st := t.status(o, now)
+		t.mu.Lock()
+		was, seen := t.last[o.Name]
+		t.last[o.Name] = st.Healthy
+		t.mu.Unlock()
+		if seen && was != st.Healthy {
+			if st.Healthy {
+				t.logger.Info("SLO recovered", "objective", o.Name)
+			} else {
+				t.logger.Warn("SLO unhealthy", "objective", o.Name, "error", t.Check(o.Route))
+			}
+		}
+		if t.healthy == nil {
+			continue
+		}
+		healthy := 0.0
+		if st.Healthy {
+			healthy = 1
+		}
+		t.healthy.WithLabelValues(o.Name).Set(healthy)
+		for sli, s := range map[string]*SLIStatus{SLIAvailability: st.Availability, SLILatency: st.Latency} {
+			if s == nil {
+				continue
+			}
+			t.budget.WithLabelValues(o.Name, sli).Set(s.BudgetRemaining)
+			for w, burn := range s.BurnRate {
+				t.burn.WithLabelValues(o.Name, sli, w).Set(burn)
+			}
+		}
+	}
+}
+
+// Middleware counts every request served by next against its objective.
+// identify names the route template; without it the path is used.
+func (t *Tracker) Middleware(identify observability.AccessIdentify, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(sw, r)
+		var route string
+		if identify != nil {
+			route, _ = identify(r)
+		}
+		if route == "" {
+			route = r.URL.Path
+		}
+		t.Observe(route, sw.status, time.Since(start))
+	})
+}
+
+// SummaryHandler serves GET /admin/v1/slo
+func SummaryHandler(t *Tracker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", "GET")
+			httpx.WriteJSON(w, http.StatusMethodNotAllowed, httpx.ErrorResponse{Error: "method not allowed"})
+			return
+		}
+		httpx.WriteJSON(w, http.StatusOK, t.Summary())
+	}
+}
+
+// statusWriter records the response status
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
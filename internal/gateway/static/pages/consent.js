@@ -0,0 +1,55 @@
+"use strict";
+
+const $ = (id) => document.getElementById(id);
+
+const params = new URLSearchParams(location.search);
+const client = params.get("client_id");
+if (client) $("client").textContent = client;
+
+// showScopes lists the requested scopes by their scope_<name> message;
+// unknown scopes are shown by name
+function showScopes(messages) {
+  $("scopes").replaceChildren();
+  for (const s of (params.get("scope") || "basic").split(/\s+/).filter(Boolean)) {
+    const li = document.createElement("li");
+    li.textContent = messages["scope_" + s] || s;
+    $("scopes").appendChild(li);
+  }
+}
+
+// The page ships in English; the gateway's catalog translates it into the
+// browser's language
+async function localize() {
+  try {
+    const res = await fetch("/v1/i18n/messages");
+    if (!res.ok) return;
+    const { language, messages } = await res.json();
+    document.documentElement.lang = language;
+    for (const el of document.querySelectorAll("[data-i18n]")) {
+      const msg = messages[el.dataset.i18n];
+      if (msg) el.textContent = msg;
+    }
+    showScopes(messages);
+  } catch (e) {
+    // Keep the English text
+  }
+}
+
+showScopes({
+  scope_basic: "That you control your identifier",
+  scope_premium: "That you hold a premium membership credential",
+});
+localize();
+
+// Pass return_to on to the login page, which only follows same-origin paths
+const next = new URLSearchParams();
+if (params.get("return_to")) next.set("return_to", params.get("return_to"));
+$("approve").href = "login" + (next.toString() ? "?" + next : "");
+
+$("decline").addEventListener("click", () => {
+  if (history.length > 1) {
+    history.back();
+  } else {
+    location.replace("./");
+  }
+});
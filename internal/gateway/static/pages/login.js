@@ -0,0 +1,71 @@
+"use strict";
+
+// The access token is kept in sessionStorage for pages on this origin; it is
+// gone when the tab closes
+const TOKEN_KEY = "gateway-access-token";
+const $ = (id) => document.getElementById(id);
+
+// returnTo only follows same-origin paths, so the page can't be used as an
+// open redirect
+function returnTo() {
+  const to = new URLSearchParams(location.search).get("return_to") || "";
+  return to.startsWith("/") && !to.startsWith("//") && !to.startsWith("/\\") ? to : "";
+}
+
+function fail(message) {
+  $("pending").hidden = true;
+  $("error").textContent = message;
+  $("restart").hidden = false;
+}
+
+async function start() {
+  $("error").textContent = "";
+  $("restart").hidden = true;
+  let session;
+  try {
+    const res = await fetch("/v1/auth/cross-device", { method: "POST" });
+    if (!res.ok) throw new Error(res.statusText);
+    session = await res.json();
+  } catch (e) {
+    fail("Could not start a login session. Try again in a moment.");
+    return;
+  }
+  $("qr").src = session.qr_code_url;
+  $("deep-link").href = session.deep_link;
+  $("expires").textContent = "The code expires at " + new Date(session.expires_at * 1000).toLocaleTimeString() + ".";
+  $("pending").hidden = false;
+  poll(session);
+}
+
+// poll long-polls the session until the wallet completes it
+async function poll(session) {
+  for (;;) {
+    let res;
+    try {
+      res = await fetch("/v1/auth/status/" + encodeURIComponent(session.id) + "?wait=25", {
+        headers: { "X-Poll-Secret": session.poll_secret },
+      });
+    } catch (e) {
+      await new Promise((r) => setTimeout(r, 2000));
+      continue;
+    }
+    if (res.status === 404) return fail("The login code expired.");
+    if (!res.ok) return fail("Login failed.");
+    const st = await res.json();
+    if (st.status === "completed") {
+      sessionStorage.setItem(TOKEN_KEY, st.access_token);
+      $("pending").hidden = true;
+      const to = returnTo();
+      if (to) {
+        location.replace(to);
+      } else {
+        $("done").hidden = false;
+      }
+      return;
+    }
+    if (st.status !== "pending") return fail("This login was already used.");
+  }
+}
+
+$("restart").addEventListener("click", start);
+start();
@@ -0,0 +1,172 @@
+package static
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/example/privacy-gateway/internal/shared/httpx"
+)
+
+//go:embed pages
+var embedded embed.FS
+
+// DefaultCSP allows the pages' own scripts, styles, QR images and API calls
+// and nothing else
+const DefaultCSP = "default-src 'self'; img-src 'self' data:; connect-src 'self'; frame-ancestors 'none'; form-action 'self'; base-uri 'none'"
+
+// hashedName matches file names carrying a content hash, as bundlers emit
+// them (app.3f2a91c0.js, index-B7x9kQ2a.css)
+var hashedName = regexp.MustCompile(`[.-]([A-Za-z0-9_]{8,})\.[A-Za-z0-9]+$`)
+
+// Config configures static serving. The zero value serves the embedded
+// wallet onboarding, QR login and consent pages under /wallet/.
+type Config struct {
+	Prefix string // Mount point (default "/wallet/")
+	// Dir serves this directory instead of the embedded pages, e.g. a
+	// branded build of them. It is read once at startup.
+	Dir string
+	// SPA serves index.html for paths that match no file and have no
+	// extension, so a client-side router can handle them
+	SPA bool
+	// MaxAge is the cache lifetime of assets without a content hash in
+	// their name (default 1h). HTML is always revalidated and hashed
+	// assets are cached for a year.
+	MaxAge time.Duration
+	CSP    string // Content-Security-Policy (default DefaultCSP)
+}
+
+// file is a loaded file with its validator and caching policy
+type file struct {
+	name         string
+	data         []byte
+	etag         string
+	cacheControl string
+}
+
+// Server serves a small static site from memory with ETags and
+// per-file caching headers
+type Server struct {
+	cfg   Config
+	files map[string]*file // By path below the prefix, without a leading slash
+}
+
+// New loads the site
+func New(cfg Config) (*Server, error) {
+	if cfg.Prefix == "" {
+		cfg.Prefix = "/wallet/"
+	}
+	if !strings.HasSuffix(cfg.Prefix, "/") {
+		cfg.Prefix += "/"
+	}
+	if cfg.MaxAge == 0 {
+		cfg.MaxAge = time.Hour
+	}
+	if cfg.CSP == "" {
+		cfg.CSP = DefaultCSP
+	}
+	var site fs.FS
+	if cfg.Dir != "" {
+		site = os.DirFS(cfg.Dir)
+	} else {
+		site, _ = fs.Sub(embedded, "pages")
+	}
+
+	s := &Server{cfg: cfg, files: make(map[string]*file)}
+	err := fs.WalkDir(site, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		data, err := fs.ReadFile(site, p)
+		if err != nil {
+			return err
+		}
+		sum := sha256.Sum256(data)
+		s.files[p] = &file{
+			name:         path.Base(p),
+			data:         data,
+			etag:         `"` + hex.EncodeToString(sum[:8]) + `"`,
+			cacheControl: s.cacheControl(p),
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("static site: %w", err)
+	}
+	if cfg.SPA && s.files["index.html"] == nil {
+		return nil, fmt.Errorf("static site: SPA mode needs an index.html")
+	}
+	return s, nil
+}
+
+// cacheControl picks the caching policy of a file: HTML is revalidated on
+// every load so deploys show up at once, fingerprinted assets never change
+// and the rest expire after MaxAge
+func (s *Server) cacheControl(p string) string {
+	if strings.HasSuffix(p, ".html") {
+		return "no-cache"
+	}
+	if m := hashedName.FindStringSubmatch(p); m != nil && strings.ContainsAny(m[1], "0123456789") {
+		return "public, max-age=31536000, immutable"
+	}
+	return fmt.Sprintf("public, max-age=%d", int(s.cfg.MaxAge.Seconds()))
+}
+
+// Prefix returns where the site is mounted
+func (s *Server) Prefix() string {
+	return s.cfg.Prefix
+}
+
+// lookup resolves a request path to a file: the path itself, then
+// path.html and path/index.html, then index.html in SPA mode
+func (s *Server) lookup(p string) *file {
+	p = strings.TrimPrefix(path.Clean("/"+p), "/")
+	candidates := []string{p, p + ".html", path.Join(p, "index.html")}
+	if p == "" {
+		candidates = []string{"index.html"}
+	}
+	for _, c := range candidates {
+		if f := s.files[c]; f != nil {
+			return f
+		}
+	}
+	if s.cfg.SPA && path.Ext(p) == "" {
+		return s.files["index.html"]
+	}
+	return nil
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == strings.TrimSuffix(s.cfg.Prefix, "/") {
+		http.Redirect(w, r, s.cfg.Prefix, http.StatusMovedPermanently)
+		return
+	}
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		httpx.WriteJSON(w, http.StatusMethodNotAllowed, httpx.ErrorResponse{Error: "method not allowed"})
+		return
+	}
+	f := s.lookup(strings.TrimPrefix(r.URL.Path, s.cfg.Prefix))
+	if f == nil {
+		httpx.WriteJSON(w, http.StatusNotFound, httpx.ErrorResponse{Error: "not found"})
+		return
+	}
+	h := w.Header()
+	h.Set("Content-Security-Policy", s.cfg.CSP)
+	h.Set("X-Content-Type-Options", "nosniff")
+	h.Set("Referrer-Policy", "no-referrer")
+	h.Set("Cache-Control", f.cacheControl)
+	h.Set("ETag", f.etag)
+	// ServeContent answers If-None-Match and Range and sets Content-Type
+	// from the extension
+	http.ServeContent(w, r, f.name, time.Time{}, bytes.NewReader(f.data))
+}
@@ -0,0 +1,71 @@
+package statuslist
+
+import (
+	"errors"
+	"net/http"
+	"path"
+	"strconv"
+
+	"github.com/example/privacy-gateway/internal/shared/httpx"
+)
+
+// ContentTypeVCJWT is the media type of a JWT-secured credential
+const ContentTypeVCJWT = "application/vc+jwt"
+
+// CredentialHandler serves GET /status/revocation/{id}, each list's
+// credential at its statusListCredential URL
+func CredentialHandler(l *List) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			httpx.WriteJSON(w, http.StatusMethodNotAllowed, httpx.ErrorResponse{Error: "method not allowed"})
+			return
+		}
+		id, err := strconv.ParseInt(path.Base(r.URL.Path), 10, 64)
+		if err != nil {
+			httpx.WriteJSON(w, http.StatusNotFound, httpx.ErrorResponse{Error: "status list not found"})
+			return
+		}
+		vc, err := l.Credential(r.Context(), id)
+		if errors.Is(err, ErrUnknownList) {
+			httpx.WriteJSON(w, http.StatusNotFound, httpx.ErrorResponse{Error: "status list not found"})
+			return
+		}
+		if err != nil {
+			httpx.WriteJSON(w, http.StatusServiceUnavailable, httpx.ErrorResponse{Error: "status list unavailable"})
+			return
+		}
+		// Short cache so revocations propagate within a minute
+		w.Header().Set("Cache-Control", "public, max-age=60")
+		w.Header().Set("Content-Type", ContentTypeVCJWT)
+		_, _ = w.Write([]byte(vc))
+	}
+}
+
+// revokeRequest names the credential or token to revoke
+type revokeRequest struct {
+	JTI string `json:"jti"`
+}
+
+// RevokeHandler serves POST /admin/v1/status/revocations (admin)
+func RevokeHandler(l *List) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			httpx.WriteJSON(w, http.StatusMethodNotAllowed, httpx.ErrorResponse{Error: "method not allowed"})
+			return
+		}
+		var req revokeRequest
+		if err := httpx.DecodeJSON(r, &req); err != nil || req.JTI == "" {
+			httpx.WriteJSON(w, http.StatusBadRequest, httpx.ErrorResponse{Error: "jti is required"})
+			return
+		}
+		err := l.Revoke(r.Context(), req.JTI)
+		switch {
+		case errors.Is(err, ErrUnknownEntry):
+			httpx.WriteJSON(w, http.StatusNotFound, httpx.ErrorResponse{Error: err.Error()})
+		case err != nil:
+			httpx.WriteJSON(w, http.StatusInternalServerError, httpx.ErrorResponse{Error: "failed to revoke"})
+		default:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}
+}
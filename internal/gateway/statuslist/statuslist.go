@@ -0,0 +1,377 @@
+package statuslist
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	ErrUnknownEntry  = errors.New("no status list entry for jti")
+	ErrUnknownList   = errors.New("no such status list")
+	ErrInvalidConfig = errors.New("invalid status list config")
+)
+
+// MinSize is the minimum list length in bits required by the Bitstring
+// Status List spec, so a single entry doesn't stand out (herd privacy)
+const MinSize = 131072
+
+// maxProbes bounds the random index draws per allocation. A list is retired
+// at half full, so each draw hits a free index at least half the time.
+const maxProbes = 32
+
+// Redis keys. Each list has its own keys under "sl:<id>:". Bits use SETBIT,
+// which numbers bits from the most significant bit of the first byte exactly
+// like the spec's bitstring; "used" marks allocated indexes the same way.
+const keyCurrent = "sl:cur"
+
+func listKey(id int64, part string) string {
+	return "sl:" + strconv.FormatInt(id, 10) + ":" + part
+}
+
+func entryKey(jti string) string {
+	return "sl:jti|" + jti
+}
+
+// Config configures the revocation status lists
+type Config struct {
+	URL        string // Base URL; list n is published at URL + "/n"
+	IssuerDID  string
+	KeyID      string
+	SigningKey ed25519.PrivateKey
+	Size       int           // Bits per list (default and minimum MinSize)
+	Validity   time.Duration // Lifetime of each published list credential (default 24h)
+}
+
+// List is the gateway's revocation list, published as Bitstring Status List
+// credentials. Entries are allocated to gateway-issued credentials and
+// tokens by jti at random indexes; revoking sets the entry's bit and
+// republishes its list. When the current list is half full, allocation
+// moves on to a new list with its own URL.
+type List struct {
+	client *redis.Client
+	cfg    Config
+}
+
+// NewList creates the status list
+func NewList(client *redis.Client, cfg Config) (*List, error) {
+	if cfg.URL == "" || cfg.IssuerDID == "" || len(cfg.SigningKey) != ed25519.PrivateKeySize {
+		return nil, fmt.Errorf("%w: URL, issuer DID and signing key are required", ErrInvalidConfig)
+	}
+	cfg.URL = strings.TrimSuffix(cfg.URL, "/")
+	if cfg.KeyID == "" {
+		cfg.KeyID = cfg.IssuerDID + "#key-1"
+	}
+	if cfg.Size < MinSize {
+		cfg.Size = MinSize
+	}
+	cfg.Size = (cfg.Size + 7) / 8 * 8
+	if cfg.Validity == 0 {
+		cfg.Validity = 24 * time.Hour
+	}
+	return &List{client: client, cfg: cfg}, nil
+}
+
+// URL returns the statusListCredential URL of list id
+func (l *List) URL(id int64) string {
+	return l.cfg.URL + "/" + strconv.FormatInt(id, 10)
+}
+
+// current returns the list new entries are allocated from. Lists are
+// numbered from 1.
+func (l *List) current(ctx context.Context) (int64, error) {
+	id, err := l.client.Get(ctx, keyCurrent).Int64()
+	if err == redis.Nil {
+		return 1, nil
+	}
+	return id, err
+}
+
+// allocateScript claims a free index in the current list and maps the jti
+// to it. It returns 1 on success, 0 if the index is taken, -1 if the list
+// is full and -2 if another replica already rolled over to a newer list.
+var allocateScript = redis.NewScript(`
+if (redis.call('GET', KEYS[1]) or '1') ~= ARGV[1] then
+	return -2
+end
+if tonumber(redis.call('GET', KEYS[3]) or '0') >= tonumber(ARGV[3]) then
+	return -1
+end
+if redis.call('GETBIT', KEYS[2], ARGV[2]) == 1 then
+	return 0
+end
+redis.call('SETBIT', KEYS[2], ARGV[2], 1)
+redis.call('INCR', KEYS[3])
+redis.call('SET', KEYS[4], ARGV[1] .. ':' .. ARGV[2], 'PXAT', ARGV[4])
+return 1
+`)
+
+// rolloverScript moves allocation from a full list to the next one, unless
+// another replica already did
+var rolloverScript = redis.NewScript(`
+if (redis.call('GET', KEYS[1]) or '1') == ARGV[1] then
+	redis.call('SET', KEYS[1], tonumber(ARGV[1]) + 1)
+end
+return 1
+`)
+
+// Allocate assigns a random unused index to jti and returns the
+// credentialStatus entry to embed in the credential. The jti mapping expires
+// with the credential at expires; the bit itself stays in the list.
+func (l *List) Allocate(ctx context.Context, jti string, expires time.Time) (map[string]interface{}, error) {
+	id, err := l.current(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// Sequential indexes would reveal issuance order, so draw at random and
+	// stop at half full to keep the draws cheap
+	limit := int64(l.cfg.Size / 2)
+	for probe := 0; probe < maxProbes; probe++ {
+		index, err := l.randomIndex()
+		if err != nil {
+			return nil, err
+		}
+		keys := []string{keyCurrent, listKey(id, "used"), listKey(id, "count"), entryKey(jti)}
+		res, err := allocateScript.Run(ctx, l.client, keys, id, index, limit, expires.UnixMilli()).Int64()
+		if err != nil {
+			return nil, err
+		}
+		switch res {
+		case 1:
+			return l.entry(id, index), nil
+		case -1:
+			if err := rolloverScript.Run(ctx, l.client, []string{keyCurrent}, id).Err(); err != nil {
+				return nil, err
+			}
+			fallthrough
+		case -2:
+			if id, err = l.current(ctx); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return nil, fmt.Errorf("no free status list index after %d probes", maxProbes)
+}
+
+func (l *List) randomIndex() (int64, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(int64(l.cfg.Size)))
+	if err != nil {
+		return 0, err
+	}
+	return n.Int64(), nil
+}
+
+func (l *List) entry(id, index int64) map[string]interface{} {
+	i := strconv.FormatInt(index, 10)
+	return map[string]interface{}{
+		"id":                   l.URL(id) + "#" + i,
+		"type":                 "BitstringStatusListEntry",
+		"statusPurpose":        "revocation",
+		"statusListIndex":      i,
+		"statusListCredential": l.URL(id),
+	}
+}
+
+// lookup returns the list and index allocated to jti
+func (l *List) lookup(ctx context.Context, jti string) (id, index int64, err error) {
+	v, err := l.client.Get(ctx, entryKey(jti)).Result()
+	if err == redis.Nil {
+		return 0, 0, fmt.Errorf("%w: %s", ErrUnknownEntry, jti)
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+	ids, indexes, _ := strings.Cut(v, ":")
+	if id, err = strconv.ParseInt(ids, 10, 64); err != nil {
+		return 0, 0, err
+	}
+	index, err = strconv.ParseInt(indexes, 10, 64)
+	return id, index, err
+}
+
+// Revoke sets the bit for jti and republishes its list
+func (l *List) Revoke(ctx context.Context, jti string) error {
+	id, index, err := l.lookup(ctx, jti)
+	if err != nil {
+		return err
+	}
+	// Bump the version with the bit so concurrent publishers can order their snapshots
+	_, err = l.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.SetBit(ctx, listKey(id, "bits"), index, 1)
+		pipe.Incr(ctx, listKey(id, "ver"))
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return l.publish(ctx, id)
+}
+
+// Revoked reports whether jti's bit is set
+func (l *List) Revoked(ctx context.Context, jti string) (bool, error) {
+	id, index, err := l.lookup(ctx, jti)
+	if err != nil {
+		return false, err
+	}
+	bit, err := l.client.GetBit(ctx, listKey(id, "bits"), index).Result()
+	return bit == 1, err
+}
+
+// publishScript stores a list credential unless it was built from an older
+// snapshot than the one currently published, so a slow replica can't
+// overwrite a fresher list. Equal versions hold the same bits and only
+// refresh the validity period.
+var publishScript = redis.NewScript(`
+local cur = tonumber(redis.call('GET', KEYS[2]) or '-1')
+if tonumber(ARGV[2]) < cur then
+	return 0
+end
+redis.call('SET', KEYS[1], ARGV[1])
+redis.call('SET', KEYS[2], ARGV[2])
+return 1
+`)
+
+// Publish republishes every list up to the current one
+func (l *List) Publish(ctx context.Context) error {
+	cur, err := l.current(ctx)
+	if err != nil {
+		return err
+	}
+	for id := int64(1); id <= cur; id++ {
+		if err := l.publish(ctx, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// publish builds and signs list id's credential from a consistent snapshot
+// of its bits and swaps it in with a single write
+func (l *List) publish(ctx context.Context, id int64) error {
+	var (
+		verCmd  *redis.StringCmd
+		bitsCmd *redis.StringCmd
+	)
+	_, err := l.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		verCmd = pipe.Get(ctx, listKey(id, "ver"))
+		bitsCmd = pipe.Get(ctx, listKey(id, "bits"))
+		return nil
+	})
+	if err != nil && err != redis.Nil {
+		return err
+	}
+	version, _ := verCmd.Int64()
+	bits, _ := bitsCmd.Bytes()
+
+	vc, err := l.sign(id, bits)
+	if err != nil {
+		return err
+	}
+	return publishScript.Run(ctx, l.client, []string{listKey(id, "vc"), listKey(id, "vc:ver")}, vc, version).Err()
+}
+
+// Credential returns list id's published credential (a JWT), publishing it
+// first if none exists yet. Lists past the current one don't exist.
+func (l *List) Credential(ctx context.Context, id int64) (string, error) {
+	cur, err := l.current(ctx)
+	if err != nil {
+		return "", err
+	}
+	if id < 1 || id > cur {
+		return "", fmt.Errorf("%w: %d", ErrUnknownList, id)
+	}
+	vc, err := l.client.Get(ctx, listKey(id, "vc")).Result()
+	if err == redis.Nil {
+		if err := l.publish(ctx, id); err != nil {
+			return "", err
+		}
+		vc, err = l.client.Get(ctx, listKey(id, "vc")).Result()
+	}
+	return vc, err
+}
+
+// encodeList pads bits to the list size, gzips it and multibase-encodes it
+// (base64url, "u" prefix) as the spec's encodedList
+func (l *List) encodeList(bits []byte) (string, error) {
+	buf := make([]byte, l.cfg.Size/8)
+	copy(buf, bits)
+	var out bytes.Buffer
+	zw := gzip.NewWriter(&out)
+	if _, err := zw.Write(buf); err != nil {
+		return "", err
+	}
+	if err := zw.Close(); err != nil {
+		return "", err
+	}
+	return "u" + base64.RawURLEncoding.EncodeToString(out.Bytes()), nil
+}
+
+// sign builds the BitstringStatusListCredential as a JWT
+func (l *List) sign(id int64, bits []byte) (string, error) {
+	url := l.URL(id)
+	encoded, err := l.encodeList(bits)
+	if err != nil {
+		return "", err
+	}
+	now := time.Now()
+	claims := map[string]interface{}{
+		"iss": l.cfg.IssuerDID,
+		"sub": url,
+		"iat": now.Unix(),
+		"exp": now.Add(l.cfg.Validity).Unix(),
+		"vc": map[string]interface{}{
+			"@context":   []string{"https://www.w3.org/ns/credentials/v2"},
+			"id":         url,
+			"type":       []string{"VerifiableCredential", "BitstringStatusListCredential"},
+			"issuer":     l.cfg.IssuerDID,
+			"validFrom":  now.UTC().Format(time.RFC3339),
+			"validUntil": now.Add(l.cfg.Validity).UTC().Format(time.RFC3339),
+			"credentialSubject": map[string]interface{}{
+				"id":            url + "#list",
+				"type":          "BitstringStatusList",
+				"statusPurpose": "revocation",
+				"encodedList":   encoded,
+			},
+		},
+	}
+	header, err := json.Marshal(map[string]string{"alg": "EdDSA", "typ": "vc+jwt", "kid": l.cfg.KeyID})
+	if err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	input := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	sig := ed25519.Sign(l.cfg.SigningKey, []byte(input))
+	return input + "." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
+
+// Refresh republishes the lists at the given interval, well inside Validity,
+// so the published credential never expires, until ctx is cancelled
+func (l *List) Refresh(ctx context.Context, interval time.Duration, onError func(error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := l.Publish(ctx); err != nil && onError != nil {
+				onError(err)
+			}
+		}
+	}
+}
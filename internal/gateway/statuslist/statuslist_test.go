@@ -0,0 +1,86 @@
+package statuslist
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// TestAllocateRollsOver checks that entries get distinct random indexes,
+// move to a new list with its own URL once a list is half full, and that
+// jti mappings expire with their credentials
+func TestAllocateRollsOver(t *testing.T) {
+	ctx := context.Background()
+	mr := miniredis.RunT(t)
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := NewList(redis.NewClient(&redis.Options{Addr: mr.Addr()}), Config{
+		URL:        "https://gateway.example.com/status/revocation",
+		IssuerDID:  "did:web:gateway.example.com",
+		SigningKey: key,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Small enough to fill in a test
+	l.cfg.Size = 64
+
+	expires := time.Now().Add(time.Hour)
+	seen := map[string]bool{}
+	sequential := true
+	for i := 0; i < 40; i++ {
+		jti := "jti-" + strconv.Itoa(i)
+		entry, err := l.Allocate(ctx, jti, expires)
+		if err != nil {
+			t.Fatalf("allocate %d: %v", i, err)
+		}
+		want := l.URL(1)
+		if i >= 32 {
+			want = l.URL(2)
+		}
+		if got := entry["statusListCredential"]; got != want {
+			t.Fatalf("entry %d: list = %v, want %s", i, got, want)
+		}
+		id := entry["id"].(string)
+		if seen[id] {
+			t.Fatalf("entry %d: index reused: %s", i, id)
+		}
+		seen[id] = true
+		if entry["statusListIndex"] != strconv.Itoa(i%32) {
+			sequential = false
+		}
+	}
+	if sequential {
+		t.Error("indexes were allocated sequentially")
+	}
+
+	if err := l.Revoke(ctx, "jti-35"); err != nil {
+		t.Fatal(err)
+	}
+	if revoked, err := l.Revoked(ctx, "jti-35"); err != nil || !revoked {
+		t.Fatalf("revoked = %v, %v; want true", revoked, err)
+	}
+	if _, err := l.Credential(ctx, 2); err != nil {
+		t.Fatalf("credential for list 2: %v", err)
+	}
+	if _, err := l.Credential(ctx, 3); !errors.Is(err, ErrUnknownList) {
+		t.Fatalf("credential for list 3: err = %v, want ErrUnknownList", err)
+	}
+
+	if ttl := mr.TTL(entryKey("jti-0")); ttl <= 0 || ttl > time.Hour {
+		t.Fatalf("jti mapping ttl = %v, want up to 1h", ttl)
+	}
+	mr.FastForward(2 * time.Hour)
+	if _, err := l.Revoked(ctx, "jti-0"); !errors.Is(err, ErrUnknownEntry) {
+		t.Fatalf("after expiry: err = %v, want ErrUnknownEntry", err)
+	}
+}
@@ -0,0 +1,205 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var (
+	ErrNotFound  = errors.New("record not found")
+	ErrNoPrimary = errors.New("primary DSN is required")
+)
+
+// Config holds Postgres connection configuration
+type Config struct {
+	PrimaryDSN  string   // Read/write primary
+	ReplicaDSNs []string // Optional read replicas
+
+	MaxConns          int32         // Max connections per pool
+	MinConns          int32         // Idle connections kept warm per pool
+	MaxConnLifetime   time.Duration // Recycle connections after this long
+	HealthCheckPeriod time.Duration // How often replica health is probed
+
+	// StatementCacheCapacity is the number of prepared statements cached per
+	// connection. Policy and issuer lookups are executed with cached statements.
+	StatementCacheCapacity int
+
+	// Region is stamped on audit events that don't name one (multi-region)
+	Region string
+}
+
+// replica is a read-only pool with tracked health
+type replica struct {
+	dsn     string
+	pool    *pgxpool.Pool
+	healthy atomic.Bool
+}
+
+// Postgres routes writes to the primary and reads to healthy replicas,
+// falling back to the primary when no replica is available
+type Postgres struct {
+	primary  *pgxpool.Pool
+	replicas []*replica
+	next     atomic.Uint32
+
+	healthCheckPeriod time.Duration
+	region            string
+	stop              chan struct{}
+	wg                sync.WaitGroup
+}
+
+// NewPostgres connects to the primary and all configured replicas
+func NewPostgres(ctx context.Context, cfg Config) (*Postgres, error) {
+	if cfg.PrimaryDSN == "" {
+		return nil, ErrNoPrimary
+	}
+	if cfg.MaxConns == 0 {
+		cfg.MaxConns = 20
+	}
+	if cfg.MaxConnLifetime == 0 {
+		cfg.MaxConnLifetime = 30 * time.Minute
+	}
+	if cfg.HealthCheckPeriod == 0 {
+		cfg.HealthCheckPeriod = 5 * time.Second
+	}
+	if cfg.StatementCacheCapacity == 0 {
+		cfg.StatementCacheCapacity = 128
+	}
+
+	primary, err := newPool(ctx, cfg.PrimaryDSN, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to primary: %w", err)
+	}
+
+	p := &Postgres{
+		primary:           primary,
+		healthCheckPeriod: cfg.HealthCheckPeriod,
+		region:            cfg.Region,
+		stop:              make(chan struct{}),
+	}
+
+	for _, dsn := range cfg.ReplicaDSNs {
+		pool, err := newPool(ctx, dsn, cfg)
+		if err != nil {
+			p.Close()
+			return nil, fmt.Errorf("failed to configure replica: %w", err)
+		}
+		r := &replica{dsn: dsn, pool: pool}
+		// Replicas start healthy only if reachable; the monitor corrects this later
+		r.healthy.Store(pool.Ping(ctx) == nil)
+		p.replicas = append(p.replicas, r)
+	}
+
+	if len(p.replicas) > 0 {
+		p.wg.Add(1)
+		go p.monitor()
+	}
+
+	return p, nil
+}
+
+// newPool builds a pgx pool with statement caching enabled
+func newPool(ctx context.Context, dsn string, cfg Config) (*pgxpool.Pool, error) {
+	poolCfg, err := pgxpool.ParseConfig(dsn)
+	if err != nil {
+		return nil, err
+	}
+	poolCfg.MaxConns = cfg.MaxConns
+	poolCfg.MinConns = cfg.MinConns
+	poolCfg.MaxConnLifetime = cfg.MaxConnLifetime
+	poolCfg.HealthCheckPeriod = cfg.HealthCheckPeriod
+
+	// Cache prepared statements per connection so repeated lookups skip parse/plan
+	poolCfg.ConnConfig.DefaultQueryExecMode = pgx.QueryExecModeCacheStatement
+	poolCfg.ConnConfig.StatementCacheCapacity = cfg.StatementCacheCapacity
+
+	return pgxpool.NewWithConfig(ctx, poolCfg)
+}
+
+// monitor periodically pings replicas and updates their health
+func (p *Postgres) monitor() {
+	defer p.wg.Done()
+
+	ticker := time.NewTicker(p.healthCheckPeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.stop:
+			return
+		case <-ticker.C:
+			for _, r := range p.replicas {
+				ctx, cancel := context.WithTimeout(context.Background(), p.healthCheckPeriod)
+				r.healthy.Store(r.pool.Ping(ctx) == nil)
+				cancel()
+			}
+		}
+	}
+}
+
+// Writer returns the primary pool
+func (p *Postgres) Writer() *pgxpool.Pool {
+	return p.primary
+}
+
+// Reader returns a healthy replica pool (round-robin), or the primary if none are healthy
+func (p *Postgres) Reader() *pgxpool.Pool {
+	n := len(p.replicas)
+	if n == 0 {
+		return p.primary
+	}
+
+	// Reduce before converting so the index can't go negative when int is
+	// 32 bits and the counter wraps
+	start := p.next.Add(1) % uint32(n)
+	for i := uint32(0); i < uint32(n); i++ {
+		r := p.replicas[(start+i)%uint32(n)]
+		if r.healthy.Load() {
+			return r.pool
+		}
+	}
+	return p.primary
+}
+
+// markUnhealthy flags the replica owning pool as unhealthy after a connection failure
+func (p *Postgres) markUnhealthy(pool *pgxpool.Pool) {
+	for _, r := range p.replicas {
+		if r.pool == pool {
+			r.healthy.Store(false)
+			return
+		}
+	}
+}
+
+// Ping checks primary connectivity (for health.NewDatabaseChecker)
+func (p *Postgres) Ping(ctx context.Context) error {
+	return p.primary.Ping(ctx)
+}
+
+// HealthyReplicas returns the number of replicas currently serving reads
+func (p *Postgres) HealthyReplicas() int {
+	count := 0
+	for _, r := range p.replicas {
+		if r.healthy.Load() {
+			count++
+		}
+	}
+	return count
+}
+
+// Close stops the health monitor and closes all pools
+func (p *Postgres) Close() {
+	close(p.stop)
+	p.wg.Wait()
+	for _, r := range p.replicas {
+		r.pool.Close()
+	}
+	p.primary.Close()
+}
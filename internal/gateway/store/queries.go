@@ -0,0 +1,1001 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/example/privacy-gateway/internal/shared/models"
+	"github.com/example/privacy-gateway/internal/shared/tenant"
+	"github.com/example/privacy-gateway/internal/shared/validate"
+)
+
+// policyColumns lists policy columns in policyFields order
+var policyColumns = []string{
+	"id", "name", "route_prefix", "route", "methods", "priority", "required_scopes",
+	"required_vc_types", "allowed_issuers", "min_trust_tier", "require_domain_linked_issuer",
+	"rate_limit", "quota", "priority_class", "limits", "mirror", "traffic_split", "transform",
+	"body_conditions", "filters", "scripts", "token_ttl_seconds", "allow_api_keys", "shadow", "trust_score",
+	"receipt", "sign_response", "envelope",
+}
+
+// policyFields returns pointers to the policy fields backing policyColumns.
+// They serve as scan destinations and (dereferenced by pgx) as query arguments;
+// JSONB columns map to pointer fields that stay nil when NULL.
+func policyFields(pol *models.Policy) []any {
+	return []any{
+		&pol.ID, &pol.Name, &pol.RoutePrefix, &pol.Route, &pol.Methods, &pol.Priority, &pol.RequiredScopes,
+		&pol.RequiredVCTypes, &pol.AllowedIssuers, &pol.MinTrustTier, &pol.RequireDomainLinkedIssuer,
+		&pol.RateLimit, &pol.Quota, &pol.PriorityClass, &pol.Limits, &pol.Mirror, &pol.TrafficSplit, &pol.Transform,
+		&pol.BodyConditions, &pol.Filters, &pol.Scripts, &pol.TokenTTLSeconds, &pol.AllowAPIKeys, &pol.Shadow,
+		&pol.TrustScore, &pol.Receipt, &pol.SignResponse, &pol.Envelope,
+	}
+}
+
+var (
+	listPoliciesSQL = "SELECT " + strings.Join(policyColumns, ", ") + " FROM policies ORDER BY id"
+	getPolicySQL    = "SELECT " + strings.Join(policyColumns, ", ") + " FROM policies WHERE id = $1"
+	upsertPolicySQL = upsertSQL("policies", "id", policyColumns)
+	lockPolicySQL   = getPolicySQL + " FOR UPDATE"
+)
+
+// upsertSQL builds an INSERT ... ON CONFLICT DO UPDATE statement for columns
+func upsertSQL(table, key string, columns []string) string {
+	placeholders := make([]string, len(columns))
+	updates := make([]string, 0, len(columns))
+	for i, col := range columns {
+		placeholders[i] = fmt.Sprintf("$%d", i+1)
+		if col != key {
+			updates = append(updates, col+" = EXCLUDED."+col)
+		}
+	}
+	return fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s) ON CONFLICT (%s) DO UPDATE SET %s",
+		table, strings.Join(columns, ", "), strings.Join(placeholders, ", "), key, strings.Join(updates, ", "))
+}
+
+const (
+	bumpPolicyVersionSQL = `UPDATE policy_version SET version = version + 1`
+	setTrafficSplitSQL   = `UPDATE policies SET traffic_split = $2 WHERE id = $1`
+	getPolicyVersionSQL  = `SELECT version FROM policy_version`
+
+	listIssuersSQL  = `SELECT did, public_key, enabled, trust_tier, key_pinning, created_at, updated_at FROM issuers ORDER BY did`
+	getIssuerSQL    = `SELECT did, public_key, enabled, trust_tier, key_pinning, created_at, updated_at FROM issuers WHERE did = $1`
+	upsertIssuerSQL = `INSERT INTO issuers (did, public_key, enabled, trust_tier, key_pinning, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, now(), now())
+		ON CONFLICT (did) DO UPDATE SET public_key = $2, enabled = $3, trust_tier = $4, key_pinning = $5, updated_at = now()`
+
+	listAdminsSQL  = `SELECT did, role, enabled, created_at, updated_at FROM admins ORDER BY did`
+	getAdminSQL    = `SELECT did, role, enabled, created_at, updated_at FROM admins WHERE did = $1`
+	upsertAdminSQL = `INSERT INTO admins (did, role, enabled, created_at, updated_at) VALUES ($1, $2, $3, now(), now())
+		ON CONFLICT (did) DO UPDATE SET role = $2, enabled = $3, updated_at = now()`
+	deleteAdminSQL = `DELETE FROM admins WHERE did = $1`
+
+	accountColumns       = `id, did, COALESCE(previous_did, ''), COALESCE(email, ''), COALESCE(phone, ''), created_at, updated_at`
+	getAccountSQL        = `SELECT ` + accountColumns + ` FROM accounts WHERE id = $1`
+	getAccountByEmailSQL = `SELECT ` + accountColumns + ` FROM accounts WHERE lower(email) = lower($1)`
+	getAccountByPhoneSQL = `SELECT ` + accountColumns + ` FROM accounts WHERE phone = $1`
+	getAccountByDIDSQL   = `SELECT ` + accountColumns + ` FROM accounts WHERE did = $1`
+	upsertAccountSQL     = `INSERT INTO accounts (id, did, email, phone, created_at, updated_at)
+		VALUES ($1, $2, NULLIF($3, ''), NULLIF($4, ''), now(), now())
+		ON CONFLICT (id) DO UPDATE SET did = $2, email = NULLIF($3, ''), phone = NULLIF($4, ''), updated_at = now()`
+	// The expected current DID guards against two recoveries racing. A link
+	// of the old DID to the account moves along with it.
+	rebindAccountDIDSQL = `WITH moved AS (
+			UPDATE account_links SET did = $3, updated_at = now()
+			WHERE did = $2 AND account_id = $1 AND EXISTS (SELECT 1 FROM accounts WHERE id = $1 AND did = $2)
+		)
+		UPDATE accounts SET previous_did = did, did = $3, updated_at = now() WHERE id = $1 AND did = $2`
+
+	accountLinkColumns   = `did, account_id, COALESCE(linked_by, ''), created_at, updated_at`
+	getAccountLinkSQL    = `SELECT ` + accountLinkColumns + ` FROM account_links WHERE did = $1`
+	listAccountLinksSQL  = `SELECT ` + accountLinkColumns + ` FROM account_links WHERE account_id = $1 ORDER BY created_at, did`
+	upsertAccountLinkSQL = `INSERT INTO account_links (did, account_id, linked_by, created_at, updated_at)
+		VALUES ($1, $2, NULLIF($3, ''), now(), now())
+		ON CONFLICT (did) DO UPDATE SET account_id = $2, linked_by = NULLIF($3, ''), updated_at = now()`
+	deleteAccountLinkSQL = `DELETE FROM account_links WHERE did = $1`
+
+	deviceColumns     = `id, did, key_id, COALESCE(label, ''), source, created_at, last_seen_at, revoked_at`
+	listDevicesSQL    = `SELECT ` + deviceColumns + ` FROM devices WHERE did = $1 ORDER BY created_at, id`
+	getDeviceSQL      = `SELECT ` + deviceColumns + ` FROM devices WHERE did = $1 AND id = $2`
+	getDeviceByKeySQL = `SELECT ` + deviceColumns + ` FROM devices WHERE did = $1 AND key_id = $2`
+	insertDeviceSQL   = `INSERT INTO devices (id, did, key_id, label, source, created_at, last_seen_at)
+		VALUES ($1, $2, $3, NULLIF($4, ''), $5, now(), now())
+		ON CONFLICT (did, key_id) DO NOTHING`
+	touchDeviceSQL  = `UPDATE devices SET last_seen_at = now() WHERE id = $1`
+	labelDeviceSQL  = `UPDATE devices SET label = NULLIF($3, '') WHERE did = $1 AND id = $2`
+	revokeDeviceSQL = `UPDATE devices SET revoked_at = now() WHERE did = $1 AND id = $2 AND revoked_at IS NULL`
+
+	alertEndpointColumns   = `id, did, channel, target, created_at`
+	listAlertEndpointsSQL  = `SELECT ` + alertEndpointColumns + ` FROM alert_endpoints WHERE did = $1 ORDER BY created_at, id`
+	insertAlertEndpointSQL = `INSERT INTO alert_endpoints (id, did, channel, target, created_at) VALUES ($1, $2, $3, $4, now())`
+	deleteAlertEndpointSQL = `DELETE FROM alert_endpoints WHERE did = $1 AND id = $2`
+
+	apiKeyColumns   = `id, name, hash, previous_hash, previous_expires_at, scopes, expires_at, revoked, created_at, updated_at`
+	listAPIKeysSQL  = `SELECT ` + apiKeyColumns + ` FROM api_keys ORDER BY id`
+	getAPIKeySQL    = `SELECT ` + apiKeyColumns + ` FROM api_keys WHERE id = $1`
+	insertAPIKeySQL = `INSERT INTO api_keys (id, name, hash, previous_hash, previous_expires_at, scopes, expires_at, revoked, created_at, updated_at)
+		VALUES ($1, $2, $3, '', NULL, $4, $5, false, now(), now())`
+	rotateAPIKeySQL = `UPDATE api_keys SET previous_hash = hash, previous_expires_at = $3, hash = $2, updated_at = now()
+		WHERE id = $1 AND NOT revoked`
+	revokeAPIKeySQL = `UPDATE api_keys SET revoked = true, updated_at = now() WHERE id = $1`
+
+	getRevocationListSQL    = `SELECT list_id, revoked, updated_at, COALESCE(owner_did, ''), seq FROM revocation_lists WHERE list_id = $1`
+	upsertRevocationListSQL = `INSERT INTO revocation_lists (list_id, revoked, updated_at, owner_did, seq, base_seq) VALUES ($1, $2, $3, $4, 1, 1)
+		ON CONFLICT (list_id) DO UPDATE SET revoked = $2, updated_at = $3, owner_did = $4,
+			seq = revocation_lists.seq + 1, base_seq = revocation_lists.seq + 1`
+	applyRevocationDeltaSQL = `UPDATE revocation_lists SET
+			revoked = ARRAY(SELECT DISTINCT j FROM unnest(array_cat(revoked, $2::text[])) AS j WHERE NOT j = ANY($3::text[])),
+			seq = seq + 1, updated_at = now()
+		WHERE list_id = $1 RETURNING seq`
+	insertRevocationDeltaSQL = `INSERT INTO revocation_deltas (list_id, seq, added, removed, created_at) VALUES ($1, $2, $3, $4, now())`
+	getRevocationBaseSQL     = `SELECT revoked, seq, base_seq FROM revocation_lists WHERE list_id = $1`
+	listRevocationDeltasSQL  = `SELECT seq, added, removed FROM revocation_deltas
+		WHERE list_id = $1 AND seq > $2 ORDER BY seq LIMIT $3`
+	pruneRevocationDeltasSQL = `DELETE FROM revocation_deltas WHERE list_id = $1`
+
+	upsertQuotaUsageSQL = `INSERT INTO quota_usage (scope, subject, policy_id, period, count, updated_at)
+		VALUES ($1, $2, $3, $4, $5, now())
+		ON CONFLICT (scope, subject, policy_id, period) DO UPDATE SET count = GREATEST(quota_usage.count, $5), updated_at = now()`
+	listQuotaUsageSQL = `SELECT scope, subject, policy_id, period, count, updated_at FROM quota_usage
+		WHERE scope = $1 AND subject = $2 AND period >= $3 AND period <= $4 ORDER BY period, policy_id`
+
+	insertMeteringRecordSQL = `INSERT INTO metering_records
+		(id, did, route, policy_id, window_start, window_end, requests, errors, bytes_in, bytes_out, tenant)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, NULLIF($11, '')) ON CONFLICT (id) DO NOTHING`
+	summarizeTenantUsageSQL = `SELECT COALESCE(tenant, ''), '', '', SUM(requests), SUM(errors), SUM(bytes_in), SUM(bytes_out), COUNT(DISTINCT did)
+		FROM metering_records WHERE window_start >= $1 AND window_start < $2
+		GROUP BY 1 ORDER BY 4 DESC`
+	tenantUsageByRouteSQL = `SELECT COALESCE(tenant, ''), route, policy_id, SUM(requests), SUM(errors), SUM(bytes_in), SUM(bytes_out), COUNT(DISTINCT did)
+		FROM metering_records WHERE COALESCE(tenant, '') = $1 AND window_start >= $2 AND window_start < $3
+		GROUP BY 1, 2, 3 ORDER BY 4 DESC`
+
+	insertAuditEventSQL = `INSERT INTO audit_events (time, event, subject, actor, outcome, metadata, region, tenant)
+		VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, ''), NULLIF($8, ''))`
+	listAuditEventsSQL = `SELECT time, event, COALESCE(subject, ''), COALESCE(actor, ''), outcome, metadata, COALESCE(region, ''), COALESCE(tenant, '')
+		FROM audit_events ORDER BY time DESC LIMIT $1`
+
+	listAuditPartitionsSQL = `SELECT c.oid::regclass::text, pg_get_expr(c.relpartbound, c.oid), GREATEST(c.reltuples, 0)::bigint
+		FROM pg_inherits i JOIN pg_class c ON c.oid = i.inhrelid
+		WHERE i.inhparent = 'audit_events'::regclass`
+	purgeAuditEventsSQL = `DELETE FROM audit_events WHERE ctid = ANY(ARRAY(
+		SELECT ctid FROM audit_events WHERE time < $1 LIMIT $2))`
+	purgeMeteringRecordsSQL = `DELETE FROM metering_records WHERE ctid = ANY(ARRAY(
+		SELECT ctid FROM metering_records WHERE window_end < $1 LIMIT $2))`
+	purgeQuotaUsageSQL = `DELETE FROM quota_usage WHERE ctid = ANY(ARRAY(
+		SELECT ctid FROM quota_usage WHERE period < $1 LIMIT $2))`
+	// Consumers behind the newest pruned delta must fetch the full list, so
+	// base_seq moves past it
+	purgeRevocationDeltasSQL = `WITH pruned AS (
+			DELETE FROM revocation_deltas WHERE created_at < $1 RETURNING list_id, seq
+		), bases AS (
+			UPDATE revocation_lists l SET base_seq = GREATEST(l.base_seq, p.seq)
+			FROM (SELECT list_id, max(seq) AS seq FROM pruned GROUP BY list_id) p
+			WHERE l.list_id = p.list_id
+		)
+		SELECT count(*) FROM pruned`
+)
+
+// read runs fn against a replica. If the replica can't be reached it is taken
+// out of rotation and fn is retried once on the primary; other errors (no
+// rows, SQL and scan errors) are returned as-is since every replica would
+// fail the same way.
+func (p *Postgres) read(ctx context.Context, fn func(pool *pgxpool.Pool) error) error {
+	pool := p.Reader()
+	err := fn(pool)
+	if err == nil || pool == p.primary || ctx.Err() != nil || !connectivityError(err) {
+		return err
+	}
+	p.markUnhealthy(pool)
+	return fn(p.primary)
+}
+
+// connectivityError reports whether err means the server couldn't be used,
+// as opposed to a query failing on a working connection
+func connectivityError(err error) bool {
+	var connectErr *pgconn.ConnectError
+	var netErr net.Error
+	if errors.As(err, &connectErr) || errors.As(err, &netErr) || pgconn.SafeToRetry(err) || pgconn.Timeout(err) {
+		return true
+	}
+	// Class 08 is connection exceptions; 57P01-57P03 are shutdowns and
+	// "cannot connect now" while the server starts
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return strings.HasPrefix(pgErr.Code, "08") || strings.HasPrefix(pgErr.Code, "57P")
+	}
+	return false
+}
+
+// ListPolicies returns all policies
+func (p *Postgres) ListPolicies(ctx context.Context) ([]models.Policy, error) {
+	var policies []models.Policy
+	err := p.read(ctx, func(pool *pgxpool.Pool) error {
+		rows, err := pool.Query(ctx, listPoliciesSQL)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		policies = policies[:0]
+		for rows.Next() {
+			pol, err := scanPolicy(rows)
+			if err != nil {
+				return err
+			}
+			policies = append(policies, pol)
+		}
+		return rows.Err()
+	})
+	return policies, err
+}
+
+// GetPolicy returns a single policy by ID
+func (p *Postgres) GetPolicy(ctx context.Context, id string) (models.Policy, error) {
+	var pol models.Policy
+	err := p.read(ctx, func(pool *pgxpool.Pool) error {
+		var err error
+		pol, err = scanPolicy(pool.QueryRow(ctx, getPolicySQL, id))
+		return err
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return pol, ErrNotFound
+	}
+	return pol, err
+}
+
+// UpsertPolicy creates or replaces a policy and bumps the policy version
+func (p *Postgres) UpsertPolicy(ctx context.Context, pol models.Policy) error {
+	return pgx.BeginFunc(ctx, p.primary, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, upsertPolicySQL, policyFields(&pol)...); err != nil {
+			return err
+		}
+		_, err := tx.Exec(ctx, bumpPolicyVersionSQL)
+		return err
+	})
+}
+
+// UpdateTrafficSplit changes only a policy's traffic split. It locks the
+// row on the primary and passes the current policy to fn, so concurrent
+// edits to the rest of the policy aren't overwritten and the split is never
+// computed from a stale replica read. An error from fn aborts the update.
+func (p *Postgres) UpdateTrafficSplit(ctx context.Context, id string, fn func(pol models.Policy) (*models.TrafficSplit, error)) (*models.TrafficSplit, error) {
+	var split *models.TrafficSplit
+	err := pgx.BeginFunc(ctx, p.primary, func(tx pgx.Tx) error {
+		pol, err := scanPolicy(tx.QueryRow(ctx, lockPolicySQL, id))
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if split, err = fn(pol); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(ctx, setTrafficSplitSQL, id, split); err != nil {
+			return err
+		}
+		_, err = tx.Exec(ctx, bumpPolicyVersionSQL)
+		return err
+	})
+	return split, err
+}
+
+// PolicyVersion returns the current policy table version.
+// It is read from the primary so invalidation is never delayed by replica lag.
+func (p *Postgres) PolicyVersion(ctx context.Context) (int64, error) {
+	var version int64
+	err := p.primary.QueryRow(ctx, getPolicyVersionSQL).Scan(&version)
+	return version, err
+}
+
+// scanPolicy decodes a policy row
+func scanPolicy(row pgx.Row) (models.Policy, error) {
+	var pol models.Policy
+	err := row.Scan(policyFields(&pol)...)
+	return pol, err
+}
+
+// ListIssuers returns all registered issuers
+func (p *Postgres) ListIssuers(ctx context.Context) ([]models.Issuer, error) {
+	var issuers []models.Issuer
+	err := p.read(ctx, func(pool *pgxpool.Pool) error {
+		rows, err := pool.Query(ctx, listIssuersSQL)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		issuers = issuers[:0]
+		for rows.Next() {
+			var iss models.Issuer
+			if err := rows.Scan(&iss.DID, &iss.PublicKey, &iss.Enabled, &iss.TrustTier, &iss.KeyPinning, &iss.CreatedAt, &iss.UpdatedAt); err != nil {
+				return err
+			}
+			issuers = append(issuers, iss)
+		}
+		return rows.Err()
+	})
+	return issuers, err
+}
+
+// GetIssuer returns a single issuer by DID
+func (p *Postgres) GetIssuer(ctx context.Context, did string) (models.Issuer, error) {
+	var iss models.Issuer
+	err := p.read(ctx, func(pool *pgxpool.Pool) error {
+		return pool.QueryRow(ctx, getIssuerSQL, did).
+			Scan(&iss.DID, &iss.PublicKey, &iss.Enabled, &iss.TrustTier, &iss.KeyPinning, &iss.CreatedAt, &iss.UpdatedAt)
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return iss, ErrNotFound
+	}
+	return iss, err
+}
+
+// UpsertIssuer creates or updates an issuer, rejecting invalid ones with
+// validate.ErrInvalidIssuer
+func (p *Postgres) UpsertIssuer(ctx context.Context, iss models.Issuer) error {
+	if err := validate.ValidateIssuer(iss); err != nil {
+		return err
+	}
+	_, err := p.primary.Exec(ctx, upsertIssuerSQL, iss.DID, iss.PublicKey, iss.Enabled, iss.TrustTier, iss.KeyPinning)
+	return err
+}
+
+// ListAdmins returns all admin identities
+func (p *Postgres) ListAdmins(ctx context.Context) ([]models.Admin, error) {
+	var admins []models.Admin
+	err := p.read(ctx, func(pool *pgxpool.Pool) error {
+		rows, err := pool.Query(ctx, listAdminsSQL)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		admins = admins[:0]
+		for rows.Next() {
+			var a models.Admin
+			if err := rows.Scan(&a.DID, &a.Role, &a.Enabled, &a.CreatedAt, &a.UpdatedAt); err != nil {
+				return err
+			}
+			admins = append(admins, a)
+		}
+		return rows.Err()
+	})
+	return admins, err
+}
+
+// GetAdmin returns an admin identity by DID
+func (p *Postgres) GetAdmin(ctx context.Context, did string) (models.Admin, error) {
+	var a models.Admin
+	err := p.read(ctx, func(pool *pgxpool.Pool) error {
+		return pool.QueryRow(ctx, getAdminSQL, did).Scan(&a.DID, &a.Role, &a.Enabled, &a.CreatedAt, &a.UpdatedAt)
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return a, ErrNotFound
+	}
+	return a, err
+}
+
+// UpsertAdmin creates or updates an admin identity
+func (p *Postgres) UpsertAdmin(ctx context.Context, a models.Admin) error {
+	_, err := p.primary.Exec(ctx, upsertAdminSQL, a.DID, a.Role, a.Enabled)
+	return err
+}
+
+// DeleteAdmin removes an admin identity
+func (p *Postgres) DeleteAdmin(ctx context.Context, did string) error {
+	tag, err := p.primary.Exec(ctx, deleteAdminSQL, did)
+	if err == nil && tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return err
+}
+
+// GetAccount returns an account by ID
+func (p *Postgres) GetAccount(ctx context.Context, id string) (models.Account, error) {
+	return p.getAccount(ctx, getAccountSQL, id)
+}
+
+// GetAccountByDID returns the account bound to did
+func (p *Postgres) GetAccountByDID(ctx context.Context, did string) (models.Account, error) {
+	return p.getAccount(ctx, getAccountByDIDSQL, did)
+}
+
+// FindAccountByContact returns the account with the given email address
+// (case-insensitive) or phone number; channel is "email" or "sms"
+func (p *Postgres) FindAccountByContact(ctx context.Context, channel, address string) (models.Account, error) {
+	switch channel {
+	case "email":
+		return p.getAccount(ctx, getAccountByEmailSQL, address)
+	case "sms":
+		return p.getAccount(ctx, getAccountByPhoneSQL, address)
+	}
+	return models.Account{}, ErrNotFound
+}
+
+func (p *Postgres) getAccount(ctx context.Context, query, arg string) (models.Account, error) {
+	var a models.Account
+	err := p.read(ctx, func(pool *pgxpool.Pool) error {
+		return pool.QueryRow(ctx, query, arg).Scan(&a.ID, &a.DID, &a.PreviousDID, &a.Email, &a.Phone, &a.CreatedAt, &a.UpdatedAt)
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return a, ErrNotFound
+	}
+	return a, err
+}
+
+// UpsertAccount creates or updates an account
+func (p *Postgres) UpsertAccount(ctx context.Context, a models.Account) error {
+	_, err := p.primary.Exec(ctx, upsertAccountSQL, a.ID, a.DID, a.Email, a.Phone)
+	return err
+}
+
+// RebindAccountDID moves an account from oldDID to newDID, keeping oldDID as
+// its previous DID, and moves oldDID's account link if it has one. It
+// returns ErrNotFound unless the account is still bound to oldDID.
+func (p *Postgres) RebindAccountDID(ctx context.Context, id, oldDID, newDID string) error {
+	tag, err := p.primary.Exec(ctx, rebindAccountDIDSQL, id, oldDID, newDID)
+	if err == nil && tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return err
+}
+
+// GetAccountLink returns the account link of did
+func (p *Postgres) GetAccountLink(ctx context.Context, did string) (models.AccountLink, error) {
+	var l models.AccountLink
+	err := p.read(ctx, func(pool *pgxpool.Pool) error {
+		return pool.QueryRow(ctx, getAccountLinkSQL, did).Scan(&l.DID, &l.AccountID, &l.LinkedBy, &l.CreatedAt, &l.UpdatedAt)
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return l, ErrNotFound
+	}
+	return l, err
+}
+
+// ListAccountLinks returns the DIDs linked to an account, oldest first
+func (p *Postgres) ListAccountLinks(ctx context.Context, accountID string) ([]models.AccountLink, error) {
+	var links []models.AccountLink
+	err := p.read(ctx, func(pool *pgxpool.Pool) error {
+		rows, err := pool.Query(ctx, listAccountLinksSQL, accountID)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		links = links[:0]
+		for rows.Next() {
+			var l models.AccountLink
+			if err := rows.Scan(&l.DID, &l.AccountID, &l.LinkedBy, &l.CreatedAt, &l.UpdatedAt); err != nil {
+				return err
+			}
+			links = append(links, l)
+		}
+		return rows.Err()
+	})
+	return links, err
+}
+
+// UpsertAccountLink links a DID to an account, moving it if it was linked
+// to another one
+func (p *Postgres) UpsertAccountLink(ctx context.Context, l models.AccountLink) error {
+	_, err := p.primary.Exec(ctx, upsertAccountLinkSQL, l.DID, l.AccountID, l.LinkedBy)
+	return err
+}
+
+// DeleteAccountLink unlinks a DID
+func (p *Postgres) DeleteAccountLink(ctx context.Context, did string) error {
+	tag, err := p.primary.Exec(ctx, deleteAccountLinkSQL, did)
+	if err == nil && tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return err
+}
+
+func scanDevice(row pgx.Row, d *models.Device) error {
+	return row.Scan(&d.ID, &d.DID, &d.KeyID, &d.Label, &d.Source, &d.CreatedAt, &d.LastSeenAt, &d.RevokedAt)
+}
+
+// ListDevices returns the devices of did, including revoked ones, oldest first
+func (p *Postgres) ListDevices(ctx context.Context, did string) ([]models.Device, error) {
+	var devices []models.Device
+	err := p.read(ctx, func(pool *pgxpool.Pool) error {
+		rows, err := pool.Query(ctx, listDevicesSQL, did)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		devices = devices[:0]
+		for rows.Next() {
+			var d models.Device
+			if err := scanDevice(rows, &d); err != nil {
+				return err
+			}
+			devices = append(devices, d)
+		}
+		return rows.Err()
+	})
+	return devices, err
+}
+
+// GetDevice returns a device of did by ID
+func (p *Postgres) GetDevice(ctx context.Context, did, id string) (models.Device, error) {
+	return p.getDevice(ctx, getDeviceSQL, did, id)
+}
+
+// GetDeviceByKey returns the device of did with the given key ID
+func (p *Postgres) GetDeviceByKey(ctx context.Context, did, keyID string) (models.Device, error) {
+	return p.getDevice(ctx, getDeviceByKeySQL, did, keyID)
+}
+
+func (p *Postgres) getDevice(ctx context.Context, query, did, arg string) (models.Device, error) {
+	var d models.Device
+	err := p.read(ctx, func(pool *pgxpool.Pool) error {
+		return scanDevice(pool.QueryRow(ctx, query, did, arg), &d)
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return d, ErrNotFound
+	}
+	return d, err
+}
+
+// CreateDevice inserts a device. It reports false, inserting nothing, when
+// the DID already has a device with the same key ID.
+func (p *Postgres) CreateDevice(ctx context.Context, d models.Device) (bool, error) {
+	tag, err := p.primary.Exec(ctx, insertDeviceSQL, d.ID, d.DID, d.KeyID, d.Label, d.Source)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+// TouchDevice records that a device was just used
+func (p *Postgres) TouchDevice(ctx context.Context, id string) error {
+	_, err := p.primary.Exec(ctx, touchDeviceSQL, id)
+	return err
+}
+
+// LabelDevice sets a device's label; an empty label clears it
+func (p *Postgres) LabelDevice(ctx context.Context, did, id, label string) error {
+	tag, err := p.primary.Exec(ctx, labelDeviceSQL, did, id, label)
+	if err == nil && tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return err
+}
+
+// RevokeDevice revokes a device; it returns ErrNotFound if the device does
+// not exist or is already revoked
+func (p *Postgres) RevokeDevice(ctx context.Context, did, id string) error {
+	tag, err := p.primary.Exec(ctx, revokeDeviceSQL, did, id)
+	if err == nil && tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return err
+}
+
+// ListAlertEndpoints returns the login alert endpoints of did
+func (p *Postgres) ListAlertEndpoints(ctx context.Context, did string) ([]models.AlertEndpoint, error) {
+	var endpoints []models.AlertEndpoint
+	err := p.read(ctx, func(pool *pgxpool.Pool) error {
+		rows, err := pool.Query(ctx, listAlertEndpointsSQL, did)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		endpoints = endpoints[:0]
+		for rows.Next() {
+			var e models.AlertEndpoint
+			if err := rows.Scan(&e.ID, &e.DID, &e.Channel, &e.Target, &e.CreatedAt); err != nil {
+				return err
+			}
+			endpoints = append(endpoints, e)
+		}
+		return rows.Err()
+	})
+	return endpoints, err
+}
+
+// InsertAlertEndpoint registers a login alert endpoint
+func (p *Postgres) InsertAlertEndpoint(ctx context.Context, e models.AlertEndpoint) error {
+	_, err := p.primary.Exec(ctx, insertAlertEndpointSQL, e.ID, e.DID, e.Channel, e.Target)
+	return err
+}
+
+// DeleteAlertEndpoint removes a login alert endpoint of did
+func (p *Postgres) DeleteAlertEndpoint(ctx context.Context, did, id string) error {
+	tag, err := p.primary.Exec(ctx, deleteAlertEndpointSQL, did, id)
+	if err == nil && tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return err
+}
+
+func scanAPIKey(row pgx.Row, k *models.APIKey) error {
+	return row.Scan(&k.ID, &k.Name, &k.Hash, &k.PreviousHash, &k.PreviousExpiresAt, &k.Scopes,
+		&k.ExpiresAt, &k.Revoked, &k.CreatedAt, &k.UpdatedAt)
+}
+
+// ListAPIKeys returns all API keys, including revoked ones
+func (p *Postgres) ListAPIKeys(ctx context.Context) ([]models.APIKey, error) {
+	var keys []models.APIKey
+	err := p.read(ctx, func(pool *pgxpool.Pool) error {
+		rows, err := pool.Query(ctx, listAPIKeysSQL)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		keys = keys[:0]
+		for rows.Next() {
+			var k models.APIKey
+			if err := scanAPIKey(rows, &k); err != nil {
+				return err
+			}
+			keys = append(keys, k)
+		}
+		return rows.Err()
+	})
+	return keys, err
+}
+
+// GetAPIKey returns an API key by ID. It reads from the primary: callers
+// cache the result, and a lagging replica could return a key that was just
+// revoked or miss one that was just created.
+func (p *Postgres) GetAPIKey(ctx context.Context, id string) (models.APIKey, error) {
+	var k models.APIKey
+	err := scanAPIKey(p.primary.QueryRow(ctx, getAPIKeySQL, id), &k)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return k, ErrNotFound
+	}
+	return k, err
+}
+
+// InsertAPIKey stores a new API key
+func (p *Postgres) InsertAPIKey(ctx context.Context, k models.APIKey) error {
+	_, err := p.primary.Exec(ctx, insertAPIKeySQL, k.ID, k.Name, k.Hash, k.Scopes, k.ExpiresAt)
+	return err
+}
+
+// RotateAPIKey replaces a key's secret hash, keeping the previous one valid
+// until previousExpiresAt
+func (p *Postgres) RotateAPIKey(ctx context.Context, id, hash string, previousExpiresAt time.Time) error {
+	tag, err := p.primary.Exec(ctx, rotateAPIKeySQL, id, hash, previousExpiresAt)
+	if err == nil && tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return err
+}
+
+// RevokeAPIKey permanently disables an API key
+func (p *Postgres) RevokeAPIKey(ctx context.Context, id string) error {
+	tag, err := p.primary.Exec(ctx, revokeAPIKeySQL, id)
+	if err == nil && tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return err
+}
+
+// GetRevocationList returns a revocation list by ID
+func (p *Postgres) GetRevocationList(ctx context.Context, listID string) (models.RevocationList, error) {
+	var list models.RevocationList
+	err := p.read(ctx, func(pool *pgxpool.Pool) error {
+		return pool.QueryRow(ctx, getRevocationListSQL, listID).Scan(&list.ListID, &list.Revoked, &list.UpdatedAt, &list.Owner, &list.Seq)
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return list, ErrNotFound
+	}
+	return list, err
+}
+
+// UpsertRevocationList replaces a revocation list. Deltas recorded before
+// the replace no longer apply and are dropped; consumers behind it get the
+// full list from GetRevocationChanges.
+func (p *Postgres) UpsertRevocationList(ctx context.Context, list models.RevocationList) error {
+	tx, err := p.primary.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+	if _, err := tx.Exec(ctx, upsertRevocationListSQL, list.ListID, list.Revoked, list.UpdatedAt, list.Owner); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, pruneRevocationDeltasSQL, list.ListID); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// ApplyRevocationDelta adds and removes jtis on an existing list and records
+// the change for delta consumers. Removals win over additions.
+func (p *Postgres) ApplyRevocationDelta(ctx context.Context, listID string, added, removed []string) (models.RevocationDelta, error) {
+	delta := models.RevocationDelta{ListID: listID, Added: added, Removed: removed}
+	tx, err := p.primary.Begin(ctx)
+	if err != nil {
+		return delta, err
+	}
+	defer tx.Rollback(ctx)
+	err = tx.QueryRow(ctx, applyRevocationDeltaSQL, listID, nonNil(added), nonNil(removed)).Scan(&delta.Seq)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return delta, ErrNotFound
+	}
+	if err != nil {
+		return delta, err
+	}
+	if _, err := tx.Exec(ctx, insertRevocationDeltaSQL, listID, delta.Seq, nonNil(added), nonNil(removed)); err != nil {
+		return delta, err
+	}
+	return delta, tx.Commit(ctx)
+}
+
+// GetRevocationChanges returns up to limit deltas after since, or the full
+// list when since predates the last full replace
+func (p *Postgres) GetRevocationChanges(ctx context.Context, listID string, since int64, limit int) (models.RevocationChanges, error) {
+	changes := models.RevocationChanges{ListID: listID}
+	err := p.read(ctx, func(pool *pgxpool.Pool) error {
+		var (
+			revoked []string
+			base    int64
+		)
+		if err := pool.QueryRow(ctx, getRevocationBaseSQL, listID).Scan(&revoked, &changes.Seq, &base); err != nil {
+			return err
+		}
+		if since < base || since > changes.Seq {
+			changes.Full, changes.Revoked = true, revoked
+			return nil
+		}
+		rows, err := pool.Query(ctx, listRevocationDeltasSQL, listID, since, limit)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			d := models.RevocationDelta{ListID: listID}
+			if err := rows.Scan(&d.Seq, &d.Added, &d.Removed); err != nil {
+				return err
+			}
+			changes.Deltas = append(changes.Deltas, d)
+		}
+		return rows.Err()
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return changes, ErrNotFound
+	}
+	return changes, err
+}
+
+func nonNil(s []string) []string {
+	if s == nil {
+		return []string{}
+	}
+	return s
+}
+
+// UpsertQuotaUsage records usage counters flushed from Redis. Counts only
+// grow, so a late flush from a lagging replica cannot lower a stored value.
+func (p *Postgres) UpsertQuotaUsage(ctx context.Context, usage []models.QuotaUsage) error {
+	batch := &pgx.Batch{}
+	for _, u := range usage {
+		batch.Queue(upsertQuotaUsageSQL, u.Scope, u.Subject, u.PolicyID, u.Period, u.Count)
+	}
+	return p.primary.SendBatch(ctx, batch).Close()
+}
+
+// ListQuotaUsage returns usage for a subject with period in [from, to].
+// Periods compare as strings, so from=2024-01 to=2024-01-31 covers the month
+// record and every day in it.
+func (p *Postgres) ListQuotaUsage(ctx context.Context, scope, subject, from, to string) ([]models.QuotaUsage, error) {
+	var usage []models.QuotaUsage
+	err := p.read(ctx, func(pool *pgxpool.Pool) error {
+		rows, err := pool.Query(ctx, listQuotaUsageSQL, scope, subject, from, to)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		usage = usage[:0]
+		for rows.Next() {
+			var u models.QuotaUsage
+			if err := rows.Scan(&u.Scope, &u.Subject, &u.PolicyID, &u.Period, &u.Count, &u.UpdatedAt); err != nil {
+				return err
+			}
+			usage = append(usage, u)
+		}
+		return rows.Err()
+	})
+	return usage, err
+}
+
+// InsertMeteringRecords stores metering records. Records already present by
+// ID are skipped, so a retried batch is not double-billed.
+func (p *Postgres) InsertMeteringRecords(ctx context.Context, records []models.MeteringRecord) error {
+	batch := &pgx.Batch{}
+	for _, r := range records {
+		batch.Queue(insertMeteringRecordSQL, r.ID, r.DID, r.Route, r.PolicyID,
+			r.WindowStart, r.WindowEnd, r.Requests, r.Errors, r.BytesIn, r.BytesOut, r.Tenant)
+	}
+	return p.primary.SendBatch(ctx, batch).Close()
+}
+
+// SummarizeTenantUsage sums metering records per tenant for windows starting
+// in [from, to), busiest first. Records without a tenant are summed under "".
+func (p *Postgres) SummarizeTenantUsage(ctx context.Context, from, to time.Time) ([]models.TenantUsage, error) {
+	return p.tenantUsage(ctx, summarizeTenantUsageSQL, from, to)
+}
+
+// TenantUsageByRoute breaks one tenant's usage down by route and policy
+func (p *Postgres) TenantUsageByRoute(ctx context.Context, tenant string, from, to time.Time) ([]models.TenantUsage, error) {
+	return p.tenantUsage(ctx, tenantUsageByRouteSQL, tenant, from, to)
+}
+
+func (p *Postgres) tenantUsage(ctx context.Context, sql string, args ...any) ([]models.TenantUsage, error) {
+	var usage []models.TenantUsage
+	err := p.read(ctx, func(pool *pgxpool.Pool) error {
+		usage = nil
+		rows, err := pool.Query(ctx, sql, args...)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var u models.TenantUsage
+			if err := rows.Scan(&u.Tenant, &u.Route, &u.PolicyID, &u.Requests, &u.Errors, &u.BytesIn, &u.BytesOut, &u.DIDs); err != nil {
+				return err
+			}
+			usage = append(usage, u)
+		}
+		return rows.Err()
+	})
+	return usage, err
+}
+
+// InsertAuditEvent appends an event to the audit trail. The tenant defaults
+// to the one on ctx.
+func (p *Postgres) InsertAuditEvent(ctx context.Context, ev models.AuditEvent) error {
+	if ev.Region == "" {
+		ev.Region = p.region
+	}
+	if ev.Tenant == "" {
+		ev.Tenant = tenant.FromContext(ctx)
+	}
+	_, err := p.primary.Exec(ctx, insertAuditEventSQL, ev.Time, ev.Event, ev.Subject, ev.Actor, ev.Outcome, ev.Metadata, ev.Region, ev.Tenant)
+	return err
+}
+
+// ListAuditEvents returns the most recent audit events, newest first
+func (p *Postgres) ListAuditEvents(ctx context.Context, limit int) ([]models.AuditEvent, error) {
+	var events []models.AuditEvent
+	err := p.read(ctx, func(pool *pgxpool.Pool) error {
+		rows, err := pool.Query(ctx, listAuditEventsSQL, limit)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		events = events[:0]
+		for rows.Next() {
+			var ev models.AuditEvent
+			if err := rows.Scan(&ev.Time, &ev.Event, &ev.Subject, &ev.Actor, &ev.Outcome, &ev.Metadata, &ev.Region, &ev.Tenant); err != nil {
+				return err
+			}
+			events = append(events, ev)
+		}
+		return rows.Err()
+	})
+	return events, err
+}
+
+// auditPartitionBound matches the upper bound of a range partition
+var auditPartitionBound = regexp.MustCompile(`TO \('([^']+)'\)`)
+
+// PurgeAuditEvents deletes audit events older than before. When audit_events
+// is range-partitioned by time, partitions entirely before the cutoff are
+// dropped (their row count is the planner estimate); remaining rows are
+// deleted in batches of batchSize.
+func (p *Postgres) PurgeAuditEvents(ctx context.Context, before time.Time, batchSize int) (int64, error) {
+	rows, err := p.primary.Query(ctx, listAuditPartitionsSQL)
+	if err != nil {
+		return 0, err
+	}
+	type partition struct {
+		name string
+		rows int64
+	}
+	var drop []partition
+	for rows.Next() {
+		var (
+			part  partition
+			bound string
+		)
+		if err := rows.Scan(&part.name, &bound, &part.rows); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		m := auditPartitionBound.FindStringSubmatch(bound)
+		if m == nil {
+			continue // DEFAULT or MAXVALUE partition
+		}
+		upper, err := parsePartitionBound(m[1])
+		if err != nil {
+			continue
+		}
+		if !upper.After(before) {
+			drop = append(drop, part)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	var purged int64
+	for _, part := range drop {
+		if _, err := p.primary.Exec(ctx, "DROP TABLE "+part.name); err != nil {
+			return purged, fmt.Errorf("drop %s: %w", part.name, err)
+		}
+		purged += part.rows
+	}
+	n, err := p.deleteBatches(ctx, purgeAuditEventsSQL, before, batchSize)
+	return purged + n, err
+}
+
+func parsePartitionBound(s string) (time.Time, error) {
+	for _, layout := range []string{"2006-01-02 15:04:05-07", "2006-01-02 15:04:05-07:00", "2006-01-02 15:04:05", "2006-01-02"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unrecognised partition bound %q", s)
+}
+
+// PurgeMeteringRecords deletes metering records whose window ended before
+// the cutoff, in batches of batchSize
+func (p *Postgres) PurgeMeteringRecords(ctx context.Context, before time.Time, batchSize int) (int64, error) {
+	return p.deleteBatches(ctx, purgeMeteringRecordsSQL, before, batchSize)
+}
+
+// PurgeQuotaUsage deletes usage rows for periods before the given one
+// ("2006-01"; earlier daily periods sort before it too), in batches of
+// batchSize
+func (p *Postgres) PurgeQuotaUsage(ctx context.Context, beforePeriod string, batchSize int) (int64, error) {
+	return p.deleteBatches(ctx, purgeQuotaUsageSQL, beforePeriod, batchSize)
+}
+
+// PurgeRevocationDeltas deletes revocation deltas recorded before the
+// cutoff. Consumers that haven't caught up past them get the full list on
+// their next fetch.
+func (p *Postgres) PurgeRevocationDeltas(ctx context.Context, before time.Time) (int64, error) {
+	var n int64
+	err := p.primary.QueryRow(ctx, purgeRevocationDeltasSQL, before).Scan(&n)
+	return n, err
+}
+
+// deleteBatches runs a "DELETE ... LIMIT $2" statement until it deletes
+// fewer than batchSize rows, so large purges don't hold long locks or bloat
+// one transaction
+func (p *Postgres) deleteBatches(ctx context.Context, sql string, cutoff any, batchSize int) (int64, error) {
+	var total int64
+	for {
+		tag, err := p.primary.Exec(ctx, sql, cutoff, batchSize)
+		if err != nil {
+			return total, err
+		}
+		total += tag.RowsAffected()
+		if tag.RowsAffected() < int64(batchSize) {
+			return total, nil
+		}
+		if err := ctx.Err(); err != nil {
+			return total, err
+		}
+	}
+}
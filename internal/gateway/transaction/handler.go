@@ -0,0 +1,144 @@
+package transaction
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/example/privacy-gateway/internal/shared/httpx"
+)
+
+// createResponse is returned to the upstream
+type createResponse struct {
+	ID          string `json:"id"`
+	PollSecret  string `json:"poll_secret"`
+	Challenge   string `json:"challenge"`
+	DetailsHash string `json:"details_hash"`
+	ExpiresAt   int64  `json:"expires_at"`
+}
+
+// walletView is what the wallet shows the user before signing
+type walletView struct {
+	ID          string          `json:"id"`
+	DID         string          `json:"did"`
+	Details     json.RawMessage `json:"details"`
+	DetailsHash string          `json:"details_hash"`
+	Challenge   string          `json:"challenge"`
+	Status      string          `json:"status"`
+	ExpiresAt   int64           `json:"expires_at"`
+}
+
+// statusResponse is what the upstream polls
+type statusResponse struct {
+	Status       string `json:"status"`
+	Confirmation string `json:"confirmation,omitempty"` // Once confirmed
+	ExpiresAt    int64  `json:"expires_at"`
+}
+
+type signRequest struct {
+	KID       string `json:"kid"`
+	Signature string `json:"signature"`
+}
+
+// Handler serves the transaction endpoints:
+//
+//	POST /v1/transactions               upstream: {"did", "details", "ttl_seconds"}
+//	GET  /v1/transactions/{id}          wallet: details and challenge to sign
+//	POST /v1/transactions/{id}/confirm  wallet: {"kid", "signature"} over the challenge
+//	POST /v1/transactions/{id}/decline  wallet: {"kid", "signature"} over the challenge + ":decline"
+//	GET  /v1/transactions/{id}/status   upstream: status and confirmation (X-Poll-Secret)
+//
+// creator authenticates the upstream on create, e.g. by API key, and
+// returns "" to reject it.
+func Handler(svc *Service, creator func(*http.Request) string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rest := strings.Trim(strings.TrimPrefix(r.URL.Path, "/v1/transactions"), "/")
+		parts := strings.Split(rest, "/")
+		w.Header().Set("Cache-Control", "no-store")
+
+		switch {
+		case rest == "" && r.Method == http.MethodPost:
+			who := creator(r)
+			if who == "" {
+				httpx.WriteJSON(w, http.StatusUnauthorized, httpx.ErrorResponse{Error: "unauthorized"})
+				return
+			}
+			var in CreateInput
+			if err := httpx.DecodeJSON(r, &in); err != nil {
+				httpx.WriteJSON(w, http.StatusBadRequest, httpx.ErrorResponse{Error: "invalid request body"})
+				return
+			}
+			t, secret, err := svc.Create(r.Context(), in, who)
+			switch {
+			case errors.Is(err, ErrInvalidRequest):
+				httpx.WriteJSON(w, http.StatusBadRequest, httpx.ErrorResponse{Error: err.Error()})
+			case err != nil:
+				httpx.WriteJSON(w, http.StatusInternalServerError, httpx.ErrorResponse{Error: "failed to create transaction"})
+			default:
+				httpx.WriteJSON(w, http.StatusCreated, createResponse{
+					ID:          t.ID,
+					PollSecret:  secret,
+					Challenge:   t.Challenge(),
+					DetailsHash: t.DetailsHash,
+					ExpiresAt:   t.ExpiresAt.Unix(),
+				})
+			}
+		case len(parts) == 1 && rest != "" && r.Method == http.MethodGet:
+			t, err := svc.Get(r.Context(), parts[0])
+			if err != nil {
+				writeError(w, err)
+				return
+			}
+			httpx.WriteJSON(w, http.StatusOK, walletView{
+				ID:          t.ID,
+				DID:         t.DID,
+				Details:     t.Details,
+				DetailsHash: t.DetailsHash,
+				Challenge:   t.Challenge(),
+				Status:      t.Status,
+				ExpiresAt:   t.ExpiresAt.Unix(),
+			})
+		case len(parts) == 2 && (parts[1] == "confirm" || parts[1] == "decline") && r.Method == http.MethodPost:
+			var req signRequest
+			if err := httpx.DecodeJSON(r, &req); err != nil {
+				httpx.WriteJSON(w, http.StatusBadRequest, httpx.ErrorResponse{Error: "invalid request body"})
+				return
+			}
+			decide := svc.Confirm
+			if parts[1] == "decline" {
+				decide = svc.Decline
+			}
+			t, err := decide(r.Context(), parts[0], req.KID, req.Signature)
+			if err != nil {
+				writeError(w, err)
+				return
+			}
+			httpx.WriteJSON(w, http.StatusOK, map[string]string{"status": t.Status})
+		case len(parts) == 2 && parts[1] == "status" && r.Method == http.MethodGet:
+			t, err := svc.Poll(r.Context(), parts[0], r.Header.Get("X-Poll-Secret"))
+			if err != nil {
+				writeError(w, err)
+				return
+			}
+			httpx.WriteJSON(w, http.StatusOK, statusResponse{Status: t.Status, Confirmation: t.Confirmation, ExpiresAt: t.ExpiresAt.Unix()})
+		default:
+			httpx.WriteJSON(w, http.StatusNotFound, httpx.ErrorResponse{Error: "not found"})
+		}
+	}
+}
+
+// writeError maps service errors; a bad poll secret reads as an unknown
+// transaction
+func writeError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrNotFound), errors.Is(err, ErrBadSecret):
+		httpx.WriteJSON(w, http.StatusNotFound, httpx.ErrorResponse{Error: ErrNotFound.Error()})
+	case errors.Is(err, ErrDecided):
+		httpx.WriteJSON(w, http.StatusConflict, httpx.ErrorResponse{Error: err.Error()})
+	case errors.Is(err, ErrInvalidProof):
+		httpx.WriteJSON(w, http.StatusBadRequest, httpx.ErrorResponse{Error: err.Error()})
+	default:
+		httpx.WriteJSON(w, http.StatusInternalServerError, httpx.ErrorResponse{Error: "transaction failed"})
+	}
+}
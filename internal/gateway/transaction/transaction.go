@@ -0,0 +1,399 @@
+package transaction
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/example/privacy-gateway/internal/gateway/did"
+	"github.com/example/privacy-gateway/internal/shared/audit"
+	"github.com/example/privacy-gateway/internal/shared/clock"
+	"github.com/example/privacy-gateway/internal/shared/crypto"
+	"github.com/example/privacy-gateway/internal/shared/observability"
+	"github.com/example/privacy-gateway/internal/shared/validate"
+)
+
+var (
+	ErrNotFound       = errors.New("transaction not found or expired")
+	ErrBadSecret      = errors.New("invalid poll secret")
+	ErrInvalidRequest = errors.New("invalid transaction request")
+	ErrInvalidProof   = errors.New("invalid transaction signature")
+	ErrDecided        = errors.New("transaction already decided")
+	ErrInvalidConfig  = errors.New("invalid transaction config")
+)
+
+// Transaction status values
+const (
+	StatusPending   = "pending"
+	StatusConfirmed = "confirmed"
+	StatusDeclined  = "declined"
+)
+
+// ConfirmationType is the typ header of confirmation JWTs
+const ConfirmationType = "txn-confirmation+jwt"
+
+// Config configures transaction signing
+type Config struct {
+	DID        string // Gateway DID that signs confirmations
+	KeyID      string // Verification method ID (default DID + "#key-1")
+	SigningKey ed25519.PrivateKey
+	Resolver   did.Resolver  // Resolves the payer DID for its signature (default did:key only)
+	TTL        time.Duration // Default time to confirm (default 5m)
+	MaxTTL     time.Duration // Longest TTL an upstream may ask for (default 30m)
+	MaxDetails int           // Largest details document in bytes (default 16KB)
+	Audit      audit.Sink    // Optional; decisions are always logged
+	Clock      clock.Clock
+	Logger     *slog.Logger
+}
+
+// Transaction is one approval request. The upstream holds the poll secret;
+// the wallet only sees the ID and the details it is asked to sign.
+type Transaction struct {
+	ID           string          `json:"id"`
+	SecretHash   string          `json:"secret_hash"`
+	DID          string          `json:"did"` // Who must approve
+	Details      json.RawMessage `json:"details"`
+	DetailsHash  string          `json:"details_hash"`
+	Status       string          `json:"status"`
+	CreatedBy    string          `json:"created_by,omitempty"`
+	KeyID        string          `json:"kid,omitempty"`
+	Signature    string          `json:"signature,omitempty"` // The wallet's, over the challenge
+	Confirmation string          `json:"confirmation,omitempty"`
+	CreatedAt    time.Time       `json:"created_at"`
+	ExpiresAt    time.Time       `json:"expires_at"`
+}
+
+// Challenge is what the wallet signs to confirm the transaction
+func (t *Transaction) Challenge() string {
+	return "transaction:" + t.ID + ":" + t.DetailsHash
+}
+
+// DeclineMessage is what the wallet signs to decline it
+func (t *Transaction) DeclineMessage() string {
+	return t.Challenge() + ":decline"
+}
+
+// ConfirmationClaims is the payload of a confirmation JWT
+type ConfirmationClaims struct {
+	Issuer      string          `json:"iss"`
+	Subject     string          `json:"sub"` // The DID that approved
+	IssuedAt    int64           `json:"iat"`
+	JWTID       string          `json:"jti"` // The transaction ID
+	Transaction ConfirmedDetail `json:"txn"`
+}
+
+// ConfirmedDetail lets anyone check the wallet's approval without trusting
+// the gateway: Signature verifies over Challenge with key KeyID of the
+// subject, and DetailsHash is the SHA-256 of the canonical Details
+type ConfirmedDetail struct {
+	Details     json.RawMessage `json:"details"`
+	DetailsHash string          `json:"details_hash"`
+	Challenge   string          `json:"challenge"`
+	KeyID       string          `json:"kid"`
+	Signature   string          `json:"signature"`
+	SignedAt    int64           `json:"signed_at"`
+}
+
+// Service runs transaction approvals. An upstream registers the details of
+// a payment or other sensitive operation for a DID; the DID's wallet signs
+// a challenge embedding the hash of those details; the gateway checks the
+// signature and returns a signed confirmation the upstream keeps as
+// non-repudiable proof of approval. Transactions live in Redis, so any
+// replica can serve any step.
+type Service struct {
+	client *redis.Client
+	cfg    Config
+	audit  *audit.Recorder
+}
+
+// NewService creates the transaction service
+func NewService(client *redis.Client, cfg Config) (*Service, error) {
+	if cfg.DID == "" || len(cfg.SigningKey) != ed25519.PrivateKeySize {
+		return nil, fmt.Errorf("%w: DID and signing key are required", ErrInvalidConfig)
+	}
+	if cfg.KeyID == "" {
+		cfg.KeyID = cfg.DID + "#key-1"
+	}
+	if cfg.Resolver == nil {
+		cfg.Resolver = did.KeyResolver{}
+	}
+	if cfg.TTL == 0 {
+		cfg.TTL = 5 * time.Minute
+	}
+	if cfg.MaxTTL == 0 {
+		cfg.MaxTTL = 30 * time.Minute
+	}
+	if cfg.MaxDetails == 0 {
+		cfg.MaxDetails = 16 << 10
+	}
+	cfg.Clock = clock.Or(cfg.Clock)
+	cfg.Logger = observability.Component(cfg.Logger, observability.ComponentAuth)
+	return &Service{client: client, cfg: cfg, audit: audit.NewRecorder(cfg.Audit, cfg.Logger, cfg.Clock)}, nil
+}
+
+func txKey(id string) string { return "txn:" + id }
+
+// CreateInput is what the upstream registers
+type CreateInput struct {
+	DID        string          `json:"did"`
+	Details    json.RawMessage `json:"details"` // A JSON object, e.g. amount, currency and payee
+	TTLSeconds int             `json:"ttl_seconds,omitempty"`
+}
+
+// Create registers a transaction for in.DID to approve and returns it with
+// the poll secret, which is only available here
+func (s *Service) Create(ctx context.Context, in CreateInput, createdBy string) (*Transaction, string, error) {
+	if err := validate.ValidateDID(in.DID); err != nil {
+		return nil, "", fmt.Errorf("%w: %v", ErrInvalidRequest, err)
+	}
+	if len(in.Details) > s.cfg.MaxDetails {
+		return nil, "", fmt.Errorf("%w: details are limited to %d bytes", ErrInvalidRequest, s.cfg.MaxDetails)
+	}
+	details, err := Canonicalize(in.Details)
+	if err != nil {
+		return nil, "", fmt.Errorf("%w: %v", ErrInvalidRequest, err)
+	}
+	ttl := s.cfg.TTL
+	if in.TTLSeconds != 0 {
+		ttl = time.Duration(in.TTLSeconds) * time.Second
+		if err := validate.ValidateTTL(ttl, 30*time.Second, s.cfg.MaxTTL); err != nil {
+			return nil, "", fmt.Errorf("%w: %v", ErrInvalidRequest, err)
+		}
+	}
+	id, err := crypto.RandomToken(16)
+	if err != nil {
+		return nil, "", err
+	}
+	secret, err := crypto.RandomToken(32)
+	if err != nil {
+		return nil, "", err
+	}
+	now := s.cfg.Clock.Now().UTC()
+	t := &Transaction{
+		ID:          id,
+		SecretHash:  crypto.HashSecret(secret),
+		DID:         in.DID,
+		Details:     details,
+		DetailsHash: HashDetails(details),
+		Status:      StatusPending,
+		CreatedBy:   createdBy,
+		CreatedAt:   now,
+		ExpiresAt:   now.Add(ttl),
+	}
+	data, err := json.Marshal(t)
+	if err != nil {
+		return nil, "", err
+	}
+	if err := s.client.Set(ctx, txKey(id), data, ttl).Err(); err != nil {
+		return nil, "", err
+	}
+	return t, secret, nil
+}
+
+// Get returns a transaction; the wallet uses it to show the details
+func (s *Service) Get(ctx context.Context, id string) (*Transaction, error) {
+	data, err := s.client.Get(ctx, txKey(id)).Bytes()
+	if err == redis.Nil {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	var t Transaction
+	if err := json.Unmarshal(data, &t); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// Poll returns a transaction to the upstream that created it, with the
+// confirmation once the wallet has signed
+func (s *Service) Poll(ctx context.Context, id, secret string) (*Transaction, error) {
+	t, err := s.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if subtle.ConstantTimeCompare([]byte(t.SecretHash), []byte(crypto.HashSecret(secret))) != 1 {
+		return nil, ErrBadSecret
+	}
+	return t, nil
+}
+
+// Confirm checks the wallet's signature over the challenge with kid, a
+// verification method of the transaction's DID, and issues the signed
+// confirmation
+func (s *Service) Confirm(ctx context.Context, id, kid, signature string) (*Transaction, error) {
+	return s.decide(ctx, id, kid, signature, true)
+}
+
+// Decline records the wallet's refusal, signed over DeclineMessage
+func (s *Service) Decline(ctx context.Context, id, kid, signature string) (*Transaction, error) {
+	return s.decide(ctx, id, kid, signature, false)
+}
+
+func (s *Service) decide(ctx context.Context, id, kid, signature string, confirm bool) (*Transaction, error) {
+	t, err := s.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if t.Status != StatusPending {
+		return nil, ErrDecided
+	}
+	msg := t.DeclineMessage()
+	if confirm {
+		msg = t.Challenge()
+	}
+	// Resolution can be slow; verify before taking the lock
+	if err := s.verify(ctx, t.DID, kid, msg, signature); err != nil {
+		s.audit.Record(ctx, "transaction.signature_invalid", t.DID, t.DID, "failure", map[string]interface{}{
+			"transaction": t.ID, "details_hash": t.DetailsHash, "error": err.Error(),
+		})
+		return nil, err
+	}
+
+	var out Transaction
+	err = s.update(ctx, id, func(t *Transaction) error {
+		if t.Status != StatusPending {
+			return ErrDecided
+		}
+		t.KeyID, t.Signature = kid, signature
+		t.Status = StatusDeclined
+		if confirm {
+			jwt, err := s.sign(t)
+			if err != nil {
+				return err
+			}
+			t.Status, t.Confirmation = StatusConfirmed, jwt
+		}
+		out = *t
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	event := "transaction.declined"
+	if confirm {
+		event = "transaction.confirmed"
+	}
+	s.audit.Record(ctx, event, out.DID, out.DID, "success", map[string]interface{}{
+		"transaction": out.ID, "details_hash": out.DetailsHash, "kid": kid,
+	})
+	return &out, nil
+}
+
+// verify checks signature over msg with kid, which must belong to holder
+func (s *Service) verify(ctx context.Context, holder, kid, msg, signature string) error {
+	if prefix, _, ok := strings.Cut(kid, "#"); !ok || prefix != holder {
+		return fmt.Errorf("%w: kid must be a verification method of %s", ErrInvalidProof, holder)
+	}
+	doc, err := s.cfg.Resolver.Resolve(ctx, holder, did.ResolveOptions{})
+	if err != nil {
+		return fmt.Errorf("%w: resolve %s: %v", ErrInvalidProof, holder, err)
+	}
+	pub, err := doc.AuthenticationKey(kid)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidProof, err)
+	}
+	if err := crypto.VerifySignature(pub, msg, signature); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidProof, err)
+	}
+	return nil
+}
+
+// sign builds and signs the confirmation JWT
+func (s *Service) sign(t *Transaction) (string, error) {
+	now := s.cfg.Clock.Now()
+	claims := ConfirmationClaims{
+		Issuer:   s.cfg.DID,
+		Subject:  t.DID,
+		IssuedAt: now.Unix(),
+		JWTID:    t.ID,
+		Transaction: ConfirmedDetail{
+			Details:     t.Details,
+			DetailsHash: t.DetailsHash,
+			Challenge:   t.Challenge(),
+			KeyID:       t.KeyID,
+			Signature:   t.Signature,
+			SignedAt:    now.Unix(),
+		},
+	}
+	header, err := json.Marshal(map[string]string{"alg": "EdDSA", "typ": ConfirmationType, "kid": s.cfg.KeyID})
+	if err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	input := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	sig := ed25519.Sign(s.cfg.SigningKey, []byte(input))
+	return input + "." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
+
+// update applies fn to a transaction under optimistic locking (WATCH/MULTI)
+func (s *Service) update(ctx context.Context, id string, fn func(*Transaction) error) error {
+	key := txKey(id)
+	return s.client.Watch(ctx, func(tx *redis.Tx) error {
+		data, err := tx.Get(ctx, key).Bytes()
+		if err == redis.Nil {
+			return ErrNotFound
+		}
+		if err != nil {
+			return err
+		}
+		var t Transaction
+		if err := json.Unmarshal(data, &t); err != nil {
+			return err
+		}
+		if err := fn(&t); err != nil {
+			return err
+		}
+		ttl := t.ExpiresAt.Sub(s.cfg.Clock.Now())
+		if ttl <= 0 {
+			return ErrNotFound
+		}
+		encoded, err := json.Marshal(t)
+		if err != nil {
+			return err
+		}
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.Set(ctx, key, encoded, ttl)
+			return nil
+		})
+		return err
+	}, key)
+}
+
+// Canonicalize re-encodes a JSON object with sorted keys and no
+// insignificant whitespace, escaping <, > and & as encoding/json does so the
+// bytes survive being embedded in other JSON documents unchanged. Numbers
+// keep their original text.
+func Canonicalize(raw json.RawMessage) (json.RawMessage, error) {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	var v map[string]interface{}
+	if err := dec.Decode(&v); err != nil || v == nil {
+		return nil, errors.New("details must be a JSON object")
+	}
+	if dec.More() {
+		return nil, errors.New("details must be a single JSON object")
+	}
+	return json.Marshal(v)
+}
+
+// HashDetails returns the base64url SHA-256 of canonical details
+func HashDetails(canonical []byte) string {
+	sum := sha256.Sum256(canonical)
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
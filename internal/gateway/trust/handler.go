@@ -0,0 +1,47 @@
+package trust
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/example/privacy-gateway/internal/shared/httpx"
+)
+
+// Handler serves the admin trust history endpoints:
+//
+//	GET    /admin/v1/trust/{did}  history and current score
+//	DELETE /admin/v1/trust/{did}  reset the history, e.g. after a DID was abused
+//
+// {did} is path-escaped.
+func Handler(t *Tracker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rest := strings.Trim(strings.TrimPrefix(r.URL.EscapedPath(), "/admin/v1/trust"), "/")
+		did, err := url.PathUnescape(rest)
+		if err != nil || did == "" || strings.Contains(rest, "/") {
+			httpx.WriteJSON(w, http.StatusNotFound, httpx.ErrorResponse{Error: "not found"})
+			return
+		}
+		switch r.Method {
+		case http.MethodGet:
+			h, err := t.Get(r.Context(), did)
+			switch {
+			case errors.Is(err, ErrNotFound):
+				httpx.WriteJSON(w, http.StatusNotFound, httpx.ErrorResponse{Error: err.Error()})
+			case err != nil:
+				httpx.WriteJSON(w, http.StatusInternalServerError, httpx.ErrorResponse{Error: "failed to load trust history"})
+			default:
+				httpx.WriteJSON(w, http.StatusOK, h)
+			}
+		case http.MethodDelete:
+			if err := t.Forget(r.Context(), did); err != nil {
+				httpx.WriteJSON(w, http.StatusInternalServerError, httpx.ErrorResponse{Error: "failed to reset trust history"})
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			httpx.WriteJSON(w, http.StatusMethodNotAllowed, httpx.ErrorResponse{Error: "method not allowed"})
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package trust
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/example/privacy-gateway/internal/shared/models"
+	"github.com/example/privacy-gateway/internal/shared/observability"
+	"github.com/redis/go-redis/v9"
+)
+
+var ErrNotFound = errors.New("no history for DID")
+
+// Score weights; they add up to the maximum score of 100
+const (
+	weightAge       = 50
+	weightAuths     = 30
+	weightDiversity = 20
+)
+
+// Config configures trust scoring. A DID reaches each component's full
+// weight at its maturity threshold.
+type Config struct {
+	MaturityAge       time.Duration // Age since first seen for full weight (default 30d)
+	MaturityAuths     int           // Successful authentications for full weight (default 20)
+	MaturityDiversity int           // Distinct credential issuers plus types for full weight (default 4)
+	Retention         time.Duration // How long history outlives the last authentication (default 180d)
+	Clock             func() time.Time
+	Logger            *slog.Logger
+}
+
+// History is what the gateway has seen of a DID
+type History struct {
+	DID       string    `json:"did"`
+	FirstSeen time.Time `json:"first_seen"`
+	Auths     int64     `json:"auths"`
+	Issuers   int64     `json:"issuers"` // Distinct credential issuers presented
+	VCTypes   int64     `json:"vc_types"`
+	Score     int       `json:"score"`
+}
+
+// Tracker keeps per-DID history in Redis and scores it from 0 (never seen)
+// to 100, so policies can hold brand-new, throwaway DIDs to stricter terms.
+// The score grows with the DID's age, its successful authentications and
+// the diversity of credentials it has presented.
+type Tracker struct {
+	client *redis.Client
+	cfg    Config
+}
+
+// NewTracker creates a tracker
+func NewTracker(client *redis.Client, cfg Config) *Tracker {
+	if cfg.MaturityAge == 0 {
+		cfg.MaturityAge = 30 * 24 * time.Hour
+	}
+	if cfg.MaturityAuths == 0 {
+		cfg.MaturityAuths = 20
+	}
+	if cfg.MaturityDiversity == 0 {
+		cfg.MaturityDiversity = 4
+	}
+	if cfg.Retention == 0 {
+		cfg.Retention = 180 * 24 * time.Hour
+	}
+	if cfg.Clock == nil {
+		cfg.Clock = time.Now
+	}
+	cfg.Logger = observability.Component(cfg.Logger, observability.ComponentAuth)
+	return &Tracker{client: client, cfg: cfg}
+}
+
+func historyKey(did string) string { return "trust:h|" + did }
+func issuersKey(did string) string { return "trust:i|" + did }
+func typesKey(did string) string   { return "trust:t|" + did }
+
+// RecordAuth records a successful authentication of did, with the issuer and
+// types of the credential it presented (if any), and returns the updated
+// history
+func (t *Tracker) RecordAuth(ctx context.Context, did, issuer string, vcTypes []string) (History, error) {
+	now := t.cfg.Clock()
+	var firstSeen *redis.StringCmd
+	var auths *redis.IntCmd
+	var issuers, types *redis.IntCmd
+	_, err := t.client.TxPipelined(ctx, func(p redis.Pipeliner) error {
+		hk, ik, tk := historyKey(did), issuersKey(did), typesKey(did)
+		p.HSetNX(ctx, hk, "first_seen", now.Unix())
+		auths = p.HIncrBy(ctx, hk, "auths", 1)
+		firstSeen = p.HGet(ctx, hk, "first_seen")
+		if issuer != "" {
+			p.SAdd(ctx, ik, issuer)
+		}
+		if len(vcTypes) > 0 {
+			members := make([]interface{}, len(vcTypes))
+			for i, typ := range vcTypes {
+				members[i] = typ
+			}
+			p.SAdd(ctx, tk, members...)
+		}
+		issuers = p.SCard(ctx, ik)
+		types = p.SCard(ctx, tk)
+		for _, k := range []string{hk, ik, tk} {
+			p.Expire(ctx, k, t.cfg.Retention)
+		}
+		return nil
+	})
+	if err != nil {
+		return History{}, fmt.Errorf("record trust history: %w", err)
+	}
+	first, _ := strconv.ParseInt(firstSeen.Val(), 10, 64)
+	h := History{DID: did, FirstSeen: time.Unix(first, 0).UTC(), Auths: auths.Val(), Issuers: issuers.Val(), VCTypes: types.Val()}
+	h.Score = t.score(h, now)
+	return h, nil
+}
+
+// Get returns did's history without recording anything; ErrNotFound if the
+// DID has not authenticated within the retention period
+func (t *Tracker) Get(ctx context.Context, did string) (History, error) {
+	var fields *redis.MapStringStringCmd
+	var issuers, types *redis.IntCmd
+	_, err := t.client.Pipelined(ctx, func(p redis.Pipeliner) error {
+		fields = p.HGetAll(ctx, historyKey(did))
+		issuers = p.SCard(ctx, issuersKey(did))
+		types = p.SCard(ctx, typesKey(did))
+		return nil
+	})
+	if err != nil {
+		return History{}, fmt.Errorf("load trust history: %w", err)
+	}
+	vals := fields.Val()
+	if len(vals) == 0 {
+		return History{}, ErrNotFound
+	}
+	first, _ := strconv.ParseInt(vals["first_seen"], 10, 64)
+	auths, _ := strconv.ParseInt(vals["auths"], 10, 64)
+	h := History{DID: did, FirstSeen: time.Unix(first, 0).UTC(), Auths: auths, Issuers: issuers.Val(), VCTypes: types.Val()}
+	h.Score = t.score(h, t.cfg.Clock())
+	return h, nil
+}
+
+// Forget deletes did's history, resetting its score to 0
+func (t *Tracker) Forget(ctx context.Context, did string) error {
+	return t.client.Del(ctx, historyKey(did), issuersKey(did), typesKey(did)).Err()
+}
+
+// Enrich records the authentication of the claims' subject and sets
+// claims.TrustScore. Token minting calls it after the subject has proven
+// control of the DID; API key tokens should not be enriched. If Redis is
+// unavailable the score is left at 0, the most restrictive value, rather
+// than failing the login.
+func (t *Tracker) Enrich(ctx context.Context, claims *models.AccessTokenClaims) {
+	h, err := t.RecordAuth(ctx, claims.Subject, claims.VCIssuer, claims.VCTypes)
+	if err != nil {
+		t.cfg.Logger.Warn("trust score unavailable", "subject", claims.Subject, "error", err)
+		claims.TrustScore = 0
+		return
+	}
+	claims.TrustScore = h.Score
+}
+
+// score weighs each component by how close it is to maturity
+func (t *Tracker) score(h History, now time.Time) int {
+	age := now.Sub(h.FirstSeen)
+	if age < 0 {
+		age = 0
+	}
+	s := weightAge*math.Min(float64(age)/float64(t.cfg.MaturityAge), 1) +
+		weightAuths*math.Min(float64(h.Auths)/float64(t.cfg.MaturityAuths), 1) +
+		weightDiversity*math.Min(float64(h.Issuers+h.VCTypes)/float64(t.cfg.MaturityDiversity), 1)
+	return int(math.Round(s))
+}
@@ -0,0 +1,94 @@
+package webhook
+
+import (
+	"sync"
+	"time"
+
+	"github.com/example/privacy-gateway/internal/shared/cache"
+	"github.com/example/privacy-gateway/internal/shared/clock"
+)
+
+// DefaultMaxTrackedDIDs bounds the DIDs a FailureTracker keeps windows for.
+// DIDs are caller-supplied, so the least recently failing ones are dropped
+// rather than letting a flood of made-up DIDs grow memory.
+const DefaultMaxTrackedDIDs = 10000
+
+// FailureTracker publishes EventAuthFailures when a DID fails authentication
+// Threshold times within Window. It fires once per window per DID.
+type FailureTracker struct {
+	dispatcher *Dispatcher
+	threshold  int
+	window     time.Duration
+	clock      clock.Clock
+
+	mu      sync.Mutex
+	windows *cache.LRU[*failureWindow]
+}
+
+type failureWindow struct {
+	count int
+	fired bool
+}
+
+// NewFailureTracker creates a tracker publishing through dispatcher. It
+// tracks at most maxTracked DIDs (default DefaultMaxTrackedDIDs); clk
+// defaults to clock.Real.
+func NewFailureTracker(dispatcher *Dispatcher, threshold int, window time.Duration, maxTracked int, clk clock.Clock) *FailureTracker {
+	if threshold == 0 {
+		threshold = 5
+	}
+	if window == 0 {
+		window = 5 * time.Minute
+	}
+	if maxTracked == 0 {
+		maxTracked = DefaultMaxTrackedDIDs
+	}
+	clk = clock.Or(clk)
+	return &FailureTracker{
+		dispatcher: dispatcher,
+		threshold:  threshold,
+		window:     window,
+		clock:      clk,
+		windows:    cache.NewLRU[*failureWindow](maxTracked, clk),
+	}
+}
+
+// RecordFailure counts an authentication failure for did
+func (f *FailureTracker) RecordFailure(did, reason string) {
+	f.mu.Lock()
+	// A window expires Window after its first failure
+	w, ok := f.windows.Get(did)
+	if !ok {
+		w = &failureWindow{}
+		f.windows.Set(did, w, f.window)
+	}
+	w.count++
+	fire := w.count >= f.threshold && !w.fired
+	if fire {
+		w.fired = true
+	}
+	count := w.count
+	f.mu.Unlock()
+
+	if fire {
+		_ = f.dispatcher.Publish(EventAuthFailures, map[string]interface{}{
+			"did":            did,
+			"failures":       count,
+			"window_seconds": int(f.window.Seconds()),
+			"last_reason":    reason,
+		})
+	}
+}
+
+// RecordSuccess clears the failure window for did
+func (f *FailureTracker) RecordSuccess(did string) {
+	f.mu.Lock()
+	f.windows.Delete(did)
+	f.mu.Unlock()
+}
+
+// Prune drops expired windows. Memory is bounded without it; calling it
+// periodically frees windows sooner.
+func (f *FailureTracker) Prune() {
+	f.windows.Prune()
+}
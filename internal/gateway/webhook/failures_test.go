@@ -0,0 +1,42 @@
+package webhook
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/example/privacy-gateway/internal/shared/clock"
+)
+
+// TestFailureTrackerBounded checks that windows for caller-supplied DIDs
+// are capped and expire on the injected clock
+func TestFailureTrackerBounded(t *testing.T) {
+	d := NewDispatcher(Config{}, nil, nil)
+	d.SetSubscriptions([]Subscription{{ID: "s1", URL: "https://hooks.example.com", Events: []string{EventAuthFailures}}})
+	clk := clock.NewFake(time.Unix(1700000000, 0))
+	f := NewFailureTracker(d, 2, time.Minute, 3, clk)
+
+	for i := 0; i < 100; i++ {
+		f.RecordFailure("did:example:"+strconv.Itoa(i), "bad_signature")
+	}
+	if n := f.windows.Len(); n != 3 {
+		t.Fatalf("tracked %d DIDs, want 3", n)
+	}
+
+	f.RecordFailure("did:example:victim", "bad_signature")
+	clk.Advance(2 * time.Minute)
+	f.RecordFailure("did:example:victim", "bad_signature")
+	if len(d.queue) != 0 {
+		t.Fatal("failures in different windows fired an event")
+	}
+	f.RecordFailure("did:example:victim", "bad_signature")
+	if len(d.queue) != 1 {
+		t.Fatalf("queued %d events, want 1", len(d.queue))
+	}
+
+	clk.Advance(2 * time.Minute)
+	f.Prune()
+	if n := f.windows.Len(); n != 0 {
+		t.Fatalf("%d windows left after prune, want 0", n)
+	}
+}
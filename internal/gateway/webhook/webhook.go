@@ -0,0 +1,262 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/example/privacy-gateway/internal/shared/bus"
+	"github.com/example/privacy-gateway/internal/shared/observability"
+	"github.com/example/privacy-gateway/internal/shared/retry"
+)
+
+// Event types
+const (
+	EventTokenIssued    = "token.issued"
+	EventTokenRevoked   = "token.revoked"
+	EventIssuerUpdated  = "issuer.updated"
+	EventAuthFailures   = "auth.repeated_failures"
+	EventHealthChanged  = "health.changed" // See health.Notifier
+	EventDeviceAdded    = "device.added"
+	EventDeviceRevoked  = "device.revoked"
+	SignatureHeader     = "X-Gateway-Signature"
+	EventTypeHeader     = "X-Gateway-Event"
+	DeliveryIDHeader    = "X-Gateway-Delivery"
+	signatureVersionTag = "v1"
+)
+
+var ErrQueueFull = errors.New("webhook queue is full")
+
+// Event is the payload delivered to subscribers
+type Event struct {
+	ID   string                 `json:"id"`
+	Type string                 `json:"type"`
+	Time time.Time              `json:"time"`
+	Data map[string]interface{} `json:"data,omitempty"`
+}
+
+// Subscription receives events of the listed types (all types if empty)
+type Subscription struct {
+	ID     string   `json:"id"`
+	URL    string   `json:"url"`
+	Secret string   `json:"-"`
+	Events []string `json:"events,omitempty"`
+}
+
+func (s Subscription) wants(eventType string) bool {
+	if len(s.Events) == 0 {
+		return true
+	}
+	for _, e := range s.Events {
+		if e == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// Config holds dispatcher configuration
+type Config struct {
+	Workers   int           // Concurrent deliveries
+	QueueSize int           // Pending deliveries before Publish drops
+	Timeout   time.Duration // Per delivery attempt
+	Retry     retry.Config
+}
+
+// delivery is one event destined for one subscription
+type delivery struct {
+	sub   Subscription
+	event Event
+	body  []byte
+}
+
+// Dispatcher delivers HMAC-signed events to subscribed endpoints with retries
+type Dispatcher struct {
+	client  *http.Client
+	retry   retry.Config
+	workers int
+	logger  *slog.Logger
+	queue   chan delivery
+
+	mu   sync.RWMutex
+	subs []Subscription
+
+	onDelivered func(eventType string, ok bool) // Metrics callback
+	bus         bus.MessageBus                  // Optional fan-out, see SetBus
+	wg          sync.WaitGroup
+}
+
+// NewDispatcher creates a webhook dispatcher
+func NewDispatcher(cfg Config, logger *slog.Logger, onDelivered func(eventType string, ok bool)) *Dispatcher {
+	if cfg.Workers == 0 {
+		cfg.Workers = 4
+	}
+	if cfg.QueueSize == 0 {
+		cfg.QueueSize = 1000
+	}
+	if cfg.Timeout == 0 {
+		cfg.Timeout = 5 * time.Second
+	}
+	if cfg.Retry.MaxAttempts == 0 {
+		cfg.Retry = retry.DefaultConfig()
+		cfg.Retry.MaxAttempts = 5
+	}
+	if logger != nil {
+		logger = observability.Component(logger, observability.ComponentEvents)
+	}
+	return &Dispatcher{
+		client:      &http.Client{Timeout: cfg.Timeout},
+		retry:       cfg.Retry,
+		workers:     cfg.Workers,
+		logger:      logger,
+		queue:       make(chan delivery, cfg.QueueSize),
+		onDelivered: onDelivered,
+	}
+}
+
+// SetSubscriptions replaces the subscription set
+func (d *Dispatcher) SetSubscriptions(subs []Subscription) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.subs = append([]Subscription(nil), subs...)
+}
+
+// SetBus also publishes every event on the message bus, on subject
+// gateway.webhooks.<type> (e.g. gateway.webhooks.token.issued), for
+// internal consumers that subscribe to the bus instead of registering an
+// endpoint. Call before Start.
+func (d *Dispatcher) SetBus(b bus.MessageBus) {
+	d.bus = b
+}
+
+// Start runs delivery workers until ctx is cancelled
+func (d *Dispatcher) Start(ctx context.Context) {
+	for i := 0; i < d.workers; i++ {
+		d.wg.Add(1)
+		go func() {
+			defer d.wg.Done()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case del := <-d.queue:
+					d.deliver(ctx, del)
+				}
+			}
+		}()
+	}
+}
+
+// Wait blocks until all workers have exited
+func (d *Dispatcher) Wait() {
+	d.wg.Wait()
+}
+
+// Publish enqueues an event for every matching subscription without blocking.
+// Deliveries that don't fit in the queue are dropped and reported.
+func (d *Dispatcher) Publish(eventType string, data map[string]interface{}) error {
+	evt := Event{ID: uuid.NewString(), Type: eventType, Time: time.Now().UTC(), Data: data}
+	body, err := json.Marshal(evt)
+	if err != nil {
+		return err
+	}
+
+	d.mu.RLock()
+	subs := d.subs
+	d.mu.RUnlock()
+
+	if d.bus != nil {
+		go d.fanOut(evt.Type, body)
+	}
+
+	var dropped error
+	for _, sub := range subs {
+		if !sub.wants(eventType) {
+			continue
+		}
+		select {
+		case d.queue <- delivery{sub: sub, event: evt, body: body}:
+		default:
+			dropped = ErrQueueFull
+			d.report(eventType, false)
+		}
+	}
+	return dropped
+}
+
+// fanOut publishes an event body on the message bus
+func (d *Dispatcher) fanOut(eventType string, body []byte) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := d.bus.Publish(ctx, "gateway.webhooks."+eventType, body); err != nil && d.logger != nil {
+		d.logger.Warn("webhook bus publish failed", "event", eventType, "error", err)
+	}
+}
+
+// deliver posts a single delivery with retries
+func (d *Dispatcher) deliver(ctx context.Context, del delivery) {
+	err := retry.WithExponentialBackoffContext(ctx, d.retry, func(ctx context.Context) error {
+		return d.post(ctx, del)
+	})
+	if err != nil && d.logger != nil {
+		d.logger.Warn("webhook delivery failed",
+			"subscription", del.sub.ID, "event", del.event.Type, "delivery", del.event.ID, "error", err)
+	}
+	d.report(del.event.Type, err == nil)
+}
+
+// post performs one delivery attempt
+func (d *Dispatcher) post(ctx context.Context, del delivery) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, del.sub.URL, bytes.NewReader(del.body))
+	if err != nil {
+		return retry.NonRetryable(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventTypeHeader, del.event.Type)
+	req.Header.Set(DeliveryIDHeader, del.event.ID)
+	req.Header.Set(SignatureHeader, Sign(del.sub.Secret, time.Now(), del.body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	switch {
+	case resp.StatusCode < 300:
+		return nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return fmt.Errorf("webhook endpoint returned %d", resp.StatusCode)
+	default:
+		return retry.NonRetryable(fmt.Errorf("webhook endpoint returned %d", resp.StatusCode))
+	}
+}
+
+func (d *Dispatcher) report(eventType string, ok bool) {
+	if d.onDelivered != nil {
+		d.onDelivered(eventType, ok)
+	}
+}
+
+// Sign produces the signature header value: t=<unix>,v1=<hex HMAC-SHA256 of "<unix>.<body>">.
+// Receivers should recompute the MAC and reject stale timestamps to prevent replay.
+func Sign(secret string, t time.Time, body []byte) string {
+	ts := strconv.FormatInt(t.Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(ts))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "t=" + ts + "," + signatureVersionTag + "=" + hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,50 @@
+package audit
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/example/privacy-gateway/internal/shared/clock"
+	"github.com/example/privacy-gateway/internal/shared/models"
+)
+
+// Sink records audit events; store.Postgres and events.AuditStream
+// implement it
+type Sink interface {
+	InsertAuditEvent(ctx context.Context, ev models.AuditEvent) error
+}
+
+// Recorder writes a component's audit events. Every event is logged, then
+// inserted into the sink when one is configured. The insert outlives the
+// caller's context, and a failure is logged rather than returned, so
+// auditing never fails the operation it records.
+type Recorder struct {
+	sink   Sink
+	logger *slog.Logger
+	clock  clock.Clock
+}
+
+// NewRecorder creates a recorder. sink may be nil, in which case events are
+// only logged; clk defaults to clock.Real.
+func NewRecorder(sink Sink, logger *slog.Logger, clk clock.Clock) *Recorder {
+	return &Recorder{sink: sink, logger: logger, clock: clock.Or(clk)}
+}
+
+// Record records one event
+func (r *Recorder) Record(ctx context.Context, event, subject, actor, outcome string, meta map[string]interface{}) {
+	ev := models.AuditEvent{
+		Time:     r.clock.Now().UTC(),
+		Event:    event,
+		Subject:  subject,
+		Actor:    actor,
+		Outcome:  outcome,
+		Metadata: meta,
+	}
+	r.logger.Info("audit", "event", event, "subject", subject, "actor", actor, "outcome", outcome, "metadata", meta)
+	if r.sink == nil {
+		return
+	}
+	if err := r.sink.InsertAuditEvent(context.WithoutCancel(ctx), ev); err != nil {
+		r.logger.Error("failed to record audit event", "event", event, "error", err)
+	}
+}
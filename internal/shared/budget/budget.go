@@ -0,0 +1,123 @@
+package budget
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Stages of the verify path
+const (
+	StageResolution = "resolution" // DID resolution (holder and issuer documents)
+	StageRevocation = "revocation" // Revocation list and status lookups
+	StagePolicy     = "policy"     // Policy lookup and evaluation
+)
+
+var ErrStageTimeout = errors.New("stage exceeded its time budget")
+
+// DefaultStages returns the per-stage caps of the verify path
+func DefaultStages() map[string]time.Duration {
+	return map[string]time.Duration{
+		StageResolution: 2 * time.Second,
+		StageRevocation: 500 * time.Millisecond,
+		StagePolicy:     50 * time.Millisecond,
+	}
+}
+
+// Config configures a request budget
+type Config struct {
+	Total  time.Duration            // Whole request (default 5s)
+	Stages map[string]time.Duration // Per-stage caps (default DefaultStages); stages without one only get what's left of Total
+}
+
+// Budget is the time a request may spend, split into per-stage caps. A
+// stage gets its cap or whatever is left of the total, whichever is less,
+// so one slow dependency can't use up the time the other stages need.
+type Budget struct {
+	cfg      Config
+	deadline time.Time
+
+	mu    sync.Mutex
+	spent map[string]time.Duration
+}
+
+type ctxKey struct{}
+
+// Start attaches a budget to ctx and bounds ctx by its total
+func Start(ctx context.Context, cfg Config) (context.Context, context.CancelFunc) {
+	if cfg.Total == 0 {
+		cfg.Total = 5 * time.Second
+	}
+	if cfg.Stages == nil {
+		cfg.Stages = DefaultStages()
+	}
+	b := &Budget{cfg: cfg, deadline: time.Now().Add(cfg.Total), spent: make(map[string]time.Duration)}
+	if d, ok := ctx.Deadline(); ok && d.Before(b.deadline) {
+		b.deadline = d
+	}
+	ctx, cancel := context.WithDeadline(ctx, b.deadline)
+	return context.WithValue(ctx, ctxKey{}, b), cancel
+}
+
+// FromContext returns the request's budget, or nil
+func FromContext(ctx context.Context) *Budget {
+	b, _ := ctx.Value(ctxKey{}).(*Budget)
+	return b
+}
+
+// Remaining returns the time left of the total
+func (b *Budget) Remaining() time.Duration {
+	return time.Until(b.deadline)
+}
+
+// Spent returns the time spent per stage so far
+func (b *Budget) Spent() map[string]time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	out := make(map[string]time.Duration, len(b.spent))
+	for k, v := range b.spent {
+		out[k] = v
+	}
+	return out
+}
+
+// Run runs fn with stage's deadline. If the stage deadline (rather than the
+// caller's) cut fn short, the error wraps ErrStageTimeout and names the
+// stage. Without a budget in ctx fn runs with ctx unchanged.
+func Run(ctx context.Context, stage string, fn func(ctx context.Context) error) error {
+	b := FromContext(ctx)
+	if b == nil {
+		return fn(ctx)
+	}
+	limit, capped := b.cfg.Stages[stage]
+	stageCtx := ctx
+	if capped {
+		var cancel context.CancelFunc
+		stageCtx, cancel = context.WithTimeout(ctx, limit)
+		defer cancel()
+	}
+
+	start := time.Now()
+	err := fn(stageCtx)
+	elapsed := time.Since(start)
+	b.mu.Lock()
+	b.spent[stage] += elapsed
+	b.mu.Unlock()
+
+	if err != nil && capped && ctx.Err() == nil && errors.Is(stageCtx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("%w: %s after %s: %w", ErrStageTimeout, stage, limit, err)
+	}
+	return err
+}
+
+// Middleware gives every request a budget
+func Middleware(cfg Config, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := Start(r.Context(), cfg)
+		defer cancel()
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
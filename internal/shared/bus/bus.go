@@ -0,0 +1,58 @@
+package bus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var ErrClosed = errors.New("message bus closed")
+
+// Drivers
+const (
+	DriverRedis = "redis"
+	DriverNATS  = "nats"
+)
+
+// Message is a message received on a subject
+type Message struct {
+	Subject string
+	Data    []byte
+}
+
+// Subscription delivers the messages published on one subject
+type Subscription interface {
+	Channel() <-chan Message
+	Close() error
+}
+
+// MessageBus fans messages out to every replica subscribed to a subject.
+// Delivery is at most once: subscribers that are disconnected or too slow
+// miss messages, so consumers use the bus to learn about changes early and
+// still reconcile periodically.
+type MessageBus interface {
+	Publish(ctx context.Context, subject string, data []byte) error
+	// Subscribe returns once the subscription is active, so a message
+	// published after it returns is delivered
+	Subscribe(ctx context.Context, subject string) (Subscription, error)
+	Close() error
+}
+
+// Config selects the message bus
+type Config struct {
+	Driver string // DriverRedis (default) or DriverNATS
+	NATS   NATSConfig
+}
+
+// New creates the configured message bus; the Redis driver uses client
+func New(cfg Config, client *redis.Client) (MessageBus, error) {
+	switch cfg.Driver {
+	case "", DriverRedis:
+		return NewRedis(client), nil
+	case DriverNATS:
+		return NewNATS(cfg.NATS)
+	}
+	return nil, fmt.Errorf("unknown message bus driver %q", cfg.Driver)
+}
@@ -0,0 +1,400 @@
+package bus
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/example/privacy-gateway/internal/shared/observability"
+)
+
+// NATSConfig configures the NATS driver
+type NATSConfig struct {
+	URL           string // nats://[user:pass@]host:4222, or tls://host:4222 for TLS
+	Token         string // Auth token
+	TLS           *tls.Config
+	Timeout       time.Duration // Dial, handshake and flush timeout (default 5s)
+	ReconnectWait time.Duration // Delay between reconnect attempts (default 1s)
+	Logger        *slog.Logger
+}
+
+// NATS is a message bus on NATS core subjects. It speaks the client
+// protocol (CONNECT, PUB, SUB, UNSUB, PING) directly. A dropped connection
+// is re-dialled in the background and subscriptions are restored;
+// messages published meanwhile are missed, as with Redis pub/sub.
+type NATS struct {
+	cfg NATSConfig
+	url *url.URL
+
+	mu      sync.Mutex // Guards everything below and writes to conn
+	conn    net.Conn
+	pongs   []chan error // Flush waiters, in PING order
+	subs    map[int64]*natsSub
+	nextSID int64
+	closed  bool
+}
+
+type natsSub struct {
+	nc      *NATS
+	sid     int64
+	subject string
+	ch      chan Message
+}
+
+// NewNATS creates a NATS bus; it connects on first use
+func NewNATS(cfg NATSConfig) (*NATS, error) {
+	u, err := url.Parse(cfg.URL)
+	if err != nil || u.Host == "" || (u.Scheme != "nats" && u.Scheme != "tls") {
+		return nil, fmt.Errorf("invalid NATS URL %q", cfg.URL)
+	}
+	if u.Port() == "" {
+		u.Host = net.JoinHostPort(u.Hostname(), "4222")
+	}
+	if cfg.Timeout == 0 {
+		cfg.Timeout = 5 * time.Second
+	}
+	if cfg.ReconnectWait == 0 {
+		cfg.ReconnectWait = time.Second
+	}
+	cfg.Logger = observability.Component(cfg.Logger, observability.ComponentEvents)
+	return &NATS{cfg: cfg, url: u, subs: make(map[int64]*natsSub)}, nil
+}
+
+// Publish sends data on subject. It returns once the message is written to
+// the connection; call Flush to wait until the server has processed it.
+func (b *NATS) Publish(ctx context.Context, subject string, data []byte) error {
+	if err := validSubject(subject); err != nil {
+		return err
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err := b.ensure(ctx); err != nil {
+		return err
+	}
+	buf := fmt.Appendf(nil, "PUB %s %d\r\n", subject, len(data))
+	buf = append(append(buf, data...), "\r\n"...)
+	return b.write(buf)
+}
+
+// Flush waits until the server has processed everything written so far
+func (b *NATS) Flush(ctx context.Context) error {
+	b.mu.Lock()
+	if err := b.ensure(ctx); err != nil {
+		b.mu.Unlock()
+		return err
+	}
+	done := make(chan error, 1)
+	b.pongs = append(b.pongs, done)
+	err := b.write([]byte("PING\r\n"))
+	b.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	timer := time.NewTimer(b.cfg.Timeout)
+	defer timer.Stop()
+	select {
+	case err := <-done:
+		return err
+	case <-timer.C:
+		return errors.New("nats: flush timed out")
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Subscribe subscribes to subject, which may use NATS wildcards (* and >)
+func (b *NATS) Subscribe(ctx context.Context, subject string) (Subscription, error) {
+	b.mu.Lock()
+	if err := b.ensure(ctx); err != nil {
+		b.mu.Unlock()
+		return nil, err
+	}
+	b.nextSID++
+	sub := &natsSub{nc: b, sid: b.nextSID, subject: subject, ch: make(chan Message, 256)}
+	b.subs[sub.sid] = sub
+	err := b.write(fmt.Appendf(nil, "SUB %s %d\r\n", subject, sub.sid))
+	b.mu.Unlock()
+	if err == nil {
+		err = b.Flush(ctx)
+	}
+	if err != nil {
+		sub.Close()
+		return nil, err
+	}
+	return sub, nil
+}
+
+// Close closes the connection and every subscription
+func (b *NATS) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+	for sid, sub := range b.subs {
+		delete(b.subs, sid)
+		close(sub.ch)
+	}
+	b.drop(ErrClosed)
+	return nil
+}
+
+func (s *natsSub) Channel() <-chan Message {
+	return s.ch
+}
+
+func (s *natsSub) Close() error {
+	b := s.nc
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.subs[s.sid]; !ok {
+		return nil
+	}
+	delete(b.subs, s.sid)
+	close(s.ch)
+	if b.conn != nil {
+		return b.write(fmt.Appendf(nil, "UNSUB %d\r\n", s.sid))
+	}
+	return nil
+}
+
+// ensure connects if there's no connection; the caller holds mu
+func (b *NATS) ensure(ctx context.Context) error {
+	if b.closed {
+		return ErrClosed
+	}
+	if b.conn != nil {
+		return nil
+	}
+	conn, r, err := b.dial(ctx)
+	if err != nil {
+		return err
+	}
+	b.conn = conn
+	// Restore subscriptions after a reconnect
+	for _, sub := range b.subs {
+		if err := b.write(fmt.Appendf(nil, "SUB %s %d\r\n", sub.subject, sub.sid)); err != nil {
+			return err
+		}
+	}
+	go b.read(conn, r)
+	return nil
+}
+
+// write sends buf on the connection; the caller holds mu
+func (b *NATS) write(buf []byte) error {
+	_ = b.conn.SetWriteDeadline(time.Now().Add(b.cfg.Timeout))
+	if _, err := b.conn.Write(buf); err != nil {
+		b.drop(err)
+		return err
+	}
+	return nil
+}
+
+// drop closes the connection and fails pending flushes; the caller holds mu
+func (b *NATS) drop(err error) {
+	if b.conn != nil {
+		b.conn.Close()
+		b.conn = nil
+	}
+	for _, p := range b.pongs {
+		p <- err
+	}
+	b.pongs = nil
+}
+
+// dial connects and completes the handshake: INFO, optional TLS upgrade,
+// CONNECT, and a PING answered by PONG
+func (b *NATS) dial(ctx context.Context) (net.Conn, *bufio.Reader, error) {
+	dialer := &net.Dialer{Timeout: b.cfg.Timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", b.url.Host)
+	if err != nil {
+		return nil, nil, err
+	}
+	_ = conn.SetDeadline(time.Now().Add(b.cfg.Timeout))
+	r := bufio.NewReader(conn)
+	line, err := r.ReadString('\n')
+	if err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	if !strings.HasPrefix(line, "INFO ") {
+		conn.Close()
+		return nil, nil, fmt.Errorf("unexpected NATS greeting %q", strings.TrimSpace(line))
+	}
+	var info struct {
+		TLSRequired bool `json:"tls_required"`
+	}
+	_ = json.Unmarshal([]byte(line[5:]), &info)
+	if b.url.Scheme == "tls" || info.TLSRequired {
+		cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+		if b.cfg.TLS != nil {
+			cfg = b.cfg.TLS.Clone()
+		}
+		if cfg.ServerName == "" {
+			cfg.ServerName = b.url.Hostname()
+		}
+		tlsConn := tls.Client(conn, cfg)
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return nil, nil, err
+		}
+		conn, r = tlsConn, bufio.NewReader(tlsConn)
+	}
+
+	opts := map[string]interface{}{"verbose": false, "pedantic": false, "name": "did-gateway", "lang": "go", "protocol": 0}
+	if b.cfg.Token != "" {
+		opts["auth_token"] = b.cfg.Token
+	}
+	if u := b.url.User; u != nil {
+		opts["user"] = u.Username()
+		opts["pass"], _ = u.Password()
+	}
+	connect, _ := json.Marshal(opts)
+	if _, err := fmt.Fprintf(conn, "CONNECT %s\r\nPING\r\n", connect); err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			conn.Close()
+			return nil, nil, err
+		}
+		line = strings.TrimSpace(line)
+		if line == "PONG" {
+			break
+		}
+		if strings.HasPrefix(line, "-ERR") {
+			conn.Close()
+			return nil, nil, errors.New("nats: " + strings.TrimSpace(strings.TrimPrefix(line, "-ERR")))
+		}
+	}
+	_ = conn.SetDeadline(time.Time{})
+	return conn, r, nil
+}
+
+// read handles server messages on conn until it fails, then reconnects if
+// there are subscriptions to restore
+func (b *NATS) read(conn net.Conn, r *bufio.Reader) {
+	err := b.readLoop(conn, r)
+
+	b.mu.Lock()
+	current := b.conn == conn
+	if current {
+		b.drop(err)
+	}
+	reconnect := current && !b.closed && len(b.subs) > 0
+	b.mu.Unlock()
+	if !current {
+		return
+	}
+	b.cfg.Logger.Warn("nats connection lost", "error", err)
+	if reconnect {
+		go b.reconnect()
+	}
+}
+
+func (b *NATS) readLoop(conn net.Conn, r *bufio.Reader) error {
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		op, args, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(op) {
+		case "MSG":
+			if err := b.deliver(args, r); err != nil {
+				return err
+			}
+		case "PING":
+			b.mu.Lock()
+			if b.conn == conn {
+				err = b.write([]byte("PONG\r\n"))
+			}
+			b.mu.Unlock()
+			if err != nil {
+				return err
+			}
+		case "PONG":
+			b.mu.Lock()
+			if len(b.pongs) > 0 {
+				b.pongs[0] <- nil
+				b.pongs = b.pongs[1:]
+			}
+			b.mu.Unlock()
+		case "-ERR":
+			b.cfg.Logger.Warn("nats server error", "error", args)
+		}
+		// +OK and INFO updates need no reply
+	}
+}
+
+// deliver reads a MSG payload ("<subject> <sid> [reply-to] <#bytes>") and
+// hands it to its subscription, dropping it if the subscriber is too slow
+func (b *NATS) deliver(args string, r *bufio.Reader) error {
+	f := strings.Fields(args)
+	if len(f) < 3 {
+		return fmt.Errorf("nats: malformed MSG %q", args)
+	}
+	size, err := strconv.Atoi(f[len(f)-1])
+	if err != nil || size < 0 {
+		return fmt.Errorf("nats: malformed MSG %q", args)
+	}
+	payload := make([]byte, size+2) // Trailing CRLF
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return err
+	}
+	sid, _ := strconv.ParseInt(f[1], 10, 64)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	sub, ok := b.subs[sid]
+	if !ok {
+		return nil
+	}
+	select {
+	case sub.ch <- Message{Subject: f[0], Data: payload[:size]}:
+	default:
+		b.cfg.Logger.Warn("nats subscriber too slow; dropping message", "subject", f[0])
+	}
+	return nil
+}
+
+func (b *NATS) reconnect() {
+	for {
+		time.Sleep(b.cfg.ReconnectWait)
+		b.mu.Lock()
+		if b.closed || b.conn != nil {
+			b.mu.Unlock()
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), b.cfg.Timeout)
+		err := b.ensure(ctx)
+		cancel()
+		b.mu.Unlock()
+		if err == nil {
+			b.cfg.Logger.Info("nats reconnected", "subscriptions", len(b.subs))
+			return
+		}
+		b.cfg.Logger.Warn("nats reconnect failed", "error", err)
+	}
+}
+
+func validSubject(subject string) error {
+	if subject == "" || strings.ContainsAny(subject, " \t\r\n*>") {
+		return fmt.Errorf("nats: invalid subject %q", subject)
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package bus
+
+import (
+	"context"
+	"sync"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Redis is a message bus on Redis pub/sub
+type Redis struct {
+	client *redis.Client
+}
+
+// NewRedis creates a Redis message bus; closing it leaves client open
+func NewRedis(client *redis.Client) *Redis {
+	return &Redis{client: client}
+}
+
+// Publish publishes data on the channel named subject
+func (b *Redis) Publish(ctx context.Context, subject string, data []byte) error {
+	return b.client.Publish(ctx, subject, data).Err()
+}
+
+// Subscribe subscribes to the channel named subject
+func (b *Redis) Subscribe(ctx context.Context, subject string) (Subscription, error) {
+	ps := b.client.Subscribe(ctx, subject)
+	if _, err := ps.Receive(ctx); err != nil {
+		ps.Close()
+		return nil, err
+	}
+	sub := &redisSub{ps: ps, ch: make(chan Message, 64), done: make(chan struct{})}
+	go sub.forward()
+	return sub, nil
+}
+
+// Close is a no-op; the Redis client belongs to the caller
+func (b *Redis) Close() error {
+	return nil
+}
+
+type redisSub struct {
+	ps   *redis.PubSub
+	ch   chan Message
+	done chan struct{}
+	once sync.Once
+}
+
+func (s *redisSub) forward() {
+	defer close(s.ch)
+	for msg := range s.ps.Channel() {
+		select {
+		case s.ch <- Message{Subject: msg.Channel, Data: []byte(msg.Payload)}:
+		case <-s.done:
+			return
+		}
+	}
+}
+
+func (s *redisSub) Channel() <-chan Message {
+	return s.ch
+}
+
+func (s *redisSub) Close() error {
+	s.once.Do(func() { close(s.done) })
+	return s.ps.Close()
+}
@@ -0,0 +1,156 @@
+package cache
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+
+	"github.com/ugorji/go/codec"
+	"google.golang.org/protobuf/proto"
+)
+
+var (
+	ErrUnknownCodec = errors.New("unknown cache codec")
+	ErrCodecType    = errors.New("value type not supported by codec")
+)
+
+// Codec serializes cache values. Encoded values are framed with the codec's
+// ID, so readers always decode with the codec a value was written with and
+// the codec chosen for a type can change without invalidating the cache.
+type Codec interface {
+	ID() byte
+	Name() string
+	Marshal(v any) ([]byte, error)
+	Unmarshal(data []byte, v any) error
+}
+
+// codecMagic starts every framed value: magic | codec ID | payload. JSON is
+// written unframed, as before codecs existed, so older replicas can still
+// read it; any value without the frame is JSON.
+var codecMagic = []byte("\x00c")
+
+// Built-in codecs
+var (
+	JSON     Codec = jsonCodec{}
+	Msgpack  Codec = newMsgpackCodec()
+	Protobuf Codec = protobufCodec{}
+)
+
+var codecsByID = map[byte]Codec{
+	JSON.ID():     JSON,
+	Msgpack.ID():  Msgpack,
+	Protobuf.ID(): Protobuf,
+}
+
+// Encode serializes v with c and frames it
+func Encode(c Codec, v any) ([]byte, error) {
+	data, err := c.Marshal(v)
+	if err != nil || c.ID() == JSON.ID() {
+		return data, err
+	}
+	out := make([]byte, 0, len(codecMagic)+1+len(data))
+	out = append(out, codecMagic...)
+	out = append(out, c.ID())
+	return append(out, data...), nil
+}
+
+// Decode deserializes data written by Encode into v, with whichever codec
+// wrote it
+func Decode(data []byte, v any) error {
+	rest, ok := bytes.CutPrefix(data, codecMagic)
+	if !ok {
+		return JSON.Unmarshal(data, v)
+	}
+	if len(rest) == 0 {
+		return fmt.Errorf("%w: empty frame", ErrUnknownCodec)
+	}
+	c, ok := codecsByID[rest[0]]
+	if !ok {
+		return fmt.Errorf("%w: id %d", ErrUnknownCodec, rest[0])
+	}
+	return c.Unmarshal(rest[1:], v)
+}
+
+// codecTable selects a codec per value type (pointers count as their
+// element type); unregistered types use JSON
+type codecTable struct {
+	byType sync.Map // reflect.Type -> Codec
+}
+
+func (t *codecTable) set(sample any, c Codec) {
+	t.byType.Store(baseType(sample), c)
+}
+
+func (t *codecTable) get(v any) Codec {
+	if c, ok := t.byType.Load(baseType(v)); ok {
+		return c.(Codec)
+	}
+	return JSON
+}
+
+func baseType(v any) reflect.Type {
+	t := reflect.TypeOf(v)
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) ID() byte                           { return 'j' }
+func (jsonCodec) Name() string                       { return "json" }
+func (jsonCodec) Marshal(v any) ([]byte, error)      { return json.Marshal(v) }
+func (jsonCodec) Unmarshal(data []byte, v any) error { return json.Unmarshal(data, v) }
+
+// msgpackCodec encodes structs by reflection, honouring json tags (including
+// omitempty), so types written for JSON need no extra annotations
+type msgpackCodec struct {
+	h *codec.MsgpackHandle
+}
+
+func newMsgpackCodec() msgpackCodec {
+	h := &codec.MsgpackHandle{WriteExt: true}
+	h.RawToString = true
+	h.MapType = reflect.TypeOf(map[string]interface{}(nil))
+	h.TypeInfos = codec.NewTypeInfos([]string{"codec", "json"})
+	return msgpackCodec{h: h}
+}
+
+func (msgpackCodec) ID() byte     { return 'm' }
+func (msgpackCodec) Name() string { return "msgpack" }
+
+func (c msgpackCodec) Marshal(v any) ([]byte, error) {
+	var out []byte
+	err := codec.NewEncoderBytes(&out, c.h).Encode(v)
+	return out, err
+}
+
+func (c msgpackCodec) Unmarshal(data []byte, v any) error {
+	return codec.NewDecoderBytes(data, c.h).Decode(v)
+}
+
+// protobufCodec handles generated protobuf messages only
+type protobufCodec struct{}
+
+func (protobufCodec) ID() byte     { return 'p' }
+func (protobufCodec) Name() string { return "protobuf" }
+
+func (protobufCodec) Marshal(v any) ([]byte, error) {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return nil, fmt.Errorf("%w: protobuf needs a proto.Message, got %T", ErrCodecType, v)
+	}
+	return proto.Marshal(m)
+}
+
+func (protobufCodec) Unmarshal(data []byte, v any) error {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return fmt.Errorf("%w: protobuf needs a proto.Message, got %T", ErrCodecType, v)
+	}
+	return proto.Unmarshal(data, m)
+}
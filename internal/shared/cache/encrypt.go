@@ -0,0 +1,135 @@
+package cache
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	ErrInvalidKeyring = errors.New("invalid cache encryption keyring")
+	ErrDecrypt        = errors.New("cache value could not be decrypted")
+)
+
+// encMagic starts every encrypted value: format version 1
+var encMagic = []byte("\x00e1")
+
+// Encryptor seals L2 cache values with AES-256-GCM. Each value carries the
+// ID of the key that sealed it (magic | len(kid) | kid | nonce | ciphertext),
+// so keys can be rotated: new values use the current key, while older keys
+// stay in the keyring until the values they sealed have expired. The Redis
+// key is bound as additional data, so a value can't be moved to another key.
+type Encryptor struct {
+	current string
+	aeads   map[string]cipher.AEAD
+}
+
+// NewEncryptor creates an encryptor from 32-byte keys by ID; current seals
+// new values. Keys usually come from config or are unwrapped from a KMS at
+// startup.
+func NewEncryptor(keys map[string][]byte, current string) (*Encryptor, error) {
+	if _, ok := keys[current]; !ok {
+		return nil, fmt.Errorf("%w: current key %q not in keyring", ErrInvalidKeyring, current)
+	}
+	e := &Encryptor{current: current, aeads: make(map[string]cipher.AEAD, len(keys))}
+	for id, key := range keys {
+		if id == "" || len(id) > 255 {
+			return nil, fmt.Errorf("%w: key IDs must be 1-255 bytes", ErrInvalidKeyring)
+		}
+		if len(key) != 32 {
+			return nil, fmt.Errorf("%w: key %q must be 32 bytes", ErrInvalidKeyring, id)
+		}
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, err
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, err
+		}
+		e.aeads[id] = aead
+	}
+	return e, nil
+}
+
+// ParseKeyring parses "id:base64key,id:base64key" (standard or URL base64)
+// as used in config. The first entry is the current key.
+func ParseKeyring(spec string) (map[string][]byte, string, error) {
+	keys := make(map[string][]byte)
+	current := ""
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		id, enc, ok := strings.Cut(entry, ":")
+		if !ok {
+			return nil, "", fmt.Errorf("%w: entry %q is not id:key", ErrInvalidKeyring, entry)
+		}
+		key, err := base64.StdEncoding.DecodeString(enc)
+		if err != nil {
+			key, err = base64.RawURLEncoding.DecodeString(enc)
+		}
+		if err != nil {
+			return nil, "", fmt.Errorf("%w: key %q is not base64", ErrInvalidKeyring, id)
+		}
+		if _, dup := keys[id]; dup {
+			return nil, "", fmt.Errorf("%w: duplicate key %q", ErrInvalidKeyring, id)
+		}
+		keys[id] = key
+		if current == "" {
+			current = id
+		}
+	}
+	if current == "" {
+		return nil, "", fmt.Errorf("%w: no keys", ErrInvalidKeyring)
+	}
+	return keys, current, nil
+}
+
+// Seal encrypts value for the Redis key with the current key
+func (e *Encryptor) Seal(key string, value []byte) ([]byte, error) {
+	aead := e.aeads[e.current]
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	out := make([]byte, 0, len(encMagic)+1+len(e.current)+len(nonce)+len(value)+aead.Overhead())
+	out = append(out, encMagic...)
+	out = append(out, byte(len(e.current)))
+	out = append(out, e.current...)
+	out = append(out, nonce...)
+	return aead.Seal(out, nonce, value, []byte(key)), nil
+}
+
+// Open decrypts a value sealed for the Redis key. Plaintext values, unknown
+// key IDs and tampered values all fail with ErrDecrypt.
+func (e *Encryptor) Open(key string, data []byte) ([]byte, error) {
+	rest, ok := bytes.CutPrefix(data, encMagic)
+	if !ok || len(rest) < 1 {
+		return nil, fmt.Errorf("%w: not encrypted", ErrDecrypt)
+	}
+	n := int(rest[0])
+	if len(rest) < 1+n {
+		return nil, fmt.Errorf("%w: truncated", ErrDecrypt)
+	}
+	id := string(rest[1 : 1+n])
+	aead, ok := e.aeads[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown key %q", ErrDecrypt, id)
+	}
+	rest = rest[1+n:]
+	if len(rest) < aead.NonceSize() {
+		return nil, fmt.Errorf("%w: truncated", ErrDecrypt)
+	}
+	plain, err := aead.Open(nil, rest[:aead.NonceSize()], rest[aead.NonceSize():], []byte(key))
+	if err != nil {
+		return nil, fmt.Errorf("%w: authentication failed", ErrDecrypt)
+	}
+	return plain, nil
+}
@@ -0,0 +1,40 @@
+package cache
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Namespace builds cache keys for one family of cached values. Every key is
+// prefixed with the namespace and the schema version of the values stored
+// under it ("did:v1:key|did:key:z6Mk..."), so a deploy that changes how
+// values are serialized bumps the version and never reads entries written
+// by the previous format; those simply expire.
+type Namespace struct {
+	prefix string
+}
+
+// NewNamespace creates a namespace; version is the value schema version
+func NewNamespace(name string, version int) Namespace {
+	return Namespace{prefix: name + ":v" + strconv.Itoa(version) + ":"}
+}
+
+// Key joins parts with "|" under the namespace. Parts may contain ":" (DIDs
+// do) but not "|".
+func (n Namespace) Key(parts ...string) string {
+	return n.prefix + strings.Join(parts, "|")
+}
+
+// Prefix returns the prefix shared by every key of the namespace, e.g. for
+// SCAN patterns
+func (n Namespace) Prefix() string {
+	return n.prefix
+}
+
+// Namespaces of the gateway's cached values. Bump a version whenever the
+// serialized form of its values changes.
+var (
+	DIDKeys     = NewNamespace("did", 2)     // DID public keys and documents (v2: msgpack documents)
+	PolicyKeys  = NewNamespace("policy", 1)  // Policy decisions
+	SessionKeys = NewNamespace("session", 1) // Cross-device sessions
+)
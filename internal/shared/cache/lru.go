@@ -0,0 +1,107 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"github.com/example/privacy-gateway/internal/shared/clock"
+)
+
+// LRU is a size-bounded in-process cache whose entries also expire. Unlike
+// RistrettoCache, a Set is visible immediately and always admitted, which
+// suits small result and negative caches keyed by caller-chosen values that
+// must not grow without bound.
+type LRU[V any] struct {
+	mu    sync.Mutex
+	size  int
+	clock clock.Clock
+	order *list.List // Front is most recently used
+	items map[string]*list.Element
+}
+
+type lruEntry[V any] struct {
+	key     string
+	value   V
+	expires time.Time
+}
+
+// NewLRU creates a cache holding at most size entries. clk defaults to
+// clock.Real.
+func NewLRU[V any](size int, clk clock.Clock) *LRU[V] {
+	if size < 1 {
+		size = 1
+	}
+	return &LRU[V]{size: size, clock: clock.Or(clk), order: list.New(), items: make(map[string]*list.Element)}
+}
+
+// Get returns the unexpired value stored under key
+func (c *LRU[V]) Get(key string) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var zero V
+	el, ok := c.items[key]
+	if !ok {
+		return zero, false
+	}
+	e := el.Value.(*lruEntry[V])
+	if !c.clock.Now().Before(e.expires) {
+		c.remove(el)
+		return zero, false
+	}
+	c.order.MoveToFront(el)
+	return e.value, true
+}
+
+// Set stores value under key for ttl, evicting the least recently used
+// entry when the cache is full
+func (c *LRU[V]) Set(key string, value V, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	expires := c.clock.Now().Add(ttl)
+	if el, ok := c.items[key]; ok {
+		e := el.Value.(*lruEntry[V])
+		e.value, e.expires = value, expires
+		c.order.MoveToFront(el)
+		return
+	}
+	c.items[key] = c.order.PushFront(&lruEntry[V]{key: key, value: value, expires: expires})
+	if c.order.Len() > c.size {
+		c.remove(c.order.Back())
+	}
+}
+
+// Delete removes key
+func (c *LRU[V]) Delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.items[key]; ok {
+		c.remove(el)
+	}
+}
+
+// Prune removes expired entries
+func (c *LRU[V]) Prune() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.clock.Now()
+	for el := c.order.Back(); el != nil; {
+		prev := el.Prev()
+		if !now.Before(el.Value.(*lruEntry[V]).expires) {
+			c.remove(el)
+		}
+		el = prev
+	}
+}
+
+// Len returns the number of entries, including expired ones not yet evicted
+func (c *LRU[V]) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+func (c *LRU[V]) remove(el *list.Element) {
+	c.order.Remove(el)
+	delete(c.items, el.Value.(*lruEntry[V]).key)
+}
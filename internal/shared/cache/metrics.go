@@ -0,0 +1,254 @@
+package cache
+
+import (
+	"math/rand"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+
+	"github.com/example/privacy-gateway/internal/shared/httpx"
+)
+
+// Counters counts end-to-end lookups of a cache across all its layers. Pass
+// OnHit and OnMiss as the onHit/onMiss callbacks of a cache.
+type Counters struct {
+	hits   atomic.Uint64
+	misses atomic.Uint64
+}
+
+// OnHit records a hit
+func (c *Counters) OnHit() {
+	c.hits.Add(1)
+}
+
+// OnMiss records a miss
+func (c *Counters) OnMiss() {
+	c.misses.Add(1)
+}
+
+// LookupStats are end-to-end hit and miss counts
+type LookupStats struct {
+	Hits     uint64  `json:"hits"`
+	Misses   uint64  `json:"misses"`
+	HitRatio float64 `json:"hit_ratio"`
+}
+
+// Stats returns the lookup counts
+func (c *Counters) Stats() LookupStats {
+	h, m := c.hits.Load(), c.misses.Load()
+	return LookupStats{Hits: h, Misses: m, HitRatio: ratio(h, m)}
+}
+
+// L1Stats reports a Ristretto cache's own metrics. Keys and CostUsed are
+// derived from add and evict counters, so keys removed with Delete are
+// still counted.
+type L1Stats struct {
+	Hits            uint64  `json:"hits"`
+	Misses          uint64  `json:"misses"`
+	HitRatio        float64 `json:"hit_ratio"`
+	Keys            uint64  `json:"keys"`
+	KeysAdded       uint64  `json:"keys_added"`
+	KeysEvicted     uint64  `json:"keys_evicted"`
+	CostUsed        uint64  `json:"cost_used"`
+	MaxCost         int64   `json:"max_cost"`
+	CostUtilization float64 `json:"cost_utilization"`
+	SetsDropped     uint64  `json:"sets_dropped"`
+	SetsRejected    uint64  `json:"sets_rejected"`
+}
+
+// Stats returns the cache's metrics
+func (r *RistrettoCache) Stats() L1Stats {
+	m := r.cache.Metrics
+	st := L1Stats{MaxCost: r.cache.MaxCost()}
+	if m == nil {
+		return st
+	}
+	st.Hits, st.Misses = m.Hits(), m.Misses()
+	st.HitRatio = ratio(st.Hits, st.Misses)
+	st.KeysAdded, st.KeysEvicted = m.KeysAdded(), m.KeysEvicted()
+	if st.KeysAdded > st.KeysEvicted {
+		st.Keys = st.KeysAdded - st.KeysEvicted
+	}
+	if added, evicted := m.CostAdded(), m.CostEvicted(); added > evicted {
+		st.CostUsed = added - evicted
+	}
+	if st.MaxCost > 0 {
+		st.CostUtilization = float64(st.CostUsed) / float64(st.MaxCost)
+	}
+	st.SetsDropped, st.SetsRejected = m.SetsDropped(), m.SetsRejected()
+	return st
+}
+
+// TrackHotKeys starts estimating the most frequently read keys, tracking at
+// most capacity candidates. Reads are sampled (1 in 16), so the overhead on
+// the hot path stays small. Use it while tuning; it is off by default.
+func (r *RistrettoCache) TrackHotKeys(capacity int) {
+	if capacity <= 0 {
+		capacity = 256
+	}
+	r.hot.Store(&hotKeys{capacity: capacity, counts: make(map[string]uint64, capacity)})
+}
+
+// HotKey is a key with its estimated read count
+type HotKey struct {
+	Key   string `json:"key"`
+	Reads uint64 `json:"reads"`
+}
+
+// HotKeys returns up to n of the most read keys, or nil when tracking is off
+func (r *RistrettoCache) HotKeys(n int) []HotKey {
+	h := r.hot.Load()
+	if h == nil {
+		return nil
+	}
+	return h.top(n)
+}
+
+// hotKeySample is the inverse sampling rate of hot key tracking
+const hotKeySample = 16
+
+// hotKeys is a Space-Saving top-k estimator: when full, the least counted
+// key is replaced and the newcomer inherits its count, so heavy hitters are
+// never lost while rare keys churn through the last slots
+type hotKeys struct {
+	capacity int
+
+	mu     sync.Mutex
+	counts map[string]uint64
+}
+
+func (h *hotKeys) observe(key string) {
+	if rand.Intn(hotKeySample) != 0 {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.counts[key]; ok || len(h.counts) < h.capacity {
+		h.counts[key] += hotKeySample
+		return
+	}
+	minKey, minCount := "", uint64(0)
+	for k, c := range h.counts {
+		if minKey == "" || c < minCount {
+			minKey, minCount = k, c
+		}
+	}
+	delete(h.counts, minKey)
+	h.counts[key] = minCount + hotKeySample
+}
+
+func (h *hotKeys) top(n int) []HotKey {
+	h.mu.Lock()
+	out := make([]HotKey, 0, len(h.counts))
+	for k, c := range h.counts {
+		out = append(out, HotKey{Key: k, Reads: c})
+	}
+	h.mu.Unlock()
+	sort.Slice(out, func(i, j int) bool { return out[i].Reads > out[j].Reads })
+	if n > 0 && len(out) > n {
+		out = out[:n]
+	}
+	return out
+}
+
+// Registry collects the gateway's caches for the admin metrics endpoint
+type Registry struct {
+	mu     sync.RWMutex
+	caches map[string]registered
+}
+
+type registered struct {
+	l1          *RistrettoCache
+	counters    *Counters
+	writeBehind func() WriteBehindStats
+}
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{caches: make(map[string]registered)}
+}
+
+// Register adds a cache under name. Either argument may be nil: l1 reports
+// the in-memory layer, counters the end-to-end lookups (L1 and L2).
+func (r *Registry) Register(name string, l1 *RistrettoCache, counters *Counters) {
+	r.mu.Lock()
+	c := r.caches[name]
+	c.l1, c.counters = l1, counters
+	r.caches[name] = c
+	r.mu.Unlock()
+}
+
+// RegisterWriteBehind reports the write-behind counters of the cache
+// registered under name, e.g. RegisterWriteBehind("did", c.WriteBehindStats)
+func (r *Registry) RegisterWriteBehind(name string, stats func() WriteBehindStats) {
+	r.mu.Lock()
+	c := r.caches[name]
+	c.writeBehind = stats
+	r.caches[name] = c
+	r.mu.Unlock()
+}
+
+// CacheStats is the admin view of one registered cache
+type CacheStats struct {
+	Lookups     *LookupStats      `json:"lookups,omitempty"`
+	L1          *L1Stats          `json:"l1,omitempty"`
+	HotKeys     []HotKey          `json:"hot_keys,omitempty"`
+	WriteBehind *WriteBehindStats `json:"write_behind,omitempty"`
+}
+
+// Stats returns every registered cache's stats, with up to hot hot keys each
+func (r *Registry) Stats(hot int) map[string]CacheStats {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	out := make(map[string]CacheStats, len(r.caches))
+	for name, c := range r.caches {
+		var st CacheStats
+		if c.counters != nil {
+			l := c.counters.Stats()
+			st.Lookups = &l
+		}
+		if c.l1 != nil {
+			l1 := c.l1.Stats()
+			st.L1 = &l1
+			if hot > 0 {
+				st.HotKeys = c.l1.HotKeys(hot)
+			}
+		}
+		if c.writeBehind != nil {
+			wb := c.writeBehind()
+			st.WriteBehind = &wb
+		}
+		out[name] = st
+	}
+	return out
+}
+
+// Handler serves GET /admin/v1/caches (admin). ?hot=N includes the N hottest keys
+// of caches with hot key tracking enabled.
+func (r *Registry) Handler() http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodGet {
+			httpx.WriteJSON(w, http.StatusMethodNotAllowed, httpx.ErrorResponse{Error: "method not allowed"})
+			return
+		}
+		hot := 0
+		if v := req.URL.Query().Get("hot"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 || n > 1000 {
+				httpx.WriteJSON(w, http.StatusBadRequest, httpx.ErrorResponse{Error: "hot must be between 0 and 1000"})
+				return
+			}
+			hot = n
+		}
+		httpx.WriteJSON(w, http.StatusOK, r.Stats(hot))
+	}
+}
+
+func ratio(hits, misses uint64) float64 {
+	if hits+misses == 0 {
+		return 0
+	}
+	return float64(hits) / float64(hits+misses)
+}
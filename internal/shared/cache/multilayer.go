@@ -3,18 +3,26 @@ package cache
 import (
 	"context"
 	"crypto/ed25519"
+	"errors"
 	"fmt"
 	"sync"
 	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/example/privacy-gateway/internal/shared/circuitbreaker"
+	"github.com/example/privacy-gateway/internal/shared/health"
 )
 
 // MultiLayerCache provides L1 (in-memory) + L2 (Redis) caching
 type MultiLayerCache struct {
-	l1     *RistrettoCache
-	l2     *RedisCache
-	mu     sync.RWMutex
-	onHit  func() // Metrics callback
-	onMiss func() // Metrics callback
+	l1      *RistrettoCache
+	l2      *RedisCache
+	breaker *circuitbreaker.CircuitBreaker // Optional, see WithBreaker
+	wb      *writeBehind                   // Optional, see WithWriteBehind
+	mu      sync.RWMutex
+	onHit   func() // Metrics callback
+	onMiss  func() // Metrics callback
 }
 
 // NewMultiLayerCache creates a new multi-layer cache
@@ -27,6 +35,60 @@ func NewMultiLayerCache(l1 *RistrettoCache, l2 *RedisCache, onHit, onMiss func()
 	}
 }
 
+// WithBreaker guards L2 operations with cb. Once Redis calls keep failing
+// or timing out the circuit opens and the cache runs L1-only: reads that
+// miss L1 are misses right away instead of waiting out a Redis timeout, and
+// writes only go to L1. Misses (redis.Nil) don't count as failures.
+func (m *MultiLayerCache) WithBreaker(cb *circuitbreaker.CircuitBreaker) *MultiLayerCache {
+	m.breaker = cb
+	return m
+}
+
+// NewL2Breaker creates a circuit breaker tuned for L2 cache calls: short
+// call timeout, quick to open, and a single probe after 10s
+func NewL2Breaker() *circuitbreaker.CircuitBreaker {
+	return circuitbreaker.New(circuitbreaker.Config{
+		MaxFailures:      5,
+		Timeout:          250 * time.Millisecond,
+		ResetTimeout:     10 * time.Second,
+		HalfOpenMaxCalls: 1,
+	})
+}
+
+// Degraded reports whether the cache is running L1-only
+func (m *MultiLayerCache) Degraded() bool {
+	return m.breaker != nil && m.breaker.State() == circuitbreaker.StateOpen
+}
+
+// Check reports L1-only mode as degraded. Register it as a component, e.g.
+// health.NewRedisChecker("cache", c.Check), so /healthz shows the mode.
+func (m *MultiLayerCache) Check(ctx context.Context) error {
+	if m.Degraded() {
+		return fmt.Errorf("%w: L1-only, Redis circuit open", health.ErrDegraded)
+	}
+	return nil
+}
+
+// l2Call runs an L2 operation through the breaker, if any
+func (m *MultiLayerCache) l2Call(ctx context.Context, fn func(ctx context.Context) error) error {
+	if m.breaker == nil {
+		return fn(ctx)
+	}
+	var miss error
+	err := m.breaker.Call(ctx, func(ctx context.Context) error {
+		err := fn(ctx)
+		if errors.Is(err, redis.Nil) || errors.Is(err, ErrCacheMiss) {
+			miss = err
+			return nil
+		}
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return miss
+}
+
 // Get retrieves a value, checking L1 then L2
 func (m *MultiLayerCache) Get(ctx context.Context, key string) (interface{}, error) {
 	// Try L1 first (in-memory, fastest)
@@ -38,7 +100,11 @@ func (m *MultiLayerCache) Get(ctx context.Context, key string) (interface{}, err
 	}
 
 	// Try L2 (Redis, distributed)
-	val, err := m.l2.Get(ctx, key)
+	var val interface{}
+	err := m.l2Call(ctx, func(ctx context.Context) (err error) {
+		val, err = m.l2.Get(ctx, key)
+		return err
+	})
 	if err == nil {
 		// Populate L1 for next time
 		m.l1.Set(key, val, 1, time.Hour)
@@ -54,19 +120,42 @@ func (m *MultiLayerCache) Get(ctx context.Context, key string) (interface{}, err
 	return nil, ErrCacheMiss
 }
 
-// Set stores a value in both L1 and L2
+// Set stores a value in both L1 and L2. With write-behind the L2 write is
+// queued and Set returns once L1 is updated.
 func (m *MultiLayerCache) Set(ctx context.Context, key string, value interface{}, cost int64, ttl time.Duration) error {
 	// Set in L1 (in-memory)
 	m.l1.Set(key, value, cost, ttl)
 
-	// Set in L2 (Redis)
-	return m.l2.Set(ctx, key, value, ttl)
+	// Set in L2 (Redis), unless running L1-only
+	return m.setL2(ctx, key, func(ctx context.Context) error {
+		return m.l2.Set(ctx, key, value, ttl)
+	})
 }
 
-// Delete removes a key from both caches
+// setL2 runs an L2 write now, or queues it with write-behind
+func (m *MultiLayerCache) setL2(ctx context.Context, key string, set func(ctx context.Context) error) error {
+	if m.wb != nil {
+		m.wb.enqueue(key, set)
+		return nil
+	}
+	err := m.l2Call(ctx, set)
+	if errors.Is(err, circuitbreaker.ErrCircuitOpen) {
+		return nil
+	}
+	return err
+}
+
+// Delete removes a key from both caches, cancelling a queued write-behind
+// for it. Unlike Set it reports an open circuit, since the L2 entry is
+// still there.
 func (m *MultiLayerCache) Delete(ctx context.Context, key string) error {
 	m.l1.Delete(key)
-	return m.l2.Delete(ctx, key)
+	if m.wb != nil {
+		defer m.wb.cancel(key)()
+	}
+	return m.l2Call(ctx, func(ctx context.Context) error {
+		return m.l2.Delete(ctx, key)
+	})
 }
 
 // GetOrLoad retrieves from cache or loads using the provided function
@@ -110,36 +199,90 @@ func NewDIDCache(l1 *RistrettoCache, l2 *RedisCache, onHit, onMiss func()) *DIDC
 	}
 }
 
-// GetPublicKey retrieves a cached public key for a DID
+// WithBreaker guards L2 operations with cb, see MultiLayerCache.WithBreaker
+func (d *DIDCache) WithBreaker(cb *circuitbreaker.CircuitBreaker) *DIDCache {
+	d.cache.WithBreaker(cb)
+	return d
+}
+
+// WithWriteBehind queues L2 writes, see MultiLayerCache.WithWriteBehind
+func (d *DIDCache) WithWriteBehind(cfg WriteBehindConfig) *DIDCache {
+	d.cache.WithWriteBehind(cfg)
+	return d
+}
+
+// RunWriteBehind processes queued L2 writes until ctx is cancelled
+func (d *DIDCache) RunWriteBehind(ctx context.Context) {
+	d.cache.RunWriteBehind(ctx)
+}
+
+// WriteBehindStats returns the write-behind counters
+func (d *DIDCache) WriteBehindStats() WriteBehindStats {
+	return d.cache.WriteBehindStats()
+}
+
+// Degraded reports whether the cache is running L1-only
+func (d *DIDCache) Degraded() bool {
+	return d.cache.Degraded()
+}
+
+// Check reports L1-only mode as degraded, for health.Checker
+func (d *DIDCache) Check(ctx context.Context) error {
+	return d.cache.Check(ctx)
+}
+
+// GetPublicKey retrieves a cached public key for a DID. Keys are held as
+// ed25519.PublicKey in L1 and raw bytes in L2, so neither layer goes through
+// the JSON interface{} round-trip of MultiLayerCache.Get
 func (d *DIDCache) GetPublicKey(ctx context.Context, did string) (ed25519.PublicKey, error) {
-	val, err := d.cache.Get(ctx, "did:"+did)
+	m := d.cache
+	key := DIDKeys.Key("key", did)
+
+	if val, ok := m.l1.Get(key); ok {
+		if pub, ok := val.(ed25519.PublicKey); ok {
+			if m.onHit != nil {
+				m.onHit()
+			}
+			return pub, nil
+		}
+	}
+
+	var raw []byte
+	err := m.l2Call(ctx, func(ctx context.Context) (err error) {
+		raw, err = m.l2.GetBytes(ctx, key)
+		return err
+	})
 	if err != nil {
+		if m.onMiss != nil {
+			m.onMiss()
+		}
+		if errors.Is(err, redis.Nil) || errors.Is(err, circuitbreaker.ErrCircuitOpen) {
+			return nil, ErrCacheMiss
+		}
 		return nil, err
 	}
+	if len(raw) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("invalid public key size: %d", len(raw))
+	}
 
-	// Handle different value types
-	switch v := val.(type) {
-	case ed25519.PublicKey:
-		return v, nil
-	case []byte:
-		if len(v) == ed25519.PublicKeySize {
-			return ed25519.PublicKey(v), nil
-		}
-		return nil, fmt.Errorf("invalid public key size: %d", len(v))
-	case string:
-		// Assume hex or base64 encoded
-		return nil, fmt.Errorf("string public key not yet supported")
-	default:
-		return nil, fmt.Errorf("unexpected public key type: %T", v)
+	pub := ed25519.PublicKey(raw)
+	m.l1.Set(key, pub, int64(len(pub)), time.Hour)
+	if m.onHit != nil {
+		m.onHit()
 	}
+	return pub, nil
 }
 
 // SetPublicKey stores a public key for a DID
 func (d *DIDCache) SetPublicKey(ctx context.Context, did string, pubKey ed25519.PublicKey, ttl time.Duration) error {
-	return d.cache.Set(ctx, "did:"+did, pubKey, int64(len(pubKey)), ttl)
+	key := DIDKeys.Key("key", did)
+	d.cache.l1.Set(key, pubKey, int64(len(pubKey)), ttl)
+	return d.cache.setL2(ctx, key, func(ctx context.Context) error {
+		return d.cache.l2.SetBytes(ctx, key, pubKey, ttl)
+	})
 }
 
 // Invalidate removes a DID from cache
 func (d *DIDCache) Invalidate(ctx context.Context, did string) error {
-	return d.cache.Delete(ctx, "did:"+did)
+	return d.cache.Delete(ctx, DIDKeys.Key("key", did))
 }
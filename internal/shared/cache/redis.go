@@ -14,22 +14,64 @@ var ErrCacheMiss = errors.New("cache miss")
 // RedisCache provides a distributed L2 cache using Redis
 type RedisCache struct {
 	client *redis.Client
+	enc    *Encryptor  // Optional, seals every value written
+	codecs *codecTable // Per-type codecs for GetValue/SetValue
 }
 
 // NewRedisCache creates a new Redis cache client
 func NewRedisCache(client *redis.Client) *RedisCache {
-	return &RedisCache{client: client}
+	return &RedisCache{client: client, codecs: &codecTable{}}
+}
+
+// UseCodec makes SetValue encode values of sample's type with c. Reads
+// decode with whatever codec wrote a value, so switching a type's codec is
+// safe while older entries are still cached.
+func (r *RedisCache) UseCodec(sample any, c Codec) {
+	r.codecs.set(sample, c)
+}
+
+// WithEncryption returns a cache on the same client that encrypts values
+// with enc. Use it for sensitive entries (presentation results, session
+// data); values that are public anyway, like DID public keys, don't need it.
+// Values that fail to decrypt (plaintext, retired key, tampered) are misses.
+func (r *RedisCache) WithEncryption(enc *Encryptor) *RedisCache {
+	return &RedisCache{client: r.client, enc: enc, codecs: r.codecs}
+}
+
+// seal encrypts data when encryption is enabled
+func (r *RedisCache) seal(key string, data []byte) ([]byte, error) {
+	if r.enc == nil {
+		return data, nil
+	}
+	return r.enc.Seal(key, data)
+}
+
+// open decrypts data when encryption is enabled
+func (r *RedisCache) open(key string, data []byte) ([]byte, error) {
+	if r.enc == nil {
+		return data, nil
+	}
+	plain, err := r.enc.Open(key, data)
+	if err != nil {
+		return nil, ErrCacheMiss
+	}
+	return plain, nil
 }
 
 // Get retrieves a value from Redis
 func (r *RedisCache) Get(ctx context.Context, key string) (interface{}, error) {
-	val, err := r.client.Get(ctx, key).Result()
+	raw, err := r.client.Get(ctx, key).Bytes()
 	if err == redis.Nil {
 		return nil, ErrCacheMiss
 	}
 	if err != nil {
 		return nil, err
 	}
+	data, err := r.open(key, raw)
+	if err != nil {
+		return nil, err
+	}
+	val := string(data)
 
 	// Try to unmarshal as generic interface{}
 	var result interface{}
@@ -42,7 +84,11 @@ func (r *RedisCache) Get(ctx context.Context, key string) (interface{}, error) {
 
 // GetBytes retrieves raw bytes from Redis
 func (r *RedisCache) GetBytes(ctx context.Context, key string) ([]byte, error) {
-	return r.client.Get(ctx, key).Bytes()
+	data, err := r.client.Get(ctx, key).Bytes()
+	if err != nil {
+		return nil, err
+	}
+	return r.open(key, data)
 }
 
 // Set stores a value in Redis with TTL
@@ -51,12 +97,38 @@ func (r *RedisCache) Set(ctx context.Context, key string, value interface{}, ttl
 	if err != nil {
 		return err
 	}
-	return r.client.Set(ctx, key, data, ttl).Err()
+	return r.SetBytes(ctx, key, data, ttl)
 }
 
 // SetBytes stores raw bytes in Redis with TTL
 func (r *RedisCache) SetBytes(ctx context.Context, key string, value []byte, ttl time.Duration) error {
-	return r.client.Set(ctx, key, value, ttl).Err()
+	data, err := r.seal(key, value)
+	if err != nil {
+		return err
+	}
+	return r.client.Set(ctx, key, data, ttl).Err()
+}
+
+// GetValue decodes the value stored at key into v
+func (r *RedisCache) GetValue(ctx context.Context, key string, v any) error {
+	data, err := r.GetBytes(ctx, key)
+	if errors.Is(err, redis.Nil) {
+		return ErrCacheMiss
+	}
+	if err != nil {
+		return err
+	}
+	return Decode(data, v)
+}
+
+// SetValue encodes v with its type's codec (JSON unless set with UseCodec)
+// and stores it with TTL
+func (r *RedisCache) SetValue(ctx context.Context, key string, v any, ttl time.Duration) error {
+	data, err := Encode(r.codecs.get(v), v)
+	if err != nil {
+		return err
+	}
+	return r.SetBytes(ctx, key, data, ttl)
 }
 
 // Delete removes a key from Redis
@@ -74,23 +146,43 @@ func (r *RedisCache) Pipeline() redis.Pipeliner {
 	return r.client.Pipeline()
 }
 
-// MGet gets multiple keys at once (pipelining)
+// MGet gets multiple keys at once (pipelining). With encryption, values that
+// fail to decrypt come back as nil like missing keys.
 func (r *RedisCache) MGet(ctx context.Context, keys ...string) ([]interface{}, error) {
-	return r.client.MGet(ctx, keys...).Result()
+	vals, err := r.client.MGet(ctx, keys...).Result()
+	if err != nil || r.enc == nil {
+		return vals, err
+	}
+	for i, v := range vals {
+		s, ok := v.(string)
+		if !ok {
+			continue
+		}
+		plain, err := r.open(keys[i], []byte(s))
+		if err != nil {
+			vals[i] = nil
+			continue
+		}
+		vals[i] = string(plain)
+	}
+	return vals, nil
 }
 
 // MSet sets multiple keys at once
 func (r *RedisCache) MSet(ctx context.Context, values map[string]interface{}, ttl time.Duration) error {
 	pipe := r.client.Pipeline()
-	
+
 	for key, val := range values {
 		data, err := json.Marshal(val)
 		if err != nil {
 			return err
 		}
+		if data, err = r.seal(key, data); err != nil {
+			return err
+		}
 		pipe.Set(ctx, key, data, ttl)
 	}
-	
+
 	_, err := pipe.Exec(ctx)
 	return err
 }
@@ -1,6 +1,7 @@
 package cache
 
 import (
+	"sync/atomic"
 	"time"
 
 	"github.com/dgraph-io/ristretto"
@@ -9,6 +10,7 @@ import (
 // RistrettoCache provides an in-memory L1 cache using Ristretto
 type RistrettoCache struct {
 	cache *ristretto.Cache
+	hot   atomic.Pointer[hotKeys] // Set by TrackHotKeys
 }
 
 // NewRistrettoCache creates a new L1 cache
@@ -16,10 +18,10 @@ type RistrettoCache struct {
 // numCounters: number of keys to track frequency (10x maxCost recommended)
 func NewRistrettoCache(maxCost int64, numCounters int64) (*RistrettoCache, error) {
 	cache, err := ristretto.NewCache(&ristretto.Config{
-		NumCounters: numCounters,      // 10x maxCost recommended
-		MaxCost:     maxCost,           // Total cache size
-		BufferItems: 64,                // Number of keys per Get buffer
-		Metrics:     true,              // Enable metrics
+		NumCounters: numCounters, // 10x maxCost recommended
+		MaxCost:     maxCost,     // Total cache size
+		BufferItems: 64,          // Number of keys per Get buffer
+		Metrics:     true,        // Enable metrics
 	})
 	if err != nil {
 		return nil, err
@@ -30,6 +32,9 @@ func NewRistrettoCache(maxCost int64, numCounters int64) (*RistrettoCache, error
 
 // Get retrieves a value from the cache
 func (r *RistrettoCache) Get(key string) (interface{}, bool) {
+	if h := r.hot.Load(); h != nil {
+		h.observe(key)
+	}
 	return r.cache.Get(key)
 }
 
@@ -39,6 +44,11 @@ func (r *RistrettoCache) Set(key string, value interface{}, cost int64, ttl time
 	return r.cache.SetWithTTL(key, value, cost, ttl)
 }
 
+// Wait blocks until buffered Sets have been applied
+func (r *RistrettoCache) Wait() {
+	r.cache.Wait()
+}
+
 // Delete removes a key from the cache
 func (r *RistrettoCache) Delete(key string) {
 	r.cache.Del(key)
@@ -0,0 +1,198 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/example/privacy-gateway/internal/shared/circuitbreaker"
+	"github.com/example/privacy-gateway/internal/shared/observability"
+)
+
+// WriteBehindConfig configures asynchronous L2 writes
+type WriteBehindConfig struct {
+	QueueSize int           // Pending writes (default 4096); the oldest is dropped on overflow
+	Timeout   time.Duration // Per write (default 1s)
+	Logger    *slog.Logger
+}
+
+// WriteBehindStats are the write-behind queue's counters
+type WriteBehindStats struct {
+	Queued     int    `json:"queued"`
+	Written    uint64 `json:"written"`
+	Superseded uint64 `json:"superseded"` // Skipped: a newer Set or a Delete for the key came first
+	Dropped    uint64 `json:"dropped"`    // Queue overflow, oldest first
+	Failed     uint64 `json:"failed"`     // Redis errors and timeouts
+	Skipped    uint64 `json:"skipped"`    // Circuit open (L1-only)
+}
+
+// writeBehind queues L2 writes for a single worker. Each key remembers the
+// sequence number of its latest queued write, so older writes for the same
+// key are skipped and a Delete cancels whatever is still queued.
+type writeBehind struct {
+	cfg   WriteBehindConfig
+	queue chan pendingWrite
+
+	mu     sync.Mutex
+	seq    uint64
+	latest map[string]uint64
+
+	// inflight is held while a write runs, so Delete can't be overtaken by
+	// a write that had already been dequeued
+	inflight sync.Mutex
+
+	written, superseded, dropped, failed, skipped atomic.Uint64
+}
+
+type pendingWrite struct {
+	key string
+	seq uint64
+	set func(ctx context.Context) error
+}
+
+// WithWriteBehind makes Set write to L2 asynchronously: L1 is updated
+// right away and the Redis write is queued, so a slow Redis never sits on
+// the caller's path. When the queue is full the oldest pending write is
+// dropped; the value stays in L1, and other replicas load it themselves on
+// their L2 miss.
+// Start RunWriteBehind to process the queue.
+func (m *MultiLayerCache) WithWriteBehind(cfg WriteBehindConfig) *MultiLayerCache {
+	if cfg.QueueSize == 0 {
+		cfg.QueueSize = 4096
+	}
+	if cfg.Timeout == 0 {
+		cfg.Timeout = time.Second
+	}
+	cfg.Logger = observability.Component(cfg.Logger, observability.ComponentCache)
+	m.wb = &writeBehind{
+		cfg:    cfg,
+		queue:  make(chan pendingWrite, cfg.QueueSize),
+		latest: make(map[string]uint64),
+	}
+	return m
+}
+
+// RunWriteBehind writes queued values to L2 until ctx is cancelled, then
+// drains the queue for up to 5 seconds. It returns at once without
+// WithWriteBehind.
+func (m *MultiLayerCache) RunWriteBehind(ctx context.Context) {
+	wb := m.wb
+	if wb == nil {
+		return
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			for shutdownCtx.Err() == nil {
+				select {
+				case w := <-wb.queue:
+					m.write(shutdownCtx, w)
+				default:
+					return
+				}
+			}
+			return
+		case w := <-wb.queue:
+			m.write(ctx, w)
+		}
+	}
+}
+
+// WriteBehindStats returns the write-behind counters; zero without
+// WithWriteBehind
+func (m *MultiLayerCache) WriteBehindStats() WriteBehindStats {
+	wb := m.wb
+	if wb == nil {
+		return WriteBehindStats{}
+	}
+	return WriteBehindStats{
+		Queued:     len(wb.queue),
+		Written:    wb.written.Load(),
+		Superseded: wb.superseded.Load(),
+		Dropped:    wb.dropped.Load(),
+		Failed:     wb.failed.Load(),
+		Skipped:    wb.skipped.Load(),
+	}
+}
+
+// enqueue queues set as the latest L2 write for key, dropping the oldest
+// pending write if the queue is full
+func (wb *writeBehind) enqueue(key string, set func(ctx context.Context) error) {
+	wb.mu.Lock()
+	wb.seq++
+	w := pendingWrite{key: key, seq: wb.seq, set: set}
+	wb.latest[key] = w.seq
+	wb.mu.Unlock()
+
+	for {
+		select {
+		case wb.queue <- w:
+			return
+		default:
+		}
+		select {
+		case old := <-wb.queue:
+			wb.done(old)
+			if wb.dropped.Add(1)%1000 == 1 {
+				wb.cfg.Logger.Warn("cache write-behind queue is full; dropping oldest writes", "dropped", wb.dropped.Load())
+			}
+		default:
+		}
+	}
+}
+
+// current reports whether w is still the latest write for its key
+func (wb *writeBehind) current(w pendingWrite) bool {
+	wb.mu.Lock()
+	defer wb.mu.Unlock()
+	return wb.latest[w.key] == w.seq
+}
+
+// done forgets w if it's still the latest write for its key
+func (wb *writeBehind) done(w pendingWrite) {
+	wb.mu.Lock()
+	if wb.latest[w.key] == w.seq {
+		delete(wb.latest, w.key)
+	}
+	wb.mu.Unlock()
+}
+
+// cancel forgets any queued write for key. It waits for a running write
+// and holds off the next one until the returned func is called, so a
+// caller deleting key from L2 can't be overtaken.
+func (wb *writeBehind) cancel(key string) func() {
+	wb.inflight.Lock()
+	wb.mu.Lock()
+	delete(wb.latest, key)
+	wb.mu.Unlock()
+	return wb.inflight.Unlock
+}
+
+func (m *MultiLayerCache) write(ctx context.Context, w pendingWrite) {
+	wb := m.wb
+	wb.inflight.Lock()
+	defer wb.inflight.Unlock()
+	if !wb.current(w) {
+		wb.superseded.Add(1)
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, wb.cfg.Timeout)
+	err := m.l2Call(ctx, w.set)
+	cancel()
+	wb.done(w)
+	switch {
+	case err == nil:
+		wb.written.Add(1)
+	case errors.Is(err, circuitbreaker.ErrCircuitOpen):
+		wb.skipped.Add(1)
+	default:
+		if wb.failed.Add(1)%100 == 1 {
+			wb.cfg.Logger.Warn("cache write-behind failed", "key", w.key, "failed", wb.failed.Load(), "error", err)
+		}
+	}
+}
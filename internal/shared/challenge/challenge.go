@@ -0,0 +1,366 @@
+package challenge
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrMalformed          = errors.New("malformed challenge")
+	ErrBindingMismatch    = errors.New("challenge binding mismatch")
+	ErrUnsupportedVersion = errors.New("unsupported challenge version")
+	ErrExpired            = errors.New("challenge expired")
+	ErrAudienceMismatch   = errors.New("challenge audience mismatch")
+	ErrDomainMismatch     = errors.New("challenge domain mismatch")
+)
+
+// ClockSkew is the tolerance applied to iat when validating
+const ClockSkew = 30 * time.Second
+
+// Challenge format versions. Version 1 is the original unversioned format;
+// version 2 starts with a v= line and adds the issue time.
+const (
+	Version1       = 1
+	Version2       = 2
+	CurrentVersion = Version2
+)
+
+// PKCE code challenge methods
+const (
+	MethodS256  = "S256"
+	MethodPlain = "plain"
+)
+
+// Challenge is the decoded form of the canonical challenge string:
+//
+//	v=<version>                       (version 2+)
+//	did=<did>
+//	nonce=<nonce>
+//	aud=<audience>
+//	domain=<domain>
+//	iat=<unix>                        (version 2+)
+//	exp=<unix>
+//	origin=<origin>                   (optional)
+//	client_id=<client id>             (optional)
+//	code_challenge=<challenge>        (optional)
+//	code_challenge_method=<S256|plain> (optional)
+//
+// Optional binding fields are part of the signed string, so a wallet signing
+// the challenge also attests to the origin and client it was issued for.
+type Challenge struct {
+	Version   int // Zero renders as CurrentVersion
+	DID       string
+	Nonce     string
+	Audience  string
+	Domain    string
+	IssuedAt  int64
+	ExpiresAt int64
+
+	Origin              string
+	ClientID            string
+	CodeChallenge       string
+	CodeChallengeMethod string
+}
+
+// Binding is what the verifying client presents to prove it owns the challenge
+type Binding struct {
+	Origin       string // Origin header of the verify request
+	ClientID     string
+	CodeVerifier string
+}
+
+// String renders the canonical challenge string
+func (c Challenge) String() string {
+	version := c.Version
+	if version == 0 {
+		version = CurrentVersion
+	}
+	var b strings.Builder
+	b.Grow(96 + len(c.DID) + len(c.Nonce) + len(c.Audience) + len(c.Domain) +
+		len(c.Origin) + len(c.ClientID) + len(c.CodeChallenge))
+	if version >= Version2 {
+		writeField(&b, "v", strconv.Itoa(version))
+	}
+	writeField(&b, "did", c.DID)
+	writeField(&b, "nonce", c.Nonce)
+	writeField(&b, "aud", c.Audience)
+	writeField(&b, "domain", c.Domain)
+	if version >= Version2 {
+		writeField(&b, "iat", strconv.FormatInt(c.IssuedAt, 10))
+	}
+	writeField(&b, "exp", strconv.FormatInt(c.ExpiresAt, 10))
+	if c.Origin != "" {
+		writeField(&b, "origin", c.Origin)
+	}
+	if c.ClientID != "" {
+		writeField(&b, "client_id", c.ClientID)
+	}
+	if c.CodeChallenge != "" {
+		writeField(&b, "code_challenge", c.CodeChallenge)
+		writeField(&b, "code_challenge_method", c.CodeChallengeMethod)
+	}
+	return b.String()
+}
+
+func writeField(b *strings.Builder, key, value string) {
+	b.WriteString(key)
+	b.WriteByte('=')
+	b.WriteString(value)
+	b.WriteByte('\n')
+}
+
+// Parse decodes a canonical challenge string in any supported version. A
+// leading v= line selects the version; without one the string is parsed as
+// Version1 so challenges issued before a rollout still verify. Unknown or
+// duplicate fields, values containing line breaks, a malformed DID and a
+// code_challenge_method without a code_challenge are rejected.
+func Parse(s string) (Challenge, error) {
+	c := Challenge{Version: Version1}
+	if s == "" {
+		return c, fmt.Errorf("%w: empty", ErrMalformed)
+	}
+
+	rest := strings.TrimSuffix(s, "\n")
+	if v, ok := strings.CutPrefix(rest, "v="); ok {
+		v, rest, _ = strings.Cut(v, "\n")
+		version, err := strconv.Atoi(v)
+		if err != nil {
+			return c, fmt.Errorf("%w: invalid version", ErrMalformed)
+		}
+		if version != Version2 {
+			return c, fmt.Errorf("%w: %d", ErrUnsupportedVersion, version)
+		}
+		c.Version = version
+	}
+
+	// Lines are walked with Cut and seen fields tracked in a bitmask so
+	// parsing doesn't allocate on the verify hot path
+	var seen uint16
+	for more := true; more; {
+		var line string
+		line, rest, more = strings.Cut(rest, "\n")
+		key, value, ok := strings.Cut(line, "=")
+		if !ok || strings.IndexByte(value, '\r') >= 0 {
+			return c, fmt.Errorf("%w: bad line %q", ErrMalformed, line)
+		}
+		bit := fieldBit(key)
+		if bit == 0 || (key == "iat" && c.Version < Version2) {
+			return c, fmt.Errorf("%w: unknown field %s", ErrMalformed, key)
+		}
+		if seen&bit != 0 {
+			return c, fmt.Errorf("%w: duplicate field %s", ErrMalformed, key)
+		}
+		seen |= bit
+
+		switch key {
+		case "did":
+			if !didSyntax.MatchString(value) {
+				return c, fmt.Errorf("%w: invalid did", ErrMalformed)
+			}
+			c.DID = value
+		case "nonce":
+			c.Nonce = value
+		case "aud":
+			c.Audience = value
+		case "domain":
+			c.Domain = value
+		case "exp":
+			exp, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return c, fmt.Errorf("%w: invalid exp", ErrMalformed)
+			}
+			c.ExpiresAt = exp
+		case "iat":
+			iat, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return c, fmt.Errorf("%w: invalid iat", ErrMalformed)
+			}
+			c.IssuedAt = iat
+		case "origin":
+			c.Origin = value
+		case "client_id":
+			c.ClientID = value
+		case "code_challenge":
+			c.CodeChallenge = value
+		case "code_challenge_method":
+			c.CodeChallengeMethod = value
+		}
+	}
+
+	required := requiredV1
+	if c.Version >= Version2 {
+		required |= fieldBit("iat")
+	}
+	if missing := required &^ seen; missing != 0 {
+		for i, name := range fieldNames {
+			if missing&(1<<i) != 0 {
+				return c, fmt.Errorf("%w: missing required field %s", ErrMalformed, name)
+			}
+		}
+	}
+	if c.CodeChallenge != "" && c.CodeChallengeMethod != MethodS256 && c.CodeChallengeMethod != MethodPlain {
+		return c, fmt.Errorf("%w: unsupported code_challenge_method", ErrMalformed)
+	}
+	if c.CodeChallenge == "" && c.CodeChallengeMethod != "" {
+		return c, fmt.Errorf("%w: code_challenge_method without code_challenge", ErrMalformed)
+	}
+	return c, nil
+}
+
+// fieldNames lists known fields; a field's bit in the seen mask is 1<<index
+var fieldNames = [...]string{
+	"did", "nonce", "aud", "domain", "exp", "iat",
+	"origin", "client_id", "code_challenge", "code_challenge_method",
+}
+
+// requiredV1 are the fields every version must carry (did through exp)
+const requiredV1 uint16 = 1<<5 - 1
+
+// fieldBit returns the seen-mask bit of a field, or 0 if unknown
+func fieldBit(key string) uint16 {
+	for i, name := range fieldNames {
+		if name == key {
+			return 1 << i
+		}
+	}
+	return 0
+}
+
+// didSyntax matches the DID Core ABNF, the same syntax validate.ValidateDID
+// checks. It lives here too because validate imports this package; method
+// support is left to callers.
+var didSyntax = regexp.MustCompile(`^did:[a-z0-9]+:(?:(?:[a-zA-Z0-9._-]|%[0-9A-Fa-f]{2})*:)*(?:[a-zA-Z0-9._-]|%[0-9A-Fa-f]{2})+$`)
+
+// Expected holds the values a challenge must match to be accepted
+type Expected struct {
+	DID      string // Optional; checked when set
+	Audience string
+	Domain   string
+	Now      time.Time // Defaults to time.Now()
+	// TTL, when set, bounds the challenge lifetime: exp may be at most
+	// TTL (plus ClockSkew) after iat, or after now for Version1
+	TTL time.Duration
+}
+
+// Validate checks each field of a parsed challenge: the DID is well formed,
+// the nonce is present, audience and domain match, iat is not in the future,
+// exp has not passed and, with a TTL, is not further out than it allows.
+func (c Challenge) Validate(want Expected) error {
+	now := want.Now
+	if now.IsZero() {
+		now = time.Now()
+	}
+	if !didSyntax.MatchString(c.DID) {
+		return fmt.Errorf("%w: invalid did", ErrMalformed)
+	}
+	if want.DID != "" && c.DID != want.DID {
+		return fmt.Errorf("%w: did does not match request", ErrMalformed)
+	}
+	if c.Nonce == "" {
+		return fmt.Errorf("%w: empty nonce", ErrMalformed)
+	}
+	if c.Audience != want.Audience {
+		return fmt.Errorf("%w: got %q", ErrAudienceMismatch, c.Audience)
+	}
+	if c.Domain != want.Domain {
+		return fmt.Errorf("%w: got %q", ErrDomainMismatch, c.Domain)
+	}
+	if c.Version >= Version2 {
+		if c.IssuedAt > now.Add(ClockSkew).Unix() {
+			return fmt.Errorf("%w: issued in the future", ErrMalformed)
+		}
+		if c.ExpiresAt < c.IssuedAt {
+			return fmt.Errorf("%w: exp before iat", ErrMalformed)
+		}
+	}
+	if c.ExpiresAt <= now.Unix() {
+		return ErrExpired
+	}
+	if want.TTL > 0 {
+		// Version1 has no iat, so its lifetime is measured from now
+		from := now.Unix()
+		if c.Version >= Version2 {
+			from = c.IssuedAt
+		}
+		if c.ExpiresAt-from > int64((want.TTL + ClockSkew).Seconds()) {
+			return fmt.Errorf("%w: lifetime exceeds ttl", ErrMalformed)
+		}
+	}
+	return nil
+}
+
+// codeChallengeRegex matches RFC 7636 code challenges
+var codeChallengeRegex = regexp.MustCompile(`^[A-Za-z0-9._~-]{43,128}$`)
+
+// clientIDRegex restricts client IDs to a safe printable set
+var clientIDRegex = regexp.MustCompile(`^[A-Za-z0-9._:/-]{1,128}$`)
+
+// Bind validates client-supplied binding parameters and attaches them to the
+// challenge before it is issued. Empty values leave that binding unset.
+func (c *Challenge) Bind(origin, clientID, codeChallenge, method string) error {
+	if origin != "" {
+		u, err := url.Parse(origin)
+		if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" ||
+			u.Path != "" || u.RawQuery != "" || u.Fragment != "" || u.User != nil {
+			return fmt.Errorf("%w: origin must be scheme://host[:port]", ErrMalformed)
+		}
+		c.Origin = origin
+	}
+	if clientID != "" {
+		if !clientIDRegex.MatchString(clientID) {
+			return fmt.Errorf("%w: invalid client_id", ErrMalformed)
+		}
+		c.ClientID = clientID
+	}
+	if codeChallenge != "" {
+		if method == "" {
+			method = MethodS256
+		}
+		if method != MethodS256 && method != MethodPlain {
+			return fmt.Errorf("%w: unsupported code_challenge_method", ErrMalformed)
+		}
+		if !codeChallengeRegex.MatchString(codeChallenge) {
+			return fmt.Errorf("%w: invalid code_challenge", ErrMalformed)
+		}
+		c.CodeChallenge = codeChallenge
+		c.CodeChallengeMethod = method
+	}
+	return nil
+}
+
+// VerifyBinding checks the verify request against the binding fields embedded
+// in the challenge. Fields absent from the challenge are not enforced.
+func (c Challenge) VerifyBinding(b Binding) error {
+	if c.Origin != "" && !constantTimeEqual(c.Origin, b.Origin) {
+		return fmt.Errorf("%w: origin", ErrBindingMismatch)
+	}
+	if c.ClientID != "" && !constantTimeEqual(c.ClientID, b.ClientID) {
+		return fmt.Errorf("%w: client_id", ErrBindingMismatch)
+	}
+	if c.CodeChallenge != "" {
+		if b.CodeVerifier == "" {
+			return fmt.Errorf("%w: code_verifier required", ErrBindingMismatch)
+		}
+		computed := b.CodeVerifier
+		if c.CodeChallengeMethod == MethodS256 {
+			sum := sha256.Sum256([]byte(b.CodeVerifier))
+			computed = base64.RawURLEncoding.EncodeToString(sum[:])
+		}
+		if !constantTimeEqual(c.CodeChallenge, computed) {
+			return fmt.Errorf("%w: code_verifier", ErrBindingMismatch)
+		}
+	}
+	return nil
+}
+
+func constantTimeEqual(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
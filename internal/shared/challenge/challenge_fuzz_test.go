@@ -0,0 +1,32 @@
+package challenge_test
+
+import (
+	"testing"
+
+	"github.com/example/privacy-gateway/internal/shared/challenge"
+)
+
+// FuzzParse checks Parse never panics and that whatever it accepts
+// re-renders to a string parsing back to the same challenge
+func FuzzParse(f *testing.F) {
+	f.Add("v=2\ndid=did:key:z6Mk\nnonce=n\naud=a\ndomain=d\niat=1\nexp=2\n")
+	f.Add("did=did:key:z6Mk\nnonce=n\naud=a\ndomain=d\nexp=2\norigin=https://app.example.com\nclient_id=c\ncode_challenge=x\ncode_challenge_method=S256\n")
+	f.Add("v=3\n")
+	f.Add("v=2\ndid=a\ndid=b\n")
+	f.Add("did=a\r\n")
+	f.Add("=\n=\n")
+
+	f.Fuzz(func(t *testing.T, s string) {
+		c, err := challenge.Parse(s)
+		if err != nil {
+			return
+		}
+		again, err := challenge.Parse(c.String())
+		if err != nil {
+			t.Fatalf("re-rendered challenge %q does not parse: %v", c.String(), err)
+		}
+		if again != c {
+			t.Fatalf("round trip changed the challenge:\n%#v\n%#v", c, again)
+		}
+	})
+}
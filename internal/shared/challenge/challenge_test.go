@@ -0,0 +1,104 @@
+package challenge_test
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/example/privacy-gateway/internal/shared/challenge"
+)
+
+const testDID = "did:key:z6MkhaXgBZDvotDkL5257faiztiGiC2QtKLGpbnnEGta2doK"
+
+func TestVerifyEnforcesBinding(t *testing.T) {
+	gen, err := challenge.NewGenerator(challenge.Config{Audience: "did-gateway", Domain: "localhost", TTL: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifier := "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	sum := sha256.Sum256([]byte(verifier))
+	codeChallenge := base64.RawURLEncoding.EncodeToString(sum[:])
+
+	c, err := gen.Generate(testDID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Bind("https://app.example.com", "wallet-web", codeChallenge, ""); err != nil {
+		t.Fatal(err)
+	}
+	s := c.String()
+
+	good := challenge.Binding{Origin: "https://app.example.com", ClientID: "wallet-web", CodeVerifier: verifier}
+	cases := []struct {
+		name string
+		mod  func(b *challenge.Binding)
+		want error
+	}{
+		{"match", func(*challenge.Binding) {}, nil},
+		{"empty binding", func(b *challenge.Binding) { *b = challenge.Binding{} }, challenge.ErrBindingMismatch},
+		{"other origin", func(b *challenge.Binding) { b.Origin = "https://evil.example.com" }, challenge.ErrBindingMismatch},
+		{"missing origin", func(b *challenge.Binding) { b.Origin = "" }, challenge.ErrBindingMismatch},
+		{"other client", func(b *challenge.Binding) { b.ClientID = "other" }, challenge.ErrBindingMismatch},
+		{"missing verifier", func(b *challenge.Binding) { b.CodeVerifier = "" }, challenge.ErrBindingMismatch},
+		{"wrong verifier", func(b *challenge.Binding) { b.CodeVerifier = codeChallenge }, challenge.ErrBindingMismatch},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			b := good
+			tc.mod(&b)
+			_, err := gen.Verify(s, testDID, b)
+			if !errors.Is(err, tc.want) {
+				t.Fatalf("err = %v, want %v", err, tc.want)
+			}
+		})
+	}
+
+	// Unbound challenges verify with an empty binding
+	plain, err := gen.Generate(testDID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := gen.Verify(plain.String(), testDID, challenge.Binding{}); err != nil {
+		t.Fatalf("unbound challenge: %v", err)
+	}
+}
+
+func TestValidateEnforcesTTL(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	want := challenge.Expected{DID: testDID, Audience: "did-gateway", Domain: "localhost", Now: now, TTL: 5 * time.Minute}
+	cases := []struct {
+		name    string
+		version int
+		iat     int64
+		exp     int64
+		ok      bool
+	}{
+		{"v2 within ttl", challenge.Version2, now.Unix(), now.Add(5 * time.Minute).Unix(), true},
+		{"v2 within skew", challenge.Version2, now.Unix(), now.Add(5*time.Minute + challenge.ClockSkew).Unix(), true},
+		{"v2 beyond ttl", challenge.Version2, now.Unix(), now.Add(time.Hour).Unix(), false},
+		{"v2 years ahead", challenge.Version2, now.Unix(), now.AddDate(5, 0, 0).Unix(), false},
+		{"v1 within ttl", challenge.Version1, 0, now.Add(4 * time.Minute).Unix(), true},
+		{"v1 beyond ttl", challenge.Version1, 0, now.Add(time.Hour).Unix(), false},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			ch := challenge.Challenge{
+				Version: c.version, DID: testDID, Nonce: "n", Audience: "did-gateway", Domain: "localhost",
+				IssuedAt: c.iat, ExpiresAt: c.exp,
+			}
+			parsed, err := challenge.Parse(ch.String())
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = parsed.Validate(want)
+			if c.ok && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !c.ok && !errors.Is(err, challenge.ErrMalformed) {
+				t.Fatalf("err = %v, want ErrMalformed", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,145 @@
+package challenge
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"time"
+
+	"github.com/example/privacy-gateway/internal/shared/clock"
+)
+
+// MinNonceBytes is the smallest nonce the generator will issue
+const MinNonceBytes = 16
+
+// Config controls how challenges are issued
+type Config struct {
+	Audience   string
+	Domain     string
+	TTL        time.Duration // Challenge lifetime (default 5m)
+	NonceBytes int           // Random bytes per nonce (default 32, min 16)
+	Version    int           // Format to issue (default CurrentVersion)
+	// Region prefixes nonces with the issuing region ("eu-west-1.<nonce>")
+	// in multi-region deployments, so single-use checks know where a nonce
+	// came from (see region.Guard)
+	Region string
+	Clock  clock.Clock // Issue and expiry times (default clock.Real)
+}
+
+// Generator issues challenges for a configured audience and domain
+type Generator struct {
+	cfg Config
+}
+
+// NewGenerator creates a generator. Set Version to Version1 to keep issuing
+// the old format until every wallet understands the new one; Parse accepts
+// both regardless.
+func NewGenerator(cfg Config) (*Generator, error) {
+	if cfg.TTL == 0 {
+		cfg.TTL = 5 * time.Minute
+	}
+	if cfg.NonceBytes == 0 {
+		cfg.NonceBytes = 32
+	}
+	if cfg.Version == 0 {
+		cfg.Version = CurrentVersion
+	}
+	cfg.Clock = clock.Or(cfg.Clock)
+	if cfg.NonceBytes < MinNonceBytes {
+		return nil, fmt.Errorf("challenge nonce must be at least %d bytes", MinNonceBytes)
+	}
+	if cfg.Version != Version1 && cfg.Version != Version2 {
+		return nil, fmt.Errorf("%w: %d", ErrUnsupportedVersion, cfg.Version)
+	}
+	return &Generator{cfg: cfg}, nil
+}
+
+// TTL returns the configured challenge lifetime
+func (g *Generator) TTL() time.Duration {
+	return g.cfg.TTL
+}
+
+// Clock returns the clock challenges are stamped and checked with
+func (g *Generator) Clock() clock.Clock {
+	return g.cfg.Clock
+}
+
+// Generate issues a fresh challenge for did
+func (g *Generator) Generate(did string) (Challenge, error) {
+	nonce, err := g.NewNonce()
+	if err != nil {
+		return Challenge{}, err
+	}
+	return g.GenerateWithNonce(did, nonce)
+}
+
+// NewNonce returns a fresh nonce to issue later with GenerateWithNonce, for
+// flows that hand out a challenge reference before the DID is known
+func (g *Generator) NewNonce() (string, error) {
+	buf := make([]byte, g.cfg.NonceBytes)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return g.nonce(buf), nil
+}
+
+// GenerateWithNonce issues a challenge for did carrying a nonce from NewNonce
+func (g *Generator) GenerateWithNonce(did, nonce string) (Challenge, error) {
+	now := g.cfg.Clock.Now()
+	c := Challenge{
+		Version:   g.cfg.Version,
+		DID:       did,
+		Nonce:     nonce,
+		Audience:  g.cfg.Audience,
+		Domain:    g.cfg.Domain,
+		ExpiresAt: now.Add(g.cfg.TTL).Unix(),
+	}
+	if c.Version >= Version2 {
+		c.IssuedAt = now.Unix()
+	}
+	if err := c.Validate(g.expected(did, now)); err != nil {
+		return Challenge{}, err
+	}
+	return c, nil
+}
+
+// nonce encodes random bytes as a nonce, tagged with the region if set
+func (g *Generator) nonce(buf []byte) string {
+	n := base64.RawURLEncoding.EncodeToString(buf)
+	if g.cfg.Region != "" {
+		n = g.cfg.Region + "." + n
+	}
+	return n
+}
+
+// Verify parses a challenge string presented at verify and validates it
+// against the configured audience and domain. did is the DID the caller
+// claims to be authenticating, and b what the verify request presents for
+// the origin, client_id and PKCE bindings: every binding the challenge
+// carries must match, so a bound challenge fails with an empty Binding.
+func (g *Generator) Verify(s, did string, b Binding) (Challenge, error) {
+	c, err := g.verify(s, did)
+	if err != nil {
+		return Challenge{}, err
+	}
+	if err := c.VerifyBinding(b); err != nil {
+		return Challenge{}, err
+	}
+	return c, nil
+}
+
+// verify is Verify without the binding checks
+func (g *Generator) verify(s, did string) (Challenge, error) {
+	c, err := Parse(s)
+	if err != nil {
+		return Challenge{}, err
+	}
+	if err := c.Validate(g.expected(did, g.cfg.Clock.Now())); err != nil {
+		return Challenge{}, err
+	}
+	return c, nil
+}
+
+func (g *Generator) expected(did string, now time.Time) Expected {
+	return Expected{DID: did, Audience: g.cfg.Audience, Domain: g.cfg.Domain, Now: now, TTL: g.cfg.TTL}
+}
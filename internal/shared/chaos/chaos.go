@@ -0,0 +1,246 @@
+package chaos
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/example/privacy-gateway/internal/shared/observability"
+)
+
+// Faults
+const (
+	FaultLatency = "latency" // Delay, then carry on
+	FaultError   = "error"   // Fail: an error status for routes and HTTP dependencies, an error otherwise
+	FaultDrop    = "drop"    // Close the connection without a response
+)
+
+// Header carries per-request faults when Config.Header is set, e.g.
+// "latency=300ms, error@redis"; see ParseHeader
+const Header = "X-Chaos"
+
+var (
+	ErrNotAllowed  = errors.New("fault injection is only allowed in dev, test and staging")
+	ErrInvalidRule = errors.New("invalid fault rule")
+	ErrInjected    = errors.New("injected fault")
+)
+
+// allowedEnvironments may enable fault injection
+var allowedEnvironments = map[string]bool{
+	"dev":         true,
+	"development": true,
+	"test":        true,
+	"staging":     true,
+}
+
+// Rule injects a fault into requests on a route or calls to a dependency
+type Rule struct {
+	Route      string `json:"route,omitempty"`      // Request path prefix
+	Dependency string `json:"dependency,omitempty"` // Dependency name, e.g. redis or upstream
+	Fault      string `json:"fault"`
+	LatencyMS  int    `json:"latency_ms,omitempty"` // For latency faults
+	Status     int    `json:"status,omitempty"`     // For error faults on routes and HTTP dependencies (default 503)
+	// Percent of matching requests or calls affected, 0-100 (default 100)
+	Percent float64 `json:"percent,omitempty"`
+}
+
+func (r Rule) validate() (Rule, error) {
+	switch {
+	case (r.Route == "") == (r.Dependency == ""):
+		return r, fmt.Errorf("%w: set exactly one of route and dependency", ErrInvalidRule)
+	case r.Percent < 0 || r.Percent > 100:
+		return r, fmt.Errorf("%w: percent %v out of range", ErrInvalidRule, r.Percent)
+	}
+	switch r.Fault {
+	case FaultLatency:
+		if r.LatencyMS <= 0 {
+			return r, fmt.Errorf("%w: latency fault without latency_ms", ErrInvalidRule)
+		}
+	case FaultError:
+		if r.Status == 0 {
+			r.Status = 503
+		}
+		if r.Status < 400 || r.Status > 599 {
+			return r, fmt.Errorf("%w: status %d is not an error", ErrInvalidRule, r.Status)
+		}
+	case FaultDrop:
+	default:
+		return r, fmt.Errorf("%w: unknown fault %q", ErrInvalidRule, r.Fault)
+	}
+	if r.Percent == 0 {
+		r.Percent = 100
+	}
+	return r, nil
+}
+
+// ParseHeader parses an X-Chaos value: comma-separated faults, each
+// "<fault>[=<arg>][@<dependency>]". Without a dependency the fault applies
+// to the request itself. For example:
+//
+//	latency=300ms        delay the request
+//	error=502            answer 502 without serving the request
+//	drop                 close the connection
+//	error@redis          fail every Redis call the request makes
+//	latency=2s@upstream  slow the upstream call down
+func ParseHeader(v string) ([]Rule, error) {
+	var rules []Rule
+	for _, part := range strings.Split(v, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		spec, dep, _ := strings.Cut(part, "@")
+		fault, arg, hasArg := strings.Cut(spec, "=")
+		r := Rule{Fault: strings.TrimSpace(fault), Dependency: strings.TrimSpace(dep), Route: "/"}
+		if r.Dependency != "" {
+			r.Route = ""
+		}
+		arg = strings.TrimSpace(arg)
+		switch {
+		case r.Fault == FaultLatency:
+			d, err := time.ParseDuration(arg)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %q: latency needs a duration", ErrInvalidRule, part)
+			}
+			r.LatencyMS = int(d.Milliseconds())
+		case r.Fault == FaultError && hasArg:
+			status, err := strconv.Atoi(arg)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %q: error takes a status", ErrInvalidRule, part)
+			}
+			r.Status = status
+		case hasArg:
+			return nil, fmt.Errorf("%w: %q takes no argument", ErrInvalidRule, part)
+		}
+		r, err := r.validate()
+		if err != nil {
+			return nil, fmt.Errorf("%w (in %q)", err, part)
+		}
+		rules = append(rules, r)
+	}
+	return rules, nil
+}
+
+// Config configures fault injection
+type Config struct {
+	// Environment must be dev, development, test or staging; anything else,
+	// including empty, refuses to create an injector
+	Environment string
+	Rules       []Rule
+	// Header honors X-Chaos request headers. Anyone who can reach the
+	// gateway can then inject faults, so keep it to test environments.
+	Header bool
+	Logger *slog.Logger
+}
+
+// Injector injects configured and header-requested faults into requests
+// (Middleware) and dependency calls (Dependency, RedisHook, Transport)
+type Injector struct {
+	header bool
+	logger *slog.Logger
+	rand   func() float64
+
+	mu    sync.RWMutex
+	rules []Rule
+}
+
+// NewInjector creates an injector; it fails outside dev, test and staging
+func NewInjector(cfg Config) (*Injector, error) {
+	if !allowedEnvironments[strings.ToLower(cfg.Environment)] {
+		return nil, fmt.Errorf("%w: environment is %q", ErrNotAllowed, cfg.Environment)
+	}
+	i := &Injector{
+		header: cfg.Header,
+		logger: observability.Component(cfg.Logger, observability.ComponentChaos),
+		rand:   rand.Float64,
+	}
+	if err := i.SetRules(cfg.Rules); err != nil {
+		return nil, err
+	}
+	i.logger.Warn("fault injection enabled", "environment", cfg.Environment, "rules", len(cfg.Rules), "header", cfg.Header)
+	return i, nil
+}
+
+// SetRules replaces the rules if all of them are valid
+func (i *Injector) SetRules(rules []Rule) error {
+	valid := make([]Rule, 0, len(rules))
+	for n, r := range rules {
+		r, err := r.validate()
+		if err != nil {
+			return fmt.Errorf("%w (rule %d)", err, n)
+		}
+		valid = append(valid, r)
+	}
+	i.mu.Lock()
+	i.rules = valid
+	i.mu.Unlock()
+	return nil
+}
+
+// Rules returns the configured rules
+func (i *Injector) Rules() []Rule {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	return append([]Rule(nil), i.rules...)
+}
+
+type ctxKey struct{}
+
+// withRequestRules attaches header-requested dependency faults to ctx
+func withRequestRules(ctx context.Context, rules []Rule) context.Context {
+	return context.WithValue(ctx, ctxKey{}, rules)
+}
+
+// inject applies the faults matching a route (dep empty) or a dependency:
+// it sleeps out latency faults and returns the first error or drop fault
+// that fires. The error is ctx's if it ends during a delay.
+func (i *Injector) inject(ctx context.Context, route, dep string, extra []Rule) (*Rule, error) {
+	i.mu.RLock()
+	rules := append(i.rules[:len(i.rules):len(i.rules)], extra...)
+	i.mu.RUnlock()
+	if reqRules, ok := ctx.Value(ctxKey{}).([]Rule); ok && dep != "" {
+		rules = append(rules, reqRules...)
+	}
+
+	for n := range rules {
+		r := &rules[n]
+		if dep != "" && r.Dependency != dep || dep == "" && (r.Route == "" || !strings.HasPrefix(route, r.Route)) {
+			continue
+		}
+		if r.Percent < 100 && i.rand()*100 >= r.Percent {
+			continue
+		}
+		i.logger.Debug("injecting fault", "fault", r.Fault, "route", route, "dependency", dep, "latency_ms", r.LatencyMS, "status", r.Status)
+		if r.Fault != FaultLatency {
+			return r, nil
+		}
+		t := time.NewTimer(time.Duration(r.LatencyMS) * time.Millisecond)
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			t.Stop()
+			return nil, ctx.Err()
+		}
+	}
+	return nil, nil
+}
+
+// Dependency injects the faults for a call to the named dependency. Call it
+// before the call and return its error in place of the call's; error and
+// drop faults are errors wrapping ErrInjected.
+func (i *Injector) Dependency(ctx context.Context, name string) error {
+	r, err := i.inject(ctx, "", name, nil)
+	if err != nil || r == nil {
+		return err
+	}
+	if r.Fault == FaultDrop {
+		return fmt.Errorf("%w: %s: connection dropped", ErrInjected, name)
+	}
+	return fmt.Errorf("%w: %s unavailable", ErrInjected, name)
+}
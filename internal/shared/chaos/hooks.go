@@ -0,0 +1,102 @@
+package chaos
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisHook returns a go-redis hook injecting the faults of dependency name
+// (usually "redis") into dials and commands, e.g. to check the L2 breaker
+// opens and the cache degrades to L1 while Redis is down:
+//
+//	client.AddHook(chaos.RedisHook(injector, "redis"))
+func RedisHook(i *Injector, name string) redis.Hook {
+	return redisHook{i: i, name: name}
+}
+
+type redisHook struct {
+	i    *Injector
+	name string
+}
+
+func (h redisHook) DialHook(next redis.DialHook) redis.DialHook {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if err := h.i.Dependency(ctx, h.name); err != nil {
+			return nil, err
+		}
+		return next(ctx, network, addr)
+	}
+}
+
+func (h redisHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		if err := h.i.Dependency(ctx, h.name); err != nil {
+			cmd.SetErr(err)
+			return err
+		}
+		return next(ctx, cmd)
+	}
+}
+
+func (h redisHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		if err := h.i.Dependency(ctx, h.name); err != nil {
+			for _, cmd := range cmds {
+				cmd.SetErr(err)
+			}
+			return err
+		}
+		return next(ctx, cmds)
+	}
+}
+
+// Transport wraps base (nil: http.DefaultTransport) to inject the faults of
+// dependency name into outgoing requests: latency before the request, an
+// error fault as a synthetic response with its status, a drop as a
+// connection error
+func Transport(i *Injector, name string, base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &transport{i: i, name: name, base: base}
+}
+
+type transport struct {
+	i    *Injector
+	name string
+	base http.RoundTripper
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	fault, err := t.i.inject(req.Context(), "", t.name, nil)
+	if err != nil {
+		return nil, err
+	}
+	if fault == nil {
+		return t.base.RoundTrip(req)
+	}
+	if req.Body != nil {
+		req.Body.Close()
+	}
+	if fault.Fault == FaultDrop {
+		return nil, fmt.Errorf("%w: %s: %w", ErrInjected, t.name, io.ErrUnexpectedEOF)
+	}
+	const body = `{"error":"injected fault"}`
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", fault.Status, http.StatusText(fault.Status)),
+		StatusCode:    fault.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": {"application/json"}, "X-Chaos-Injected": {FaultError}},
+		Body:          io.NopCloser(strings.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
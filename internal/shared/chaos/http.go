@@ -0,0 +1,80 @@
+package chaos
+
+import (
+	"net/http"
+
+	"github.com/example/privacy-gateway/internal/shared/httpx"
+)
+
+// Middleware injects the faults of rules matching the request path and,
+// when enabled, of its X-Chaos header. Header faults naming a dependency
+// apply to that dependency's calls made while serving the request.
+func (i *Injector) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var requested []Rule
+		if v := r.Header.Get(Header); v != "" && i.header {
+			rules, err := ParseHeader(v)
+			if err != nil {
+				httpx.WriteJSON(w, http.StatusBadRequest, httpx.ErrorResponse{Error: err.Error()})
+				return
+			}
+			requested = rules
+			r = r.WithContext(withRequestRules(r.Context(), rules))
+		}
+
+		fault, err := i.inject(r.Context(), r.URL.Path, "", requested)
+		if err != nil {
+			return // Client went away during injected latency
+		}
+		if fault == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Set("X-Chaos-Injected", fault.Fault)
+		if fault.Fault == FaultError {
+			httpx.WriteJSON(w, fault.Status, httpx.ErrorResponse{Error: "injected fault"})
+			return
+		}
+		if conn, _, err := http.NewResponseController(w).Hijack(); err == nil {
+			conn.Close()
+			return
+		}
+		// HTTP/2 can't hijack; aborting resets the stream instead
+		panic(http.ErrAbortHandler)
+	})
+}
+
+// rulesBody is the body of RulesHandler
+type rulesBody struct {
+	Rules []Rule `json:"rules"`
+}
+
+// RulesHandler serves the fault rules, mounted behind admin auth:
+//
+//	GET /admin/v1/chaos  current rules
+//	PUT /admin/v1/chaos  {"rules": [{"dependency": "redis", "fault": "error"}]} replaces them
+//
+// A PUT with any invalid rule changes nothing; {"rules": []} stops injecting.
+func RulesHandler(i *Injector) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPut:
+			var req rulesBody
+			if err := httpx.DecodeJSONLimit(r, &req, 64<<10); err != nil {
+				httpx.WriteJSON(w, http.StatusBadRequest, httpx.ErrorResponse{Error: "invalid request body"})
+				return
+			}
+			if err := i.SetRules(req.Rules); err != nil {
+				httpx.WriteJSON(w, http.StatusBadRequest, httpx.ErrorResponse{Error: err.Error()})
+				return
+			}
+			i.logger.Warn("fault rules changed", "rules", len(req.Rules))
+		default:
+			w.Header().Set("Allow", "GET, PUT")
+			httpx.WriteJSON(w, http.StatusMethodNotAllowed, httpx.ErrorResponse{Error: "method not allowed"})
+			return
+		}
+		httpx.WriteJSON(w, http.StatusOK, rulesBody{Rules: i.Rules()})
+	}
+}
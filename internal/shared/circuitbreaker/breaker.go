@@ -5,6 +5,8 @@ import (
 	"errors"
 	"sync"
 	"time"
+
+	"github.com/example/privacy-gateway/internal/shared/clock"
 )
 
 // State represents the circuit breaker state
@@ -17,8 +19,9 @@ const (
 )
 
 var (
-	ErrCircuitOpen = errors.New("circuit breaker is open")
-	ErrTimeout     = errors.New("operation timed out")
+	ErrCircuitOpen  = errors.New("circuit breaker is open")
+	ErrTimeout      = errors.New("operation timed out")
+	ErrTooManyCalls = errors.New("circuit breaker concurrency limit reached")
 )
 
 // CircuitBreaker prevents cascading failures by failing fast when a service is down
@@ -26,25 +29,40 @@ type CircuitBreaker struct {
 	maxFailures  int
 	timeout      time.Duration
 	resetTimeout time.Duration
+	slots        chan struct{} // Concurrency limit; nil = unlimited
+	halfOpenMax  int
+	successesReq int
+	clock        clock.Clock
 
-	mu           sync.RWMutex
-	state        State
-	failures     int
-	successes    int
-	lastFailTime time.Time
+	mu              sync.RWMutex
+	state           State
+	failures        int
+	successes       int
+	probes          int   // Half-open calls in flight
+	probeGen        int64 // Bumped on every move to half-open
+	lastFailTime    time.Time
 	lastStateChange time.Time
-	
+
 	// Metrics
-	totalCalls   int64
-	totalSuccess int64
-	totalFailure int64
+	totalCalls    int64
+	totalSuccess  int64
+	totalFailure  int64
+	totalRejected int64
 }
 
 // Config holds circuit breaker configuration
 type Config struct {
-	MaxFailures  int           // Number of failures before opening
-	Timeout      time.Duration // Max duration for a single call
-	ResetTimeout time.Duration // Time to wait before trying again
+	MaxFailures   int           // Number of failures before opening
+	Timeout       time.Duration // Max duration for a single call
+	ResetTimeout  time.Duration // Time to wait before trying again
+	MaxConcurrent int           // Calls in flight at once (0 = unlimited); extra calls fail with ErrTooManyCalls
+
+	HalfOpenMaxCalls int // Probe calls in flight while half-open (0 = unlimited); extra calls fail with ErrCircuitOpen
+	SuccessThreshold int // Successful probes needed to close again (default 3)
+
+	// Clock times the reset timeout (default clock.Real). The per-call
+	// Timeout is a context deadline and always runs on real time.
+	Clock clock.Clock
 }
 
 // New creates a new circuit breaker
@@ -58,48 +76,75 @@ func New(cfg Config) *CircuitBreaker {
 	if cfg.ResetTimeout == 0 {
 		cfg.ResetTimeout = 60 * time.Second
 	}
+	if cfg.SuccessThreshold == 0 {
+		cfg.SuccessThreshold = 3
+	}
 
-	return &CircuitBreaker{
-		maxFailures:  cfg.MaxFailures,
-		timeout:      cfg.Timeout,
-		resetTimeout: cfg.ResetTimeout,
-		state:        StateClosed,
-		lastStateChange: time.Now(),
+	cb := &CircuitBreaker{
+		maxFailures:     cfg.MaxFailures,
+		timeout:         cfg.Timeout,
+		resetTimeout:    cfg.ResetTimeout,
+		halfOpenMax:     cfg.HalfOpenMaxCalls,
+		successesReq:    cfg.SuccessThreshold,
+		clock:           clock.Or(cfg.Clock),
+		state:           StateClosed,
+		lastStateChange: clock.Or(cfg.Clock).Now(),
+	}
+	if cfg.MaxConcurrent > 0 {
+		cb.slots = make(chan struct{}, cfg.MaxConcurrent)
 	}
+	return cb
 }
 
-// Call executes the given function with circuit breaker protection
+// Call executes the given function with circuit breaker protection. fn
+// runs on the caller's goroutine with a context bounded by the breaker's
+// timeout, so it must honour cancellation: a timed out call is over once fn
+// returns, instead of running on in the background. A call cancelled by the
+// caller is not counted as a failure; one that runs out of time is, whether
+// the breaker's deadline or the caller's expired first.
 func (cb *CircuitBreaker) Call(ctx context.Context, fn func(context.Context) error) error {
-	if !cb.canAttempt() {
+	if cb.slots != nil {
+		select {
+		case cb.slots <- struct{}{}:
+			defer func() { <-cb.slots }()
+		default:
+			cb.mu.Lock()
+			cb.totalRejected++
+			cb.mu.Unlock()
+			return ErrTooManyCalls
+		}
+	}
+	probe, ok := cb.canAttempt()
+	if !ok {
 		return ErrCircuitOpen
 	}
+	if probe >= 0 {
+		defer cb.endProbe(probe)
+	}
 
 	// Create timeout context
 	callCtx, cancel := context.WithTimeout(ctx, cb.timeout)
 	defer cancel()
 
-	// Execute with timeout
-	errCh := make(chan error, 1)
-	go func() {
-		errCh <- fn(callCtx)
-	}()
-
-	select {
-	case err := <-errCh:
-		if err != nil {
-			cb.recordFailure()
-			return err
-		}
+	err := fn(callCtx)
+	switch {
+	case err == nil:
 		cb.recordSuccess()
 		return nil
-	case <-callCtx.Done():
+	case errors.Is(ctx.Err(), context.Canceled):
+		return err
+	case callCtx.Err() != nil:
 		cb.recordFailure()
 		return ErrTimeout
+	default:
+		cb.recordFailure()
+		return err
 	}
 }
 
-// canAttempt checks if a request can be attempted
-func (cb *CircuitBreaker) canAttempt() bool {
+// canAttempt checks if a request can be attempted. Half-open calls are
+// probes; probe is the half-open generation for endProbe, or -1.
+func (cb *CircuitBreaker) canAttempt() (probe int64, ok bool) {
 	cb.mu.Lock()
 	defer cb.mu.Unlock()
 
@@ -107,22 +152,38 @@ func (cb *CircuitBreaker) canAttempt() bool {
 
 	switch cb.state {
 	case StateClosed:
-		return true
+		return -1, true
 	case StateOpen:
 		// Check if we should transition to half-open
-		if time.Since(cb.lastFailTime) > cb.resetTimeout {
+		if cb.clock.Now().Sub(cb.lastFailTime) > cb.resetTimeout {
 			cb.state = StateHalfOpen
 			cb.successes = 0
-			cb.lastStateChange = time.Now()
-			return true
+			cb.probes = 1
+			cb.probeGen++
+			cb.lastStateChange = cb.clock.Now()
+			return cb.probeGen, true
 		}
-		return false
+		return -1, false
 	case StateHalfOpen:
 		// Allow limited requests in half-open state
-		return true
+		if cb.halfOpenMax > 0 && cb.probes >= cb.halfOpenMax {
+			return -1, false
+		}
+		cb.probes++
+		return cb.probeGen, true
 	}
 
-	return false
+	return -1, false
+}
+
+// endProbe releases a half-open slot, unless the breaker has left that
+// half-open period since
+func (cb *CircuitBreaker) endProbe(gen int64) {
+	cb.mu.Lock()
+	if gen == cb.probeGen && cb.probes > 0 {
+		cb.probes--
+	}
+	cb.mu.Unlock()
 }
 
 // recordFailure records a failed call
@@ -132,17 +193,17 @@ func (cb *CircuitBreaker) recordFailure() {
 
 	cb.totalFailure++
 	cb.failures++
-	cb.lastFailTime = time.Now()
+	cb.lastFailTime = cb.clock.Now()
 
 	if cb.state == StateHalfOpen {
 		// If fails in half-open, go back to open
 		cb.state = StateOpen
 		cb.failures = 0
-		cb.lastStateChange = time.Now()
+		cb.lastStateChange = cb.clock.Now()
 	} else if cb.failures >= cb.maxFailures {
 		// Open the circuit
 		cb.state = StateOpen
-		cb.lastStateChange = time.Now()
+		cb.lastStateChange = cb.clock.Now()
 	}
 }
 
@@ -156,11 +217,11 @@ func (cb *CircuitBreaker) recordSuccess() {
 	if cb.state == StateHalfOpen {
 		cb.successes++
 		// After a few successes in half-open, close the circuit
-		if cb.successes >= 3 {
+		if cb.successes >= cb.successesReq {
 			cb.state = StateClosed
 			cb.failures = 0
 			cb.successes = 0
-			cb.lastStateChange = time.Now()
+			cb.lastStateChange = cb.clock.Now()
 		}
 	} else {
 		cb.failures = 0
@@ -180,23 +241,27 @@ func (cb *CircuitBreaker) Stats() Stats {
 	defer cb.mu.RUnlock()
 
 	return Stats{
-		State:        cb.state,
-		Failures:     cb.failures,
-		TotalCalls:   cb.totalCalls,
-		TotalSuccess: cb.totalSuccess,
-		TotalFailure: cb.totalFailure,
-		LastFailTime: cb.lastFailTime,
+		State:         cb.state,
+		Failures:      cb.failures,
+		TotalCalls:    cb.totalCalls,
+		TotalSuccess:  cb.totalSuccess,
+		TotalFailure:  cb.totalFailure,
+		TotalRejected: cb.totalRejected,
+		InFlight:      len(cb.slots),
+		LastFailTime:  cb.lastFailTime,
 	}
 }
 
 // Stats holds circuit breaker statistics
 type Stats struct {
-	State        State
-	Failures     int
-	TotalCalls   int64
-	TotalSuccess int64
-	TotalFailure int64
-	LastFailTime time.Time
+	State         State
+	Failures      int
+	TotalCalls    int64
+	TotalSuccess  int64
+	TotalFailure  int64
+	TotalRejected int64 // Over MaxConcurrent
+	InFlight      int   // Only tracked with MaxConcurrent
+	LastFailTime  time.Time
 }
 
 // Reset manually resets the circuit breaker to closed state
@@ -207,5 +272,5 @@ func (cb *CircuitBreaker) Reset() {
 	cb.state = StateClosed
 	cb.failures = 0
 	cb.successes = 0
-	cb.lastStateChange = time.Now()
+	cb.lastStateChange = cb.clock.Now()
 }
@@ -0,0 +1,132 @@
+package clock
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock tells the time. Components that expire things (challenges, tokens,
+// cache entries, breaker resets) take one in their config so tests can move
+// time forward instead of sleeping.
+type Clock interface {
+	Now() time.Time
+}
+
+// Real is the system clock
+var Real Clock = realClock{}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time { return time.Now() }
+
+// Or returns c, or Real if c is nil; use it to default Clock config fields
+func Or(c Clock) Clock {
+	if c == nil {
+		return Real
+	}
+	return c
+}
+
+// Fake is a clock that only moves when told to. It is safe for concurrent
+// use.
+type Fake struct {
+	mu      sync.Mutex
+	now     time.Time
+	tickers map[*fakeTicker]struct{}
+}
+
+// NewFake creates a fake clock reading now
+func NewFake(now time.Time) *Fake {
+	return &Fake{now: now}
+}
+
+// Now returns the fake time
+func (f *Fake) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+// Advance moves the clock forward by d, firing tickers that come due
+func (f *Fake) Advance(d time.Duration) {
+	f.mu.Lock()
+	f.now = f.now.Add(d)
+	f.fire()
+	f.mu.Unlock()
+}
+
+// Set moves the clock to t, which may be earlier, firing tickers that come
+// due
+func (f *Fake) Set(t time.Time) {
+	f.mu.Lock()
+	f.now = t
+	f.fire()
+	f.mu.Unlock()
+}
+
+// Ticker delivers ticks like time.Ticker. Like time.Ticker it drops ticks
+// for a slow receiver.
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+}
+
+// NewTicker returns a ticker with period d on c. A Fake clock's tickers fire
+// when it is advanced; any other clock gets a real ticker.
+func NewTicker(c Clock, d time.Duration) Ticker {
+	if f, ok := c.(*Fake); ok {
+		return f.newTicker(d)
+	}
+	return realTicker{time.NewTicker(d)}
+}
+
+type realTicker struct{ t *time.Ticker }
+
+func (r realTicker) C() <-chan time.Time { return r.t.C }
+func (r realTicker) Stop()               { r.t.Stop() }
+
+// fakeTicker fires when its Fake clock passes next
+type fakeTicker struct {
+	f      *Fake
+	c      chan time.Time
+	period time.Duration
+	next   time.Time
+}
+
+func (t *fakeTicker) C() <-chan time.Time { return t.c }
+
+func (t *fakeTicker) Stop() {
+	t.f.mu.Lock()
+	defer t.f.mu.Unlock()
+	delete(t.f.tickers, t)
+}
+
+func (f *Fake) newTicker(d time.Duration) *fakeTicker {
+	if d <= 0 {
+		panic("clock: non-positive interval for NewTicker")
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	t := &fakeTicker{f: f, c: make(chan time.Time, 1), period: d, next: f.now.Add(d)}
+	if f.tickers == nil {
+		f.tickers = make(map[*fakeTicker]struct{})
+	}
+	f.tickers[t] = struct{}{}
+	return t
+}
+
+// fire sends one tick on every ticker that is due. Called with f.mu held.
+func (f *Fake) fire() {
+	for t := range f.tickers {
+		if f.now.Before(t.next) {
+			continue
+		}
+		select {
+		case t.c <- f.now:
+		default:
+		}
+		for !f.now.Before(t.next) {
+			t.next = t.next.Add(t.period)
+		}
+	}
+}
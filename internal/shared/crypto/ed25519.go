@@ -12,6 +12,9 @@ import (
 
 var ed25519Prefix = []byte{0xed, 0x01}
 
+// decoyKey is a fixed key for VerifyDecoy, whose result is discarded
+var decoyKey = ed25519.NewKeyFromSeed(make([]byte, ed25519.SeedSize)).Public().(ed25519.PublicKey)
+
 func GenerateEd25519Key() (ed25519.PublicKey, ed25519.PrivateKey, error) {
 	pub, priv, err := ed25519.GenerateKey(rand.Reader)
 	return pub, priv, err
@@ -23,11 +26,20 @@ func EncodeDidKey(pub ed25519.PublicKey) string {
 	return "did:key:z" + base58.Encode(buf)
 }
 
+// maxDidKeyLen bounds the base58 part of an Ed25519 did:key (48 characters)
+// before decoding, which is quadratic in the input length
+const maxDidKeyLen = 64
+
+// DecodeDidKey returns the Ed25519 key of a did:key. The input is untrusted
+// and never panics the decoder.
 func DecodeDidKey(did string) (ed25519.PublicKey, error) {
 	if !strings.HasPrefix(did, "did:key:z") {
 		return nil, errors.New("unsupported DID method")
 	}
 	enc := strings.TrimPrefix(did, "did:key:z")
+	if len(enc) > maxDidKeyLen {
+		return nil, errors.New("invalid did:key length")
+	}
 	raw, err := base58.Decode(enc)
 	if err != nil {
 		return nil, err
@@ -74,3 +86,30 @@ func DecodePublicKey(enc string) (ed25519.PublicKey, error) {
 	}
 	return ed25519.PublicKey(raw), nil
 }
+
+// VerifySignature checks a base64url Ed25519 signature over msg. The signature
+// is decoded into a stack buffer so the hot verify path doesn't allocate.
+func VerifySignature(pub ed25519.PublicKey, msg, sig string) error {
+	var buf [ed25519.SignatureSize]byte
+	if len(pub) != ed25519.PublicKeySize {
+		// ed25519.Verify panics on a key of the wrong size
+		return errors.New("invalid public key size")
+	}
+	if base64.RawURLEncoding.DecodedLen(len(sig)) != ed25519.SignatureSize {
+		return errors.New("invalid signature size")
+	}
+	if _, err := base64.RawURLEncoding.Decode(buf[:], []byte(sig)); err != nil {
+		return errors.New("invalid signature encoding")
+	}
+	if !ed25519.Verify(pub, []byte(msg), buf[:]) {
+		return errors.New("signature verification failed")
+	}
+	return nil
+}
+
+// VerifyDecoy does the work of VerifySignature and throws the result away.
+// Call it on failure paths that have no key to check (an undecodable DID, a
+// document without a usable key) so they cost the same as a bad signature.
+func VerifyDecoy(msg, sig string) {
+	_ = VerifySignature(decoyKey, msg, sig)
+}
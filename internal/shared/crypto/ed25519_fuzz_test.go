@@ -0,0 +1,48 @@
+package crypto_test
+
+import (
+	"crypto/ed25519"
+	"testing"
+
+	"github.com/example/privacy-gateway/internal/shared/crypto"
+)
+
+func FuzzDecodeDidKey(f *testing.F) {
+	pub, _, err := crypto.GenerateEd25519Key()
+	if err != nil {
+		f.Fatal(err)
+	}
+	f.Add(crypto.EncodeDidKey(pub))
+	f.Add("did:key:z6MkhaXgBZDvotDkL5257faiztiGiC2QtKLGpbnnEGta2doK")
+	f.Add("did:key:z")
+	f.Add("did:key:z0OIl")
+	f.Add("did:web:example.com")
+
+	f.Fuzz(func(t *testing.T, did string) {
+		pub, err := crypto.DecodeDidKey(did)
+		if err != nil {
+			return
+		}
+		if len(pub) != ed25519.PublicKeySize {
+			t.Fatalf("decoded %d byte key", len(pub))
+		}
+		if got := crypto.EncodeDidKey(pub); got != did {
+			t.Fatalf("%q decoded to a key encoding as %q", did, got)
+		}
+	})
+}
+
+func FuzzVerifySignature(f *testing.F) {
+	pub, priv, err := crypto.GenerateEd25519Key()
+	if err != nil {
+		f.Fatal(err)
+	}
+	msg := "did=did:key:z6Mk\nnonce=abc\n"
+	f.Add([]byte(pub), msg, crypto.EncodePublicKey(ed25519.PublicKey(ed25519.Sign(priv, []byte(msg)))))
+	f.Add([]byte{}, "", "")
+	f.Add([]byte{1, 2, 3}, msg, "AAAA")
+
+	f.Fuzz(func(t *testing.T, key []byte, msg, sig string) {
+		_ = crypto.VerifySignature(ed25519.PublicKey(key), msg, sig)
+	})
+}
@@ -0,0 +1,25 @@
+package crypto
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+)
+
+// RandomToken returns n random bytes, base64url encoded, for use as an
+// opaque secret (session IDs, codes, poll secrets)
+func RandomToken(n int) (string, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// HashSecret returns the base64url SHA-256 of a secret for storage. Only
+// use it for high-entropy secrets such as RandomToken output; a plain hash
+// is enough there, and a slow KDF would only cost latency.
+func HashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
@@ -0,0 +1,141 @@
+package crypto
+
+import (
+	"crypto/ecdh"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"errors"
+	"math/big"
+	"strings"
+
+	"github.com/mr-tron/base58"
+)
+
+var x25519Prefix = []byte{0xec, 0x01}
+
+// fieldPrime is 2^255 - 19, the prime of both curve25519 forms
+var fieldPrime = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 255), big.NewInt(19))
+
+// minDeriveSecret is the shortest secret DeriveX25519Key accepts
+const minDeriveSecret = 32
+
+// GenerateX25519Key generates a random X25519 key agreement key
+func GenerateX25519Key() (*ecdh.PrivateKey, error) {
+	return ecdh.X25519().GenerateKey(rand.Reader)
+}
+
+// DeriveX25519Key derives an X25519 key from a long-term secret and a label
+// naming its use (e.g. "envelope-2024-06"), so one configured secret can back
+// several keys and the same label always yields the same key. The secret
+// must be at least 32 bytes of key material, not a password.
+func DeriveX25519Key(secret []byte, label string) (*ecdh.PrivateKey, error) {
+	if len(secret) < minDeriveSecret {
+		return nil, errors.New("derivation secret too short")
+	}
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte("x25519:" + label))
+	return ecdh.X25519().NewPrivateKey(mac.Sum(nil))
+}
+
+// X25519PrivateFromEd25519 converts an Ed25519 private key to the X25519
+// private key matching X25519FromEd25519 of its public key, so a holder of
+// a did:key can decrypt what was encrypted to the derived key
+func X25519PrivateFromEd25519(priv ed25519.PrivateKey) (*ecdh.PrivateKey, error) {
+	if len(priv) != ed25519.PrivateKeySize {
+		return nil, errors.New("invalid private key size")
+	}
+	h := sha512.Sum512(priv.Seed())
+	// X25519 clamps the scalar itself, as Ed25519 does
+	return ecdh.X25519().NewPrivateKey(h[:32])
+}
+
+// X25519FromEd25519 converts an Ed25519 public key to the X25519 key of the
+// same curve point (u = (1 + y) / (1 - y)), the key agreement key a did:key
+// document derives from its Ed25519 key
+func X25519FromEd25519(pub ed25519.PublicKey) (*ecdh.PublicKey, error) {
+	if len(pub) != ed25519.PublicKeySize {
+		return nil, errors.New("invalid public key size")
+	}
+	le := make([]byte, len(pub))
+	copy(le, pub)
+	le[31] &= 0x7f // Drop the sign bit of x
+	y := new(big.Int).SetBytes(reverse(le))
+	if y.Cmp(fieldPrime) >= 0 {
+		return nil, errors.New("invalid Ed25519 public key")
+	}
+	den := new(big.Int).Sub(big.NewInt(1), y)
+	den.Mod(den, fieldPrime)
+	if den.Sign() == 0 {
+		return nil, errors.New("invalid Ed25519 public key")
+	}
+	u := new(big.Int).Add(big.NewInt(1), y)
+	u.Mul(u, den.ModInverse(den, fieldPrime))
+	u.Mod(u, fieldPrime)
+	out := make([]byte, 32)
+	u.FillBytes(out)
+	return ecdh.X25519().NewPublicKey(reverse(out))
+}
+
+func EncodeX25519PrivateKey(priv *ecdh.PrivateKey) string {
+	return base64.RawURLEncoding.EncodeToString(priv.Bytes())
+}
+
+func DecodeX25519PrivateKey(enc string) (*ecdh.PrivateKey, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(enc)
+	if err != nil {
+		return nil, err
+	}
+	return ecdh.X25519().NewPrivateKey(raw)
+}
+
+func EncodeX25519PublicKey(pub *ecdh.PublicKey) string {
+	return base64.RawURLEncoding.EncodeToString(pub.Bytes())
+}
+
+func DecodeX25519PublicKey(enc string) (*ecdh.PublicKey, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(enc)
+	if err != nil {
+		return nil, err
+	}
+	return ecdh.X25519().NewPublicKey(raw)
+}
+
+// EncodeX25519Multibase encodes an X25519 key as multibase (base58btc) with
+// its multicodec prefix, as in X25519KeyAgreementKey2020 methods
+func EncodeX25519Multibase(pub *ecdh.PublicKey) string {
+	buf := append([]byte{}, x25519Prefix...)
+	buf = append(buf, pub.Bytes()...)
+	return "z" + base58.Encode(buf)
+}
+
+// DecodeX25519Multibase decodes a multibase X25519 key. The input is
+// untrusted and never panics the decoder.
+func DecodeX25519Multibase(enc string) (*ecdh.PublicKey, error) {
+	if !strings.HasPrefix(enc, "z") {
+		return nil, errors.New("unsupported multibase encoding")
+	}
+	enc = strings.TrimPrefix(enc, "z")
+	if len(enc) > maxDidKeyLen {
+		return nil, errors.New("invalid X25519 key length")
+	}
+	raw, err := base58.Decode(enc)
+	if err != nil {
+		return nil, err
+	}
+	if len(raw) != len(x25519Prefix)+32 || raw[0] != x25519Prefix[0] || raw[1] != x25519Prefix[1] {
+		return nil, errors.New("invalid X25519 key")
+	}
+	return ecdh.X25519().NewPublicKey(raw[len(x25519Prefix):])
+}
+
+// reverse reverses b in place and returns it
+func reverse(b []byte) []byte {
+	for i, j := 0, len(b)-1; i < j; i, j = i+1, j-1 {
+		b[i], b[j] = b[j], b[i]
+	}
+	return b
+}
@@ -0,0 +1,101 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/example/privacy-gateway/internal/shared/observability"
+)
+
+// DrainConfig configures graceful termination
+type DrainConfig struct {
+	// GracePeriod is the pod's terminationGracePeriodSeconds, after which
+	// the kubelet kills the process (default TERMINATION_GRACE_PERIOD_SECONDS,
+	// else 30s). The downward API doesn't expose it, so the deployment
+	// passes it in explicitly.
+	GracePeriod time.Duration
+	// Delay is how long readiness fails before the server shuts down, so
+	// endpoints and load balancers stop routing to the pod first (default
+	// 5s, at most a third of GracePeriod)
+	Delay time.Duration
+	// Logger is optional
+	Logger *slog.Logger
+}
+
+// Drainer is a readiness gate for termination. After Drain starts,
+// readiness reports not ready and responses ask clients to close their
+// connections, while requests are still served for Delay; then shutdown
+// runs with what is left of the grace period, less a second of margin.
+type Drainer struct {
+	cfg      DrainConfig
+	draining atomic.Bool
+}
+
+// NewDrainer creates a drainer
+func NewDrainer(cfg DrainConfig) *Drainer {
+	if cfg.GracePeriod == 0 {
+		cfg.GracePeriod = 30 * time.Second
+		if s, err := strconv.Atoi(os.Getenv("TERMINATION_GRACE_PERIOD_SECONDS")); err == nil && s > 0 {
+			cfg.GracePeriod = time.Duration(s) * time.Second
+		}
+	}
+	if cfg.Delay == 0 {
+		cfg.Delay = 5 * time.Second
+	}
+	if cfg.Delay > cfg.GracePeriod/3 {
+		cfg.Delay = cfg.GracePeriod / 3
+	}
+	cfg.Logger = observability.Component(cfg.Logger, observability.ComponentHealth)
+	return &Drainer{cfg: cfg}
+}
+
+// Draining reports whether Drain has started
+func (d *Drainer) Draining() bool {
+	return d.draining.Load()
+}
+
+// Readiness wraps a readiness handler to fail while draining
+func (d *Drainer) Readiness(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if d.Draining() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			fmt.Fprintln(w, "draining")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Middleware closes keep-alive connections after each response while
+// draining, so clients reconnect to a replica that stays
+func (d *Drainer) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if d.Draining() {
+			w.Header().Set("Connection", "close")
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Drain marks the instance not ready, waits Delay and calls shutdown
+// (e.g. http.Server.Shutdown) with a context that expires before the
+// grace period does. Call it on SIGTERM; it returns shutdown's error.
+func (d *Drainer) Drain(shutdown func(context.Context) error) error {
+	start := time.Now()
+	d.draining.Store(true)
+	d.cfg.Logger.Info("draining", "delay", d.cfg.Delay, "grace_period", d.cfg.GracePeriod)
+	time.Sleep(d.cfg.Delay)
+
+	deadline := start.Add(d.cfg.GracePeriod - time.Second)
+	ctx, cancel := context.WithDeadline(context.Background(), deadline)
+	defer cancel()
+	err := shutdown(ctx)
+	d.cfg.Logger.Info("drained", "elapsed", time.Since(start).Round(time.Millisecond), "error", err)
+	return err
+}
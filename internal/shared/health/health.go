@@ -3,6 +3,7 @@ package health
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"sync"
@@ -18,12 +19,43 @@ const (
 	StatusUnhealthy Status = "unhealthy"
 )
 
-// Component represents a health check component
+// Criticality of a component, as reported in verbose mode
+const (
+	Critical    = "critical"
+	NonCritical = "non-critical"
+)
+
+// ErrDegraded marks a check error as degraded rather than unhealthy, e.g.
+// a component running in a fallback mode
+var ErrDegraded = errors.New("degraded")
+
+// Component represents a health check component. The fields after Latency
+// are only reported in verbose mode.
 type Component struct {
 	Name    string        `json:"name"`
 	Status  Status        `json:"status"`
 	Error   string        `json:"error,omitempty"`
 	Latency time.Duration `json:"latency,omitempty"`
+
+	Version             string     `json:"version,omitempty"`
+	Endpoint            string     `json:"endpoint,omitempty"`
+	Criticality         string     `json:"criticality,omitempty"`
+	DependsOn           []string   `json:"depends_on,omitempty"`
+	LastSuccess         *time.Time `json:"last_success,omitempty"`
+	ConsecutiveFailures int        `json:"consecutive_failures,omitempty"`
+}
+
+// ComponentInfo describes a checked dependency
+type ComponentInfo struct {
+	Version  string // E.g. the server version reported by the dependency
+	Endpoint string // Address checked; never include credentials
+	// Critical is reported to operators; it does not change how the
+	// component counts toward overall status or readiness
+	Critical bool
+	// DependsOn names components this one needs. A component whose
+	// dependency is unhealthy is reported degraded rather than checked.
+	DependsOn []string
+	Timeout   time.Duration // Per check (default 2s)
 }
 
 // HealthStatus represents overall health status
@@ -39,56 +71,131 @@ type Checker interface {
 	Check(ctx context.Context) error
 }
 
+// registration is a checker with its metadata and check history
+type registration struct {
+	checker Checker
+	info    ComponentInfo
+
+	mu                  sync.Mutex
+	lastSuccess         time.Time
+	consecutiveFailures int
+}
+
+// ComponentChange is a component's status change within a transition
+type ComponentChange struct {
+	Name  string `json:"name"`
+	From  Status `json:"from,omitempty"`
+	To    Status `json:"to"`
+	Error string `json:"error,omitempty"`
+}
+
+// Transition is a change of overall health
+type Transition struct {
+	From       Status            `json:"from,omitempty"` // Empty on the first check
+	To         Status            `json:"to"`
+	Time       time.Time         `json:"time"`
+	Components []ComponentChange `json:"components"`
+}
+
 // HealthChecker aggregates multiple health checks
 type HealthChecker struct {
-	checkers []Checker
+	checkers []*registration
 	mu       sync.RWMutex
+
+	// Last observed statuses, for transitions
+	stateMu      sync.Mutex
+	overall      Status
+	observedAt   time.Time
+	components   map[string]Status
+	onTransition []func(Transition)
 }
 
 // New creates a new health checker
 func New() *HealthChecker {
 	return &HealthChecker{
-		checkers: make([]Checker, 0),
+		checkers: make([]*registration, 0),
 	}
 }
 
-// Register adds a health checker
+// Register adds a critical health checker
 func (h *HealthChecker) Register(checker Checker) {
+	h.RegisterComponent(checker, ComponentInfo{Critical: true})
+}
+
+// RegisterComponent adds a health checker with its metadata
+func (h *HealthChecker) RegisterComponent(checker Checker, info ComponentInfo) {
 	h.mu.Lock()
 	defer h.mu.Unlock()
-	h.checkers = append(h.checkers, checker)
+	h.checkers = append(h.checkers, &registration{checker: checker, info: info})
 }
 
-// Check runs all health checks
+// Check runs all health checks. Components are checked in dependency
+// order, in parallel where they don't depend on each other; the result
+// includes verbose fields.
 func (h *HealthChecker) Check(ctx context.Context) *HealthStatus {
 	h.mu.RLock()
 	checkers := h.checkers
 	h.mu.RUnlock()
 
 	components := make([]*Component, len(checkers))
+	done := make(map[string]chan struct{}, len(checkers))
+	byName := make(map[string]int, len(checkers))
+	for i, reg := range checkers {
+		if _, dup := byName[reg.checker.Name()]; !dup {
+			done[reg.checker.Name()] = make(chan struct{})
+			byName[reg.checker.Name()] = i
+		}
+	}
+	deps := dependencies(checkers)
 	var wg sync.WaitGroup
 
-	// Run checks in parallel
-	for i, checker := range checkers {
+	// Run checks in parallel, each waiting for its dependencies
+	for i, reg := range checkers {
 		wg.Add(1)
-		go func(idx int, chk Checker) {
+		go func(idx int, reg *registration) {
 			defer wg.Done()
+			if byName[reg.checker.Name()] == idx {
+				defer close(done[reg.checker.Name()])
+			}
 
-			start := time.Now()
-			err := chk.Check(ctx)
-			latency := time.Since(start)
-
-			component := &Component{
-				Name:    chk.Name(),
-				Status:  statusFromError(err),
-				Latency: latency,
+			var failedDep string
+			for _, dep := range deps[reg.checker.Name()] {
+				select {
+				case <-done[dep]:
+					if components[byName[dep]].Status == StatusUnhealthy {
+						failedDep = dep
+					}
+				case <-ctx.Done():
+					failedDep = dep
+				}
+				if failedDep != "" {
+					break
+				}
 			}
-			if err != nil {
-				component.Error = err.Error()
+
+			var component *Component
+			if failedDep != "" {
+				component = &Component{
+					Name:   reg.checker.Name(),
+					Status: StatusDegraded,
+					Error:  "dependency " + failedDep + " is unhealthy",
+				}
+			} else {
+				start := time.Now()
+				err := reg.run(ctx)
+				component = &Component{
+					Name:    reg.checker.Name(),
+					Status:  statusFromError(err),
+					Latency: time.Since(start),
+				}
+				if err != nil {
+					component.Error = err.Error()
+				}
 			}
+			reg.record(component)
 
 			components[idx] = component
-		}(i, checker)
+		}(i, reg)
 	}
 
 	wg.Wait()
@@ -96,23 +203,187 @@ func (h *HealthChecker) Check(ctx context.Context) *HealthStatus {
 	// Calculate overall status
 	overallStatus := calculateOverallStatus(components)
 
-	return &HealthStatus{
+	status := &HealthStatus{
 		Status:     overallStatus,
 		Components: components,
 		Timestamp:  time.Now(),
 	}
+	h.observe(status)
+	return status
+}
+
+// OnTransition calls fn whenever a check finds the overall status changed.
+// The first check counts as a transition only when it isn't healthy.
+// Callbacks run in order on the checking goroutine and should be quick.
+func (h *HealthChecker) OnTransition(fn func(Transition)) {
+	h.stateMu.Lock()
+	defer h.stateMu.Unlock()
+	h.onTransition = append(h.onTransition, fn)
+}
+
+// Run checks health every interval until ctx is cancelled, so transitions
+// are noticed without /healthz traffic
+func (h *HealthChecker) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		checkCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		h.Check(checkCtx)
+		cancel()
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// observe records the statuses of a check and reports a transition
+func (h *HealthChecker) observe(status *HealthStatus) {
+	h.stateMu.Lock()
+	defer h.stateMu.Unlock()
+	if status.Timestamp.Before(h.observedAt) {
+		return // A concurrent check already reported newer results
+	}
+	h.observedAt = status.Timestamp
+	if h.components == nil {
+		h.components = make(map[string]Status)
+	}
+	var changes []ComponentChange
+	seen := make(map[string]bool, len(status.Components))
+	for _, c := range status.Components {
+		seen[c.Name] = true
+		if prev := h.components[c.Name]; prev != c.Status {
+			changes = append(changes, ComponentChange{Name: c.Name, From: prev, To: c.Status, Error: c.Error})
+			h.components[c.Name] = c.Status
+		}
+	}
+	for name := range h.components {
+		if !seen[name] {
+			delete(h.components, name) // Unregistered
+		}
+	}
+
+	from := h.overall
+	h.overall = status.Status
+	if from == status.Status || (from == "" && status.Status == StatusHealthy) {
+		return
+	}
+	t := Transition{From: from, To: status.Status, Time: status.Timestamp, Components: changes}
+	for _, fn := range h.onTransition {
+		fn(t)
+	}
+}
+
+// run checks the component under its own timeout. A panic is reported as
+// an error instead of crashing the process, and a checker that ignores its
+// context is abandoned at the deadline.
+func (r *registration) run(ctx context.Context) error {
+	timeout := r.info.Timeout
+	if timeout <= 0 {
+		timeout = 2 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	result := make(chan error, 1)
+	go func() {
+		defer func() {
+			if p := recover(); p != nil {
+				result <- fmt.Errorf("panic: %v", p)
+			}
+		}()
+		result <- r.checker.Check(ctx)
+	}()
+	select {
+	case err := <-result:
+		return err
+	case <-ctx.Done():
+		return fmt.Errorf("check timed out: %w", ctx.Err())
+	}
+}
+
+// dependencies returns each component's dependencies, leaving out unknown
+// names and edges that would close a cycle
+func dependencies(checkers []*registration) map[string][]string {
+	declared := make(map[string][]string, len(checkers))
+	for _, reg := range checkers {
+		declared[reg.checker.Name()] = reg.info.DependsOn
+	}
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	state := make(map[string]int, len(checkers))
+	out := make(map[string][]string, len(checkers))
+	var visit func(name string)
+	visit = func(name string) {
+		state[name] = visiting
+		for _, dep := range declared[name] {
+			if _, known := declared[dep]; !known || state[dep] == visiting {
+				continue
+			}
+			if state[dep] == 0 {
+				visit(dep)
+			}
+			out[name] = append(out[name], dep)
+		}
+		state[name] = visited
+	}
+	for _, reg := range checkers {
+		if state[reg.checker.Name()] == 0 {
+			visit(reg.checker.Name())
+		}
+	}
+	return out
+}
+
+// record updates the check history and fills in the verbose fields
+func (r *registration) record(c *Component) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if c.Status == StatusHealthy {
+		r.lastSuccess = time.Now()
+		r.consecutiveFailures = 0
+	} else {
+		r.consecutiveFailures++
+	}
+
+	c.Version, c.Endpoint, c.DependsOn = r.info.Version, r.info.Endpoint, r.info.DependsOn
+	c.Criticality = NonCritical
+	if r.info.Critical {
+		c.Criticality = Critical
+	}
+	if !r.lastSuccess.IsZero() {
+		last := r.lastSuccess
+		c.LastSuccess = &last
+	}
+	c.ConsecutiveFailures = r.consecutiveFailures
 }
 
-// Handler returns an HTTP handler for health checks
+// terse strips the verbose fields
+func (s *HealthStatus) terse() *HealthStatus {
+	out := &HealthStatus{Status: s.Status, Timestamp: s.Timestamp, Components: make([]*Component, len(s.Components))}
+	for i, c := range s.Components {
+		out.Components[i] = &Component{Name: c.Name, Status: c.Status, Error: c.Error, Latency: c.Latency}
+	}
+	return out
+}
+
+// Handler returns an HTTP handler for health checks. With ?verbose=1 the
+// components include their metadata, last success and consecutive failures.
 func (h *HealthChecker) Handler() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
 		defer cancel()
 
 		status := h.Check(ctx)
+		if v := r.URL.Query().Get("verbose"); v != "1" && v != "true" {
+			status = status.terse()
+		}
 
 		w.Header().Set("Content-Type", "application/json")
-		
+
 		// Set HTTP status code based on health
 		switch status.Status {
 		case StatusHealthy:
@@ -132,6 +403,9 @@ func statusFromError(err error) Status {
 	if err == nil {
 		return StatusHealthy
 	}
+	if errors.Is(err, ErrDegraded) {
+		return StatusDegraded
+	}
 	return StatusUnhealthy
 }
 
@@ -237,3 +511,16 @@ func LivenessHandler() http.HandlerFunc {
 		fmt.Fprintln(w, "alive")
 	}
 }
+
+// Unregister removes the health checkers with the given name
+func (h *HealthChecker) Unregister(name string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	kept := make([]*registration, 0, len(h.checkers))
+	for _, reg := range h.checkers {
+		if reg.checker.Name() != name {
+			kept = append(kept, reg)
+		}
+	}
+	h.checkers = kept
+}
@@ -0,0 +1,71 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// HTTPCheckerConfig configures an HTTP health check
+type HTTPCheckerConfig struct {
+	Name           string
+	URL            string
+	ExpectedStatus int           // Default 200
+	Timeout        time.Duration // Default 2s
+	Client         *http.Client  // Default a client without redirects
+}
+
+// HTTPChecker checks a dependency by GETting a URL and comparing the
+// response status
+type HTTPChecker struct {
+	cfg HTTPCheckerConfig
+}
+
+// NewHTTPChecker creates an HTTP health checker
+func NewHTTPChecker(cfg HTTPCheckerConfig) *HTTPChecker {
+	if cfg.ExpectedStatus == 0 {
+		cfg.ExpectedStatus = http.StatusOK
+	}
+	if cfg.Timeout == 0 {
+		cfg.Timeout = 2 * time.Second
+	}
+	if cfg.Client == nil {
+		cfg.Client = &http.Client{
+			CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+		}
+	}
+	return &HTTPChecker{cfg: cfg}
+}
+
+// Name returns the checker name
+func (c *HTTPChecker) Name() string {
+	return c.cfg.Name
+}
+
+// URL returns the checked URL
+func (c *HTTPChecker) URL() string {
+	return c.cfg.URL
+}
+
+// Check performs the health check
+func (c *HTTPChecker) Check(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, c.cfg.Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.cfg.URL, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("User-Agent", "did-gateway-health")
+	resp, err := c.cfg.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+	if resp.StatusCode != c.cfg.ExpectedStatus {
+		return fmt.Errorf("GET %s returned %d, want %d", c.cfg.URL, resp.StatusCode, c.cfg.ExpectedStatus)
+	}
+	return nil
+}
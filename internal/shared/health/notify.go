@@ -0,0 +1,64 @@
+package health
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/example/privacy-gateway/internal/shared/audit"
+	"github.com/example/privacy-gateway/internal/shared/models"
+	"github.com/example/privacy-gateway/internal/shared/observability"
+)
+
+// EventTransition is the audit and webhook event type of a transition
+const EventTransition = "health.changed"
+
+// EventPublisher publishes webhook events; webhook.Dispatcher implements it
+type EventPublisher interface {
+	Publish(eventType string, data map[string]interface{}) error
+}
+
+// Notifier returns an OnTransition callback that logs each transition,
+// writes it to the audit trail and publishes it as a health.changed
+// webhook (e.g. to a PagerDuty Events endpoint), so alerts fire from the
+// gateway itself where there is no Prometheus. Either sink may be nil.
+func Notifier(events EventPublisher, sink audit.Sink, logger *slog.Logger) func(Transition) {
+	logger = observability.Component(logger, observability.ComponentHealth)
+	return func(t Transition) {
+		names := make([]string, 0, len(t.Components))
+		for _, c := range t.Components {
+			names = append(names, c.Name)
+		}
+		level := slog.LevelWarn
+		if t.To == StatusHealthy {
+			level = slog.LevelInfo
+		}
+		logger.Log(context.Background(), level, "health changed", "from", t.From, "to", t.To, "components", names)
+
+		data := map[string]interface{}{
+			"from":       t.From,
+			"to":         t.To,
+			"components": t.Components,
+		}
+		if events != nil {
+			if err := events.Publish(EventTransition, data); err != nil {
+				logger.Warn("failed to publish health change", "error", err)
+			}
+		}
+		if sink != nil {
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+			err := sink.InsertAuditEvent(ctx, models.AuditEvent{
+				Time:     t.Time,
+				Event:    EventTransition,
+				Subject:  string(t.To),
+				Outcome:  string(t.To),
+				Metadata: data,
+			})
+			if err != nil {
+				// Expected when the database is what failed
+				logger.Warn("failed to audit health change", "error", err)
+			}
+		}
+	}
+}
@@ -7,10 +7,14 @@ import (
 	"net/http"
 )
 
-const maxBodyBytes = 1 << 20
+// DefaultMaxBodyBytes is the request body limit used when a route sets none
+const DefaultMaxBodyBytes = 1 << 20
 
 type ErrorResponse struct {
 	Error string `json:"error"`
+	// Message is a human-readable explanation in the caller's language (see
+	// i18n.WriteError); clients match on Error, never on Message
+	Message string `json:"message,omitempty"`
 }
 
 func WriteJSON(w http.ResponseWriter, status int, payload interface{}) {
@@ -22,7 +26,12 @@ func WriteJSON(w http.ResponseWriter, status int, payload interface{}) {
 }
 
 func DecodeJSON(r *http.Request, dst interface{}) error {
-	r.Body = io.NopCloser(io.LimitReader(r.Body, maxBodyBytes))
+	return DecodeJSONLimit(r, dst, DefaultMaxBodyBytes)
+}
+
+// DecodeJSONLimit is DecodeJSON with an explicit body size limit
+func DecodeJSONLimit(r *http.Request, dst interface{}, limit int64) error {
+	r.Body = io.NopCloser(io.LimitReader(r.Body, limit))
 	dec := json.NewDecoder(r.Body)
 	dec.DisallowUnknownFields()
 	if err := dec.Decode(dst); err != nil {
@@ -0,0 +1,79 @@
+package httpx
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+var (
+	ErrDuplicateKey = errors.New("duplicate JSON object key")
+	ErrTrailingData = errors.New("trailing data after JSON value")
+	ErrJSONTooDeep  = errors.New("JSON nested too deeply")
+)
+
+// CheckStrictJSON walks the token stream of a single JSON value rejecting
+// duplicate keys, nesting deeper than maxDepth (0 for no limit) and trailing
+// data. encoding/json silently keeps the last duplicate and stops after the
+// first value, which lets a body show different data to different parsers.
+func CheckStrictJSON(data []byte, maxDepth int) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	// One frame per open container; keys is nil for arrays
+	type frame struct {
+		keys      map[string]struct{}
+		expectKey bool
+	}
+	var stack []frame
+
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return err
+		}
+
+		if n := len(stack); n > 0 && stack[n-1].keys != nil {
+			top := &stack[n-1]
+			if top.expectKey {
+				if key, ok := tok.(string); ok {
+					if _, dup := top.keys[key]; dup {
+						return fmt.Errorf("%w %q", ErrDuplicateKey, key)
+					}
+					top.keys[key] = struct{}{}
+					top.expectKey = false
+					continue
+				}
+			} else {
+				// This token is the value; the next one is a key again
+				top.expectKey = true
+			}
+		}
+
+		switch tok {
+		case json.Delim('{'), json.Delim('['):
+			if maxDepth > 0 && len(stack) == maxDepth {
+				return fmt.Errorf("%w: deeper than %d", ErrJSONTooDeep, maxDepth)
+			}
+			f := frame{}
+			if tok == json.Delim('{') {
+				f = frame{keys: make(map[string]struct{}), expectKey: true}
+			}
+			stack = append(stack, f)
+		case json.Delim('}'), json.Delim(']'):
+			stack = stack[:len(stack)-1]
+		}
+		if len(stack) == 0 {
+			// Top-level value complete; anything after it is trailing data
+			if _, err := dec.Token(); err != io.EOF {
+				return ErrTrailingData
+			}
+			return nil
+		}
+	}
+}
@@ -0,0 +1,179 @@
+package i18n
+
+import (
+	"embed"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/example/privacy-gateway/internal/shared/challenge"
+	"github.com/example/privacy-gateway/internal/shared/httpx"
+)
+
+// DefaultLanguage is used when a request accepts none of the catalog's
+// languages, and for codes a language lacks
+const DefaultLanguage = "en"
+
+// Message codes beyond the policy denial reasons, which are codes too
+const (
+	CodeUnauthorized     = "unauthorized"
+	CodeForbidden        = "forbidden"
+	CodeRateLimited      = "rate_limited"
+	CodeChallengeExpired = "challenge_expired"
+	CodeChallengeInvalid = "challenge_invalid"
+	CodeSessionExpired   = "session_expired"
+	CodeConsentRequired  = "consent_required"
+	CodeConsentDeclined  = "consent_declined"
+
+	CodeRecoveryExpired      = "recovery_expired"
+	CodeRecoveryInvalid      = "recovery_invalid"
+	CodeRecoveryProofInvalid = "recovery_proof_invalid"
+	CodeRecoveryFailed       = "recovery_failed"
+)
+
+//go:embed locales/*.json
+var locales embed.FS
+
+// catalog holds the messages by language, then code
+var catalog = load()
+
+func load() map[string]map[string]string {
+	files, err := locales.ReadDir("locales")
+	if err != nil {
+		panic(err)
+	}
+	c := make(map[string]map[string]string, len(files))
+	for _, f := range files {
+		data, err := locales.ReadFile(path.Join("locales", f.Name()))
+		if err != nil {
+			panic(err)
+		}
+		var msgs map[string]string
+		if err := json.Unmarshal(data, &msgs); err != nil {
+			panic("i18n: " + f.Name() + ": " + err.Error())
+		}
+		c[strings.TrimSuffix(f.Name(), ".json")] = msgs
+	}
+	return c
+}
+
+// Languages returns the catalog's languages, sorted
+func Languages() []string {
+	langs := make([]string, 0, len(catalog))
+	for l := range catalog {
+		langs = append(langs, l)
+	}
+	sort.Strings(langs)
+	return langs
+}
+
+// Negotiate picks the catalog language that best matches an
+// Accept-Language header: the highest weighted tag the catalog has, by
+// exact match or primary subtag (de-AT matches de), else DefaultLanguage
+func Negotiate(acceptLanguage string) string {
+	type tag struct {
+		lang string
+		q    float64
+	}
+	var tags []tag
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		lang, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				q = f
+			}
+		}
+		if lang != "" && q > 0 {
+			tags = append(tags, tag{strings.ToLower(strings.TrimSpace(lang)), q})
+		}
+	}
+	sort.SliceStable(tags, func(i, j int) bool { return tags[i].q > tags[j].q })
+	for _, t := range tags {
+		if _, ok := catalog[t.lang]; ok {
+			return t.lang
+		}
+		primary, _, _ := strings.Cut(t.lang, "-")
+		if _, ok := catalog[primary]; ok {
+			return primary
+		}
+	}
+	return DefaultLanguage
+}
+
+// Message returns the message for code in lang, falling back to
+// DefaultLanguage; unknown codes give ""
+func Message(lang, code string) string {
+	if msg, ok := catalog[lang][code]; ok {
+		return msg
+	}
+	return catalog[DefaultLanguage][code]
+}
+
+// Messages returns every message of lang, with DefaultLanguage filling
+// the gaps
+func Messages(lang string) map[string]string {
+	out := make(map[string]string, len(catalog[DefaultLanguage]))
+	for code, msg := range catalog[DefaultLanguage] {
+		out[code] = msg
+	}
+	for code, msg := range catalog[lang] {
+		out[code] = msg
+	}
+	return out
+}
+
+// ForRequest negotiates r's language and marks the response as varying by
+// it, for handlers that localize their own bodies
+func ForRequest(w http.ResponseWriter, r *http.Request) string {
+	lang := Negotiate(r.Header.Get("Accept-Language"))
+	w.Header().Add("Vary", "Accept-Language")
+	w.Header().Set("Content-Language", lang)
+	return lang
+}
+
+// WriteError writes an error response whose error field is code, the
+// machine-readable part clients match on, with the message in the
+// request's language
+func WriteError(w http.ResponseWriter, r *http.Request, status int, code string) {
+	WriteErrorMessage(w, r, status, code, code)
+}
+
+// WriteErrorMessage is WriteError for handlers whose error field predates
+// the catalog: errorField is kept as is and the message is looked up by
+// code
+func WriteErrorMessage(w http.ResponseWriter, r *http.Request, status int, errorField, code string) {
+	lang := ForRequest(w, r)
+	httpx.WriteJSON(w, status, httpx.ErrorResponse{Error: errorField, Message: Message(lang, code)})
+}
+
+// ChallengeCode maps a challenge validation error to its message code
+func ChallengeCode(err error) string {
+	if errors.Is(err, challenge.ErrExpired) {
+		return CodeChallengeExpired
+	}
+	return CodeChallengeInvalid
+}
+
+// Handler serves GET /v1/i18n/messages: the catalog in the request's
+// language, for wallets and the wallet pages to localize their own text
+func Handler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", "GET")
+			httpx.WriteJSON(w, http.StatusMethodNotAllowed, httpx.ErrorResponse{Error: "method not allowed"})
+			return
+		}
+		lang := ForRequest(w, r)
+		w.Header().Set("Cache-Control", "public, max-age=3600")
+		httpx.WriteJSON(w, http.StatusOK, struct {
+			Language  string            `json:"language"`
+			Languages []string          `json:"languages"`
+			Messages  map[string]string `json:"messages"`
+		}{lang, Languages(), Messages(lang)})
+	}
+}
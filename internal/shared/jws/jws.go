@@ -0,0 +1,16 @@
+package jws
+
+import (
+	"encoding/base64"
+	"encoding/json"
+)
+
+// DecodeSegment decodes a base64url JSON segment of a compact JWS or JWE
+// (a header or payload) into dst
+func DecodeSegment(seg string, dst interface{}) error {
+	raw, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(raw, dst)
+}
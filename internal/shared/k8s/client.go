@@ -0,0 +1,145 @@
+package k8s
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ServiceAccountDir is where Kubernetes mounts the pod's service account
+// token, CA bundle and namespace
+const ServiceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+
+var (
+	ErrNotInCluster = errors.New("not running in a Kubernetes pod")
+	ErrNotFound     = errors.New("kubernetes object not found")
+	ErrConflict     = errors.New("kubernetes object changed concurrently")
+)
+
+// Client is a minimal Kubernetes API client using the pod's service
+// account. It covers only what the gateway needs, so the module doesn't
+// depend on client-go.
+type Client struct {
+	base      string
+	http      *http.Client
+	tokenFile string
+	namespace string
+}
+
+// InCluster creates a client from the service account mounted in the pod
+// and KUBERNETES_SERVICE_HOST/PORT
+func InCluster() (*Client, error) {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return nil, ErrNotInCluster
+	}
+	ca, err := os.ReadFile(filepath.Join(ServiceAccountDir, "ca.crt"))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrNotInCluster, err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(ca) {
+		return nil, errors.New("service account CA bundle has no certificates")
+	}
+	ns, err := os.ReadFile(filepath.Join(ServiceAccountDir, "namespace"))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrNotInCluster, err)
+	}
+	return &Client{
+		base: "https://" + net.JoinHostPort(host, port),
+		http: &http.Client{
+			Timeout:   10 * time.Second,
+			Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}},
+		},
+		tokenFile: filepath.Join(ServiceAccountDir, "token"),
+		namespace: strings.TrimSpace(string(ns)),
+	}, nil
+}
+
+// NewClient creates a client for the API server at base, e.g. a test
+// server or kubectl proxy. tokenFile may be empty.
+func NewClient(base string, httpClient *http.Client, tokenFile, namespace string) *Client {
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 10 * time.Second}
+	}
+	return &Client{base: strings.TrimSuffix(base, "/"), http: httpClient, tokenFile: tokenFile, namespace: namespace}
+}
+
+// Namespace returns the pod's namespace
+func (c *Client) Namespace() string {
+	return c.namespace
+}
+
+// status is the body of an API error
+type status struct {
+	Message string `json:"message"`
+	Reason  string `json:"reason"`
+}
+
+// do sends in as JSON and decodes the response into out. 404 and 409 map to
+// ErrNotFound and ErrConflict.
+func (c *Client) do(ctx context.Context, method, path string, in, out interface{}) error {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.base+path, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.tokenFile != "" {
+		// Bound tokens are rotated on disk by the kubelet; read it each time
+		token, err := os.ReadFile(c.tokenFile)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode/100 != 2 {
+		var st status
+		_ = json.Unmarshal(data, &st)
+		msg := st.Message
+		if msg == "" {
+			msg = resp.Status
+		}
+		switch resp.StatusCode {
+		case http.StatusNotFound:
+			return fmt.Errorf("%w: %s", ErrNotFound, msg)
+		case http.StatusConflict:
+			return fmt.Errorf("%w: %s", ErrConflict, msg)
+		}
+		return fmt.Errorf("kubernetes API %s %s: %s", method, path, msg)
+	}
+	if out == nil {
+		return nil
+	}
+	return json.Unmarshal(data, out)
+}
@@ -0,0 +1,226 @@
+package k8s
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// microTime is the Lease timestamp format (metav1.MicroTime)
+const microTime = "2006-01-02T15:04:05.000000Z07:00"
+
+// lease is a coordination.k8s.io/v1 Lease
+type lease struct {
+	APIVersion string        `json:"apiVersion"`
+	Kind       string        `json:"kind"`
+	Metadata   leaseMetadata `json:"metadata"`
+	Spec       leaseSpec     `json:"spec"`
+}
+
+type leaseMetadata struct {
+	Name            string `json:"name"`
+	Namespace       string `json:"namespace,omitempty"`
+	ResourceVersion string `json:"resourceVersion,omitempty"`
+}
+
+type leaseSpec struct {
+	HolderIdentity       string `json:"holderIdentity,omitempty"`
+	LeaseDurationSeconds int    `json:"leaseDurationSeconds,omitempty"`
+	AcquireTime          string `json:"acquireTime,omitempty"`
+	RenewTime            string `json:"renewTime,omitempty"`
+	LeaseTransitions     int    `json:"leaseTransitions,omitempty"`
+}
+
+// LeaseConfig configures a LeaseLock
+type LeaseConfig struct {
+	Namespace string // Default the client's
+	Prefix    string // Lease name prefix before the job name (default "gateway-leader-")
+}
+
+// LeaseLock is a leader.Lock on coordination.k8s.io Leases, one per job,
+// so the holder shows in kubectl get leases. Updates are conditional on
+// the resourceVersion read, so two replicas can't both take a lease.
+//
+// Expiry is judged on the local clock, as client-go does: a lease is taken
+// over only once this replica has seen the same holder and renewTime for a
+// whole leaseDurationSeconds, so clock skew between nodes doesn't matter.
+// A follower that starts while a lease is held therefore waits up to one
+// duration longer. The service account needs get, create and update on
+// leases.
+type LeaseLock struct {
+	client *Client
+	cfg    LeaseConfig
+
+	mu       sync.Mutex
+	observed map[string]observation // By lease name
+}
+
+// observation is when a lease record was first seen unchanged
+type observation struct {
+	record string
+	at     time.Time
+}
+
+// NewLeaseLock creates a lock on client's API server
+func NewLeaseLock(client *Client, cfg LeaseConfig) *LeaseLock {
+	if cfg.Namespace == "" {
+		cfg.Namespace = client.Namespace()
+	}
+	if cfg.Prefix == "" {
+		cfg.Prefix = "gateway-leader-"
+	}
+	return &LeaseLock{client: client, cfg: cfg, observed: make(map[string]observation)}
+}
+
+// leaseName turns a job name into a valid object name
+func (l *LeaseLock) leaseName(name string) string {
+	n := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '-', r == '.':
+			return r
+		case r >= 'A' && r <= 'Z':
+			return r + 'a' - 'A'
+		}
+		return '-'
+	}, l.cfg.Prefix+name)
+	if len(n) > 253 {
+		n = n[:253]
+	}
+	return strings.Trim(n, "-.")
+}
+
+func (l *LeaseLock) path(name string) string {
+	p := "/apis/coordination.k8s.io/v1/namespaces/" + url.PathEscape(l.cfg.Namespace) + "/leases"
+	if name != "" {
+		p += "/" + url.PathEscape(name)
+	}
+	return p
+}
+
+func (l *LeaseLock) get(ctx context.Context, name string) (*lease, error) {
+	var ls lease
+	if err := l.client.do(ctx, "GET", l.path(name), nil, &ls); err != nil {
+		return nil, err
+	}
+	return &ls, nil
+}
+
+func (l *LeaseLock) update(ctx context.Context, ls *lease) error {
+	return l.client.do(ctx, "PUT", l.path(ls.Metadata.Name), ls, nil)
+}
+
+func seconds(ttl time.Duration) int {
+	return int(math.Max(1, math.Ceil(ttl.Seconds())))
+}
+
+// expired reports whether ls has gone unrenewed for its duration as seen
+// from here
+func (l *LeaseLock) expired(ls *lease) bool {
+	record := ls.Spec.HolderIdentity + "|" + ls.Spec.RenewTime
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	obs, ok := l.observed[ls.Metadata.Name]
+	if !ok || obs.record != record {
+		l.observed[ls.Metadata.Name] = observation{record: record, at: time.Now()}
+		return false
+	}
+	return time.Since(obs.at) > time.Duration(ls.Spec.LeaseDurationSeconds)*time.Second
+}
+
+// Acquire creates the lease, or takes it over if it is free or expired
+func (l *LeaseLock) Acquire(ctx context.Context, name, id string, ttl time.Duration) (bool, error) {
+	name = l.leaseName(name)
+	now := time.Now().UTC().Format(microTime)
+	ls, err := l.get(ctx, name)
+	if errors.Is(err, ErrNotFound) {
+		ls = &lease{
+			APIVersion: "coordination.k8s.io/v1",
+			Kind:       "Lease",
+			Metadata:   leaseMetadata{Name: name, Namespace: l.cfg.Namespace},
+			Spec:       leaseSpec{HolderIdentity: id, LeaseDurationSeconds: seconds(ttl), AcquireTime: now, RenewTime: now},
+		}
+		err = l.client.do(ctx, "POST", l.path(""), ls, nil)
+		if errors.Is(err, ErrConflict) {
+			return false, nil // Created by another replica first
+		}
+		return err == nil, err
+	}
+	if err != nil {
+		return false, err
+	}
+	holder := ls.Spec.HolderIdentity
+	if holder != "" && holder != id && !l.expired(ls) {
+		return false, nil
+	}
+	if holder != id {
+		ls.Spec.AcquireTime = now
+		ls.Spec.LeaseTransitions++
+	}
+	ls.Spec.HolderIdentity = id
+	ls.Spec.LeaseDurationSeconds = seconds(ttl)
+	ls.Spec.RenewTime = now
+	if err := l.update(ctx, ls); err != nil {
+		if errors.Is(err, ErrConflict) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// Renew updates renewTime if id holds the lease. A concurrent change is
+// returned as ErrConflict rather than as a lost lease, so the elector
+// checks again before stepping down.
+func (l *LeaseLock) Renew(ctx context.Context, name, id string, ttl time.Duration) (bool, error) {
+	ls, err := l.get(ctx, l.leaseName(name))
+	if errors.Is(err, ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if ls.Spec.HolderIdentity != id {
+		return false, nil
+	}
+	ls.Spec.LeaseDurationSeconds = seconds(ttl)
+	ls.Spec.RenewTime = time.Now().UTC().Format(microTime)
+	if err := l.update(ctx, ls); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Release clears the holder if id holds the lease, so a follower takes
+// over at its next attempt
+func (l *LeaseLock) Release(ctx context.Context, name, id string) error {
+	ls, err := l.get(ctx, l.leaseName(name))
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if ls.Spec.HolderIdentity != id {
+		return nil
+	}
+	ls.Spec.HolderIdentity = ""
+	ls.Spec.LeaseDurationSeconds = 1
+	ls.Spec.RenewTime = time.Now().UTC().Format(microTime)
+	return l.update(ctx, ls)
+}
+
+// Holder returns the lease's holderIdentity
+func (l *LeaseLock) Holder(ctx context.Context, name string) (string, error) {
+	ls, err := l.get(ctx, l.leaseName(name))
+	if errors.Is(err, ErrNotFound) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return ls.Spec.HolderIdentity, nil
+}
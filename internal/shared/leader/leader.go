@@ -0,0 +1,205 @@
+package leader
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/example/privacy-gateway/internal/shared/observability"
+)
+
+// Config configures leader election for one job
+type Config struct {
+	Name  string        // Job name; one leader per name
+	ID    string        // This instance (default hostname plus a random suffix)
+	TTL   time.Duration // Lease lifetime; takeover happens within it after a crash (default 15s)
+	Retry time.Duration // How often followers try to take over (default TTL/3)
+	// Logger is optional
+	Logger *slog.Logger
+}
+
+// Stats is an elector's state
+type Stats struct {
+	Name     string `json:"name"`
+	ID       string `json:"id"`
+	Leader   bool   `json:"leader"`
+	Terms    int64  `json:"terms"`
+	Holder   string `json:"holder,omitempty"`
+	LeaseTTL string `json:"lease_ttl"`
+}
+
+// Lock stores the named leases replicas compete for: Redis (NewRedisLock)
+// or, on Kubernetes, a coordination.k8s.io Lease (k8s.LeaseLock)
+type Lock interface {
+	// Acquire takes the lease for id if it is free or expired
+	Acquire(ctx context.Context, name, id string, ttl time.Duration) (bool, error)
+	// Renew extends the lease if id still holds it
+	Renew(ctx context.Context, name, id string, ttl time.Duration) (bool, error)
+	// Release gives the lease up if id holds it
+	Release(ctx context.Context, name, id string) error
+	// Holder returns the current holder, empty if none
+	Holder(ctx context.Context, name string) (string, error)
+}
+
+// Elector runs a background job on exactly one replica. Replicas compete
+// for a lease with a TTL in a Lock. The holder renews it every
+// Retry and runs the job; when it stops renewing (crash, network
+// partition, shutdown) the lease expires and a follower takes over within
+// TTL. The job's context is cancelled as soon as leadership is lost or can
+// no longer be confirmed before the lease would expire, so two replicas
+// never run it at once for longer than clock drift allows.
+type Elector struct {
+	lock Lock
+	cfg  Config
+
+	leader atomic.Bool
+	terms  atomic.Int64
+}
+
+// NewElector creates an elector for cfg.Name on a Redis lease
+func NewElector(client *redis.Client, cfg Config) (*Elector, error) {
+	return NewLockElector(NewRedisLock(client), cfg)
+}
+
+// NewLockElector creates an elector for cfg.Name on lock
+func NewLockElector(lock Lock, cfg Config) (*Elector, error) {
+	if cfg.Name == "" {
+		return nil, errors.New("leader election needs a job name")
+	}
+	if cfg.ID == "" {
+		host, _ := os.Hostname()
+		suffix := make([]byte, 4)
+		if _, err := rand.Read(suffix); err != nil {
+			return nil, err
+		}
+		cfg.ID = host + "-" + hex.EncodeToString(suffix)
+	}
+	if cfg.TTL == 0 {
+		cfg.TTL = 15 * time.Second
+	}
+	if cfg.Retry == 0 {
+		cfg.Retry = cfg.TTL / 3
+	}
+	if cfg.Retry >= cfg.TTL {
+		return nil, errors.New("leader retry interval must be shorter than the lease TTL")
+	}
+	cfg.Logger = observability.Component(cfg.Logger, observability.ComponentJobs)
+	return &Elector{lock: lock, cfg: cfg}, nil
+}
+
+// IsLeader reports whether this instance currently holds the lease
+func (e *Elector) IsLeader() bool {
+	return e.leader.Load()
+}
+
+// Run campaigns until ctx is cancelled, calling job whenever this instance
+// becomes leader. job must return promptly once its context is cancelled;
+// if it returns on its own while still leader, it is started again at the
+// next renewal.
+func (e *Elector) Run(ctx context.Context, job func(ctx context.Context)) {
+	ticker := time.NewTicker(e.cfg.Retry)
+	defer ticker.Stop()
+	for {
+		if e.acquire(ctx) {
+			e.lead(ctx, job)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (e *Elector) acquire(ctx context.Context) bool {
+	ok, err := e.lock.Acquire(ctx, e.cfg.Name, e.cfg.ID, e.cfg.TTL)
+	if err != nil {
+		if ctx.Err() == nil {
+			e.cfg.Logger.Warn("leader election failed", "job", e.cfg.Name, "error", err)
+		}
+		return false
+	}
+	return ok
+}
+
+// lead runs job while the lease is renewed, then releases the lease
+func (e *Elector) lead(ctx context.Context, job func(ctx context.Context)) {
+	e.leader.Store(true)
+	e.terms.Add(1)
+	e.cfg.Logger.Info("became leader", "job", e.cfg.Name, "id", e.cfg.ID)
+	defer func() {
+		e.leader.Store(false)
+		// Hand over right away on shutdown instead of waiting out the TTL
+		releaseCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), time.Second)
+		defer cancel()
+		_ = e.lock.Release(releaseCtx, e.cfg.Name, e.cfg.ID)
+		e.cfg.Logger.Info("leadership ended", "job", e.cfg.Name, "id", e.cfg.ID)
+	}()
+
+	jobCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var jobDone chan struct{} // nil while the job isn't running
+	start := func() {
+		jobDone = make(chan struct{})
+		go func(done chan struct{}) {
+			defer close(done)
+			job(jobCtx)
+		}(jobDone)
+	}
+	stop := func() {
+		cancel()
+		if jobDone != nil {
+			<-jobDone
+		}
+	}
+	start()
+
+	ticker := time.NewTicker(e.cfg.Retry)
+	defer ticker.Stop()
+	renewed := time.Now()
+	for {
+		select {
+		case <-ctx.Done():
+			stop()
+			return
+		case <-jobDone:
+			jobDone = nil
+		case <-ticker.C:
+			held, err := e.lock.Renew(ctx, e.cfg.Name, e.cfg.ID, e.cfg.TTL)
+			switch {
+			case err == nil && !held:
+				e.cfg.Logger.Warn("lost leadership", "job", e.cfg.Name, "id", e.cfg.ID)
+				stop()
+				return
+			case err != nil:
+				if time.Since(renewed)+e.cfg.Retry >= e.cfg.TTL {
+					// Can't confirm the lease before it could expire; another
+					// replica may take over, so stop first
+					e.cfg.Logger.Warn("leadership unconfirmed", "job", e.cfg.Name, "id", e.cfg.ID, "error", err)
+					stop()
+					return
+				}
+			default:
+				renewed = time.Now()
+				if jobDone == nil {
+					// The job returned on its own; run it again
+					start()
+				}
+			}
+		}
+	}
+}
+
+// Stats returns the elector's state and the current lease holder
+func (e *Elector) Stats(ctx context.Context) Stats {
+	st := Stats{Name: e.cfg.Name, ID: e.cfg.ID, Leader: e.IsLeader(), Terms: e.terms.Load(), LeaseTTL: e.cfg.TTL.String()}
+	st.Holder, _ = e.lock.Holder(ctx, e.cfg.Name)
+	return st
+}
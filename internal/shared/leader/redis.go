@@ -0,0 +1,65 @@
+package leader
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// renewScript extends the lease only if this instance still holds it
+var renewScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0`)
+
+// releaseScript deletes the lease only if this instance holds it
+var releaseScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0`)
+
+// RedisLock keeps each lease in a Redis key (SET NX with a TTL)
+type RedisLock struct {
+	client *redis.Client
+}
+
+// NewRedisLock creates a lock on client
+func NewRedisLock(client *redis.Client) *RedisLock {
+	return &RedisLock{client: client}
+}
+
+func redisKey(name string) string {
+	return "ldr|" + name
+}
+
+// Acquire sets the lease key if it doesn't exist
+func (l *RedisLock) Acquire(ctx context.Context, name, id string, ttl time.Duration) (bool, error) {
+	return l.client.SetNX(ctx, redisKey(name), id, ttl).Result()
+}
+
+// Renew resets the key's TTL if id holds it
+func (l *RedisLock) Renew(ctx context.Context, name, id string, ttl time.Duration) (bool, error) {
+	n, err := renewScript.Run(ctx, l.client, []string{redisKey(name)}, id, ttl.Milliseconds()).Int64()
+	if err != nil {
+		return false, err
+	}
+	return n == 1, nil
+}
+
+// Release deletes the key if id holds it
+func (l *RedisLock) Release(ctx context.Context, name, id string) error {
+	return releaseScript.Run(ctx, l.client, []string{redisKey(name)}, id).Err()
+}
+
+// Holder returns the key's value
+func (l *RedisLock) Holder(ctx context.Context, name string) (string, error) {
+	holder, err := l.client.Get(ctx, redisKey(name)).Result()
+	if errors.Is(err, redis.Nil) {
+		return "", nil
+	}
+	return holder, err
+}
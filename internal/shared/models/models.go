@@ -7,31 +7,296 @@ type RateLimit struct {
 	MaxRequests   int `json:"max_requests"`
 }
 
+// RouteLimits overrides the global body size limit and timeouts for a route.
+// Zero values inherit the gateway defaults.
+type RouteLimits struct {
+	MaxRequestBodyBytes    int64 `json:"max_request_body_bytes,omitempty"`
+	MaxResponseBodyBytes   int64 `json:"max_response_body_bytes,omitempty"`
+	UpstreamTimeoutSeconds int   `json:"upstream_timeout_seconds,omitempty"`
+	IdleTimeoutSeconds     int   `json:"idle_timeout_seconds,omitempty"`
+	MaxConcurrent          int   `json:"max_concurrent,omitempty"` // In-flight requests; 0 = unlimited
+	MaxQueue               int   `json:"max_queue,omitempty"`      // Requests waiting for a slot
+	QueueTimeoutMillis     int   `json:"queue_timeout_ms,omitempty"`
+	// Resilience names the upstream's resilience profile (aggressive,
+	// balanced or conservative): its timeout, retries and circuit breaker
+	Resilience string `json:"resilience,omitempty"`
+}
+
+// MirrorConfig asynchronously copies a sample of requests to a secondary upstream
+type MirrorConfig struct {
+	UpstreamURL string  `json:"upstream_url"`
+	Percent     float64 `json:"percent"` // 0-100
+	// ForwardCredentials sends the caller's Authorization, cookies and API
+	// key to the mirror too; by default they are stripped
+	ForwardCredentials bool `json:"forward_credentials,omitempty"`
+}
+
+// SplitTarget is one weighted upstream version in a traffic split
+type SplitTarget struct {
+	Name        string `json:"name"`
+	UpstreamURL string `json:"upstream_url"`
+	Weight      int    `json:"weight"`
+}
+
+// TrafficSplit routes a policy's traffic across upstream versions by weight
+type TrafficSplit struct {
+	Targets     []SplitTarget `json:"targets"`
+	StickyByDID bool          `json:"sticky_by_did,omitempty"` // Same DID always lands on the same target
+}
+
+// RequestTransform rewrites the upstream request. Values in SetQuery and
+// SetHeaders may reference token claims as ${sub}, ${vc_issuer} or
+// ${vc_claims.<path>}.
+type RequestTransform struct {
+	StripPrefix    string            `json:"strip_prefix,omitempty"`
+	RewritePattern string            `json:"rewrite_pattern,omitempty"` // Regex applied to the path
+	RewriteTo      string            `json:"rewrite_to,omitempty"`      // Replacement, may use $1 etc.
+	SetQuery       map[string]string `json:"set_query,omitempty"`
+	SetHeaders     map[string]string `json:"set_headers,omitempty"`
+}
+
+// BodyCondition asserts a JSON request body field, e.g. amount lte 1000.
+// Op is one of eq, ne, lt, lte, gt, gte, in, exists.
+type BodyCondition struct {
+	Field        string      `json:"field"` // Dot path, array indexes allowed (items.0.price)
+	Op           string      `json:"op"`
+	Value        interface{} `json:"value,omitempty"`
+	ExemptScopes []string    `json:"exempt_scopes,omitempty"` // Skip for tokens holding any of these
+}
+
+// TransformScripts are jq programs applied to JSON bodies. Scripts see the body
+// as "." and the variables $claims, $method, $path and $status (responses only).
+type TransformScripts struct {
+	Request  string `json:"request,omitempty"`
+	Response string `json:"response,omitempty"`
+}
+
+// Quota caps cumulative requests per UTC calendar day and month. Zero
+// disables that period.
+type Quota struct {
+	Scope   string `json:"scope,omitempty"` // "did" (default) or "issuer"
+	Daily   int64  `json:"daily,omitempty"`
+	Monthly int64  `json:"monthly,omitempty"`
+}
+
+// QuotaUsage is the request count of one subject under one policy for a
+// period: YYYY-MM-DD for days, YYYY-MM for months
+type QuotaUsage struct {
+	Scope     string    `json:"scope"`
+	Subject   string    `json:"subject"`
+	PolicyID  string    `json:"policy_id"`
+	Period    string    `json:"period"`
+	Count     int64     `json:"count"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// MeteringRecord is the usage of one DID on one route over a metering window
+type MeteringRecord struct {
+	ID          string    `json:"id"` // Unique per record so sinks can de-duplicate
+	DID         string    `json:"did"`
+	Tenant      string    `json:"tenant,omitempty"` // Issuer DID of the presented credential
+	Route       string    `json:"route"`            // Route template, not the raw path
+	PolicyID    string    `json:"policy_id"`
+	WindowStart time.Time `json:"window_start"`
+	WindowEnd   time.Time `json:"window_end"`
+	Requests    int64     `json:"requests"`
+	Errors      int64     `json:"errors"` // Responses with status >= 500
+	BytesIn     int64     `json:"bytes_in"`
+	BytesOut    int64     `json:"bytes_out"`
+}
+
+// TenantUsage sums a tenant's metering records, overall or for one route
+// and policy
+type TenantUsage struct {
+	Tenant   string `json:"tenant"`
+	Route    string `json:"route,omitempty"`
+	PolicyID string `json:"policy_id,omitempty"`
+	Requests int64  `json:"requests"`
+	Errors   int64  `json:"errors"`
+	BytesIn  int64  `json:"bytes_in"`
+	BytesOut int64  `json:"bytes_out"`
+	DIDs     int64  `json:"dids"` // Distinct holders
+}
+
+// TrustScoreRule holds callers whose DID trust score is below MinScore to
+// stricter terms. They are denied unless StepUp lets them through with a
+// verified credential or RateLimit lets them through at a lower rate.
+type TrustScoreRule struct {
+	MinScore  int        `json:"min_score"`
+	StepUp    bool       `json:"step_up,omitempty"`
+	RateLimit *RateLimit `json:"rate_limit,omitempty"` // Replaces the policy's rate limit below MinScore
+}
+
+// ReceiptConfig turns on gateway-signed receipts for a route
+type ReceiptConfig struct {
+	Mode string `json:"mode,omitempty"` // "upstream" (default), "response" or "both"
+}
+
+// ResponseSigning turns on gateway signatures over upstream responses
+type ResponseSigning struct {
+	Headers []string `json:"headers,omitempty"` // Covered response headers besides Content-Type
+}
+
+// EnvelopeEncryption turns on JWE envelope mode for a route: request bodies
+// must be encrypted to the gateway and responses are encrypted to the caller
+type EnvelopeEncryption struct {
+	Enc string `json:"enc,omitempty"` // Response content encryption: "A256GCM" (default) or "A128GCM"
+}
+
 type Policy struct {
-	ID              string     `json:"id"`
-	Name            string     `json:"name"`
-	RoutePrefix     string     `json:"route_prefix"`
-	RequiredScopes  []string   `json:"required_scopes"`
-	RequiredVCTypes []string   `json:"required_vc_types,omitempty"`
-	AllowedIssuers  []string   `json:"allowed_issuers,omitempty"`
-	MinTrustTier    *int       `json:"min_trust_tier,omitempty"`
-	RateLimit       *RateLimit `json:"rate_limit,omitempty"`
-	TokenTTLSeconds int        `json:"token_ttl_seconds"`
+	ID                        string              `json:"id"`
+	Name                      string              `json:"name"`
+	RoutePrefix               string              `json:"route_prefix"`
+	Route                     string              `json:"route,omitempty"`    // Path template, e.g. /api/v1/users/{id}/*
+	Methods                   []string            `json:"methods,omitempty"`  // Empty matches any method
+	Priority                  int                 `json:"priority,omitempty"` // Higher wins over specificity
+	RequiredScopes            []string            `json:"required_scopes"`
+	RequiredVCTypes           []string            `json:"required_vc_types,omitempty"`
+	AllowedIssuers            []string            `json:"allowed_issuers,omitempty"`
+	MinTrustTier              *int                `json:"min_trust_tier,omitempty"`
+	TrustScore                *TrustScoreRule     `json:"trust_score,omitempty"`
+	RequireDomainLinkedIssuer bool                `json:"require_domain_linked_issuer,omitempty"`
+	RateLimit                 *RateLimit          `json:"rate_limit,omitempty"`
+	Quota                     *Quota              `json:"quota,omitempty"`
+	PriorityClass             string              `json:"priority_class,omitempty"` // critical, normal or bulk
+	Limits                    *RouteLimits        `json:"limits,omitempty"`
+	Mirror                    *MirrorConfig       `json:"mirror,omitempty"`
+	TrafficSplit              *TrafficSplit       `json:"traffic_split,omitempty"`
+	Transform                 *RequestTransform   `json:"transform,omitempty"`
+	Receipt                   *ReceiptConfig      `json:"receipt,omitempty"`
+	SignResponse              *ResponseSigning    `json:"sign_response,omitempty"`
+	Envelope                  *EnvelopeEncryption `json:"envelope,omitempty"`
+	BodyConditions            []BodyCondition     `json:"body_conditions,omitempty"`
+	Filters                   []string            `json:"filters,omitempty"` // WASM filter chain, by name
+	Scripts                   *TransformScripts   `json:"scripts,omitempty"`
+	AllowAPIKeys              bool                `json:"allow_api_keys,omitempty"`
+	TokenTTLSeconds           int                 `json:"token_ttl_seconds"`
+	Shadow                    bool                `json:"shadow,omitempty"` // Evaluate and record denials without enforcing them
 }
 
 type Issuer struct {
+	DID       string `json:"did"`
+	PublicKey string `json:"public_key"`
+	Enabled   bool   `json:"enabled"`
+	TrustTier int    `json:"trust_tier"`
+	// KeyPinning "enforce" requires the key named by a credential's kid in the
+	// issuer's resolved DID document to equal PublicKey
+	KeyPinning KeyPinningMode `json:"key_pinning,omitempty"`
+	CreatedAt  time.Time      `json:"created_at"`
+	UpdatedAt  time.Time      `json:"updated_at"`
+}
+
+// KeyPinningMode is how an issuer's resolved keys are checked
+type KeyPinningMode string
+
+// Key pinning modes
+const (
+	KeyPinningOff     KeyPinningMode = "off"     // Only PublicKey is checked
+	KeyPinningEnforce KeyPinningMode = "enforce" // Resolved keys must match PublicKey
+)
+
+// Valid reports whether m is a known mode. Unset means off.
+func (m KeyPinningMode) Valid() bool {
+	return m == "" || m == KeyPinningOff || m == KeyPinningEnforce
+}
+
+// APIKey is a managed key for service clients that can't do DID auth. Only
+// the SHA-256 of the secret is stored; after a rotation the previous secret
+// stays valid until PreviousExpiresAt.
+type APIKey struct {
+	ID                string     `json:"id"`
+	Name              string     `json:"name"`
+	Hash              string     `json:"-"`
+	PreviousHash      string     `json:"-"`
+	PreviousExpiresAt *time.Time `json:"previous_expires_at,omitempty"`
+	Scopes            []string   `json:"scopes"`
+	ExpiresAt         time.Time  `json:"expires_at"`
+	Revoked           bool       `json:"revoked"`
+	CreatedAt         time.Time  `json:"created_at"`
+	UpdatedAt         time.Time  `json:"updated_at"`
+}
+
+// Admin is a DID allowed to use the admin API with a role
+type Admin struct {
 	DID       string    `json:"did"`
-	PublicKey string    `json:"public_key"`
+	Role      string    `json:"role"`
 	Enabled   bool      `json:"enabled"`
-	TrustTier int       `json:"trust_tier"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 }
 
+// Account is an end user known to the gateway by contact details, bound to
+// the DID they authenticate with. Recovery rebinds DID to a new key's DID.
+type Account struct {
+	ID          string    `json:"id"`
+	DID         string    `json:"did"`
+	PreviousDID string    `json:"previous_did,omitempty"`
+	Email       string    `json:"email,omitempty"`
+	Phone       string    `json:"phone,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// AccountLink maps a DID to an internal account ID. An account may have
+// several DIDs; a DID belongs to at most one account.
+type AccountLink struct {
+	DID       string    `json:"did"`
+	AccountID string    `json:"account_id"`
+	LinkedBy  string    `json:"linked_by,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Device is one key a DID authenticates with: a verification method of its
+// DID document, or a device key registered with the gateway
+type Device struct {
+	ID         string     `json:"id"`
+	DID        string     `json:"did"`
+	KeyID      string     `json:"key_id"` // Verification method ID, or the device's did:key
+	Label      string     `json:"label,omitempty"`
+	Source     string     `json:"source"` // "document" or "registry"
+	CreatedAt  time.Time  `json:"created_at"`
+	LastSeenAt time.Time  `json:"last_seen_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
+
+// AlertEndpoint is where a DID's owner receives login alerts: a webhook URL
+// or a push token for FCM or APNs
+type AlertEndpoint struct {
+	ID        string    `json:"id"`
+	DID       string    `json:"did"`
+	Channel   string    `json:"channel"` // "webhook", "fcm" or "apns"
+	Target    string    `json:"target"`  // URL or device push token
+	CreatedAt time.Time `json:"created_at"`
+}
+
 type RevocationList struct {
 	ListID    string    `json:"listId"`
 	Revoked   []string  `json:"revoked"`
 	UpdatedAt time.Time `json:"updatedAt"`
+	// Owner is the issuer DID allowed to push deltas to the list (optional)
+	Owner string `json:"owner,omitempty"`
+	// Seq increases with every change, full replace or delta
+	Seq int64 `json:"seq"`
+}
+
+// RevocationDelta is one incremental change to a revocation list
+type RevocationDelta struct {
+	ListID  string   `json:"listId"`
+	Seq     int64    `json:"seq"`
+	Added   []string `json:"added,omitempty"`
+	Removed []string `json:"removed,omitempty"`
+}
+
+// RevocationChanges brings a consumer from a known seq to the current one:
+// the deltas since then, or the full list when they are no longer available
+// (the list was replaced since)
+type RevocationChanges struct {
+	ListID  string            `json:"listId"`
+	Seq     int64             `json:"seq"`
+	Full    bool              `json:"full"`
+	Revoked []string          `json:"revoked,omitempty"`
+	Deltas  []RevocationDelta `json:"deltas,omitempty"`
 }
 
 type ChallengeResponse struct {
@@ -40,6 +305,11 @@ type ChallengeResponse struct {
 	ExpiresAt int64  `json:"expiresAt"`
 	Audience  string `json:"audience"`
 	Domain    string `json:"domain"`
+
+	// Optional bindings echoed back when requested
+	Origin        string `json:"origin,omitempty"`
+	ClientID      string `json:"client_id,omitempty"`
+	CodeChallenge string `json:"code_challenge,omitempty"`
 }
 
 type AuthVerifyRequest struct {
@@ -49,6 +319,10 @@ type AuthVerifyRequest struct {
 	Scopes       []string `json:"scopes,omitempty"`
 	Credential   string   `json:"credential,omitempty"`
 	Presentation string   `json:"presentation,omitempty"`
+	ClientID     string   `json:"client_id,omitempty"`
+	CodeVerifier string   `json:"code_verifier,omitempty"`
+	// CrossDeviceID hands the minted token to the browser session that showed the QR code
+	CrossDeviceID string `json:"cross_device_id,omitempty"`
 }
 
 type AuthVerifyResponse struct {
@@ -58,16 +332,20 @@ type AuthVerifyResponse struct {
 }
 
 type AccessTokenClaims struct {
-	Subject     string   `json:"sub"`
-	Scopes      []string `json:"scopes"`
-	VCTypes     []string `json:"vc_types,omitempty"`
-	VCIssuer    string   `json:"vc_issuer,omitempty"`
-	VCTrustTier int      `json:"vc_trust_tier,omitempty"`
-	Issuer      string   `json:"iss"`
-	IssuedAt    int64    `json:"iat"`
-	ExpiresAt   int64    `json:"exp"`
-	JWTID       string   `json:"jti"`
-	KeyID       string   `json:"kid,omitempty"` // Signing key ID (for rotation tracking)
+	Subject     string                 `json:"sub"`
+	Scopes      []string               `json:"scopes"`
+	VCTypes     []string               `json:"vc_types,omitempty"`
+	VCIssuer    string                 `json:"vc_issuer,omitempty"`
+	VCTrustTier int                    `json:"vc_trust_tier,omitempty"`
+	VCClaims    map[string]interface{} `json:"vc_claims,omitempty"`   // Selected credentialSubject claims
+	AccountID   string                 `json:"account_id,omitempty"`  // Internal account linked to the subject DID
+	TrustScore  int                    `json:"trust_score,omitempty"` // 0-100 from the DID's history; absent is 0
+	DeviceID    string                 `json:"device_id,omitempty"`   // Device the subject authenticated with
+	Issuer      string                 `json:"iss"`
+	IssuedAt    int64                  `json:"iat"`
+	ExpiresAt   int64                  `json:"exp"`
+	JWTID       string                 `json:"jti"`
+	KeyID       string                 `json:"kid,omitempty"` // Signing key ID (for rotation tracking)
 }
 
 type CredentialClaims struct {
@@ -86,4 +364,6 @@ type AuditEvent struct {
 	Actor    string                 `json:"actor,omitempty"`
 	Outcome  string                 `json:"outcome"`
 	Metadata map[string]interface{} `json:"metadata,omitempty"`
+	Region   string                 `json:"region,omitempty"` // Gateway region that recorded the event
+	Tenant   string                 `json:"tenant,omitempty"` // Issuer DID of the credential behind the request
 }
@@ -0,0 +1,267 @@
+package observability
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"math/rand"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Access log formats
+const (
+	AccessFormatJSON = "json" // A structured "access" line through Logger; the default
+	// AccessFormatCombined writes NCSA Combined Log Format lines, as Apache,
+	// NGINX and Caddy do, for analytics pipelines built around web server
+	// logs
+	AccessFormatCombined = "combined"
+)
+
+// AccessIdentify returns the route template and authenticated DID of a
+// request after it was served; either may be empty
+type AccessIdentify func(r *http.Request) (route, did string)
+
+// AccessLogConfig configures the access log
+type AccessLogConfig struct {
+	Logger   *slog.Logger
+	Identify AccessIdentify // Default: no route template or subject
+	// Sample keeps this fraction of requests whose route (or path) starts
+	// with the key, longest prefix first, e.g. {"/v1/auth/": 0.1}. Other
+	// routes are always logged. Server errors and slow requests are logged
+	// regardless of sampling.
+	Sample map[string]float64
+	Slow   time.Duration // Default 1s
+	// SubjectKey keys the HMAC that pseudonymizes DIDs in the log, so lines
+	// of one subject correlate without naming it. Without a key the hash is
+	// unkeyed and DIDs can be confirmed by hashing a guess.
+	SubjectKey []byte
+	// Format is AccessFormatJSON (default) or AccessFormatCombined
+	Format string
+	// Output receives combined lines (default stdout); JSON lines go through
+	// Logger
+	Output io.Writer
+	// ClientIP puts the client address in combined lines; otherwise the
+	// host field is "-", as the JSON log never records addresses
+	ClientIP bool
+}
+
+type accessKey struct{}
+
+// accessEntry collects annotations for one request's access log line
+type accessEntry struct {
+	mu    sync.Mutex
+	attrs []slog.Attr
+}
+
+// Annotate adds a field (e.g. "did_cache"="l1", "breaker"="open") to the access
+// log line of the request carrying ctx. It does nothing outside AccessLog.
+func Annotate(ctx context.Context, key string, value string) {
+	e, ok := ctx.Value(accessKey{}).(*accessEntry)
+	if !ok {
+		return
+	}
+	e.mu.Lock()
+	e.attrs = append(e.attrs, slog.String(key, value))
+	e.mu.Unlock()
+}
+
+// AccessLog writes one line per request served by next: method, route,
+// hashed subject DID, status, response bytes, latency and any annotations,
+// or in the combined format its fields. Query strings are logged redacted;
+// headers and bodies never are, except the combined format's referer and
+// user agent.
+func AccessLog(cfg AccessLogConfig, next http.Handler) http.Handler {
+	cfg.Logger = Component(cfg.Logger, ComponentHTTP)
+	if cfg.Slow == 0 {
+		cfg.Slow = time.Second
+	}
+	if cfg.Identify == nil {
+		cfg.Identify = func(*http.Request) (string, string) { return "", "" }
+	}
+	prefixes := make([]string, 0, len(cfg.Sample))
+	for p := range cfg.Sample {
+		prefixes = append(prefixes, p)
+	}
+	sort.Slice(prefixes, func(i, j int) bool { return len(prefixes[i]) > len(prefixes[j]) })
+	rate := func(route string) float64 {
+		for _, p := range prefixes {
+			if strings.HasPrefix(route, p) {
+				return cfg.Sample[p]
+			}
+		}
+		return 1
+	}
+
+	combined := cfg.Format == AccessFormatCombined
+	if combined && cfg.Output == nil {
+		cfg.Output = os.Stdout
+	}
+	var outMu sync.Mutex
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		entry := &accessEntry{}
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		r = r.WithContext(context.WithValue(r.Context(), accessKey{}, entry))
+		next.ServeHTTP(sw, r)
+		latency := time.Since(start)
+
+		route, did := cfg.Identify(r)
+		if route == "" {
+			route = r.URL.Path
+		}
+		sampleRate := rate(route)
+		if sw.status < 500 && latency < cfg.Slow && sampleRate < 1 && rand.Float64() >= sampleRate {
+			return
+		}
+
+		if combined {
+			line := combinedLine(cfg, r, route, did, sw, start)
+			outMu.Lock()
+			_, _ = io.WriteString(cfg.Output, line)
+			outMu.Unlock()
+			return
+		}
+
+		attrs := []slog.Attr{
+			slog.String("method", r.Method),
+			slog.String("route", route),
+			slog.Int("status", sw.status),
+			slog.Int64("bytes", sw.written),
+			slog.Float64("latency_ms", float64(latency.Microseconds())/1000),
+		}
+		if q := RedactQuery(r.URL.RawQuery); q != "" {
+			attrs = append(attrs, slog.String("query", q))
+		}
+		if did != "" {
+			attrs = append(attrs, slog.String("subject", subjectHash(cfg.SubjectKey, did)))
+		}
+		if id := r.Header.Get("X-Request-ID"); id != "" {
+			attrs = append(attrs, slog.String("request_id", id))
+		}
+		if sampleRate < 1 {
+			attrs = append(attrs, slog.Float64("sample_rate", sampleRate))
+		}
+		entry.mu.Lock()
+		for _, a := range entry.attrs {
+			attrs = append(attrs, RedactAttr(nil, a))
+		}
+		entry.mu.Unlock()
+
+		level := slog.LevelInfo
+		if sw.status >= 500 {
+			level = slog.LevelError
+		} else if latency >= cfg.Slow {
+			level = slog.LevelWarn
+		}
+		cfg.Logger.LogAttrs(r.Context(), level, "access", attrs...)
+	})
+}
+
+// combinedLine formats a request in Combined Log Format:
+//
+//	host - subject [time] "METHOD route?query PROTO" status bytes "referer" "user-agent"
+//
+// With the same privacy rules as the JSON log: the route template rather
+// than the path, the redacted query and referer query, and the hashed
+// subject as the user.
+func combinedLine(cfg AccessLogConfig, r *http.Request, route, did string, sw *statusWriter, start time.Time) string {
+	host := "-"
+	if cfg.ClientIP {
+		host = r.RemoteAddr
+		if h, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+			host = h
+		}
+	}
+	user := "-"
+	if did != "" {
+		user = subjectHash(cfg.SubjectKey, did)
+	}
+	target := route
+	if q := RedactQuery(r.URL.RawQuery); q != "" {
+		target += "?" + q
+	}
+	bytes := "-"
+	if sw.written > 0 {
+		bytes = strconv.FormatInt(sw.written, 10)
+	}
+	referer := r.Referer()
+	if base, query, ok := strings.Cut(referer, "?"); ok {
+		referer = base + "?" + RedactQuery(query)
+	}
+	var b strings.Builder
+	b.WriteString(host)
+	b.WriteString(" - ")
+	b.WriteString(user)
+	b.WriteString(start.Format(" [02/Jan/2006:15:04:05 -0700] "))
+	b.WriteString(clfQuote(r.Method + " " + target + " " + r.Proto))
+	b.WriteString(" " + strconv.Itoa(sw.status) + " " + bytes + " ")
+	b.WriteString(clfQuote(referer))
+	b.WriteString(" ")
+	b.WriteString(clfQuote(r.UserAgent()))
+	b.WriteString("\n")
+	return b.String()
+}
+
+// clfQuote quotes s as NGINX does, escaping quotes, backslashes and control
+// characters as \xHH; empty values are "-"
+func clfQuote(s string) string {
+	if s == "" {
+		return `"-"`
+	}
+	var b strings.Builder
+	b.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c == '"' || c == '\\' || c < 0x20 || c >= 0x7f {
+			b.WriteString(`\x`)
+			b.WriteString(strconv.FormatUint(uint64(c)>>4, 16))
+			b.WriteString(strconv.FormatUint(uint64(c)&0xf, 16))
+			continue
+		}
+		b.WriteByte(c)
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// subjectHash pseudonymizes a DID: the first 16 hex characters of its
+// HMAC-SHA256 under key
+func subjectHash(key []byte, did string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(did))
+	return hex.EncodeToString(mac.Sum(nil))[:16]
+}
+
+// statusWriter records the status and body bytes written
+type statusWriter struct {
+	http.ResponseWriter
+	status  int
+	written int64
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(b)
+	w.written += int64(n)
+	return n, err
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,39 @@
+package observability
+
+import "log/slog"
+
+// ComponentKey is the log attribute naming the subsystem a line comes from
+const ComponentKey = "component"
+
+// Components of the gateway. Each subsystem tags its logger with one, so
+// its level can be raised on its own (LOG_LEVEL=info,resolver=debug or
+// PUT /admin/v1/loglevel) and its lines filtered by component.
+const (
+	ComponentResolver   = "resolver"   // DID resolution: methods, breakers, document cache
+	ComponentCache      = "cache"      // Shared cache layers and write-behind
+	ComponentProxy      = "proxy"      // Upstream routing and health checks
+	ComponentPolicy     = "policy"     // Policy evaluation and shadow policies
+	ComponentAudit      = "audit"      // Audit event recording and streaming
+	ComponentAuth       = "auth"       // Admin login, sessions and API keys
+	ComponentAdmin      = "admin"      // Admin API and dashboard
+	ComponentIssuance   = "issuance"   // OpenID4VCI credential issuance
+	ComponentRecovery   = "recovery"   // Account recovery and key rotation
+	ComponentRevocation = "revocation" // Revocation lists, sync and filters
+	ComponentEvents     = "events"     // Message bus, event publishing and webhooks
+	ComponentMetering   = "metering"   // Usage metering, quotas and tenant metrics
+	ComponentJobs       = "jobs"       // Scheduler, leader election and cleanup
+	ComponentConfig     = "config"     // GitOps sync and configuration bundles
+	ComponentHealth     = "health"     // Health checks and transitions
+	ComponentHTTP       = "http"       // Access log
+	ComponentChaos      = "chaos"      // Fault injection (dev and staging only)
+)
+
+// Component returns a child of logger tagged with component (one of the
+// Component constants); tagging an already tagged logger replaces its
+// component. A nil logger is slog.Default().
+func Component(logger *slog.Logger, component string) *slog.Logger {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return logger.With(ComponentKey, component)
+}
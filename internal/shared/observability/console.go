@@ -0,0 +1,170 @@
+package observability
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+const (
+	ansiReset  = "\x1b[0m"
+	ansiFaint  = "\x1b[2m"
+	ansiBold   = "\x1b[1m"
+	ansiRed    = "\x1b[31m"
+	ansiGreen  = "\x1b[32m"
+	ansiYellow = "\x1b[33m"
+	ansiBlue   = "\x1b[34m"
+	ansiCyan   = "\x1b[36m"
+)
+
+// consoleHandler writes one human-readable line per record for local
+// development:
+//
+//	15:04:05.000 INF DID resolved method=web duration_ms=12 component=resolver
+//
+// Attributes of nested groups are flattened to dotted keys. ReplaceAttr is
+// applied to attributes but not to the time, level and message.
+type consoleHandler struct {
+	mu     *sync.Mutex
+	w      io.Writer
+	opts   slog.HandlerOptions
+	color  bool
+	attrs  []byte   // preformatted attributes from WithAttrs
+	groups []string // open groups, for ReplaceAttr
+	prefix string   // dotted key prefix of the open groups
+}
+
+func newConsoleHandler(w io.Writer, opts *slog.HandlerOptions, color bool) *consoleHandler {
+	h := &consoleHandler{mu: &sync.Mutex{}, w: w, color: color}
+	if opts != nil {
+		h.opts = *opts
+	}
+	return h
+}
+
+func (h *consoleHandler) Enabled(_ context.Context, level slog.Level) bool {
+	min := slog.LevelInfo
+	if h.opts.Level != nil {
+		min = h.opts.Level.Level()
+	}
+	return level >= min
+}
+
+func (h *consoleHandler) Handle(_ context.Context, r slog.Record) error {
+	var buf bytes.Buffer
+	if !r.Time.IsZero() {
+		h.paint(&buf, ansiFaint, r.Time.Format("15:04:05.000"))
+		buf.WriteByte(' ')
+	}
+	label, color := levelLabel(r.Level)
+	h.paint(&buf, color, label)
+	buf.WriteByte(' ')
+	h.paint(&buf, ansiBold, r.Message)
+	buf.Write(h.attrs)
+	r.Attrs(func(a slog.Attr) bool {
+		h.appendAttr(&buf, h.prefix, h.groups, a)
+		return true
+	})
+	buf.WriteByte('\n')
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, err := h.w.Write(buf.Bytes())
+	return err
+}
+
+func (h *consoleHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	c := *h
+	buf := bytes.NewBuffer(append([]byte(nil), h.attrs...))
+	for _, a := range attrs {
+		h.appendAttr(buf, h.prefix, h.groups, a)
+	}
+	c.attrs = buf.Bytes()
+	return &c
+}
+
+func (h *consoleHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	c := *h
+	c.groups = append(append([]string(nil), h.groups...), name)
+	c.prefix = h.prefix + name + "."
+	return &c
+}
+
+func (h *consoleHandler) appendAttr(buf *bytes.Buffer, prefix string, groups []string, a slog.Attr) {
+	a.Value = a.Value.Resolve()
+	if a.Value.Kind() == slog.KindGroup {
+		members := a.Value.Group()
+		if len(members) == 0 {
+			return
+		}
+		if a.Key != "" {
+			prefix += a.Key + "."
+			groups = append(groups[:len(groups):len(groups)], a.Key)
+		}
+		for _, m := range members {
+			h.appendAttr(buf, prefix, groups, m)
+		}
+		return
+	}
+	if h.opts.ReplaceAttr != nil {
+		a = h.opts.ReplaceAttr(groups, a)
+		a.Value = a.Value.Resolve()
+	}
+	if a.Equal(slog.Attr{}) {
+		return
+	}
+	buf.WriteByte(' ')
+	h.paint(buf, ansiCyan, prefix+a.Key+"=")
+	var v string
+	if a.Value.Kind() == slog.KindTime {
+		v = a.Value.Time().Format(time.RFC3339Nano)
+	} else {
+		v = a.Value.String()
+	}
+	buf.WriteString(quoteValue(v))
+}
+
+func (h *consoleHandler) paint(buf *bytes.Buffer, color, s string) {
+	if !h.color {
+		buf.WriteString(s)
+		return
+	}
+	buf.WriteString(color)
+	buf.WriteString(s)
+	buf.WriteString(ansiReset)
+}
+
+func levelLabel(l slog.Level) (string, string) {
+	switch {
+	case l >= slog.LevelError:
+		return "ERR", ansiRed
+	case l >= slog.LevelWarn:
+		return "WRN", ansiYellow
+	case l >= slog.LevelInfo:
+		return "INF", ansiGreen
+	default:
+		return "DBG", ansiBlue
+	}
+}
+
+// quoteValue quotes values that would not read back as a single token
+func quoteValue(s string) string {
+	if s == "" {
+		return `""`
+	}
+	if strings.IndexFunc(s, func(r rune) bool {
+		return unicode.IsSpace(r) || r == '"' || r == '=' || !unicode.IsPrint(r)
+	}) >= 0 {
+		return strconv.Quote(s)
+	}
+	return s
+}
@@ -0,0 +1,259 @@
+package observability
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/example/privacy-gateway/internal/shared/httpx"
+)
+
+var ErrInvalidLevel = errors.New("invalid log level")
+
+// Levels holds the log level of every logger created by NewLogger: a base
+// level plus per-component overrides, all changeable at runtime
+type Levels struct {
+	base slog.LevelVar
+
+	mu         sync.RWMutex
+	components map[string]slog.Level
+}
+
+// NewLevels creates levels at base with no component overrides
+func NewLevels(base slog.Level) *Levels {
+	l := &Levels{components: make(map[string]slog.Level)}
+	l.base.Set(base)
+	return l
+}
+
+// Parse applies a LOG_LEVEL spec: a base level optionally followed by
+// component overrides, e.g. "info,resolver=debug,proxy=warn". Nothing changes
+// if any part is invalid.
+func (l *Levels) Parse(spec string) error {
+	var req levelsRequest
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		component, level, ok := strings.Cut(part, "=")
+		if !ok {
+			req.Level = part
+			continue
+		}
+		if req.Components == nil {
+			req.Components = make(map[string]string)
+		}
+		req.Components[strings.TrimSpace(component)] = level
+	}
+	return applyLevels(l, req)
+}
+
+func parseLevel(s string) (slog.Level, error) {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(strings.TrimSpace(s))); err != nil {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidLevel, s)
+	}
+	return lvl, nil
+}
+
+// Set changes the level of component, or the base level for ""
+func (l *Levels) Set(component string, level slog.Level) {
+	if component == "" {
+		l.base.Set(level)
+		return
+	}
+	l.mu.Lock()
+	l.components[component] = level
+	l.mu.Unlock()
+}
+
+// Reset drops the override of component, which then follows the base level
+func (l *Levels) Reset(component string) {
+	l.mu.Lock()
+	delete(l.components, component)
+	l.mu.Unlock()
+}
+
+// Level returns the effective level of component
+func (l *Levels) Level(component string) slog.Level {
+	if component != "" {
+		l.mu.RLock()
+		lvl, ok := l.components[component]
+		l.mu.RUnlock()
+		if ok {
+			return lvl
+		}
+	}
+	return l.base.Level()
+}
+
+// LevelsView is the JSON form of Levels
+type LevelsView struct {
+	Level      string            `json:"level"`
+	Components map[string]string `json:"components"`
+}
+
+// View returns the current levels
+func (l *Levels) View() LevelsView {
+	v := LevelsView{Level: strings.ToLower(l.base.Level().String()), Components: make(map[string]string)}
+	l.mu.RLock()
+	for m, lvl := range l.components {
+		v.Components[m] = strings.ToLower(lvl.String())
+	}
+	l.mu.RUnlock()
+	return v
+}
+
+// Handler wraps h so records are filtered by these levels instead of h's own
+func (l *Levels) Handler(h slog.Handler) slog.Handler {
+	return &levelHandler{next: h, levels: l}
+}
+
+// levelHandler filters records by the level of the component its logger was
+// tagged with (see Component). The component attribute is held back and
+// added to each record, so re-tagging a child logger replaces it rather than
+// repeating the key.
+type levelHandler struct {
+	next      slog.Handler
+	levels    *Levels
+	component string
+	committed bool // component already passed to next, before a group
+}
+
+func (h *levelHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.levels.Level(h.component)
+}
+
+func (h *levelHandler) Handle(ctx context.Context, r slog.Record) error {
+	if h.component != "" && !h.committed {
+		r = r.Clone()
+		r.AddAttrs(slog.String(ComponentKey, h.component))
+	}
+	return h.next.Handle(ctx, r)
+}
+
+func (h *levelHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	c := *h
+	rest := make([]slog.Attr, 0, len(attrs))
+	for _, a := range attrs {
+		if a.Key == ComponentKey && !h.committed {
+			c.component = a.Value.String()
+			continue
+		}
+		rest = append(rest, a)
+	}
+	if len(rest) > 0 {
+		c.next = h.next.WithAttrs(rest)
+	}
+	return &c
+}
+
+func (h *levelHandler) WithGroup(name string) slog.Handler {
+	c := *h
+	next := h.next
+	if h.component != "" && !h.committed {
+		// Keep the component at the top level rather than inside the group
+		next = next.WithAttrs([]slog.Attr{slog.String(ComponentKey, h.component)})
+		c.committed = true
+	}
+	c.next = next.WithGroup(name)
+	return &c
+}
+
+// levelsRequest changes levels; an empty component level removes the override
+type levelsRequest struct {
+	Level      string            `json:"level,omitempty"`
+	Components map[string]string `json:"components,omitempty"`
+	// Modules is the name components had when the endpoint was added; it is
+	// still accepted, and Components wins where both name one
+	Modules map[string]string `json:"modules,omitempty"`
+}
+
+// LevelsHandler serves the runtime log levels, mounted behind admin auth:
+//
+//	GET /admin/v1/loglevel  current levels
+//	PUT /admin/v1/loglevel  {"level": "warn", "components": {"resolver": "debug", "proxy": ""}}
+//
+// A PUT changes only what it names and is applied in full or not at all.
+func LevelsHandler(levels *Levels, logger *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPut:
+			var req levelsRequest
+			if err := httpx.DecodeJSONLimit(r, &req, 4<<10); err != nil {
+				httpx.WriteJSON(w, http.StatusBadRequest, httpx.ErrorResponse{Error: "invalid request body"})
+				return
+			}
+			req.mergeModules()
+			if err := applyLevels(levels, req); err != nil {
+				httpx.WriteJSON(w, http.StatusBadRequest, httpx.ErrorResponse{Error: err.Error()})
+				return
+			}
+			logger.Warn("log levels changed", "level", req.Level, "components", req.Components)
+		default:
+			httpx.WriteJSON(w, http.StatusMethodNotAllowed, httpx.ErrorResponse{Error: "method not allowed"})
+			return
+		}
+		httpx.WriteJSON(w, http.StatusOK, levels.View())
+	}
+}
+
+// mergeModules folds the deprecated modules field into Components
+func (req *levelsRequest) mergeModules() {
+	if len(req.Modules) == 0 {
+		return
+	}
+	if req.Components == nil {
+		req.Components = make(map[string]string, len(req.Modules))
+	}
+	for m, spec := range req.Modules {
+		if _, ok := req.Components[m]; !ok {
+			req.Components[m] = spec
+		}
+	}
+	req.Modules = nil
+}
+
+// applyLevels validates every level in req before changing any
+func applyLevels(levels *Levels, req levelsRequest) error {
+	var base *slog.Level
+	if req.Level != "" {
+		lvl, err := parseLevel(req.Level)
+		if err != nil {
+			return err
+		}
+		base = &lvl
+	}
+	parsed := make(map[string]slog.Level, len(req.Components))
+	for m, spec := range req.Components {
+		if m == "" {
+			return fmt.Errorf("%w: empty component name", ErrInvalidLevel)
+		}
+		if spec == "" {
+			continue
+		}
+		lvl, err := parseLevel(spec)
+		if err != nil {
+			return fmt.Errorf("%w (component %s)", err, m)
+		}
+		parsed[m] = lvl
+	}
+
+	if base != nil {
+		levels.Set("", *base)
+	}
+	for m := range req.Components {
+		if lvl, ok := parsed[m]; ok {
+			levels.Set(m, lvl)
+		} else {
+			levels.Reset(m)
+		}
+	}
+	return nil
+}
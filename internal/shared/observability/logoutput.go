@@ -0,0 +1,184 @@
+package observability
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strconv"
+	"sync"
+)
+
+// Log formats
+const (
+	FormatJSON    = "json"    // One JSON object per line; the default, for production
+	FormatLogfmt  = "logfmt"  // key=value lines for collectors that predate JSON
+	FormatConsole = "console" // Aligned, colored lines for development
+)
+
+var ErrInvalidLogConfig = errors.New("invalid log config")
+
+// LogConfig selects the log encoder and where lines go
+type LogConfig struct {
+	Format string // FormatJSON (default), FormatLogfmt or FormatConsole
+	// Color is "auto" (default: color when writing to a terminal), "always"
+	// or "never"; only the console format uses color
+	Color string
+	// File logs to this path instead of stdout, rotating it by size
+	File       string
+	MaxSizeMB  int // Rotate the file past this size (default 100)
+	MaxBackups int // Rotated files kept as File.1 ... File.N (default 5)
+}
+
+// LogConfigFromEnv reads LOG_FORMAT, LOG_COLOR, LOG_FILE,
+// LOG_FILE_MAX_SIZE_MB and LOG_FILE_MAX_BACKUPS
+func LogConfigFromEnv() (LogConfig, error) {
+	cfg := LogConfig{
+		Format: os.Getenv("LOG_FORMAT"),
+		Color:  os.Getenv("LOG_COLOR"),
+		File:   os.Getenv("LOG_FILE"),
+	}
+	for env, dst := range map[string]*int{"LOG_FILE_MAX_SIZE_MB": &cfg.MaxSizeMB, "LOG_FILE_MAX_BACKUPS": &cfg.MaxBackups} {
+		if v := os.Getenv(env); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				return cfg, fmt.Errorf("%w: %s=%q", ErrInvalidLogConfig, env, v)
+			}
+			*dst = n
+		}
+	}
+	return cfg, nil
+}
+
+// NewLogHandler creates the handler cfg selects. The closer closes the log
+// file, if any; call it on shutdown.
+func NewLogHandler(cfg LogConfig, opts *slog.HandlerOptions) (slog.Handler, io.Closer, error) {
+	var (
+		out    io.Writer = os.Stdout
+		closer io.Closer = io.NopCloser(nil)
+		tty              = isTerminal(os.Stdout)
+	)
+	if cfg.File != "" {
+		f, err := NewRotatingFile(cfg.File, cfg.MaxSizeMB, cfg.MaxBackups)
+		if err != nil {
+			return nil, nil, err
+		}
+		out, closer, tty = f, f, false
+	}
+
+	switch cfg.Format {
+	case "", FormatJSON:
+		return slog.NewJSONHandler(out, opts), closer, nil
+	case FormatLogfmt:
+		return slog.NewTextHandler(out, opts), closer, nil
+	case FormatConsole:
+		var color bool
+		switch cfg.Color {
+		case "", "auto":
+			color = tty
+		case "always":
+			color = true
+		case "never":
+		default:
+			closer.Close()
+			return nil, nil, fmt.Errorf("%w: color %q", ErrInvalidLogConfig, cfg.Color)
+		}
+		return newConsoleHandler(out, opts, color), closer, nil
+	default:
+		closer.Close()
+		return nil, nil, fmt.Errorf("%w: format %q (want json, logfmt or console)", ErrInvalidLogConfig, cfg.Format)
+	}
+}
+
+func isTerminal(f *os.File) bool {
+	st, err := f.Stat()
+	return err == nil && st.Mode()&os.ModeCharDevice != 0
+}
+
+// RotatingFile is a log file rotated by size: past the limit it is renamed
+// to path.1 (older backups shift up, the oldest is removed) and reopened
+type RotatingFile struct {
+	path       string
+	maxSize    int64
+	maxBackups int
+
+	mu   sync.Mutex
+	f    *os.File
+	size int64
+}
+
+// NewRotatingFile opens path for appending; maxSizeMB and maxBackups
+// default to 100 and 5
+func NewRotatingFile(path string, maxSizeMB, maxBackups int) (*RotatingFile, error) {
+	if maxSizeMB == 0 {
+		maxSizeMB = 100
+	}
+	if maxBackups == 0 {
+		maxBackups = 5
+	}
+	r := &RotatingFile{path: path, maxSize: int64(maxSizeMB) << 20, maxBackups: maxBackups}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *RotatingFile) open() error {
+	f, err := os.OpenFile(r.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o640)
+	if err != nil {
+		return err
+	}
+	st, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	r.f, r.size = f, st.Size()
+	return nil
+}
+
+// Write appends p, rotating first if p would take the file past its limit.
+// A line is never split across files.
+func (r *RotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.f == nil {
+		return 0, os.ErrClosed
+	}
+	if r.size > 0 && r.size+int64(len(p)) > r.maxSize {
+		if err := r.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := r.f.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+func (r *RotatingFile) rotate() error {
+	if err := r.f.Close(); err != nil {
+		return err
+	}
+	r.f = nil
+	_ = os.Remove(fmt.Sprintf("%s.%d", r.path, r.maxBackups))
+	for i := r.maxBackups - 1; i >= 1; i-- {
+		_ = os.Rename(fmt.Sprintf("%s.%d", r.path, i), fmt.Sprintf("%s.%d", r.path, i+1))
+	}
+	if err := os.Rename(r.path, r.path+".1"); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return r.open()
+}
+
+// Close closes the file
+func (r *RotatingFile) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.f == nil {
+		return nil
+	}
+	err := r.f.Close()
+	r.f = nil
+	return err
+}
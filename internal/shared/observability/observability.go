@@ -2,13 +2,14 @@ package observability
 
 import (
 	"context"
+	"io"
 	"log/slog"
 	"net/http"
 	"os"
-	"strings"
 
 	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
 	"go.opentelemetry.io/otel/propagation"
 	"go.opentelemetry.io/otel/sdk/resource"
@@ -16,17 +17,46 @@ import (
 	semconv "go.opentelemetry.io/otel/semconv/v1.25.0"
 )
 
+// LogLevels are the levels of loggers created by NewLogger, read from
+// LOG_LEVEL (e.g. "info" or "info,resolver=debug"). Serve them with
+// LevelsHandler to change them without a restart.
+var LogLevels = NewLevels(slog.LevelInfo)
+
+// NewLogger creates the service logger, configured from the environment
+// (see LogConfigFromEnv); sensitive attributes are redacted, see RedactAttr.
+// An invalid log configuration falls back to JSON on stdout with a warning.
 func NewLogger(service string) *slog.Logger {
-	level := slog.LevelInfo
-	switch strings.ToLower(os.Getenv("LOG_LEVEL")) {
-	case "debug":
-		level = slog.LevelDebug
-	case "warn":
-		level = slog.LevelWarn
-	case "error":
-		level = slog.LevelError
-	}
-	return slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: level})).With("service", service)
+	cfg, cfgErr := LogConfigFromEnv()
+	var logger *slog.Logger
+	if cfgErr == nil {
+		// The file, if any, stays open for the life of the process
+		logger, _, cfgErr = NewLoggerConfig(service, cfg)
+	}
+	if cfgErr != nil {
+		logger, _, _ = NewLoggerConfig(service, LogConfig{})
+		logger.Warn("ignoring log output config", "error", cfgErr)
+	}
+	return logger
+}
+
+// NewLoggerConfig creates the service logger with an explicit output
+// config. LOG_LEVEL still sets LogLevels. Close the returned closer on
+// shutdown when logging to a file.
+func NewLoggerConfig(service string, cfg LogConfig) (*slog.Logger, io.Closer, error) {
+	handler, closer, err := NewLogHandler(cfg, &slog.HandlerOptions{Level: slog.LevelDebug, ReplaceAttr: RedactAttr})
+	if err != nil {
+		return nil, nil, err
+	}
+	invalid := LogLevels.Parse(os.Getenv("LOG_LEVEL"))
+	logger := slog.New(LogLevels.Handler(handler)).With("service", service)
+	if region := os.Getenv("GATEWAY_REGION"); region != "" {
+		logger = logger.With("region", region)
+	}
+	logger = logger.With(PodFromEnv().LogAttrs()...)
+	if invalid != nil {
+		logger.Warn("ignoring LOG_LEVEL", "error", invalid)
+	}
+	return logger, closer, nil
 }
 
 func SetupTracing(ctx context.Context, service string, otlpEndpoint string) (func(context.Context) error, error) {
@@ -39,9 +69,12 @@ func SetupTracing(ctx context.Context, service string, otlpEndpoint string) (fun
 	if err != nil {
 		return nil, err
 	}
-	res, err := resource.New(ctx, resource.WithAttributes(
-		semconv.ServiceName(service),
-	))
+	attrs := []attribute.KeyValue{semconv.ServiceName(service)}
+	if region := os.Getenv("GATEWAY_REGION"); region != "" {
+		attrs = append(attrs, semconv.CloudRegion(region))
+	}
+	attrs = append(attrs, PodFromEnv().ResourceAttributes()...)
+	res, err := resource.New(ctx, resource.WithAttributes(attrs...))
 	if err != nil {
 		return nil, err
 	}
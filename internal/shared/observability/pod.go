@@ -0,0 +1,106 @@
+package observability
+
+import (
+	"bufio"
+	"io"
+	"log/slog"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.25.0"
+)
+
+// DefaultPodLabelsFile is where the deployment mounts the pod's labels
+// with a downward API volume
+const DefaultPodLabelsFile = "/etc/podinfo/labels"
+
+// Pod is the Kubernetes metadata of this replica, passed in by the downward
+// API: POD_NAME, POD_NAMESPACE, POD_UID and NODE_NAME, and the labels file at
+// POD_LABELS_FILE (default DefaultPodLabelsFile). Outside Kubernetes it is
+// empty.
+type Pod struct {
+	Name      string
+	Namespace string
+	UID       string
+	Node      string
+	Labels    map[string]string
+}
+
+// PodFromEnv reads the pod metadata. A missing labels file leaves Labels
+// empty.
+func PodFromEnv() Pod {
+	p := Pod{
+		Name:      os.Getenv("POD_NAME"),
+		Namespace: os.Getenv("POD_NAMESPACE"),
+		UID:       os.Getenv("POD_UID"),
+		Node:      os.Getenv("NODE_NAME"),
+	}
+	path := os.Getenv("POD_LABELS_FILE")
+	if path == "" {
+		path = DefaultPodLabelsFile
+	}
+	if f, err := os.Open(path); err == nil {
+		p.Labels = parsePodLabels(f)
+		f.Close()
+	}
+	return p
+}
+
+// parsePodLabels parses the downward API format, one key="quoted value" per
+// line
+func parsePodLabels(r io.Reader) map[string]string {
+	labels := make(map[string]string)
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		key, quoted, ok := strings.Cut(sc.Text(), "=")
+		if !ok {
+			continue
+		}
+		if v, err := strconv.Unquote(quoted); err == nil {
+			labels[key] = v
+		}
+	}
+	return labels
+}
+
+// LogAttrs returns the pod as a "k8s" log group; labels are left to traces
+// to keep log lines short
+func (p Pod) LogAttrs() []any {
+	if p.Name == "" {
+		return nil
+	}
+	attrs := []any{slog.String("pod", p.Name)}
+	if p.Namespace != "" {
+		attrs = append(attrs, slog.String("namespace", p.Namespace))
+	}
+	if p.Node != "" {
+		attrs = append(attrs, slog.String("node", p.Node))
+	}
+	return []any{slog.Group("k8s", attrs...)}
+}
+
+// ResourceAttributes returns the pod as OpenTelemetry resource attributes,
+// labels as k8s.pod.label.<key>
+func (p Pod) ResourceAttributes() []attribute.KeyValue {
+	var attrs []attribute.KeyValue
+	for _, kv := range []struct {
+		val string
+		fn  func(string) attribute.KeyValue
+	}{{p.Name, semconv.K8SPodName}, {p.Namespace, semconv.K8SNamespaceName}, {p.UID, semconv.K8SPodUID}, {p.Node, semconv.K8SNodeName}} {
+		if kv.val != "" {
+			attrs = append(attrs, kv.fn(kv.val))
+		}
+	}
+	keys := make([]string, 0, len(p.Labels))
+	for k := range p.Labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		attrs = append(attrs, attribute.String("k8s.pod.label."+k, p.Labels[k]))
+	}
+	return attrs
+}
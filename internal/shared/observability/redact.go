@@ -0,0 +1,95 @@
+package observability
+
+import (
+	"log/slog"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Redacted replaces secret values in logs
+const Redacted = "[REDACTED]"
+
+// sensitiveKeys are attribute and query parameter names whose values are
+// never logged, matched case-insensitively on the whole name or its last
+// "_", "-" or "." separated part (so "access_token" and "x.signature" match)
+var sensitiveKeys = map[string]bool{
+	"authorization": true,
+	"token":         true,
+	"secret":        true,
+	"password":      true,
+	"signature":     true,
+	"sig":           true,
+	"credential":    true,
+	"credentials":   true,
+	"vc":            true,
+	"vp":            true,
+	"jwt":           true,
+	"proof":         true,
+	"verifier":      true, // PKCE code_verifier
+	"cookie":        true,
+}
+
+// sensitiveNames only match whole names; as suffixes they'd catch fields
+// like status_code or cache_key
+var sensitiveNames = map[string]bool{
+	"code":                true, // OAuth authorization code
+	"pre-authorized_code": true,
+	"tx_code":             true,
+	"api_key":             true,
+	"x-api-key":           true,
+	"private_key":         true,
+}
+
+// jwtLike matches compact JWS/JWT values (header.payload.signature), which
+// carry credentials wherever they turn up
+var jwtLike = regexp.MustCompile(`eyJ[A-Za-z0-9_-]{4,}\.[A-Za-z0-9_-]{4,}\.[A-Za-z0-9_-]*`)
+
+// Sensitive reports whether values under key must be redacted
+func Sensitive(key string) bool {
+	key = strings.ToLower(key)
+	if sensitiveKeys[key] || sensitiveNames[key] {
+		return true
+	}
+	if i := strings.LastIndexAny(key, "._-"); i >= 0 {
+		return sensitiveKeys[key[i+1:]]
+	}
+	return false
+}
+
+// RedactAttr is a slog.HandlerOptions.ReplaceAttr that blanks sensitive
+// attributes and any JWT embedded in a string value
+func RedactAttr(_ []string, a slog.Attr) slog.Attr {
+	if Sensitive(a.Key) {
+		return slog.String(a.Key, Redacted)
+	}
+	if a.Value.Kind() == slog.KindString {
+		if s := a.Value.String(); strings.Contains(s, "eyJ") {
+			return slog.String(a.Key, jwtLike.ReplaceAllString(s, Redacted))
+		}
+	}
+	return a
+}
+
+// RedactQuery returns a raw query with sensitive parameter values replaced,
+// keeping parameter order and encoding otherwise
+func RedactQuery(raw string) string {
+	if raw == "" {
+		return ""
+	}
+	parts := strings.Split(raw, "&")
+	for i, part := range parts {
+		k, v, _ := strings.Cut(part, "=")
+		name, err := url.QueryUnescape(k)
+		if err != nil {
+			name = k
+		}
+		switch {
+		case Sensitive(name):
+			parts[i] = k + "=" + Redacted
+		case strings.Contains(v, "eyJ"):
+			parts[i] = k + "=" + jwtLike.ReplaceAllString(v, Redacted)
+		}
+	}
+	return strings.Join(parts, "&")
+}
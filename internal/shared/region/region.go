@@ -0,0 +1,170 @@
+package region
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	ErrReplayed      = errors.New("already used")
+	ErrForeignRegion = errors.New("issued in another region")
+	ErrInvalidRegion = errors.New("invalid region name")
+)
+
+// Cross-region policies for single-use values issued in another region
+const (
+	// CrossRegionAccept claims the value locally. A replay in both regions
+	// within the replication lag goes unnoticed; that window is the bounded
+	// risk accepted for active-active.
+	CrossRegionAccept = "accept"
+	// CrossRegionReject only accepts values issued in this region; clients
+	// pinned to one region by geo-DNS never notice.
+	CrossRegionReject = "reject"
+)
+
+var nameRegex = regexp.MustCompile(`^[a-z0-9-]{1,32}$`)
+
+// Config describes this gateway's region in a multi-region deployment
+type Config struct {
+	Name           string        // This region, e.g. "eu-west-1" (empty = single region)
+	Peers          []string      // The other active regions
+	CrossRegion    string        // CrossRegionAccept (default) or CrossRegionReject
+	ReplicationLag time.Duration // Expected Redis replication lag between regions (default 2s)
+}
+
+// Guard makes nonces, jtis and other single-use values safe across regions
+// whose Redis replicates asynchronously. Values are tagged with the region
+// that issued them (Tag); a value claimed in its own region is checked
+// against a strongly consistent local Redis, while one issued elsewhere is
+// handled per CrossRegion. Claims outlive the value's TTL by the replication
+// lag, so a replicated claim is still there when a late replay arrives.
+type Guard struct {
+	client *redis.Client
+	cfg    Config
+	peers  map[string]bool
+
+	mu     sync.Mutex
+	claims map[string]*counts // By origin region
+}
+
+type counts struct {
+	accepted atomic.Int64
+	replayed atomic.Int64
+	rejected atomic.Int64
+}
+
+// ClaimStats counts claims by the region that issued the value
+type ClaimStats struct {
+	Accepted int64 `json:"accepted"`
+	Replayed int64 `json:"replayed"`
+	Rejected int64 `json:"rejected"`
+}
+
+// NewGuard creates a guard for this region
+func NewGuard(client *redis.Client, cfg Config) (*Guard, error) {
+	if cfg.CrossRegion == "" {
+		cfg.CrossRegion = CrossRegionAccept
+	}
+	if cfg.CrossRegion != CrossRegionAccept && cfg.CrossRegion != CrossRegionReject {
+		return nil, errors.New("cross-region policy must be accept or reject")
+	}
+	if cfg.ReplicationLag == 0 {
+		cfg.ReplicationLag = 2 * time.Second
+	}
+	g := &Guard{client: client, cfg: cfg, peers: make(map[string]bool), claims: make(map[string]*counts)}
+	if cfg.Name == "" && len(cfg.Peers) > 0 {
+		return nil, fmt.Errorf("%w: peers need a local region name", ErrInvalidRegion)
+	}
+	if cfg.Name == "" {
+		return g, nil
+	}
+	for _, name := range append([]string{cfg.Name}, cfg.Peers...) {
+		if !nameRegex.MatchString(name) {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidRegion, name)
+		}
+		g.peers[name] = true
+	}
+	return g, nil
+}
+
+// Name returns this region, empty in a single-region deployment
+func (g *Guard) Name() string {
+	return g.cfg.Name
+}
+
+// Tag prefixes a single-use value with this region ("eu-west-1.<value>")
+func (g *Guard) Tag(value string) string {
+	if g.cfg.Name == "" {
+		return value
+	}
+	return g.cfg.Name + "." + value
+}
+
+// Origin returns the region a tagged value was issued in, or "" for values
+// without a known region tag
+func (g *Guard) Origin(value string) string {
+	name, _, ok := strings.Cut(value, ".")
+	if !ok || !g.peers[name] {
+		return ""
+	}
+	return name
+}
+
+// Claim marks value as used under key for ttl. It returns ErrReplayed when
+// the value was claimed before, and ErrForeignRegion when it was issued in
+// another region and the policy rejects those.
+func (g *Guard) Claim(ctx context.Context, key, value string, ttl time.Duration) error {
+	origin := g.Origin(value)
+	c := g.counts(origin)
+	if origin != "" && origin != g.cfg.Name && g.cfg.CrossRegion == CrossRegionReject {
+		c.rejected.Add(1)
+		return fmt.Errorf("%w: %s", ErrForeignRegion, origin)
+	}
+	if len(g.peers) > 1 {
+		ttl += g.cfg.ReplicationLag
+	}
+	fresh, err := g.client.SetNX(ctx, key, g.cfg.Name, ttl).Result()
+	if err != nil {
+		return err
+	}
+	if !fresh {
+		c.replayed.Add(1)
+		return ErrReplayed
+	}
+	c.accepted.Add(1)
+	return nil
+}
+
+func (g *Guard) counts(origin string) *counts {
+	if origin == "" {
+		origin = "untagged"
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	c, ok := g.claims[origin]
+	if !ok {
+		c = &counts{}
+		g.claims[origin] = c
+	}
+	return c
+}
+
+// Stats returns claim counts by origin region, for metrics labelled with the
+// local and origin region
+func (g *Guard) Stats() map[string]ClaimStats {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	out := make(map[string]ClaimStats, len(g.claims))
+	for origin, c := range g.claims {
+		out[origin] = ClaimStats{Accepted: c.accepted.Load(), Replayed: c.replayed.Load(), Rejected: c.rejected.Load()}
+	}
+	return out
+}
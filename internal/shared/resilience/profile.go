@@ -0,0 +1,115 @@
+package resilience
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/example/privacy-gateway/internal/shared/circuitbreaker"
+	"github.com/example/privacy-gateway/internal/shared/retry"
+)
+
+// Profile names
+const (
+	Aggressive   = "aggressive"   // Fail fast: short timeouts, no retries, quick to open
+	Balanced     = "balanced"     // The default, and the did:web settings
+	Conservative = "conservative" // Patient: long timeouts, more retries, slow to open
+)
+
+var ErrUnknownProfile = errors.New("unknown resilience profile")
+
+// Profile bundles the timeout, retry and circuit breaker settings for one
+// kind of dependency, so a target is tuned by picking a name rather than a
+// dozen numbers
+type Profile struct {
+	Name    string
+	Timeout time.Duration         // Per attempt
+	Retry   retry.Config          // MaxAttempts <= 1 disables retries
+	Breaker circuitbreaker.Config // Timeout is left 0 and derived, see CallTimeout
+}
+
+var profiles = map[string]Profile{
+	Aggressive: {
+		Name:    Aggressive,
+		Timeout: time.Second,
+		Retry:   retry.Config{MaxAttempts: 1},
+		Breaker: circuitbreaker.Config{
+			MaxFailures:      3,
+			ResetTimeout:     10 * time.Second,
+			MaxConcurrent:    64,
+			HalfOpenMaxCalls: 1,
+			SuccessThreshold: 1,
+		},
+	},
+	Balanced: {
+		Name:    Balanced,
+		Timeout: 5 * time.Second,
+		Retry: retry.Config{
+			MaxAttempts:  2,
+			InitialDelay: 200 * time.Millisecond,
+			MaxDelay:     time.Second,
+			Multiplier:   2,
+			Jitter:       true,
+		},
+		Breaker: circuitbreaker.Config{
+			MaxFailures:      5,
+			ResetTimeout:     30 * time.Second,
+			HalfOpenMaxCalls: 1,
+			SuccessThreshold: 3,
+		},
+	},
+	Conservative: {
+		Name:    Conservative,
+		Timeout: 10 * time.Second,
+		Retry: retry.Config{
+			MaxAttempts:  3,
+			InitialDelay: 500 * time.Millisecond,
+			MaxDelay:     5 * time.Second,
+			Multiplier:   2,
+			Jitter:       true,
+		},
+		Breaker: circuitbreaker.Config{
+			MaxFailures:      10,
+			ResetTimeout:     60 * time.Second,
+			HalfOpenMaxCalls: 2,
+			SuccessThreshold: 5,
+		},
+	},
+}
+
+// Lookup returns the named profile; an empty name is Balanced
+func Lookup(name string) (Profile, error) {
+	if name == "" {
+		name = Balanced
+	}
+	p, ok := profiles[name]
+	if !ok {
+		return Profile{}, fmt.Errorf("%w: %q (want one of %v)", ErrUnknownProfile, name, Names())
+	}
+	return p, nil
+}
+
+// Names lists the profile names
+func Names() []string {
+	names := make([]string, 0, len(profiles))
+	for name := range profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// CallTimeout bounds a whole call: every attempt plus the backoff between
+// them. Breakers use it as their timeout.
+func (p Profile) CallTimeout() time.Duration {
+	attempts := time.Duration(max(1, p.Retry.MaxAttempts))
+	return attempts*p.Timeout + (attempts-1)*p.Retry.MaxDelay
+}
+
+// NewBreaker creates a circuit breaker with the profile's settings
+func (p Profile) NewBreaker() *circuitbreaker.CircuitBreaker {
+	cfg := p.Breaker
+	cfg.Timeout = p.CallTimeout()
+	return circuitbreaker.New(cfg)
+}
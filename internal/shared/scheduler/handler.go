@@ -0,0 +1,46 @@
+package scheduler
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/example/privacy-gateway/internal/shared/httpx"
+)
+
+// Handler serves the admin job endpoints:
+//
+//	GET  /admin/v1/jobs
+//	GET  /admin/v1/jobs/{name}
+//	POST /admin/v1/jobs/{name}/run
+func (s *Scheduler) Handler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rest := strings.Trim(strings.TrimPrefix(r.URL.Path, "/admin/v1/jobs"), "/")
+		name, action, _ := strings.Cut(rest, "/")
+
+		switch {
+		case name == "" && r.Method == http.MethodGet:
+			httpx.WriteJSON(w, http.StatusOK, s.Status())
+		case name != "" && action == "" && r.Method == http.MethodGet:
+			st, err := s.JobStatus(name)
+			if err != nil {
+				httpx.WriteJSON(w, http.StatusNotFound, httpx.ErrorResponse{Error: "job not found"})
+				return
+			}
+			httpx.WriteJSON(w, http.StatusOK, st)
+		case name != "" && action == "run" && r.Method == http.MethodPost:
+			err := s.Trigger(r.Context(), name)
+			switch {
+			case errors.Is(err, ErrUnknownJob):
+				httpx.WriteJSON(w, http.StatusNotFound, httpx.ErrorResponse{Error: "job not found"})
+			case errors.Is(err, ErrJobRunning):
+				httpx.WriteJSON(w, http.StatusConflict, httpx.ErrorResponse{Error: err.Error()})
+			default:
+				st, _ := s.JobStatus(name)
+				httpx.WriteJSON(w, http.StatusAccepted, st)
+			}
+		default:
+			httpx.WriteJSON(w, http.StatusNotFound, httpx.ErrorResponse{Error: "not found"})
+		}
+	}
+}
@@ -0,0 +1,258 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/example/privacy-gateway/internal/shared/observability"
+)
+
+var (
+	ErrDuplicateJob = errors.New("job already registered")
+	ErrUnknownJob   = errors.New("unknown job")
+	ErrJobRunning   = errors.New("job is already running")
+)
+
+// Job is a scheduled background task
+type Job struct {
+	Name     string
+	Schedule string        // See ParseSchedule
+	Jitter   time.Duration // Random delay added to each scheduled run, to spread load
+	Timeout  time.Duration // Per-run deadline (0 = none)
+	Run      func(ctx context.Context) error
+}
+
+// Outcomes of a run
+const (
+	OutcomeSuccess = "success"
+	OutcomeFailure = "failure"
+)
+
+// Status is a job's schedule and last run
+type Status struct {
+	Name         string     `json:"name"`
+	Schedule     string     `json:"schedule"`
+	Running      bool       `json:"running"`
+	NextRun      *time.Time `json:"next_run,omitempty"`
+	LastStart    *time.Time `json:"last_start,omitempty"`
+	LastDuration string     `json:"last_duration,omitempty"`
+	LastOutcome  string     `json:"last_outcome,omitempty"`
+	LastError    string     `json:"last_error,omitempty"`
+	LastTrigger  string     `json:"last_trigger,omitempty"` // "schedule" or "manual"
+	Runs         int64      `json:"runs"`
+	Failures     int64      `json:"failures"`
+	Skipped      int64      `json:"skipped"` // Runs skipped because the previous one hadn't finished
+}
+
+// Config configures a scheduler
+type Config struct {
+	Location *time.Location // Time zone of cron expressions (default UTC)
+	Logger   *slog.Logger
+}
+
+// Scheduler runs registered jobs on their schedules. A job never overlaps
+// itself: a run due while the previous one (or a manual trigger) is still
+// going is skipped and counted. Run the scheduler under a leader.Elector
+// when its jobs must run on one replica only.
+type Scheduler struct {
+	cfg Config
+
+	mu   sync.Mutex
+	jobs map[string]*entry
+}
+
+type entry struct {
+	job      Job
+	schedule Schedule
+	running  atomic.Bool
+
+	mu     sync.Mutex
+	status Status
+}
+
+// New creates a scheduler
+func New(cfg Config) *Scheduler {
+	if cfg.Location == nil {
+		cfg.Location = time.UTC
+	}
+	cfg.Logger = observability.Component(cfg.Logger, observability.ComponentJobs)
+	return &Scheduler{cfg: cfg, jobs: make(map[string]*entry)}
+}
+
+// Add registers a job; call before Run
+func (s *Scheduler) Add(job Job) error {
+	if job.Name == "" || job.Run == nil {
+		return errors.New("job needs a name and a run function")
+	}
+	sched, err := ParseSchedule(job.Schedule, s.cfg.Location)
+	if err != nil {
+		return fmt.Errorf("job %s: %w", job.Name, err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.jobs[job.Name]; ok {
+		return fmt.Errorf("%w: %s", ErrDuplicateJob, job.Name)
+	}
+	s.jobs[job.Name] = &entry{job: job, schedule: sched, status: Status{Name: job.Name, Schedule: job.Schedule}}
+	return nil
+}
+
+// Run runs every job on its schedule until ctx is cancelled, then waits for
+// running jobs to return
+func (s *Scheduler) Run(ctx context.Context) {
+	s.mu.Lock()
+	entries := make([]*entry, 0, len(s.jobs))
+	for _, e := range s.jobs {
+		entries = append(entries, e)
+	}
+	s.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for _, e := range entries {
+		wg.Add(1)
+		go func(e *entry) {
+			defer wg.Done()
+			s.loop(ctx, e)
+		}(e)
+	}
+	wg.Wait()
+}
+
+func (s *Scheduler) loop(ctx context.Context, e *entry) {
+	for {
+		next := e.schedule.Next(time.Now())
+		if next.IsZero() {
+			s.cfg.Logger.Warn("job schedule never fires", "job", e.job.Name, "schedule", e.job.Schedule)
+			return
+		}
+		e.mu.Lock()
+		e.status.NextRun = &next
+		e.mu.Unlock()
+
+		delay := time.Until(next)
+		if e.job.Jitter > 0 {
+			delay += time.Duration(rand.Int63n(int64(e.job.Jitter)))
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		// Runs inline, so fire times missed while it runs are skipped
+		s.execute(ctx, e, "schedule")
+	}
+}
+
+// Trigger starts a job now, outside its schedule. It returns ErrJobRunning
+// if the job is running.
+func (s *Scheduler) Trigger(ctx context.Context, name string) error {
+	s.mu.Lock()
+	e, ok := s.jobs[name]
+	s.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownJob, name)
+	}
+	if !e.running.CompareAndSwap(false, true) {
+		return ErrJobRunning
+	}
+	go s.run(context.WithoutCancel(ctx), e, "manual")
+	return nil
+}
+
+func (s *Scheduler) execute(ctx context.Context, e *entry, trigger string) {
+	if !e.running.CompareAndSwap(false, true) {
+		e.mu.Lock()
+		e.status.Skipped++
+		e.mu.Unlock()
+		s.cfg.Logger.Warn("job still running, skipping run", "job", e.job.Name)
+		return
+	}
+	s.run(ctx, e, trigger)
+}
+
+// run executes a job the caller has marked running
+func (s *Scheduler) run(ctx context.Context, e *entry, trigger string) {
+	defer e.running.Store(false)
+	if e.job.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, e.job.Timeout)
+		defer cancel()
+	}
+	start := time.Now()
+	e.mu.Lock()
+	e.status.LastStart, e.status.LastTrigger = &start, trigger
+	e.mu.Unlock()
+
+	err := safeRun(ctx, e.job.Run)
+	elapsed := time.Since(start)
+
+	e.mu.Lock()
+	e.status.Runs++
+	e.status.LastDuration = elapsed.String()
+	e.status.LastOutcome, e.status.LastError = OutcomeSuccess, ""
+	if err != nil {
+		e.status.Failures++
+		e.status.LastOutcome, e.status.LastError = OutcomeFailure, err.Error()
+	}
+	e.mu.Unlock()
+
+	if err != nil {
+		s.cfg.Logger.Error("job failed", "job", e.job.Name, "trigger", trigger, "duration", elapsed, "error", err)
+		return
+	}
+	s.cfg.Logger.Debug("job finished", "job", e.job.Name, "trigger", trigger, "duration", elapsed)
+}
+
+// safeRun turns a panicking job into a failed run
+func safeRun(ctx context.Context, fn func(context.Context) error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return fn(ctx)
+}
+
+// Status returns every job's status, sorted by name
+func (s *Scheduler) Status() []Status {
+	s.mu.Lock()
+	entries := make([]*entry, 0, len(s.jobs))
+	for _, e := range s.jobs {
+		entries = append(entries, e)
+	}
+	s.mu.Unlock()
+	out := make([]Status, 0, len(entries))
+	for _, e := range entries {
+		out = append(out, e.snapshot())
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// JobStatus returns one job's status
+func (s *Scheduler) JobStatus(name string) (Status, error) {
+	s.mu.Lock()
+	e, ok := s.jobs[name]
+	s.mu.Unlock()
+	if !ok {
+		return Status{}, fmt.Errorf("%w: %s", ErrUnknownJob, name)
+	}
+	return e.snapshot(), nil
+}
+
+func (e *entry) snapshot() Status {
+	e.mu.Lock()
+	st := e.status
+	e.mu.Unlock()
+	st.Running = e.running.Load()
+	return st
+}
@@ -0,0 +1,82 @@
+package tenant
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Request outcomes
+const (
+	OutcomeAllowed   = "allowed"
+	OutcomeDenied    = "denied"
+	OutcomeThrottled = "throttled" // Rate limit or quota
+	OutcomeError     = "error"     // 5xx
+)
+
+// Outcome classifies a response status
+func Outcome(status int) string {
+	switch {
+	case status == http.StatusUnauthorized || status == http.StatusForbidden:
+		return OutcomeDenied
+	case status == http.StatusTooManyRequests:
+		return OutcomeThrottled
+	case status >= 500:
+		return OutcomeError
+	}
+	return OutcomeAllowed
+}
+
+// Metrics are per-tenant Prometheus metrics. Every tenant label goes
+// through the Labeler, so label cardinality is bounded by MaxTenants.
+type Metrics struct {
+	labeler *Labeler
+
+	requests    *prometheus.CounterVec
+	denials     *prometheus.CounterVec
+	rateLimited *prometheus.CounterVec
+	bytes       *prometheus.CounterVec
+}
+
+// NewMetrics creates and registers the tenant metrics
+func NewMetrics(reg prometheus.Registerer, labeler *Labeler) *Metrics {
+	m := &Metrics{
+		labeler: labeler,
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "gateway_tenant_requests_total",
+			Help: "Requests by tenant (credential issuer) and outcome.",
+		}, []string{"tenant", "outcome"}),
+		denials: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "gateway_tenant_denials_total",
+			Help: "Policy denials by tenant and reason.",
+		}, []string{"tenant", "reason"}),
+		rateLimited: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "gateway_tenant_rate_limited_total",
+			Help: "Requests rejected by rate limits, by tenant and policy.",
+		}, []string{"tenant", "policy"}),
+		bytes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "gateway_tenant_bytes_total",
+			Help: "Request and response body bytes by tenant.",
+		}, []string{"tenant", "direction"}),
+	}
+	reg.MustRegister(m.requests, m.denials, m.rateLimited, m.bytes)
+	return m
+}
+
+// Request counts a finished request
+func (m *Metrics) Request(tenant, outcome string, bytesIn, bytesOut int64) {
+	label := m.labeler.Label(tenant)
+	m.requests.WithLabelValues(label, outcome).Inc()
+	m.bytes.WithLabelValues(label, "in").Add(float64(bytesIn))
+	m.bytes.WithLabelValues(label, "out").Add(float64(bytesOut))
+}
+
+// Denied counts a policy denial
+func (m *Metrics) Denied(tenant, reason string) {
+	m.denials.WithLabelValues(m.labeler.Label(tenant), reason).Inc()
+}
+
+// RateLimited counts a rate-limited request
+func (m *Metrics) RateLimited(tenant, policyID string) {
+	m.rateLimited.WithLabelValues(m.labeler.Label(tenant), policyID).Inc()
+}
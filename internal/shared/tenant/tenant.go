@@ -0,0 +1,123 @@
+package tenant
+
+import (
+	"context"
+	"log/slog"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"github.com/example/privacy-gateway/internal/shared/models"
+	"github.com/example/privacy-gateway/internal/shared/observability"
+)
+
+// Labels for requests without a known tenant
+const (
+	LabelNone  = "none"  // No tenant (anonymous, API key or admin traffic)
+	LabelOther = "other" // A tenant beyond the label budget
+)
+
+type ctxKey struct{}
+
+// WithTenant records the tenant of a request: the issuer DID of the
+// credential it presented. The auth middleware sets it once the credential
+// is verified; audit events and metering records pick it up from there.
+func WithTenant(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, ctxKey{}, tenant)
+}
+
+// FromContext returns the request's tenant, or ""
+func FromContext(ctx context.Context) string {
+	t, _ := ctx.Value(ctxKey{}).(string)
+	return t
+}
+
+// IssuerLister lists registered issuers; store.Postgres implements it
+type IssuerLister interface {
+	ListIssuers(ctx context.Context) ([]models.Issuer, error)
+}
+
+// Config configures tenant labels
+type Config struct {
+	Issuers IssuerLister
+	// MaxTenants caps distinct metric label values (default 200); enabled
+	// issuers beyond it, in DID order, share LabelOther
+	MaxTenants int
+	Refresh    time.Duration // How often the issuer registry is reloaded (default 1m)
+	Logger     *slog.Logger
+}
+
+// Labeler maps tenants to metric label values. Only registered, enabled
+// issuers get their own label, and at most MaxTenants of them, so a flood
+// of unknown issuers can't blow up metric cardinality.
+type Labeler struct {
+	cfg    Config
+	labels atomic.Pointer[map[string]bool]
+}
+
+// NewLabeler creates a labeler; call Refresh or Run to load the registry
+func NewLabeler(cfg Config) *Labeler {
+	if cfg.MaxTenants == 0 {
+		cfg.MaxTenants = 200
+	}
+	if cfg.Refresh == 0 {
+		cfg.Refresh = time.Minute
+	}
+	cfg.Logger = observability.Component(cfg.Logger, observability.ComponentMetering)
+	l := &Labeler{cfg: cfg}
+	l.labels.Store(&map[string]bool{})
+	return l
+}
+
+// Label returns the metric label value for a tenant
+func (l *Labeler) Label(tenant string) string {
+	if tenant == "" {
+		return LabelNone
+	}
+	if (*l.labels.Load())[tenant] {
+		return tenant
+	}
+	return LabelOther
+}
+
+// Refresh reloads the labelled tenants from the issuer registry
+func (l *Labeler) Refresh(ctx context.Context) error {
+	issuers, err := l.cfg.Issuers.ListIssuers(ctx)
+	if err != nil {
+		return err
+	}
+	dids := make([]string, 0, len(issuers))
+	for _, iss := range issuers {
+		if iss.Enabled {
+			dids = append(dids, iss.DID)
+		}
+	}
+	sort.Strings(dids)
+	if len(dids) > l.cfg.MaxTenants {
+		l.cfg.Logger.Warn("more tenants than metric labels; the rest are labelled other",
+			"tenants", len(dids), "max", l.cfg.MaxTenants)
+		dids = dids[:l.cfg.MaxTenants]
+	}
+	labels := make(map[string]bool, len(dids))
+	for _, did := range dids {
+		labels[did] = true
+	}
+	l.labels.Store(&labels)
+	return nil
+}
+
+// Run refreshes the labels until ctx is cancelled
+func (l *Labeler) Run(ctx context.Context) {
+	ticker := time.NewTicker(l.cfg.Refresh)
+	defer ticker.Stop()
+	for {
+		if err := l.Refresh(ctx); err != nil && ctx.Err() == nil {
+			l.cfg.Logger.Warn("failed to refresh tenant labels", "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
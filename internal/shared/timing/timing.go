@@ -0,0 +1,27 @@
+package timing
+
+import (
+	"context"
+	"time"
+)
+
+// Floor is the minimum duration of an operation whose outcome must not be
+// observable from its latency, e.g. a failed login: "unknown DID", "DID
+// did not resolve" and "bad signature" all take at least the floor. It only
+// hides differences below the floor, so set it above the slowest failure
+// path the caller cares about.
+type Floor time.Duration
+
+// Wait blocks until the floor has passed since start or ctx is done
+func (f Floor) Wait(ctx context.Context, start time.Time) {
+	d := time.Duration(f) - time.Since(start)
+	if d <= 0 {
+		return
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+	case <-ctx.Done():
+	}
+}
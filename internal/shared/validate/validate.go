@@ -1,11 +1,21 @@
 package validate
 
 import (
+	"encoding/asn1"
+	"encoding/base64"
 	"errors"
 	"fmt"
+	"math/big"
+	"net"
+	"net/url"
 	"regexp"
 	"strings"
 	"time"
+
+	"golang.org/x/net/idna"
+
+	"github.com/example/privacy-gateway/internal/shared/challenge"
+	"github.com/example/privacy-gateway/internal/shared/models"
 )
 
 var (
@@ -13,6 +23,7 @@ var (
 	ErrInvalidDIDMethod = errors.New("unsupported DID method")
 	ErrInvalidSignature = errors.New("invalid signature format")
 	ErrInvalidScopes    = errors.New("invalid scopes")
+	ErrInvalidIssuer    = errors.New("invalid issuer")
 )
 
 // Supported DID methods
@@ -22,11 +33,10 @@ var supportedDIDMethods = map[string]bool{
 	"ion": true,
 }
 
-// DID format: did:<method>:<method-specific-id>
-var didRegex = regexp.MustCompile(`^did:([a-z0-9]+):([a-zA-Z0-9._%-]+)$`)
-
-// Base64URL pattern (for signatures)
-var base64URLRegex = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+// DID format: did:<method>:<method-specific-id>, per the DID Core ABNF.
+// Percent signs must start a pct-encoded octet; only the last segment of the
+// method-specific ID has to be non-empty.
+var didRegex = regexp.MustCompile(`^did:[a-z0-9]+:(?:(?:[a-zA-Z0-9._-]|%[0-9A-Fa-f]{2})*:)*(?:[a-zA-Z0-9._-]|%[0-9A-Fa-f]{2})+$`)
 
 // ValidateDID validates a DID string
 func ValidateDID(did string) error {
@@ -34,12 +44,12 @@ func ValidateDID(did string) error {
 		return ErrInvalidDID
 	}
 
-	matches := didRegex.FindStringSubmatch(did)
-	if matches == nil {
+	// MatchString avoids the submatch slice; method and ID are split by hand
+	if !didRegex.MatchString(did) {
 		return ErrInvalidDID
 	}
 
-	method := matches[1]
+	method, methodSpecificID, _ := strings.Cut(did[len("did:"):], ":")
 	if !supportedDIDMethods[method] {
 		return fmt.Errorf("%w: %s", ErrInvalidDIDMethod, method)
 	}
@@ -48,52 +58,153 @@ func ValidateDID(did string) error {
 	switch method {
 	case "key":
 		// did:key uses multibase encoding (starts with 'z' for base58btc)
-		methodSpecificID := matches[2]
 		if !strings.HasPrefix(methodSpecificID, "z") {
 			return fmt.Errorf("%w: did:key must start with 'z'", ErrInvalidDID)
 		}
 	case "web":
-		// did:web uses domain names (optionally with path)
-		methodSpecificID := matches[2]
+		// did:web uses domain names (optionally with port and path)
 		if len(methodSpecificID) < 3 {
 			return fmt.Errorf("%w: did:web domain too short", ErrInvalidDID)
 		}
+		if strings.HasPrefix(methodSpecificID, ":") || strings.Contains(methodSpecificID, "::") {
+			return fmt.Errorf("%w: empty did:web path segment", ErrInvalidDID)
+		}
+		if err := validateWebDomain(methodSpecificID); err != nil {
+			return err
+		}
 	}
 
 	return nil
 }
 
-// ValidateSignature validates a base64url-encoded signature
+// Signature algorithms (JOSE names)
+const (
+	AlgEd25519   = "EdDSA"
+	AlgES256     = "ES256"
+	AlgES256K    = "ES256K"
+	AlgES256KRec = "ES256K-R"
+)
+
+// validateWebDomain checks the domain of a did:web method-specific ID. IDN
+// domains may be punycode or percent-encoded UTF-8; both must map to a valid
+// IDNA lookup name.
+func validateWebDomain(id string) error {
+	domain, _, _ := strings.Cut(id, ":")
+	host, err := url.PathUnescape(domain)
+	if err != nil {
+		return fmt.Errorf("%w: bad did:web domain encoding", ErrInvalidDID)
+	}
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	if _, err := idna.Lookup.ToASCII(host); err != nil {
+		return fmt.Errorf("%w: invalid did:web domain", ErrInvalidDID)
+	}
+	return nil
+}
+
+// ValidateSignature validates a base64url-encoded Ed25519 signature
 func ValidateSignature(signature string) error {
-	if signature == "" {
+	return ValidateSignatureAlg(signature, AlgEd25519)
+}
+
+// ValidateSignatureAlg decodes a base64url signature and checks its byte
+// length for alg:
+//
+//	EdDSA      64 bytes
+//	ES256      64 bytes raw (r||s) or an ASN.1 DER sequence
+//	ES256K     64 bytes raw
+//	ES256K-R   65 bytes (r||s||recovery id)
+func ValidateSignatureAlg(signature, alg string) error {
+	if signature == "" || !isBase64URL(signature) {
 		return ErrInvalidSignature
 	}
+	// Every supported encoding fits in 72 bytes (DER ES256); decode into a
+	// stack buffer so validation doesn't allocate
+	var buf [72]byte
+	if base64.RawURLEncoding.DecodedLen(len(signature)) > len(buf) {
+		return fmt.Errorf("%w: signature too long", ErrInvalidSignature)
+	}
+	n, err := base64.RawURLEncoding.Decode(buf[:], []byte(signature))
+	if err != nil {
+		return fmt.Errorf("%w: not base64url", ErrInvalidSignature)
+	}
+	raw := buf[:n]
 
-	if !base64URLRegex.MatchString(signature) {
-		return ErrInvalidSignature
+	switch alg {
+	case AlgEd25519, AlgES256K:
+		if len(raw) != 64 {
+			return fmt.Errorf("%w: %s signature must be 64 bytes, got %d", ErrInvalidSignature, alg, len(raw))
+		}
+	case AlgES256:
+		if len(raw) == 64 {
+			return nil
+		}
+		// asn1 retains its input, so hand it a copy to keep buf on the stack
+		if !isDERSignature(append([]byte(nil), raw...), 32) {
+			return fmt.Errorf("%w: ES256 signature must be 64 bytes raw or DER", ErrInvalidSignature)
+		}
+	case AlgES256KRec:
+		if len(raw) != 65 {
+			return fmt.Errorf("%w: %s signature must be 65 bytes, got %d", ErrInvalidSignature, alg, len(raw))
+		}
+		// Accept both 0/1 and Ethereum-style 27/28 recovery ids
+		if v := raw[64]; v > 1 && v != 27 && v != 28 {
+			return fmt.Errorf("%w: invalid recovery id", ErrInvalidSignature)
+		}
+	default:
+		return fmt.Errorf("%w: unsupported algorithm %q", ErrInvalidSignature, alg)
+	}
+	return nil
+}
+
+// isBase64URL reports whether s uses only the unpadded base64url alphabet
+func isBase64URL(s string) bool {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if !('A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || c == '-' || c == '_') {
+			return false
+		}
 	}
+	return true
+}
 
-	// Ed25519 signatures are 64 bytes (86 base64url chars, or 88 with padding)
-	if len(signature) < 80 || len(signature) > 100 {
-		return fmt.Errorf("%w: unexpected signature length", ErrInvalidSignature)
+// isDERSignature reports whether raw is a DER ECDSA signature whose r and s
+// are positive and fit in size bytes
+func isDERSignature(raw []byte, size int) bool {
+	var sig struct{ R, S *big.Int }
+	rest, err := asn1.Unmarshal(raw, &sig)
+	if err != nil || len(rest) != 0 {
+		return false
 	}
+	for _, v := range []*big.Int{sig.R, sig.S} {
+		if v.Sign() <= 0 || v.BitLen() > size*8 {
+			return false
+		}
+	}
+	return true
+}
 
-	return nil
+// requestableScopes are the scopes a caller may ask for at /v1/auth/verify
+var requestableScopes = map[string]bool{
+	"basic":   true,
+	"premium": true,
 }
 
-// ValidateScopes validates requested scopes
+// privilegedScopes may only be granted by an admin, on API keys: "debug"
+// explains policy denials, which reveals what each route requires
+var privilegedScopes = map[string]bool{
+	"debug": true,
+}
+
+// ValidateScopes validates scopes a caller requests for its own token
 func ValidateScopes(scopes []string) error {
 	if len(scopes) == 0 {
 		return nil // Empty scopes are allowed (will default to 'basic')
 	}
 
-	validScopes := map[string]bool{
-		"basic":   true,
-		"premium": true,
-	}
-
 	for _, scope := range scopes {
-		if !validScopes[scope] {
+		if !requestableScopes[scope] {
 			return fmt.Errorf("%w: unknown scope '%s'", ErrInvalidScopes, scope)
 		}
 	}
@@ -101,21 +212,37 @@ func ValidateScopes(scopes []string) error {
 	return nil
 }
 
-// ValidateChallenge validates the challenge string format
-func ValidateChallenge(challenge string) error {
-	if challenge == "" {
-		return errors.New("challenge cannot be empty")
+// ValidateKeyScopes validates scopes an admin grants to an API key, which
+// may include privileged scopes
+func ValidateKeyScopes(scopes []string) error {
+	for _, scope := range scopes {
+		if !requestableScopes[scope] && !privilegedScopes[scope] {
+			return fmt.Errorf("%w: unknown scope '%s'", ErrInvalidScopes, scope)
+		}
 	}
+	return nil
+}
 
-	// Challenge should contain required fields
-	requiredFields := []string{"did=", "nonce=", "aud=", "domain=", "exp="}
-	for _, field := range requiredFields {
-		if !strings.Contains(challenge, field) {
-			return fmt.Errorf("challenge missing required field: %s", field)
-		}
+// ValidateVerifyRequest checks the caller-supplied fields of a
+// /v1/auth/verify request before any signature work
+func ValidateVerifyRequest(req *models.AuthVerifyRequest) error {
+	if err := ValidateDID(req.DID); err != nil {
+		return err
 	}
+	if err := ValidateSignature(req.Signature); err != nil {
+		return err
+	}
+	if err := ValidateChallenge(req.Challenge); err != nil {
+		return err
+	}
+	return ValidateScopes(req.Scopes)
+}
 
-	return nil
+// ValidateChallenge validates the challenge string format. Any supported
+// format version is accepted.
+func ValidateChallenge(s string) error {
+	_, err := challenge.Parse(s)
+	return err
 }
 
 // ValidateTTL validates a time-to-live duration
@@ -146,3 +273,14 @@ func SanitizeString(s string, maxLen int) string {
 
 	return s
 }
+
+// ValidateIssuer checks the fields of a trusted issuer before it is stored
+func ValidateIssuer(iss models.Issuer) error {
+	if err := ValidateDID(iss.DID); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidIssuer, err)
+	}
+	if !iss.KeyPinning.Valid() {
+		return fmt.Errorf("%w: unknown key_pinning %q", ErrInvalidIssuer, iss.KeyPinning)
+	}
+	return nil
+}
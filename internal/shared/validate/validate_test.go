@@ -0,0 +1,58 @@
+package validate_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/example/privacy-gateway/internal/shared/models"
+	"github.com/example/privacy-gateway/internal/shared/validate"
+)
+
+const (
+	testDID       = "did:key:z6MkhaXgBZDvotDkL5257faiztiGiC2QtKLGpbnnEGta2doK"
+	testChallenge = "v=2\ndid=" + testDID + "\nnonce=n\naud=did-gateway\ndomain=localhost\niat=1700000000\nexp=1700000300\n"
+	// 64 zero bytes, unpadded base64url
+	testSignature = "AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA"
+)
+
+// TestVerifyRequestRejectsDebugScope checks that callers can't grant
+// themselves the scope that explains policy denials
+func TestVerifyRequestRejectsDebugScope(t *testing.T) {
+	req := &models.AuthVerifyRequest{DID: testDID, Challenge: testChallenge, Signature: testSignature, Scopes: []string{"basic"}}
+	if err := validate.ValidateVerifyRequest(req); err != nil {
+		t.Fatalf("basic scope: %v", err)
+	}
+
+	req.Scopes = []string{"basic", "debug"}
+	if err := validate.ValidateVerifyRequest(req); !errors.Is(err, validate.ErrInvalidScopes) {
+		t.Fatalf("debug scope: err = %v, want ErrInvalidScopes", err)
+	}
+
+	if err := validate.ValidateKeyScopes([]string{"basic", "debug"}); err != nil {
+		t.Fatalf("admin-issued key with debug scope: %v", err)
+	}
+}
+
+// TestValidateIssuerKeyPinning checks that only known key pinning modes
+// are stored
+func TestValidateIssuerKeyPinning(t *testing.T) {
+	cases := []struct {
+		mode models.KeyPinningMode
+		ok   bool
+	}{
+		{"", true},
+		{models.KeyPinningOff, true},
+		{models.KeyPinningEnforce, true},
+		{"Enforce", false},
+		{"strict", false},
+	}
+	for _, c := range cases {
+		err := validate.ValidateIssuer(models.Issuer{DID: testDID, KeyPinning: c.mode})
+		if c.ok && err != nil {
+			t.Errorf("%q: %v", c.mode, err)
+		}
+		if !c.ok && !errors.Is(err, validate.ErrInvalidIssuer) {
+			t.Errorf("%q: err = %v, want ErrInvalidIssuer", c.mode, err)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package conformance
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/example/privacy-gateway/internal/gateway/did"
+	"github.com/example/privacy-gateway/internal/shared/crypto"
+)
+
+type didKeyVectors struct {
+	Ed25519 []struct {
+		Name      string `json:"name"`
+		Seed      string `json:"seed"`
+		PublicKey string `json:"publicKey"`
+		DID       string `json:"did"`
+	} `json:"ed25519"`
+	Invalid []struct {
+		Name string `json:"name"`
+		DID  string `json:"did"`
+	} `json:"invalid"`
+}
+
+func TestDidKeyEd25519(t *testing.T) {
+	var v didKeyVectors
+	load(t, "did-key.json", &v)
+	for _, tc := range v.Ed25519 {
+		t.Run(tc.Name, func(t *testing.T) {
+			want := ed25519.PublicKey(unhex(t, tc.PublicKey))
+			if tc.Seed != "" {
+				pub := ed25519.NewKeyFromSeed(unhex(t, tc.Seed)).Public().(ed25519.PublicKey)
+				if !pub.Equal(want) {
+					t.Fatalf("seed derives %x, vector says %x", pub, want)
+				}
+			}
+			if got := crypto.EncodeDidKey(want); got != tc.DID {
+				t.Errorf("EncodeDidKey = %s, want %s", got, tc.DID)
+			}
+			pub, err := crypto.DecodeDidKey(tc.DID)
+			if err != nil {
+				t.Fatalf("DecodeDidKey: %v", err)
+			}
+			if !pub.Equal(want) {
+				t.Errorf("DecodeDidKey = %x, want %x", pub, want)
+			}
+		})
+	}
+}
+
+func TestDidKeyInvalid(t *testing.T) {
+	var v didKeyVectors
+	load(t, "did-key.json", &v)
+	for _, tc := range v.Invalid {
+		t.Run(tc.Name, func(t *testing.T) {
+			if pub, err := crypto.DecodeDidKey(tc.DID); err == nil {
+				t.Errorf("DecodeDidKey accepted %s as %x", tc.DID, pub)
+			}
+			if _, err := (did.KeyResolver{}).Resolve(context.Background(), tc.DID, did.ResolveOptions{}); !errors.Is(err, did.ErrInvalidDIDURL) {
+				t.Errorf("Resolve error = %v, want ErrInvalidDIDURL", err)
+			}
+		})
+	}
+}
+
+// TestDidKeyDocument compares the resolved document with the did:key spec's
+// Ed25519VerificationKey2020 representation. The gateway doesn't derive the
+// X25519 keyAgreement key (the spec's enableEncryptionKeyDerivation option),
+// so the fixture has none.
+func TestDidKeyDocument(t *testing.T) {
+	const id = "did:key:z6MkiTBz1ymuepAQ4HEHYSF1H8quG5GLVVQR3djdX3mDooWp"
+	want, err := os.ReadFile(filepath.Join("testdata", "documents", "did-key-zero-seed.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc, err := (did.KeyResolver{}).Resolve(context.Background(), id, did.ResolveOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := json.Marshal(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !jsonEqual(t, got, want) {
+		t.Errorf("resolved document\n%s\ndiffers from fixture\n%s", got, want)
+	}
+
+	pub := ed25519.NewKeyFromSeed(make([]byte, ed25519.SeedSize)).Public().(ed25519.PublicKey)
+	for _, ref := range []string{doc.VerificationMethod[0].ID, "#z6MkiTBz1ymuepAQ4HEHYSF1H8quG5GLVVQR3djdX3mDooWp"} {
+		if k, err := doc.AuthenticationKey(ref); err != nil || !k.Equal(pub) {
+			t.Errorf("AuthenticationKey(%s) = %x, %v", ref, k, err)
+		}
+		if k, err := doc.AssertionKey(ref); err != nil || !k.Equal(pub) {
+			t.Errorf("AssertionKey(%s) = %x, %v", ref, k, err)
+		}
+	}
+}
+
+func TestEd25519RFC8032(t *testing.T) {
+	var v struct {
+		Vectors []struct {
+			Name      string `json:"name"`
+			SecretKey string `json:"secretKey"`
+			PublicKey string `json:"publicKey"`
+			Message   string `json:"message"`
+			Signature string `json:"signature"`
+		} `json:"vectors"`
+	}
+	load(t, "ed25519-rfc8032.json", &v)
+	for _, tc := range v.Vectors {
+		t.Run(tc.Name, func(t *testing.T) {
+			priv := ed25519.NewKeyFromSeed(unhex(t, tc.SecretKey))
+			pub := priv.Public().(ed25519.PublicKey)
+			if !bytes.Equal(pub, unhex(t, tc.PublicKey)) {
+				t.Fatalf("public key %x, want %s", pub, tc.PublicKey)
+			}
+			msg, sig := unhex(t, tc.Message), unhex(t, tc.Signature)
+			if got := ed25519.Sign(priv, msg); !bytes.Equal(got, sig) {
+				t.Fatalf("signature %x, want %s", got, tc.Signature)
+			}
+
+			// The gateway's verify path, as used for challenge responses
+			enc := base64.RawURLEncoding.EncodeToString(sig)
+			if err := crypto.VerifySignature(pub, string(msg), enc); err != nil {
+				t.Errorf("VerifySignature: %v", err)
+			}
+			sig[0] ^= 1
+			if err := crypto.VerifySignature(pub, string(msg), base64.RawURLEncoding.EncodeToString(sig)); err == nil {
+				t.Error("VerifySignature accepted a corrupted signature")
+			}
+			if err := crypto.VerifySignature(pub, string(msg)+"x", enc); err == nil {
+				t.Error("VerifySignature accepted a different message")
+			}
+		})
+	}
+}
@@ -0,0 +1,6 @@
+// Package conformance checks the gateway's DID handling against published
+// test vectors: the did:key Ed25519 encoding, RFC 8032 signatures, DID Core
+// syntax and documents, and the did:web URL mapping. The vectors live in
+// testdata and run with `make test`; a failure means behavior drifted from
+// the specs, not that a vector should be edited to match.
+package conformance
@@ -0,0 +1,115 @@
+package conformance
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/example/privacy-gateway/internal/gateway/did"
+)
+
+func TestDocuments(t *testing.T) {
+	var v struct {
+		Valid []struct {
+			File            string            `json:"file"`
+			ID              string            `json:"id"`
+			Authentication  map[string]string `json:"authentication"`
+			AssertionMethod map[string]string `json:"assertionMethod"`
+			Dereference     []struct {
+				URL  string `json:"url"`
+				Kind string `json:"kind"`
+				ID   string `json:"id"`
+			} `json:"dereference"`
+		} `json:"valid"`
+		Invalid []string `json:"invalid"`
+	}
+	load(t, "documents.json", &v)
+
+	for _, tc := range v.Valid {
+		t.Run(tc.File, func(t *testing.T) {
+			data, err := os.ReadFile(filepath.Join("testdata", tc.File))
+			if err != nil {
+				t.Fatal(err)
+			}
+			doc, err := did.ParseDocument(data, did.DocumentLimits{})
+			if err != nil {
+				t.Fatalf("ParseDocument: %v", err)
+			}
+			if doc.ID != tc.ID {
+				t.Errorf("id = %q, want %q", doc.ID, tc.ID)
+			}
+			checkKeys(t, "AuthenticationKey", doc.AuthenticationKey, tc.Authentication)
+			checkKeys(t, "AssertionKey", doc.AssertionKey, tc.AssertionMethod)
+
+			for _, d := range tc.Dereference {
+				u, err := did.ParseURL(d.URL)
+				if err != nil {
+					t.Fatalf("ParseURL(%q): %v", d.URL, err)
+				}
+				res, err := did.Dereference(doc, u)
+				var got string
+				switch r := res.(type) {
+				case *did.Document:
+					got = r.ID
+				case *did.VerificationMethod:
+					got = r.ID
+				case *did.Service:
+					got = r.ID
+				case string:
+					got = r
+				}
+				if d.Kind == "notFound" {
+					if !errors.Is(err, did.ErrNotFound) {
+						t.Errorf("Dereference(%s) = %v, %v, want ErrNotFound", d.URL, res, err)
+					}
+					continue
+				}
+				if err != nil || kind(res) != d.Kind || got != d.ID {
+					t.Errorf("Dereference(%s) = %s %q, %v, want %s %q", d.URL, kind(res), got, err, d.Kind, d.ID)
+				}
+			}
+		})
+	}
+
+	for _, file := range v.Invalid {
+		data, err := os.ReadFile(filepath.Join("testdata", file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := did.ParseDocument(data, did.DocumentLimits{}); !errors.Is(err, did.ErrMalformedDocument) {
+			t.Errorf("ParseDocument(%s) = %v, want ErrMalformedDocument", file, err)
+		}
+	}
+}
+
+// checkKeys looks each method up and compares it with the expected hex key;
+// an empty expectation means the lookup must fail
+func checkKeys(t *testing.T, name string, lookup func(string) (ed25519.PublicKey, error), want map[string]string) {
+	t.Helper()
+	for id, hexKey := range want {
+		got, err := lookup(id)
+		switch {
+		case hexKey == "" && err == nil:
+			t.Errorf("%s(%s) = %x, want an error", name, id, got)
+		case hexKey != "" && (err != nil || hex.EncodeToString(got) != hexKey):
+			t.Errorf("%s(%s) = %x, %v, want %s", name, id, got, err, hexKey)
+		}
+	}
+}
+
+func kind(res interface{}) string {
+	switch res.(type) {
+	case *did.Document:
+		return "document"
+	case *did.VerificationMethod:
+		return "verificationMethod"
+	case *did.Service:
+		return "service"
+	case string:
+		return "url"
+	}
+	return "none"
+}
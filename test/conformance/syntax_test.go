@@ -0,0 +1,105 @@
+package conformance
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/example/privacy-gateway/internal/gateway/did"
+	"github.com/example/privacy-gateway/internal/shared/validate"
+)
+
+type syntaxVectors struct {
+	Supported   []string `json:"supported"`
+	Unsupported []string `json:"unsupported"`
+	Invalid     []string `json:"invalid"`
+	URLs        []struct {
+		URL      string            `json:"url"`
+		DID      string            `json:"did"`
+		Path     string            `json:"path"`
+		Query    map[string]string `json:"query"`
+		Fragment string            `json:"fragment"`
+	} `json:"urls"`
+	InvalidURLs []string `json:"invalidURLs"`
+}
+
+func TestDIDSyntax(t *testing.T) {
+	var v syntaxVectors
+	load(t, "did-syntax.json", &v)
+	for _, s := range v.Supported {
+		if err := validate.ValidateDID(s); err != nil {
+			t.Errorf("ValidateDID(%q) = %v, want nil", s, err)
+		}
+	}
+	for _, s := range v.Unsupported {
+		if err := validate.ValidateDID(s); !errors.Is(err, validate.ErrInvalidDIDMethod) {
+			t.Errorf("ValidateDID(%q) = %v, want ErrInvalidDIDMethod", s, err)
+		}
+	}
+	for _, s := range v.Invalid {
+		if err := validate.ValidateDID(s); !errors.Is(err, validate.ErrInvalidDID) {
+			t.Errorf("ValidateDID(%q) = %v, want ErrInvalidDID", s, err)
+		}
+	}
+}
+
+func TestDIDURLSyntax(t *testing.T) {
+	var v syntaxVectors
+	load(t, "did-syntax.json", &v)
+	for _, tc := range v.URLs {
+		u, err := did.ParseURL(tc.URL)
+		if err != nil {
+			t.Errorf("ParseURL(%q): %v", tc.URL, err)
+			continue
+		}
+		if u.DID != tc.DID || u.Path != tc.Path || u.Fragment != tc.Fragment {
+			t.Errorf("ParseURL(%q) = did %q path %q fragment %q, want %q %q %q",
+				tc.URL, u.DID, u.Path, u.Fragment, tc.DID, tc.Path, tc.Fragment)
+		}
+		if len(u.Query) != len(tc.Query) {
+			t.Errorf("ParseURL(%q) query = %v, want %v", tc.URL, u.Query, tc.Query)
+		}
+		for k, want := range tc.Query {
+			if got := u.Query.Get(k); got != want {
+				t.Errorf("ParseURL(%q) %s = %q, want %q", tc.URL, k, got, want)
+			}
+		}
+		if opts := u.ResolveOptions(); opts.VersionID != tc.Query["versionId"] || opts.VersionTime != tc.Query["versionTime"] {
+			t.Errorf("ParseURL(%q) resolve options = %+v", tc.URL, opts)
+		}
+	}
+	for _, s := range v.InvalidURLs {
+		if _, err := did.ParseURL(s); !errors.Is(err, did.ErrInvalidDIDURL) {
+			t.Errorf("ParseURL(%q) = %v, want ErrInvalidDIDURL", s, err)
+		}
+	}
+}
+
+func TestDidWebURL(t *testing.T) {
+	var v struct {
+		Vectors []struct {
+			DID       string `json:"did"`
+			VersionID string `json:"versionId"`
+			URL       string `json:"url"`
+		} `json:"vectors"`
+		Invalid []string `json:"invalid"`
+	}
+	load(t, "did-web.json", &v)
+	w, err := did.NewWebResolver(did.WebConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range v.Vectors {
+		got, err := w.DocumentURL(tc.DID, tc.VersionID)
+		if err != nil || got != tc.URL {
+			t.Errorf("DocumentURL(%q) = %q, %v, want %q", tc.DID, got, err, tc.URL)
+		}
+		if norm, err := did.NormalizeWebDID(tc.DID); err != nil || norm != tc.DID {
+			t.Errorf("NormalizeWebDID(%q) = %q, %v", tc.DID, norm, err)
+		}
+	}
+	for _, s := range v.Invalid {
+		if got, err := w.DocumentURL(s, ""); err == nil {
+			t.Errorf("DocumentURL(%q) = %q, want an error", s, got)
+		}
+	}
+}